@@ -1,27 +1,180 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"flag"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/JannisK89/notes-api/internal/db"
+	"github.com/JannisK89/notes-api/internal/events"
 	"github.com/JannisK89/notes-api/internal/handlers"
+	"github.com/JannisK89/notes-api/internal/models"
 	"github.com/JannisK89/notes-api/internal/repository"
 	"github.com/JannisK89/notes-api/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+// dbPath is the location of the SQLite database used unless DATABASE_URL is
+// set.
+const dbPath = "./notes.db"
+
+// shutdownTimeout is how long the server waits for open requests to finish
+// when it is stopped.
+const shutdownTimeout = 10 * time.Second
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:], os.Stdout); err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		return
+	}
+
+	storage := flag.String("storage", "database", "where notes are stored: database, or memory to keep them in memory only")
+	snapshot := flag.String("snapshot", "", "JSON file the memory storage is loaded from at start and saved to on shutdown")
+	flag.Parse()
 
-	dbconn, err := db.NewSQLiteDB("./notes.db")
+	var repos *repositories
+	var err error
+	switch *storage {
+	case "database":
+		repos, err = openDatabase(databaseURL())
+	case "memory":
+		repos, err = openMemory(*snapshot)
+	default:
+		log.Fatalf("--storage must be database or memory, got %q", *storage)
+	}
 	if err != nil {
-		log.Fatal("Could not connect to Database: ", err)
+		log.Fatal("Could not open storage: ", err)
 	}
+	notesRepo := repos.notes
 
-	notesRepo := repository.NewNotesRepository(dbconn)
+	broker := events.NewBroker(intEnv("EVENT_LOG_SIZE", events.DefaultLogSize))
+	eventsHandler := handlers.NewEventHandler(broker)
+	eventsHandler.Heartbeat = durationEnv("EVENT_HEARTBEAT", eventsHandler.Heartbeat)
 	notesService := service.NewNoteService(notesRepo)
+	notesService.Events = broker
+	notesService.Tx = repos.tx
 	notesHandler := handlers.NewNoteHandler(notesService)
+	notesHandler.Timeout = durationEnv("REQUEST_TIMEOUT", notesHandler.Timeout)
+	notesHandler.ShareLinkAttempts.Limit = intEnv("SHARE_LINK_ATTEMPTS", notesHandler.ShareLinkAttempts.Limit)
+	notesHandler.ShareLinkAttempts.Window = durationEnv("SHARE_LINK_ATTEMPT_WINDOW", notesHandler.ShareLinkAttempts.Window)
+	workspacesService := service.NewWorkspaceService(repos.workspaces)
+	collabHub := service.NewCollabHub(notesRepo)
+	collabHub.Events = broker
+	collabHub.Members = workspacesService
+	collabHub.SaveInterval = durationEnv("COLLAB_SAVE_INTERVAL", collabHub.SaveInterval)
+	collabHub.AccessInterval = durationEnv("COLLAB_ACCESS_INTERVAL", collabHub.AccessInterval)
+	collabHandler := handlers.NewCollabHandler(collabHub)
+	collabHandler.PingInterval = durationEnv("COLLAB_PING_INTERVAL", collabHandler.PingInterval)
+	collabHandler.AllowedOrigins = listEnv("COLLAB_ALLOWED_ORIGINS")
+	notebooksService := service.NewNotebookService(repos.notebooks)
+	notebooksHandler := handlers.NewNotebookHandler(notebooksService, notesService)
+	usersService := service.NewUserService(repos.users)
+	usersService.SessionTTL = durationEnv("SESSION_TTL", usersService.SessionTTL)
+	usersService.TicketTTL = durationEnv("TICKET_TTL", usersService.TicketTTL)
+	usersHandler := handlers.NewUserHandler(usersService)
+	workspacesHandler := handlers.NewWorkspaceHandler(workspacesService)
+	eventsHandler.AccessInterval = durationEnv("EVENT_ACCESS_INTERVAL", eventsHandler.AccessInterval)
+	eventsHandler.Members = workspacesService
+	eventsHandler.Users = usersService
+	webhooksRepo := repos.webhooks
+	// Webhooks may only call private addresses, such as services on the
+	// same host or network, if the operator allows it explicitly.
+	allowPrivateTargets := boolEnv("WEBHOOK_ALLOW_PRIVATE_TARGETS", false)
+	webhooksService := service.NewWebhookService(webhooksRepo)
+	webhooksService.AllowPrivateTargets = allowPrivateTargets
+	webhooksHandler := handlers.NewWebhookHandler(webhooksService)
+
+	purger := service.NewTrashPurger(notesRepo)
+	purger.Retention = durationEnv("TRASH_RETENTION", purger.Retention)
+	purger.Interval = durationEnv("TRASH_PURGE_INTERVAL", purger.Interval)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go purger.Run(ctx)
+
+	dispatcher := service.NewWebhookDispatcher(webhooksRepo)
+	dispatcher.MaxAttempts = intEnv("WEBHOOK_MAX_ATTEMPTS", dispatcher.MaxAttempts)
+	dispatcher.RetryDelay = durationEnv("WEBHOOK_RETRY_DELAY", dispatcher.RetryDelay)
+	dispatcher.AllowPrivateTargets = allowPrivateTargets
+	go dispatcher.Run(ctx)
+
+	// API keys need the scope of an endpoint to call it, sessions may call
+	// all of them.
+	canRead := handlers.RequireScope(models.ScopeNotesRead)
+	canWrite := handlers.RequireScope(models.ScopeNotesWrite)
+	canDelete := handlers.RequireScope(models.ScopeNotesDelete)
+
+	// workspaceRoutes registers the endpoints that act within the workspace
+	// selected for a request.
+	workspaceRoutes := func(r chi.Router) {
+		r.Route("/notes", func(r chi.Router) {
+			r.With(canRead).Get("/", notesHandler.GetAll)
+			r.With(canWrite).Post("/", notesHandler.Create)
+			r.With(canRead).Get("/search", notesHandler.Search)
+			r.With(canRead).Get("/{noteId}", notesHandler.Get)
+			r.With(canRead).Get("/{noteId}/collab", collabHandler.Edit)
+			r.With(canWrite).Put("/{noteId}", notesHandler.Update)
+			r.With(canWrite).Patch("/{noteId}", notesHandler.Patch)
+			r.With(canDelete).Delete("/{noteId}", notesHandler.Delete)
+			r.With(canWrite).Post("/{noteId}/restore", notesHandler.Restore)
+			r.With(canWrite).Post("/{noteId}/move", notesHandler.Move)
+			r.With(canRead).Get("/{noteId}/revisions", notesHandler.GetRevisions)
+			r.With(canRead).Get("/{noteId}/revisions/diff", notesHandler.DiffRevisions)
+			r.With(canRead).Get("/{noteId}/revisions/{revision}", notesHandler.GetRevision)
+			r.With(canWrite).Post("/{noteId}/revisions/{revision}/restore", notesHandler.RestoreRevision)
+			r.With(canRead).Get("/{noteId}/shares", notesHandler.GetShares)
+			r.With(canWrite).Post("/{noteId}/shares", notesHandler.Share)
+			r.With(canWrite).Delete("/{noteId}/shares/{userId}", notesHandler.Unshare)
+			r.With(canRead).Get("/{noteId}/share-links", notesHandler.GetShareLinks)
+			r.With(canWrite).Post("/{noteId}/share-links", notesHandler.CreateShareLink)
+			r.With(canWrite).Delete("/{noteId}/share-links/{linkId}", notesHandler.DeleteShareLink)
+		})
+		r.Route("/notebooks", func(r chi.Router) {
+			r.With(canRead).Get("/", notebooksHandler.GetAll)
+			r.With(canWrite).Post("/", notebooksHandler.Create)
+			r.With(canRead).Get("/{notebookId}", notebooksHandler.Get)
+			r.With(canWrite).Put("/{notebookId}", notebooksHandler.Rename)
+			r.With(canDelete).Delete("/{notebookId}", notebooksHandler.Delete)
+			r.With(canWrite).Post("/{notebookId}/move", notebooksHandler.Move)
+			r.With(canRead).Get("/{notebookId}/notes", notebooksHandler.GetNotes)
+			r.With(canRead).Get("/{notebookId}/shares", notebooksHandler.GetShares)
+			r.With(canWrite).Post("/{notebookId}/shares", notebooksHandler.Share)
+			r.With(canWrite).Delete("/{notebookId}/shares/{userId}", notebooksHandler.Unshare)
+		})
+		r.Route("/shared", func(r chi.Router) {
+			r.With(canRead).Get("/notes", notesHandler.GetShared)
+			r.With(canRead).Get("/notebooks", notebooksHandler.GetShared)
+		})
+		r.Route("/tags", func(r chi.Router) {
+			r.With(canRead).Get("/", notesHandler.GetTags)
+			r.With(canWrite).Post("/{tag}/rename", notesHandler.RenameTag)
+			r.With(canWrite).Post("/{tag}/merge", notesHandler.MergeTags)
+		})
+		r.Route("/trash", func(r chi.Router) {
+			r.With(canRead).Get("/", notesHandler.GetTrash)
+			r.With(canDelete).Delete("/{noteId}", notesHandler.Purge)
+		})
+		r.Route("/webhooks", func(r chi.Router) {
+			r.With(canRead).Get("/", webhooksHandler.GetAll)
+			r.With(canWrite).Post("/", webhooksHandler.Create)
+			r.With(canDelete).Delete("/{webhookId}", webhooksHandler.Delete)
+			r.With(canRead).Get("/{webhookId}/deliveries", webhooksHandler.GetDeliveries)
+			r.With(canWrite).Post("/{webhookId}/deliveries/{deliveryId}/redeliver", webhooksHandler.Redeliver)
+		})
+		r.With(canRead).Get("/events", eventsHandler.Stream)
+		r.With(canRead).Get("/sync", notesHandler.GetChanges)
+		r.With(canWrite).Post("/sync", notesHandler.Push)
+	}
 
 	r := chi.NewRouter()
 
@@ -30,19 +183,214 @@ func main() {
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 
+	// Share links are public, whoever has the token may read the note.
+	r.Get(handlers.ShareLinkPath+"{token}", notesHandler.ViewShareLink)
+
 	r.Route("/api/v1", func(r chi.Router) {
-		r.Route("/notes", func(r chi.Router) {
-			r.Get("/", notesHandler.GetAll)
-			r.Post("/", notesHandler.Create)
-			r.Get("/{noteId}", notesHandler.Get)
-			r.Put("/{noteId}", notesHandler.Update)
-			r.Delete("/{noteId}", notesHandler.Delete)
+		r.Post("/auth/register", usersHandler.Register)
+		r.Post("/auth/login", usersHandler.Login)
+
+		r.Group(func(r chi.Router) {
+			r.Use(usersHandler.Authenticate)
+
+			r.Get("/auth/me", usersHandler.Me)
+			r.Group(func(r chi.Router) {
+				r.Use(handlers.RequireSession)
+
+				r.Post("/auth/logout", usersHandler.Logout)
+				r.Post("/auth/tickets", usersHandler.CreateTicket)
+				r.Get("/auth/keys", usersHandler.GetAPIKeys)
+				r.Post("/auth/keys", usersHandler.CreateAPIKey)
+				r.Delete("/auth/keys/{keyId}", usersHandler.DeleteAPIKey)
+			})
+			r.With(canRead).Get("/workspaces", workspacesHandler.GetAll)
+			r.With(canWrite).Post("/workspaces", workspacesHandler.Create)
+			// Notes, notebooks and tags live in a workspace, given by the
+			// path or the X-Workspace-Id header and otherwise the personal
+			// workspace of the user.
+			r.Group(func(r chi.Router) {
+				r.Use(workspacesHandler.SelectWorkspace)
+
+				workspaceRoutes(r)
+			})
+			r.Route("/workspaces/{workspaceId}", func(r chi.Router) {
+				r.Use(workspacesHandler.SelectWorkspace)
+
+				r.With(canRead).Get("/members", workspacesHandler.GetMembers)
+				r.With(canWrite).Post("/members", workspacesHandler.AddMember)
+				r.With(canWrite).Delete("/members/{userId}", workspacesHandler.RemoveMember)
+				workspaceRoutes(r)
+			})
 		})
 	})
 
-	error := http.ListenAndServe(":3000", r)
-	if error != nil {
-		log.Fatal("Could not start server: ", error)
+	server := &http.Server{Addr: ":3000", Handler: r}
+	// Event streams never finish on their own, closing the broker ends them
+	// so that the server can shut down.
+	server.RegisterOnShutdown(broker.Close)
+	go func() {
+		error := server.ListenAndServe()
+		if error != nil && error != http.ErrServerClosed {
+			log.Fatal("Could not start server: ", error)
+		}
+	}()
+
+	<-ctx.Done()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Println("Could not shut down server: ", err)
+	}
+	// Shutdown does not wait for WebSockets, so the editing sessions are
+	// closed separately, storing the text of every note being edited.
+	collabHub.Close()
+	if repos.memory != nil && *snapshot != "" {
+		if err := repos.memory.Save(*snapshot); err != nil {
+			log.Println("Could not save snapshot: ", err)
+		}
+	}
+}
+
+// databaseURL returns the database the server uses: the postgres:// URL or
+// SQLite path in DATABASE_URL, or dbPath if it is unset.
+func databaseURL() string {
+	if url := os.Getenv("DATABASE_URL"); url != "" {
+		return url
+	}
+	return dbPath
+}
+
+// poolConfig returns the connection pool for PostgreSQL, tuned by the
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_CONN_MAX_LIFETIME and
+// DB_CONN_MAX_IDLE_TIME variables.
+func poolConfig() db.PoolConfig {
+	pool := db.DefaultPoolConfig
+	pool.MaxOpenConns = intEnv("DB_MAX_OPEN_CONNS", pool.MaxOpenConns)
+	pool.MaxIdleConns = intEnv("DB_MAX_IDLE_CONNS", pool.MaxIdleConns)
+	pool.ConnMaxLifetime = durationEnv("DB_CONN_MAX_LIFETIME", pool.ConnMaxLifetime)
+	pool.ConnMaxIdleTime = durationEnv("DB_CONN_MAX_IDLE_TIME", pool.ConnMaxIdleTime)
+	return pool
+}
+
+// repositories are the repositories the server stores its data with.
+// memory is the store behind them for the memory storage.
+type repositories struct {
+	notes      repository.NoteRepository
+	notebooks  repository.NotebookRepository
+	users      repository.UserRepository
+	workspaces repository.WorkspaceRepository
+	webhooks   repository.WebhookRepository
+	tx         repository.TxManager
+	memory     *repository.MemoryStore
+}
+
+// openDatabase connects to the PostgreSQL database at dsn if it is a
+// postgres:// URL and to the SQLite database at path dsn otherwise, applies
+// any pending migrations and returns the repositories written for it.
+func openDatabase(dsn string) (*repositories, error) {
+	maxRevisions := intEnv("MAX_REVISIONS", repository.DefaultMaxRevisions)
+	var dbconn *sql.DB
+	var notesRepo repository.NoteRepository
+	if db.IsPostgresDSN(dsn) {
+		var err error
+		if dbconn, err = db.NewPostgresDB(dsn, poolConfig()); err != nil {
+			return nil, err
+		}
+		postgresNotes := repository.NewPostgresNotesRepository(dbconn)
+		postgresNotes.MaxRevisions = maxRevisions
+		notesRepo = postgresNotes
+	} else {
+		var err error
+		if dbconn, err = db.NewSQLiteDB(dsn); err != nil {
+			return nil, err
+		}
+		sqliteNotes := repository.NewNotesRepository(dbconn)
+		sqliteNotes.MaxRevisions = maxRevisions
+		notesRepo = sqliteNotes
 	}
+	return &repositories{
+		notes:      notesRepo,
+		notebooks:  repository.NewNotebooksRepository(dbconn),
+		users:      repository.NewUsersRepository(dbconn),
+		workspaces: repository.NewWorkspacesRepository(dbconn),
+		webhooks:   repository.NewWebhooksRepository(dbconn),
+		tx:         repository.NewTxManager(dbconn),
+	}, nil
+}
 
+// openMemory returns repositories that keep everything in memory, starting
+// from the snapshot at path unless path is empty.
+func openMemory(path string) (*repositories, error) {
+	store := repository.NewMemoryStore()
+	if path != "" {
+		var err error
+		if store, err = repository.LoadMemoryStore(path); err != nil {
+			return nil, err
+		}
+	}
+	notesRepo := repository.NewMemoryNotesRepository(store)
+	notesRepo.MaxRevisions = intEnv("MAX_REVISIONS", repository.DefaultMaxRevisions)
+	return &repositories{
+		notes:      notesRepo,
+		notebooks:  repository.NewMemoryNotebooksRepository(store),
+		users:      repository.NewMemoryUsersRepository(store),
+		workspaces: repository.NewMemoryWorkspacesRepository(store),
+		webhooks:   repository.NewMemoryWebhooksRepository(store),
+		tx:         repository.NewMemoryTxManager(store),
+		memory:     store,
+	}, nil
+}
+
+// durationEnv returns the duration in the environment variable key, or def if
+// it is unset. It exits if the variable is not a positive duration.
+func durationEnv(key string, def time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Fatalf("%s must be a positive duration such as 720h, got %q", key, value)
+	}
+	return d
+}
+
+// intEnv returns the integer in the environment variable key, or def if it
+// is unset. It exits if the variable is not a non-negative integer.
+func intEnv(key string, def int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Fatalf("%s must be a non-negative integer, got %q", key, value)
+	}
+	return n
+}
+
+// listEnv returns the comma-separated values in the environment variable
+// key without surrounding spaces, or nil if it is unset.
+func listEnv(key string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// boolEnv returns the boolean in the environment variable key, or def if it
+// is unset. It exits if the variable is not a boolean such as true or false.
+func boolEnv(key string, def bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Fatalf("%s must be true or false, got %q", key, value)
+	}
+	return b
 }
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/JannisK89/notes-api/internal/db"
+	"github.com/JannisK89/notes-api/internal/migrate"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+// runMigrate implements the migrate subcommand, which applies, rolls back or
+// lists the schema migrations of the database, or adopts the data created
+// before there were users into the personal workspace of a user.
+func runMigrate(args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the migrations that would run without running them")
+	steps := flags.Int("steps", 1, "number of migrations to roll back with down")
+	email := flags.String("email", "", "email of the user who adopts the data created before there were users with adopt")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s migrate [flags] up|down|status|adopt\n", os.Args[0])
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("expected one of up, down, status or adopt")
+	}
+
+	dbconn, migrator, err := openMigrator(databaseURL())
+	if err != nil {
+		return err
+	}
+	defer dbconn.Close()
+	migrator.Logf = func(format string, args ...interface{}) {
+		fmt.Fprintf(stdout, format+"\n", args...)
+	}
+
+	switch flags.Arg(0) {
+	case "up":
+		migrations, err := migrator.Up(*dryRun)
+		if *dryRun {
+			printPlan(stdout, "apply", migrations, true)
+		} else if err == nil && len(migrations) == 0 {
+			fmt.Fprintln(stdout, "Database is up to date")
+		}
+		return err
+	case "down":
+		migrations, err := migrator.Down(*steps, *dryRun)
+		if *dryRun {
+			printPlan(stdout, "roll back", migrations, false)
+		} else if err == nil && len(migrations) == 0 {
+			fmt.Fprintln(stdout, "Nothing to roll back")
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := ""
+			if !s.AppliedAt.IsZero() {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05 MST")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, s.State, appliedAt)
+		}
+		return w.Flush()
+	case "adopt":
+		if *email == "" {
+			flags.Usage()
+			return fmt.Errorf("adopt needs the -email of the user who adopts the data")
+		}
+		adopted, err := repository.NewUsersRepository(dbconn).AdoptLegacyData(*email, *dryRun)
+		if err != nil {
+			return err
+		}
+		action := "Adopted"
+		if *dryRun {
+			action = "Would adopt"
+		}
+		fmt.Fprintf(stdout, "%s %d notes, %d notebooks and %d tags into the personal workspace of %s\n",
+			action, adopted.Notes, adopted.Notebooks, adopted.Tags, *email)
+		return nil
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown migrate command %q", flags.Arg(0))
+	}
+}
+
+// printPlan lists the migrations a dry run would apply or roll back along
+// with their SQL.
+func printPlan(w io.Writer, action string, migrations []migrate.Migration, up bool) {
+	if len(migrations) == 0 {
+		fmt.Fprintf(w, "No migrations to %s\n", action)
+		return
+	}
+	for _, m := range migrations {
+		fmt.Fprintf(w, "-- Would %s %04d_%s\n", action, m.Version, m.Name)
+		sql := m.UpSQL
+		if !up {
+			sql = m.DownSQL
+		}
+		if sql == "" {
+			sql = "-- (Go migration)\n"
+		}
+		fmt.Fprintln(w, sql)
+	}
+}
+
+// openMigrator connects to the database at dsn like openDatabase, without
+// migrating it, and returns a migrator for its schema.
+func openMigrator(dsn string) (*sql.DB, *migrate.Migrator, error) {
+	var dbconn *sql.DB
+	var err error
+	newMigrator := db.NewSQLiteMigrator
+	if db.IsPostgresDSN(dsn) {
+		dbconn, err = db.OpenPostgresDB(dsn, poolConfig())
+		newMigrator = db.NewPostgresMigrator
+	} else {
+		dbconn, err = db.OpenSQLiteDB(dsn)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	migrator, err := newMigrator(dbconn)
+	if err != nil {
+		dbconn.Close()
+		return nil, nil, err
+	}
+	return dbconn, migrator, nil
+}
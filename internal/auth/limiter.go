@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// AttemptLimiter limits the attempts made under a key, such as password
+// guesses for a share link or from a client address, to Limit per Window.
+// The window of a key starts with its first attempt. A Limit of 0 disables
+// the limiter. It is safe for concurrent use.
+type AttemptLimiter struct {
+	Limit  int
+	Window time.Duration
+
+	mu       sync.Mutex
+	attempts map[string]*attemptWindow
+	swept    time.Time
+	now      func() time.Time
+}
+
+// attemptWindow counts the attempts made under a key until reset.
+type attemptWindow struct {
+	count int
+	reset time.Time
+}
+
+// NewAttemptLimiter creates an AttemptLimiter that allows limit attempts
+// per key and window.
+func NewAttemptLimiter(limit int, window time.Duration) *AttemptLimiter {
+	return &AttemptLimiter{Limit: limit, Window: window, attempts: map[string]*attemptWindow{}, now: time.Now}
+}
+
+// Begin records an attempt under each of keys and reports whether it may be
+// made. If one of the keys has no attempts left, none is recorded and Begin
+// returns false and how long it takes until all of them have attempts
+// again.
+func (l *AttemptLimiter) Begin(keys ...string) (bool, time.Duration) {
+	if l.Limit <= 0 {
+		return true, 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+
+	wait := time.Duration(0)
+	for _, key := range keys {
+		if w, ok := l.attempts[key]; ok && w.count >= l.Limit && now.Before(w.reset) {
+			wait = max(wait, w.reset.Sub(now))
+		}
+	}
+	if wait > 0 {
+		return false, wait
+	}
+	for _, key := range keys {
+		w, ok := l.attempts[key]
+		if !ok || !now.Before(w.reset) {
+			w = &attemptWindow{reset: now.Add(l.Window)}
+			l.attempts[key] = w
+		}
+		w.count++
+	}
+	return true, 0
+}
+
+// Refund takes back an attempt recorded by Begin under each of keys, for
+// attempts that turn out not to count, such as those that succeed.
+func (l *AttemptLimiter) Refund(keys ...string) {
+	if l.Limit <= 0 {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, key := range keys {
+		if w, ok := l.attempts[key]; ok && w.count > 0 {
+			w.count--
+		}
+	}
+}
+
+// sweep forgets the keys whose window ended, at most once per window, so
+// that keys that are not used again do not pile up. The lock must be held.
+func (l *AttemptLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.Window {
+		return
+	}
+	for key, w := range l.attempts {
+		if !now.Before(w.reset) {
+			delete(l.attempts, key)
+		}
+	}
+	l.swept = now
+}
@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttemptLimiter_Begin(t *testing.T) {
+	// Arrange
+	now := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	limiter := NewAttemptLimiter(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	// Act
+	first, _ := limiter.Begin("link:a", "client:1")
+	second, _ := limiter.Begin("link:a", "client:2")
+	third, wait := limiter.Begin("link:a", "client:3")
+	otherLink, _ := limiter.Begin("link:b", "client:3")
+	now = now.Add(30 * time.Second)
+	limiter.Refund("link:a", "client:2")
+	refunded, _ := limiter.Begin("link:a", "client:2")
+	usedUp, _ := limiter.Begin("link:a", "client:4")
+	now = now.Add(30 * time.Second)
+	renewed, _ := limiter.Begin("link:a", "client:4")
+
+	// Assert
+	assert.True(t, first)
+	assert.True(t, second)
+	assert.False(t, third)
+	assert.Equal(t, time.Minute, wait)
+	assert.True(t, otherLink, "a denied attempt is not recorded")
+	assert.True(t, refunded)
+	assert.False(t, usedUp)
+	assert.True(t, renewed)
+}
+
+func TestAttemptLimiter_Disabled(t *testing.T) {
+	// Arrange
+	limiter := NewAttemptLimiter(0, time.Minute)
+
+	// Act
+	allowed := true
+	for i := 0; i < 100; i++ {
+		ok, _ := limiter.Begin("link:a")
+		allowed = allowed && ok
+	}
+
+	// Assert
+	assert.True(t, allowed)
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testParams keep the tests fast.
+var testParams = Params{Memory: 1024, Time: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashPassword(t *testing.T) {
+	// Act
+	hash, err := HashPassword("correct horse battery staple", testParams)
+	other, otherErr := HashPassword("correct horse battery staple", testParams)
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, otherErr)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.NotEqual(t, hash, other, "every hash has its own salt")
+}
+
+func TestCheckPassword(t *testing.T) {
+	// Arrange
+	hash, err := HashPassword("correct horse battery staple", testParams)
+	require.NoError(t, err)
+
+	// Act
+	match, matchErr := CheckPassword(hash, "correct horse battery staple")
+	mismatch, mismatchErr := CheckPassword(hash, "Correct horse battery staple")
+
+	// Assert
+	assert.NoError(t, matchErr)
+	assert.True(t, match)
+	assert.NoError(t, mismatchErr)
+	assert.False(t, mismatch)
+}
+
+func TestCheckPasswordInvalidHash(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+	}{
+		{"empty", ""},
+		{"bcrypt", "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy"},
+		{"version", "$argon2id$v=16$m=1024,t=1,p=1$c2FsdA$a2V5"},
+		{"params", "$argon2id$v=19$m=x,t=1,p=1$c2FsdA$a2V5"},
+		{"salt", "$argon2id$v=19$m=1024,t=1,p=1$!!$a2V5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			_, err := CheckPassword(tt.hash, "password")
+
+			// Assert
+			assert.ErrorIs(t, err, ErrInvalidHash)
+		})
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	// Act
+	token, err := NewToken()
+	other, otherErr := NewToken()
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, otherErr)
+	assert.Len(t, token, 43)
+	assert.NotEqual(t, token, other)
+	assert.Len(t, HashToken(token), 64)
+	assert.Equal(t, HashToken(token), HashToken(token))
+	assert.NotEqual(t, HashToken(token), HashToken(other))
+}
+
+func TestNewAPIKey(t *testing.T) {
+	// Act
+	key, err := NewAPIKey()
+
+	// Assert
+	require.NoError(t, err)
+	assert.Len(t, key, len(APIKeyPrefix)+43)
+	assert.True(t, IsAPIKey(key))
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+)
+
+// tokenLength is the number of random bytes in a session token.
+const tokenLength = 32
+
+// NewToken returns a random session token, encoded as unpadded URL-safe
+// base64.
+func NewToken() (string, error) {
+	token := make([]byte, tokenLength)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashToken returns the hex-encoded SHA-256 hash of token, under which the
+// session is stored. Tokens are random enough that they need no salt.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyPrefix starts every API key, which tells them apart from session
+// tokens and makes them easy to find when they are leaked.
+const APIKeyPrefix = "nk_"
+
+// NewAPIKey returns a random API key. Like session tokens, API keys are
+// stored by their HashToken.
+func NewAPIKey() (string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + token, nil
+}
+
+// IsAPIKey reports whether token is an API key rather than a session token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, APIKeyPrefix)
+}
+
+// TicketPrefix starts every ticket, a short-lived token for the clients that
+// cannot send an Authorization header, such as browsers opening a WebSocket.
+const TicketPrefix = "nt_"
+
+// NewTicket returns a random ticket. Like session tokens, tickets are stored
+// by their HashToken.
+func NewTicket() (string, error) {
+	token, err := NewToken()
+	if err != nil {
+		return "", err
+	}
+	return TicketPrefix + token, nil
+}
+
+// IsTicket reports whether token is a ticket rather than a session token.
+func IsTicket(token string) bool {
+	return strings.HasPrefix(token, TicketPrefix)
+}
@@ -0,0 +1,233 @@
+// Package crdt implements a text that several editors can change at the same
+// time without coordinating, as a Replicated Growable Array (RGA). Every
+// character has a unique Id and is inserted after another character. Deleted
+// characters stay behind as tombstones, so later inserts can still refer to
+// them. Replicas that apply the same operations end up with the same text,
+// whatever order concurrent operations arrive in.
+package crdt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// ErrUnknownId is returned when an operation refers to a character the
+	// text does not have.
+	ErrUnknownId = errors.New("unknown character id")
+	// ErrDuplicateId is returned when an insert uses the id of a character
+	// the text already has.
+	ErrDuplicateId = errors.New("duplicate character id")
+	// ErrInvalidOp is returned when an operation is malformed.
+	ErrInvalidOp = errors.New("invalid operation")
+)
+
+// Id identifies a character. Site identifies the editor who inserted it and
+// Clock is a Lamport clock, which must be greater than the clock of the
+// character it was inserted after. The zero Id stands for the start of the
+// text.
+type Id struct {
+	Site  int `json:"site"`
+	Clock int `json:"clock"`
+}
+
+// IsZero reports whether id is the start of the text.
+func (id Id) IsZero() bool {
+	return id == Id{}
+}
+
+// String formats id as site:clock.
+func (id Id) String() string {
+	return fmt.Sprintf("%d:%d", id.Site, id.Clock)
+}
+
+// precedes reports whether a character with id a comes before one with id b
+// when both are inserted after the same character. Later inserts come first.
+func (a Id) precedes(b Id) bool {
+	return a.Clock > b.Clock || (a.Clock == b.Clock && a.Site > b.Site)
+}
+
+// Element is a character of a text. Deleted characters have no text.
+type Element struct {
+	Id      Id     `json:"id"`
+	Text    string `json:"text,omitempty"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// Insert inserts the characters of Text after the character After. They get
+// the ids Id, Id with Clock + 1, and so on.
+type Insert struct {
+	Id    Id     `json:"id"`
+	After Id     `json:"after"`
+	Text  string `json:"text"`
+}
+
+// Delete deletes the characters with Ids.
+type Delete struct {
+	Ids []Id `json:"ids"`
+}
+
+// Doc is a replica of a text. It is not safe for concurrent use.
+type Doc struct {
+	elements []Element
+	ids      map[Id]bool
+	clock    int
+}
+
+// NewDoc returns a replica of text. Its characters are inserted by site 0
+// with the clocks 1 to the number of characters.
+func NewDoc(text string) *Doc {
+	d := &Doc{ids: map[Id]bool{}}
+	if text != "" {
+		d.Insert(Insert{Id: Id{Clock: 1}, Text: text})
+	}
+	return d
+}
+
+// Load returns a replica with the given elements, for example from a
+// snapshot of another replica.
+// It returns ErrDuplicateId if two elements have the same id.
+func Load(elements []Element) (*Doc, error) {
+	d := &Doc{elements: make([]Element, len(elements)), ids: make(map[Id]bool, len(elements))}
+	for i, e := range elements {
+		if e.Id.IsZero() || d.ids[e.Id] {
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateId, e.Id)
+		}
+		if e.Deleted {
+			e.Text = ""
+		}
+		d.elements[i] = e
+		d.ids[e.Id] = true
+		d.clock = max(d.clock, e.Id.Clock)
+	}
+	return d, nil
+}
+
+// Clock returns the highest clock of the characters of the text. Inserts
+// with a higher clock come after everything the replica has seen.
+func (d *Doc) Clock() int {
+	return d.clock
+}
+
+// Has reports whether the text has a character with id, deleted or not. It
+// always has the start of the text.
+func (d *Doc) Has(id Id) bool {
+	return id.IsZero() || d.ids[id]
+}
+
+// Text returns the text without its deleted characters.
+func (d *Doc) Text() string {
+	var b strings.Builder
+	for _, e := range d.elements {
+		b.WriteString(e.Text)
+	}
+	return b.String()
+}
+
+// Len returns the number of characters of the text that are not deleted.
+func (d *Doc) Len() int {
+	n := 0
+	for _, e := range d.elements {
+		if !e.Deleted {
+			n++
+		}
+	}
+	return n
+}
+
+// Elements returns a copy of the characters of the text, including
+// tombstones, in order.
+func (d *Doc) Elements() []Element {
+	return append([]Element(nil), d.elements...)
+}
+
+// VisibleIds returns the ids of the characters of the text that are not
+// deleted, in order.
+func (d *Doc) VisibleIds() []Id {
+	ids := make([]Id, 0, len(d.elements))
+	for _, e := range d.elements {
+		if !e.Deleted {
+			ids = append(ids, e.Id)
+		}
+	}
+	return ids
+}
+
+// Insert applies an insert to the text.
+// It returns ErrInvalidOp if there is no text or the clock is not greater
+// than that of the character it is inserted after, ErrUnknownId if that
+// character does not exist and ErrDuplicateId if one of the ids is taken.
+func (d *Doc) Insert(op Insert) error {
+	if op.Text == "" || !utf8.ValidString(op.Text) || op.Id.Clock <= op.After.Clock {
+		return fmt.Errorf("%w: insert %v after %v", ErrInvalidOp, op.Id, op.After)
+	}
+	if !d.Has(op.After) {
+		return fmt.Errorf("%w: %v", ErrUnknownId, op.After)
+	}
+	runes := []rune(op.Text)
+	for i := range runes {
+		if id := (Id{op.Id.Site, op.Id.Clock + i}); d.ids[id] {
+			return fmt.Errorf("%w: %v", ErrDuplicateId, id)
+		}
+	}
+
+	pos := 0
+	if !op.After.IsZero() {
+		pos = d.index(op.After) + 1
+	}
+	// Skip the characters inserted after the same character later, along
+	// with everything inserted after those. They all have higher clocks.
+	for pos < len(d.elements) && d.elements[pos].Id.precedes(op.Id) {
+		pos++
+	}
+
+	// The characters of a run follow each other directly, as nothing can
+	// have been inserted after them yet.
+	inserted := make([]Element, len(runes))
+	for i, r := range runes {
+		id := Id{op.Id.Site, op.Id.Clock + i}
+		inserted[i] = Element{Id: id, Text: string(r)}
+		d.ids[id] = true
+	}
+	d.elements = append(d.elements[:pos], append(inserted, d.elements[pos:]...)...)
+	d.clock = max(d.clock, op.Id.Clock+len(runes)-1)
+	return nil
+}
+
+// Delete applies a delete to the text and returns how many characters were
+// deleted that were not already. It either deletes all characters or none.
+// It returns ErrInvalidOp if there are no ids and ErrUnknownId if one of the
+// characters does not exist.
+func (d *Doc) Delete(op Delete) (int, error) {
+	if len(op.Ids) == 0 {
+		return 0, fmt.Errorf("%w: delete nothing", ErrInvalidOp)
+	}
+	deleted := make(map[Id]bool, len(op.Ids))
+	for _, id := range op.Ids {
+		if !d.ids[id] {
+			return 0, fmt.Errorf("%w: %v", ErrUnknownId, id)
+		}
+		deleted[id] = true
+	}
+
+	n := 0
+	for i := range d.elements {
+		if e := &d.elements[i]; deleted[e.Id] && !e.Deleted {
+			e.Deleted, e.Text = true, ""
+			n++
+		}
+	}
+	return n, nil
+}
+
+// index returns the position of the character with id, which must exist.
+func (d *Doc) index(id Id) int {
+	for i, e := range d.elements {
+		if e.Id == id {
+			return i
+		}
+	}
+	panic("crdt: missing character " + id.String())
+}
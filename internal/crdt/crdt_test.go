@@ -0,0 +1,172 @@
+package crdt
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoc_Insert(t *testing.T) {
+	tests := []struct {
+		name string
+		ops  []Insert
+		want string
+	}{
+		{"into empty text", []Insert{{Id{1, 1}, Id{}, "hello"}}, "hello"},
+		{"at start", []Insert{{Id{1, 1}, Id{}, "world"}, {Id{1, 6}, Id{}, "hello "}}, "hello world"},
+		{"in the middle", []Insert{{Id{1, 1}, Id{}, "helld"}, {Id{1, 6}, Id{1, 3}, "lo wor"}}, "hello world"},
+		{"unicode", []Insert{{Id{1, 1}, Id{}, "café"}, {Id{1, 5}, Id{1, 4}, " crème"}}, "café crème"},
+		{
+			"concurrent at the same place, later first",
+			[]Insert{{Id{1, 1}, Id{}, "ac"}, {Id{2, 3}, Id{1, 1}, "b"}, {Id{3, 3}, Id{1, 1}, "B"}},
+			"aBbc",
+		},
+		{
+			"concurrent after an insert that came later",
+			[]Insert{{Id{1, 1}, Id{}, "ac"}, {Id{2, 5}, Id{1, 1}, "xy"}, {Id{3, 3}, Id{1, 1}, "b"}},
+			"axybc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			doc := NewDoc("")
+
+			// Act
+			for _, op := range tt.ops {
+				require.NoError(t, doc.Insert(op))
+			}
+
+			// Assert
+			assert.Equal(t, tt.want, doc.Text())
+		})
+	}
+}
+
+func TestDoc_InvalidOps(t *testing.T) {
+	// Arrange
+	doc := NewDoc("abc")
+
+	// Act
+	emptyErr := doc.Insert(Insert{Id: Id{1, 4}, Text: ""})
+	clockErr := doc.Insert(Insert{Id: Id{1, 2}, After: Id{0, 3}, Text: "d"})
+	unknownErr := doc.Insert(Insert{Id: Id{1, 9}, After: Id{2, 8}, Text: "d"})
+	duplicateErr := doc.Insert(Insert{Id: Id{0, 3}, Text: "d"})
+	_, deleteNothingErr := doc.Delete(Delete{})
+	_, deleteUnknownErr := doc.Delete(Delete{Ids: []Id{{0, 1}, {2, 8}}})
+
+	// Assert
+	assert.ErrorIs(t, emptyErr, ErrInvalidOp)
+	assert.ErrorIs(t, clockErr, ErrInvalidOp)
+	assert.ErrorIs(t, unknownErr, ErrUnknownId)
+	assert.ErrorIs(t, duplicateErr, ErrDuplicateId)
+	assert.ErrorIs(t, deleteNothingErr, ErrInvalidOp)
+	assert.ErrorIs(t, deleteUnknownErr, ErrUnknownId)
+	assert.Equal(t, "abc", doc.Text())
+}
+
+func TestDoc_Delete(t *testing.T) {
+	// Arrange
+	doc := NewDoc("hello world")
+
+	// Act
+	n, err := doc.Delete(Delete{Ids: []Id{{0, 6}, {0, 7}, {0, 8}}})
+	again, againErr := doc.Delete(Delete{Ids: []Id{{0, 8}, {0, 9}}})
+	insertErr := doc.Insert(Insert{Id: Id{1, 12}, After: Id{0, 7}, Text: "W"})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.NoError(t, againErr)
+	assert.Equal(t, 1, again)
+	assert.NoError(t, insertErr)
+	assert.Equal(t, "helloWld", doc.Text())
+	assert.Equal(t, 8, doc.Len())
+	assert.Equal(t, 12, doc.Clock())
+	assert.Len(t, doc.Elements(), 12)
+}
+
+func TestLoad(t *testing.T) {
+	// Arrange
+	doc := NewDoc("abc")
+	_, err := doc.Delete(Delete{Ids: []Id{{0, 2}}})
+	require.NoError(t, err)
+
+	// Act
+	loaded, loadErr := Load(doc.Elements())
+	_, duplicateErr := Load([]Element{{Id: Id{0, 1}, Text: "a"}, {Id: Id{0, 1}, Text: "b"}})
+
+	// Assert
+	require.NoError(t, loadErr)
+	assert.Equal(t, "ac", loaded.Text())
+	assert.Equal(t, 3, loaded.Clock())
+	assert.True(t, loaded.Has(Id{0, 2}))
+	assert.Equal(t, []Id{{0, 1}, {0, 3}}, loaded.VisibleIds())
+	assert.ErrorIs(t, duplicateErr, ErrDuplicateId)
+}
+
+// op is an insert or delete made by a replica.
+type op struct {
+	insert *Insert
+	delete *Delete
+}
+
+func (o op) apply(d *Doc) error {
+	if o.insert != nil {
+		return d.Insert(*o.insert)
+	}
+	_, err := d.Delete(*o.delete)
+	return err
+}
+
+func TestDoc_Converges(t *testing.T) {
+	for seed := int64(1); seed <= 50; seed++ {
+		// Arrange
+		rng := rand.New(rand.NewSource(seed))
+		const sites = 3
+		replicas := make([]*Doc, sites)
+		for i := range replicas {
+			replicas[i] = NewDoc("shared text")
+		}
+		// Every replica makes changes without seeing the others', and then
+		// receives theirs in order.
+		made := make([][]op, sites)
+		for site, doc := range replicas {
+			for i := 0; i < 8; i++ {
+				ids := doc.VisibleIds()
+				var o op
+				if len(ids) > 0 && rng.Intn(3) == 0 {
+					o.delete = &Delete{Ids: []Id{ids[rng.Intn(len(ids))]}}
+				} else {
+					after := Id{}
+					if all := doc.Elements(); len(all) > 0 && rng.Intn(5) > 0 {
+						after = all[rng.Intn(len(all))].Id
+					}
+					o.insert = &Insert{Id: Id{site + 1, doc.Clock() + 1}, After: after, Text: string(rune('a' + rng.Intn(26)))}
+				}
+				require.NoError(t, o.apply(doc))
+				made[site] = append(made[site], o)
+			}
+		}
+
+		// Act
+		for site, doc := range replicas {
+			for _, other := range rng.Perm(sites) {
+				if other == site {
+					continue
+				}
+				for _, o := range made[other] {
+					require.NoError(t, o.apply(doc))
+				}
+			}
+		}
+
+		// Assert
+		for _, doc := range replicas[1:] {
+			assert.Equal(t, replicas[0].Text(), doc.Text(), "seed %d", seed)
+			assert.Equal(t, replicas[0].Elements(), doc.Elements(), "seed %d", seed)
+		}
+	}
+}
@@ -0,0 +1,3 @@
+DROP INDEX notes_deleted_at;
+
+ALTER TABLE notes DROP COLUMN deleted_at;
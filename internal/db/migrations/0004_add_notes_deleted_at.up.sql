@@ -0,0 +1,5 @@
+-- Deleted notes are moved to the trash by setting deleted_at and are only
+-- removed for good when they are purged from it.
+ALTER TABLE notes ADD COLUMN deleted_at TEXT;
+
+CREATE INDEX notes_deleted_at ON notes (deleted_at);
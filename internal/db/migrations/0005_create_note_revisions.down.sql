@@ -0,0 +1,3 @@
+DROP TRIGGER note_revisions_delete;
+
+DROP TABLE note_revisions;
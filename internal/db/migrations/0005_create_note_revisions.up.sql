@@ -0,0 +1,17 @@
+-- The title and content of every version of a note that changed them.
+CREATE TABLE note_revisions (
+    note_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (note_id, version)
+);
+
+CREATE TRIGGER note_revisions_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM note_revisions WHERE note_id = old.id;
+END;
+
+-- Start the history of existing notes with their current version.
+INSERT INTO note_revisions (note_id, version, title, content, created_at)
+SELECT id, version, title, content, updated_at FROM notes;
@@ -0,0 +1,5 @@
+DROP TRIGGER note_tags_delete;
+
+DROP TABLE note_tags;
+
+DROP TABLE tags;
@@ -0,0 +1,5 @@
+DROP INDEX notes_notebook_id;
+
+ALTER TABLE notes DROP COLUMN notebook_id;
+
+DROP TABLE notebooks;
@@ -0,0 +1,19 @@
+-- Notebooks form a tree. path lists the IDs from the root down to the
+-- notebook itself, as in /1/4/9/, so that a subtree is selected by prefix.
+CREATE TABLE notebooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    parent_id INTEGER REFERENCES notebooks (id),
+    path TEXT NOT NULL DEFAULT '',
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+-- Sibling notebooks have distinct names.
+CREATE UNIQUE INDEX notebooks_parent_name ON notebooks (coalesce(parent_id, 0), name);
+
+CREATE INDEX notebooks_path ON notebooks (path);
+
+ALTER TABLE notes ADD COLUMN notebook_id INTEGER REFERENCES notebooks (id);
+
+CREATE INDEX notes_notebook_id ON notes (notebook_id);
@@ -0,0 +1,43 @@
+-- Tags of different users with the same name become one tag again.
+DROP TRIGGER note_tags_delete;
+
+CREATE TABLE global_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL UNIQUE
+);
+
+INSERT INTO global_tags (id, name) SELECT min(id), name FROM tags GROUP BY name;
+
+UPDATE OR IGNORE note_tags SET tag_id = (
+    SELECT global_tags.id FROM global_tags JOIN tags ON tags.name = global_tags.name WHERE tags.id = note_tags.tag_id);
+
+DELETE FROM note_tags WHERE tag_id NOT IN (SELECT id FROM global_tags);
+
+DROP TABLE tags;
+
+ALTER TABLE global_tags RENAME TO tags;
+
+CREATE TRIGGER note_tags_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM note_tags WHERE note_id = old.id;
+    DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM note_tags WHERE note_tags.tag_id = tags.id);
+END;
+
+-- Notebooks of different users with the same name in the same place get
+-- their id appended to their name to tell them apart.
+DROP INDEX notebooks_parent_name;
+
+UPDATE notebooks SET name = name || ' (' || id || ')' WHERE EXISTS (
+    SELECT 1 FROM notebooks AS other
+    WHERE coalesce(other.parent_id, 0) = coalesce(notebooks.parent_id, 0) AND other.name = notebooks.name AND other.id < notebooks.id);
+
+ALTER TABLE notebooks DROP COLUMN user_id;
+
+CREATE UNIQUE INDEX notebooks_parent_name ON notebooks (coalesce(parent_id, 0), name);
+
+DROP INDEX notes_user_id;
+
+ALTER TABLE notes DROP COLUMN user_id;
+
+DROP TABLE sessions;
+
+DROP TABLE users;
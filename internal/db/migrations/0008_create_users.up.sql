@@ -0,0 +1,50 @@
+CREATE TABLE users (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    email TEXT NOT NULL UNIQUE COLLATE NOCASE,
+    password_hash TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+-- Sessions are stored by the SHA-256 hash of their token, so the tokens
+-- cannot be read back from the database.
+CREATE TABLE sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+);
+
+CREATE INDEX sessions_user_id ON sessions (user_id);
+
+-- Notes, notebooks and tags belong to a user. Those created before there
+-- were users are adopted by the first user to register.
+ALTER TABLE notes ADD COLUMN user_id INTEGER REFERENCES users (id);
+
+CREATE INDEX notes_user_id ON notes (user_id);
+
+ALTER TABLE notebooks ADD COLUMN user_id INTEGER REFERENCES users (id);
+
+DROP INDEX notebooks_parent_name;
+
+CREATE UNIQUE INDEX notebooks_parent_name ON notebooks (coalesce(user_id, 0), coalesce(parent_id, 0), name);
+
+-- Tag names are only unique per user, which needs a new table.
+DROP TRIGGER note_tags_delete;
+
+CREATE TABLE user_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users (id),
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+INSERT INTO user_tags (id, name) SELECT id, name FROM tags;
+
+DROP TABLE tags;
+
+ALTER TABLE user_tags RENAME TO tags;
+
+CREATE TRIGGER note_tags_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM note_tags WHERE note_id = old.id;
+    DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM note_tags WHERE note_tags.tag_id = tags.id);
+END;
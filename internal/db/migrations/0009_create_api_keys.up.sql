@@ -0,0 +1,15 @@
+-- API keys are stored by the SHA-256 hash of the key, like sessions. Scopes
+-- are separated by spaces.
+CREATE TABLE api_keys (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    expires_at TEXT,
+    last_used_at TEXT
+);
+
+CREATE INDEX api_keys_user_id ON api_keys (user_id);
@@ -0,0 +1,7 @@
+DROP TRIGGER notebook_shares_delete;
+
+DROP TRIGGER note_shares_delete;
+
+DROP TABLE notebook_shares;
+
+DROP TABLE note_shares;
@@ -0,0 +1,29 @@
+-- Shares grant other users than the owner access to a note or notebook.
+-- Sharing a notebook shares everything below it.
+CREATE TABLE note_shares (
+    note_id INTEGER NOT NULL REFERENCES notes (id),
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (note_id, user_id)
+);
+
+CREATE INDEX note_shares_user_id ON note_shares (user_id);
+
+CREATE TABLE notebook_shares (
+    notebook_id INTEGER NOT NULL REFERENCES notebooks (id),
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (notebook_id, user_id)
+);
+
+CREATE INDEX notebook_shares_user_id ON notebook_shares (user_id);
+
+CREATE TRIGGER note_shares_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM note_shares WHERE note_id = old.id;
+END;
+
+CREATE TRIGGER notebook_shares_delete AFTER DELETE ON notebooks BEGIN
+    DELETE FROM notebook_shares WHERE notebook_id = old.id;
+END;
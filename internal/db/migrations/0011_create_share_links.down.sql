@@ -0,0 +1,3 @@
+DROP TRIGGER share_links_delete;
+
+DROP TABLE share_links;
@@ -0,0 +1,19 @@
+-- Share links let anyone with their token read a note. Only a hash of the
+-- token and of the optional password is stored.
+CREATE TABLE share_links (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id INTEGER NOT NULL REFERENCES notes (id),
+    prefix TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    password_hash TEXT,
+    expires_at TEXT,
+    max_views INTEGER,
+    views INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX share_links_note_id ON share_links (note_id);
+
+CREATE TRIGGER share_links_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM share_links WHERE note_id = old.id;
+END;
@@ -0,0 +1,61 @@
+-- Tags go back to the users who created the notes carrying them.
+DROP TRIGGER note_tags_delete;
+
+CREATE TABLE user_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER REFERENCES users (id),
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+INSERT INTO user_tags (user_id, name)
+SELECT DISTINCT notes.user_id, tags.name FROM note_tags
+JOIN notes ON notes.id = note_tags.note_id JOIN tags ON tags.id = note_tags.tag_id;
+
+CREATE TABLE user_note_tags (
+    note_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+INSERT INTO user_note_tags (note_id, tag_id)
+SELECT note_tags.note_id, user_tags.id FROM note_tags
+JOIN notes ON notes.id = note_tags.note_id JOIN tags ON tags.id = note_tags.tag_id
+JOIN user_tags ON user_tags.user_id IS notes.user_id AND user_tags.name = tags.name;
+
+DELETE FROM note_tags;
+
+INSERT INTO note_tags (note_id, tag_id) SELECT note_id, tag_id FROM user_note_tags;
+
+DROP TABLE user_note_tags;
+
+DROP TABLE tags;
+
+ALTER TABLE user_tags RENAME TO tags;
+
+CREATE TRIGGER note_tags_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM note_tags WHERE note_id = old.id;
+    DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM note_tags WHERE note_tags.tag_id = tags.id);
+END;
+
+-- Notebooks of the same user with the same name in the same place, from
+-- different workspaces, get their id appended to their name to tell them
+-- apart.
+DROP INDEX notebooks_parent_name;
+
+UPDATE notebooks SET name = name || ' (' || id || ')' WHERE EXISTS (
+    SELECT 1 FROM notebooks AS other
+    WHERE coalesce(other.user_id, 0) = coalesce(notebooks.user_id, 0)
+    AND coalesce(other.parent_id, 0) = coalesce(notebooks.parent_id, 0) AND other.name = notebooks.name AND other.id < notebooks.id);
+
+ALTER TABLE notebooks DROP COLUMN workspace_id;
+
+CREATE UNIQUE INDEX notebooks_parent_name ON notebooks (coalesce(user_id, 0), coalesce(parent_id, 0), name);
+
+DROP INDEX notes_workspace_id;
+
+ALTER TABLE notes DROP COLUMN workspace_id;
+
+DROP TABLE workspace_members;
+
+DROP TABLE workspaces;
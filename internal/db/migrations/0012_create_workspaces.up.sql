@@ -0,0 +1,61 @@
+-- Workspaces hold notes, notebooks and tags. Their members see everything in
+-- them with the role of their membership, and nothing outside of them.
+CREATE TABLE workspaces (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    personal_user_id INTEGER UNIQUE REFERENCES users (id),
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE workspace_members (
+    workspace_id INTEGER NOT NULL REFERENCES workspaces (id),
+    user_id INTEGER NOT NULL REFERENCES users (id),
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX workspace_members_user_id ON workspace_members (user_id);
+
+-- Every user gets a personal workspace with everything they had so far.
+INSERT INTO workspaces (name, personal_user_id, created_at) SELECT 'Personal', id, created_at FROM users ORDER BY id;
+
+INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+SELECT id, personal_user_id, 'owner', created_at FROM workspaces;
+
+-- user_id stays as the creator of a note or notebook, who owns it.
+ALTER TABLE notes ADD COLUMN workspace_id INTEGER REFERENCES workspaces (id);
+
+UPDATE notes SET workspace_id = (SELECT id FROM workspaces WHERE personal_user_id = notes.user_id);
+
+CREATE INDEX notes_workspace_id ON notes (workspace_id);
+
+ALTER TABLE notebooks ADD COLUMN workspace_id INTEGER REFERENCES workspaces (id);
+
+UPDATE notebooks SET workspace_id = (SELECT id FROM workspaces WHERE personal_user_id = notebooks.user_id);
+
+DROP INDEX notebooks_parent_name;
+
+CREATE UNIQUE INDEX notebooks_parent_name ON notebooks (coalesce(workspace_id, 0), coalesce(parent_id, 0), name);
+
+-- Tag names are unique per workspace rather than per user.
+DROP TRIGGER note_tags_delete;
+
+CREATE TABLE workspace_tags (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace_id INTEGER REFERENCES workspaces (id),
+    name TEXT NOT NULL,
+    UNIQUE (workspace_id, name)
+);
+
+INSERT INTO workspace_tags (id, workspace_id, name)
+SELECT id, (SELECT id FROM workspaces WHERE personal_user_id = tags.user_id), name FROM tags;
+
+DROP TABLE tags;
+
+ALTER TABLE workspace_tags RENAME TO tags;
+
+CREATE TRIGGER note_tags_delete AFTER DELETE ON notes BEGIN
+    DELETE FROM note_tags WHERE note_id = old.id;
+    DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM note_tags WHERE note_tags.tag_id = tags.id);
+END;
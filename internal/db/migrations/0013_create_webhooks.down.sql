@@ -0,0 +1,7 @@
+DROP TRIGGER webhooks_delete;
+
+DROP TABLE webhook_deliveries;
+
+DROP TABLE webhook_events;
+
+DROP TABLE webhooks;
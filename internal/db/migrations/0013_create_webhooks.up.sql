@@ -0,0 +1,43 @@
+-- Webhooks are called with the changes to the notes of a workspace. The
+-- secret signs the deliveries, so unlike tokens it is stored as it is.
+CREATE TABLE webhooks (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    workspace_id INTEGER NOT NULL REFERENCES workspaces (id),
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX webhooks_workspace_id ON webhooks (workspace_id);
+
+CREATE TABLE webhook_events (
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id),
+    event TEXT NOT NULL,
+    PRIMARY KEY (webhook_id, event)
+);
+
+-- Deliveries are an outbox: they are added in the transaction of the change
+-- they report and sent afterwards, until they succeed or run out of
+-- attempts.
+CREATE TABLE webhook_deliveries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    webhook_id INTEGER NOT NULL REFERENCES webhooks (id),
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TEXT,
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at TEXT NOT NULL,
+    delivered_at TEXT
+);
+
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+
+CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+
+CREATE TRIGGER webhooks_delete AFTER DELETE ON webhooks BEGIN
+    DELETE FROM webhook_events WHERE webhook_id = old.id;
+    DELETE FROM webhook_deliveries WHERE webhook_id = old.id;
+END;
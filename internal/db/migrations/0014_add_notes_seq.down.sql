@@ -0,0 +1,20 @@
+DROP TRIGGER notes_seq_delete;
+
+DROP TRIGGER notes_seq_update;
+
+DROP TRIGGER notes_seq_insert;
+
+DROP TABLE note_tombstones;
+
+DROP INDEX notes_workspace_seq;
+
+DROP TRIGGER notes_fts_update;
+
+CREATE TRIGGER notes_fts_update AFTER UPDATE ON notes BEGIN
+    INSERT INTO notes_fts (notes_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO notes_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+ALTER TABLE notes DROP COLUMN seq;
+
+DROP TABLE sync_sequence;
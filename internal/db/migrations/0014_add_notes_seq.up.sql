@@ -0,0 +1,51 @@
+-- Every write to a note gives it the next number of a sequence shared by all
+-- workspaces, so clients can sync everything changed since the last number
+-- they saw. Writes are serialized, so numbers become visible in order.
+CREATE TABLE sync_sequence (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    seq INTEGER NOT NULL
+);
+
+ALTER TABLE notes ADD COLUMN seq INTEGER NOT NULL DEFAULT 0;
+
+-- Only reindex notes whose text changed, not every time their number does.
+DROP TRIGGER notes_fts_update;
+
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF title, content ON notes BEGIN
+    INSERT INTO notes_fts (notes_fts, rowid, title, content) VALUES ('delete', old.id, old.title, old.content);
+    INSERT INTO notes_fts (rowid, title, content) VALUES (new.id, new.title, new.content);
+END;
+
+-- Existing notes are numbered in the order they were created.
+UPDATE notes SET seq = id;
+
+INSERT INTO sync_sequence (id, seq) SELECT 1, coalesce(max(seq), 0) FROM notes;
+
+CREATE INDEX notes_workspace_seq ON notes (workspace_id, seq);
+
+-- Purged notes leave a tombstone numbered like a write, so clients that
+-- still have them learn that they are gone.
+CREATE TABLE note_tombstones (
+    note_id INTEGER PRIMARY KEY,
+    workspace_id INTEGER REFERENCES workspaces (id),
+    seq INTEGER NOT NULL,
+    deleted_at TEXT NOT NULL
+);
+
+CREATE INDEX note_tombstones_workspace_seq ON note_tombstones (workspace_id, seq);
+
+CREATE TRIGGER notes_seq_insert AFTER INSERT ON notes BEGIN
+    UPDATE sync_sequence SET seq = seq + 1;
+    UPDATE notes SET seq = (SELECT seq FROM sync_sequence) WHERE id = new.id;
+END;
+
+CREATE TRIGGER notes_seq_update AFTER UPDATE OF title, content, created_at, updated_at, version, deleted_at, notebook_id, workspace_id ON notes BEGIN
+    UPDATE sync_sequence SET seq = seq + 1;
+    UPDATE notes SET seq = (SELECT seq FROM sync_sequence) WHERE id = new.id;
+END;
+
+CREATE TRIGGER notes_seq_delete AFTER DELETE ON notes BEGIN
+    UPDATE sync_sequence SET seq = seq + 1;
+    INSERT INTO note_tombstones (note_id, workspace_id, seq, deleted_at)
+    VALUES (old.id, old.workspace_id, (SELECT seq FROM sync_sequence), strftime('%Y-%m-%dT%H:%M:%fZ', 'now'));
+END;
@@ -0,0 +1,3 @@
+DROP INDEX notes_client_id;
+
+ALTER TABLE notes DROP COLUMN client_id;
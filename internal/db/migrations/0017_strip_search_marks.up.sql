@@ -0,0 +1,44 @@
+-- Highlights and snippets enclose matched terms in the characters \x02 and
+-- \x03, so the same characters in a note would come out as marks. The index
+-- reads notes through a view that replaces them with spaces, which separate
+-- terms like them, so that only the marks of matched terms are left.
+CREATE VIEW notes_fts_content AS SELECT id,
+    replace(replace(title, char(2), ' '), char(3), ' ') AS title,
+    replace(replace(content, char(2), ' '), char(3), ' ') AS content
+FROM notes;
+
+DROP TRIGGER notes_fts_update;
+DROP TRIGGER notes_fts_delete;
+DROP TRIGGER notes_fts_insert;
+DROP TABLE notes_fts;
+
+CREATE VIRTUAL TABLE notes_fts USING fts5(
+    title,
+    content,
+    content = 'notes_fts_content',
+    content_rowid = 'id',
+    tokenize = 'unicode61 remove_diacritics 2'
+);
+
+CREATE TRIGGER notes_fts_insert AFTER INSERT ON notes BEGIN
+    INSERT INTO notes_fts (rowid, title, content) VALUES (new.id,
+        replace(replace(new.title, char(2), ' '), char(3), ' '),
+        replace(replace(new.content, char(2), ' '), char(3), ' '));
+END;
+
+CREATE TRIGGER notes_fts_delete AFTER DELETE ON notes BEGIN
+    INSERT INTO notes_fts (notes_fts, rowid, title, content) VALUES ('delete', old.id,
+        replace(replace(old.title, char(2), ' '), char(3), ' '),
+        replace(replace(old.content, char(2), ' '), char(3), ' '));
+END;
+
+CREATE TRIGGER notes_fts_update AFTER UPDATE OF title, content ON notes BEGIN
+    INSERT INTO notes_fts (notes_fts, rowid, title, content) VALUES ('delete', old.id,
+        replace(replace(old.title, char(2), ' '), char(3), ' '),
+        replace(replace(old.content, char(2), ' '), char(3), ' '));
+    INSERT INTO notes_fts (rowid, title, content) VALUES (new.id,
+        replace(replace(new.title, char(2), ' '), char(3), ' '),
+        replace(replace(new.content, char(2), ' '), char(3), ' '));
+END;
+
+INSERT INTO notes_fts (notes_fts) VALUES ('rebuild');
@@ -0,0 +1,55 @@
+DROP TABLE note_tombstones;
+
+DROP TABLE sync_sequence;
+
+DROP TABLE webhook_deliveries;
+
+DROP TABLE webhook_events;
+
+DROP TABLE webhooks;
+
+DROP TABLE share_links;
+
+DROP TABLE notebook_shares;
+
+DROP TABLE note_shares;
+
+DROP TABLE note_tags;
+
+DROP TABLE tags;
+
+DROP TABLE note_revisions;
+
+DROP TABLE notes;
+
+DROP TABLE notebooks;
+
+DROP TABLE workspace_members;
+
+DROP TABLE workspaces;
+
+DROP TABLE api_keys;
+
+DROP TABLE sessions;
+
+DROP TABLE users;
+
+DROP FUNCTION webhooks_delete();
+
+DROP FUNCTION notebooks_delete();
+
+DROP FUNCTION notes_delete();
+
+DROP FUNCTION notes_seq();
+
+DROP TEXT SEARCH CONFIGURATION notes_search;
+
+DROP COLLATION nocase;
+
+DROP AGGREGATE group_concat(TEXT);
+
+DROP FUNCTION group_concat_step(TEXT, TEXT);
+
+DROP AGGREGATE group_concat(TEXT, TEXT);
+
+DROP FUNCTION group_concat_step(TEXT, TEXT, TEXT);
@@ -0,0 +1,299 @@
+-- The PostgreSQL schema of the notes API, equal to the one the SQLite
+-- migrations build. Timestamps are stored as text in the same fixed-width
+-- format, so they sort chronologically, and foreign keys are not enforced
+-- but cleaned up by triggers like in SQLite.
+
+-- SQLite functions the queries of the repositories rely on.
+CREATE FUNCTION group_concat_step(acc TEXT, value TEXT, separator TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT CASE WHEN value IS NULL THEN acc WHEN acc IS NULL THEN value ELSE acc || separator || value END
+$$;
+
+CREATE AGGREGATE group_concat(TEXT, TEXT) (SFUNC = group_concat_step, STYPE = TEXT);
+
+CREATE FUNCTION group_concat_step(acc TEXT, value TEXT) RETURNS TEXT
+LANGUAGE sql IMMUTABLE AS $$
+    SELECT group_concat_step(acc, value, ',')
+$$;
+
+CREATE AGGREGATE group_concat(TEXT) (SFUNC = group_concat_step, STYPE = TEXT);
+
+-- Emails are matched case-insensitively like with COLLATE NOCASE in
+-- SQLite. Names and titles compare byte by byte like in SQLite.
+CREATE COLLATION nocase (provider = icu, locale = 'und-u-ks-level2', deterministic = false);
+
+-- Search ignores case and accents like the unicode61 tokenizer with
+-- remove_diacritics, and does not stem words.
+CREATE EXTENSION IF NOT EXISTS unaccent;
+
+CREATE TEXT SEARCH CONFIGURATION notes_search (COPY = simple);
+
+ALTER TEXT SEARCH CONFIGURATION notes_search
+    ALTER MAPPING FOR asciiword, asciihword, hword_asciipart, word, hword, hword_part, numword, numhword, hword_numpart
+    WITH unaccent, simple;
+
+CREATE TABLE users (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    email TEXT NOT NULL UNIQUE COLLATE nocase,
+    password_hash TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+-- Sessions are stored by the SHA-256 hash of their token, so the tokens
+-- cannot be read back from the database.
+CREATE TABLE sessions (
+    token_hash TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    created_at TEXT NOT NULL,
+    expires_at TEXT NOT NULL
+);
+
+CREATE INDEX sessions_user_id ON sessions (user_id);
+
+CREATE TABLE api_keys (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    scopes TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    expires_at TEXT,
+    last_used_at TEXT
+);
+
+CREATE INDEX api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE workspaces (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    name TEXT NOT NULL,
+    personal_user_id INTEGER UNIQUE,
+    created_at TEXT NOT NULL
+);
+
+CREATE TABLE workspace_members (
+    workspace_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor', 'owner')),
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX workspace_members_user_id ON workspace_members (user_id);
+
+CREATE TABLE notebooks (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    workspace_id INTEGER,
+    user_id INTEGER,
+    name TEXT NOT NULL COLLATE "C",
+    parent_id INTEGER,
+    path TEXT NOT NULL DEFAULT '' COLLATE "C",
+    created_at TEXT NOT NULL,
+    updated_at TEXT NOT NULL
+);
+
+CREATE INDEX notebooks_path ON notebooks (path);
+
+CREATE UNIQUE INDEX notebooks_parent_name ON notebooks (coalesce(workspace_id, 0), coalesce(parent_id, 0), name);
+
+-- The search column holds the words of the title, weighted A, and of the
+-- content, weighted B, so title matches can rank higher.
+CREATE TABLE notes (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    workspace_id INTEGER,
+    user_id INTEGER,
+    title TEXT NOT NULL COLLATE "C",
+    content TEXT NOT NULL,
+    created_at TEXT NOT NULL DEFAULT '',
+    updated_at TEXT NOT NULL DEFAULT '',
+    version INTEGER NOT NULL DEFAULT 1,
+    deleted_at TEXT,
+    notebook_id INTEGER,
+    seq BIGINT NOT NULL DEFAULT 0,
+    search TSVECTOR GENERATED ALWAYS AS (
+        setweight(to_tsvector('notes_search', title), 'A') ||
+        setweight(to_tsvector('notes_search', content), 'B')
+    ) STORED
+);
+
+CREATE INDEX notes_deleted_at ON notes (deleted_at);
+
+CREATE INDEX notes_notebook_id ON notes (notebook_id);
+
+CREATE INDEX notes_user_id ON notes (user_id);
+
+CREATE INDEX notes_workspace_id ON notes (workspace_id);
+
+CREATE INDEX notes_workspace_seq ON notes (workspace_id, seq);
+
+CREATE INDEX notes_search ON notes USING gin (search);
+
+CREATE TABLE note_revisions (
+    note_id INTEGER NOT NULL,
+    version INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (note_id, version)
+);
+
+CREATE TABLE tags (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    workspace_id INTEGER,
+    name TEXT NOT NULL COLLATE "C",
+    UNIQUE (workspace_id, name)
+);
+
+CREATE TABLE note_tags (
+    note_id INTEGER NOT NULL,
+    tag_id INTEGER NOT NULL,
+    PRIMARY KEY (note_id, tag_id)
+);
+
+CREATE INDEX note_tags_tag_id ON note_tags (tag_id);
+
+CREATE TABLE note_shares (
+    note_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (note_id, user_id)
+);
+
+CREATE INDEX note_shares_user_id ON note_shares (user_id);
+
+CREATE TABLE notebook_shares (
+    notebook_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    role TEXT NOT NULL CHECK (role IN ('viewer', 'editor')),
+    created_at TEXT NOT NULL,
+    PRIMARY KEY (notebook_id, user_id)
+);
+
+CREATE INDEX notebook_shares_user_id ON notebook_shares (user_id);
+
+CREATE TABLE share_links (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    note_id INTEGER NOT NULL,
+    prefix TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    password_hash TEXT,
+    expires_at TEXT,
+    max_views INTEGER,
+    views INTEGER NOT NULL DEFAULT 0,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX share_links_note_id ON share_links (note_id);
+
+-- Webhooks are called with the changes to the notes of a workspace. The
+-- secret signs the deliveries, so unlike tokens it is stored as it is.
+CREATE TABLE webhooks (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    workspace_id INTEGER NOT NULL,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,
+    created_at TEXT NOT NULL
+);
+
+CREATE INDEX webhooks_workspace_id ON webhooks (workspace_id);
+
+CREATE TABLE webhook_events (
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    PRIMARY KEY (webhook_id, event)
+);
+
+CREATE TABLE webhook_deliveries (
+    id INTEGER GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY,
+    webhook_id INTEGER NOT NULL,
+    event TEXT NOT NULL,
+    payload TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'succeeded', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TEXT,
+    last_status_code INTEGER,
+    last_error TEXT,
+    created_at TEXT NOT NULL,
+    delivered_at TEXT
+);
+
+CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+
+CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id);
+
+-- Every write to a note gives it the next number of a sequence shared by all
+-- workspaces, so clients can sync everything changed since the last number
+-- they saw. Taking a number locks the row until the write commits, so
+-- numbers become visible in order. A SEQUENCE would not guarantee that.
+CREATE TABLE sync_sequence (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    seq BIGINT NOT NULL
+);
+
+INSERT INTO sync_sequence (id, seq) VALUES (1, 0);
+
+-- Purged notes leave a tombstone numbered like a write, so clients that
+-- still have them learn that they are gone.
+CREATE TABLE note_tombstones (
+    note_id INTEGER PRIMARY KEY,
+    workspace_id INTEGER,
+    seq BIGINT NOT NULL,
+    deleted_at TEXT NOT NULL
+);
+
+CREATE INDEX note_tombstones_workspace_seq ON note_tombstones (workspace_id, seq);
+
+CREATE FUNCTION notes_seq() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    UPDATE sync_sequence SET seq = seq + 1 RETURNING seq INTO NEW.seq;
+    RETURN NEW;
+END
+$$;
+
+CREATE TRIGGER notes_seq_insert BEFORE INSERT ON notes
+    FOR EACH ROW EXECUTE FUNCTION notes_seq();
+
+CREATE TRIGGER notes_seq_update BEFORE UPDATE OF title, content, created_at, updated_at, version, deleted_at, notebook_id, workspace_id ON notes
+    FOR EACH ROW EXECUTE FUNCTION notes_seq();
+
+-- Removing a note removes everything that belongs to it.
+CREATE FUNCTION notes_delete() RETURNS trigger LANGUAGE plpgsql AS $$
+DECLARE
+    next_seq BIGINT;
+BEGIN
+    DELETE FROM note_revisions WHERE note_id = OLD.id;
+    DELETE FROM note_tags WHERE note_id = OLD.id;
+    DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM note_tags WHERE note_tags.tag_id = tags.id);
+    DELETE FROM note_shares WHERE note_id = OLD.id;
+    DELETE FROM share_links WHERE note_id = OLD.id;
+    UPDATE sync_sequence SET seq = seq + 1 RETURNING seq INTO next_seq;
+    INSERT INTO note_tombstones (note_id, workspace_id, seq, deleted_at)
+    VALUES (OLD.id, OLD.workspace_id, next_seq, to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS.MS"Z"'));
+    RETURN OLD;
+END
+$$;
+
+CREATE TRIGGER notes_delete AFTER DELETE ON notes
+    FOR EACH ROW EXECUTE FUNCTION notes_delete();
+
+CREATE FUNCTION notebooks_delete() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    DELETE FROM notebook_shares WHERE notebook_id = OLD.id;
+    RETURN OLD;
+END
+$$;
+
+CREATE TRIGGER notebooks_delete AFTER DELETE ON notebooks
+    FOR EACH ROW EXECUTE FUNCTION notebooks_delete();
+
+CREATE FUNCTION webhooks_delete() RETURNS trigger LANGUAGE plpgsql AS $$
+BEGIN
+    DELETE FROM webhook_events WHERE webhook_id = OLD.id;
+    DELETE FROM webhook_deliveries WHERE webhook_id = OLD.id;
+    RETURN OLD;
+END
+$$;
+
+CREATE TRIGGER webhooks_delete AFTER DELETE ON webhooks
+    FOR EACH ROW EXECUTE FUNCTION webhooks_delete();
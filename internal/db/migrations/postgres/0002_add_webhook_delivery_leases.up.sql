@@ -0,0 +1,5 @@
+-- A dispatcher leases a delivery while it sends it, so that other
+-- dispatchers on the same database do not send it as well. If the dispatcher
+-- stops before it records the outcome, the delivery is due again once the
+-- lease expires.
+ALTER TABLE webhook_deliveries ADD COLUMN leased_until TEXT;
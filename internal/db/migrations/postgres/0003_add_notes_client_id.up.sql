@@ -0,0 +1,6 @@
+-- Notes created by a sync push keep the client ID the client chose for them,
+-- so that pushing the same change again returns the note instead of creating
+-- another one. Client IDs are chosen per user.
+ALTER TABLE notes ADD COLUMN client_id TEXT;
+
+CREATE UNIQUE INDEX notes_client_id ON notes (workspace_id, user_id, client_id);
@@ -0,0 +1,200 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"embed"
+	"io/fs"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/migrate"
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/postgres/*.sql
+var postgresMigrations embed.FS
+
+// PoolConfig tunes the connection pool of a PostgreSQL database. Zero
+// values leave the setting of database/sql unchanged.
+type PoolConfig struct {
+	// MaxOpenConns limits the connections open at once.
+	MaxOpenConns int
+	// MaxIdleConns limits the connections kept open while idle.
+	MaxIdleConns int
+	// ConnMaxLifetime is how long a connection may be reused.
+	ConnMaxLifetime time.Duration
+	// ConnMaxIdleTime is how long a connection may be idle before it is
+	// closed.
+	ConnMaxIdleTime time.Duration
+}
+
+// DefaultPoolConfig is the pool used unless configured otherwise. It
+// recycles connections regularly, so the server follows failovers and
+// load balancers in front of PostgreSQL.
+var DefaultPoolConfig = PoolConfig{
+	MaxOpenConns:    25,
+	MaxIdleConns:    25,
+	ConnMaxLifetime: 30 * time.Minute,
+	ConnMaxIdleTime: 5 * time.Minute,
+}
+
+// IsPostgresDSN reports whether dsn is a postgres:// or postgresql:// URL
+// rather than the path of a SQLite database.
+func IsPostgresDSN(dsn string) bool {
+	return strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://")
+}
+
+// NewPostgresDB connects to the PostgreSQL database at dsn with a pool
+// tuned by pool and applies any pending migrations.
+func NewPostgresDB(dsn string, pool PoolConfig) (*sql.DB, error) {
+	db, err := OpenPostgresDB(dsn, pool)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := NewPostgresMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	_, err = migrator.Up(false)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// OpenPostgresDB connects to the PostgreSQL database at dsn with a pool
+// tuned by pool without migrating it. Queries are written with ? for
+// parameters like for SQLite, which the connection rewrites to the $1, $2,
+// ... of PostgreSQL.
+func OpenPostgresDB(dsn string, pool PoolConfig) (*sql.DB, error) {
+	connector, err := pq.NewConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	db := sql.OpenDB(rebindConnector{connector})
+	if pool.MaxOpenConns != 0 {
+		db.SetMaxOpenConns(pool.MaxOpenConns)
+	}
+	if pool.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(pool.MaxIdleConns)
+	}
+	if pool.ConnMaxLifetime != 0 {
+		db.SetConnMaxLifetime(pool.ConnMaxLifetime)
+	}
+	if pool.ConnMaxIdleTime != 0 {
+		db.SetConnMaxIdleTime(pool.ConnMaxIdleTime)
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewPostgresMigrator returns a migrator for the PostgreSQL schema of the
+// notes API.
+func NewPostgresMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	migrations, err := PostgresMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, migrations)
+}
+
+// PostgresMigrations returns the SQL migrations embedded from the
+// migrations/postgres directory. They build the same schema as the SQLite
+// migrations, so their versions are numbered on their own.
+func PostgresMigrations() ([]migrate.Migration, error) {
+	dir, err := fs.Sub(postgresMigrations, "migrations/postgres")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.Load(dir)
+}
+
+// rebind replaces the ? parameters of query with $1, $2, ... Question marks
+// in quoted strings and identifiers are left alone.
+func rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	var quote byte
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '?':
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// rebindConnector opens connections that rebind the queries they are given.
+type rebindConnector struct {
+	driver.Connector
+}
+
+func (c rebindConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &rebindConn{conn.(pqConn)}, nil
+}
+
+// pqConn is the interface implemented by the connections of lib/pq.
+type pqConn interface {
+	driver.Conn
+	driver.ConnBeginTx
+	driver.ConnPrepareContext
+	driver.ExecerContext
+	driver.QueryerContext
+	driver.Pinger
+	driver.SessionResetter
+	driver.Validator
+}
+
+// rebindConn is a connection of lib/pq that rebinds queries with parameters.
+// Queries without are passed on unchanged, so migrations may contain
+// question marks and several statements.
+type rebindConn struct {
+	pqConn
+}
+
+func (c *rebindConn) Prepare(query string) (driver.Stmt, error) {
+	return c.pqConn.Prepare(rebind(query))
+}
+
+func (c *rebindConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.pqConn.PrepareContext(ctx, rebind(query))
+}
+
+func (c *rebindConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if len(args) > 0 {
+		query = rebind(query)
+	}
+	return c.pqConn.ExecContext(ctx, query, args)
+}
+
+func (c *rebindConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if len(args) > 0 {
+		query = rebind(query)
+	}
+	return c.pqConn.QueryContext(ctx, query, args)
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebind(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"no parameters", "SELECT 1", "SELECT 1"},
+		{"parameters", "SELECT id FROM notes WHERE id = ? AND workspace_id = ? LIMIT ?", "SELECT id FROM notes WHERE id = $1 AND workspace_id = $2 LIMIT $3"},
+		{"quoted", `SELECT '?', "a?b" FROM notes WHERE title LIKE ? ESCAPE '\'`, `SELECT '?', "a?b" FROM notes WHERE title LIKE $1 ESCAPE '\'`},
+		{"doubled quotes", "SELECT 'it''s ?' WHERE id = ?", "SELECT 'it''s ?' WHERE id = $1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := rebind(tt.query)
+
+			// Assert
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestIsPostgresDSN(t *testing.T) {
+	assert.True(t, IsPostgresDSN("postgres://notes@localhost/notes"))
+	assert.True(t, IsPostgresDSN("postgresql://notes@localhost/notes?sslmode=disable"))
+	assert.False(t, IsPostgresDSN("./notes.db"))
+	assert.False(t, IsPostgresDSN("file:notes.db?cache=shared"))
+}
@@ -2,23 +2,130 @@ package db
 
 import (
 	"database/sql"
+	"embed"
+	"io/fs"
+	"strings"
+
+	"github.com/JannisK89/notes-api/internal/migrate"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+//go:embed migrations/*.sql
+var sqliteMigrations embed.FS
+
+// NewSQLiteDB opens the SQLite database at path and applies any pending
+// migrations. The driver must be built with the sqlite_fts5 tag, as note
+// search relies on an FTS5 index.
 func NewSQLiteDB(path string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", path)
+	db, err := OpenSQLiteDB(path)
 	if err != nil {
 		return nil, err
 	}
 
-	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS notes (
-        id INTEGER PRIMARY KEY AUTOINCREMENT,
-        title TEXT NOT NULL,
-        content TEXT NOT NULL
-    )`)
+	migrator, err := NewSQLiteMigrator(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	_, err = migrator.Up(false)
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
 	return db, nil
 }
+
+// OpenSQLiteDB opens the SQLite database at path without migrating it.
+// Transactions take the write lock when they begin, so that concurrent
+// writers wait for each other instead of failing with "database is locked"
+// when a transaction that has read tries to write.
+func OpenSQLiteDB(path string) (*sql.DB, error) {
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	db, err := sql.Open("sqlite3", path+sep+"_txlock=immediate")
+	if err != nil {
+		return nil, err
+	}
+	err = db.Ping()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// NewSQLiteMigrator returns a migrator for the SQLite schema of the notes
+// API.
+func NewSQLiteMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	migrations, err := SQLiteMigrations()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, migrations)
+}
+
+// SQLiteMigrations returns the SQL migrations embedded from the migrations
+// directory together with the Go migrations below.
+func SQLiteMigrations() ([]migrate.Migration, error) {
+	dir, err := fs.Sub(sqliteMigrations, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := migrate.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	return append(migrations, migrate.Migration{
+		Version:  2,
+		Name:     "add_note_metadata",
+		UpFunc:   addNoteMetadata,
+		DownFunc: dropNoteMetadata,
+	}), nil
+}
+
+// addNoteMetadata adds the created_at, updated_at and version columns to the
+// notes table and sets the creation and update time of existing notes to the
+// current time. Databases created before migrations were introduced may
+// already have the columns, in which case it does nothing.
+func addNoteMetadata(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT name FROM pragma_table_info('notes')")
+	if err != nil {
+		return err
+	}
+	columns := map[string]bool{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return err
+		}
+		columns[name] = true
+	}
+	rows.Close()
+	if columns["created_at"] {
+		return nil
+	}
+
+	_, err = tx.Exec(`
+        ALTER TABLE notes ADD COLUMN created_at TEXT NOT NULL DEFAULT '';
+        ALTER TABLE notes ADD COLUMN updated_at TEXT NOT NULL DEFAULT '';
+        ALTER TABLE notes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+        UPDATE notes SET
+            created_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now'),
+            updated_at = strftime('%Y-%m-%dT%H:%M:%fZ', 'now');
+    `)
+	return err
+}
+
+// dropNoteMetadata reverts addNoteMetadata.
+func dropNoteMetadata(tx *sql.Tx) error {
+	_, err := tx.Exec(`
+        ALTER TABLE notes DROP COLUMN version;
+        ALTER TABLE notes DROP COLUMN updated_at;
+        ALTER TABLE notes DROP COLUMN created_at;
+    `)
+	return err
+}
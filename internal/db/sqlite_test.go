@@ -0,0 +1,657 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/crdt"
+	"github.com/JannisK89/notes-api/internal/migrate"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSQLiteDB_SearchIndexFollowsNotes(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	search := func(query string) []int {
+		rows, err := db.Query("SELECT rowid FROM notes_fts WHERE notes_fts MATCH ? ORDER BY rowid", query)
+		require.NoError(t, err)
+		defer rows.Close()
+		ids := []int{}
+		for rows.Next() {
+			var id int
+			require.NoError(t, rows.Scan(&id))
+			ids = append(ids, id)
+		}
+		return ids
+	}
+
+	// Act
+	_, err = db.Exec("INSERT INTO notes (title, content, created_at, updated_at) VALUES ('Shopping', 'apples and pears', '', ''), ('Café', 'espresso tasting', '', '')")
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE notes SET content = 'bananas' WHERE id = 1")
+	require.NoError(t, err)
+	_, err = db.Exec("DELETE FROM notes WHERE id = 2")
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, []int{1}, search("banana*"))
+	assert.Empty(t, search("apples"))
+	assert.Empty(t, search("cafe"))
+}
+
+func TestNewSQLiteDB_MigratesLegacyDatabase(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	legacy, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL, content TEXT NOT NULL)`)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`INSERT INTO notes (title, content) VALUES ('Old', 'written before timestamps existed')`)
+	require.NoError(t, err)
+	legacy.Close()
+
+	// Act
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Assert
+	var createdAt, updatedAt string
+	var version int
+	require.NoError(t, db.QueryRow("SELECT created_at, updated_at, version FROM notes WHERE id = 1").Scan(&createdAt, &updatedAt, &version))
+	_, err = time.Parse("2006-01-02T15:04:05.000Z", createdAt)
+	assert.NoError(t, err)
+	assert.Equal(t, createdAt, updatedAt)
+	assert.Equal(t, 1, version)
+
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM notes_fts WHERE notes_fts MATCH '\"before timestamps\"'").Scan(&count))
+	assert.Equal(t, 1, count)
+
+	var revision string
+	require.NoError(t, db.QueryRow("SELECT content FROM note_revisions WHERE note_id = 1 AND version = 1").Scan(&revision))
+	assert.Equal(t, "written before timestamps existed", revision)
+}
+
+func TestSQLiteUsers_AdoptLegacyData(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	legacy, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, title TEXT NOT NULL, content TEXT NOT NULL)`)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`INSERT INTO notes (title, content) VALUES ('Old', 'written before there were users')`)
+	require.NoError(t, err)
+	legacy.Close()
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+
+	// Act
+	malloryId, err := users.Create(&models.User{Email: "mallory@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	mallory, err := workspaces.GetPersonalMember(malloryId)
+	require.NoError(t, err)
+	_, stolenErr := notes.Get(context.Background(), mallory.WorkspaceId, 1)
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	ada, err := workspaces.GetPersonalMember(adaId)
+	require.NoError(t, err)
+	adopted, adoptErr := users.AdoptLegacyData("ADA@example.com", false)
+	note, getErr := notes.Get(context.Background(), ada.WorkspaceId, 1)
+	again, againErr := users.AdoptLegacyData("ada@example.com", false)
+
+	// Assert
+	for _, err := range []error{adoptErr, getErr, againErr} {
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, stolenErr, repository.ErrNoteNotFound)
+	assert.Equal(t, &repository.LegacyData{Notes: 1}, adopted)
+	assert.Equal(t, "Old", note.Title)
+	assert.Equal(t, &repository.LegacyData{}, again)
+}
+
+func TestNewSQLiteDB_MigratesPreMigrationSchema(t *testing.T) {
+	// Arrange: the schema as it was created before migrations existed.
+	path := filepath.Join(t.TempDir(), "notes.db")
+	legacy, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`CREATE TABLE notes (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        title TEXT NOT NULL,
+        content TEXT NOT NULL,
+        created_at TEXT NOT NULL,
+        updated_at TEXT NOT NULL,
+        version INTEGER NOT NULL DEFAULT 1
+    )`)
+	require.NoError(t, err)
+	_, err = legacy.Exec(`INSERT INTO notes (title, content, created_at, updated_at, version) VALUES ('Kept', 'metadata', '2024-05-01T09:30:00.000Z', '2024-05-02T09:30:00.000Z', 3)`)
+	require.NoError(t, err)
+	legacy.Close()
+
+	// Act
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// Assert
+	var updatedAt string
+	var version int
+	require.NoError(t, db.QueryRow("SELECT updated_at, version FROM notes WHERE id = 1").Scan(&updatedAt, &version))
+	assert.Equal(t, "2024-05-02T09:30:00.000Z", updatedAt)
+	assert.Equal(t, 3, version)
+}
+
+func TestSQLiteMigrations_DownAndUp(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	migrator, err := NewSQLiteMigrator(db)
+	require.NoError(t, err)
+	migrator.Logf = t.Logf
+	migrations, err := SQLiteMigrations()
+	require.NoError(t, err)
+
+	// Act
+	rolledBack, downErr := migrator.Down(len(migrations), false)
+	applied, upErr := migrator.Up(false)
+
+	// Assert
+	assert.NoError(t, downErr)
+	assert.Len(t, rolledBack, len(migrations))
+	assert.NoError(t, upErr)
+	assert.Len(t, applied, len(migrations))
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	for _, s := range statuses {
+		assert.Equal(t, migrate.StateApplied, s.State, s.Name)
+	}
+}
+
+func TestSQLiteWorkspaces_IsolateData(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+	notebooks := repository.NewNotebooksRepository(db)
+
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	graceId, err := users.Create(&models.User{Email: "grace@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	ada, err := workspaces.GetPersonalMember(adaId)
+	require.NoError(t, err)
+	grace, err := workspaces.GetPersonalMember(graceId)
+	require.NoError(t, err)
+	noteId, err := notes.Create(context.Background(), ada.WorkspaceId, adaId, &models.Note{Title: "Private", Content: "Only Ada", Tags: []string{"secret"}})
+	require.NoError(t, err)
+	notebookId, err := notebooks.Create(ada.WorkspaceId, adaId, &models.Notebook{Name: "Work"})
+	require.NoError(t, err)
+
+	// Act
+	_, getErr := notes.Get(context.Background(), grace.WorkspaceId, noteId)
+	updateErr := notes.Update(context.Background(), grace.WorkspaceId, noteId, &models.Note{Title: "Mine", Content: "Now"}, 0)
+	deleteErr := notes.Delete(context.Background(), grace.WorkspaceId, noteId, 0)
+	_, accessErr := notes.GetNoteAccess(context.Background(), grace.WorkspaceId, graceId, noteId)
+	_, revisionsErr := notes.GetRevisions(context.Background(), grace.WorkspaceId, noteId)
+	_, linkErr := notes.CreateShareLink(context.Background(), grace.WorkspaceId, noteId, &models.ShareLink{Prefix: "abcdefgh"}, "hash", "")
+	moveErr := notes.MoveNote(context.Background(), ada.WorkspaceId, noteId, &notebookId, 0)
+	_, notebookErr := notebooks.Get(grace.WorkspaceId, notebookId)
+	renameErr := notebooks.Rename(grace.WorkspaceId, notebookId, "Mine")
+	page, listErr := notes.GetAll(context.Background(), grace.WorkspaceId, models.ListOptions{Limit: 10})
+	tags, tagsErr := notes.GetTags(context.Background(), grace.WorkspaceId)
+	_, addErr := workspaces.AddMember(ada.WorkspaceId, "grace@example.com", models.RoleViewer)
+	_, memberErr := workspaces.GetMember(ada.WorkspaceId, graceId)
+
+	// Assert
+	assert.ErrorIs(t, getErr, repository.ErrNoteNotFound)
+	assert.ErrorIs(t, updateErr, repository.ErrNoteNotFound)
+	assert.ErrorIs(t, deleteErr, repository.ErrNoteNotFound)
+	assert.ErrorIs(t, accessErr, repository.ErrNoteNotFound)
+	assert.ErrorIs(t, revisionsErr, repository.ErrNoteNotFound)
+	assert.ErrorIs(t, linkErr, repository.ErrNoteNotFound)
+	assert.NoError(t, moveErr)
+	assert.ErrorIs(t, notebookErr, repository.ErrNotebookNotFound)
+	assert.ErrorIs(t, renameErr, repository.ErrNotebookNotFound)
+	assert.NoError(t, listErr)
+	assert.Empty(t, page.Notes)
+	assert.NoError(t, tagsErr)
+	assert.Empty(t, tags)
+	assert.ErrorIs(t, addErr, repository.ErrPersonalWorkspace)
+	assert.ErrorIs(t, memberErr, repository.ErrWorkspaceNotFound)
+	note, err := notes.Get(context.Background(), ada.WorkspaceId, noteId)
+	require.NoError(t, err)
+	assert.Equal(t, "Private", note.Title)
+	assert.Equal(t, 2, note.Version)
+}
+
+func TestSQLiteWorkspaces_ShareWithMembers(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	graceId, err := users.Create(&models.User{Email: "grace@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	team := &models.Workspace{Name: "Team"}
+	teamId, err := workspaces.Create(adaId, team)
+	require.NoError(t, err)
+	noteId, err := notes.Create(context.Background(), teamId, adaId, &models.Note{Title: "Plan", Content: "Ship it", Tags: []string{"work"}})
+	require.NoError(t, err)
+
+	// Act
+	_, beforeErr := workspaces.GetMember(teamId, graceId)
+	_, addErr := workspaces.AddMember(teamId, "grace@example.com", models.RoleViewer)
+	member, memberErr := workspaces.GetMember(teamId, graceId)
+	shared, shareErr := notes.ShareNote(context.Background(), teamId, noteId, "grace@example.com", models.RoleEditor)
+	role, accessErr := notes.GetNoteAccess(context.Background(), teamId, graceId, noteId)
+	_, demoteErr := workspaces.AddMember(teamId, "ada@example.com", models.RoleEditor)
+	removeErr := workspaces.RemoveMember(teamId, graceId)
+	afterRole, afterErr := notes.GetNoteAccess(context.Background(), teamId, graceId, noteId)
+	all, allErr := workspaces.GetAll(adaId)
+
+	// Assert
+	assert.ErrorIs(t, beforeErr, repository.ErrWorkspaceNotFound)
+	assert.NoError(t, addErr)
+	assert.NoError(t, memberErr)
+	assert.Equal(t, models.RoleViewer, member.Role)
+	assert.NoError(t, shareErr)
+	assert.Equal(t, graceId, shared.UserId)
+	assert.NoError(t, accessErr)
+	assert.Equal(t, models.RoleEditor, role)
+	assert.ErrorIs(t, demoteErr, repository.ErrLastOwner)
+	assert.NoError(t, removeErr)
+	assert.NoError(t, afterErr)
+	assert.Empty(t, afterRole)
+	assert.NoError(t, allErr)
+	require.Len(t, all, 2)
+	assert.True(t, all[0].Personal)
+	assert.Equal(t, "Team", all[1].Name)
+	assert.Equal(t, models.RoleOwner, all[1].Role)
+}
+
+func TestSQLiteWebhooks_DeliverNoteEvents(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+	webhooks := repository.NewWebhooksRepository(db)
+
+	var mu sync.Mutex
+	failing := true
+	var received []*http.Request
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received, bodies = append(received, r), append(bodies, body)
+		if failing {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer receiver.Close()
+
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	ada, err := workspaces.GetPersonalMember(adaId)
+	require.NoError(t, err)
+	webhookId, err := webhooks.Create(ada.WorkspaceId, &models.Webhook{
+		URL:    receiver.URL,
+		Events: []models.EventType{models.EventNoteCreated, models.EventNoteDeleted},
+		Secret: "whsec_0123456789abcdef",
+	})
+	require.NoError(t, err)
+	dispatcher := service.NewWebhookDispatcher(webhooks)
+	dispatcher.AllowPrivateTargets = true
+	dispatcher.MaxAttempts = 2
+	dispatcher.RetryDelay = 50 * time.Millisecond
+	ctx := context.Background()
+
+	// Act
+	noteId, err := notes.Create(context.Background(), ada.WorkspaceId, adaId, &models.Note{Title: "Plan", Content: "Ship it"})
+	require.NoError(t, err)
+	first, firstErr := dispatcher.DeliverOnce(ctx)
+	early, earlyErr := dispatcher.DeliverOnce(ctx)
+	time.Sleep(60 * time.Millisecond)
+	retried, retriedErr := dispatcher.DeliverOnce(ctx)
+	time.Sleep(200 * time.Millisecond)
+	afterDead, afterDeadErr := dispatcher.DeliverOnce(ctx)
+	dead, deadErr := webhooks.GetDeliveries(ada.WorkspaceId, webhookId)
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	_, redeliverErr := webhooks.Redeliver(ada.WorkspaceId, webhookId, dead[0].Id)
+	redelivered, redeliveredErr := dispatcher.DeliverOnce(ctx)
+	updateErr := notes.Update(context.Background(), ada.WorkspaceId, noteId, &models.Note{Title: "Plan", Content: "Shipped"}, 0)
+	deleteErr := notes.Delete(context.Background(), ada.WorkspaceId, noteId, 0)
+	deleted, deletedErr := dispatcher.DeliverOnce(ctx)
+	deliveries, deliveriesErr := webhooks.GetDeliveries(ada.WorkspaceId, webhookId)
+
+	// Assert
+	for _, err := range []error{firstErr, earlyErr, retriedErr, afterDeadErr, deadErr, redeliverErr, redeliveredErr, updateErr, deleteErr, deletedErr, deliveriesErr} {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{1, 0, 1, 0, 1, 1}, []int{first, early, retried, afterDead, redelivered, deleted})
+	require.Len(t, dead, 1)
+	assert.Equal(t, models.DeliveryDead, dead[0].Status)
+	assert.Equal(t, 2, dead[0].Attempts)
+	assert.Equal(t, http.StatusServiceUnavailable, dead[0].LastStatusCode)
+
+	require.Len(t, received, 4)
+	req, body := received[2], bodies[2]
+	timestamp, err := strconv.ParseInt(req.Header.Get(service.TimestampHeader), 10, 64)
+	require.NoError(t, err)
+	assert.Equal(t, string(models.EventNoteCreated), req.Header.Get(service.EventHeader))
+	assert.Equal(t, strconv.Itoa(dead[0].Id), req.Header.Get(service.DeliveryHeader))
+	assert.Equal(t, service.SignPayload("whsec_0123456789abcdef", timestamp, body), req.Header.Get(service.SignatureHeader))
+	var event models.Event
+	require.NoError(t, json.Unmarshal(body, &event))
+	assert.Equal(t, models.EventNoteCreated, event.Type)
+	assert.Equal(t, noteId, event.NoteId)
+	require.NotNil(t, event.Note)
+	assert.Equal(t, "Plan", event.Note.Title)
+	assert.Equal(t, string(models.EventNoteDeleted), received[3].Header.Get(service.EventHeader))
+
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, models.EventNoteDeleted, deliveries[0].Event)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Equal(t, models.EventNoteCreated, deliveries[1].Event)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[1].Status)
+	assert.Equal(t, 1, deliveries[1].Attempts)
+	assert.NotNil(t, deliveries[1].DeliveredAt)
+}
+
+func TestSQLiteWebhooks_DispatchersShareDeliveries(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+	webhooks := repository.NewWebhooksRepository(db)
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	fastDone := make(chan struct{})
+	blocked := false
+	receive := func(r *http.Request) int {
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.Header.Get(service.DeliveryHeader)]++
+		return len(received)
+	}
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if receive(r) == 3 {
+			close(fastDone)
+		}
+	}))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-fastDone:
+		case <-time.After(5 * time.Second):
+			mu.Lock()
+			blocked = true
+			mu.Unlock()
+		}
+		receive(r)
+	}))
+	defer slow.Close()
+
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	ada, err := workspaces.GetPersonalMember(adaId)
+	require.NoError(t, err)
+	for _, url := range []string{slow.URL, fast.URL} {
+		_, err = webhooks.Create(ada.WorkspaceId, &models.Webhook{
+			URL:    url,
+			Events: []models.EventType{models.EventNoteCreated},
+			Secret: "whsec_0123456789abcdef",
+		})
+		require.NoError(t, err)
+	}
+	for _, title := range []string{"Plan", "Build", "Ship"} {
+		_, err = notes.Create(context.Background(), ada.WorkspaceId, adaId, &models.Note{Title: title, Content: "Soon"})
+		require.NoError(t, err)
+	}
+	dispatchers := []*service.WebhookDispatcher{service.NewWebhookDispatcher(webhooks), service.NewWebhookDispatcher(webhooks)}
+
+	// Act
+	attempted := make([]int, len(dispatchers))
+	errs := make([]error, len(dispatchers))
+	var wg sync.WaitGroup
+	for i, dispatcher := range dispatchers {
+		dispatcher.AllowPrivateTargets = true
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			attempted[i], errs[i] = dispatcher.DeliverOnce(context.Background())
+		}()
+	}
+	wg.Wait()
+	due, dueErr := webhooks.GetDueDeliveries(time.Now(), 10)
+
+	// Assert
+	for _, err := range append(errs, dueErr) {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 6, attempted[0]+attempted[1])
+	assert.Empty(t, due)
+	assert.False(t, blocked, "the slow webhook held up the fast one")
+	assert.Len(t, received, 6)
+	for id, n := range received {
+		assert.Equal(t, 1, n, "delivery %s was sent %d times", id, n)
+	}
+}
+
+func TestSQLiteWebhooks_RefusePrivateTargets(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+	webhooks := repository.NewWebhooksRepository(db)
+
+	var mu sync.Mutex
+	received := 0
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received++
+	}))
+	defer receiver.Close()
+
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	ada, err := workspaces.GetPersonalMember(adaId)
+	require.NoError(t, err)
+	webhookId, err := webhooks.Create(ada.WorkspaceId, &models.Webhook{
+		URL:    receiver.URL,
+		Events: []models.EventType{models.EventNoteCreated},
+		Secret: "whsec_0123456789abcdef",
+	})
+	require.NoError(t, err)
+	dispatcher := service.NewWebhookDispatcher(webhooks)
+	ctx := context.Background()
+
+	// Act
+	_, err = notes.Create(context.Background(), ada.WorkspaceId, adaId, &models.Note{Title: "Plan", Content: "Ship it"})
+	require.NoError(t, err)
+	refused, refusedErr := dispatcher.DeliverOnce(ctx)
+	failed, failedErr := webhooks.GetDeliveries(ada.WorkspaceId, webhookId)
+	mu.Lock()
+	refusedReceived := received
+	mu.Unlock()
+
+	dispatcher.AllowPrivateTargets = true
+	_, redeliverErr := webhooks.Redeliver(ada.WorkspaceId, webhookId, failed[0].Id)
+	allowed, allowedErr := dispatcher.DeliverOnce(ctx)
+	deliveries, deliveriesErr := webhooks.GetDeliveries(ada.WorkspaceId, webhookId)
+
+	// Assert
+	for _, err := range []error{refusedErr, failedErr, redeliverErr, allowedErr, deliveriesErr} {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []int{1, 1}, []int{refused, allowed})
+	assert.Equal(t, 0, refusedReceived)
+	require.Len(t, failed, 1)
+	assert.Equal(t, models.DeliveryPending, failed[0].Status)
+	assert.Equal(t, 0, failed[0].LastStatusCode)
+	assert.Contains(t, failed[0].LastError, service.ErrPrivateTarget.Error())
+	assert.Equal(t, 1, received)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+}
+
+func TestSQLiteSync_NumbersEveryWrite(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	graceId, err := users.Create(&models.User{Email: "grace@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	ada, err := workspaces.GetPersonalMember(adaId)
+	require.NoError(t, err)
+	grace, err := workspaces.GetPersonalMember(graceId)
+	require.NoError(t, err)
+	planId, err := notes.Create(context.Background(), ada.WorkspaceId, adaId, &models.Note{Title: "Plan", Content: "Ship it", Tags: []string{"work"}})
+	require.NoError(t, err)
+	draftId, err := notes.Create(context.Background(), ada.WorkspaceId, adaId, &models.Note{Title: "Draft", Content: "Maybe"})
+	require.NoError(t, err)
+	_, err = notes.Create(context.Background(), grace.WorkspaceId, graceId, &models.Note{Title: "Other", Content: "Not Ada's"})
+	require.NoError(t, err)
+
+	// Act
+	initial, initialErr := notes.GetChanges(context.Background(), ada.WorkspaceId, 0, 100)
+	first, firstErr := notes.GetChanges(context.Background(), ada.WorkspaceId, 0, 1)
+	unchanged, unchangedErr := notes.GetChanges(context.Background(), ada.WorkspaceId, initial.Seq, 100)
+	updateErr := notes.Update(context.Background(), ada.WorkspaceId, planId, &models.Note{Title: "Plan", Content: "Shipped"}, 0)
+	_, renameErr := notes.RenameTag(context.Background(), ada.WorkspaceId, "work", "done")
+	deleteErr := notes.Delete(context.Background(), ada.WorkspaceId, draftId, 0)
+	trashed, trashedErr := notes.GetChanges(context.Background(), ada.WorkspaceId, initial.Seq, 100)
+	purgeErr := notes.Purge(context.Background(), ada.WorkspaceId, draftId)
+	purged, purgedErr := notes.GetChanges(context.Background(), ada.WorkspaceId, trashed.Seq, 100)
+	results, searchErr := notes.Search(context.Background(), ada.WorkspaceId, models.SearchOptions{Query: "shipped", Limit: 10})
+
+	// Assert
+	for _, err := range []error{initialErr, firstErr, unchangedErr, updateErr, renameErr, deleteErr, trashedErr, purgeErr, purgedErr, searchErr} {
+		require.NoError(t, err)
+	}
+	require.Len(t, initial.Changes, 2)
+	assert.Equal(t, planId, initial.Changes[0].NoteId)
+	assert.Equal(t, []string{"work"}, initial.Changes[0].Note.Tags)
+	assert.Equal(t, draftId, initial.Changes[1].NoteId)
+	assert.Less(t, initial.Changes[0].Seq, initial.Changes[1].Seq)
+	assert.Equal(t, initial.Changes[1].Seq, initial.Seq)
+	assert.False(t, initial.HasMore)
+	require.Len(t, first.Changes, 1)
+	assert.True(t, first.HasMore)
+	assert.Equal(t, initial.Changes[0].Seq, first.Seq)
+	assert.Empty(t, unchanged.Changes)
+	assert.Equal(t, initial.Seq, unchanged.Seq)
+
+	require.Len(t, trashed.Changes, 2)
+	assert.Equal(t, planId, trashed.Changes[0].NoteId)
+	assert.Equal(t, "Shipped", trashed.Changes[0].Note.Content)
+	assert.Equal(t, []string{"done"}, trashed.Changes[0].Note.Tags)
+	assert.Equal(t, 3, trashed.Changes[0].Note.Version)
+	assert.Equal(t, &models.Change{Seq: trashed.Changes[1].Seq, NoteId: draftId, Deleted: true, DeletedAt: trashed.Changes[1].DeletedAt}, trashed.Changes[1])
+	require.NotNil(t, trashed.Changes[1].DeletedAt)
+	require.Len(t, purged.Changes, 1)
+	assert.Equal(t, draftId, purged.Changes[0].NoteId)
+	assert.True(t, purged.Changes[0].Deleted)
+	assert.NotNil(t, purged.Changes[0].DeletedAt)
+	require.Len(t, results, 1)
+	assert.Equal(t, planId, results[0].Id)
+}
+
+func TestSQLiteCollab_MergesOutsideChanges(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.db")
+	db, err := NewSQLiteDB(path)
+	require.NoError(t, err)
+	defer db.Close()
+	users := repository.NewUsersRepository(db)
+	workspaces := repository.NewWorkspacesRepository(db)
+	notes := repository.NewNotesRepository(db)
+
+	adaId, err := users.Create(&models.User{Email: "ada@example.com", PasswordHash: "hash"})
+	require.NoError(t, err)
+	ada, err := workspaces.GetPersonalMember(adaId)
+	require.NoError(t, err)
+	noteId, err := notes.Create(context.Background(), ada.WorkspaceId, adaId, &models.Note{Title: "Hi", Content: "hello world", Tags: []string{"greeting"}})
+	require.NoError(t, err)
+	hub := service.NewCollabHub(notes)
+	hub.SaveInterval = time.Hour
+	c, err := hub.Join(context.Background(), *ada, noteId, false)
+	require.NoError(t, err)
+	snapshot := <-c.Messages()
+
+	// Act
+	applyErr := c.Apply(&models.CollabMessage{Type: models.CollabInsert, Id: &crdt.Id{Site: snapshot.Site, Clock: 12}, After: &crdt.Id{Clock: 11}, Text: "!"})
+	updateErr := notes.Update(context.Background(), ada.WorkspaceId, noteId, &models.Note{Title: "Greeting", Content: "hello big world"}, 0)
+	c.Leave()
+	hub.Close()
+	note, getErr := notes.Get(context.Background(), ada.WorkspaceId, noteId)
+
+	// Assert
+	require.NoError(t, applyErr)
+	require.NoError(t, updateErr)
+	require.NoError(t, getErr)
+	assert.Equal(t, "hello big world!", note.Content)
+	assert.Equal(t, "Greeting", note.Title)
+	assert.Equal(t, 3, note.Version)
+	assert.Equal(t, []string{"greeting"}, note.Tags)
+}
@@ -0,0 +1,236 @@
+// Package diff computes the differences between two texts, either line by
+// line as a unified diff or word by word.
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Op is the kind of an Edit.
+type Op string
+
+const (
+	// Equal is text found in both texts.
+	Equal Op = "equal"
+	// Insert is text only found in the new text.
+	Insert Op = "insert"
+	// Delete is text only found in the old text.
+	Delete Op = "delete"
+)
+
+// Edit is a piece of text that is kept, inserted or deleted when going from
+// the old text to the new one.
+type Edit struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Words returns the edits that turn a into b, comparing words and the
+// whitespace between them. Consecutive edits of the same kind are joined.
+func Words(a, b string) []Edit {
+	edits := []Edit{}
+	for _, e := range compare(splitWords(a), splitWords(b)) {
+		if n := len(edits); n > 0 && edits[n-1].Op == e.Op {
+			edits[n-1].Text += e.Text
+			continue
+		}
+		edits = append(edits, e)
+	}
+	return edits
+}
+
+// Unified returns a unified diff of the lines of a and b with the given
+// number of context lines around each change, or "" if they are equal. The
+// headers name the texts fromName and toName.
+func Unified(fromName, toName, a, b string, context int) string {
+	edits := compare(splitLines(a), splitLines(b))
+
+	changes := []int{}
+	for i, e := range edits {
+		if e.Op != Equal {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+	for first := 0; first < len(changes); {
+		// Changes closer than twice the context share a hunk.
+		last := first
+		for last+1 < len(changes) && changes[last+1]-changes[last] <= 2*context+1 {
+			last++
+		}
+		start := max(changes[first]-context, 0)
+		end := min(changes[last]+context+1, len(edits))
+		writeHunk(&out, edits, start, end)
+		first = last + 1
+	}
+	return out.String()
+}
+
+// writeHunk writes the lines edits[start:end] as a hunk of a unified diff.
+func writeHunk(out *strings.Builder, edits []Edit, start, end int) {
+	var fromLine, toLine int
+	for _, e := range edits[:start] {
+		if e.Op != Insert {
+			fromLine++
+		}
+		if e.Op != Delete {
+			toLine++
+		}
+	}
+	var fromLen, toLen int
+	for _, e := range edits[start:end] {
+		if e.Op != Insert {
+			fromLen++
+		}
+		if e.Op != Delete {
+			toLen++
+		}
+	}
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(fromLine, fromLen), hunkRange(toLine, toLen))
+
+	prefixes := map[Op]string{Equal: " ", Insert: "+", Delete: "-"}
+	for _, e := range edits[start:end] {
+		out.WriteString(prefixes[e.Op])
+		out.WriteString(e.Text)
+		if !strings.HasSuffix(e.Text, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+// hunkRange formats the range of a hunk that starts after line before and
+// spans n lines.
+func hunkRange(before, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	default:
+		return fmt.Sprintf("%d,%d", before+1, n)
+	}
+}
+
+// splitLines splits s into lines, keeping their line breaks.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// splitWords splits s into alternating runs of whitespace and other
+// characters.
+func splitWords(s string) []string {
+	words := []string{}
+	start, space := 0, false
+	for i, r := range s {
+		if i > start && unicode.IsSpace(r) != space {
+			words = append(words, s[start:i])
+			start = i
+		}
+		space = unicode.IsSpace(r)
+	}
+	if start < len(s) {
+		words = append(words, s[start:])
+	}
+	return words
+}
+
+// compare returns the shortest list of single-token edits that turns a into
+// b, using the algorithm from Myers' "An O(ND) Difference Algorithm and Its
+// Variations".
+func compare(a, b []string) []Edit {
+	// Common prefixes and suffixes are equal either way, so skip them.
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	edits := make([]Edit, 0, len(a)+len(b))
+	for _, token := range a[:prefix] {
+		edits = append(edits, Edit{Equal, token})
+	}
+	edits = append(edits, shortestEdit(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, token := range a[len(a)-suffix:] {
+		edits = append(edits, Edit{Equal, token})
+	}
+	return edits
+}
+
+// shortestEdit implements the greedy forward search of Myers' algorithm and
+// walks back through the recorded frontiers to recover the edits.
+func shortestEdit(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	// v[offset+k] is the furthest x reached on diagonal k = x - y.
+	v := make([]int, 2*offset+1)
+	trace := [][]int{}
+
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	edits := []Edit{}
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			edits = append(edits, Edit{Equal, a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				edits = append(edits, Edit{Insert, b[y-1]})
+			} else {
+				edits = append(edits, Edit{Delete, a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
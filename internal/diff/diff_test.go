@@ -0,0 +1,121 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Edit
+	}{
+		{"equal", "same text", "same text", []Edit{{Equal, "same text"}}},
+		{"empty", "", "", []Edit{}},
+		{"insert", "", "new", []Edit{{Insert, "new"}}},
+		{"delete", "old", "", []Edit{{Delete, "old"}}},
+		{
+			"replace word",
+			"the quick brown fox",
+			"the slow brown fox",
+			[]Edit{{Equal, "the "}, {Delete, "quick"}, {Insert, "slow"}, {Equal, " brown fox"}},
+		},
+		{
+			"append words",
+			"buy apples",
+			"buy apples and pears",
+			[]Edit{{Equal, "buy apples"}, {Insert, " and pears"}},
+		},
+		{
+			"unicode",
+			"café au lait",
+			"café noir",
+			[]Edit{{Equal, "café "}, {Delete, "au lait"}, {Insert, "noir"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			got := Words(tt.a, tt.b)
+
+			// Assert
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWordsRebuildsBothTexts(t *testing.T) {
+	// Arrange
+	a := "one two three four five six seven"
+	b := "zero one three four 4.5 five seven eight"
+
+	// Act
+	edits := Words(a, b)
+
+	// Assert
+	var from, to strings.Builder
+	for _, e := range edits {
+		if e.Op != Insert {
+			from.WriteString(e.Text)
+		}
+		if e.Op != Delete {
+			to.WriteString(e.Text)
+		}
+	}
+	assert.Equal(t, a, from.String())
+	assert.Equal(t, b, to.String())
+}
+
+func TestUnified(t *testing.T) {
+	// Arrange
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n"
+
+	// Act
+	got := Unified("v1", "v2", a, b, 2)
+
+	// Assert
+	assert.Equal(t, `--- v1
++++ v2
+@@ -1,5 +1,5 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+@@ -11,2 +11,3 @@
+ 11
+ 12
++13
+`, got)
+}
+
+func TestUnifiedJoinsNearbyChanges(t *testing.T) {
+	// Act
+	got := Unified("a", "b", "1\n2\n3\n4\n5\n", "1\nX\n3\n4\nY\n", 1)
+
+	// Assert
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+X\n 3\n 4\n-5\n+Y\n", got)
+}
+
+func TestUnifiedNoNewlineAtEnd(t *testing.T) {
+	// Act
+	got := Unified("a", "b", "first\nlast", "first\nlast\n", 3)
+
+	// Assert
+	assert.Equal(t, "--- a\n+++ b\n@@ -1,2 +1,2 @@\n first\n-last\n\\ No newline at end of file\n+last\n", got)
+}
+
+func TestUnifiedEmpty(t *testing.T) {
+	// Act
+	equal := Unified("a", "b", "same\n", "same\n", 3)
+	created := Unified("a", "b", "", "new\n", 3)
+
+	// Assert
+	assert.Empty(t, equal)
+	assert.Equal(t, "--- a\n+++ b\n@@ -0,0 +1 @@\n+new\n", created)
+}
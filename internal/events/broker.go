@@ -0,0 +1,147 @@
+// Package events passes the changes made to notes on to the clients that
+// follow them. It keeps a bounded log of recent events so that clients can
+// resume after reconnecting.
+package events
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// DefaultLogSize is the number of events a Broker keeps for resuming when
+// no size is configured.
+const DefaultLogSize = 1000
+
+// subscriptionBuffer is the number of events a subscriber may fall behind
+// before it is dropped.
+const subscriptionBuffer = 64
+
+// ErrClosed is returned when subscribing to a Broker that has been closed.
+var ErrClosed = errors.New("event broker is closed")
+
+// Broker assigns ids to published events, keeps the most recent ones and
+// passes them on to the subscribers of their workspace. It is safe for
+// concurrent use.
+type Broker struct {
+	mu     sync.Mutex
+	size   int
+	log    []models.Event
+	lastId int
+	subs   map[*Subscription]struct{}
+	closed bool
+	now    func() time.Time
+}
+
+// NewBroker creates a Broker that keeps the last size events, or
+// DefaultLogSize if size is less than 1.
+func NewBroker(size int) *Broker {
+	if size < 1 {
+		size = DefaultLogSize
+	}
+	return &Broker{size: size, subs: map[*Subscription]struct{}{}, now: time.Now}
+}
+
+// Publish assigns the next id and the current time to e, adds it to the log
+// and sends it to the subscribers of its workspace. Subscribers that cannot
+// keep up are dropped, they can resume from the log. Events published after
+// Close are discarded.
+func (b *Broker) Publish(e models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.lastId++
+	e.Id = b.lastId
+	e.CreatedAt = b.now().UTC()
+	if len(b.log) == b.size {
+		b.log = append(b.log[:0], b.log[1:]...)
+	}
+	b.log = append(b.log, e)
+
+	for sub := range b.subs {
+		if sub.workspaceId != e.WorkspaceId {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe follows the events of a workspace. If lastId is not 0 it also
+// returns the logged events of the workspace after that id, or a single
+// EventReset event if the log no longer reaches back to it.
+// It returns ErrClosed if the broker has been closed.
+func (b *Broker) Subscribe(workspaceId int, lastId int) (*Subscription, []models.Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, ErrClosed
+	}
+
+	sub := &Subscription{broker: b, workspaceId: workspaceId, events: make(chan models.Event, subscriptionBuffer)}
+	b.subs[sub] = struct{}{}
+	if lastId == 0 {
+		return sub, nil, nil
+	}
+
+	// Ids restart after the server restarts, so an id from the future is as
+	// lost as one that dropped out of the log.
+	if lastId > b.lastId || (len(b.log) > 0 && lastId < b.log[0].Id-1) {
+		reset := models.Event{Id: b.lastId, Type: models.EventReset, WorkspaceId: workspaceId, CreatedAt: b.now().UTC()}
+		return sub, []models.Event{reset}, nil
+	}
+	missed := []models.Event{}
+	for _, e := range b.log {
+		if e.Id > lastId && e.WorkspaceId == workspaceId {
+			missed = append(missed, e)
+		}
+	}
+	return sub, missed, nil
+}
+
+// Close ends all subscriptions and discards events published afterwards.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
+
+// drop ends a subscription. b.mu must be held.
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// Subscription receives the events of a workspace from a Broker.
+type Subscription struct {
+	broker      *Broker
+	workspaceId int
+	events      chan models.Event
+}
+
+// Events returns the channel the events are delivered on. It is closed when
+// the subscription ends, because it was closed, fell too far behind or the
+// broker was closed.
+func (s *Subscription) Events() <-chan models.Event {
+	return s.events
+}
+
+// Close ends the subscription. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
@@ -0,0 +1,105 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+var published = time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+// newTestBroker returns a Broker of the given size with a fixed clock.
+func newTestBroker(size int) *Broker {
+	b := NewBroker(size)
+	b.now = func() time.Time { return published }
+	return b
+}
+
+func TestBroker_PublishToWorkspace(t *testing.T) {
+	b := newTestBroker(10)
+	sub, missed, err := b.Subscribe(3, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, missed)
+
+	b.Publish(models.Event{Type: models.EventNoteCreated, WorkspaceId: 3, NoteId: 1})
+	b.Publish(models.Event{Type: models.EventNoteCreated, WorkspaceId: 4, NoteId: 2})
+	b.Publish(models.Event{Type: models.EventNoteDeleted, WorkspaceId: 3, NoteId: 1})
+
+	assert.Equal(t, models.Event{Id: 1, Type: models.EventNoteCreated, WorkspaceId: 3, NoteId: 1, CreatedAt: published}, <-sub.Events())
+	assert.Equal(t, models.Event{Id: 3, Type: models.EventNoteDeleted, WorkspaceId: 3, NoteId: 1, CreatedAt: published}, <-sub.Events())
+	assert.Empty(t, sub.Events())
+}
+
+func TestBroker_SubscribeResumes(t *testing.T) {
+	b := newTestBroker(3)
+	for i := 1; i <= 5; i++ {
+		b.Publish(models.Event{Type: models.EventNoteUpdated, WorkspaceId: 3 + (i+1)%2, NoteId: i})
+	}
+
+	tests := []struct {
+		name   string
+		lastId int
+		want   []int
+	}{
+		{"none", 0, nil},
+		{"logged", 2, []int{3, 5}},
+		{"oldest logged", 3, []int{5}},
+		{"current", 5, []int{}},
+		{"dropped out of log", 1, []int{5}},
+		{"from the future", 9, []int{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, missed, err := b.Subscribe(3, tt.lastId)
+			defer sub.Close()
+
+			assert.NoError(t, err)
+			if tt.want == nil {
+				assert.Nil(t, missed)
+				return
+			}
+			ids := []int{}
+			for _, e := range missed {
+				ids = append(ids, e.Id)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+
+	_, missed, _ := b.Subscribe(3, 1)
+	assert.Equal(t, []models.Event{{Id: 5, Type: models.EventReset, WorkspaceId: 3, CreatedAt: published}}, missed)
+}
+
+func TestBroker_DropsSlowSubscriber(t *testing.T) {
+	b := newTestBroker(10)
+	sub, _, _ := b.Subscribe(3, 0)
+
+	for i := 0; i <= subscriptionBuffer; i++ {
+		b.Publish(models.Event{Type: models.EventNoteUpdated, WorkspaceId: 3, NoteId: 1})
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	assert.Equal(t, subscriptionBuffer, received)
+}
+
+func TestBroker_Close(t *testing.T) {
+	b := newTestBroker(10)
+	sub, _, _ := b.Subscribe(3, 0)
+	closed, _, _ := b.Subscribe(3, 0)
+	closed.Close()
+	closed.Close()
+
+	b.Close()
+	b.Publish(models.Event{Type: models.EventNoteCreated, WorkspaceId: 3, NoteId: 1})
+	_, _, err := b.Subscribe(3, 0)
+
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.Empty(t, b.log)
+}
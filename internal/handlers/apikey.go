@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// ErrInsufficientScope is returned when a request is made with an API key
+// that lacks the scope the endpoint requires
+var ErrInsufficientScope = errors.New("api key lacks the required scope")
+
+// ErrSessionRequired is returned when an endpoint that only accepts session
+// tokens is called with an API key
+var ErrSessionRequired = errors.New("endpoint requires a session token, not an api key")
+
+// RequireScope returns a middleware that only lets requests through that
+// were made with a session token or with an API key that has scope. It must
+// run after Authenticate.
+// It returns a 403 error if the API key lacks the scope.
+func RequireScope(scope models.Scope) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key := getAPIKey(r); key != nil && !key.HasScope(scope) {
+				writeScopeError(w, scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// writeScopeError responds with a 403 error naming the scope the API key of
+// the request lacks.
+func writeScopeError(w http.ResponseWriter, scope models.Scope) {
+	w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="notes-api", error="insufficient_scope", scope=%q`, scope))
+	utils.ErrorResponse(w, http.StatusForbidden, fmt.Sprintf("%s: %s", ErrInsufficientScope, scope))
+}
+
+// RequireSession is a middleware that turns away requests made with an API
+// key, so keys cannot be used to mint more keys or end sessions. It must run
+// after Authenticate.
+// It returns a 403 error if the request was made with an API key.
+func RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if getAPIKey(r) != nil {
+			utils.ErrorResponse(w, http.StatusForbidden, ErrSessionRequired.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// GetAPIKeys lists the API keys of the user, without the keys themselves.
+func (h UserHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.userService.GetAPIKeys(getUserId(r))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: keys})
+}
+
+// CreateAPIKey mints an API key with the name, scopes and optional
+// expires_at in the request body and responds with it. The response is the
+// only time the key is shown.
+// It returns a 400 error if the name, scopes or expiry are invalid.
+func (h UserHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	key := &models.APIKey{}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(key); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	created, err := h.userService.CreateAPIKey(getUserId(r), key)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidAPIKey) || errors.Is(err, service.ErrInvalidScope) {
+			utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: created})
+}
+
+// DeleteAPIKey revokes an API key of the user.
+// It returns a 400 error if the id is invalid and a 404 error if the user
+// has no key with the id.
+func (h UserHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	keyid, err := strconv.Atoi(chi.URLParam(r, "keyId"))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, ErrInvalidId.Error())
+		return
+	}
+
+	if err := h.userService.DeleteAPIKey(getUserId(r), keyid); err != nil {
+		log.Println(err)
+		if errors.Is(err, repository.ErrAPIKeyNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrAPIKeyNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidId) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
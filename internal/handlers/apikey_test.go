@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestUserHandler_CreateAPIKey(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	userRepoMock.On("CreateAPIKey", testUserId, mock.AnythingOfType("*models.APIKey"), mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { args.Get(1).(*models.APIKey).Id = 3 }).Return(3, nil)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"name": " Backup ", "scopes": ["notes:write", "notes:read", "notes:read"], "expires_at": "2999-01-01T00:00:00Z"}`, http.StatusCreated},
+		{"no scopes", `{"name": "Backup", "scopes": []}`, http.StatusBadRequest},
+		{"unknown scope", `{"name": "Backup", "scopes": ["notes:admin"]}`, http.StatusBadRequest},
+		{"no name", `{"name": " ", "scopes": ["notes:read"]}`, http.StatusBadRequest},
+		{"expired", `{"name": "Backup", "scopes": ["notes:read"], "expires_at": "2000-01-01T00:00:00Z"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, "/api/v1/auth/keys", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			// Act
+			userHandler.CreateAPIKey(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	userRepoMock.AssertNumberOfCalls(t, "CreateAPIKey", 1)
+
+	stored := userRepoMock.Calls[0].Arguments
+	key := stored.Get(1).(*models.APIKey)
+	assert.Equal(t, "Backup", key.Name)
+	assert.Equal(t, []models.Scope{models.ScopeNotesRead, models.ScopeNotesWrite}, key.Scopes)
+	assert.True(t, strings.HasPrefix(key.Key, key.Prefix))
+	assert.Equal(t, auth.HashToken(key.Key), stored.String(2))
+}
+
+func TestUserHandler_APIKeyScopes(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	user := &models.User{Id: testUserId, Email: "ada@example.com"}
+	key := &models.APIKey{Id: 3, Scopes: []models.Scope{models.ScopeNotesRead}}
+	userRepoMock.On("GetAPIKeyUser", auth.HashToken("nk_reader")).Return(user, key, nil)
+	userRepoMock.On("GetAPIKeyUser", auth.HashToken("nk_revoked")).
+		Return((*models.User)(nil), (*models.APIKey)(nil), &repository.RepoError{Src: "GetAPIKeyUser", Err: repository.ErrAPIKeyNotFound})
+	userRepoMock.On("GetSessionUser", auth.HashToken("session")).Return(user, nil)
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	read := userHandler.Authenticate(RequireScope(models.ScopeNotesRead)(ok))
+	write := userHandler.Authenticate(RequireScope(models.ScopeNotesWrite)(ok))
+	sessionOnly := userHandler.Authenticate(RequireSession(ok))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		token   string
+		status  int
+	}{
+		{"key with scope", read, "nk_reader", http.StatusNoContent},
+		{"key without scope", write, "nk_reader", http.StatusForbidden},
+		{"revoked key", read, "nk_revoked", http.StatusUnauthorized},
+		{"session has all scopes", write, "session", http.StatusNoContent},
+		{"key on session endpoint", sessionOnly, "nk_reader", http.StatusForbidden},
+		{"session on session endpoint", sessionOnly, "session", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/notes", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+
+			// Act
+			tt.handler.ServeHTTP(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/notes", nil)
+	req.Header.Set("Authorization", "Bearer nk_reader")
+	rec := httptest.NewRecorder()
+	write.ServeHTTP(rec, req)
+	assert.Equal(t, `Bearer realm="notes-api", error="insufficient_scope", scope="notes:write"`, rec.Header().Get("WWW-Authenticate"))
+}
+
+func TestUserHandler_GetAndDeleteAPIKeys(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	userRepoMock.On("GetAPIKeys", testUserId).Return([]*models.APIKey{{Id: 3, Name: "Backup", Prefix: "nk_abcdefgh", Scopes: []models.Scope{models.ScopeNotesRead}}}, nil)
+	userRepoMock.On("DeleteAPIKey", testUserId, 3).Return(nil)
+	userRepoMock.On("DeleteAPIKey", testUserId, 4).Return(&repository.RepoError{Src: "DeleteAPIKeyByID", Id: 4, Err: repository.ErrAPIKeyNotFound})
+
+	withKeyId := func(id string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("keyId", id)
+		req := newRequest(http.MethodDelete, "/api/v1/auth/keys/"+id, nil)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	// Act
+	list := httptest.NewRecorder()
+	userHandler.GetAPIKeys(list, newRequest(http.MethodGet, "/api/v1/auth/keys", nil))
+	deleted := httptest.NewRecorder()
+	userHandler.DeleteAPIKey(deleted, withKeyId("3"))
+	missing := httptest.NewRecorder()
+	userHandler.DeleteAPIKey(missing, withKeyId("4"))
+	invalid := httptest.NewRecorder()
+	userHandler.DeleteAPIKey(invalid, withKeyId("abc"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, list.Code)
+	var res struct{ Data []map[string]interface{} }
+	assert.NoError(t, json.Unmarshal(list.Body.Bytes(), &res))
+	assert.Equal(t, "nk_abcdefgh", res.Data[0]["prefix"])
+	assert.NotContains(t, res.Data[0], "key")
+	assert.Equal(t, http.StatusNoContent, deleted.Code)
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	userRepoMock.AssertExpectations(t)
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/JannisK89/notes-api/internal/websocket"
+)
+
+// userKey and apiKeyKey are the context keys under which Authenticate
+// stores the user of a request and the API key it was made with.
+type (
+	userKey   struct{}
+	apiKeyKey struct{}
+)
+
+// contextWithUser returns a copy of ctx that carries user.
+func contextWithUser(ctx context.Context, user *models.User) context.Context {
+	return context.WithValue(ctx, userKey{}, user)
+}
+
+// contextWithAPIKey returns a copy of ctx that carries the API key a request
+// was made with.
+func contextWithAPIKey(ctx context.Context, key *models.APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// getAPIKey returns the API key the request was authenticated with, or nil
+// if it was authenticated with a session token.
+func getAPIKey(r *http.Request) *models.APIKey {
+	key, _ := r.Context().Value(apiKeyKey{}).(*models.APIKey)
+	return key
+}
+
+// getUser returns the user Authenticate stored in the request context, or
+// nil if there is none.
+func getUser(r *http.Request) *models.User {
+	user, _ := r.Context().Value(userKey{}).(*models.User)
+	return user
+}
+
+// getUserId returns the id of the authenticated user of the request, or 0 if
+// there is none, which matches no notes.
+func getUserId(r *http.Request) int {
+	if user := getUser(r); user != nil {
+		return user.Id
+	}
+	return 0
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>"
+// header. It returns an empty string if there is no such header.
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// ticket returns the ticket in the ticket query parameter of a request that
+// may be authenticated with one, or an empty string. Only WebSocket
+// handshakes and event streams may, as browsers cannot add an Authorization
+// header to them.
+func ticket(r *http.Request) string {
+	if r.Method != http.MethodGet {
+		return ""
+	}
+	if !websocket.IsUpgrade(r) && !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		return ""
+	}
+	return r.URL.Query().Get("ticket")
+}
+
+// unauthorized responds with a 401 error that asks for a bearer token.
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="notes-api"`)
+	utils.ErrorResponse(w, http.StatusUnauthorized, service.ErrUnauthenticated.Error())
+}
+
+// credentials is the request body of the register and login endpoints.
+type credentials struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// UserHandler handles HTTP requests related to users. It provides methods
+// for registering, logging in and out and a middleware that authenticates
+// requests.
+type UserHandler struct {
+	userService service.UserService
+}
+
+// NewUserHandler creates a new UserHandler
+func NewUserHandler(userService service.UserService) *UserHandler {
+	return &UserHandler{userService}
+}
+
+// Authenticate is a middleware that only lets requests with a valid session
+// token or API key in their Authorization header through and stores their
+// user and API key in the request context. WebSocket handshakes and event
+// streams without the header can be authenticated with a ticket instead.
+// It returns a 401 error if the token is missing, unknown or expired.
+func (h UserHandler) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := bearerToken(r)
+		var user *models.User
+		var key *models.APIKey
+		var err error
+		if t := ticket(r); token == "" && t != "" {
+			user, err = h.userService.AuthenticateTicket(t)
+		} else if auth.IsAPIKey(token) {
+			user, key, err = h.userService.AuthenticateAPIKey(token)
+		} else {
+			user, err = h.userService.Authenticate(token)
+		}
+		if err != nil {
+			if errors.Is(err, service.ErrUnauthenticated) {
+				unauthorized(w)
+				return
+			}
+			log.Println(err)
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+			return
+		}
+		ctx := contextWithUser(r.Context(), user)
+		if key != nil {
+			ctx = contextWithAPIKey(ctx, key)
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Register creates a user with the email and password in the request body
+// and responds with the created user.
+// It returns a 400 error if the email or password is invalid and a 409
+// error if there already is a user with the email.
+func (h UserHandler) Register(w http.ResponseWriter, r *http.Request) {
+	var body credentials
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	user, err := h.userService.Register(body.Email, body.Password)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidEmail) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidEmail.Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidPassword) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidPassword.Error())
+			return
+		} else if errors.Is(err, repository.ErrUserExists) {
+			utils.ErrorResponse(w, http.StatusConflict, repository.ErrUserExists.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: user})
+}
+
+// Login checks the email and password in the request body and responds with
+// a session token and its expiry.
+// It returns a 401 error if the email is unknown or the password is wrong.
+func (h UserHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var body credentials
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	session, err := h.userService.Login(body.Email, body.Password)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidCredentials) {
+			utils.ErrorResponse(w, http.StatusUnauthorized, service.ErrInvalidCredentials.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: session})
+}
+
+// Logout ends the session of the token in the Authorization header, so it
+// can no longer be used.
+// It returns a 401 error if the token is missing or unknown.
+func (h UserHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	if err := h.userService.Logout(bearerToken(r)); err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrUnauthenticated) {
+			unauthorized(w)
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// CreateTicket responds with a ticket for the user, which authenticates one
+// WebSocket handshake or event stream in place of the Authorization header.
+func (h UserHandler) CreateTicket(w http.ResponseWriter, r *http.Request) {
+	ticket, err := h.userService.CreateTicket(getUserId(r))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: ticket})
+}
+
+// Me responds with the authenticated user.
+func (h UserHandler) Me(w http.ResponseWriter, r *http.Request) {
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: getUser(r)})
+}
@@ -0,0 +1,289 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// testUserId is the id of the user requests made with newRequest are
+// authenticated as and testWorkspaceId the workspace they act in.
+const (
+	testUserId      = 7
+	testWorkspaceId = 3
+)
+
+// testParams keep password hashing fast in tests.
+var testParams = auth.Params{Memory: 64, Time: 1, Threads: 1, SaltLength: 16, KeyLength: 32}
+
+// newRequest returns a request authenticated as the user with testUserId,
+// who owns the workspace with testWorkspaceId.
+func newRequest(method string, target string, body io.Reader) *http.Request {
+	return withRole(httptest.NewRequest(method, target, body), models.RoleOwner)
+}
+
+// withRole returns a request authenticated as the user with testUserId, who
+// has role in the workspace with testWorkspaceId.
+func withRole(req *http.Request, role models.Role) *http.Request {
+	ctx := contextWithUser(req.Context(), &models.User{Id: testUserId, Email: "ada@example.com"})
+	return req.WithContext(contextWithMember(ctx, &models.Member{WorkspaceId: testWorkspaceId, UserId: testUserId, Role: role}))
+}
+
+// newUserHandler returns a UserHandler backed by userRepoMock.
+func newUserHandler(userRepoMock *mocks.UserRepoMock) *UserHandler {
+	userService := service.NewUserService(userRepoMock)
+	userService.Params = testParams
+	return NewUserHandler(userService)
+}
+
+func TestUserHandler_Register(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	isUser := func(email string) interface{} {
+		return mock.MatchedBy(func(user *models.User) bool { return user.Email == email })
+	}
+	userRepoMock.On("Create", isUser("ada@example.com")).Run(func(args mock.Arguments) {
+		args.Get(0).(*models.User).Id = 1
+	}).Return(1, nil)
+	userRepoMock.On("Create", isUser("taken@example.com")).Return(0, &repository.RepoError{Src: "CreateUser", Err: repository.ErrUserExists})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"email": " ada@example.com ", "password": "correct horse"}`, http.StatusCreated},
+		{"exists", `{"email": "taken@example.com", "password": "correct horse"}`, http.StatusConflict},
+		{"invalid email", `{"email": "Ada <ada@example.com>", "password": "correct horse"}`, http.StatusBadRequest},
+		{"short password", `{"email": "ada@example.com", "password": "short"}`, http.StatusBadRequest},
+		{"invalid body", `{"email":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/auth/register", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			// Act
+			userHandler.Register(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.NotContains(t, rec.Body.String(), "argon2id")
+		})
+	}
+	userRepoMock.AssertNumberOfCalls(t, "Create", 2)
+}
+
+func TestUserHandler_Login(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	hash, err := auth.HashPassword("correct horse", testParams)
+	assert.NoError(t, err)
+	userRepoMock.On("GetByEmail", "ada@example.com").Return(&models.User{Id: 1, Email: "ada@example.com", PasswordHash: hash}, nil)
+	userRepoMock.On("GetByEmail", "nobody@example.com").Return((*models.User)(nil), &repository.RepoError{Src: "GetUserByEmail", Err: repository.ErrUserNotFound})
+	userRepoMock.On("CreateSession", 1, mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil).Once()
+
+	login := func(body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		userHandler.Login(rec, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(body)))
+		return rec
+	}
+
+	// Act
+	valid := login(`{"email": "ada@example.com", "password": "correct horse"}`)
+	wrong := login(`{"email": "ada@example.com", "password": "wrong horse"}`)
+	unknown := login(`{"email": "nobody@example.com", "password": "correct horse"}`)
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, valid.Code, valid.Body.String())
+	var res struct{ Data models.Session }
+	assert.NoError(t, json.Unmarshal(valid.Body.Bytes(), &res))
+	assert.Len(t, res.Data.Token, 43)
+	assert.WithinDuration(t, time.Now().Add(service.DefaultSessionTTL), res.Data.ExpiresAt, time.Minute)
+	tokenHash := userRepoMock.Calls[1].Arguments.String(1)
+	assert.Equal(t, auth.HashToken(res.Data.Token), tokenHash)
+
+	assert.Equal(t, http.StatusUnauthorized, wrong.Code)
+	assert.Equal(t, http.StatusUnauthorized, unknown.Code)
+	assert.Equal(t, wrong.Body.String(), unknown.Body.String())
+	userRepoMock.AssertExpectations(t)
+}
+
+func TestUserHandler_Authenticate(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	userRepoMock.On("GetSessionUser", auth.HashToken("valid")).Return(&models.User{Id: testUserId, Email: "ada@example.com"}, nil)
+	userRepoMock.On("GetSessionUser", auth.HashToken("expired")).Return((*models.User)(nil), &repository.RepoError{Src: "GetSessionUser", Err: repository.ErrSessionNotFound})
+
+	var seen int
+	protected := userHandler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = getUserId(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		userId int
+	}{
+		{"valid", "Bearer valid", http.StatusNoContent, testUserId},
+		{"lowercase scheme", "bearer valid", http.StatusNoContent, testUserId},
+		{"expired", "Bearer expired", http.StatusUnauthorized, 0},
+		{"missing", "", http.StatusUnauthorized, 0},
+		{"basic", "Basic dXNlcjpwYXNz", http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = 0
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/notes", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			protected.ServeHTTP(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.userId, seen)
+			if tt.status == http.StatusUnauthorized {
+				assert.Contains(t, rec.Header().Get("WWW-Authenticate"), "Bearer")
+			}
+		})
+	}
+}
+
+func TestUserHandler_Logout(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	userRepoMock.On("DeleteSession", auth.HashToken("valid")).Return(nil).Once()
+	userRepoMock.On("DeleteSession", auth.HashToken("valid")).Return(&repository.RepoError{Src: "DeleteSession", Err: repository.ErrSessionNotFound}).Once()
+
+	logout := func() *httptest.ResponseRecorder {
+		req := newRequest(http.MethodPost, "/api/v1/auth/logout", nil)
+		req.Header.Set("Authorization", "Bearer valid")
+		rec := httptest.NewRecorder()
+		userHandler.Logout(rec, req)
+		return rec
+	}
+
+	// Act
+	first := logout()
+	second := logout()
+
+	// Assertion
+	assert.Equal(t, http.StatusNoContent, first.Code)
+	assert.Equal(t, http.StatusUnauthorized, second.Code)
+	userRepoMock.AssertExpectations(t)
+}
+
+func TestUserHandler_CreateTicket(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	var hash string
+	var expiresAt time.Time
+	userRepoMock.On("CreateSession", testUserId, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		hash, expiresAt = args.String(1), args.Get(2).(time.Time)
+	}).Return(nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	userHandler.CreateTicket(rec, newRequest(http.MethodPost, "/api/v1/auth/tickets", nil))
+
+	// Assertion
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	var body struct {
+		Data models.Ticket `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.True(t, auth.IsTicket(body.Data.Ticket))
+	assert.Equal(t, auth.HashToken(body.Data.Ticket), hash)
+	assert.True(t, body.Data.ExpiresAt.Equal(expiresAt))
+	assert.WithinDuration(t, time.Now().Add(service.DefaultTicketTTL), expiresAt, time.Minute)
+}
+
+func TestUserHandler_AuthenticateTicket(t *testing.T) {
+	// Arrange
+	userRepoMock := &mocks.UserRepoMock{}
+	userHandler := newUserHandler(userRepoMock)
+
+	ticket := auth.TicketPrefix + "valid"
+	userRepoMock.On("GetSessionUser", auth.HashToken(ticket)).Return(&models.User{Id: testUserId, Email: "ada@example.com"}, nil)
+	// The ticket is removed when it is used, so it works once.
+	userRepoMock.On("DeleteSession", auth.HashToken(ticket)).Return(nil).Once()
+	userRepoMock.On("DeleteSession", auth.HashToken(ticket)).Return(&repository.RepoError{Src: "DeleteSession", Err: repository.ErrSessionNotFound})
+	streamTicket := auth.TicketPrefix + "stream"
+	userRepoMock.On("GetSessionUser", auth.HashToken(streamTicket)).Return(&models.User{Id: testUserId, Email: "ada@example.com"}, nil)
+	userRepoMock.On("DeleteSession", auth.HashToken(streamTicket)).Return(nil).Once()
+
+	var seen int
+	protected := userHandler.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = getUserId(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name    string
+		ticket  string
+		upgrade bool
+		accept  string
+		header  string
+		status  int
+		userId  int
+	}{
+		{"handshake", ticket, true, "", "", http.StatusNoContent, testUserId},
+		{"used", ticket, true, "", "", http.StatusUnauthorized, 0},
+		{"event stream", streamTicket, false, "text/event-stream", "", http.StatusNoContent, testUserId},
+		{"other request", ticket, false, "", "", http.StatusUnauthorized, 0},
+		{"as bearer token", ticket, false, "", "Bearer " + ticket, http.StatusUnauthorized, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			seen = 0
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/notes/1/collab?ticket="+tt.ticket, nil)
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+			}
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			protected.ServeHTTP(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code)
+			assert.Equal(t, tt.userId, seen)
+		})
+	}
+	userRepoMock.AssertNumberOfCalls(t, "DeleteSession", 3)
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/JannisK89/notes-api/internal/websocket"
+)
+
+// ErrUpgradeRequired is returned when a collaborative editing session is
+// requested without upgrading to a WebSocket
+var ErrUpgradeRequired = errors.New("request must upgrade to a websocket")
+
+// ErrOriginNotAllowed is returned when a browser opens a collaborative
+// editing session from a page of another origin that is not allowed
+var ErrOriginNotAllowed = errors.New("origin is not allowed")
+
+const (
+	// DefaultPingInterval is how often an editing session pings the client
+	// when no interval is configured. A client that does not answer within
+	// two intervals is disconnected.
+	DefaultPingInterval = 30 * time.Second
+	// collabWriteTimeout is how long sending a message to an editor may
+	// take.
+	collabWriteTimeout = 10 * time.Second
+)
+
+// CollabHandler lets the editors of a note edit it together over a
+// WebSocket.
+type CollabHandler struct {
+	collab service.CollabService
+	// PingInterval is how often the client is pinged.
+	PingInterval time.Duration
+	// AllowedOrigins are the origins, such as https://app.example.com, of
+	// pages other than those of the API itself that may open a session.
+	AllowedOrigins []string
+}
+
+// NewCollabHandler creates a new CollabHandler with the default ping
+// interval
+func NewCollabHandler(collab service.CollabService) *CollabHandler {
+	return &CollabHandler{collab: collab, PingInterval: DefaultPingInterval}
+}
+
+// Edit upgrades the request to a WebSocket and joins the editing session of
+// a note. The client first receives a snapshot of the text and then the
+// changes, cursors and saves of the other editors, and sends its own as
+// JSON messages. Viewers of the note and API keys without the notes:write
+// scope can only follow along.
+// It returns a 400 error if the id is invalid, a 403 error if the user cannot
+// view the note or the request comes from a page of an origin that is not
+// allowed, a 404 error if the note is not found, a 426 error if the
+// request does not upgrade to a WebSocket and a 503 error if the server is
+// shutting down.
+func (h CollabHandler) Edit(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, ErrInvalidId.Error())
+		return
+	}
+	if !websocket.IsUpgrade(r) || r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		utils.ErrorResponse(w, http.StatusUpgradeRequired, ErrUpgradeRequired.Error())
+		return
+	}
+	if !h.allowOrigin(r) {
+		utils.ErrorResponse(w, http.StatusForbidden, ErrOriginNotAllowed.Error())
+		return
+	}
+
+	key := getAPIKey(r)
+	readOnly := key != nil && !key.HasScope(models.ScopeNotesWrite)
+	c, err := h.collab.Join(r.Context(), getMember(r), noteid, readOnly)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, repository.ErrNoteNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		} else if errors.Is(err, service.ErrInvalidId) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+		} else if errors.Is(err, service.ErrCollabClosed) {
+			utils.ErrorResponse(w, http.StatusServiceUnavailable, service.ErrCollabClosed.Error())
+		} else {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Println(err)
+		c.Leave()
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer conn.Close()
+	conn.ReadTimeout = 2 * h.PingInterval
+	conn.WriteTimeout = collabWriteTimeout
+
+	written := make(chan struct{})
+	go h.write(conn, c, written)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		msg := &models.CollabMessage{}
+		if err := json.Unmarshal(data, msg); err != nil {
+			err = fmt.Errorf("%w: %v", service.ErrInvalidCollabMessage, err)
+			closeCollab(conn, websocket.ClosePolicyViolation, err.Error())
+			break
+		}
+		if err := c.Apply(msg); err != nil {
+			log.Println(err)
+			if errors.Is(err, service.ErrCollabClosed) {
+				// The writer tells the client why it was removed.
+				break
+			}
+			closeCollab(conn, websocket.ClosePolicyViolation, errors.Unwrap(err).Error())
+			break
+		}
+	}
+	c.Leave()
+	<-written
+}
+
+// allowOrigin reports whether the Origin header of r, which browsers send
+// with every WebSocket handshake, is that of the API itself or one of
+// AllowedOrigins. Other clients may leave it out.
+func (h CollabHandler) allowOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range h.AllowedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// write sends the messages of the session to the client and pings it until
+// the collaborator leaves or is removed, which it tells the client before
+// closing the connection. It closes written when it is done.
+func (h CollabHandler) write(conn *websocket.Conn, c *service.Collaborator, written chan<- struct{}) {
+	defer close(written)
+	ping := time.NewTicker(h.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case msg, ok := <-c.Messages():
+			if !ok {
+				err := c.Err()
+				switch {
+				case err == nil:
+					// The collaborator left after the connection ended.
+				case errors.Is(err, service.ErrCollabClosed):
+					closeCollab(conn, websocket.CloseGoingAway, err.Error())
+				case errors.Is(err, repository.ErrNoteNotFound):
+					closeCollab(conn, websocket.CloseNormal, repository.ErrNoteNotFound.Error())
+				default:
+					closeCollab(conn, websocket.ClosePolicyViolation, err.Error())
+				}
+				return
+			}
+			if err := conn.WriteJSON(msg); err != nil {
+				conn.Close()
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}
+}
+
+// closeCollab sends an error message to the client and starts the closing
+// handshake with code.
+func closeCollab(conn *websocket.Conn, code int, reason string) {
+	conn.WriteJSON(&models.CollabMessage{Type: models.CollabError, Message: reason})
+	conn.WriteClose(code, reason)
+}
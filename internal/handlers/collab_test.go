@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/crdt"
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// collabClient is the client side of an editing session.
+type collabClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialCollab opens an editing session of note 1 on server as a member with
+// role.
+func dialCollab(t *testing.T, server *httptest.Server, role models.Role) *collabClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/api/v1/notes/1/collab", nil)
+	require.NoError(t, err)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("X-Test-Role", string(role))
+	require.NoError(t, req.Write(conn))
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, res.StatusCode)
+	return &collabClient{t, conn, r}
+}
+
+// send writes a masked frame with opcode and payload.
+func (c *collabClient) send(opcode byte, payload []byte) {
+	c.t.Helper()
+	frame := []byte{0x80 | opcode}
+	if len(payload) <= 125 {
+		frame = append(frame, 0x80|byte(len(payload)))
+	} else {
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(len(payload)))
+	}
+	frame = append(frame, 0, 0, 0, 0)
+	frame = append(frame, payload...)
+	_, err := c.conn.Write(frame)
+	require.NoError(c.t, err)
+}
+
+// sendJSON sends msg as a text message.
+func (c *collabClient) sendJSON(msg string) {
+	c.send(0x1, []byte(msg))
+}
+
+// receive reads a frame and returns its opcode and payload.
+func (c *collabClient) receive() (int, []byte) {
+	c.t.Helper()
+	var header [2]byte
+	_, err := io.ReadFull(c.r, header[:])
+	require.NoError(c.t, err)
+	size := int(header[1] & 0x7f)
+	if size == 126 {
+		var ext [2]byte
+		_, err := io.ReadFull(c.r, ext[:])
+		require.NoError(c.t, err)
+		size = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, size)
+	_, err = io.ReadFull(c.r, payload)
+	require.NoError(c.t, err)
+	return int(header[0] & 0x0f), payload
+}
+
+// receiveJSON reads a text message and decodes it.
+func (c *collabClient) receiveJSON() *models.CollabMessage {
+	c.t.Helper()
+	opcode, payload := c.receive()
+	require.Equal(c.t, 0x1, opcode, string(payload))
+	msg := &models.CollabMessage{}
+	require.NoError(c.t, json.Unmarshal(payload, msg))
+	return msg
+}
+
+// receiveClose reads a close frame and returns its code.
+func (c *collabClient) receiveClose() int {
+	c.t.Helper()
+	opcode, payload := c.receive()
+	require.Equal(c.t, 0x8, opcode, string(payload))
+	return int(binary.BigEndian.Uint16(payload))
+}
+
+// newCollabServer serves the editing session of note 1 from hub, to members
+// with the role in the X-Test-Role header.
+func newCollabServer(t *testing.T, hub *service.CollabHub) *httptest.Server {
+	collabHandler := NewCollabHandler(hub)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		collabHandler.Edit(w, withNoteId(withRole(r, models.Role(r.Header.Get("X-Test-Role"))), "1"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestCollabHandler_Edit(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Plan", Content: "hi", Version: 2}, nil).Once()
+	saved := make(chan *models.Note, 1)
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, mock.Anything, 2).Run(func(args mock.Arguments) {
+		note := args.Get(3).(*models.Note)
+		note.Version = 3
+		saved <- note
+	}).Return(nil).Once()
+	hub := service.NewCollabHub(noteRepoMock)
+	server := newCollabServer(t, hub)
+
+	// Act
+	alice := dialCollab(t, server, models.RoleOwner)
+	aliceSnapshot := alice.receiveJSON()
+	bob := dialCollab(t, server, models.RoleEditor)
+	bobSnapshot := bob.receiveJSON()
+	bobJoined := alice.receiveJSON()
+	alice.sendJSON(`{"type": "insert", "id": {"site": 1, "clock": 3}, "after": {"site": 0, "clock": 2}, "text": "!"}`)
+	inserted := bob.receiveJSON()
+	bob.sendJSON(`{"type": "presence", "cursor": {"site": 1, "clock": 3}}`)
+	moved := alice.receiveJSON()
+	bob.sendJSON(`{"type": "delete", "ids": [{"site": 0, "clock": 1}]}`)
+	deleted := alice.receiveJSON()
+	alice.sendJSON(`{"type": "insert", "id": {"site": 2, "clock": 4}, "text": "?"}`)
+	aliceError := alice.receiveJSON()
+	aliceClose := alice.receiveClose()
+	aliceLeft := bob.receiveJSON()
+	bob.send(0x8, binary.BigEndian.AppendUint16(nil, 1000))
+	bobClose := bob.receiveClose()
+	hub.Close()
+
+	// Assertion
+	assert.Equal(t, &models.CollabMessage{
+		Type: models.CollabSnapshot, Site: 1, UserId: testUserId, Title: "Plan", Version: 2, Clock: 2,
+		Elements: []crdt.Element{{Id: crdt.Id{Clock: 1}, Text: "h"}, {Id: crdt.Id{Clock: 2}, Text: "i"}},
+	}, aliceSnapshot)
+	assert.Equal(t, 2, bobSnapshot.Site)
+	assert.Equal(t, []*models.CollabEditor{{Site: 1, UserId: testUserId}}, bobSnapshot.Editors)
+	assert.Equal(t, &models.CollabMessage{Type: models.CollabPresence, Site: 2, UserId: testUserId}, bobJoined)
+	assert.Equal(t, &models.CollabMessage{
+		Type: models.CollabInsert, Site: 1, UserId: testUserId, Id: &crdt.Id{Site: 1, Clock: 3}, After: &crdt.Id{Clock: 2}, Text: "!",
+	}, inserted)
+	assert.Equal(t, &models.CollabMessage{Type: models.CollabPresence, Site: 2, UserId: testUserId, Cursor: &crdt.Id{Site: 1, Clock: 3}}, moved)
+	assert.Equal(t, &models.CollabMessage{Type: models.CollabDelete, Site: 2, UserId: testUserId, Ids: []crdt.Id{{Clock: 1}}}, deleted)
+	assert.Equal(t, models.CollabError, aliceError.Type)
+	assert.Contains(t, aliceError.Message, "insert must have an id of site 1")
+	assert.Equal(t, 1008, aliceClose)
+	assert.Equal(t, &models.CollabMessage{Type: models.CollabLeave, Site: 1, UserId: testUserId}, aliceLeft)
+	assert.Equal(t, 1000, bobClose)
+	note := <-saved
+	assert.Equal(t, "Plan", note.Title)
+	assert.Equal(t, "i!", note.Content)
+	assert.Nil(t, note.Tags)
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestCollabHandler_EditReadOnly(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.Role(""), nil)
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Plan", Content: "hi", Version: 2}, nil)
+	hub := service.NewCollabHub(noteRepoMock)
+	server := newCollabServer(t, hub)
+
+	// Act
+	viewer := dialCollab(t, server, models.RoleViewer)
+	snapshot := viewer.receiveJSON()
+	viewer.sendJSON(`{"type": "presence", "cursor": {"site": 0, "clock": 1}}`)
+	viewer.sendJSON(`{"type": "delete", "ids": [{"site": 0, "clock": 1}]}`)
+	viewerError := viewer.receiveJSON()
+	viewerClose := viewer.receiveClose()
+	hub.Close()
+
+	// Assertion
+	assert.True(t, snapshot.ReadOnly)
+	assert.Equal(t, "insufficient permission: editor role required", viewerError.Message)
+	assert.Equal(t, 1008, viewerClose)
+	noteRepoMock.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCollabHandler_EditErrors(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.RoleOwner, nil)
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 5).
+		Return(models.Role(""), &repository.RepoError{Src: "GetNoteAccess", Id: 5, Err: repository.ErrNoteNotFound})
+	hub := service.NewCollabHub(noteRepoMock)
+	closedHub := service.NewCollabHub(noteRepoMock)
+	closedHub.Close()
+
+	tests := []struct {
+		name    string
+		hub     *service.CollabHub
+		id      string
+		upgrade bool
+		origin  string
+		status  int
+	}{
+		{"no upgrade", hub, "1", false, "", http.StatusUpgradeRequired},
+		{"invalid id", hub, "abc", true, "", http.StatusBadRequest},
+		{"zero id", hub, "0", true, "", http.StatusBadRequest},
+		{"not found", hub, "5", true, "", http.StatusNotFound},
+		{"shutting down", closedHub, "1", true, "", http.StatusServiceUnavailable},
+		{"other origin", hub, "1", true, "https://evil.example.com", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withNoteId(newRequest(http.MethodGet, "/api/v1/notes/"+tt.id+"/collab", nil), tt.id)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.upgrade {
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Sec-WebSocket-Version", "13")
+				req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			NewCollabHandler(tt.hub).Edit(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNotCalled(t, "Get", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestCollabHandler_EditOrigin(t *testing.T) {
+	collabHandler := NewCollabHandler(nil)
+	collabHandler.AllowedOrigins = []string{"https://app.example.com/"}
+
+	tests := []struct {
+		name    string
+		origin  string
+		allowed bool
+	}{
+		{"no origin", "", true},
+		{"same origin", "http://example.com", true},
+		{"allowed origin", "https://app.example.com", true},
+		{"other origin", "https://evil.example.com", false},
+		{"null origin", "null", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			req := httptest.NewRequest(http.MethodGet, "http://example.com/api/v1/notes/1/collab", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			// Act
+			allowed := collabHandler.allowOrigin(req)
+
+			// Assertion
+			assert.Equal(t, tt.allowed, allowed)
+		})
+	}
+}
+
+func TestCollabHandler_EditKeepsContent(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Plan", Content: "hi", Version: 2}, nil)
+	saved := make(chan *models.Note, 1)
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, mock.Anything, 2).Run(func(args mock.Arguments) {
+		saved <- args.Get(3).(*models.Note)
+	}).Return(nil).Once()
+	hub := service.NewCollabHub(noteRepoMock)
+	server := newCollabServer(t, hub)
+
+	// Act
+	editor := dialCollab(t, server, models.RoleOwner)
+	editor.receiveJSON()
+	// The text is replaced by inserting the new text first.
+	editor.sendJSON(`{"type": "insert", "id": {"site": 1, "clock": 3}, "after": {"site": 0, "clock": 2}, "text": "!"}`)
+	editor.sendJSON(`{"type": "delete", "ids": [{"site": 0, "clock": 1}, {"site": 0, "clock": 2}]}`)
+	editor.sendJSON(`{"type": "delete", "ids": [{"site": 1, "clock": 3}]}`)
+	editorError := editor.receiveJSON()
+	editorClose := editor.receiveClose()
+	hub.Close()
+
+	// Assertion
+	assert.Contains(t, editorError.Message, "delete would leave the note without content")
+	assert.Equal(t, 1008, editorClose)
+	assert.Equal(t, "!", (<-saved).Content)
+	noteRepoMock.AssertExpectations(t)
+}
+
+// memberLookup is a service.MemberLookup that finds the user with role in
+// every workspace, or no membership if role is empty.
+type memberLookup struct {
+	role models.Role
+}
+
+func (l memberLookup) Member(userId int, workspaceId int) (*models.Member, error) {
+	if l.role == "" {
+		return nil, &repository.RepoError{Src: "GetWorkspaceMember", Id: workspaceId, Err: repository.ErrWorkspaceNotFound}
+	}
+	return &models.Member{WorkspaceId: workspaceId, UserId: userId, Role: l.role}, nil
+}
+
+func TestCollabHandler_EditAccessChanged(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    models.Role
+		members service.MemberLookup
+	}{
+		{"share revoked", "", nil},
+		{"member removed", models.RoleOwner, memberLookup{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			noteRepoMock := &mocks.NoteRepoMock{}
+			// The note is shared with the user until they are checked again.
+			noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.RoleEditor, nil).Twice()
+			noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.Role(""), nil)
+			noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Plan", Content: "hi", Version: 2}, nil)
+			hub := service.NewCollabHub(noteRepoMock)
+			hub.AccessInterval = 10 * time.Millisecond
+			hub.Members = tt.members
+			server := newCollabServer(t, hub)
+
+			// Act
+			editor := dialCollab(t, server, tt.role)
+			snapshot := editor.receiveJSON()
+			editorError := editor.receiveJSON()
+			editorClose := editor.receiveClose()
+			hub.Close()
+
+			// Assertion
+			assert.False(t, snapshot.ReadOnly)
+			assert.Equal(t, service.ErrCollabAccessChanged.Error(), editorError.Message)
+			assert.Equal(t, 1008, editorClose)
+		})
+	}
+}
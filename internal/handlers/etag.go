@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+)
+
+// ErrPreconditionFailed is returned when the If-Match header of a request
+// does not match the current version of a note
+var ErrPreconditionFailed = errors.New("note has been modified")
+
+// etag returns the entity tag of a note at the given version
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseETags parses a comma-separated list of entity tags into the note
+// versions they stand for. Weak tags are only accepted if weak is true. Tags
+// that are not note versions are skipped. wildcard reports whether the list is
+// "*".
+func parseETags(header string, weak bool) (versions []int, wildcard bool) {
+	if strings.TrimSpace(header) == "*" {
+		return nil, true
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = tag[2:]
+		}
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.Atoi(tag[1 : len(tag)-1])
+		if err != nil || version < 1 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+	return versions, false
+}
+
+// getIfMatch returns the note version a write must be conditional on
+// according to the If-Match header, or 0 if the header is absent.
+// As a conditional write can only be made against one version, "*" and a list
+// of several tags are resolved against the current version of the note, so
+// the write fails if the note is deleted or changed in between.
+// It returns ErrPreconditionFailed if none of the tags can match, including
+// when the note does not exist, which "*" requires to (RFC 9110, 13.1.1).
+func (h NoteHandler) getIfMatch(ctx context.Context, r *http.Request, noteid int) (int, error) {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return 0, nil
+	}
+	versions, wildcard := parseETags(header, false)
+	if !wildcard {
+		switch len(versions) {
+		case 0:
+			return 0, ErrPreconditionFailed
+		case 1:
+			return versions[0], nil
+		}
+	}
+
+	note, err := h.noteService.Get(ctx, getMember(r), noteid)
+	if errors.Is(err, repository.ErrNoteNotFound) {
+		return 0, fmt.Errorf("%w: %v", ErrPreconditionFailed, err)
+	} else if err != nil {
+		return 0, err
+	}
+	if wildcard {
+		return note.Version, nil
+	}
+	for _, version := range versions {
+		if version == note.Version {
+			return version, nil
+		}
+	}
+	return 0, ErrPreconditionFailed
+}
+
+// noneMatch reports whether the If-None-Match header of the request matches
+// the given note version, in which case the client's copy is up to date.
+func noneMatch(r *http.Request, version int) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	versions, wildcard := parseETags(header, true)
+	if wildcard {
+		return true
+	}
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConditionError responds to a failed If-Match precondition with a 412
+// error carrying the current ETag of the note, if known. It reports whether
+// err was such a failure.
+func writeConditionError(w http.ResponseWriter, err error) bool {
+	var conflict *service.ConflictError
+	if errors.As(err, &conflict) {
+		if conflict.Current > 0 {
+			w.Header().Set("ETag", etag(conflict.Current))
+		}
+		utils.ErrorResponse(w, http.StatusPreconditionFailed, ErrPreconditionFailed.Error())
+		return true
+	} else if errors.Is(err, ErrPreconditionFailed) {
+		utils.ErrorResponse(w, http.StatusPreconditionFailed, ErrPreconditionFailed.Error())
+		return true
+	}
+	return false
+}
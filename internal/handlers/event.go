@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/events"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+)
+
+const (
+	// DefaultHeartbeat is how often an idle event stream sends a comment to
+	// keep the connection open when no interval is configured.
+	DefaultHeartbeat = 15 * time.Second
+	// DefaultEventAccessInterval is how often the access of a client to the
+	// events it follows is checked again when no interval is configured.
+	DefaultEventAccessInterval = 30 * time.Second
+)
+
+// ErrAccessChanged is the reason an event stream ends when its client may no
+// longer follow the events of the workspace
+var ErrAccessChanged = errors.New("access to the workspace changed")
+
+// getLastEventId extracts the id of the last event a client received from
+// the Last-Event-ID header or, for clients that cannot set it, the
+// last_event_id query parameter. It is 0 if neither is set.
+// It returns an error if the id is not a non-negative integer
+func getLastEventId(r *http.Request) (int, error) {
+	lastid := r.Header.Get("Last-Event-ID")
+	if lastid == "" {
+		lastid = r.URL.Query().Get("last_event_id")
+	}
+	if lastid == "" {
+		return 0, nil
+	}
+	lastidAsInt, err := strconv.Atoi(lastid)
+	if err != nil || lastidAsInt < 0 {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidId, lastid)
+	}
+	return lastidAsInt, nil
+}
+
+// EventHandler streams the changes made to notes to clients as Server-Sent
+// Events.
+type EventHandler struct {
+	broker *events.Broker
+	// Heartbeat is how often an idle stream sends a comment.
+	Heartbeat time.Duration
+	// AccessInterval is how often the access of a client to the events it
+	// follows is checked again.
+	AccessInterval time.Duration
+	// Members looks up the current role of the user of a stream in its
+	// workspace, if it is set.
+	Members service.MemberLookup
+	// Users looks up the API key a stream was opened with, if it is set.
+	Users service.UserService
+}
+
+// NewEventHandler creates a new EventHandler with the default heartbeat and
+// access interval
+func NewEventHandler(broker *events.Broker) *EventHandler {
+	return &EventHandler{broker: broker, Heartbeat: DefaultHeartbeat, AccessInterval: DefaultEventAccessInterval}
+}
+
+// Stream sends the notes created, updated and deleted in the selected
+// workspace as Server-Sent Events until the client disconnects or the server
+// shuts down. A client that reconnects with the Last-Event-ID header first
+// receives the events it missed, or a reset event if they are no longer
+// available. The stream ends once the user is no longer a member of the
+// workspace or the API key it was opened with is revoked or expires.
+// It returns a 400 error if the last event id is invalid, a 403 error if
+// the user is a guest of the workspace and a 503 error if the server is
+// shutting down.
+func (h EventHandler) Stream(w http.ResponseWriter, r *http.Request) {
+	lastid, err := getLastEventId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !getMember(r).Role.Includes(models.RoleViewer) {
+		utils.ErrorResponse(w, http.StatusForbidden, fmt.Sprintf("%s: %s role required", service.ErrForbidden, models.RoleViewer))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		log.Println("event stream: response writer does not support flushing")
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	sub, missed, err := h.broker.Subscribe(getMember(r).WorkspaceId, lastid)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, e := range missed {
+		if err := writeEvent(w, e); err != nil {
+			log.Println(err)
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(h.Heartbeat)
+	defer heartbeat.Stop()
+	access := time.NewTicker(h.AccessInterval)
+	defer access.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			if err := writeEvent(w, e); err != nil {
+				log.Println(err)
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		case <-access.C:
+			err := h.checkAccess(r)
+			if errors.Is(err, ErrAccessChanged) {
+				return
+			} else if err != nil {
+				// The access is checked again with the next tick.
+				log.Println(err)
+			}
+			continue
+		}
+		flusher.Flush()
+	}
+}
+
+// checkAccess checks again that the client of a stream may follow the events
+// of its workspace: the user must still be at least a viewer of it and the
+// API key the stream was opened with, if any, must neither be revoked nor
+// expired.
+// It returns ErrAccessChanged if the client may no longer follow the events.
+func (h EventHandler) checkAccess(r *http.Request) error {
+	m := getMember(r)
+	if h.Members != nil {
+		current, err := h.Members.Member(m.UserId, m.WorkspaceId)
+		if errors.Is(err, repository.ErrWorkspaceNotFound) {
+			return ErrAccessChanged
+		} else if err != nil {
+			return err
+		}
+		if !current.Role.Includes(models.RoleViewer) {
+			return ErrAccessChanged
+		}
+	}
+	key := getAPIKey(r)
+	if key == nil || h.Users == nil {
+		return nil
+	}
+	keys, err := h.Users.GetAPIKeys(m.UserId)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.Id == key.Id {
+			if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+				return ErrAccessChanged
+			}
+			return nil
+		}
+	}
+	return ErrAccessChanged
+}
+
+// writeEvent writes e in the Server-Sent Events format.
+func writeEvent(w http.ResponseWriter, e models.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Id, e.Type, data)
+	return err
+}
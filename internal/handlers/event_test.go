@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/events"
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// openStream starts a server for eventHandler and connects to its stream as
+// a viewer of the workspace with testWorkspaceId, resuming after lastId if
+// it is not empty.
+func openStream(t *testing.T, eventHandler *EventHandler, lastId string) (*http.Response, *bufio.Scanner) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventHandler.Stream(w, withRole(r, models.RoleViewer))
+	}))
+	t.Cleanup(server.Close)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/api/v1/events", nil)
+	if lastId != "" {
+		req.Header.Set("Last-Event-ID", lastId)
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Error when opening the event stream: %s", err)
+	}
+	t.Cleanup(func() { res.Body.Close() })
+	return res, bufio.NewScanner(res.Body)
+}
+
+// readEvent returns the lines of the next message of an event stream.
+func readEvent(scanner *bufio.Scanner) []string {
+	lines := []string{}
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			return lines
+		}
+		lines = append(lines, scanner.Text())
+	}
+	return lines
+}
+
+// eventData decodes the data line of a message of an event stream.
+func eventData(t *testing.T, lines []string) models.Event {
+	t.Helper()
+	var e models.Event
+	if len(lines) != 3 || json.Unmarshal([]byte(strings.TrimPrefix(lines[2], "data: ")), &e) != nil {
+		t.Fatalf("Unexpected event %q", lines)
+	}
+	return e
+}
+
+func TestEventHandler_Stream(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	broker := events.NewBroker(10)
+	noteService := service.NewNoteService(noteRepoMock)
+	noteService.Events = broker
+	eventHandler := NewEventHandler(broker)
+	member := models.Member{WorkspaceId: testWorkspaceId, UserId: testUserId, Role: models.RoleOwner}
+
+	created := &models.Note{Title: "Test Note", Content: "I Am A Test Note"}
+	noteRepoMock.On("Create", mock.Anything, testWorkspaceId, testUserId, created).Return(1, nil)
+	noteRepoMock.On("Delete", mock.Anything, testWorkspaceId, 1, 0).Return(nil)
+	updated := &models.Note{Title: "Updated Note", Content: "I Am Updated"}
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 2, updated, 0).Return(nil)
+
+	_, err := noteService.Create(context.Background(), member, created)
+	assert.NoError(t, err)
+	broker.Publish(models.Event{Type: models.EventNoteCreated, WorkspaceId: 9, NoteId: 5})
+	assert.NoError(t, noteService.Delete(context.Background(), member, 1, 0))
+
+	// Act
+	res, scanner := openStream(t, eventHandler, "1")
+	missed := readEvent(scanner)
+	assert.NoError(t, noteService.Update(context.Background(), member, 2, updated, 0))
+	live := readEvent(scanner)
+	broker.Close()
+	more := scanner.Scan()
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+	assert.Equal(t, []string{"id: 3", "event: note.deleted"}, missed[:2])
+	deleted := eventData(t, missed)
+	assert.Equal(t, testUserId, deleted.UserId)
+	assert.Equal(t, 1, deleted.NoteId)
+	assert.Nil(t, deleted.Note)
+	assert.Equal(t, []string{"id: 4", "event: note.updated"}, live[:2])
+	assert.Equal(t, &models.Note{Id: 2, Title: "Updated Note", Content: "I Am Updated"}, eventData(t, live).Note)
+	assert.False(t, more)
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestEventHandler_StreamReset(t *testing.T) {
+	// Arrange
+	broker := events.NewBroker(1)
+	eventHandler := NewEventHandler(broker)
+	broker.Publish(models.Event{Type: models.EventNoteCreated, WorkspaceId: testWorkspaceId, NoteId: 1})
+	broker.Publish(models.Event{Type: models.EventNoteDeleted, WorkspaceId: testWorkspaceId, NoteId: 1})
+	broker.Publish(models.Event{Type: models.EventNoteCreated, WorkspaceId: testWorkspaceId, NoteId: 2})
+
+	// Act
+	_, scanner := openStream(t, eventHandler, "1")
+	reset := readEvent(scanner)
+
+	// Assertion
+	assert.Equal(t, []string{"id: 3", "event: reset"}, reset[:2])
+	broker.Close()
+}
+
+func TestEventHandler_StreamHeartbeat(t *testing.T) {
+	// Arrange
+	broker := events.NewBroker(10)
+	eventHandler := NewEventHandler(broker)
+	eventHandler.Heartbeat = 10 * time.Millisecond
+
+	// Act
+	_, scanner := openStream(t, eventHandler, "")
+	heartbeat := readEvent(scanner)
+
+	// Assertion
+	assert.Equal(t, []string{": heartbeat"}, heartbeat)
+	broker.Close()
+}
+
+func TestEventHandler_StreamAccessChanged(t *testing.T) {
+	expired := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name  string
+		role  models.Role
+		keys  []*models.APIKey
+		ended bool
+	}{
+		{"still allowed", models.RoleViewer, []*models.APIKey{{Id: 4}}, false},
+		{"member removed", "", []*models.APIKey{{Id: 4}}, true},
+		{"key revoked", models.RoleViewer, []*models.APIKey{{Id: 5}}, true},
+		{"key expired", models.RoleViewer, []*models.APIKey{{Id: 4, ExpiresAt: &expired}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			userRepoMock := &mocks.UserRepoMock{}
+			userRepoMock.On("GetAPIKeys", testUserId).Return(tt.keys, nil)
+			broker := events.NewBroker(10)
+			t.Cleanup(broker.Close)
+			eventHandler := NewEventHandler(broker)
+			eventHandler.Heartbeat = 50 * time.Millisecond
+			eventHandler.AccessInterval = 10 * time.Millisecond
+			eventHandler.Members = memberLookup{tt.role}
+			eventHandler.Users = service.NewUserService(userRepoMock)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				key := &models.APIKey{Id: 4, Scopes: []models.Scope{models.ScopeNotesRead}}
+				eventHandler.Stream(w, withRole(r.WithContext(contextWithAPIKey(r.Context(), key)), models.RoleViewer))
+			}))
+			t.Cleanup(server.Close)
+			res, err := http.Get(server.URL + "/api/v1/events")
+			if err != nil {
+				t.Fatalf("Error when opening the event stream: %s", err)
+			}
+			t.Cleanup(func() { res.Body.Close() })
+
+			// Act
+			message := readEvent(bufio.NewScanner(res.Body))
+
+			// Assertion
+			if tt.ended {
+				assert.Empty(t, message)
+			} else {
+				assert.Equal(t, []string{": heartbeat"}, message)
+			}
+		})
+	}
+}
+
+func TestEventHandler_StreamErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		lastId string
+		closed bool
+		want   int
+	}{
+		{"invalid last event id", "abc", false, http.StatusBadRequest},
+		{"negative last event id", "-1", false, http.StatusBadRequest},
+		{"shutting down", "", true, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			broker := events.NewBroker(10)
+			if tt.closed {
+				broker.Close()
+			}
+			eventHandler := NewEventHandler(broker)
+			req := newRequest(http.MethodGet, "/api/v1/events?last_event_id="+tt.lastId, nil)
+			rec := httptest.NewRecorder()
+
+			// Act
+			eventHandler.Stream(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.want, rec.Code)
+		})
+	}
+}
@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/JannisK89/notes-api/internal/auth"
 	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/patch"
 	"github.com/JannisK89/notes-api/internal/repository"
 	"github.com/JannisK89/notes-api/internal/service"
 	"github.com/JannisK89/notes-api/internal/utils"
@@ -18,6 +25,26 @@ import (
 // ErrInvalidId is returned when the id is not a valid integer
 var ErrInvalidId = errors.New("id must be a valid integer")
 
+// ErrInvalidLimit is returned when the limit query parameter is not a valid
+// integer
+var ErrInvalidLimit = errors.New("limit must be a valid integer")
+
+// ErrInvalidTime is returned when a time query parameter is not an RFC 3339
+// timestamp
+var ErrInvalidTime = errors.New("time must be an RFC 3339 timestamp")
+
+// ErrUnsupportedPatch is returned when a patch has a content type other than
+// JSON Merge Patch or JSON Patch
+var ErrUnsupportedPatch = errors.New("patch must be application/merge-patch+json or application/json-patch+json")
+
+// ErrInvalidOffset is returned when the offset query parameter is not a valid
+// integer
+var ErrInvalidOffset = errors.New("offset must be a valid integer")
+
+// ErrRequestTimeout is returned when the work for a request took longer than
+// the Timeout of the handler
+var ErrRequestTimeout = errors.New("request took too long, try again later")
+
 // getNoteid extracts the noteid from the URL and returns it as an integer
 // It returns an error if the noteid is not a valid integer
 func getNoteId(r *http.Request) (int, error) {
@@ -29,20 +56,149 @@ func getNoteId(r *http.Request) (int, error) {
 	return noteidAsInt, nil
 }
 
+// getListOptions reads the paging, sorting and filtering query parameters of
+// a list request. A sort prefixed with "-" orders the notes descending.
+// It returns an error if the limit is not a valid integer or a time filter is
+// not an RFC 3339 timestamp.
+func getListOptions(r *http.Request) (models.ListOptions, error) {
+	query := r.URL.Query()
+	opts := models.ListOptions{
+		Cursor:  query.Get("cursor"),
+		Title:   query.Get("title"),
+		Content: query.Get("content"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		limitAsInt, err := strconv.Atoi(limit)
+		if err != nil {
+			return opts, fmt.Errorf("%w: %v", ErrInvalidLimit, limit)
+		}
+		opts.Limit = limitAsInt
+	}
+
+	timeFilters := map[string]*time.Time{
+		"created_after":  &opts.CreatedAfter,
+		"created_before": &opts.CreatedBefore,
+		"updated_after":  &opts.UpdatedAfter,
+		"updated_before": &opts.UpdatedBefore,
+	}
+	for param, dest := range timeFilters {
+		if value := query.Get(param); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return opts, fmt.Errorf("%w: %s=%v", ErrInvalidTime, param, value)
+			}
+			*dest = t.UTC()
+		}
+	}
+
+	opts.Tags = query["tag"]
+	opts.TagMode = models.TagMode(query.Get("tag_mode"))
+
+	sort := query.Get("sort")
+	if strings.HasPrefix(sort, "-") {
+		opts.Desc = true
+		sort = sort[1:]
+	}
+	opts.Sort = models.SortField(sort)
+	return opts, nil
+}
+
+// getSearchOptions reads the query and paging parameters of a search request.
+// It returns an error if the limit or offset is not a valid integer.
+func getSearchOptions(r *http.Request) (models.SearchOptions, error) {
+	query := r.URL.Query()
+	opts := models.SearchOptions{Query: query.Get("q")}
+
+	if limit := query.Get("limit"); limit != "" {
+		limitAsInt, err := strconv.Atoi(limit)
+		if err != nil {
+			return opts, fmt.Errorf("%w: %v", ErrInvalidLimit, limit)
+		}
+		opts.Limit = limitAsInt
+	}
+	if offset := query.Get("offset"); offset != "" {
+		offsetAsInt, err := strconv.Atoi(offset)
+		if err != nil {
+			return opts, fmt.Errorf("%w: %v", ErrInvalidOffset, offset)
+		}
+		opts.Offset = offsetAsInt
+	}
+	return opts, nil
+}
+
 // NoteHandler handles HTTP requests related to notes. It provides methods for
 // creating, retrieving, updating, and deleting notes.
+// Notes shared with the user can be used as far as their role allows. The
+// endpoints return a 404 error for notes the user has no access to and a 403
+// error for notes the user may see but not change in the requested way.
 type NoteHandler struct {
 	noteService service.NoteService
+
+	// Timeout limits how long the work done for a request may take. The work
+	// is also abandoned when the client goes away. 0 means no limit.
+	Timeout time.Duration
+	// ShareLinkAttempts limits the wrong passwords sent to view share links,
+	// per link and per client address.
+	ShareLinkAttempts *auth.AttemptLimiter
 }
 
-// NewNoteHandler creates a new NoteHandler
+// DefaultRequestTimeout is the Timeout of a NoteHandler created by
+// NewNoteHandler.
+const DefaultRequestTimeout = 10 * time.Second
+
+const (
+	// DefaultShareLinkAttempts is the number of wrong passwords a share link
+	// and a client address may get per DefaultShareLinkAttemptWindow.
+	DefaultShareLinkAttempts = 10
+	// DefaultShareLinkAttemptWindow is the window of
+	// DefaultShareLinkAttempts.
+	DefaultShareLinkAttemptWindow = 15 * time.Minute
+)
+
+// NewNoteHandler creates a new NoteHandler with the default timeout and
+// share link password attempts.
 func NewNoteHandler(noteService service.NoteService) *NoteHandler {
-	return &NoteHandler{noteService}
+	return &NoteHandler{
+		noteService:       noteService,
+		Timeout:           DefaultRequestTimeout,
+		ShareLinkAttempts: auth.NewAttemptLimiter(DefaultShareLinkAttempts, DefaultShareLinkAttemptWindow),
+	}
+}
+
+// context returns the context the note service is called with for r, which
+// is cancelled when r is and once Timeout has passed.
+func (h NoteHandler) context(r *http.Request) (context.Context, context.CancelFunc) {
+	if h.Timeout <= 0 {
+		return context.WithCancel(r.Context())
+	}
+	return context.WithTimeout(r.Context(), h.Timeout)
+}
+
+// StatusClientClosedRequest is the status a request is answered with when
+// the client went away before its work was done.
+const StatusClientClosedRequest = 499
+
+// writeServerError writes the response for an error no client error was
+// written for. Work abandoned once Timeout has passed is answered with a 503
+// error. Work abandoned because the client went away is not the server's
+// fault and is answered with StatusClientClosedRequest and no body, which the
+// client never reads. Any other error is a 500 error.
+func writeServerError(w http.ResponseWriter, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		utils.ErrorResponse(w, http.StatusServiceUnavailable, ErrRequestTimeout.Error())
+		return
+	} else if errors.Is(err, context.Canceled) {
+		w.WriteHeader(StatusClientClosedRequest)
+		return
+	}
+	utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
 }
 
-// Get retrieves a note by its id from the database.
+// Get retrieves a note by its id from the database along with its ETag.
 // It returns a 404 error if the note is not found and a 400 error
-// if the provided id is not a valid integer.
+// if the provided id is not a valid integer. It responds with 304 and no body
+// if the If-None-Match header matches the current version of the note.
 func (h NoteHandler) Get(w http.ResponseWriter, r *http.Request) {
 	noteid, err := getNoteId(r)
 	if err != nil {
@@ -51,26 +207,39 @@ func (h NoteHandler) Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	note, err := h.noteService.Get(noteid)
+	ctx, cancel := h.context(r)
+	defer cancel()
+	note, err := h.noteService.Get(ctx, getMember(r), noteid)
 	if err != nil {
 		log.Println(err)
 		if errors.Is(err, repository.ErrNoteNotFound) {
 			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
 			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
 		} else if errors.Is(err, service.ErrInvalidId) {
 			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
 			return
 
 		} else {
-			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+			writeServerError(w, err)
 			return
 		}
 	}
+
+	w.Header().Set("ETag", etag(note.Version))
+	if noneMatch(r, note.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: note})
 }
 
 // Create adds a new note to the database.
-// It returns a 400 error if the note data is invalid.
+// It returns a 400 error if the note data is invalid, a 403 error if the user
+// is a viewer of the workspace and a 404 error if the notebook it is filed in
+// is not found.
 func (h NoteHandler) Create(w http.ResponseWriter, r *http.Request) {
 	note := &models.Note{}
 	defer r.Body.Close()
@@ -81,34 +250,74 @@ func (h NoteHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.noteService.Create(note)
+	ctx, cancel := h.context(r)
+	defer cancel()
+	id, err := h.noteService.Create(ctx, getMember(r), note)
 	if err != nil {
 		log.Println(err)
 		if errors.Is(err, service.ErrInvalidNote) {
 			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidNote.Error())
 			return
+		} else if errors.Is(err, service.ErrInvalidTag) {
+			utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, repository.ErrNotebookNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNotebookNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
 		}
-		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		writeServerError(w, err)
 		return
 
 	}
 	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: id})
 }
 
-// GetAll retrieves all notes from the database.
+// GetAll retrieves a page of notes from the database.
+// It returns a 400 error if the paging, sorting or filtering parameters are
+// invalid and a 403 error if the user is a guest of the workspace.
 func (h NoteHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	notes, err := h.noteService.GetAll()
+	opts, err := getListOptions(r)
 	if err != nil {
 		log.Println(err)
-		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	page, err := h.noteService.GetAll(ctx, getMember(r), opts)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidListOptions) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidListOptions.Error())
+			return
+		} else if errors.Is(err, repository.ErrInvalidCursor) {
+			utils.ErrorResponse(w, http.StatusBadRequest, repository.ErrInvalidCursor.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		}
+		writeServerError(w, err)
 		return
 	}
 
-	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: notes})
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{
+		Status: utils.StatusOk,
+		Data:   page.Notes,
+		Meta:   &utils.Meta{NextCursor: page.NextCursor, HasMore: page.HasMore},
+	})
 }
 
-// Update modifies an existing note in the database.
-// It returns a 400 error if the note data is invalid or if the id is not found.
+// Update modifies an existing note in the database and responds with its
+// new ETag. If the request has an If-Match header, the note is only updated
+// if it is still at that version.
+// It returns a 400 error if the note data or id is invalid, a 404 error if
+// the note is not found and a 412 error if the If-Match header does not
+// match.
 func (h NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
 	noteid, err := getNoteId(r)
 	if err != nil {
@@ -117,6 +326,17 @@ func (h NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ctx, cancel := h.context(r)
+	defer cancel()
+	version, err := h.getIfMatch(ctx, r, noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeConditionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+
 	note := &models.Note{}
 	defer r.Body.Close()
 	err = json.NewDecoder(r.Body).Decode(note)
@@ -126,26 +346,44 @@ func (h NoteHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.noteService.Update(noteid, note)
+	err = h.noteService.Update(ctx, getMember(r), noteid, note, version)
 	if err != nil {
 		log.Println(err)
-		if errors.Is(err, service.ErrInvalidNote) {
+		if writeConditionError(w, err) {
+			return
+		} else if errors.Is(err, repository.ErrNoteNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidNote) {
 			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidNote.Error())
 			return
+		} else if errors.Is(err, service.ErrInvalidTag) {
+			utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+			return
 		} else if errors.Is(err, service.ErrInvalidId) {
 			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
 			return
 		} else {
-			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+			writeServerError(w, err)
 			return
 		}
 	}
 
+	if note.Version > 0 {
+		w.Header().Set("ETag", etag(note.Version))
+	}
 	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Note Updated", Status: utils.StatusOk})
 }
 
-// Delete removes a note from the database.
-// It returns a 400 error if the id is not found.
+// Delete moves a note to the trash and responds with 204 and no body. If the
+// request has an If-Match header, the note is only deleted if it is still at
+// that version.
+// Deleting is idempotent: once a note is deleted, further deletes of it
+// change nothing and respond with a 404 error. It returns a 400 error if the
+// id is invalid and a 412 error if the If-Match header does not match.
 func (h NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	noteid, err := getNoteId(r)
 	if err != nil {
@@ -154,16 +392,165 @@ func (h NoteHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.noteService.Delete(noteid)
+	ctx, cancel := h.context(r)
+	defer cancel()
+	version, err := h.getIfMatch(ctx, r, noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeConditionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	err = h.noteService.Delete(ctx, getMember(r), noteid, version)
+	if err != nil {
+		log.Println(err)
+		if writeConditionError(w, err) {
+			return
+		} else if errors.Is(err, repository.ErrNoteNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidId) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Patch partially updates a note and responds with the patched note and its
+// new ETag. The body is either a JSON Merge Patch (application/merge-patch+json)
+// or a JSON Patch (application/json-patch+json) against the JSON form of the
+// note. If the request has an If-Match header, the note is only patched if it
+// is still at that version.
+// It returns a 400 error if the patch is malformed or results in an invalid
+// note, a 404 error if the note is not found, a 409 error if a JSON Patch test
+// operation fails, a 412 error if the If-Match header does not match and a
+// 415 error for other content types.
+func (h NoteHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	version, err := h.getIfMatch(ctx, r, noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeConditionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	var p patch.Patch
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case patch.MergePatchType:
+		p, err = patch.ParseMergePatch(body)
+	case patch.JSONPatchType:
+		p, err = patch.ParseJSONPatch(body)
+	default:
+		w.Header().Set("Accept-Patch", patch.MergePatchType+", "+patch.JSONPatchType)
+		utils.ErrorResponse(w, http.StatusUnsupportedMediaType, ErrUnsupportedPatch.Error())
+		return
+	}
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	note, err := h.noteService.Patch(ctx, getMember(r), noteid, p, version)
 	if err != nil {
 		log.Println(err)
-		if errors.Is(err, service.ErrInvalidId) {
+		if writeConditionError(w, err) {
+			return
+		} else if errors.Is(err, repository.ErrNoteNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, patch.ErrTestFailed) {
+			utils.ErrorResponse(w, http.StatusConflict, patch.ErrTestFailed.Error())
+			return
+		} else if errors.Is(err, patch.ErrInvalidPatch) {
+			utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidNote) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidNote.Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidTag) {
+			utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, service.ErrReadOnlyField) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrReadOnlyField.Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidId) {
 			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
 			return
 		}
-		utils.ErrorResponse(w, http.StatusInternalServerError, "Internal Server Error")
+		writeServerError(w, err)
 		return
+	}
 
+	w.Header().Set("ETag", etag(note.Version))
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: note})
+}
+
+// Search finds notes matching the full-text query in the q parameter, best
+// matches first.
+// It returns a 400 error if the query is missing or malformed or the paging
+// parameters are invalid and a 403 error if the user is a guest of the
+// workspace.
+func (h NoteHandler) Search(w http.ResponseWriter, r *http.Request) {
+	opts, err := getSearchOptions(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	results, err := h.noteService.Search(ctx, getMember(r), opts)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrEmptySearchQuery) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrEmptySearchQuery.Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidListOptions) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidListOptions.Error())
+			return
+		} else if errors.Is(err, repository.ErrInvalidSearchQuery) {
+			utils.ErrorResponse(w, http.StatusBadRequest, repository.ErrInvalidSearchQuery.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		}
+		writeServerError(w, err)
+		return
 	}
-	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Message: "Note Deleted"})
+
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: results})
 }
@@ -4,15 +4,20 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/JannisK89/notes-api/internal/mocks"
 	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
 	"github.com/JannisK89/notes-api/internal/service"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 // TODO: Fix Service Mock
@@ -20,14 +25,16 @@ import (
 func TestNoteHandler_Get(t *testing.T) {
 	// Arrange
 	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
 	noteService := service.NewNoteService(noteRepoMock)
 	noteHandler := NewNoteHandler(noteService)
 
-	note := &models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note"}
+	createdAt := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	note := &models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", CreatedAt: createdAt, UpdatedAt: createdAt.Add(time.Hour), Version: 2}
 
-	noteRepoMock.On("Get", 1).Return(note, nil)
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(note, nil)
 
-	req := httptest.NewRequest(http.MethodGet, "/api/v1/notes/", nil)
+	req := newRequest(http.MethodGet, "/api/v1/notes/", nil)
 	rec := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -39,25 +46,77 @@ func TestNoteHandler_Get(t *testing.T) {
 
 	// Assertion
 	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.JSONEq(t, `{"status": "ok", "data": {"id":1,"title":"Test Note","content":"I Am A Test Note"} }`, rec.Body.String())
+	assert.JSONEq(t, `{"status": "ok", "data": {"id":1,"title":"Test Note","content":"I Am A Test Note","created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-01T10:30:00Z","version":2} }`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_GetCanceled(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	// The client went away before the note was read.
+	canceled := mock.MatchedBy(func(ctx context.Context) bool { return errors.Is(ctx.Err(), context.Canceled) })
+	noteRepoMock.On("Get", canceled, testWorkspaceId, 1).Return((*models.Note)(nil), &repository.RepoError{Src: "GetNoteByID", Id: 1, Err: context.Canceled})
+
+	req := withNoteId(newRequest(http.MethodGet, "/api/v1/notes/1", nil), "1")
+	ctx, cancel := context.WithCancel(req.Context())
+	cancel()
+	req = req.WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Get(rec, req)
+
+	// Assertion
+	assert.Equal(t, StatusClientClosedRequest, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_GetTimeout(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+	noteHandler.Timeout = 10 * time.Millisecond
+
+	// The read takes until the deadline of the request.
+	var readErr error
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Run(func(args mock.Arguments) {
+		ctx := args.Get(0).(context.Context)
+		<-ctx.Done()
+		readErr = ctx.Err()
+	}).Return((*models.Note)(nil), &repository.RepoError{Src: "GetNoteByID", Id: 1, Err: context.DeadlineExceeded})
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Get(rec, withNoteId(newRequest(http.MethodGet, "/api/v1/notes/1", nil), "1"))
+
+	// Assertion
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrRequestTimeout.Error())
+	assert.ErrorIs(t, readErr, context.DeadlineExceeded)
 	noteRepoMock.AssertExpectations(t)
 }
 
 func TestNoteHandler_Create(t *testing.T) {
 	// Arrange
 	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
 	noteService := service.NewNoteService(noteRepoMock)
 	noteHandler := NewNoteHandler(noteService)
 
 	note := &models.Note{Title: "Test Note", Content: "I Am A Test Note"}
-	noteRepoMock.On("Create", note).Return(1, nil)
+	noteRepoMock.On("Create", mock.Anything, testWorkspaceId, testUserId, note).Return(1, nil)
 
 	payload, err := json.Marshal(note)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	req := httptest.NewRequest(http.MethodPost, "/api/v1/notes", bytes.NewReader(payload))
+	req := newRequest(http.MethodPost, "/api/v1/notes", bytes.NewReader(payload))
 	rec := httptest.NewRecorder()
 
 	// Act
@@ -69,21 +128,66 @@ func TestNoteHandler_Create(t *testing.T) {
 	noteRepoMock.AssertExpectations(t)
 }
 
+func TestNoteHandler_GetAll(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteService := service.NewNoteService(noteRepoMock)
+	noteHandler := NewNoteHandler(noteService)
+
+	createdAt := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	opts := models.ListOptions{Limit: 1, Cursor: "abc", Sort: models.SortByTitle, Desc: true, Title: "Test", Tags: []string{"home", "work"}, TagMode: models.TagModeAny, CreatedAfter: createdAt}
+	page := &models.NotePage{
+		Notes:      []*models.Note{{Id: 1, Title: "Test Note", Content: "I Am A Test Note", Tags: []string{"work"}, CreatedAt: createdAt, UpdatedAt: createdAt, Version: 1}},
+		NextCursor: "def",
+		HasMore:    true,
+	}
+	noteRepoMock.On("GetAll", mock.Anything, testWorkspaceId, opts).Return(page, nil)
+
+	req := newRequest(http.MethodGet, "/api/v1/notes?limit=1&cursor=abc&sort=-title&title=Test&tag=Work&tag=home&tag_mode=any&created_after=2024-05-01T11:30:00%2B02:00", nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.GetAll(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [{"id":1,"title":"Test Note","content":"I Am A Test Note","tags":["work"],"created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-01T09:30:00Z","version":1}], "meta": {"next_cursor": "def", "has_more": true}}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_GetAllInvalidOptions(t *testing.T) {
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	for _, query := range []string{"limit=abc", "limit=1000", "limit=-1", "sort=colour", "updated_before=yesterday"} {
+		req := newRequest(http.MethodGet, "/api/v1/notes?"+query, nil)
+		rec := httptest.NewRecorder()
+
+		noteHandler.GetAll(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+	noteRepoMock.AssertExpectations(t)
+}
+
 func TestNoteHandler_Update(t *testing.T) {
 	// Arrange
 	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
 	noteService := service.NewNoteService(noteRepoMock)
 	noteHandler := NewNoteHandler(noteService)
 
 	note := &models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note"}
-	noteRepoMock.On("Update", 1, note).Return(nil)
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, note, 0).Return(nil)
 
 	payload, err := json.Marshal(note)
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	req := httptest.NewRequest(http.MethodPut, "/api/v1/notes", bytes.NewReader(payload))
+	req := newRequest(http.MethodPut, "/api/v1/notes", bytes.NewReader(payload))
 	rec := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -102,12 +206,13 @@ func TestNoteHandler_Update(t *testing.T) {
 func TestNoteHandler_Delete(t *testing.T) {
 	// Arrange
 	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
 	noteService := service.NewNoteService(noteRepoMock)
 	noteHandler := NewNoteHandler(noteService)
 
-	noteRepoMock.On("Delete", 1).Return(nil)
+	noteRepoMock.On("Delete", mock.Anything, testWorkspaceId, 1, 0).Return(nil)
 
-	req := httptest.NewRequest(http.MethodDelete, "/api/v1/notes", nil)
+	req := newRequest(http.MethodDelete, "/api/v1/notes", nil)
 	rec := httptest.NewRecorder()
 
 	rctx := chi.NewRouteContext()
@@ -118,7 +223,382 @@ func TestNoteHandler_Delete(t *testing.T) {
 	noteHandler.Delete(rec, req)
 
 	// Assertion
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_DeleteNotFound(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	// The first delete removes the note, repeating it finds nothing to delete.
+	noteRepoMock.On("Delete", mock.Anything, testWorkspaceId, 1, 0).Return(nil).Once()
+	noteRepoMock.On("Delete", mock.Anything, testWorkspaceId, 1, 0).Return(&repository.RepoError{Src: "DeleteNoteByID", Id: 1, Err: repository.ErrNoteNotFound})
+
+	// Act
+	first := httptest.NewRecorder()
+	noteHandler.Delete(first, withNoteId(newRequest(http.MethodDelete, "/api/v1/notes/1", nil), "1"))
+	second := httptest.NewRecorder()
+	noteHandler.Delete(second, withNoteId(newRequest(http.MethodDelete, "/api/v1/notes/1", nil), "1"))
+
+	// Assertion
+	assert.Equal(t, http.StatusNoContent, first.Code)
+	assert.Equal(t, http.StatusNotFound, second.Code)
+	assert.JSONEq(t, `{"status": "error", "message": "note not found"}`, second.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_UpdateNotFound(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	note := &models.Note{Title: "Test Note", Content: "I Am A Test Note"}
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 2, note, 0).Return(&repository.RepoError{Src: "UpdateNoteByID", Id: 2, Err: repository.ErrNoteNotFound})
+
+	payload, err := json.Marshal(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := withNoteId(newRequest(http.MethodPut, "/api/v1/notes/2", bytes.NewReader(payload)), "2")
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Update(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.JSONEq(t, `{"status": "error", "message": "note not found"}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_Search(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteService := service.NewNoteService(noteRepoMock)
+	noteHandler := NewNoteHandler(noteService)
+
+	opts := models.SearchOptions{Query: "test*", Limit: service.DefaultPageSize, Offset: 5}
+	results := []*models.SearchResult{{
+		Note:      models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", CreatedAt: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC), UpdatedAt: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC), Version: 1},
+		Score:     1.25,
+		Highlight: "<mark>Test</mark> Note",
+		Snippet:   "I Am A <mark>Test</mark> Note",
+	}}
+	noteRepoMock.On("Search", mock.Anything, testWorkspaceId, opts).Return(results, nil)
+
+	req := newRequest(http.MethodGet, "/api/v1/notes/search?q=test*&offset=5", nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Search(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [{"id":1,"title":"Test Note","content":"I Am A Test Note","created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-01T09:30:00Z","version":1,"score":1.25,"highlight":"<mark>Test</mark> Note","snippet":"I Am A <mark>Test</mark> Note"}]}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_SearchInvalidQuery(t *testing.T) {
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("Search", mock.Anything, testWorkspaceId, models.SearchOptions{Query: "AND", Limit: service.DefaultPageSize}).
+		Return([]*models.SearchResult(nil), &repository.RepoError{Src: "SearchNotes", Err: repository.ErrInvalidSearchQuery})
+
+	for _, query := range []string{"", "q=", "q=%20%20", "q=a&limit=x", "q=a&offset=-1", "q=AND"} {
+		req := newRequest(http.MethodGet, "/api/v1/notes/search?"+query, nil)
+		rec := httptest.NewRecorder()
+
+		noteHandler.Search(rec, req)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+	noteRepoMock.AssertExpectations(t)
+}
+
+// withNoteId adds the noteId URL parameter to req.
+func withNoteId(req *http.Request, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("noteId", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestNoteHandler_GetETag(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	note := &models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", Version: 3}
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(note, nil)
+
+	cases := map[string]int{
+		"":             http.StatusOK,
+		`"3"`:          http.StatusNotModified,
+		`W/"3"`:        http.StatusNotModified,
+		`"1", "3"`:     http.StatusNotModified,
+		"*":            http.StatusNotModified,
+		`"2"`:          http.StatusOK,
+		"not-an-etag":  http.StatusOK,
+		`"1", W/"bla"`: http.StatusOK,
+	}
+	for header, status := range cases {
+		req := withNoteId(newRequest(http.MethodGet, "/api/v1/notes/1", nil), "1")
+		if header != "" {
+			req.Header.Set("If-None-Match", header)
+		}
+		rec := httptest.NewRecorder()
+
+		// Act
+		noteHandler.Get(rec, req)
+
+		// Assert
+		assert.Equal(t, status, rec.Code, header)
+		assert.Equal(t, `"3"`, rec.Header().Get("ETag"), header)
+		if status == http.StatusNotModified {
+			assert.Empty(t, rec.Body.String(), header)
+		}
+	}
+}
+
+func TestNoteHandler_UpdateIfMatch(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	note := &models.Note{Title: "Test Note", Content: "I Am A Test Note"}
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, note, 2).Run(func(args mock.Arguments) {
+		args.Get(3).(*models.Note).Version = 3
+	}).Return(nil)
+
+	payload, err := json.Marshal(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := withNoteId(newRequest(http.MethodPut, "/api/v1/notes/1", bytes.NewReader(payload)), "1")
+	req.Header.Set("If-Match", `"2"`)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Update(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_UpdatePreconditionFailed(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	note := &models.Note{Title: "Test Note", Content: "I Am A Test Note"}
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, note, 2).Return(&repository.RepoError{Src: "UpdateNoteByID", Id: 1, Err: repository.ErrVersionConflict})
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Newer", Content: "Newer", Version: 4}, nil)
+
+	payload, err := json.Marshal(note)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := withNoteId(newRequest(http.MethodPut, "/api/v1/notes/1", bytes.NewReader(payload)), "1")
+	req.Header.Set("If-Match", `"2"`)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Update(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, `"4"`, rec.Header().Get("ETag"))
+	assert.JSONEq(t, `{"status": "error", "message": "note has been modified"}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_DeleteIfMatch(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", Version: 4}, nil)
+	noteRepoMock.On("Delete", mock.Anything, testWorkspaceId, 1, 4).Return(nil)
+
+	cases := map[string]int{
+		`"1", "4"`: http.StatusNoContent,
+		`"1", "2"`: http.StatusPreconditionFailed,
+		`W/"4"`:    http.StatusPreconditionFailed,
+		"garbage":  http.StatusPreconditionFailed,
+	}
+	for header, status := range cases {
+		req := withNoteId(newRequest(http.MethodDelete, "/api/v1/notes/1", nil), "1")
+		req.Header.Set("If-Match", header)
+		rec := httptest.NewRecorder()
+
+		// Act
+		noteHandler.Delete(rec, req)
+
+		// Assert
+		assert.Equal(t, status, rec.Code, header)
+	}
+	noteRepoMock.AssertNumberOfCalls(t, "Delete", 1)
+}
+
+func TestNoteHandler_IfMatchWildcard(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", Version: 4}, nil)
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 2).Return((*models.Note)(nil), &repository.RepoError{Src: "GetNoteByID", Id: 2, Err: repository.ErrNoteNotFound})
+	noteRepoMock.On("Delete", mock.Anything, testWorkspaceId, 1, 4).Return(nil)
+
+	cases := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		id      string
+		body    string
+		status  int
+	}{
+		{"delete", noteHandler.Delete, http.MethodDelete, "1", "", http.StatusNoContent},
+		{"delete missing", noteHandler.Delete, http.MethodDelete, "2", "", http.StatusPreconditionFailed},
+		{"update missing", noteHandler.Update, http.MethodPut, "2", `{"title": "Test Note", "content": "Changed"}`, http.StatusPreconditionFailed},
+		{"patch missing", noteHandler.Patch, http.MethodPatch, "2", `{"title": "Renamed"}`, http.StatusPreconditionFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := withNoteId(newRequest(c.method, "/api/v1/notes/"+c.id, strings.NewReader(c.body)), c.id)
+			req.Header.Set("If-Match", "*")
+			if c.method == http.MethodPatch {
+				req.Header.Set("Content-Type", "application/merge-patch+json")
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			c.handler(rec, req)
+
+			// Assert
+			assert.Equal(t, c.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNumberOfCalls(t, "Delete", 1)
+	noteRepoMock.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, 2, mock.Anything, mock.Anything)
+}
+
+func TestNoteHandler_Patch(t *testing.T) {
+	createdAt := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	stored := func() *models.Note {
+		return &models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", CreatedAt: createdAt, UpdatedAt: createdAt, Version: 2}
+	}
+
+	cases := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		title       string
+	}{
+		{"merge patch", "application/merge-patch+json", `{"title": "Renamed"}`, http.StatusOK, "Renamed"},
+		{"json patch", "application/json-patch+json", `[{"op": "test", "path": "/title", "value": "Test Note"}, {"op": "replace", "path": "/title", "value": "Renamed"}]`, http.StatusOK, "Renamed"},
+		{"failed test", "application/json-patch+json", `[{"op": "test", "path": "/title", "value": "Other"}, {"op": "replace", "path": "/title", "value": "Renamed"}]`, http.StatusConflict, ""},
+		{"removes title", "application/merge-patch+json", `{"title": null}`, http.StatusBadRequest, ""},
+		{"changes version", "application/json-patch+json", `[{"op": "replace", "path": "/version", "value": 7}]`, http.StatusBadRequest, ""},
+		{"unknown field", "application/merge-patch+json", `{"colour": "red"}`, http.StatusBadRequest, ""},
+		{"missing path", "application/json-patch+json", `[{"op": "remove", "path": "/tags/0"}]`, http.StatusBadRequest, ""},
+		{"malformed", "application/json-patch+json", `[{"op": "remove"`, http.StatusBadRequest, ""},
+		{"plain json", "application/json", `{"title": "Renamed"}`, http.StatusUnsupportedMediaType, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Arrange
+			noteRepoMock := &mocks.NoteRepoMock{}
+			ownNotes(noteRepoMock)
+			noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+			noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(stored(), nil)
+			noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, mock.Anything, 2).Run(func(args mock.Arguments) {
+				args.Get(3).(*models.Note).Version = 3
+			}).Return(nil)
+
+			req := withNoteId(newRequest(http.MethodPatch, "/api/v1/notes/1", strings.NewReader(c.body)), "1")
+			req.Header.Set("Content-Type", c.contentType)
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.Patch(rec, req)
+
+			// Assert
+			assert.Equal(t, c.status, rec.Code, rec.Body.String())
+			if c.status != http.StatusOK {
+				noteRepoMock.AssertNotCalled(t, "Update", mock.Anything, testWorkspaceId, 1, mock.Anything, 2)
+				return
+			}
+			assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+			var res struct{ Data models.Note }
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, c.title, res.Data.Title)
+			assert.Equal(t, "I Am A Test Note", res.Data.Content)
+			assert.Equal(t, 3, res.Data.Version)
+		})
+	}
+}
+
+func TestNoteHandler_PatchRetriesConcurrentChange(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	conflict := &repository.RepoError{Src: "UpdateNoteByID", Id: 1, Err: repository.ErrVersionConflict}
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Test Note", Content: "Old", Version: 2}, nil).Once()
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, mock.Anything, 2).Return(conflict).Once()
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Test Note", Content: "Changed concurrently", Version: 3}, nil).Once()
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, mock.Anything, 3).Return(nil).Once()
+
+	req := withNoteId(newRequest(http.MethodPatch, "/api/v1/notes/1", strings.NewReader(`{"title": "Renamed"}`)), "1")
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Patch(rec, req)
+
+	// Assert
 	assert.Equal(t, http.StatusOK, rec.Code)
-	assert.JSONEq(t, `{"status": "ok", "message": "Note Deleted"}`, rec.Body.String())
+	updated := noteRepoMock.Calls[4].Arguments.Get(3).(*models.Note)
+	assert.Equal(t, "Renamed", updated.Title)
+	assert.Equal(t, "Changed concurrently", updated.Content)
 	noteRepoMock.AssertExpectations(t)
 }
+
+func TestNoteHandler_PatchIfMatch(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", Version: 3}, nil)
+
+	req := withNoteId(newRequest(http.MethodPatch, "/api/v1/notes/1", strings.NewReader(`{"title": "Renamed"}`)), "1")
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	req.Header.Set("If-Match", `"2"`)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Patch(rec, req)
+
+	// Assert
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+	noteRepoMock.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,351 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// ErrInvalidRecursive is returned when the recursive query parameter is not
+// a boolean
+var ErrInvalidRecursive = errors.New("recursive must be true or false")
+
+// getNotebookId extracts the notebookId from the URL and returns it as an
+// integer
+// It returns an error if the notebookId is not a valid integer
+func getNotebookId(r *http.Request) (int, error) {
+	notebookid := chi.URLParam(r, "notebookId")
+	notebookidAsInt, err := strconv.Atoi(notebookid)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidId, notebookid)
+	}
+	return notebookidAsInt, nil
+}
+
+// writeNotebookError responds to errors shared by the notebook endpoints. It
+// reports whether err was one of them.
+func writeNotebookError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrNotebookNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNotebookNotFound.Error())
+		return true
+	} else if errors.Is(err, service.ErrForbidden) {
+		utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, repository.ErrNotebookExists) {
+		utils.ErrorResponse(w, http.StatusConflict, repository.ErrNotebookExists.Error())
+		return true
+	} else if errors.Is(err, repository.ErrNotebookNotEmpty) {
+		utils.ErrorResponse(w, http.StatusConflict, repository.ErrNotebookNotEmpty.Error())
+		return true
+	} else if errors.Is(err, repository.ErrNotebookCycle) {
+		utils.ErrorResponse(w, http.StatusConflict, repository.ErrNotebookCycle.Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidNotebook) {
+		utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidDeleteMode) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidDeleteMode.Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidId) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+		return true
+	}
+	return false
+}
+
+// NotebookHandler handles HTTP requests related to notebooks. It provides
+// methods for creating, retrieving, renaming, moving and deleting notebooks
+// and for listing the notes in them.
+// Like notes, notebooks shared with the user respond with a 404 error if the
+// user has no access and a 403 error if their role is too low.
+type NotebookHandler struct {
+	notebookService service.NotebookService
+	noteService     service.NoteService
+}
+
+// NewNotebookHandler creates a new NotebookHandler
+func NewNotebookHandler(notebookService service.NotebookService, noteService service.NoteService) *NotebookHandler {
+	return &NotebookHandler{notebookService, noteService}
+}
+
+// GetAll retrieves all notebooks, each listed after its parent.
+// It returns a 403 error if the user is a guest of the workspace.
+func (h NotebookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	notebooks, err := h.notebookService.GetAll(getMember(r))
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: notebooks})
+}
+
+// Get retrieves a notebook by its id.
+// It returns a 400 error if the id is invalid and a 404 error if the
+// notebook is not found.
+func (h NotebookHandler) Get(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	notebook, err := h.notebookService.Get(getMember(r), notebookid)
+	if err != nil {
+		log.Println(err)
+		if !writeNotebookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: notebook})
+}
+
+// Create adds a new notebook, optionally below the notebook given as
+// parent_id, and responds with the created notebook.
+// It returns a 400 error if the notebook data is invalid, a 404 error if the
+// parent is not found and a 409 error if the parent already has a notebook
+// with that name.
+func (h NotebookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	notebook := &models.Notebook{}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(notebook); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	if _, err := h.notebookService.Create(getMember(r), notebook); err != nil {
+		log.Println(err)
+		if !writeNotebookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: notebook})
+}
+
+// Rename changes the name of a notebook to the name in the request body and
+// responds with the renamed notebook.
+// It returns a 400 error if the name or id is invalid, a 404 error if the
+// notebook is not found and a 409 error if its parent already has a
+// notebook with that name.
+func (h NotebookHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var body struct {
+		Name string `json:"name"`
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	notebook, err := h.notebookService.Rename(getMember(r), notebookid, body.Name)
+	if err != nil {
+		log.Println(err)
+		if !writeNotebookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Notebook Renamed", Status: utils.StatusOk, Data: notebook})
+}
+
+// Move moves a notebook with everything in it below the notebook given as
+// parent_id in the request body, or to the top level if it is null, and
+// responds with the moved notebook.
+// It returns a 400 error if an id is invalid, a 404 error if either notebook
+// is not found and a 409 error if the notebook would end up below itself or
+// the new parent already has a notebook with its name.
+func (h NotebookHandler) Move(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var body struct {
+		ParentId *int `json:"parent_id"`
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	notebook, err := h.notebookService.Move(getMember(r), notebookid, body.ParentId)
+	if err != nil {
+		log.Println(err)
+		if !writeNotebookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Notebook Moved", Status: utils.StatusOk, Data: notebook})
+}
+
+// Delete removes a notebook and responds with 204 and no body. The mode
+// query parameter decides what happens to its contents: block (the default)
+// only deletes empty notebooks, trash moves its notes to the trash and
+// deletes the notebooks below it and move moves its contents to its parent.
+// It returns a 400 error if the id or mode is invalid, a 404 error if the
+// notebook is not found and a 409 error if it is not empty or its contents
+// cannot be moved.
+func (h NotebookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	mode := models.NotebookDeleteMode(r.URL.Query().Get("mode"))
+	if err := h.notebookService.Delete(getMember(r), notebookid, mode); err != nil {
+		log.Println(err)
+		if !writeNotebookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetNotes retrieves a page of the notes in a notebook, including those in
+// the notebooks below it if recursive is true. It takes the same parameters
+// as listing all notes.
+// It returns a 400 error if the id or the paging, sorting or filtering
+// parameters are invalid and a 404 error if the notebook is not found.
+func (h NotebookHandler) GetNotes(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	opts, err := getListOptions(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if recursive := r.URL.Query().Get("recursive"); recursive != "" {
+		if opts.Recursive, err = strconv.ParseBool(recursive); err != nil {
+			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidRecursive, recursive).Error())
+			return
+		}
+	}
+
+	if _, err := h.notebookService.Get(getMember(r), notebookid); err != nil {
+		log.Println(err)
+		if !writeNotebookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	opts.NotebookId = notebookid
+	page, err := h.noteService.GetAll(r.Context(), getMember(r), opts)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidListOptions) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidListOptions.Error())
+			return
+		} else if errors.Is(err, repository.ErrInvalidCursor) {
+			utils.ErrorResponse(w, http.StatusBadRequest, repository.ErrInvalidCursor.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{
+		Status: utils.StatusOk,
+		Data:   page.Notes,
+		Meta:   &utils.Meta{NextCursor: page.NextCursor, HasMore: page.HasMore},
+	})
+}
+
+// Move files a note in the notebook given as notebook_id in the request
+// body, or in none if it is null, and responds with the moved note and its
+// new ETag. If the request has an If-Match header, the note is only moved if
+// it is still at that version.
+// It returns a 400 error if an id is invalid, a 404 error if the note or
+// notebook is not found and a 412 error if the If-Match header does not
+// match.
+func (h NoteHandler) Move(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	version, err := h.getIfMatch(ctx, r, noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeConditionError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+
+	var body struct {
+		NotebookId *int `json:"notebook_id"`
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	note, err := h.noteService.Move(ctx, getMember(r), noteid, body.NotebookId, version)
+	if err != nil {
+		log.Println(err)
+		if writeConditionError(w, err) {
+			return
+		} else if errors.Is(err, repository.ErrNoteNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, repository.ErrNotebookNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNotebookNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidId) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+			return
+		}
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", etag(note.Version))
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Note Moved", Status: utils.StatusOk, Data: note})
+}
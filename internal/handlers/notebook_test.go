@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// withNotebookId adds the notebookId URL parameter to req.
+func withNotebookId(req *http.Request, id string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("notebookId", id)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+// newNotebookHandler returns a NotebookHandler backed by the given mocks.
+func newNotebookHandler(notebookRepoMock *mocks.NotebookRepoMock, noteRepoMock *mocks.NoteRepoMock) *NotebookHandler {
+	return NewNotebookHandler(service.NewNotebookService(notebookRepoMock), service.NewNoteService(noteRepoMock))
+}
+
+func TestNotebookHandler_Create(t *testing.T) {
+	// Arrange
+	notebookRepoMock := &mocks.NotebookRepoMock{}
+	ownNotebooks(notebookRepoMock)
+	notebookHandler := newNotebookHandler(notebookRepoMock, &mocks.NoteRepoMock{})
+
+	parentId, missingId := 1, 9
+	notebookRepoMock.On("Create", testWorkspaceId, testUserId, &models.Notebook{Name: "Projects", ParentId: &parentId}).Return(2, nil)
+	notebookRepoMock.On("Create", testWorkspaceId, testUserId, &models.Notebook{Name: "Work"}).
+		Return(0, &repository.RepoError{Src: "CreateNotebook", Err: repository.ErrNotebookExists})
+	notebookRepoMock.On("Create", testWorkspaceId, testUserId, &models.Notebook{Name: "Work", ParentId: &missingId}).
+		Return(0, &repository.RepoError{Src: "CreateNotebook", Err: repository.ErrNotebookNotFound})
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"name": " Projects ", "parent_id": 1}`, http.StatusCreated},
+		{"exists", `{"name": "Work"}`, http.StatusConflict},
+		{"missing parent", `{"name": "Work", "parent_id": 9}`, http.StatusNotFound},
+		{"empty name", `{"name": "  "}`, http.StatusBadRequest},
+		{"invalid parent", `{"name": "Work", "parent_id": 0}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, "/api/v1/notebooks", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			// Act
+			notebookHandler.Create(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	notebookRepoMock.AssertNumberOfCalls(t, "Create", 3)
+}
+
+func TestNotebookHandler_Move(t *testing.T) {
+	// Arrange
+	notebookRepoMock := &mocks.NotebookRepoMock{}
+	ownNotebooks(notebookRepoMock)
+	notebookHandler := newNotebookHandler(notebookRepoMock, &mocks.NoteRepoMock{})
+
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	parentId, childId := 3, 4
+	notebookRepoMock.On("Move", testWorkspaceId, 2, &parentId).Return(nil)
+	notebookRepoMock.On("Move", testWorkspaceId, 2, &childId).Return(&repository.RepoError{Src: "MoveNotebookByID", Id: 2, Err: repository.ErrNotebookCycle})
+	notebookRepoMock.On("Move", testWorkspaceId, 2, (*int)(nil)).Return(nil)
+	notebookRepoMock.On("Get", testWorkspaceId, 2).Return(&models.Notebook{Id: 2, Name: "Work", ParentId: &parentId, CreatedAt: at, UpdatedAt: at}, nil)
+
+	// Act
+	moved := httptest.NewRecorder()
+	notebookHandler.Move(moved, withNotebookId(newRequest(http.MethodPost, "/api/v1/notebooks/2/move", strings.NewReader(`{"parent_id": 3}`)), "2"))
+	cycle := httptest.NewRecorder()
+	notebookHandler.Move(cycle, withNotebookId(newRequest(http.MethodPost, "/api/v1/notebooks/2/move", strings.NewReader(`{"parent_id": 4}`)), "2"))
+	top := httptest.NewRecorder()
+	notebookHandler.Move(top, withNotebookId(newRequest(http.MethodPost, "/api/v1/notebooks/2/move", strings.NewReader(`{"parent_id": null}`)), "2"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, moved.Code)
+	assert.JSONEq(t, `{"message": "Notebook Moved", "status": "ok", "data": {"id": 2, "name": "Work", "parent_id": 3, "created_at": "2024-05-01T09:30:00Z", "updated_at": "2024-05-01T09:30:00Z"}}`, moved.Body.String())
+	assert.Equal(t, http.StatusConflict, cycle.Code)
+	assert.Equal(t, http.StatusOK, top.Code)
+	notebookRepoMock.AssertExpectations(t)
+}
+
+func TestNotebookHandler_Delete(t *testing.T) {
+	// Arrange
+	notebookRepoMock := &mocks.NotebookRepoMock{}
+	ownNotebooks(notebookRepoMock)
+	notebookHandler := newNotebookHandler(notebookRepoMock, &mocks.NoteRepoMock{})
+
+	notebookRepoMock.On("Delete", testWorkspaceId, 2, models.NotebookDeleteBlock).Return(&repository.RepoError{Src: "DeleteNotebookByID", Id: 2, Err: repository.ErrNotebookNotEmpty})
+	notebookRepoMock.On("Delete", testWorkspaceId, 2, models.NotebookDeleteTrash).Return(nil)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"default blocks", "", http.StatusConflict},
+		{"trash", "?mode=trash", http.StatusNoContent},
+		{"unknown mode", "?mode=shred", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withNotebookId(newRequest(http.MethodDelete, "/api/v1/notebooks/2"+tt.query, nil), "2")
+			rec := httptest.NewRecorder()
+
+			// Act
+			notebookHandler.Delete(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	notebookRepoMock.AssertExpectations(t)
+}
+
+func TestNotebookHandler_GetNotes(t *testing.T) {
+	// Arrange
+	notebookRepoMock := &mocks.NotebookRepoMock{}
+	ownNotebooks(notebookRepoMock)
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	notebookHandler := newNotebookHandler(notebookRepoMock, noteRepoMock)
+
+	notebookRepoMock.On("Get", testWorkspaceId, 2).Return(&models.Notebook{Id: 2, Name: "Work"}, nil)
+	notebookRepoMock.On("Get", testWorkspaceId, 9).Return((*models.Notebook)(nil), &repository.RepoError{Src: "GetNotebookByID", Id: 9, Err: repository.ErrNotebookNotFound})
+	opts := models.ListOptions{Limit: 20, Sort: models.SortById, TagMode: models.TagModeAll, NotebookId: 2, Recursive: true}
+	noteRepoMock.On("GetAll", mock.Anything, testWorkspaceId, opts).Return(&models.NotePage{Notes: []*models.Note{{Id: 1, Title: "Title", Content: "Content"}}}, nil)
+
+	// Act
+	found := httptest.NewRecorder()
+	notebookHandler.GetNotes(found, withNotebookId(newRequest(http.MethodGet, "/api/v1/notebooks/2/notes?recursive=true", nil), "2"))
+	missing := httptest.NewRecorder()
+	notebookHandler.GetNotes(missing, withNotebookId(newRequest(http.MethodGet, "/api/v1/notebooks/9/notes", nil), "9"))
+	invalid := httptest.NewRecorder()
+	notebookHandler.GetNotes(invalid, withNotebookId(newRequest(http.MethodGet, "/api/v1/notebooks/2/notes?recursive=maybe", nil), "2"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, found.Code)
+	assert.Contains(t, found.Body.String(), `"title":"Title"`)
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_Move(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	notebookId := 2
+	noteRepoMock.On("MoveNote", mock.Anything, testWorkspaceId, 1, &notebookId, 3).Return(nil).Once()
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Title", Content: "Content", NotebookId: &notebookId, Version: 4}, nil).Once()
+	noteRepoMock.On("MoveNote", mock.Anything, testWorkspaceId, 1, &notebookId, 3).Return(&repository.RepoError{Src: "MoveNoteByID", Id: 1, Err: repository.ErrVersionConflict}).Once()
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Version: 4}, nil).Once()
+
+	newRequest := func() *http.Request {
+		req := withNoteId(newRequest(http.MethodPost, "/api/v1/notes/1/move", strings.NewReader(`{"notebook_id": 2}`)), "1")
+		req.Header.Set("If-Match", `"3"`)
+		return req
+	}
+
+	// Act
+	moved := httptest.NewRecorder()
+	noteHandler.Move(moved, newRequest())
+	conflict := httptest.NewRecorder()
+	noteHandler.Move(conflict, newRequest())
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, moved.Code)
+	assert.Equal(t, `"4"`, moved.Header().Get("ETag"))
+	assert.Contains(t, moved.Body.String(), `"notebook_id":2`)
+	assert.Equal(t, http.StatusPreconditionFailed, conflict.Code)
+	assert.Equal(t, `"4"`, conflict.Header().Get("ETag"))
+	noteRepoMock.AssertExpectations(t)
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// ErrInvalidRevision is returned when a revision is not a valid integer
+var ErrInvalidRevision = errors.New("revision must be a valid integer")
+
+// getRevision parses the revision number in the URL parameter or query
+// parameter value.
+// It returns an error if the value is not a valid integer
+func getRevision(value string) (int, error) {
+	revision, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidRevision, value)
+	}
+	return revision, nil
+}
+
+// writeRevisionError responds to errors shared by the revision endpoints. It
+// reports whether err was one of them.
+func writeRevisionError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrNoteNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrRevisionNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrRevisionNotFound.Error())
+		return true
+	} else if errors.Is(err, service.ErrForbidden) {
+		utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidId) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+		return true
+	}
+	return false
+}
+
+// GetRevisions lists the revisions of a note, newest first.
+// It returns a 400 error if the id is invalid and a 404 error if the note is
+// not found.
+func (h NoteHandler) GetRevisions(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	revisions, err := h.noteService.GetRevisions(ctx, getMember(r), noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeRevisionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: revisions})
+}
+
+// GetRevision retrieves a single revision of a note.
+// It returns a 400 error if the id or revision is invalid and a 404 error if
+// the note or revision is not found.
+func (h NoteHandler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	revision, err := getRevision(chi.URLParam(r, "revision"))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	rev, err := h.noteService.GetRevision(ctx, getMember(r), noteid, revision)
+	if err != nil {
+		log.Println(err)
+		if !writeRevisionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: rev})
+}
+
+// DiffRevisions compares the revisions of a note given in the from and to
+// query parameters. The format parameter selects a unified diff (the
+// default) or a word diff of the content.
+// It returns a 400 error if the id, a revision or the format is invalid and
+// a 404 error if the note or a revision is not found.
+func (h NoteHandler) DiffRevisions(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query := r.URL.Query()
+	from, err := getRevision(query.Get("from"))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	to, err := getRevision(query.Get("to"))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	d, err := h.noteService.DiffRevisions(ctx, getMember(r), noteid, from, to, models.DiffFormat(query.Get("format")))
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidDiffFormat) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidDiffFormat.Error())
+			return
+		} else if !writeRevisionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: d})
+}
+
+// RestoreRevision sets a note back to the title and content of one of its
+// revisions and responds with the note and its new ETag. If the request has
+// an If-Match header, the note is only restored if it is still at that
+// version.
+// It returns a 400 error if the id or revision is invalid, a 404 error if the
+// note or revision is not found and a 412 error if the If-Match header does
+// not match.
+func (h NoteHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	revision, err := getRevision(chi.URLParam(r, "revision"))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	version, err := h.getIfMatch(ctx, r, noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeConditionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	note, err := h.noteService.RestoreRevision(ctx, getMember(r), noteid, revision, version)
+	if err != nil {
+		log.Println(err)
+		if !writeConditionError(w, err) && !writeRevisionError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("ETag", etag(note.Version))
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Revision Restored", Status: utils.StatusOk, Data: note})
+}
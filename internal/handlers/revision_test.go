@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// withRevision adds the noteId and revision URL parameters to req.
+func withRevision(req *http.Request, id string, revision string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("noteId", id)
+	rctx.URLParams.Add("revision", revision)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+var revisionTime = time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+
+func TestNoteHandler_GetRevisions(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	revisions := []*models.Revision{
+		{NoteId: 1, Version: 2, Title: "Title", Content: "Second", CreatedAt: revisionTime.Add(time.Hour)},
+		{NoteId: 1, Version: 1, Title: "Title", Content: "First", CreatedAt: revisionTime},
+	}
+	noteRepoMock.On("GetRevisions", mock.Anything, testWorkspaceId, 1).Return(revisions, nil)
+
+	req := withNoteId(newRequest(http.MethodGet, "/api/v1/notes/1/revisions", nil), "1")
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.GetRevisions(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [
+		{"note_id":1,"version":2,"title":"Title","content":"Second","created_at":"2024-05-01T10:30:00Z"},
+		{"note_id":1,"version":1,"title":"Title","content":"First","created_at":"2024-05-01T09:30:00Z"}
+	]}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_GetRevisionNotFound(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("GetRevision", mock.Anything, testWorkspaceId, 1, 7).Return((*models.Revision)(nil), &repository.RepoError{Src: "GetRevision", Id: 1, Err: repository.ErrRevisionNotFound})
+
+	// Act
+	missing := httptest.NewRecorder()
+	noteHandler.GetRevision(missing, withRevision(newRequest(http.MethodGet, "/api/v1/notes/1/revisions/7", nil), "1", "7"))
+	invalid := httptest.NewRecorder()
+	noteHandler.GetRevision(invalid, withRevision(newRequest(http.MethodGet, "/api/v1/notes/1/revisions/latest", nil), "1", "latest"))
+
+	// Assertion
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.JSONEq(t, `{"status": "error", "message": "revision not found"}`, missing.Body.String())
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_DiffRevisions(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("GetRevision", mock.Anything, testWorkspaceId, 1, 1).Return(&models.Revision{NoteId: 1, Version: 1, Title: "Groceries", Content: "apples\npears\n"}, nil)
+	noteRepoMock.On("GetRevision", mock.Anything, testWorkspaceId, 1, 3).Return(&models.Revision{NoteId: 1, Version: 3, Title: "Weekly groceries", Content: "apples\nplums\n"}, nil)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{
+			"unified",
+			"from=1&to=3",
+			http.StatusOK,
+			`{"status": "ok", "data": {"from": 1, "to": 3,
+				"title": [{"op":"delete","text":"Groceries"},{"op":"insert","text":"Weekly groceries"}],
+				"unified": "--- version 1\n+++ version 3\n@@ -1,2 +1,2 @@\n apples\n-pears\n+plums\n"}}`,
+		},
+		{
+			"words",
+			"from=1&to=3&format=words",
+			http.StatusOK,
+			`{"status": "ok", "data": {"from": 1, "to": 3,
+				"title": [{"op":"delete","text":"Groceries"},{"op":"insert","text":"Weekly groceries"}],
+				"content": [{"op":"equal","text":"apples\n"},{"op":"delete","text":"pears"},{"op":"insert","text":"plums"},{"op":"equal","text":"\n"}]}}`,
+		},
+		{"unknown format", "from=1&to=3&format=side-by-side", http.StatusBadRequest, `{"status": "error", "message": "diff format must be unified or words"}`},
+		{"missing revision", "from=1", http.StatusBadRequest, `{"status": "error", "message": "revision must be a valid integer: "}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withNoteId(newRequest(http.MethodGet, "/api/v1/notes/1/revisions/diff?"+tt.query, nil), "1")
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.DiffRevisions(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code)
+			assert.JSONEq(t, tt.body, rec.Body.String())
+		})
+	}
+}
+
+func TestNoteHandler_RestoreRevision(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("GetRevision", mock.Anything, testWorkspaceId, 1, 2).Return(&models.Revision{NoteId: 1, Version: 2, Title: "Old title", Content: "Old content"}, nil)
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, &models.Note{Title: "Old title", Content: "Old content"}, 5).
+		Run(func(args mock.Arguments) {
+			note := args.Get(3).(*models.Note)
+			note.Id, note.Version, note.CreatedAt, note.UpdatedAt = 1, 6, revisionTime, revisionTime.Add(time.Hour)
+		}).Return(nil)
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Old title", Content: "Old content", Tags: []string{"kept"}, Version: 6}, nil)
+
+	req := withRevision(newRequest(http.MethodPost, "/api/v1/notes/1/revisions/2/restore", nil), "1", "2")
+	req.Header.Set("If-Match", `"5"`)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.RestoreRevision(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `"6"`, rec.Header().Get("ETag"))
+	assert.JSONEq(t, `{"status": "ok", "message": "Revision Restored", "data": {"id":1,"title":"Old title","content":"Old content","tags":["kept"],"created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-01T10:30:00Z","version":6}}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
@@ -0,0 +1,264 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// shareRequest is the request body of the share endpoints.
+type shareRequest struct {
+	Email string      `json:"email"`
+	Role  models.Role `json:"role"`
+}
+
+// getCollaboratorId extracts the id of the user a share is revoked from
+// from the URL.
+// It returns an error if the id is not a valid integer
+func getCollaboratorId(r *http.Request) (int, error) {
+	userid := chi.URLParam(r, "userId")
+	useridAsInt, err := strconv.Atoi(userid)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidId, userid)
+	}
+	return useridAsInt, nil
+}
+
+// writeShareError responds to errors shared by the share endpoints. It
+// reports whether err was one of them.
+func writeShareError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrNoteNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrNotebookNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNotebookNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrUserNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrUserNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrShareNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrShareNotFound.Error())
+		return true
+	} else if errors.Is(err, service.ErrForbidden) {
+		utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, repository.ErrShareWithOwner) {
+		utils.ErrorResponse(w, http.StatusBadRequest, repository.ErrShareWithOwner.Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidShare) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidShare.Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidId) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+		return true
+	}
+	return false
+}
+
+// GetShares lists the owner of a note followed by the users it is shared
+// with.
+// It returns a 400 error if the id is invalid and a 404 error if the note is
+// not found.
+func (h NoteHandler) GetShares(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	shares, err := h.noteService.GetShares(ctx, getMember(r), noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeShareError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: shares})
+}
+
+// Share shares a note with the user with the email in the request body at
+// the given role, viewer or editor, and responds with the share. Sharing it
+// again with the same user changes their role.
+// It returns a 400 error if the id, email or role is invalid, a 403 error if
+// the user does not own the note and a 404 error if the note or the user to
+// share it with is not found.
+func (h NoteHandler) Share(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var body shareRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	share, err := h.noteService.Share(ctx, getMember(r), noteid, body.Email, body.Role)
+	if err != nil {
+		log.Println(err)
+		if !writeShareError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: share})
+}
+
+// Unshare stops sharing a note with a user and responds with 204 and no
+// body. Owners can revoke anyone's access, collaborators only their own.
+// It returns a 400 error if an id is invalid, a 403 error if the user may not
+// revoke the share and a 404 error if the note or share is not found.
+func (h NoteHandler) Unshare(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userid, err := getCollaboratorId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	if err := h.noteService.Unshare(ctx, getMember(r), noteid, userid); err != nil {
+		log.Println(err)
+		if !writeShareError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetShared lists the notes other users shared with the user, directly or
+// through a notebook, together with the role the user has on them.
+func (h NoteHandler) GetShared(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.context(r)
+	defer cancel()
+	notes, err := h.noteService.GetShared(ctx, getMember(r))
+	if err != nil {
+		log.Println(err)
+		writeServerError(w, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: notes})
+}
+
+// GetShares lists the owner of a notebook followed by the users it is
+// shared with.
+// It returns a 400 error if the id is invalid and a 404 error if the
+// notebook is not found.
+func (h NotebookHandler) GetShares(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	shares, err := h.notebookService.GetShares(getMember(r), notebookid)
+	if err != nil {
+		log.Println(err)
+		if !writeShareError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: shares})
+}
+
+// Share shares a notebook and everything in it with the user with the email
+// in the request body at the given role, viewer or editor, and responds with
+// the share. Sharing it again with the same user changes their role.
+// It returns a 400 error if the id, email or role is invalid, a 403 error if
+// the user does not own the notebook and a 404 error if the notebook or the
+// user to share it with is not found.
+func (h NotebookHandler) Share(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var body shareRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	share, err := h.notebookService.Share(getMember(r), notebookid, body.Email, body.Role)
+	if err != nil {
+		log.Println(err)
+		if !writeShareError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: share})
+}
+
+// Unshare stops sharing a notebook with a user and responds with 204 and no
+// body. Owners can revoke anyone's access, collaborators only their own.
+// It returns a 400 error if an id is invalid, a 403 error if the user may not
+// revoke the share and a 404 error if the notebook or share is not found.
+func (h NotebookHandler) Unshare(w http.ResponseWriter, r *http.Request) {
+	notebookid, err := getNotebookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	userid, err := getCollaboratorId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.notebookService.Unshare(getMember(r), notebookid, userid); err != nil {
+		log.Println(err)
+		if !writeShareError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetShared lists the notebooks other users shared with the user, directly
+// or through a notebook above them, together with the role the user has on
+// them.
+func (h NotebookHandler) GetShared(w http.ResponseWriter, r *http.Request) {
+	notebooks, err := h.notebookService.GetShared(getMember(r))
+	if err != nil {
+		log.Println(err)
+		writeServerError(w, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: notebooks})
+}
@@ -0,0 +1,244 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// ownNotes makes the user of newRequest the owner of every note and notebook
+// noteRepoMock is asked about.
+func ownNotes(noteRepoMock *mocks.NoteRepoMock) {
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, mock.Anything).Return(models.RoleOwner, nil).Maybe()
+	noteRepoMock.On("GetNotebookAccess", mock.Anything, testWorkspaceId, testUserId, mock.Anything).Return(models.RoleOwner, nil).Maybe()
+}
+
+// ownNotebooks makes the user of newRequest the owner of every notebook
+// notebookRepoMock is asked about.
+func ownNotebooks(notebookRepoMock *mocks.NotebookRepoMock) {
+	notebookRepoMock.On("GetNotebookAccess", testWorkspaceId, testUserId, mock.Anything).Return(models.RoleOwner, nil).Maybe()
+}
+
+// withShare sets the URL parameters of a request to a share endpoint, the
+// id of the note or notebook under key and the id of the user.
+func withShare(req *http.Request, key string, id string, userId string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add(key, id)
+	rctx.URLParams.Add("userId", userId)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestNoteHandler_SharedAccess(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.RoleViewer, nil)
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 2).Return(models.RoleEditor, nil)
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 3).Return(models.Role(""), &repository.RepoError{Src: "GetNoteAccess", Id: 3, Err: repository.ErrNoteNotFound})
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Shared", Content: "Shared note", Version: 1}, nil)
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 2, mock.Anything, 0).Return(nil)
+
+	body := `{"title": "Shared", "content": "Changed"}`
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		id      string
+		status  int
+	}{
+		{"viewer gets", noteHandler.Get, http.MethodGet, "1", http.StatusOK},
+		{"viewer updates", noteHandler.Update, http.MethodPut, "1", http.StatusForbidden},
+		{"editor updates", noteHandler.Update, http.MethodPut, "2", http.StatusOK},
+		{"editor deletes", noteHandler.Delete, http.MethodDelete, "2", http.StatusForbidden},
+		{"no access gets", noteHandler.Get, http.MethodGet, "3", http.StatusNotFound},
+		{"no access updates", noteHandler.Update, http.MethodPut, "3", http.StatusNotFound},
+		{"no access deletes", noteHandler.Delete, http.MethodDelete, "3", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/notes/"+tt.id, strings.NewReader(body))
+			req = withNoteId(withRole(req, models.RoleViewer), tt.id)
+			rec := httptest.NewRecorder()
+
+			// Act
+			tt.handler(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	noteRepoMock.AssertNumberOfCalls(t, "Update", 1)
+}
+
+func TestNoteHandler_Share(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.RoleOwner, nil)
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 2).Return(models.RoleEditor, nil)
+	noteRepoMock.On("ShareNote", mock.Anything, testWorkspaceId, 1, "grace@example.com", models.RoleEditor).
+		Return(&models.Share{UserId: 8, Email: "grace@example.com", Role: models.RoleEditor}, nil)
+	noteRepoMock.On("ShareNote", mock.Anything, testWorkspaceId, 1, "nobody@example.com", models.RoleViewer).
+		Return((*models.Share)(nil), &repository.RepoError{Src: "ShareNote", Id: 1, Err: repository.ErrUserNotFound})
+	noteRepoMock.On("ShareNote", mock.Anything, testWorkspaceId, 1, "ada@example.com", models.RoleViewer).
+		Return((*models.Share)(nil), &repository.RepoError{Src: "ShareNote", Id: 1, Err: repository.ErrShareWithOwner})
+
+	tests := []struct {
+		name   string
+		id     string
+		body   string
+		status int
+	}{
+		{"owner shares", "1", `{"email": " grace@example.com ", "role": "editor"}`, http.StatusOK},
+		{"unknown user", "1", `{"email": "nobody@example.com", "role": "viewer"}`, http.StatusNotFound},
+		{"with owner", "1", `{"email": "ada@example.com", "role": "viewer"}`, http.StatusBadRequest},
+		{"owner role", "1", `{"email": "grace@example.com", "role": "owner"}`, http.StatusBadRequest},
+		{"no email", "1", `{"role": "viewer"}`, http.StatusBadRequest},
+		{"editor shares", "2", `{"email": "grace@example.com", "role": "viewer"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/notes/"+tt.id+"/shares", strings.NewReader(tt.body))
+			req = withNoteId(withRole(req, models.RoleEditor), tt.id)
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.Share(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNumberOfCalls(t, "ShareNote", 3)
+}
+
+func TestNoteHandler_Unshare(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.RoleOwner, nil)
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 2).Return(models.RoleViewer, nil)
+	noteRepoMock.On("RevokeNoteShare", mock.Anything, testWorkspaceId, 1, 8).Return(nil)
+	noteRepoMock.On("RevokeNoteShare", mock.Anything, testWorkspaceId, 1, 5).Return(&repository.RepoError{Src: "RevokeNoteShare", Id: 1, Err: repository.ErrShareNotFound})
+	noteRepoMock.On("RevokeNoteShare", mock.Anything, testWorkspaceId, 2, testUserId).Return(nil)
+
+	tests := []struct {
+		name   string
+		id     string
+		userId string
+		status int
+	}{
+		{"owner revokes", "1", "8", http.StatusNoContent},
+		{"not shared", "1", "5", http.StatusNotFound},
+		{"viewer leaves", "2", "7", http.StatusNoContent},
+		{"viewer revokes other", "2", "8", http.StatusForbidden},
+		{"invalid user id", "1", "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withRole(httptest.NewRequest(http.MethodDelete, "/api/v1/notes/"+tt.id+"/shares/"+tt.userId, nil), models.RoleViewer)
+			req = withShare(req, "noteId", tt.id, tt.userId)
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.Unshare(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNumberOfCalls(t, "RevokeNoteShare", 3)
+}
+
+func TestNotebookHandler_SharedAccess(t *testing.T) {
+	// Arrange
+	notebookRepoMock := &mocks.NotebookRepoMock{}
+	noteRepoMock := &mocks.NoteRepoMock{}
+	notebookHandler := newNotebookHandler(notebookRepoMock, noteRepoMock)
+
+	notebookRepoMock.On("GetNotebookAccess", testWorkspaceId, testUserId, 4).Return(models.RoleViewer, nil)
+	notebookRepoMock.On("Get", testWorkspaceId, 4).Return(&models.Notebook{Id: 4, Name: "Team", Path: "/4/"}, nil)
+	noteRepoMock.On("GetNotebookAccess", mock.Anything, testWorkspaceId, testUserId, 4).Return(models.RoleViewer, nil)
+	noteRepoMock.On("GetAll", mock.Anything, testWorkspaceId, mock.MatchedBy(func(opts models.ListOptions) bool { return opts.NotebookId == 4 })).
+		Return(&models.NotePage{Notes: []*models.Note{{Id: 1, Title: "Shared"}}}, nil)
+
+	// Act
+	notes := httptest.NewRecorder()
+	notebookHandler.GetNotes(notes, withNotebookId(withRole(httptest.NewRequest(http.MethodGet, "/api/v1/notebooks/4/notes", nil), models.RoleViewer), "4"))
+	renamed := httptest.NewRecorder()
+	notebookHandler.Rename(renamed, withNotebookId(withRole(httptest.NewRequest(http.MethodPut, "/api/v1/notebooks/4", strings.NewReader(`{"name": "Mine"}`)), models.RoleViewer), "4"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, notes.Code, notes.Body.String())
+	var res struct{ Data []*models.Note }
+	assert.NoError(t, json.Unmarshal(notes.Body.Bytes(), &res))
+	assert.Len(t, res.Data, 1)
+	assert.Equal(t, http.StatusForbidden, renamed.Code)
+	notebookRepoMock.AssertNotCalled(t, "Rename", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNoteHandler_GetShared(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	shared := []*models.SharedNote{{Note: &models.Note{Id: 1, Title: "Shared"}, Owner: "grace@example.com", Role: models.RoleEditor}}
+	noteRepoMock.On("GetSharedNotes", mock.Anything, testWorkspaceId, testUserId).Return(shared, nil)
+
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.GetShared(rec, newRequest(http.MethodGet, "/api/v1/shared/notes", nil))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var res struct{ Data []map[string]interface{} }
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, "Shared", res.Data[0]["title"])
+	assert.Equal(t, "grace@example.com", res.Data[0]["owner"])
+	assert.Equal(t, "editor", res.Data[0]["role"])
+}
+
+func TestNoteHandler_Guest(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	note := &models.Note{Id: 1, Title: "Shared", Content: "For Grace", Version: 1}
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.RoleViewer, nil)
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(note, nil)
+
+	req := withRole(httptest.NewRequest(http.MethodGet, "/api/v1/notes/1", nil), "")
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("noteId", "1")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	getRec := httptest.NewRecorder()
+	listRec := httptest.NewRecorder()
+	tagsRec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Get(getRec, req)
+	noteHandler.GetAll(listRec, withRole(httptest.NewRequest(http.MethodGet, "/api/v1/notes", nil), ""))
+	noteHandler.GetTags(tagsRec, withRole(httptest.NewRequest(http.MethodGet, "/api/v1/tags", nil), ""))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, getRec.Code)
+	assert.Equal(t, http.StatusForbidden, listRec.Code)
+	assert.Equal(t, http.StatusForbidden, tagsRec.Code)
+	noteRepoMock.AssertExpectations(t)
+}
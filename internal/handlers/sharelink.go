@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// ShareLinkPath is the path share links are viewed under, followed by their
+// token.
+const ShareLinkPath = "/s/"
+
+// ErrTooManyAttempts is returned when a share link or a client sent too many
+// wrong passwords.
+var ErrTooManyAttempts = errors.New("too many wrong passwords, try again later")
+
+// shareLinkRequest is the request body of CreateShareLink.
+type shareLinkRequest struct {
+	ExpiresAt *time.Time `json:"expires_at"`
+	MaxViews  *int       `json:"max_views"`
+	Password  string     `json:"password"`
+}
+
+// publicNoteTemplate renders a note viewed through a share link as HTML.
+var publicNoteTemplate = template.Must(template.New("note").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { max-width: 40rem; margin: 2rem auto; padding: 0 1rem; font-family: sans-serif; line-height: 1.5; }
+pre { white-space: pre-wrap; font-family: inherit; }
+.meta { color: #666; font-size: 0.875rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Tags}}<p class="meta">{{range .Tags}}#{{.}} {{end}}</p>{{end}}
+<pre>{{.Content}}</pre>
+<p class="meta">Last updated {{.UpdatedAt.Format "2 January 2006 15:04 MST"}}</p>
+</body>
+</html>
+`))
+
+// wantsHTML reports whether a share link should be rendered as HTML: if the
+// format query parameter is html, or if there is none and the Accept header
+// asks for HTML, as browsers do.
+func wantsHTML(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "html"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// clientAddr returns the address of the client of r, without its port. It is
+// the address set by a trusted proxy if the server runs behind one.
+func clientAddr(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// writeShareLinkError responds to errors shared by the share link
+// endpoints. It reports whether err was one of them.
+func writeShareLinkError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrNoteNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrShareLinkNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrShareLinkNotFound.Error())
+		return true
+	} else if errors.Is(err, service.ErrForbidden) {
+		utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidShareLink) {
+		utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidId) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+		return true
+	}
+	return false
+}
+
+// CreateShareLink creates a share link to a note with the optional
+// expires_at, max_views and password in the request body and responds with
+// it. The response is the only time the token of the link is shown.
+// It returns a 400 error if the id, expiry, view limit or password is
+// invalid, a 403 error if the user does not own the note and a 404 error if
+// the note is not found.
+func (h NoteHandler) CreateShareLink(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var body shareLinkRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	link := &models.ShareLink{ExpiresAt: body.ExpiresAt, MaxViews: body.MaxViews}
+	ctx, cancel := h.context(r)
+	defer cancel()
+	created, err := h.noteService.CreateShareLink(ctx, getMember(r), noteid, link, body.Password)
+	if err != nil {
+		log.Println(err)
+		if !writeShareLinkError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	created.URL = ShareLinkPath + created.Token
+	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: created})
+}
+
+// GetShareLinks lists the share links of a note that can still be viewed,
+// without their tokens.
+// It returns a 400 error if the id is invalid, a 403 error if the user does
+// not own the note and a 404 error if the note is not found.
+func (h NoteHandler) GetShareLinks(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	links, err := h.noteService.GetShareLinks(ctx, getMember(r), noteid)
+	if err != nil {
+		log.Println(err)
+		if !writeShareLinkError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: links})
+}
+
+// DeleteShareLink revokes a share link of a note and responds with 204 and
+// no body.
+// It returns a 400 error if an id is invalid, a 403 error if the user does
+// not own the note and a 404 error if the note or link is not found.
+func (h NoteHandler) DeleteShareLink(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	linkid, err := strconv.Atoi(chi.URLParam(r, "linkId"))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, fmt.Errorf("%w: %v", ErrInvalidId, chi.URLParam(r, "linkId")).Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	if err := h.noteService.DeleteShareLink(ctx, getMember(r), noteid, linkid); err != nil {
+		log.Println(err)
+		if !writeShareLinkError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ViewShareLink responds with the note a share link points to, as HTML if
+// the client asks for it and as JSON otherwise. It needs no account; the
+// password of a protected link is sent with HTTP Basic authentication and
+// any user name. Requests with a password count against ShareLinkAttempts
+// for the link and the client address unless the password is right, and
+// are refused before the password is checked once either has no attempts
+// left, so that passwords cannot be guessed and checking them, which is
+// slow on purpose, cannot be used to overload the server.
+// It returns a 401 error if the password is missing or wrong, a 404 error
+// if the link is unknown, expired or used up and a 429 error if there were
+// too many wrong passwords.
+func (h NoteHandler) ViewShareLink(w http.ResponseWriter, r *http.Request) {
+	// Shared notes must not end up in caches, search engines or the referrer
+	// of links in them.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+
+	token := chi.URLParam(r, "token")
+	_, password, _ := r.BasicAuth()
+	var attempt []string
+	if password != "" && h.ShareLinkAttempts != nil {
+		attempt = []string{"link:" + auth.HashToken(token), "client:" + clientAddr(r)}
+		ok, wait := h.ShareLinkAttempts.Begin(attempt...)
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Round(time.Second).Seconds())))
+			utils.ErrorResponse(w, http.StatusTooManyRequests, ErrTooManyAttempts.Error())
+			return
+		}
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	note, err := h.noteService.ViewShareLink(ctx, token, password)
+	if attempt != nil && !errors.Is(err, service.ErrInvalidSharePassword) {
+		h.ShareLinkAttempts.Refund(attempt...)
+	}
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidSharePassword) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="notes-api share link", charset="UTF-8"`)
+			utils.ErrorResponse(w, http.StatusUnauthorized, service.ErrInvalidSharePassword.Error())
+			return
+		} else if errors.Is(err, repository.ErrShareLinkNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrShareLinkNotFound.Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+
+	if !wantsHTML(r) {
+		utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: note})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	if err := publicNoteTemplate.Execute(w, note); err != nil {
+		log.Println(err)
+	}
+}
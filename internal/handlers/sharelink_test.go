@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// newShareLinkHandler returns a NoteHandler backed by noteRepoMock that
+// hashes share link passwords cheaply.
+func newShareLinkHandler(noteRepoMock *mocks.NoteRepoMock) *NoteHandler {
+	noteService := service.NewNoteService(noteRepoMock)
+	noteService.Params = testParams
+	return NewNoteHandler(noteService)
+}
+
+// withToken sets the token URL parameter of a request to a share link.
+func withToken(req *http.Request, token string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("token", token)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestNoteHandler_CreateShareLink(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := newShareLinkHandler(noteRepoMock)
+
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.RoleOwner, nil)
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 2).Return(models.RoleEditor, nil)
+	noteRepoMock.On("CreateShareLink", mock.Anything, testWorkspaceId, 1, mock.AnythingOfType("*models.ShareLink"), mock.AnythingOfType("string"), mock.AnythingOfType("string")).
+		Run(func(args mock.Arguments) { args.Get(3).(*models.ShareLink).Id = 3 }).Return(3, nil)
+
+	tests := []struct {
+		name   string
+		id     string
+		body   string
+		status int
+	}{
+		{"valid", "1", `{"expires_at": "2999-01-01T00:00:00Z", "max_views": 5, "password": "correct horse"}`, http.StatusCreated},
+		{"expired", "1", `{"expires_at": "2000-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"no views", "1", `{"max_views": 0}`, http.StatusBadRequest},
+		{"short password", "1", `{"password": "short"}`, http.StatusBadRequest},
+		{"not owner", "2", `{}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/notes/"+tt.id+"/share-links", strings.NewReader(tt.body))
+			req = withNoteId(withRole(req, models.RoleEditor), tt.id)
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.CreateShareLink(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNumberOfCalls(t, "CreateShareLink", 1)
+
+	stored := noteRepoMock.Calls[1].Arguments
+	link := stored.Get(3).(*models.ShareLink)
+	assert.True(t, strings.HasPrefix(link.Token, link.Prefix))
+	assert.Equal(t, 5, *link.MaxViews)
+	assert.Equal(t, auth.HashToken(link.Token), stored.String(4))
+	ok, err := auth.CheckPassword(stored.String(5), "correct horse")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestNoteHandler_ViewShareLink(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := newShareLinkHandler(noteRepoMock)
+
+	passwordHash, err := auth.HashPassword("correct horse", testParams)
+	require.NoError(t, err)
+	note := &models.Note{Id: 1, Title: "<b>Plan</b>", Content: "Step 1", Tags: []string{"work"}, UpdatedAt: time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)}
+	noteRepoMock.On("GetShareLink", mock.Anything, auth.HashToken("open")).Return(&models.ShareLink{Id: 3, NoteId: 1}, "", nil)
+	noteRepoMock.On("GetShareLink", mock.Anything, auth.HashToken("locked")).Return(&models.ShareLink{Id: 4, NoteId: 1, HasPassword: true}, passwordHash, nil)
+	noteRepoMock.On("GetShareLink", mock.Anything, auth.HashToken("gone")).
+		Return((*models.ShareLink)(nil), "", &repository.RepoError{Src: "GetShareLink", Err: repository.ErrShareLinkNotFound})
+	noteRepoMock.On("ViewShareLink", mock.Anything, 3).Return(note, nil)
+	noteRepoMock.On("ViewShareLink", mock.Anything, 4).Return(note, nil)
+
+	view := func(token string, accept string, password string) *httptest.ResponseRecorder {
+		req := withToken(httptest.NewRequest(http.MethodGet, "/s/"+token, nil), token)
+		req.Header.Set("Accept", accept)
+		if password != "" {
+			req.SetBasicAuth("", password)
+		}
+		rec := httptest.NewRecorder()
+		noteHandler.ViewShareLink(rec, req)
+		return rec
+	}
+
+	// Act
+	asJSON := view("open", "application/json", "")
+	asHTML := view("open", "text/html,application/xhtml+xml", "")
+	noPassword := view("locked", "", "")
+	wrongPassword := view("locked", "", "wrong horse")
+	rightPassword := view("locked", "", "correct horse")
+	gone := view("gone", "", "")
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, asJSON.Code)
+	assert.Equal(t, "no-store", asJSON.Header().Get("Cache-Control"))
+	var res struct{ Data map[string]interface{} }
+	assert.NoError(t, json.Unmarshal(asJSON.Body.Bytes(), &res))
+	assert.Equal(t, "<b>Plan</b>", res.Data["title"])
+	assert.NotContains(t, res.Data, "id")
+
+	assert.Equal(t, http.StatusOK, asHTML.Code)
+	assert.Equal(t, "text/html; charset=utf-8", asHTML.Header().Get("Content-Type"))
+	assert.Contains(t, asHTML.Body.String(), "<h1>&lt;b&gt;Plan&lt;/b&gt;</h1>")
+	assert.Contains(t, asHTML.Body.String(), "#work")
+
+	assert.Equal(t, http.StatusUnauthorized, noPassword.Code)
+	assert.Contains(t, noPassword.Header().Get("WWW-Authenticate"), "Basic")
+	assert.Equal(t, http.StatusUnauthorized, wrongPassword.Code)
+	assert.Equal(t, http.StatusOK, rightPassword.Code)
+	assert.Equal(t, http.StatusNotFound, gone.Code)
+	noteRepoMock.AssertNumberOfCalls(t, "ViewShareLink", 3)
+}
+
+func TestNoteHandler_ViewShareLinkAttempts(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := newShareLinkHandler(noteRepoMock)
+	noteHandler.ShareLinkAttempts = auth.NewAttemptLimiter(2, time.Minute)
+
+	passwordHash, err := auth.HashPassword("correct horse", testParams)
+	require.NoError(t, err)
+	note := &models.Note{Id: 1, Title: "Plan", Content: "Step 1"}
+	for _, token := range []string{"locked", "other"} {
+		noteRepoMock.On("GetShareLink", mock.Anything, auth.HashToken(token)).Return(&models.ShareLink{Id: 4, NoteId: 1, HasPassword: true}, passwordHash, nil)
+	}
+	noteRepoMock.On("ViewShareLink", mock.Anything, 4).Return(note, nil)
+
+	view := func(token string, addr string, password string) *httptest.ResponseRecorder {
+		req := withToken(httptest.NewRequest(http.MethodGet, "/s/"+token, nil), token)
+		req.RemoteAddr = addr
+		if password != "" {
+			req.SetBasicAuth("", password)
+		}
+		rec := httptest.NewRecorder()
+		noteHandler.ViewShareLink(rec, req)
+		return rec
+	}
+
+	// Act
+	right := view("locked", "192.0.2.1:1234", "correct horse")
+	noPassword := view("locked", "192.0.2.1:1234", "")
+	first := view("locked", "192.0.2.1:1234", "wrong horse")
+	second := view("locked", "198.51.100.7:1234", "wrong horse")
+	sameLink := view("locked", "203.0.113.9:1234", "correct horse")
+	sameClient := view("other", "192.0.2.1:5678", "wrong horse")
+	otherClient := view("other", "203.0.113.9:1234", "correct horse")
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, right.Code)
+	assert.Equal(t, http.StatusUnauthorized, noPassword.Code)
+	assert.Equal(t, http.StatusUnauthorized, first.Code)
+	assert.Equal(t, http.StatusUnauthorized, second.Code)
+	assert.Equal(t, http.StatusTooManyRequests, sameLink.Code)
+	assert.Equal(t, "60", sameLink.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"status": "error", "message": "too many wrong passwords, try again later"}`, sameLink.Body.String())
+	assert.Equal(t, http.StatusUnauthorized, sameClient.Code)
+	assert.Equal(t, http.StatusOK, otherClient.Code)
+	noteRepoMock.AssertNumberOfCalls(t, "GetShareLink", 6)
+}
+
+func TestNoteHandler_GetAndDeleteShareLinks(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := newShareLinkHandler(noteRepoMock)
+
+	noteRepoMock.On("GetShareLinks", mock.Anything, testWorkspaceId, 1).Return([]*models.ShareLink{{Id: 3, NoteId: 1, Prefix: "abcdefgh"}}, nil)
+	noteRepoMock.On("DeleteShareLink", mock.Anything, testWorkspaceId, 1, 3).Return(nil)
+	noteRepoMock.On("DeleteShareLink", mock.Anything, testWorkspaceId, 1, 4).Return(&repository.RepoError{Src: "DeleteShareLink", Id: 4, Err: repository.ErrShareLinkNotFound})
+
+	withLinkId := func(id string) *http.Request {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("noteId", "1")
+		rctx.URLParams.Add("linkId", id)
+		req := newRequest(http.MethodDelete, "/api/v1/notes/1/share-links/"+id, nil)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	}
+
+	// Act
+	list := httptest.NewRecorder()
+	noteHandler.GetShareLinks(list, withNoteId(newRequest(http.MethodGet, "/api/v1/notes/1/share-links", nil), "1"))
+	deleted := httptest.NewRecorder()
+	noteHandler.DeleteShareLink(deleted, withLinkId("3"))
+	missing := httptest.NewRecorder()
+	noteHandler.DeleteShareLink(missing, withLinkId("4"))
+	invalid := httptest.NewRecorder()
+	noteHandler.DeleteShareLink(invalid, withLinkId("abc"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, list.Code)
+	var res struct{ Data []map[string]interface{} }
+	assert.NoError(t, json.Unmarshal(list.Body.Bytes(), &res))
+	assert.Equal(t, "abcdefgh", res.Data[0]["prefix"])
+	assert.NotContains(t, res.Data[0], "token")
+	assert.Equal(t, http.StatusNoContent, deleted.Code)
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+)
+
+// ErrInvalidSince is returned when the since query parameter is not a valid
+// integer
+var ErrInvalidSince = errors.New("since must be a valid integer")
+
+// pushRequest is the body of a push of offline changes.
+type pushRequest struct {
+	Changes []*models.PushChange `json:"changes"`
+}
+
+// GetChanges retrieves the changes to the notes after the sequence number in
+// the since query parameter, or all notes if it is missing, in order. The
+// seq of the response is passed as since to get the next page, and later
+// the changes made in the meantime.
+// It returns a 400 error if since or the limit is invalid and a 403 error if
+// the user is a guest of the workspace.
+func (h NoteHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var since int64
+	if value := query.Get("since"); value != "" {
+		var err error
+		if since, err = strconv.ParseInt(value, 10, 64); err != nil {
+			log.Println(err)
+			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("%s: %v", ErrInvalidSince, value))
+			return
+		}
+	}
+	var limit int
+	if value := query.Get("limit"); value != "" {
+		var err error
+		if limit, err = strconv.Atoi(value); err != nil {
+			log.Println(err)
+			utils.ErrorResponse(w, http.StatusBadRequest, fmt.Sprintf("%s: %v", ErrInvalidLimit, value))
+			return
+		}
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	page, err := h.noteService.GetChanges(ctx, getMember(r), since, limit)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidSync) || errors.Is(err, service.ErrInvalidListOptions) {
+			utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: page})
+}
+
+// Push applies the changes in the request body that a client made while it
+// was offline and responds with the result of each, in order. Changes that
+// conflict with changes on the server are not applied and their results
+// carry the current note.
+// It returns a 400 error if the changes are malformed or a note is invalid,
+// in which case none are applied, and a 403 error if an API key without the
+// notes:delete scope deletes notes.
+func (h NoteHandler) Push(w http.ResponseWriter, r *http.Request) {
+	body := &pushRequest{}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+	if key := getAPIKey(r); key != nil && !key.HasScope(models.ScopeNotesDelete) {
+		for _, change := range body.Changes {
+			if change != nil && change.Deleted {
+				writeScopeError(w, models.ScopeNotesDelete)
+				return
+			}
+		}
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	results, err := h.noteService.Push(ctx, getMember(r), body.Changes)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidSync) || errors.Is(err, service.ErrInvalidNote) ||
+			errors.Is(err, service.ErrInvalidTag) || errors.Is(err, service.ErrInvalidId) {
+			// The message names the change that is invalid.
+			utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: results})
+}
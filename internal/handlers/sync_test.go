@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoteHandler_GetChanges(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	updated := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	noteRepoMock.On("GetChanges", mock.Anything, testWorkspaceId, int64(0), service.DefaultChangesPageSize).Return(&models.ChangePage{
+		Changes: []*models.Change{
+			{Seq: 4, NoteId: 1, Note: &models.Note{Id: 1, Title: "Plan", Content: "Ship it", CreatedAt: updated, UpdatedAt: updated, Version: 2}},
+			{Seq: 6, NoteId: 2, Deleted: true, DeletedAt: &updated},
+		},
+		Seq: 6,
+	}, nil)
+	noteRepoMock.On("GetChanges", mock.Anything, testWorkspaceId, int64(6), 10).Return(&models.ChangePage{Changes: []*models.Change{}, Seq: 6}, nil)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		body   string
+	}{
+		{"all notes", "", http.StatusOK, `{"status": "ok", "data": {"changes": [
+			{"seq": 4, "note_id": 1, "deleted": false, "note": {"id": 1, "title": "Plan", "content": "Ship it",
+				"created_at": "2024-05-01T09:30:00Z", "updated_at": "2024-05-01T09:30:00Z", "version": 2}},
+			{"seq": 6, "note_id": 2, "deleted": true, "deleted_at": "2024-05-01T09:30:00Z"}], "seq": 6, "has_more": false}}`},
+		{"since", "?since=6&limit=10", http.StatusOK, `{"status": "ok", "data": {"changes": [], "seq": 6, "has_more": false}}`},
+		{"invalid since", "?since=abc", http.StatusBadRequest, ""},
+		{"negative since", "?since=-1", http.StatusBadRequest, ""},
+		{"invalid limit", "?limit=abc", http.StatusBadRequest, ""},
+		{"limit too large", "?limit=1001", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.GetChanges(rec, newRequest(http.MethodGet, "/api/v1/sync"+tt.query, nil))
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			if tt.body != "" {
+				assert.JSONEq(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_Push(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	current := &models.Note{Id: 2, Title: "Theirs", Content: "Changed on the server", Version: 4}
+	noteRepoMock.On("GetPushedNote", mock.Anything, testWorkspaceId, testUserId, "offline-1").
+		Return((*models.Note)(nil), &repository.RepoError{Src: "GetPushedNote", Err: repository.ErrNoteNotFound})
+	noteRepoMock.On("Create", mock.Anything, testWorkspaceId, testUserId, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(3).(*models.Note).Version = 1
+	}).Return(10, nil)
+	noteRepoMock.On("SetClientId", mock.Anything, testWorkspaceId, 10, "offline-1").Return(nil)
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 1, mock.Anything, 2).Run(func(args mock.Arguments) {
+		args.Get(3).(*models.Note).Version = 3
+	}).Return(nil)
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 2, mock.Anything, 1).
+		Return(&repository.RepoError{Src: "UpdateNoteByID", Id: 2, Err: repository.ErrVersionConflict})
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 2).Return(current, nil)
+	noteRepoMock.On("Delete", mock.Anything, testWorkspaceId, 3, 1).Return(&repository.RepoError{Src: "DeleteNoteByID", Id: 3, Err: repository.ErrNoteNotFound})
+	noteRepoMock.On("Update", mock.Anything, testWorkspaceId, 5, mock.Anything, 1).
+		Return(&repository.RepoError{Src: "UpdateNoteByID", Id: 5, Err: repository.ErrNoteNotFound})
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 5).Return((*models.Note)(nil), &repository.RepoError{Src: "GetNoteByID", Id: 5, Err: repository.ErrNoteNotFound})
+
+	body := `{"changes": [
+		{"client_id": "offline-1", "note": {"title": "New", "content": "Written offline"}},
+		{"id": 1, "version": 2, "note": {"title": "Mine", "content": "Changed offline"}},
+		{"id": 2, "version": 1, "note": {"title": "Mine", "content": "Changed offline"}},
+		{"id": 3, "version": 1, "deleted": true},
+		{"id": 5, "version": 1, "note": {"title": "Gone", "content": "Deleted on the server"}}
+	]}`
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Push(rec, newRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(body)))
+
+	// Assertion
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var res struct {
+		Data []*models.PushResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	require.Len(t, res.Data, 5)
+	assert.Equal(t, "offline-1", res.Data[0].ClientId)
+	assert.Equal(t, 10, res.Data[0].Id)
+	assert.Equal(t, models.PushApplied, res.Data[0].Status)
+	assert.Equal(t, 1, res.Data[0].Note.Version)
+	assert.Equal(t, models.PushApplied, res.Data[1].Status)
+	assert.Equal(t, 3, res.Data[1].Note.Version)
+	assert.Equal(t, models.PushConflict, res.Data[2].Status)
+	assert.Equal(t, current, res.Data[2].Note)
+	assert.Equal(t, &models.PushResult{Id: 3, Status: models.PushApplied}, res.Data[3])
+	assert.Equal(t, &models.PushResult{Id: 5, Status: models.PushConflict}, res.Data[4])
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_PushReplayed(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	pushed := &models.Note{Id: 10, Title: "New", Content: "Changed since", Version: 2}
+	noteRepoMock.On("GetPushedNote", mock.Anything, testWorkspaceId, testUserId, "offline-1").
+		Return((*models.Note)(nil), &repository.RepoError{Src: "GetPushedNote", Err: repository.ErrNoteNotFound}).Once()
+	noteRepoMock.On("Create", mock.Anything, testWorkspaceId, testUserId, mock.Anything).Run(func(args mock.Arguments) {
+		args.Get(3).(*models.Note).Version = 1
+	}).Return(10, nil).Once()
+	noteRepoMock.On("SetClientId", mock.Anything, testWorkspaceId, 10, "offline-1").Return(nil).Once()
+	noteRepoMock.On("GetPushedNote", mock.Anything, testWorkspaceId, testUserId, "offline-1").Return(pushed, nil).Once()
+
+	body := `{"changes": [{"client_id": "offline-1", "note": {"title": "New", "content": "Written offline"}}]}`
+	push := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		noteHandler.Push(rec, newRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(body)))
+		return rec
+	}
+
+	// Act
+	first := push()
+	again := push()
+
+	// Assertion
+	require.Equal(t, http.StatusOK, first.Code, first.Body.String())
+	require.Equal(t, http.StatusOK, again.Code, again.Body.String())
+	var res struct {
+		Data []*models.PushResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(first.Body.Bytes(), &res))
+	require.Len(t, res.Data, 1)
+	assert.Equal(t, 10, res.Data[0].Id)
+	assert.Equal(t, models.PushApplied, res.Data[0].Status)
+	require.NoError(t, json.Unmarshal(again.Body.Bytes(), &res))
+	require.Len(t, res.Data, 1)
+	assert.Equal(t, &models.PushResult{ClientId: "offline-1", Id: 10, Status: models.PushApplied, Note: pushed}, res.Data[0])
+	noteRepoMock.AssertNumberOfCalls(t, "Create", 1)
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_PushRejected(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	body := `{"changes": [{"client_id": "offline-1", "note": {"title": "New", "content": "Written offline"}}]}`
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Push(rec, withRole(httptest.NewRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(body)), models.RoleViewer))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [{"client_id": "offline-1", "id": 0, "status": "rejected",
+		"error": "insufficient permission: editor role required"}]}`, rec.Body.String())
+	noteRepoMock.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNoteHandler_PushInvalid(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"no changes", `{"changes": []}`},
+		{"too many changes", `{"changes": [` + strings.Repeat(`{"id": 1, "version": 1, "deleted": true},`, service.MaxPushChanges) + `{"id": 1, "version": 1, "deleted": true}]}`},
+		{"null change", `{"changes": [null]}`},
+		{"update without version", `{"changes": [{"id": 1, "note": {"title": "Mine", "content": "Changed offline"}}]}`},
+		{"delete without id", `{"changes": [{"deleted": true}]}`},
+		{"negative id", `{"changes": [{"id": -1, "version": 1, "deleted": true}]}`},
+		{"note without title", `{"changes": [{"id": 1, "version": 1, "note": {"content": "Changed offline"}}]}`},
+		{"invalid tag", `{"changes": [{"note": {"title": "New", "content": "Written offline", "tags": [" "]}}]}`},
+		{"valid change before invalid one", `{"changes": [{"note": {"title": "New", "content": "Written offline"}}, {"note": {}}]}`},
+		{"invalid json", `{"changes":`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.Push(rec, newRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(tt.body)))
+
+			// Assertion
+			assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	noteRepoMock.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestNoteHandler_PushDeleteScope(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	ownNotes(noteRepoMock)
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	body := `{"changes": [{"id": 3, "version": 1, "deleted": true}]}`
+	key := &models.APIKey{Id: 1, Scopes: []models.Scope{models.ScopeNotesRead, models.ScopeNotesWrite}}
+	req := newRequest(http.MethodPost, "/api/v1/sync", strings.NewReader(body))
+	req = req.WithContext(contextWithAPIKey(req.Context(), key))
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.Push(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Header().Get("WWW-Authenticate"), `scope="notes:delete"`)
+	noteRepoMock.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// tagChange is the response to renaming or merging a tag.
+type tagChange struct {
+	Notes int `json:"notes"`
+}
+
+// writeTagError responds to errors shared by the tag endpoints. It reports
+// whether err was one of them.
+func writeTagError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrTagNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrTagNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrTagExists) {
+		utils.ErrorResponse(w, http.StatusConflict, repository.ErrTagExists.Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidTag) {
+		utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrForbidden) {
+		utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		return true
+	}
+	return false
+}
+
+// GetTags lists all tags in use, ordered by name, along with the number of
+// notes carrying them.
+// It returns a 403 error if the user is a guest of the workspace.
+func (h NoteHandler) GetTags(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := h.context(r)
+	defer cancel()
+	tags, err := h.noteService.GetTags(ctx, getMember(r))
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: tags})
+}
+
+// RenameTag renames a tag on all notes carrying it to the name in the
+// request body and responds with the number of notes changed.
+// It returns a 400 error if a name is invalid, a 403 error if the user is a
+// viewer of the workspace, a 404 error if the tag is not found and a 409
+// error if a tag with the new name already exists.
+func (h NoteHandler) RenameTag(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Name string `json:"name"`
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	n, err := h.noteService.RenameTag(ctx, getMember(r), chi.URLParam(r, "tag"), body.Name)
+	if err != nil {
+		log.Println(err)
+		if !writeTagError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Tag Renamed", Status: utils.StatusOk, Data: tagChange{n}})
+}
+
+// MergeTags replaces a tag with the tag named by into in the request body on
+// all notes carrying it and responds with the number of notes changed.
+// It returns a 400 error if a name is invalid, a 403 error if the user is a
+// viewer of the workspace and a 404 error if either tag is not found.
+func (h NoteHandler) MergeTags(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Into string `json:"into"`
+	}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	n, err := h.noteService.MergeTags(ctx, getMember(r), chi.URLParam(r, "tag"), body.Into)
+	if err != nil {
+		log.Println(err)
+		if !writeTagError(w, err) {
+			writeServerError(w, err)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Tags Merged", Status: utils.StatusOk, Data: tagChange{n}})
+}
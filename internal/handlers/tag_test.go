@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// withTag adds the tag URL parameter to req.
+func withTag(req *http.Request, tag string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("tag", tag)
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestNoteHandler_GetTags(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("GetTags", mock.Anything, testWorkspaceId).Return([]*models.Tag{{Name: "home", Count: 2}, {Name: "work", Count: 5}}, nil)
+
+	req := newRequest(http.MethodGet, "/api/v1/tags", nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.GetTags(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [{"name":"home","count":2},{"name":"work","count":5}]}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_CreateWithTags(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	// Tags are lowercased, sorted and deduplicated.
+	noteRepoMock.On("Create", mock.Anything, testWorkspaceId, testUserId, &models.Note{Title: "Test Note", Content: "I Am A Test Note", Tags: []string{"home", "work"}}).Return(1, nil)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"title": "Test Note", "content": "I Am A Test Note", "tags": ["Work", "home", " work "]}`, http.StatusCreated},
+		{"invalid", `{"title": "Test Note", "content": "I Am A Test Note", "tags": ["two words"]}`, http.StatusBadRequest},
+		{"empty", `{"title": "Test Note", "content": "I Am A Test Note", "tags": [""]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newRequest(http.MethodPost, "/api/v1/notes", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+
+			// Act
+			noteHandler.Create(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestNoteHandler_RenameTag(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("RenameTag", mock.Anything, testWorkspaceId, "wrk", "work").Return(3, nil)
+	noteRepoMock.On("RenameTag", mock.Anything, testWorkspaceId, "home", "work").Return(0, &repository.RepoError{Src: "RenameTag", Err: repository.ErrTagExists})
+
+	// Act
+	renamed := httptest.NewRecorder()
+	noteHandler.RenameTag(renamed, withTag(newRequest(http.MethodPost, "/api/v1/tags/wrk/rename", bytes.NewBufferString(`{"name": "Work"}`)), "wrk"))
+	exists := httptest.NewRecorder()
+	noteHandler.RenameTag(exists, withTag(newRequest(http.MethodPost, "/api/v1/tags/home/rename", bytes.NewBufferString(`{"name": "work"}`)), "home"))
+	same := httptest.NewRecorder()
+	noteHandler.RenameTag(same, withTag(newRequest(http.MethodPost, "/api/v1/tags/work/rename", bytes.NewBufferString(`{"name": "WORK"}`)), "work"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, renamed.Code)
+	assert.JSONEq(t, `{"status": "ok", "message": "Tag Renamed", "data": {"notes": 3}}`, renamed.Body.String())
+	assert.Equal(t, http.StatusConflict, exists.Code)
+	assert.Equal(t, http.StatusBadRequest, same.Code)
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_MergeTags(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	noteRepoMock.On("MergeTags", mock.Anything, testWorkspaceId, "wrk", "work").Return(2, nil)
+	noteRepoMock.On("MergeTags", mock.Anything, testWorkspaceId, "wrk", "missing").Return(0, &repository.RepoError{Src: "MergeTags", Err: repository.ErrTagNotFound})
+
+	// Act
+	merged := httptest.NewRecorder()
+	noteHandler.MergeTags(merged, withTag(newRequest(http.MethodPost, "/api/v1/tags/wrk/merge", bytes.NewBufferString(`{"into": "work"}`)), "wrk"))
+	missing := httptest.NewRecorder()
+	noteHandler.MergeTags(missing, withTag(newRequest(http.MethodPost, "/api/v1/tags/wrk/merge", bytes.NewBufferString(`{"into": "missing"}`)), "wrk"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, merged.Code)
+	assert.JSONEq(t, `{"status": "ok", "message": "Tags Merged", "data": {"notes": 2}}`, merged.Body.String())
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	noteRepoMock.AssertExpectations(t)
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+)
+
+// GetTrash retrieves a page of the notes in the trash. It takes the same
+// parameters as GetAll and can also sort by deleted_at.
+// It returns a 400 error if the paging, sorting or filtering parameters are
+// invalid and a 403 error if the user is a guest of the workspace.
+func (h NoteHandler) GetTrash(w http.ResponseWriter, r *http.Request) {
+	opts, err := getListOptions(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	page, err := h.noteService.GetTrash(ctx, getMember(r), opts)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, service.ErrInvalidListOptions) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidListOptions.Error())
+			return
+		} else if errors.Is(err, repository.ErrInvalidCursor) {
+			utils.ErrorResponse(w, http.StatusBadRequest, repository.ErrInvalidCursor.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{
+		Status: utils.StatusOk,
+		Data:   page.Notes,
+		Meta:   &utils.Meta{NextCursor: page.NextCursor, HasMore: page.HasMore},
+	})
+}
+
+// Restore moves a note out of the trash and responds with the restored note
+// and its new ETag.
+// It returns a 400 error if the id is invalid, a 403 error if the user is a
+// viewer of the workspace and a 404 error if the note is not in the trash.
+func (h NoteHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	note, err := h.noteService.Restore(ctx, getMember(r), noteid)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, repository.ErrNoteNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidId) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+
+	w.Header().Set("ETag", etag(note.Version))
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Message: "Note Restored", Status: utils.StatusOk, Data: note})
+}
+
+// Purge permanently deletes a note from the trash and responds with 204 and
+// no body.
+// It returns a 400 error if the id is invalid, a 403 error if the user is
+// not an owner of the workspace and a 404 error if the note is not in the
+// trash.
+func (h NoteHandler) Purge(w http.ResponseWriter, r *http.Request) {
+	noteid, err := getNoteId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := h.context(r)
+	defer cancel()
+	err = h.noteService.Purge(ctx, getMember(r), noteid)
+	if err != nil {
+		log.Println(err)
+		if errors.Is(err, repository.ErrNoteNotFound) {
+			utils.ErrorResponse(w, http.StatusNotFound, repository.ErrNoteNotFound.Error())
+			return
+		} else if errors.Is(err, service.ErrForbidden) {
+			utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+			return
+		} else if errors.Is(err, service.ErrInvalidId) {
+			utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
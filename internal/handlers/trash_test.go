@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestNoteHandler_GetTrash(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	createdAt := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	deletedAt := createdAt.Add(time.Hour)
+	note := &models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", CreatedAt: createdAt, UpdatedAt: createdAt, Version: 2, DeletedAt: &deletedAt}
+	opts := models.ListOptions{Limit: service.DefaultPageSize, Sort: models.SortByDeletedAt, Desc: true, TagMode: models.TagModeAll, Trashed: true}
+	noteRepoMock.On("GetAll", mock.Anything, testWorkspaceId, opts).Return(&models.NotePage{Notes: []*models.Note{note}}, nil)
+
+	req := newRequest(http.MethodGet, "/api/v1/trash?sort=-deleted_at", nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.GetTrash(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [{"id":1,"title":"Test Note","content":"I Am A Test Note","created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-01T09:30:00Z","version":2,"deleted_at":"2024-05-01T10:30:00Z"}], "meta": {"has_more": false}}`, rec.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_GetAllRejectsDeletedAtSort(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	req := newRequest(http.MethodGet, "/api/v1/notes?sort=deleted_at", nil)
+	rec := httptest.NewRecorder()
+
+	// Act
+	noteHandler.GetAll(rec, req)
+
+	// Assertion
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	noteRepoMock.AssertNotCalled(t, "GetAll")
+}
+
+func TestNoteHandler_Restore(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	createdAt := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	note := &models.Note{Id: 1, Title: "Test Note", Content: "I Am A Test Note", CreatedAt: createdAt, UpdatedAt: createdAt, Version: 3}
+	noteRepoMock.On("Restore", mock.Anything, testWorkspaceId, 1).Return(note, nil)
+	noteRepoMock.On("Restore", mock.Anything, testWorkspaceId, 2).Return((*models.Note)(nil), &repository.RepoError{Src: "RestoreNoteByID", Id: 2, Err: repository.ErrNoteNotFound})
+
+	// Act
+	restored := httptest.NewRecorder()
+	noteHandler.Restore(restored, withNoteId(newRequest(http.MethodPost, "/api/v1/notes/1/restore", nil), "1"))
+	missing := httptest.NewRecorder()
+	noteHandler.Restore(missing, withNoteId(newRequest(http.MethodPost, "/api/v1/notes/2/restore", nil), "2"))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, restored.Code)
+	assert.Equal(t, `"3"`, restored.Header().Get("ETag"))
+	assert.JSONEq(t, `{"status": "ok", "message": "Note Restored", "data": {"id":1,"title":"Test Note","content":"I Am A Test Note","created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-01T09:30:00Z","version":3}}`, restored.Body.String())
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	noteRepoMock.AssertExpectations(t)
+}
+
+func TestNoteHandler_Purge(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	// A note can only be purged once, after that it is gone.
+	noteRepoMock.On("Purge", mock.Anything, testWorkspaceId, 1).Return(nil).Once()
+	noteRepoMock.On("Purge", mock.Anything, testWorkspaceId, 1).Return(&repository.RepoError{Src: "PurgeNoteByID", Id: 1, Err: repository.ErrNoteNotFound})
+
+	// Act
+	first := httptest.NewRecorder()
+	noteHandler.Purge(first, withNoteId(newRequest(http.MethodDelete, "/api/v1/trash/1", nil), "1"))
+	second := httptest.NewRecorder()
+	noteHandler.Purge(second, withNoteId(newRequest(http.MethodDelete, "/api/v1/trash/1", nil), "1"))
+
+	// Assertion
+	assert.Equal(t, http.StatusNoContent, first.Code)
+	assert.Equal(t, http.StatusNotFound, second.Code)
+	assert.JSONEq(t, `{"status": "error", "message": "note not found"}`, second.Body.String())
+	noteRepoMock.AssertExpectations(t)
+}
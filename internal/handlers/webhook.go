@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// getWebhookId extracts the webhook id from the URL.
+// It returns an error if the id is not a valid integer
+func getWebhookId(r *http.Request) (int, error) {
+	webhookid := chi.URLParam(r, "webhookId")
+	webhookidAsInt, err := strconv.Atoi(webhookid)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidId, webhookid)
+	}
+	return webhookidAsInt, nil
+}
+
+// getDeliveryId extracts the webhook delivery id from the URL.
+// It returns an error if the id is not a valid integer
+func getDeliveryId(r *http.Request) (int, error) {
+	deliveryid := chi.URLParam(r, "deliveryId")
+	deliveryidAsInt, err := strconv.Atoi(deliveryid)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidId, deliveryid)
+	}
+	return deliveryidAsInt, nil
+}
+
+// WebhookHandler handles HTTP requests related to webhooks. It provides
+// methods for managing the webhooks of a workspace and inspecting and
+// retrying their deliveries. Only owners of the workspace can use them.
+type WebhookHandler struct {
+	webhookService service.WebhookService
+}
+
+// NewWebhookHandler creates a new WebhookHandler
+func NewWebhookHandler(webhookService service.WebhookService) *WebhookHandler {
+	return &WebhookHandler{webhookService}
+}
+
+// writeWebhookError responds to errors shared by the webhook endpoints. It
+// reports whether err was one of them.
+func writeWebhookError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrWebhookNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrWebhookNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrDeliveryNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrDeliveryNotFound.Error())
+		return true
+	} else if errors.Is(err, service.ErrForbidden) {
+		utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidWebhook) {
+		utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidId) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+		return true
+	}
+	return false
+}
+
+// GetAll lists the webhooks of the selected workspace without their
+// secrets.
+// It returns a 403 error if the user is not an owner of the workspace.
+func (h WebhookHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhookService.GetAll(getMember(r))
+	if err != nil {
+		log.Println(err)
+		if !writeWebhookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: webhooks})
+}
+
+// Create adds a webhook for the URL and events in the request body and
+// responds with it, including its secret, which is not shown again.
+// It returns a 400 error if the URL, events or secret are invalid and a 403
+// error if the user is not an owner of the workspace.
+func (h WebhookHandler) Create(w http.ResponseWriter, r *http.Request) {
+	webhook := &models.Webhook{}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(webhook); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	created, err := h.webhookService.Create(getMember(r), webhook)
+	if err != nil {
+		log.Println(err)
+		if !writeWebhookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: created})
+}
+
+// Delete removes a webhook and its deliveries and responds with 204 and no
+// body.
+// It returns a 400 error if the id is invalid, a 403 error if the user is not
+// an owner of the workspace and a 404 error if the webhook is not found.
+func (h WebhookHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	webhookid, err := getWebhookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.webhookService.Delete(getMember(r), webhookid); err != nil {
+		log.Println(err)
+		if !writeWebhookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries lists the latest deliveries of a webhook, newest first, with
+// the outcome of their last attempt.
+// It returns a 400 error if the id is invalid, a 403 error if the user is not
+// an owner of the workspace and a 404 error if the webhook is not found.
+func (h WebhookHandler) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	webhookid, err := getWebhookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := h.webhookService.GetDeliveries(getMember(r), webhookid)
+	if err != nil {
+		log.Println(err)
+		if !writeWebhookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: deliveries})
+}
+
+// Redeliver queues a delivery of a webhook to be sent again right away, also
+// if it is dead, and responds with the delivery.
+// It returns a 400 error if an id is invalid, a 403 error if the user is not
+// an owner of the workspace and a 404 error if the delivery is not found.
+func (h WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	webhookid, err := getWebhookId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	deliveryid, err := getDeliveryId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(getMember(r), webhookid, deliveryid)
+	if err != nil {
+		log.Println(err)
+		if !writeWebhookError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: delivery})
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newWebhookHandler returns a WebhookHandler backed by webhookRepoMock.
+func newWebhookHandler(webhookRepoMock *mocks.WebhookRepoMock) *WebhookHandler {
+	return NewWebhookHandler(service.NewWebhookService(webhookRepoMock))
+}
+
+// withWebhookParams returns req with the webhook and delivery ids of its
+// route set to webhookId and, if it is not empty, deliveryId.
+func withWebhookParams(req *http.Request, webhookId string, deliveryId string) *http.Request {
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("webhookId", webhookId)
+	if deliveryId != "" {
+		rctx.URLParams.Add("deliveryId", deliveryId)
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestWebhookHandler_Create(t *testing.T) {
+	// Arrange
+	webhookRepoMock := &mocks.WebhookRepoMock{}
+	webhookHandler := newWebhookHandler(webhookRepoMock)
+
+	var stored *models.Webhook
+	webhookRepoMock.On("Create", testWorkspaceId, mock.Anything).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(*models.Webhook)
+		stored.Id = 5
+	}).Return(5, nil)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"url": "https://ci.example.com/hook", "events": ["note.updated", "note.created", "note.updated"]}`, http.StatusCreated},
+		{"relative url", `{"url": "/hook", "events": ["note.created"]}`, http.StatusBadRequest},
+		{"other scheme", `{"url": "ftp://ci.example.com/hook", "events": ["note.created"]}`, http.StatusBadRequest},
+		{"loopback", `{"url": "http://127.0.0.1:3000/hook", "events": ["note.created"]}`, http.StatusBadRequest},
+		{"localhost", `{"url": "http://localhost:3000/hook", "events": ["note.created"]}`, http.StatusBadRequest},
+		{"metadata", `{"url": "http://169.254.169.254/latest/meta-data/", "events": ["note.created"]}`, http.StatusBadRequest},
+		{"private", `{"url": "https://10.0.0.8/hook", "events": ["note.created"]}`, http.StatusBadRequest},
+		{"mapped loopback", `{"url": "http://[::ffff:127.0.0.1]/hook", "events": ["note.created"]}`, http.StatusBadRequest},
+		{"no events", `{"url": "https://ci.example.com/hook", "events": []}`, http.StatusBadRequest},
+		{"unknown event", `{"url": "https://ci.example.com/hook", "events": ["note.shared"]}`, http.StatusBadRequest},
+		{"short secret", `{"url": "https://ci.example.com/hook", "events": ["note.created"], "secret": "short"}`, http.StatusBadRequest},
+		{"invalid json", `{"url":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			// Act
+			webhookHandler.Create(rec, newRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(tt.body)))
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	webhookRepoMock.AssertNumberOfCalls(t, "Create", 1)
+	assert.Equal(t, []models.EventType{models.EventNoteCreated, models.EventNoteUpdated}, stored.Events)
+	assert.True(t, strings.HasPrefix(stored.Secret, service.WebhookSecretPrefix))
+}
+
+func TestWebhookHandler_CreateAllowPrivateTargets(t *testing.T) {
+	// Arrange
+	webhookRepoMock := &mocks.WebhookRepoMock{}
+	webhookService := service.NewWebhookService(webhookRepoMock)
+	webhookService.AllowPrivateTargets = true
+	webhookHandler := NewWebhookHandler(webhookService)
+
+	webhookRepoMock.On("Create", testWorkspaceId, mock.Anything).Return(5, nil)
+	body := `{"url": "http://127.0.0.1:3000/hook", "events": ["note.created"]}`
+	rec := httptest.NewRecorder()
+
+	// Act
+	webhookHandler.Create(rec, newRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(body)))
+
+	// Assertion
+	assert.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+	webhookRepoMock.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestWebhookHandler_CreateShowsSecretOnce(t *testing.T) {
+	// Arrange
+	webhookRepoMock := &mocks.WebhookRepoMock{}
+	webhookHandler := newWebhookHandler(webhookRepoMock)
+
+	created := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	webhookRepoMock.On("Create", testWorkspaceId, mock.Anything).Run(func(args mock.Arguments) {
+		webhook := args.Get(1).(*models.Webhook)
+		webhook.Id, webhook.CreatedAt = 5, created
+	}).Return(5, nil)
+	webhookRepoMock.On("GetAll", testWorkspaceId).
+		Return([]*models.Webhook{{Id: 5, URL: "https://ci.example.com/hook", Events: []models.EventType{models.EventNoteCreated}, CreatedAt: created}}, nil)
+
+	body := `{"url": "https://ci.example.com/hook", "events": ["note.created"], "secret": "0123456789abcdef"}`
+	createRec := httptest.NewRecorder()
+	listRec := httptest.NewRecorder()
+
+	// Act
+	webhookHandler.Create(createRec, newRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(body)))
+	webhookHandler.GetAll(listRec, newRequest(http.MethodGet, "/api/v1/webhooks", nil))
+
+	// Assertion
+	assert.Equal(t, http.StatusCreated, createRec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": {"id": 5, "url": "https://ci.example.com/hook", "events": ["note.created"],
+		"secret": "0123456789abcdef", "created_at": "2024-05-01T09:30:00Z"}}`, createRec.Body.String())
+	assert.Equal(t, http.StatusOK, listRec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [{"id": 5, "url": "https://ci.example.com/hook", "events": ["note.created"],
+		"created_at": "2024-05-01T09:30:00Z"}]}`, listRec.Body.String())
+	webhookRepoMock.AssertExpectations(t)
+}
+
+func TestWebhookHandler_OwnersOnly(t *testing.T) {
+	// Arrange
+	webhookRepoMock := &mocks.WebhookRepoMock{}
+	webhookHandler := newWebhookHandler(webhookRepoMock)
+
+	body := `{"url": "https://ci.example.com/hook", "events": ["note.created"]}`
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		req     *http.Request
+	}{
+		{"list", webhookHandler.GetAll, httptest.NewRequest(http.MethodGet, "/api/v1/webhooks", nil)},
+		{"create", webhookHandler.Create, httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(body))},
+		{"delete", webhookHandler.Delete, withWebhookParams(httptest.NewRequest(http.MethodDelete, "/api/v1/webhooks/5", nil), "5", "")},
+		{"deliveries", webhookHandler.GetDeliveries, withWebhookParams(httptest.NewRequest(http.MethodGet, "/api/v1/webhooks/5/deliveries", nil), "5", "")},
+		{"redeliver", webhookHandler.Redeliver, withWebhookParams(httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/5/deliveries/2/redeliver", nil), "5", "2")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			// Act
+			tt.handler(rec, withRole(tt.req, models.RoleEditor))
+
+			// Assertion
+			assert.Equal(t, http.StatusForbidden, rec.Code, rec.Body.String())
+		})
+	}
+	webhookRepoMock.AssertNotCalled(t, "GetAll")
+	webhookRepoMock.AssertNotCalled(t, "Create")
+}
+
+func TestWebhookHandler_Delete(t *testing.T) {
+	// Arrange
+	webhookRepoMock := &mocks.WebhookRepoMock{}
+	webhookHandler := newWebhookHandler(webhookRepoMock)
+
+	webhookRepoMock.On("Delete", testWorkspaceId, 5).Return(nil)
+	webhookRepoMock.On("Delete", testWorkspaceId, 6).Return(&repository.RepoError{Src: "DeleteWebhook", Id: 6, Err: repository.ErrWebhookNotFound})
+
+	tests := []struct {
+		name   string
+		id     string
+		status int
+	}{
+		{"deleted", "5", http.StatusNoContent},
+		{"not found", "6", http.StatusNotFound},
+		{"invalid id", "abc", http.StatusBadRequest},
+		{"zero id", "0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withWebhookParams(newRequest(http.MethodDelete, "/api/v1/webhooks/"+tt.id, nil), tt.id, "")
+			rec := httptest.NewRecorder()
+
+			// Act
+			webhookHandler.Delete(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	webhookRepoMock.AssertExpectations(t)
+}
+
+func TestWebhookHandler_GetDeliveries(t *testing.T) {
+	// Arrange
+	webhookRepoMock := &mocks.WebhookRepoMock{}
+	webhookHandler := newWebhookHandler(webhookRepoMock)
+
+	created := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	retry := created.Add(time.Minute)
+	webhookRepoMock.On("GetDeliveries", testWorkspaceId, 5).Return([]*models.WebhookDelivery{{
+		Id: 2, WebhookId: 5, Event: models.EventNoteDeleted, Payload: json.RawMessage(`{"type":"note.deleted"}`), Status: models.DeliveryPending,
+		Attempts: 1, NextAttemptAt: &retry, LastStatusCode: 503, LastError: "webhook responded with 503 Service Unavailable", CreatedAt: created,
+		URL: "https://ci.example.com/hook", Secret: "whsec_secret",
+	}}, nil)
+	webhookRepoMock.On("GetDeliveries", testWorkspaceId, 6).
+		Return([]*models.WebhookDelivery(nil), &repository.RepoError{Src: "GetWebhookDeliveries", Id: 6, Err: repository.ErrWebhookNotFound})
+
+	rec := httptest.NewRecorder()
+	notFoundRec := httptest.NewRecorder()
+
+	// Act
+	webhookHandler.GetDeliveries(rec, withWebhookParams(newRequest(http.MethodGet, "/api/v1/webhooks/5/deliveries", nil), "5", ""))
+	webhookHandler.GetDeliveries(notFoundRec, withWebhookParams(newRequest(http.MethodGet, "/api/v1/webhooks/6/deliveries", nil), "6", ""))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status": "ok", "data": [{"id": 2, "webhook_id": 5, "event": "note.deleted", "payload": {"type": "note.deleted"},
+		"status": "pending", "attempts": 1, "next_attempt_at": "2024-05-01T09:31:00Z", "last_status_code": 503,
+		"last_error": "webhook responded with 503 Service Unavailable", "created_at": "2024-05-01T09:30:00Z"}]}`, rec.Body.String())
+	assert.Equal(t, http.StatusNotFound, notFoundRec.Code)
+	webhookRepoMock.AssertExpectations(t)
+}
+
+func TestWebhookHandler_Redeliver(t *testing.T) {
+	// Arrange
+	webhookRepoMock := &mocks.WebhookRepoMock{}
+	webhookHandler := newWebhookHandler(webhookRepoMock)
+
+	webhookRepoMock.On("Redeliver", testWorkspaceId, 5, 2).
+		Return(&models.WebhookDelivery{Id: 2, WebhookId: 5, Event: models.EventNoteCreated, Status: models.DeliveryPending}, nil)
+	webhookRepoMock.On("Redeliver", testWorkspaceId, 5, 3).
+		Return((*models.WebhookDelivery)(nil), &repository.RepoError{Src: "RedeliverWebhook", Id: 3, Err: repository.ErrDeliveryNotFound})
+
+	tests := []struct {
+		name       string
+		deliveryId string
+		status     int
+	}{
+		{"redelivered", "2", http.StatusOK},
+		{"not found", "3", http.StatusNotFound},
+		{"invalid id", "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withWebhookParams(newRequest(http.MethodPost, "/api/v1/webhooks/5/deliveries/"+tt.deliveryId+"/redeliver", nil), "5", tt.deliveryId)
+			rec := httptest.NewRecorder()
+
+			// Act
+			webhookHandler.Redeliver(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	webhookRepoMock.AssertExpectations(t)
+}
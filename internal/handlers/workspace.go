@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/JannisK89/notes-api/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// WorkspaceHeader is the request header that selects the workspace of a
+// request when its path does not.
+const WorkspaceHeader = "X-Workspace-Id"
+
+// memberKey is the context key under which SelectWorkspace stores the
+// membership of the user in the selected workspace.
+type memberKey struct{}
+
+// contextWithMember returns a copy of ctx that carries m.
+func contextWithMember(ctx context.Context, m *models.Member) context.Context {
+	return context.WithValue(ctx, memberKey{}, m)
+}
+
+// getMember returns the membership SelectWorkspace stored in the request
+// context, or the zero Member if there is none, which matches no workspace.
+func getMember(r *http.Request) models.Member {
+	if m, _ := r.Context().Value(memberKey{}).(*models.Member); m != nil {
+		return *m
+	}
+	return models.Member{}
+}
+
+// getWorkspaceId extracts the id of the workspace of a request from the URL
+// or, if the path has none, from the X-Workspace-Id header. It is 0 if
+// neither is set.
+// It returns an error if the id is not a valid integer
+func getWorkspaceId(r *http.Request) (int, error) {
+	workspaceid := chi.URLParam(r, "workspaceId")
+	if workspaceid == "" {
+		workspaceid = r.Header.Get(WorkspaceHeader)
+	}
+	if workspaceid == "" {
+		return 0, nil
+	}
+	workspaceidAsInt, err := strconv.Atoi(workspaceid)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidId, workspaceid)
+	}
+	return workspaceidAsInt, nil
+}
+
+// WorkspaceHandler handles HTTP requests related to workspaces. It provides
+// methods for listing and creating workspaces, managing their members and a
+// middleware that selects the workspace of a request.
+type WorkspaceHandler struct {
+	workspaceService service.WorkspaceService
+}
+
+// NewWorkspaceHandler creates a new WorkspaceHandler
+func NewWorkspaceHandler(workspaceService service.WorkspaceService) *WorkspaceHandler {
+	return &WorkspaceHandler{workspaceService}
+}
+
+// writeWorkspaceError responds to errors shared by the workspace endpoints.
+// It reports whether err was one of them.
+func writeWorkspaceError(w http.ResponseWriter, err error) bool {
+	if errors.Is(err, repository.ErrWorkspaceNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrWorkspaceNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrMemberNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrMemberNotFound.Error())
+		return true
+	} else if errors.Is(err, repository.ErrUserNotFound) {
+		utils.ErrorResponse(w, http.StatusNotFound, repository.ErrUserNotFound.Error())
+		return true
+	} else if errors.Is(err, service.ErrForbidden) {
+		utils.ErrorResponse(w, http.StatusForbidden, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, repository.ErrLastOwner) {
+		utils.ErrorResponse(w, http.StatusConflict, repository.ErrLastOwner.Error())
+		return true
+	} else if errors.Is(err, repository.ErrPersonalWorkspace) {
+		utils.ErrorResponse(w, http.StatusBadRequest, repository.ErrPersonalWorkspace.Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidWorkspace) {
+		utils.ErrorResponse(w, http.StatusBadRequest, errors.Unwrap(err).Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidMember) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidMember.Error())
+		return true
+	} else if errors.Is(err, service.ErrInvalidId) {
+		utils.ErrorResponse(w, http.StatusBadRequest, service.ErrInvalidId.Error())
+		return true
+	}
+	return false
+}
+
+// SelectWorkspace is a middleware that stores the membership of the user in
+// the workspace of the request in the request context. The workspace is
+// taken from the path, then from the X-Workspace-Id header, and is the
+// personal workspace of the user if neither is set. It must run after
+// Authenticate.
+// It returns a 400 error if the id is invalid and a 404 error if the user is
+// not a member of the workspace.
+func (h WorkspaceHandler) SelectWorkspace(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workspaceid, err := getWorkspaceId(r)
+		if err != nil {
+			log.Println(err)
+			utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		m, err := h.workspaceService.Member(getUserId(r), workspaceid)
+		if err != nil {
+			log.Println(err)
+			if !writeWorkspaceError(w, err) {
+				utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+			}
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(contextWithMember(r.Context(), m)))
+	})
+}
+
+// GetAll lists the workspaces of the user together with their role in them,
+// the personal workspace first.
+func (h WorkspaceHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	workspaces, err := h.workspaceService.GetAll(getUserId(r))
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: workspaces})
+}
+
+// Create adds a new workspace with the user as its owner and responds with
+// the created workspace.
+// It returns a 400 error if the name is invalid.
+func (h WorkspaceHandler) Create(w http.ResponseWriter, r *http.Request) {
+	workspace := &models.Workspace{}
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(workspace); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	if _, err := h.workspaceService.Create(getUserId(r), workspace); err != nil {
+		log.Println(err)
+		if !writeWorkspaceError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusCreated, utils.ApiResponse{Status: utils.StatusOk, Data: workspace})
+}
+
+// GetMembers lists the members of the selected workspace, owners first.
+// It returns a 403 error if the user is a guest of the workspace.
+func (h WorkspaceHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := h.workspaceService.GetMembers(getMember(r))
+	if err != nil {
+		log.Println(err)
+		if !writeWorkspaceError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: members})
+}
+
+// AddMember makes the user with the email in the request body a member of
+// the selected workspace at the given role and responds with the member.
+// Adding them again changes their role.
+// It returns a 400 error if the email or role is invalid or the workspace is
+// personal, a 403 error if the user is not an owner of the workspace, a 404
+// error if the user to add is not found and a 409 error if the change would
+// leave the workspace without an owner.
+func (h WorkspaceHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	var body shareRequest
+	defer r.Body.Close()
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, utils.BadRequest)
+		return
+	}
+
+	member, err := h.workspaceService.AddMember(getMember(r), body.Email, body.Role)
+	if err != nil {
+		log.Println(err)
+		if !writeWorkspaceError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	utils.JSONResponse(w, http.StatusOK, utils.ApiResponse{Status: utils.StatusOk, Data: member})
+}
+
+// RemoveMember removes a user from the selected workspace and responds with
+// 204 and no body. Owners can remove anyone, members only themselves.
+// It returns a 400 error if the id is invalid, a 403 error if the user may
+// not remove the member, a 404 error if the member is not found and a 409
+// error if the last owner would leave.
+func (h WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userid, err := getCollaboratorId(r)
+	if err != nil {
+		log.Println(err)
+		utils.ErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := h.workspaceService.RemoveMember(getMember(r), userid); err != nil {
+		log.Println(err)
+		if !writeWorkspaceError(w, err) {
+			utils.ErrorResponse(w, http.StatusInternalServerError, utils.InternalServerError)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/JannisK89/notes-api/internal/mocks"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/JannisK89/notes-api/internal/service"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newWorkspaceHandler returns a WorkspaceHandler backed by workspaceRepoMock.
+func newWorkspaceHandler(workspaceRepoMock *mocks.WorkspaceRepoMock) *WorkspaceHandler {
+	return NewWorkspaceHandler(service.NewWorkspaceService(workspaceRepoMock))
+}
+
+func TestWorkspaceHandler_SelectWorkspace(t *testing.T) {
+	// Arrange
+	workspaceRepoMock := &mocks.WorkspaceRepoMock{}
+	workspaceHandler := newWorkspaceHandler(workspaceRepoMock)
+
+	personal := &models.Member{WorkspaceId: 1, UserId: testUserId, Role: models.RoleOwner}
+	team := &models.Member{WorkspaceId: testWorkspaceId, UserId: testUserId, Role: models.RoleViewer}
+	workspaceRepoMock.On("GetPersonalMember", testUserId).Return(personal, nil)
+	workspaceRepoMock.On("GetMember", testWorkspaceId, testUserId).Return(team, nil)
+	workspaceRepoMock.On("GetMember", 9, testUserId).
+		Return((*models.Member)(nil), &repository.RepoError{Src: "GetWorkspaceMember", Id: 9, Err: repository.ErrWorkspaceNotFound})
+
+	var selected models.Member
+	next := workspaceHandler.SelectWorkspace(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		selected = getMember(r)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		param  string
+		header string
+		status int
+		member models.Member
+	}{
+		{"personal by default", "", "", http.StatusNoContent, *personal},
+		{"by header", "", "3", http.StatusNoContent, *team},
+		{"by path", "3", "", http.StatusNoContent, *team},
+		{"path before header", "3", "9", http.StatusNoContent, *team},
+		{"not a member", "", "9", http.StatusNotFound, models.Member{}},
+		{"invalid id", "", "team", http.StatusBadRequest, models.Member{}},
+		{"negative id", "-1", "", http.StatusBadRequest, models.Member{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected = models.Member{}
+			req := newRequest(http.MethodGet, "/api/v1/notes", nil)
+			if tt.param != "" {
+				rctx := chi.NewRouteContext()
+				rctx.URLParams.Add("workspaceId", tt.param)
+				req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+			}
+			if tt.header != "" {
+				req.Header.Set(WorkspaceHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			// Act
+			next.ServeHTTP(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.member, selected)
+		})
+	}
+}
+
+func TestNoteHandler_WorkspaceIsolation(t *testing.T) {
+	// Arrange
+	noteRepoMock := &mocks.NoteRepoMock{}
+	noteHandler := NewNoteHandler(service.NewNoteService(noteRepoMock))
+
+	// Note 1 is in the workspace of the request, note 2 in another one.
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 1).Return(models.Role(""), nil)
+	noteRepoMock.On("GetNoteAccess", mock.Anything, testWorkspaceId, testUserId, 2).
+		Return(models.Role(""), &repository.RepoError{Src: "GetNoteAccess", Id: 2, Err: repository.ErrNoteNotFound})
+	noteRepoMock.On("Get", mock.Anything, testWorkspaceId, 1).Return(&models.Note{Id: 1, Title: "Team", Content: "Team note", Version: 1}, nil)
+
+	body := `{"title": "Title", "content": "Content"}`
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		id      string
+		status  int
+	}{
+		{"viewer gets", noteHandler.Get, http.MethodGet, "1", http.StatusOK},
+		{"viewer updates", noteHandler.Update, http.MethodPut, "1", http.StatusForbidden},
+		{"viewer creates", noteHandler.Create, http.MethodPost, "", http.StatusForbidden},
+		{"other workspace gets", noteHandler.Get, http.MethodGet, "2", http.StatusNotFound},
+		{"other workspace updates", noteHandler.Update, http.MethodPut, "2", http.StatusNotFound},
+		{"other workspace deletes", noteHandler.Delete, http.MethodDelete, "2", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/api/v1/notes/"+tt.id, strings.NewReader(body))
+			req = withNoteId(withRole(req, models.RoleViewer), tt.id)
+			rec := httptest.NewRecorder()
+
+			// Act
+			tt.handler(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	noteRepoMock.AssertNotCalled(t, "Create", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	noteRepoMock.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	noteRepoMock.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestWorkspaceHandler_Create(t *testing.T) {
+	// Arrange
+	workspaceRepoMock := &mocks.WorkspaceRepoMock{}
+	workspaceHandler := newWorkspaceHandler(workspaceRepoMock)
+
+	workspaceRepoMock.On("Create", testUserId, &models.Workspace{Name: "Team"}).Run(func(args mock.Arguments) {
+		workspace := args.Get(1).(*models.Workspace)
+		workspace.Id, workspace.Role = 4, models.RoleOwner
+	}).Return(4, nil)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{"valid", `{"name": " Team "}`, http.StatusCreated},
+		{"no name", `{"name": "  "}`, http.StatusBadRequest},
+		{"too long", `{"name": "` + strings.Repeat("a", service.MaxWorkspaceNameLength+1) + `"}`, http.StatusBadRequest},
+		{"invalid json", `{"name":`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+
+			// Act
+			workspaceHandler.Create(rec, newRequest(http.MethodPost, "/api/v1/workspaces", strings.NewReader(tt.body)))
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	workspaceRepoMock.AssertNumberOfCalls(t, "Create", 1)
+}
+
+func TestWorkspaceHandler_AddMember(t *testing.T) {
+	// Arrange
+	workspaceRepoMock := &mocks.WorkspaceRepoMock{}
+	workspaceHandler := newWorkspaceHandler(workspaceRepoMock)
+
+	workspaceRepoMock.On("AddMember", testWorkspaceId, "grace@example.com", models.RoleEditor).
+		Return(&models.WorkspaceMember{UserId: 8, Email: "grace@example.com", Role: models.RoleEditor}, nil)
+	workspaceRepoMock.On("AddMember", testWorkspaceId, "nobody@example.com", models.RoleViewer).
+		Return((*models.WorkspaceMember)(nil), &repository.RepoError{Src: "AddWorkspaceMember", Id: testWorkspaceId, Err: repository.ErrUserNotFound})
+	workspaceRepoMock.On("AddMember", testWorkspaceId, "ada@example.com", models.RoleViewer).
+		Return((*models.WorkspaceMember)(nil), &repository.RepoError{Src: "AddWorkspaceMember", Id: testWorkspaceId, Err: repository.ErrLastOwner})
+	workspaceRepoMock.On("AddMember", testWorkspaceId, "linus@example.com", models.RoleOwner).
+		Return((*models.WorkspaceMember)(nil), &repository.RepoError{Src: "AddWorkspaceMember", Id: testWorkspaceId, Err: repository.ErrPersonalWorkspace})
+
+	tests := []struct {
+		name   string
+		role   models.Role
+		body   string
+		status int
+	}{
+		{"owner adds", models.RoleOwner, `{"email": " grace@example.com ", "role": "editor"}`, http.StatusOK},
+		{"unknown user", models.RoleOwner, `{"email": "nobody@example.com", "role": "viewer"}`, http.StatusNotFound},
+		{"last owner", models.RoleOwner, `{"email": "ada@example.com", "role": "viewer"}`, http.StatusConflict},
+		{"personal", models.RoleOwner, `{"email": "linus@example.com", "role": "owner"}`, http.StatusBadRequest},
+		{"unknown role", models.RoleOwner, `{"email": "grace@example.com", "role": "admin"}`, http.StatusBadRequest},
+		{"editor adds", models.RoleEditor, `{"email": "grace@example.com", "role": "editor"}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withRole(httptest.NewRequest(http.MethodPost, "/api/v1/workspaces/3/members", strings.NewReader(tt.body)), tt.role)
+			rec := httptest.NewRecorder()
+
+			// Act
+			workspaceHandler.AddMember(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	workspaceRepoMock.AssertNumberOfCalls(t, "AddMember", 4)
+}
+
+func TestWorkspaceHandler_RemoveMember(t *testing.T) {
+	// Arrange
+	workspaceRepoMock := &mocks.WorkspaceRepoMock{}
+	workspaceHandler := newWorkspaceHandler(workspaceRepoMock)
+
+	workspaceRepoMock.On("RemoveMember", testWorkspaceId, 8).Return(nil)
+	workspaceRepoMock.On("RemoveMember", testWorkspaceId, 5).
+		Return(&repository.RepoError{Src: "RemoveWorkspaceMember", Id: testWorkspaceId, Err: repository.ErrMemberNotFound})
+	workspaceRepoMock.On("RemoveMember", testWorkspaceId, testUserId).Return(nil)
+
+	tests := []struct {
+		name   string
+		role   models.Role
+		userId string
+		status int
+	}{
+		{"owner removes", models.RoleOwner, "8", http.StatusNoContent},
+		{"not a member", models.RoleOwner, "5", http.StatusNotFound},
+		{"viewer leaves", models.RoleViewer, "7", http.StatusNoContent},
+		{"viewer removes other", models.RoleViewer, "8", http.StatusForbidden},
+		{"invalid user id", models.RoleOwner, "abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := withRole(httptest.NewRequest(http.MethodDelete, "/api/v1/workspaces/3/members/"+tt.userId, nil), tt.role)
+			req = withShare(req, "workspaceId", "3", tt.userId)
+			rec := httptest.NewRecorder()
+
+			// Act
+			workspaceHandler.RemoveMember(rec, req)
+
+			// Assertion
+			assert.Equal(t, tt.status, rec.Code, rec.Body.String())
+		})
+	}
+	workspaceRepoMock.AssertNumberOfCalls(t, "RemoveMember", 3)
+}
+
+func TestWorkspaceHandler_GetAll(t *testing.T) {
+	// Arrange
+	workspaceRepoMock := &mocks.WorkspaceRepoMock{}
+	workspaceHandler := newWorkspaceHandler(workspaceRepoMock)
+
+	workspaceRepoMock.On("GetAll", testUserId).Return([]*models.Workspace{
+		{Id: 1, Name: "Personal", Personal: true, Role: models.RoleOwner},
+		{Id: testWorkspaceId, Name: "Team", Role: models.RoleViewer},
+	}, nil)
+
+	rec := httptest.NewRecorder()
+
+	// Act
+	workspaceHandler.GetAll(rec, newRequest(http.MethodGet, "/api/v1/workspaces", nil))
+
+	// Assertion
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var res struct{ Data []map[string]interface{} }
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Len(t, res.Data, 2)
+	assert.Equal(t, true, res.Data[0]["personal"])
+	assert.Equal(t, "viewer", res.Data[1]["role"])
+}
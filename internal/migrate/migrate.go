@@ -0,0 +1,512 @@
+// Package migrate applies ordered, versioned schema migrations to a database
+// and records them in a schema_migrations table.
+//
+// Migrations are either pairs of SQL files named NNNN_name.up.sql and
+// NNNN_name.down.sql, loaded with Load, or Go functions. The checksum of every
+// applied migration is stored, so an applied migration that was edited
+// afterwards is detected instead of silently diverging. A lock row keeps
+// several processes from migrating the same database at once; its holder
+// renews it while migrating and only ever removes its own.
+package migrate
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	// ErrChecksumMismatch is returned when an applied migration has been
+	// changed since it was applied.
+	ErrChecksumMismatch = errors.New("migration checksum mismatch")
+	// ErrUnknownMigration is returned when the database has a migration
+	// applied that is not known to the migrator.
+	ErrUnknownMigration = errors.New("unknown migration applied")
+	// ErrLocked is returned when another migrator holds the lock for longer
+	// than the lock timeout.
+	ErrLocked = errors.New("migrations are locked by another process")
+	// ErrNoDown is returned when rolling back a migration without a down
+	// migration.
+	ErrNoDown = errors.New("migration cannot be rolled back")
+	// ErrInvalidMigration is returned when migration files are misnamed or
+	// versions are duplicated.
+	ErrInvalidMigration = errors.New("invalid migration")
+)
+
+// Error represents an error that occurred while migrating. It wraps the
+// underlying error and records the migration version involved.
+type Error struct {
+	Src     string
+	Version int
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Version == 0 {
+		return fmt.Sprintf("Migrate error in %s: %v", e.Src, e.Err)
+	}
+	return fmt.Sprintf("Migrate error in %s with version %d: %v", e.Src, e.Version, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Migration is a single schema change. Either the SQL or the Go function of
+// each direction is set. Migrations without a down step cannot be rolled back.
+type Migration struct {
+	Version int
+	Name    string
+
+	UpSQL   string
+	DownSQL string
+
+	UpFunc   func(tx *sql.Tx) error
+	DownFunc func(tx *sql.Tx) error
+}
+
+// Checksum identifies the content of the up migration. For Go migrations it
+// is derived from the name, as the function body cannot be hashed.
+func (m Migration) Checksum() string {
+	content := m.UpSQL
+	if m.UpFunc != nil {
+		content = "go:" + m.Name
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func (m Migration) hasDown() bool {
+	return m.DownSQL != "" || m.DownFunc != nil
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// migrationFile matches the names of SQL migration files.
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Load reads the SQL migrations in the root of fsys. Files that do not end
+// in .sql are ignored.
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, &Error{Src: "Load", Err: err}
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".sql" {
+			continue
+		}
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, &Error{Src: "Load", Err: fmt.Errorf("%w: bad file name %q", ErrInvalidMigration, entry.Name())}
+		}
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, &Error{Src: "Load", Version: version, Err: err}
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, &Error{Src: "Load", Version: version, Err: fmt.Errorf("%w: version used by %s and %s", ErrInvalidMigration, m.Name, match[2])}
+		}
+		if match[3] == "up" {
+			m.UpSQL = string(content)
+		} else {
+			m.DownSQL = string(content)
+		}
+	}
+
+	migrations := []Migration{}
+	for _, m := range byVersion {
+		if m.UpSQL == "" {
+			return nil, &Error{Src: "Load", Version: m.Version, Err: fmt.Errorf("%w: %s has no up migration", ErrInvalidMigration, m)}
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// State describes whether a migration has been applied to the database.
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateApplied  State = "applied"
+	StateModified State = "modified"
+	StateUnknown  State = "unknown"
+)
+
+// Status is the state of a single migration in the database.
+type Status struct {
+	Version   int
+	Name      string
+	State     State
+	AppliedAt time.Time
+}
+
+// Migrator applies migrations to a database.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+
+	// LockTimeout is how long to wait for another migrator to finish.
+	LockTimeout time.Duration
+	// StaleLockAge is the age after which a lock is assumed to belong to a
+	// migrator that crashed, and is taken over.
+	StaleLockAge time.Duration
+	// LockRefresh is how often a migrator renews the lock it holds, so that
+	// long migrations are not taken for crashed ones. It must be well below
+	// StaleLockAge. The lock is not renewed if it is 0.
+	LockRefresh time.Duration
+	// Logf reports every migration applied or rolled back.
+	Logf func(format string, args ...interface{})
+}
+
+// New creates a Migrator for the given migrations. It returns
+// ErrInvalidMigration if two migrations share a version.
+func New(db *sql.DB, migrations []Migration) (*Migrator, error) {
+	sorted := append([]Migration(nil), migrations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Version < sorted[j].Version })
+	for i, m := range sorted {
+		if m.Version < 1 || (m.UpSQL == "") == (m.UpFunc == nil) {
+			return nil, &Error{Src: "New", Version: m.Version, Err: fmt.Errorf("%w: %s needs a positive version and exactly one up step", ErrInvalidMigration, m)}
+		}
+		if i > 0 && sorted[i-1].Version == m.Version {
+			return nil, &Error{Src: "New", Version: m.Version, Err: fmt.Errorf("%w: duplicate version", ErrInvalidMigration)}
+		}
+	}
+	return &Migrator{
+		db:           db,
+		migrations:   sorted,
+		LockTimeout:  30 * time.Second,
+		StaleLockAge: 10 * time.Minute,
+		LockRefresh:  time.Minute,
+		Logf:         log.Printf,
+	}, nil
+}
+
+// init creates the bookkeeping tables if they do not exist.
+func (m *Migrator) init() error {
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+        version INTEGER PRIMARY KEY,
+        name TEXT NOT NULL,
+        checksum TEXT NOT NULL,
+        applied_at TEXT NOT NULL
+    )`)
+	if err != nil {
+		return err
+	}
+	_, err = m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations_lock (
+        id INTEGER PRIMARY KEY CHECK (id = 1),
+        locked_at TEXT NOT NULL,
+        token TEXT NOT NULL DEFAULT ''
+    )`)
+	if err != nil {
+		return err
+	}
+	// Lock tables created before locks carried a token lack the column.
+	if _, err := m.db.Exec("SELECT token FROM schema_migrations_lock WHERE id = 0"); err == nil {
+		return nil
+	}
+	_, err = m.db.Exec("ALTER TABLE schema_migrations_lock ADD COLUMN token TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		// Another migrator may have added it in the meantime.
+		if _, checkErr := m.db.Exec("SELECT token FROM schema_migrations_lock WHERE id = 0"); checkErr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// lock takes the migration lock, waiting up to LockTimeout for another
+// migrator to release it. Migrators racing for a free lock may all see it
+// free, so the insert leaves the row of the winner alone instead of failing
+// on its primary key. The lock row carries a token of this run, which the
+// returned function uses to release it.
+func (m *Migrator) lock() (func(), error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	token := hex.EncodeToString(b)
+
+	deadline := time.Now().Add(m.LockTimeout)
+	for {
+		now := time.Now().UTC()
+		_, err := m.db.Exec("DELETE FROM schema_migrations_lock WHERE locked_at < ?", now.Add(-m.StaleLockAge).Format(time.RFC3339))
+		if err != nil {
+			return nil, err
+		}
+		res, err := m.db.Exec("INSERT INTO schema_migrations_lock (id, locked_at, token) VALUES (1, ?, ?) ON CONFLICT DO NOTHING", now.Format(time.RFC3339), token)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			return nil, ErrLocked
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go m.refresh(token, stop, done)
+	return func() {
+		close(stop)
+		<-done
+		m.unlock(token)
+	}, nil
+}
+
+// refresh renews the lock with token every LockRefresh until stop is
+// closed, and then closes done.
+func (m *Migrator) refresh(token string, stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+	if m.LockRefresh <= 0 {
+		<-stop
+		return
+	}
+	ticker := time.NewTicker(m.LockRefresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			res, err := m.db.Exec("UPDATE schema_migrations_lock SET locked_at = ? WHERE token = ?", time.Now().UTC().Format(time.RFC3339), token)
+			if err != nil {
+				m.Logf("Could not refresh migration lock: %v", err)
+			} else if n, err := res.RowsAffected(); err == nil && n == 0 {
+				m.Logf("Migration lock was taken over by another migrator")
+			}
+		}
+	}
+}
+
+// unlock releases the lock with token. A lock that was taken over by
+// another migrator is left alone.
+func (m *Migrator) unlock(token string) {
+	if _, err := m.db.Exec("DELETE FROM schema_migrations_lock WHERE token = ?", token); err != nil {
+		m.Logf("Could not release migration lock: %v", err)
+	}
+}
+
+// applied returns the checksums and application times of the applied
+// migrations by version.
+func (m *Migrator) applied() (map[int]Status, map[int]string, error) {
+	rows, err := m.db.Query("SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	statuses := map[int]Status{}
+	checksums := map[int]string{}
+	for rows.Next() {
+		var s Status
+		var checksum, appliedAt string
+		if err := rows.Scan(&s.Version, &s.Name, &checksum, &appliedAt); err != nil {
+			return nil, nil, err
+		}
+		s.AppliedAt, _ = time.Parse(time.RFC3339, appliedAt)
+		s.State = StateApplied
+		statuses[s.Version] = s
+		checksums[s.Version] = checksum
+	}
+	return statuses, checksums, rows.Err()
+}
+
+// Status reports the state of every known migration, followed by any applied
+// migrations the migrator does not know about.
+func (m *Migrator) Status() ([]Status, error) {
+	if err := m.init(); err != nil {
+		return nil, &Error{Src: "Status", Err: err}
+	}
+	applied, checksums, err := m.applied()
+	if err != nil {
+		return nil, &Error{Src: "Status", Err: err}
+	}
+
+	statuses := []Status{}
+	for _, mig := range m.migrations {
+		s, ok := applied[mig.Version]
+		if !ok {
+			statuses = append(statuses, Status{Version: mig.Version, Name: mig.Name, State: StatePending})
+			continue
+		}
+		if checksums[mig.Version] != mig.Checksum() {
+			s.State = StateModified
+		}
+		statuses = append(statuses, s)
+		delete(applied, mig.Version)
+	}
+	unknown := []Status{}
+	for _, s := range applied {
+		s.State = StateUnknown
+		unknown = append(unknown, s)
+	}
+	sort.Slice(unknown, func(i, j int) bool { return unknown[i].Version < unknown[j].Version })
+	return append(statuses, unknown...), nil
+}
+
+// verify checks that every applied migration is known and unchanged.
+func verify(statuses []Status) error {
+	for _, s := range statuses {
+		switch s.State {
+		case StateModified:
+			return &Error{Src: "Verify", Version: s.Version, Err: ErrChecksumMismatch}
+		case StateUnknown:
+			return &Error{Src: "Verify", Version: s.Version, Err: ErrUnknownMigration}
+		}
+	}
+	return nil
+}
+
+// Up applies all pending migrations in order, each in its own transaction.
+// With dryRun set it only returns the migrations that would be applied.
+// It returns ErrChecksumMismatch or ErrUnknownMigration if the applied
+// migrations do not match the known ones.
+func (m *Migrator) Up(dryRun bool) ([]Migration, error) {
+	if err := m.init(); err != nil {
+		return nil, &Error{Src: "Up", Err: err}
+	}
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, &Error{Src: "Up", Err: err}
+	}
+	defer unlock()
+
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(statuses); err != nil {
+		return nil, err
+	}
+
+	pending := []Migration{}
+	for i, s := range statuses {
+		if s.State == StatePending {
+			pending = append(pending, m.migrations[i])
+		}
+	}
+	if dryRun {
+		return pending, nil
+	}
+
+	for i, mig := range pending {
+		if err := m.apply(mig, true); err != nil {
+			return pending[:i], err
+		}
+		m.Logf("Applied migration %s", mig)
+	}
+	return pending, nil
+}
+
+// Down rolls back the last steps applied migrations, newest first. With
+// dryRun set it only returns the migrations that would be rolled back.
+// It returns ErrNoDown if one of them has no down migration.
+func (m *Migrator) Down(steps int, dryRun bool) ([]Migration, error) {
+	if err := m.init(); err != nil {
+		return nil, &Error{Src: "Down", Err: err}
+	}
+	unlock, err := m.lock()
+	if err != nil {
+		return nil, &Error{Src: "Down", Err: err}
+	}
+	defer unlock()
+
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+	if err := verify(statuses); err != nil {
+		return nil, err
+	}
+
+	rollback := []Migration{}
+	for i := len(m.migrations) - 1; i >= 0 && len(rollback) < steps; i-- {
+		if statuses[i].State != StateApplied {
+			continue
+		}
+		if !m.migrations[i].hasDown() {
+			return nil, &Error{Src: "Down", Version: m.migrations[i].Version, Err: ErrNoDown}
+		}
+		rollback = append(rollback, m.migrations[i])
+	}
+	if dryRun {
+		return rollback, nil
+	}
+
+	for i, mig := range rollback {
+		if err := m.apply(mig, false); err != nil {
+			return rollback[:i], err
+		}
+		m.Logf("Rolled back migration %s", mig)
+	}
+	return rollback, nil
+}
+
+// apply runs the up or down step of mig and records the result in
+// schema_migrations within a single transaction.
+func (m *Migrator) apply(mig Migration, up bool) error {
+	src := "Up"
+	if !up {
+		src = "Down"
+	}
+
+	tx, err := m.db.Begin()
+	if err != nil {
+		return &Error{Src: src, Version: mig.Version, Err: err}
+	}
+	defer tx.Rollback()
+
+	switch {
+	case up && mig.UpFunc != nil:
+		err = mig.UpFunc(tx)
+	case up:
+		_, err = tx.Exec(mig.UpSQL)
+	case mig.DownFunc != nil:
+		err = mig.DownFunc(tx)
+	default:
+		_, err = tx.Exec(mig.DownSQL)
+	}
+	if err != nil {
+		return &Error{Src: src, Version: mig.Version, Err: err}
+	}
+
+	if up {
+		_, err = tx.Exec("INSERT INTO schema_migrations (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)",
+			mig.Version, mig.Name, mig.Checksum(), time.Now().UTC().Format(time.RFC3339))
+	} else {
+		_, err = tx.Exec("DELETE FROM schema_migrations WHERE version = ?", mig.Version)
+	}
+	if err != nil {
+		return &Error{Src: src, Version: mig.Version, Err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return &Error{Src: src, Version: mig.Version, Err: err}
+	}
+	return nil
+}
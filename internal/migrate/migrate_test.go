@@ -0,0 +1,352 @@
+package migrate
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newMigrator(t *testing.T, db *sql.DB, migrations []Migration) *Migrator {
+	m, err := New(db, migrations)
+	require.NoError(t, err)
+	m.Logf = t.Logf
+	return m
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = ?", name).Scan(&count))
+	return count > 0
+}
+
+var testFS = fstest.MapFS{
+	"0001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+	"0001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"0002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER); INSERT INTO b VALUES (1);")},
+	"0002_create_b.down.sql": {Data: []byte("DROP TABLE b;")},
+	"README.md":              {Data: []byte("not a migration")},
+}
+
+func TestLoad(t *testing.T) {
+	// Act
+	migrations, err := Load(testFS)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, migrations, 2)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "create_a", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE a;", migrations[0].DownSQL)
+	assert.Equal(t, 2, migrations[1].Version)
+}
+
+func TestLoad_InvalidFiles(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name":     {"create_a.up.sql": {Data: []byte("SELECT 1")}},
+		"only down":    {"0001_a.down.sql": {Data: []byte("SELECT 1")}},
+		"name clashes": {"0001_a.up.sql": {Data: []byte("SELECT 1")}, "0001_b.down.sql": {Data: []byte("SELECT 1")}},
+	}
+	for name, fsys := range cases {
+		_, err := Load(fsys)
+		assert.ErrorIs(t, err, ErrInvalidMigration, name)
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	migrations, err := Load(testFS)
+	require.NoError(t, err)
+	m := newMigrator(t, db, migrations)
+
+	// Act
+	planned, dryErr := m.Up(true)
+	afterDryRun := tableExists(t, db, "a")
+	applied, upErr := m.Up(false)
+	again, againErr := m.Up(false)
+
+	// Assert
+	assert.NoError(t, dryErr)
+	assert.Len(t, planned, 2)
+	assert.False(t, afterDryRun)
+	assert.NoError(t, upErr)
+	assert.Len(t, applied, 2)
+	assert.True(t, tableExists(t, db, "a"))
+	assert.True(t, tableExists(t, db, "b"))
+	assert.NoError(t, againErr)
+	assert.Empty(t, again)
+
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	for _, s := range statuses {
+		assert.Equal(t, StateApplied, s.State)
+		assert.WithinDuration(t, time.Now(), s.AppliedAt, time.Minute)
+	}
+}
+
+func TestMigrator_UpGoMigration(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	m := newMigrator(t, db, []Migration{{
+		Version: 1,
+		Name:    "go",
+		UpFunc: func(tx *sql.Tx) error {
+			_, err := tx.Exec("CREATE TABLE c (id INTEGER)")
+			return err
+		},
+	}})
+
+	// Act
+	_, err := m.Up(false)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.True(t, tableExists(t, db, "c"))
+}
+
+func TestMigrator_UpFailureRollsBack(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	m := newMigrator(t, db, []Migration{
+		{Version: 1, Name: "ok", UpSQL: "CREATE TABLE a (id INTEGER);"},
+		{Version: 2, Name: "broken", UpSQL: "CREATE TABLE b (id INTEGER); NOT SQL;"},
+	})
+
+	// Act
+	applied, err := m.Up(false)
+
+	// Assert
+	assert.Error(t, err)
+	assert.Len(t, applied, 1)
+	assert.True(t, tableExists(t, db, "a"))
+	assert.False(t, tableExists(t, db, "b"))
+	statuses, err := m.Status()
+	require.NoError(t, err)
+	assert.Equal(t, StatePending, statuses[1].State)
+}
+
+func TestMigrator_ChecksumMismatch(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	_, err := newMigrator(t, db, []Migration{{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id INTEGER);"}}).Up(false)
+	require.NoError(t, err)
+	edited := newMigrator(t, db, []Migration{{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id TEXT);"}})
+
+	// Act
+	_, err = edited.Up(false)
+	statuses, statusErr := edited.Status()
+
+	// Assert
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.NoError(t, statusErr)
+	assert.Equal(t, StateModified, statuses[0].State)
+}
+
+func TestMigrator_UnknownMigration(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	_, err := newMigrator(t, db, []Migration{
+		{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id INTEGER);"},
+		{Version: 2, Name: "b", UpSQL: "CREATE TABLE b (id INTEGER);"},
+	}).Up(false)
+	require.NoError(t, err)
+	older := newMigrator(t, db, []Migration{{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id INTEGER);"}})
+
+	// Act
+	_, err = older.Up(false)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrUnknownMigration)
+}
+
+func TestMigrator_Down(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	migrations, err := Load(testFS)
+	require.NoError(t, err)
+	m := newMigrator(t, db, migrations)
+	_, err = m.Up(false)
+	require.NoError(t, err)
+
+	// Act
+	planned, dryErr := m.Down(1, true)
+	rolledBack, downErr := m.Down(1, false)
+
+	// Assert
+	assert.NoError(t, dryErr)
+	require.Len(t, planned, 1)
+	assert.Equal(t, 2, planned[0].Version)
+	assert.NoError(t, downErr)
+	require.Len(t, rolledBack, 1)
+	assert.True(t, tableExists(t, db, "a"))
+	assert.False(t, tableExists(t, db, "b"))
+
+	// Re-applying runs the rolled back migration again.
+	applied, err := m.Up(false)
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1)
+	assert.True(t, tableExists(t, db, "b"))
+}
+
+func TestMigrator_DownWithoutDownMigration(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	m := newMigrator(t, db, []Migration{{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id INTEGER);"}})
+	_, err := m.Up(false)
+	require.NoError(t, err)
+
+	// Act
+	_, err = m.Down(1, false)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoDown)
+	assert.True(t, tableExists(t, db, "a"))
+}
+
+func TestMigrator_Lock(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	m := newMigrator(t, db, []Migration{{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id INTEGER);"}})
+	m.LockTimeout = 200 * time.Millisecond
+	require.NoError(t, m.init())
+	_, err := db.Exec("INSERT INTO schema_migrations_lock (id, locked_at) VALUES (1, ?)", time.Now().UTC().Format(time.RFC3339))
+	require.NoError(t, err)
+
+	// Act
+	_, lockedErr := m.Up(false)
+	m.StaleLockAge = 0
+	time.Sleep(time.Second)
+	_, staleErr := m.Up(false)
+
+	// Assert
+	assert.True(t, errors.Is(lockedErr, ErrLocked))
+	assert.NoError(t, staleErr)
+	assert.True(t, tableExists(t, db, "a"))
+}
+
+func TestMigrator_LockRefresh(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	m := newMigrator(t, db, nil)
+	m.LockRefresh = 50 * time.Millisecond
+	require.NoError(t, m.init())
+	old := time.Now().UTC().Add(-time.Hour).Format(time.RFC3339)
+
+	// Act
+	unlock, err := m.lock()
+	require.NoError(t, err)
+	_, updateErr := db.Exec("UPDATE schema_migrations_lock SET locked_at = ?", old)
+	time.Sleep(200 * time.Millisecond)
+	var lockedAt string
+	lockedAtErr := db.QueryRow("SELECT locked_at FROM schema_migrations_lock").Scan(&lockedAt)
+	unlock()
+	var count int
+	countErr := db.QueryRow("SELECT count(*) FROM schema_migrations_lock").Scan(&count)
+
+	// Assert
+	assert.NoError(t, updateErr)
+	assert.NoError(t, lockedAtErr)
+	assert.Greater(t, lockedAt, old)
+	assert.NoError(t, countErr)
+	assert.Equal(t, 0, count)
+}
+
+func TestMigrator_UnlockKeepsTakenOverLock(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	m := newMigrator(t, db, nil)
+	require.NoError(t, m.init())
+
+	// Act
+	unlock, err := m.lock()
+	require.NoError(t, err)
+	_, takeOverErr := db.Exec("UPDATE schema_migrations_lock SET token = 'other'")
+	unlock()
+	var token string
+	tokenErr := db.QueryRow("SELECT token FROM schema_migrations_lock").Scan(&token)
+
+	// Assert
+	assert.NoError(t, takeOverErr)
+	assert.NoError(t, tokenErr)
+	assert.Equal(t, "other", token)
+}
+
+func TestMigrator_InitAddsLockToken(t *testing.T) {
+	// Arrange
+	db := openDB(t)
+	_, err := db.Exec("CREATE TABLE schema_migrations_lock (id INTEGER PRIMARY KEY CHECK (id = 1), locked_at TEXT NOT NULL)")
+	require.NoError(t, err)
+	m := newMigrator(t, db, []Migration{{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id INTEGER);"}})
+
+	// Act
+	_, upErr := m.Up(false)
+
+	// Assert
+	assert.NoError(t, upErr)
+	assert.True(t, tableExists(t, db, "a"))
+}
+
+func TestMigrator_ConcurrentRunners(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "test.db")
+	migrations := []Migration{{Version: 1, Name: "a", UpSQL: "CREATE TABLE a (id INTEGER);"}}
+	errs := make(chan error, 4)
+
+	// Act
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000")
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer db.Close()
+			m, err := New(db, migrations)
+			if err != nil {
+				errs <- err
+				return
+			}
+			m.Logf = t.Logf
+			_, err = m.Up(false)
+			errs <- err
+		}()
+	}
+
+	// Assert
+	for i := 0; i < cap(errs); i++ {
+		assert.NoError(t, <-errs)
+	}
+	db, err := sql.Open("sqlite3", path)
+	require.NoError(t, err)
+	defer db.Close()
+	var count int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM schema_migrations").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestNew_InvalidMigrations(t *testing.T) {
+	cases := map[string][]Migration{
+		"duplicate version": {{Version: 1, Name: "a", UpSQL: "SELECT 1"}, {Version: 1, Name: "b", UpSQL: "SELECT 1"}},
+		"no up step":        {{Version: 1, Name: "a"}},
+		"zero version":      {{Version: 0, Name: "a", UpSQL: "SELECT 1"}},
+	}
+	for name, migrations := range cases {
+		_, err := New(nil, migrations)
+		assert.ErrorIs(t, err, ErrInvalidMigration, name)
+	}
+}
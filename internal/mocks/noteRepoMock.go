@@ -1,6 +1,9 @@
 package mocks
 
 import (
+	"context"
+	"time"
+
 	"github.com/JannisK89/notes-api/internal/models"
 	"github.com/stretchr/testify/mock"
 )
@@ -11,31 +14,175 @@ type NoteRepoMock struct {
 }
 
 // Get mocks the Get method of the NoteRepository interface
-func (m *NoteRepoMock) Get(id int) (*models.Note, error) {
-	args := m.Called(id)
+func (m *NoteRepoMock) Get(ctx context.Context, workspaceId int, id int) (*models.Note, error) {
+	args := m.Called(ctx, workspaceId, id)
 	return args.Get(0).(*models.Note), args.Error(1)
 }
 
-// GetAll mocks the GetAll method of the NoteRepository interface
-func (m *NoteRepoMock) GetAll() ([]*models.Note, error) {
-	args := m.Called()
-	return args.Get(0).([]*models.Note), args.Error(1)
-}
-
 // Create mocks the Create method of the NoteRepository interface
-func (m *NoteRepoMock) Create(note *models.Note) (int, error) {
-	args := m.Called(note)
+func (m *NoteRepoMock) Create(ctx context.Context, workspaceId int, userId int, note *models.Note) (int, error) {
+	args := m.Called(ctx, workspaceId, userId, note)
 	return args.Int(0), args.Error(1)
 }
 
+// GetAll mocks the GetAll method of the NoteRepository interface
+func (m *NoteRepoMock) GetAll(ctx context.Context, workspaceId int, opts models.ListOptions) (*models.NotePage, error) {
+	args := m.Called(ctx, workspaceId, opts)
+	return args.Get(0).(*models.NotePage), args.Error(1)
+}
+
 // Update mocks the Update method of the NoteRepository interface
-func (m *NoteRepoMock) Update(id int, note *models.Note) error {
-	args := m.Called(id, note)
+func (m *NoteRepoMock) Update(ctx context.Context, workspaceId int, id int, note *models.Note, version int) error {
+	args := m.Called(ctx, workspaceId, id, note, version)
 	return args.Error(0)
 }
 
 // Delete mocks the Delete method of the NoteRepository interface
-func (m *NoteRepoMock) Delete(id int) error {
-	args := m.Called(id)
+func (m *NoteRepoMock) Delete(ctx context.Context, workspaceId int, id int, version int) error {
+	args := m.Called(ctx, workspaceId, id, version)
+	return args.Error(0)
+}
+
+// Search mocks the Search method of the NoteRepository interface
+func (m *NoteRepoMock) Search(ctx context.Context, workspaceId int, opts models.SearchOptions) ([]*models.SearchResult, error) {
+	args := m.Called(ctx, workspaceId, opts)
+	return args.Get(0).([]*models.SearchResult), args.Error(1)
+}
+
+// Restore mocks the Restore method of the NoteRepository interface
+func (m *NoteRepoMock) Restore(ctx context.Context, workspaceId int, id int) (*models.Note, error) {
+	args := m.Called(ctx, workspaceId, id)
+	return args.Get(0).(*models.Note), args.Error(1)
+}
+
+// Purge mocks the Purge method of the NoteRepository interface
+func (m *NoteRepoMock) Purge(ctx context.Context, workspaceId int, id int) error {
+	args := m.Called(ctx, workspaceId, id)
+	return args.Error(0)
+}
+
+// PurgeDeletedBefore mocks the PurgeDeletedBefore method of the NoteRepository interface
+func (m *NoteRepoMock) PurgeDeletedBefore(ctx context.Context, t time.Time) (int, error) {
+	args := m.Called(ctx, t)
+	return args.Int(0), args.Error(1)
+}
+
+// GetRevisions mocks the GetRevisions method of the NoteRepository interface
+func (m *NoteRepoMock) GetRevisions(ctx context.Context, workspaceId int, id int) ([]*models.Revision, error) {
+	args := m.Called(ctx, workspaceId, id)
+	return args.Get(0).([]*models.Revision), args.Error(1)
+}
+
+// GetRevision mocks the GetRevision method of the NoteRepository interface
+func (m *NoteRepoMock) GetRevision(ctx context.Context, workspaceId int, id int, version int) (*models.Revision, error) {
+	args := m.Called(ctx, workspaceId, id, version)
+	return args.Get(0).(*models.Revision), args.Error(1)
+}
+
+// GetTags mocks the GetTags method of the NoteRepository interface
+func (m *NoteRepoMock) GetTags(ctx context.Context, workspaceId int) ([]*models.Tag, error) {
+	args := m.Called(ctx, workspaceId)
+	return args.Get(0).([]*models.Tag), args.Error(1)
+}
+
+// RenameTag mocks the RenameTag method of the NoteRepository interface
+func (m *NoteRepoMock) RenameTag(ctx context.Context, workspaceId int, name string, newName string) (int, error) {
+	args := m.Called(ctx, workspaceId, name, newName)
+	return args.Int(0), args.Error(1)
+}
+
+// MergeTags mocks the MergeTags method of the NoteRepository interface
+func (m *NoteRepoMock) MergeTags(ctx context.Context, workspaceId int, source string, target string) (int, error) {
+	args := m.Called(ctx, workspaceId, source, target)
+	return args.Int(0), args.Error(1)
+}
+
+// MoveNote mocks the MoveNote method of the NoteRepository interface
+func (m *NoteRepoMock) MoveNote(ctx context.Context, workspaceId int, id int, notebookId *int, version int) error {
+	args := m.Called(ctx, workspaceId, id, notebookId, version)
+	return args.Error(0)
+}
+
+// GetNoteAccess mocks the GetNoteAccess method of the NoteRepository interface
+func (m *NoteRepoMock) GetNoteAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error) {
+	args := m.Called(ctx, workspaceId, userId, id)
+	return args.Get(0).(models.Role), args.Error(1)
+}
+
+// GetNotebookAccess mocks the GetNotebookAccess method of the NoteRepository interface
+func (m *NoteRepoMock) GetNotebookAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error) {
+	args := m.Called(ctx, workspaceId, userId, id)
+	return args.Get(0).(models.Role), args.Error(1)
+}
+
+// GetNoteShares mocks the GetNoteShares method of the NoteRepository interface
+func (m *NoteRepoMock) GetNoteShares(ctx context.Context, workspaceId int, id int) ([]*models.Share, error) {
+	args := m.Called(ctx, workspaceId, id)
+	return args.Get(0).([]*models.Share), args.Error(1)
+}
+
+// ShareNote mocks the ShareNote method of the NoteRepository interface
+func (m *NoteRepoMock) ShareNote(ctx context.Context, workspaceId int, id int, email string, role models.Role) (*models.Share, error) {
+	args := m.Called(ctx, workspaceId, id, email, role)
+	return args.Get(0).(*models.Share), args.Error(1)
+}
+
+// RevokeNoteShare mocks the RevokeNoteShare method of the NoteRepository interface
+func (m *NoteRepoMock) RevokeNoteShare(ctx context.Context, workspaceId int, id int, userId int) error {
+	args := m.Called(ctx, workspaceId, id, userId)
+	return args.Error(0)
+}
+
+// GetSharedNotes mocks the GetSharedNotes method of the NoteRepository interface
+func (m *NoteRepoMock) GetSharedNotes(ctx context.Context, workspaceId int, userId int) ([]*models.SharedNote, error) {
+	args := m.Called(ctx, workspaceId, userId)
+	return args.Get(0).([]*models.SharedNote), args.Error(1)
+}
+
+// CreateShareLink mocks the CreateShareLink method of the NoteRepository interface
+func (m *NoteRepoMock) CreateShareLink(ctx context.Context, workspaceId int, noteId int, link *models.ShareLink, tokenHash string, passwordHash string) (int, error) {
+	args := m.Called(ctx, workspaceId, noteId, link, tokenHash, passwordHash)
+	return args.Int(0), args.Error(1)
+}
+
+// GetShareLinks mocks the GetShareLinks method of the NoteRepository interface
+func (m *NoteRepoMock) GetShareLinks(ctx context.Context, workspaceId int, noteId int) ([]*models.ShareLink, error) {
+	args := m.Called(ctx, workspaceId, noteId)
+	return args.Get(0).([]*models.ShareLink), args.Error(1)
+}
+
+// DeleteShareLink mocks the DeleteShareLink method of the NoteRepository interface
+func (m *NoteRepoMock) DeleteShareLink(ctx context.Context, workspaceId int, noteId int, id int) error {
+	args := m.Called(ctx, workspaceId, noteId, id)
+	return args.Error(0)
+}
+
+// GetShareLink mocks the GetShareLink method of the NoteRepository interface
+func (m *NoteRepoMock) GetShareLink(ctx context.Context, tokenHash string) (*models.ShareLink, string, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(*models.ShareLink), args.String(1), args.Error(2)
+}
+
+// ViewShareLink mocks the ViewShareLink method of the NoteRepository interface
+func (m *NoteRepoMock) ViewShareLink(ctx context.Context, id int) (*models.Note, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(*models.Note), args.Error(1)
+}
+
+// GetChanges mocks the GetChanges method of the NoteRepository interface
+func (m *NoteRepoMock) GetChanges(ctx context.Context, workspaceId int, since int64, limit int) (*models.ChangePage, error) {
+	args := m.Called(ctx, workspaceId, since, limit)
+	return args.Get(0).(*models.ChangePage), args.Error(1)
+}
+
+// GetPushedNote mocks the GetPushedNote method of the NoteRepository interface
+func (m *NoteRepoMock) GetPushedNote(ctx context.Context, workspaceId int, userId int, clientId string) (*models.Note, error) {
+	args := m.Called(ctx, workspaceId, userId, clientId)
+	return args.Get(0).(*models.Note), args.Error(1)
+}
+
+// SetClientId mocks the SetClientId method of the NoteRepository interface
+func (m *NoteRepoMock) SetClientId(ctx context.Context, workspaceId int, id int, clientId string) error {
+	args := m.Called(ctx, workspaceId, id, clientId)
 	return args.Error(0)
 }
@@ -0,0 +1,77 @@
+package mocks
+
+import (
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// NotebookRepoMock is a mock for the NotebookRepository interface
+type NotebookRepoMock struct {
+	mock.Mock
+}
+
+// Get mocks the Get method of the NotebookRepository interface
+func (m *NotebookRepoMock) Get(workspaceId int, id int) (*models.Notebook, error) {
+	args := m.Called(workspaceId, id)
+	return args.Get(0).(*models.Notebook), args.Error(1)
+}
+
+// GetAll mocks the GetAll method of the NotebookRepository interface
+func (m *NotebookRepoMock) GetAll(workspaceId int) ([]*models.Notebook, error) {
+	args := m.Called(workspaceId)
+	return args.Get(0).([]*models.Notebook), args.Error(1)
+}
+
+// Create mocks the Create method of the NotebookRepository interface
+func (m *NotebookRepoMock) Create(workspaceId int, userId int, notebook *models.Notebook) (int, error) {
+	args := m.Called(workspaceId, userId, notebook)
+	return args.Int(0), args.Error(1)
+}
+
+// Rename mocks the Rename method of the NotebookRepository interface
+func (m *NotebookRepoMock) Rename(workspaceId int, id int, name string) error {
+	args := m.Called(workspaceId, id, name)
+	return args.Error(0)
+}
+
+// Move mocks the Move method of the NotebookRepository interface
+func (m *NotebookRepoMock) Move(workspaceId int, id int, parentId *int) error {
+	args := m.Called(workspaceId, id, parentId)
+	return args.Error(0)
+}
+
+// Delete mocks the Delete method of the NotebookRepository interface
+func (m *NotebookRepoMock) Delete(workspaceId int, id int, mode models.NotebookDeleteMode) error {
+	args := m.Called(workspaceId, id, mode)
+	return args.Error(0)
+}
+
+// GetNotebookAccess mocks the GetNotebookAccess method of the NotebookRepository interface
+func (m *NotebookRepoMock) GetNotebookAccess(workspaceId int, userId int, id int) (models.Role, error) {
+	args := m.Called(workspaceId, userId, id)
+	return args.Get(0).(models.Role), args.Error(1)
+}
+
+// GetNotebookShares mocks the GetNotebookShares method of the NotebookRepository interface
+func (m *NotebookRepoMock) GetNotebookShares(workspaceId int, id int) ([]*models.Share, error) {
+	args := m.Called(workspaceId, id)
+	return args.Get(0).([]*models.Share), args.Error(1)
+}
+
+// ShareNotebook mocks the ShareNotebook method of the NotebookRepository interface
+func (m *NotebookRepoMock) ShareNotebook(workspaceId int, id int, email string, role models.Role) (*models.Share, error) {
+	args := m.Called(workspaceId, id, email, role)
+	return args.Get(0).(*models.Share), args.Error(1)
+}
+
+// RevokeNotebookShare mocks the RevokeNotebookShare method of the NotebookRepository interface
+func (m *NotebookRepoMock) RevokeNotebookShare(workspaceId int, id int, userId int) error {
+	args := m.Called(workspaceId, id, userId)
+	return args.Error(0)
+}
+
+// GetSharedNotebooks mocks the GetSharedNotebooks method of the NotebookRepository interface
+func (m *NotebookRepoMock) GetSharedNotebooks(workspaceId int, userId int) ([]*models.SharedNotebook, error) {
+	args := m.Called(workspaceId, userId)
+	return args.Get(0).([]*models.SharedNotebook), args.Error(1)
+}
@@ -0,0 +1,67 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// UserRepoMock is a mock for the UserRepository interface
+type UserRepoMock struct {
+	mock.Mock
+}
+
+// Create mocks the Create method of the UserRepository interface
+func (m *UserRepoMock) Create(user *models.User) (int, error) {
+	args := m.Called(user)
+	return args.Int(0), args.Error(1)
+}
+
+// GetByEmail mocks the GetByEmail method of the UserRepository interface
+func (m *UserRepoMock) GetByEmail(email string) (*models.User, error) {
+	args := m.Called(email)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+// CreateSession mocks the CreateSession method of the UserRepository interface
+func (m *UserRepoMock) CreateSession(userId int, tokenHash string, expiresAt time.Time) error {
+	args := m.Called(userId, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+// GetSessionUser mocks the GetSessionUser method of the UserRepository interface
+func (m *UserRepoMock) GetSessionUser(tokenHash string) (*models.User, error) {
+	args := m.Called(tokenHash)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+// DeleteSession mocks the DeleteSession method of the UserRepository interface
+func (m *UserRepoMock) DeleteSession(tokenHash string) error {
+	args := m.Called(tokenHash)
+	return args.Error(0)
+}
+
+// CreateAPIKey mocks the CreateAPIKey method of the UserRepository interface
+func (m *UserRepoMock) CreateAPIKey(userId int, key *models.APIKey, keyHash string) (int, error) {
+	args := m.Called(userId, key, keyHash)
+	return args.Int(0), args.Error(1)
+}
+
+// GetAPIKeys mocks the GetAPIKeys method of the UserRepository interface
+func (m *UserRepoMock) GetAPIKeys(userId int) ([]*models.APIKey, error) {
+	args := m.Called(userId)
+	return args.Get(0).([]*models.APIKey), args.Error(1)
+}
+
+// DeleteAPIKey mocks the DeleteAPIKey method of the UserRepository interface
+func (m *UserRepoMock) DeleteAPIKey(userId int, id int) error {
+	args := m.Called(userId, id)
+	return args.Error(0)
+}
+
+// GetAPIKeyUser mocks the GetAPIKeyUser method of the UserRepository interface
+func (m *UserRepoMock) GetAPIKeyUser(keyHash string) (*models.User, *models.APIKey, error) {
+	args := m.Called(keyHash)
+	return args.Get(0).(*models.User), args.Get(1).(*models.APIKey), args.Error(2)
+}
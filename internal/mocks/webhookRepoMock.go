@@ -0,0 +1,61 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// WebhookRepoMock is a mock for the WebhookRepository interface
+type WebhookRepoMock struct {
+	mock.Mock
+}
+
+// GetAll mocks the GetAll method of the WebhookRepository interface
+func (m *WebhookRepoMock) GetAll(workspaceId int) ([]*models.Webhook, error) {
+	args := m.Called(workspaceId)
+	return args.Get(0).([]*models.Webhook), args.Error(1)
+}
+
+// Create mocks the Create method of the WebhookRepository interface
+func (m *WebhookRepoMock) Create(workspaceId int, webhook *models.Webhook) (int, error) {
+	args := m.Called(workspaceId, webhook)
+	return args.Int(0), args.Error(1)
+}
+
+// Delete mocks the Delete method of the WebhookRepository interface
+func (m *WebhookRepoMock) Delete(workspaceId int, id int) error {
+	args := m.Called(workspaceId, id)
+	return args.Error(0)
+}
+
+// GetDeliveries mocks the GetDeliveries method of the WebhookRepository interface
+func (m *WebhookRepoMock) GetDeliveries(workspaceId int, webhookId int) ([]*models.WebhookDelivery, error) {
+	args := m.Called(workspaceId, webhookId)
+	return args.Get(0).([]*models.WebhookDelivery), args.Error(1)
+}
+
+// Redeliver mocks the Redeliver method of the WebhookRepository interface
+func (m *WebhookRepoMock) Redeliver(workspaceId int, webhookId int, id int) (*models.WebhookDelivery, error) {
+	args := m.Called(workspaceId, webhookId, id)
+	return args.Get(0).(*models.WebhookDelivery), args.Error(1)
+}
+
+// GetDueDeliveries mocks the GetDueDeliveries method of the WebhookRepository interface
+func (m *WebhookRepoMock) GetDueDeliveries(t time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	args := m.Called(t, limit)
+	return args.Get(0).([]*models.WebhookDelivery), args.Error(1)
+}
+
+// ClaimDelivery mocks the ClaimDelivery method of the WebhookRepository interface
+func (m *WebhookRepoMock) ClaimDelivery(delivery *models.WebhookDelivery, t time.Time, until time.Time) error {
+	args := m.Called(delivery, t, until)
+	return args.Error(0)
+}
+
+// UpdateDelivery mocks the UpdateDelivery method of the WebhookRepository interface
+func (m *WebhookRepoMock) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	args := m.Called(delivery)
+	return args.Error(0)
+}
@@ -0,0 +1,53 @@
+package mocks
+
+import (
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// WorkspaceRepoMock is a mock for the WorkspaceRepository interface
+type WorkspaceRepoMock struct {
+	mock.Mock
+}
+
+// GetMember mocks the GetMember method of the WorkspaceRepository interface
+func (m *WorkspaceRepoMock) GetMember(workspaceId int, userId int) (*models.Member, error) {
+	args := m.Called(workspaceId, userId)
+	return args.Get(0).(*models.Member), args.Error(1)
+}
+
+// GetPersonalMember mocks the GetPersonalMember method of the WorkspaceRepository interface
+func (m *WorkspaceRepoMock) GetPersonalMember(userId int) (*models.Member, error) {
+	args := m.Called(userId)
+	return args.Get(0).(*models.Member), args.Error(1)
+}
+
+// GetAll mocks the GetAll method of the WorkspaceRepository interface
+func (m *WorkspaceRepoMock) GetAll(userId int) ([]*models.Workspace, error) {
+	args := m.Called(userId)
+	return args.Get(0).([]*models.Workspace), args.Error(1)
+}
+
+// Create mocks the Create method of the WorkspaceRepository interface
+func (m *WorkspaceRepoMock) Create(userId int, workspace *models.Workspace) (int, error) {
+	args := m.Called(userId, workspace)
+	return args.Int(0), args.Error(1)
+}
+
+// GetMembers mocks the GetMembers method of the WorkspaceRepository interface
+func (m *WorkspaceRepoMock) GetMembers(workspaceId int) ([]*models.WorkspaceMember, error) {
+	args := m.Called(workspaceId)
+	return args.Get(0).([]*models.WorkspaceMember), args.Error(1)
+}
+
+// AddMember mocks the AddMember method of the WorkspaceRepository interface
+func (m *WorkspaceRepoMock) AddMember(workspaceId int, email string, role models.Role) (*models.WorkspaceMember, error) {
+	args := m.Called(workspaceId, email, role)
+	return args.Get(0).(*models.WorkspaceMember), args.Error(1)
+}
+
+// RemoveMember mocks the RemoveMember method of the WorkspaceRepository interface
+func (m *WorkspaceRepoMock) RemoveMember(workspaceId int, userId int) error {
+	args := m.Called(workspaceId, userId)
+	return args.Error(0)
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// Scope is a permission an API key grants.
+type Scope string
+
+const (
+	// ScopeNotesRead allows listing, searching and reading notes, notebooks,
+	// tags, revisions and the trash.
+	ScopeNotesRead Scope = "notes:read"
+	// ScopeNotesWrite allows creating, changing, moving and restoring notes
+	// and notebooks and renaming and merging tags.
+	ScopeNotesWrite Scope = "notes:write"
+	// ScopeNotesDelete allows moving notes to the trash, purging them and
+	// deleting notebooks.
+	ScopeNotesDelete Scope = "notes:delete"
+)
+
+// Scopes are all scopes an API key can be granted.
+var Scopes = []Scope{ScopeNotesRead, ScopeNotesWrite, ScopeNotesDelete}
+
+// APIKey gives scripts access to the notes of a user without logging in.
+// Key is only set when the key is created, since only its hash is stored.
+// Prefix is the start of the key, which identifies it in listings.
+type APIKey struct {
+	Id         int        `json:"id"`
+	Name       string     `json:"name"`
+	Key        string     `json:"key,omitempty"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []Scope    `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// HasScope reports whether the key grants scope.
+func (k *APIKey) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
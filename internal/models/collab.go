@@ -0,0 +1,61 @@
+package models
+
+import "github.com/JannisK89/notes-api/internal/crdt"
+
+// CollabMessageType names a message of a collaborative editing session.
+type CollabMessageType string
+
+const (
+	// CollabSnapshot is sent to an editor when they join. It carries their
+	// Site, the Elements, Clock, Title and Version of the note and the other
+	// Editors.
+	CollabSnapshot CollabMessageType = "snapshot"
+	// CollabInsert inserts Text after the character After, with the ids Id
+	// and following. Editors insert with their own Site.
+	CollabInsert CollabMessageType = "insert"
+	// CollabDelete deletes the characters with Ids.
+	CollabDelete CollabMessageType = "delete"
+	// CollabPresence moves the Cursor and selection Anchor of an editor.
+	// Both are the character they are placed after.
+	CollabPresence CollabMessageType = "presence"
+	// CollabLeave is sent when an editor leaves.
+	CollabLeave CollabMessageType = "leave"
+	// CollabSaved is sent when the text was stored as Version of the note.
+	CollabSaved CollabMessageType = "saved"
+	// CollabError is sent before the server closes the session of an
+	// editor, explaining why in Message.
+	CollabError CollabMessageType = "error"
+)
+
+// CollabMessage is a message of a collaborative editing session, sent by
+// editors and relayed to the others by the server. Which fields are set
+// depends on its Type. Site and UserId identify the editor a message is
+// about.
+type CollabMessage struct {
+	Type     CollabMessageType `json:"type"`
+	Site     int               `json:"site,omitempty"`
+	UserId   int               `json:"user_id,omitempty"`
+	Id       *crdt.Id          `json:"id,omitempty"`
+	After    *crdt.Id          `json:"after,omitempty"`
+	Text     string            `json:"text,omitempty"`
+	Ids      []crdt.Id         `json:"ids,omitempty"`
+	Cursor   *crdt.Id          `json:"cursor,omitempty"`
+	Anchor   *crdt.Id          `json:"anchor,omitempty"`
+	Elements []crdt.Element    `json:"elements,omitempty"`
+	Clock    int               `json:"clock,omitempty"`
+	Title    string            `json:"title,omitempty"`
+	Version  int               `json:"version,omitempty"`
+	ReadOnly bool              `json:"read_only,omitempty"`
+	Editors  []*CollabEditor   `json:"editors,omitempty"`
+	Message  string            `json:"message,omitempty"`
+}
+
+// CollabEditor is a user in a collaborative editing session. Cursor and
+// Anchor are nil until they are first placed.
+type CollabEditor struct {
+	Site     int      `json:"site"`
+	UserId   int      `json:"user_id"`
+	ReadOnly bool     `json:"read_only"`
+	Cursor   *crdt.Id `json:"cursor,omitempty"`
+	Anchor   *crdt.Id `json:"anchor,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// EventType names a change an Event reports.
+type EventType string
+
+const (
+	EventNoteCreated EventType = "note.created"
+	EventNoteUpdated EventType = "note.updated"
+	EventNoteDeleted EventType = "note.deleted"
+	// EventReset tells a client that events it asked to resume from are no
+	// longer available and it has to reload the notes.
+	EventReset EventType = "reset"
+)
+
+// Event reports a change to a note of a workspace. Note is the note after
+// the change and nil for deleted notes. Events streamed to clients carry
+// ids, which increase with every event, and the user who made the change.
+// Webhook payloads have neither.
+type Event struct {
+	Id          int       `json:"id,omitempty"`
+	Type        EventType `json:"type"`
+	WorkspaceId int       `json:"workspace_id"`
+	UserId      int       `json:"user_id,omitempty"`
+	NoteId      int       `json:"note_id"`
+	Note        *Note     `json:"note,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
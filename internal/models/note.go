@@ -1,7 +1,99 @@
 package models
 
+import "time"
+
+// Note is a single note. CreatedAt, UpdatedAt, Version and DeletedAt are
+// managed by the repository and ignored when a note is created or updated.
+// Version starts at 1 and is incremented on every update. DeletedAt is set
+// while the note is in the trash. Tags are kept sorted by name. NotebookId is
+// the notebook the note is filed in, or nil if it is in none. It can be set
+// when the note is created and is changed by moving the note.
 type Note struct {
-	Id      int    `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	Id         int        `json:"id"`
+	Title      string     `json:"title"`
+	Content    string     `json:"content"`
+	Tags       []string   `json:"tags,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	Version    int        `json:"version"`
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	NotebookId *int       `json:"notebook_id,omitempty"`
+}
+
+// SortField names a note attribute that lists of notes can be ordered by.
+type SortField string
+
+const (
+	SortById        SortField = "id"
+	SortByTitle     SortField = "title"
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+	SortByDeletedAt SortField = "deleted_at"
+)
+
+// TagMode selects whether a note must carry all or any of the tags listed
+// notes are filtered by.
+type TagMode string
+
+const (
+	TagModeAll TagMode = "all"
+	TagModeAny TagMode = "any"
+)
+
+// ListOptions controls which notes are returned when listing notes and in
+// what order. Results are paginated with an opaque cursor returned from the
+// previous page.
+type ListOptions struct {
+	Limit  int
+	Cursor string
+	Sort   SortField
+	Desc   bool
+
+	// Title and Content filter notes by a case-insensitive substring match.
+	Title   string
+	Content string
+
+	// Tags filters notes by the tags they carry, according to TagMode.
+	Tags    []string
+	TagMode TagMode
+
+	// NotebookId only lists the notes filed in that notebook, and with
+	// Recursive also those in the notebooks below it. It is ignored when 0.
+	NotebookId int
+	Recursive  bool
+
+	// Trashed lists the notes in the trash instead of the regular notes.
+	Trashed bool
+
+	// The time filters are inclusive and ignored when zero.
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+}
+
+// NotePage is a single page of notes. NextCursor is empty when HasMore is
+// false.
+type NotePage struct {
+	Notes      []*Note
+	NextCursor string
+	HasMore    bool
+}
+
+// SearchOptions holds a full-text search query and the window of ranked
+// results to return.
+type SearchOptions struct {
+	Query  string
+	Limit  int
+	Offset int
+}
+
+// SearchResult is a note matching a search query. Higher scores are better
+// matches. Highlight is the title and Snippet an excerpt of the content,
+// HTML-escaped, with the matched terms wrapped in <mark> tags.
+type SearchResult struct {
+	Note
+	Score     float64 `json:"score"`
+	Highlight string  `json:"highlight"`
+	Snippet   string  `json:"snippet"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// Notebook is a folder of notes. Notebooks nest: ParentId is the notebook
+// containing it, or nil for a top-level notebook. Path, CreatedAt and
+// UpdatedAt are managed by the repository.
+type Notebook struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	ParentId  *int      `json:"parent_id"`
+	Path      string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NotebookDeleteMode selects what happens to the contents of a notebook when
+// it is deleted.
+type NotebookDeleteMode string
+
+const (
+	// NotebookDeleteBlock only deletes empty notebooks.
+	NotebookDeleteBlock NotebookDeleteMode = "block"
+	// NotebookDeleteTrash moves all notes in the notebook and the notebooks
+	// below it to the trash and deletes those notebooks.
+	NotebookDeleteTrash NotebookDeleteMode = "trash"
+	// NotebookDeleteMove moves the notes and notebooks in the notebook to
+	// its parent.
+	NotebookDeleteMove NotebookDeleteMode = "move"
+)
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/diff"
+)
+
+// Revision is the title and content of a note as of one of its versions.
+// A revision is recorded whenever a note is created or its title or content
+// is written.
+type Revision struct {
+	NoteId    int       `json:"note_id"`
+	Version   int       `json:"version"`
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// DiffFormat selects how the content changes of a RevisionDiff are given.
+type DiffFormat string
+
+const (
+	// DiffUnified gives the content changes as a unified diff of its lines.
+	DiffUnified DiffFormat = "unified"
+	// DiffWords gives the content changes as a list of word edits.
+	DiffWords DiffFormat = "words"
+)
+
+// RevisionDiff holds the changes between two revisions of a note. Title
+// changes are always given word by word, content changes in Unified or
+// Content depending on the requested format.
+type RevisionDiff struct {
+	From    int         `json:"from"`
+	To      int         `json:"to"`
+	Title   []diff.Edit `json:"title"`
+	Content []diff.Edit `json:"content,omitempty"`
+	Unified string      `json:"unified,omitempty"`
+}
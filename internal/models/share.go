@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Role is the level of access a user has to a workspace, note or notebook.
+type Role string
+
+const (
+	// RoleViewer can read a note or the notes in a notebook.
+	RoleViewer Role = "viewer"
+	// RoleEditor can additionally change them.
+	RoleEditor Role = "editor"
+	// RoleOwner is the user who created a note or notebook, or an owner of
+	// its workspace. Only owners can delete, move and share them.
+	RoleOwner Role = "owner"
+)
+
+// roleRanks orders the roles by how much they allow.
+var roleRanks = map[Role]int{RoleViewer: 1, RoleEditor: 2, RoleOwner: 3}
+
+// Includes reports whether r allows everything other allows.
+func (r Role) Includes(other Role) bool {
+	return roleRanks[r] > 0 && roleRanks[r] >= roleRanks[other]
+}
+
+// Share grants a user access to a note or notebook. Sharing a notebook
+// shares all notes and notebooks below it.
+type Share struct {
+	UserId    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SharedNote is a note another user shared with the current user, directly
+// or through a notebook.
+type SharedNote struct {
+	*Note
+	Owner string `json:"owner"`
+	Role  Role   `json:"role"`
+}
+
+// SharedNotebook is a notebook another user shared with the current user.
+type SharedNotebook struct {
+	*Notebook
+	Owner string `json:"owner"`
+	Role  Role   `json:"role"`
+}
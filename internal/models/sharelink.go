@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ShareLink lets anyone who knows its token read a note without an account.
+// Token and URL are only set when the link is created, since only the hash
+// of the token is stored, and Prefix identifies the link afterwards. A link stops working
+// once ExpiresAt has passed or it has been viewed MaxViews times.
+type ShareLink struct {
+	Id          int        `json:"id"`
+	NoteId      int        `json:"note_id"`
+	Token       string     `json:"token,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Prefix      string     `json:"prefix"`
+	HasPassword bool       `json:"has_password"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	MaxViews    *int       `json:"max_views"`
+	Views       int        `json:"views"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// PublicNote is the part of a note shown through a share link.
+type PublicNote struct {
+	Title     string    `json:"title"`
+	Content   string    `json:"content"`
+	Tags      []string  `json:"tags"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// Change is an entry of the change feed of a workspace. Seq increases with
+// every write to a note, so a client that has seen the changes up to a Seq
+// only needs the changes after it. Deleted changes are tombstones of notes
+// that were moved to the trash or purged and have no Note.
+type Change struct {
+	Seq       int64      `json:"seq"`
+	NoteId    int        `json:"note_id"`
+	Deleted   bool       `json:"deleted"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Note      *Note      `json:"note,omitempty"`
+}
+
+// ChangePage is a page of the change feed of a workspace, in order. Seq is
+// the Seq of its last change, or the Seq it was requested after if it is
+// empty, and is passed to request the next page.
+type ChangePage struct {
+	Changes []*Change `json:"changes"`
+	Seq     int64     `json:"seq"`
+	HasMore bool      `json:"has_more"`
+}
+
+// PushChange is a change a client made to a note while it was offline. It
+// creates a note if Id is 0, and otherwise updates or, if Deleted is set,
+// deletes the note if it is still at the Version the client changed.
+// ClientId is chosen by the client to match the results of the notes it
+// created.
+type PushChange struct {
+	ClientId string `json:"client_id,omitempty"`
+	Id       int    `json:"id,omitempty"`
+	Version  int    `json:"version,omitempty"`
+	Deleted  bool   `json:"deleted,omitempty"`
+	Note     *Note  `json:"note,omitempty"`
+}
+
+// PushStatus is the outcome of a PushChange.
+type PushStatus string
+
+const (
+	// PushApplied changes were stored.
+	PushApplied PushStatus = "applied"
+	// PushConflict changes were made to a version of the note that is no
+	// longer current, or to a note that has been deleted since.
+	PushConflict PushStatus = "conflict"
+	// PushRejected changes were not allowed for the user.
+	PushRejected PushStatus = "rejected"
+)
+
+// PushResult is the outcome of a PushChange. Note is the note as stored if
+// the change was applied, and the current note on the server, or nil if it
+// was deleted, if there was a conflict. Error explains rejections.
+type PushResult struct {
+	ClientId string     `json:"client_id,omitempty"`
+	Id       int        `json:"id"`
+	Status   PushStatus `json:"status"`
+	Note     *Note      `json:"note,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
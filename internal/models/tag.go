@@ -0,0 +1,8 @@
+package models
+
+// Tag is a tag along with the number of notes carrying it. Notes in the
+// trash are not counted.
+type Tag struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// User is an account notes belong to. PasswordHash is never sent to
+// clients.
+type User struct {
+	Id           int       `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Session is the result of logging in. Token authenticates the requests of
+// the user until it expires or the user logs out.
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Ticket authenticates a single request of a user that cannot carry an
+// Authorization header, such as opening a WebSocket from a browser. It is
+// passed in the ticket query parameter and can only be used once, before it
+// expires.
+type Ticket struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook is a URL that is called with the changes to the notes of a
+// workspace whose type is among Events. Secret signs the deliveries and is
+// only shown when the webhook is created.
+type Webhook struct {
+	Id        int         `json:"id"`
+	URL       string      `json:"url"`
+	Events    []EventType `json:"events"`
+	Secret    string      `json:"secret,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// DeliveryStatus is the state of a WebhookDelivery.
+type DeliveryStatus string
+
+const (
+	// DeliveryPending deliveries are sent at NextAttemptAt.
+	DeliveryPending DeliveryStatus = "pending"
+	// DeliverySucceeded deliveries were accepted by the webhook.
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryDead deliveries failed too often and are no longer retried
+	// unless they are redelivered.
+	DeliveryDead DeliveryStatus = "dead"
+)
+
+// WebhookDelivery is an event sent, or to be sent, to a webhook together
+// with the outcome of the last attempt. URL and Secret are those of the
+// webhook, for sending it, and LeasedUntil is when the lease of the
+// dispatcher sending it ends.
+type WebhookDelivery struct {
+	Id             int             `json:"id"`
+	WebhookId      int             `json:"webhook_id"`
+	Event          EventType       `json:"event"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         DeliveryStatus  `json:"status"`
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	LastStatusCode int             `json:"last_status_code,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+	URL            string          `json:"-"`
+	Secret         string          `json:"-"`
+	LeasedUntil    *time.Time      `json:"-"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Workspace holds notes, notebooks and tags, which are only visible to its
+// members. Every user has a personal workspace that nobody else can join.
+// Role is the role of the current user in it.
+type Workspace struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	Personal  bool      `json:"personal"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WorkspaceMember is a user who belongs to a workspace with a role.
+type WorkspaceMember struct {
+	UserId    int       `json:"user_id"`
+	Email     string    `json:"email"`
+	Role      Role      `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Member is a user acting in the workspace selected for a request. Members
+// have Role on every note and notebook in the workspace. Guests, who were
+// only shared some of them, have an empty Role.
+type Member struct {
+	WorkspaceId int
+	UserId      int
+	Role        Role
+}
@@ -0,0 +1,124 @@
+package patch
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePatch(t *testing.T) {
+	// Cases from RFC 7396, Appendix A.
+	cases := []struct{ doc, patch, want string }{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+	for _, c := range cases {
+		p, err := ParseMergePatch([]byte(c.patch))
+		require.NoError(t, err)
+
+		got, err := p.Apply([]byte(c.doc))
+
+		assert.NoError(t, err, c.patch)
+		assert.JSONEq(t, c.want, string(got), c.patch)
+	}
+}
+
+func TestParseMergePatch_Invalid(t *testing.T) {
+	_, err := ParseMergePatch([]byte(`{"a":`))
+
+	assert.ErrorIs(t, err, ErrInvalidPatch)
+}
+
+func TestJSONPatch(t *testing.T) {
+	// Cases from RFC 6902, Appendix A.
+	cases := []struct{ name, doc, patch, want string }{
+		{"add member", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{"add array element", `{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{"remove member", `{"baz":"qux","foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, `{"foo":"bar"}`},
+		{"remove array element", `{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{"replace", `{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{"move member", `{"foo":{"bar":"baz","waldo":"fred"},"qux":{"corge":"grault"}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{"move array element", `{"foo":["all","grass","cows","eat"]}`, `[{"op":"move","from":"/foo/1","path":"/foo/3"}]`, `{"foo":["all","cows","eat","grass"]}`},
+		{"test", `{"baz":"qux","foo":["a",2,"c"]}`, `[{"op":"test","path":"/baz","value":"qux"},{"op":"test","path":"/foo/1","value":2}]`, `{"baz":"qux","foo":["a",2,"c"]}`},
+		{"add nested member", `{"foo":"bar"}`, `[{"op":"add","path":"/child","value":{"grandchild":{}}}]`, `{"foo":"bar","child":{"grandchild":{}}}`},
+		{"ignore unknown members", `{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux","xyz":123}]`, `{"foo":"bar","baz":"qux"}`},
+		{"escaped pointer", `{"/":9,"~1":10}`, `[{"op":"test","path":"/~01","value":10},{"op":"replace","path":"/~1","value":1}]`, `{"/":1,"~1":10}`},
+		{"append to array", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":["abc","def"]}]`, `{"foo":["bar",["abc","def"]]}`},
+		{"copy", `{"foo":{"a":1}}`, `[{"op":"copy","from":"/foo","path":"/bar"},{"op":"replace","path":"/bar/a","value":2}]`, `{"foo":{"a":1},"bar":{"a":2}}`},
+		{"nested arrays", `{"a":[[1,2],[3]]}`, `[{"op":"add","path":"/a/0/1","value":9},{"op":"remove","path":"/a/1/0"}]`, `{"a":[[1,9,2],[]]}`},
+		{"replace document", `{"a":1}`, `[{"op":"replace","path":"","value":{"b":2}}]`, `{"b":2}`},
+	}
+	for _, c := range cases {
+		p, err := ParseJSONPatch([]byte(c.patch))
+		require.NoError(t, err, c.name)
+
+		got, err := p.Apply([]byte(c.doc))
+
+		assert.NoError(t, err, c.name)
+		assert.JSONEq(t, c.want, string(got), c.name)
+	}
+}
+
+func TestJSONPatch_Errors(t *testing.T) {
+	cases := []struct {
+		name, doc, patch string
+		want             error
+	}{
+		{"missing member", `{"foo":"bar"}`, `[{"op":"remove","path":"/baz"}]`, ErrInvalidPatch},
+		{"missing parent", `{"foo":"bar"}`, `[{"op":"add","path":"/baz/bat","value":"qux"}]`, ErrInvalidPatch},
+		{"index out of bounds", `{"foo":["bar"]}`, `[{"op":"add","path":"/foo/2","value":"qux"}]`, ErrInvalidPatch},
+		{"leading zero index", `{"foo":["bar","baz"]}`, `[{"op":"remove","path":"/foo/01"}]`, ErrInvalidPatch},
+		{"move into itself", `{"foo":{"bar":1}}`, `[{"op":"move","from":"/foo","path":"/foo/bar/baz"}]`, ErrInvalidPatch},
+		{"failed test", `{"baz":"qux"}`, `[{"op":"test","path":"/baz","value":"bar"}]`, ErrTestFailed},
+		{"string is not number", `{"baz":"10"}`, `[{"op":"test","path":"/baz","value":10}]`, ErrTestFailed},
+		{"test missing member", `{"baz":"qux"}`, `[{"op":"test","path":"/foo","value":"bar"}]`, ErrTestFailed},
+	}
+	for _, c := range cases {
+		p, err := ParseJSONPatch([]byte(c.patch))
+		require.NoError(t, err, c.name)
+
+		_, err = p.Apply([]byte(c.doc))
+
+		assert.ErrorIs(t, err, c.want, c.name)
+	}
+}
+
+func TestParseJSONPatch_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"not an array":  `{"op":"add","path":"/a","value":1}`,
+		"unknown op":    `[{"op":"frobnicate","path":"/a"}]`,
+		"missing value": `[{"op":"add","path":"/a"}]`,
+		"bad path":      `[{"op":"remove","path":"a"}]`,
+		"bad from":      `[{"op":"move","from":"a","path":"/b"}]`,
+	}
+	for name, patch := range cases {
+		_, err := ParseJSONPatch([]byte(patch))
+
+		assert.ErrorIs(t, err, ErrInvalidPatch, name)
+	}
+}
+
+func TestJSONPatch_Atomic(t *testing.T) {
+	p, err := ParseJSONPatch([]byte(`[{"op":"replace","path":"/a","value":2},{"op":"test","path":"/a","value":3}]`))
+	require.NoError(t, err)
+	doc := []byte(`{"a":1}`)
+
+	_, err = p.Apply(doc)
+
+	assert.ErrorIs(t, err, ErrTestFailed)
+	assert.JSONEq(t, `{"a":1}`, string(doc))
+}
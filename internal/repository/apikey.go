@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// ErrAPIKeyNotFound is returned when an API key is unknown, expired or
+// belongs to another user.
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// lastUsedInterval is how stale the last use of an API key may get before
+// it is written again, so keys used for every request do not write for
+// every request.
+const lastUsedInterval = time.Minute
+
+// apiKeyColumns are the columns scanned by scanAPIKey, in order.
+const apiKeyColumns = "api_keys.id, api_keys.name, api_keys.prefix, api_keys.scopes, api_keys.created_at, api_keys.expires_at, api_keys.last_used_at"
+
+// parseNullTime parses a nullable time column stored in TimeFormat.
+func parseNullTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(TimeFormat, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// formatNullTime formats t in TimeFormat, or returns nil if t is nil.
+func formatNullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return formatTime(*t)
+}
+
+// scanAPIKey reads an API key selected with apiKeyColumns from row.
+func scanAPIKey(row scanner, dest ...interface{}) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var scopes, createdAt string
+	var expiresAt, lastUsedAt sql.NullString
+	err := row.Scan(append([]interface{}{&key.Id, &key.Name, &key.Prefix, &scopes, &createdAt, &expiresAt, &lastUsedAt}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	for _, scope := range strings.Fields(scopes) {
+		key.Scopes = append(key.Scopes, models.Scope(scope))
+	}
+	if key.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	if key.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+		return nil, err
+	}
+	if key.LastUsedAt, err = parseNullTime(lastUsedAt); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CreateAPIKey stores an API key of a user by the hash of the key and sets
+// its creation time.
+func (r *userRepository) CreateAPIKey(userId int, key *models.APIKey, keyHash string) (int, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+	var id int64
+	err := r.db.QueryRow(`INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_at, expires_at)
+    VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`,
+		userId, key.Name, key.Prefix, keyHash, strings.Join(scopes, " "), formatTime(now), formatNullTime(key.ExpiresAt)).Scan(&id)
+	if err != nil {
+		return 0, &RepoError{Src: "CreateAPIKey", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	key.Id, key.CreatedAt = int(id), now
+	return int(id), nil
+}
+
+// GetAPIKeys retrieves the API keys of a user, including expired ones,
+// newest first.
+func (r *userRepository) GetAPIKeys(userId int) ([]*models.APIKey, error) {
+	rows, err := r.db.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY id DESC", userId)
+	if err != nil {
+		return nil, &RepoError{Src: "GetAPIKeys", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	keys := []*models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, &RepoError{Src: "GetAPIKeys", Err: fmt.Errorf("DB Error: %w", err)}
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetAPIKeys", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return keys, nil
+}
+
+// DeleteAPIKey revokes an API key of a user.
+// It returns ErrAPIKeyNotFound if the user has no key with the id.
+func (r *userRepository) DeleteAPIKey(userId int, id int) error {
+	res, err := r.db.Exec("DELETE FROM api_keys WHERE id = ? AND user_id = ?", id, userId)
+	if err != nil {
+		return &RepoError{"DeleteAPIKeyByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"DeleteAPIKeyByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		return &RepoError{"DeleteAPIKeyByID", id, ErrAPIKeyNotFound}
+	}
+	return nil
+}
+
+// GetAPIKeyUser retrieves the API key stored under keyHash and its user and
+// records that the key was used.
+// It returns ErrAPIKeyNotFound if there is no such key or it has expired.
+func (r *userRepository) GetAPIKeyUser(keyHash string) (*models.User, *models.APIKey, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	user := &models.User{}
+	var createdAt string
+	row := r.db.QueryRow(`SELECT `+apiKeyColumns+`, `+userColumns+` FROM api_keys JOIN users ON users.id = api_keys.user_id
+    WHERE api_keys.key_hash = ? AND (api_keys.expires_at IS NULL OR api_keys.expires_at > ?)`, keyHash, formatTime(now))
+	key, err := scanAPIKey(row, &user.Id, &user.Email, &user.PasswordHash, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, &RepoError{Src: "GetAPIKeyUser", Err: ErrAPIKeyNotFound}
+	}
+	if err != nil {
+		return nil, nil, &RepoError{Src: "GetAPIKeyUser", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if user.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, nil, &RepoError{Src: "GetAPIKeyUser", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedInterval {
+		if _, err := r.db.Exec("UPDATE api_keys SET last_used_at = ? WHERE id = ?", formatTime(now), key.Id); err != nil {
+			return nil, nil, &RepoError{"GetAPIKeyUser", key.Id, fmt.Errorf("DB Error: %w", err)}
+		}
+		key.LastUsedAt = &now
+	}
+	return user, key, nil
+}
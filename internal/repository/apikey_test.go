@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// apiKeyRows returns the rows a query selecting apiKeyColumns and then
+// userColumns yields for a key of user.
+func apiKeyRows(key *models.APIKey, user *models.User) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "prefix", "scopes", "created_at", "expires_at", "last_used_at", "id", "email", "password_hash", "created_at"})
+	var lastUsedAt interface{}
+	if key.LastUsedAt != nil {
+		lastUsedAt = formatTime(*key.LastUsedAt)
+	}
+	return rows.AddRow(key.Id, key.Name, key.Prefix, "notes:read notes:write", formatTime(key.CreatedAt), nil, lastUsedAt,
+		user.Id, user.Email, user.PasswordHash, formatTime(user.CreatedAt))
+}
+
+func TestUserRepository_CreateAPIKey(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+	repo.now = func() time.Time { return created }
+	expiresAt := updated
+	key := &models.APIKey{Name: "Backup", Prefix: "nk_abcdefgh", Scopes: []models.Scope{models.ScopeNotesRead, models.ScopeNotesWrite}, ExpiresAt: &expiresAt}
+
+	mock.ExpectQuery("INSERT INTO api_keys").
+		WithArgs(testUserId, "Backup", "nk_abcdefgh", "hash", "notes:read notes:write", "2024-05-01T09:30:00.000Z", "2024-05-02T17:45:30.250Z").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+
+	// Act
+	id, err := repo.CreateAPIKey(testUserId, key, "hash")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+	assert.Equal(t, 3, key.Id)
+	assert.Equal(t, created, key.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetAPIKeyUser(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+	repo.now = func() time.Time { return updated }
+	user := &models.User{Id: testUserId, Email: "ada@example.com", PasswordHash: "hash", CreatedAt: created}
+	recently := updated.Add(-time.Second)
+	key := &models.APIKey{Id: 3, Name: "Backup", Prefix: "nk_abcdefgh", CreatedAt: created}
+
+	mock.ExpectQuery("FROM api_keys JOIN users").WithArgs("stale", "2024-05-02T17:45:30.250Z").WillReturnRows(apiKeyRows(key, user))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE api_keys SET last_used_at = ? WHERE id = ?")).
+		WithArgs("2024-05-02T17:45:30.250Z", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	key.LastUsedAt = &recently
+	mock.ExpectQuery("FROM api_keys JOIN users").WithArgs("fresh", "2024-05-02T17:45:30.250Z").WillReturnRows(apiKeyRows(key, user))
+	mock.ExpectQuery("FROM api_keys JOIN users").WithArgs("expired", "2024-05-02T17:45:30.250Z").WillReturnRows(sqlmock.NewRows(nil))
+
+	// Act
+	staleUser, staleKey, staleErr := repo.GetAPIKeyUser("stale")
+	_, freshKey, freshErr := repo.GetAPIKeyUser("fresh")
+	_, _, expiredErr := repo.GetAPIKeyUser("expired")
+
+	// Assert
+	assert.NoError(t, staleErr)
+	assert.Equal(t, user, staleUser)
+	assert.Equal(t, []models.Scope{models.ScopeNotesRead, models.ScopeNotesWrite}, staleKey.Scopes)
+	assert.Equal(t, updated, *staleKey.LastUsedAt)
+	assert.NoError(t, freshErr)
+	assert.Equal(t, recently, *freshKey.LastUsedAt)
+	assert.ErrorIs(t, expiredErr, ErrAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_DeleteAPIKey(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM api_keys WHERE id = ? AND user_id = ?")).WithArgs(3, testUserId).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM api_keys WHERE id = ? AND user_id = ?")).WithArgs(4, testUserId).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.DeleteAPIKey(testUserId, 3)
+	notFoundErr := repo.DeleteAPIKey(testUserId, 4)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, notFoundErr, ErrAPIKeyNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
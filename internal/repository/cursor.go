@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded or
+// was issued for a different sort order.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor is the decoded form of the opaque pagination cursor. It records the
+// sort order it was issued for and the sort key of the last note on the
+// previous page, so the next page can continue right after it.
+type cursor struct {
+	Sort  models.SortField `json:"s"`
+	Desc  bool             `json:"d,omitempty"`
+	Value string           `json:"v,omitempty"`
+	Id    int              `json:"i"`
+}
+
+func encodeCursor(c cursor) string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor parses an opaque cursor and checks that it matches the sort
+// order of the current request.
+func decodeCursor(s string, sort models.SortField, desc bool) (*cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	c := &cursor{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != sort || c.Desc != desc {
+		return nil, ErrInvalidCursor
+	}
+	return c, nil
+}
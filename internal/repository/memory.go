@@ -0,0 +1,727 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// MemoryStore holds the data of the memory repositories in maps instead of a
+// database, for tests and demos that should not leave a database behind. The
+// memory repositories keep it consistent the way the queries, triggers and
+// constraints of the database do, so they behave like the repositories for
+// SQLite: IDs are never reused, timestamps have millisecond precision and
+// lists come in the same order. Writes are serialized by a single lock.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	data *memoryData
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{data: newMemoryData()}
+}
+
+// LoadMemoryStore creates a MemoryStore with the data of the snapshot at
+// path written by MemoryStore.Save, or an empty one if there is no file at
+// path yet.
+func LoadMemoryStore(path string) (*MemoryStore, error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewMemoryStore(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := newMemoryData()
+	if err := json.Unmarshal(b, data); err != nil {
+		return nil, fmt.Errorf("invalid snapshot %s: %w", path, err)
+	}
+	return &MemoryStore{data: data}, nil
+}
+
+// Save writes a snapshot of the data of the store to a JSON file at path.
+// The file is replaced atomically, so a crash while saving leaves the
+// previous snapshot intact.
+func (s *MemoryStore) Save(path string) error {
+	s.mu.RLock()
+	b, err := json.Marshal(s.data)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// read runs fn with the data of the store locked for reading.
+func (s *MemoryStore) read(fn func(d *memoryData) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return fn(s.data)
+}
+
+// write runs fn with the data of the store locked for writing. There are no
+// transactions to roll back, so fn checks everything that can fail before it
+// changes anything.
+func (s *MemoryStore) write(fn func(d *memoryData) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s.data)
+}
+
+// readContext is read for operations that take a context. It returns the
+// error of ctx instead of running fn if ctx is done by the time the lock is
+// acquired. Within a unit of work of the store, whose lock is held already,
+// fn runs right away.
+func (s *MemoryStore) readContext(ctx context.Context, fn func(d *memoryData) error) error {
+	if s.inUnitOfWork(ctx) {
+		return s.runContext(ctx, fn)
+	}
+	return s.read(func(*memoryData) error {
+		return s.runContext(ctx, fn)
+	})
+}
+
+// writeContext is write for operations that take a context, like
+// readContext.
+func (s *MemoryStore) writeContext(ctx context.Context, fn func(d *memoryData) error) error {
+	if s.inUnitOfWork(ctx) {
+		return s.runContext(ctx, fn)
+	}
+	return s.write(func(*memoryData) error {
+		return s.runContext(ctx, fn)
+	})
+}
+
+// runContext runs fn on the data of the store unless ctx is done. The lock
+// must be held.
+func (s *MemoryStore) runContext(ctx context.Context, fn func(d *memoryData) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn(s.data)
+}
+
+// memoryUnitOfWorkKey is the context key of the MemoryStore a unit of work
+// runs on.
+type memoryUnitOfWorkKey struct{}
+
+// inUnitOfWork reports whether ctx carries a unit of work on the store.
+func (s *MemoryStore) inUnitOfWork(ctx context.Context) bool {
+	store, _ := ctx.Value(memoryUnitOfWorkKey{}).(*MemoryStore)
+	return store == s
+}
+
+// memoryTxManager implements the TxManager interface on a MemoryStore.
+type memoryTxManager struct {
+	store *MemoryStore
+}
+
+// NewMemoryTxManager creates a new memoryTxManager for the repositories on
+// store.
+func NewMemoryTxManager(store *MemoryStore) *memoryTxManager {
+	return &memoryTxManager{store: store}
+}
+
+// WithinTx runs fn with the store locked for writing, which serializes
+// units of work like the transactions of SQLite. If fn returns an error or
+// panics, its writes are undone from the undo log the repositories keep
+// while a unit of work runs, which holds a copy of every record they wrote
+// rather than of the whole store. Called within the unit of work of another
+// WithinTx, it only marks the undo log, like a savepoint, so that only the
+// changes of fn are undone.
+// The repository calls fn makes with the context it is given take part in
+// the unit of work. Calls with another context wait until it is done, so fn
+// must not make them.
+func (m *memoryTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	s := m.store
+	if !s.inUnitOfWork(ctx) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.data.undo = &memoryUndoLog{}
+		defer func() { s.data.undo = nil }()
+		ctx = context.WithValue(ctx, memoryUnitOfWorkKey{}, s)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	savepoint := s.data.savepoint()
+
+	done := false
+	defer func() {
+		if !done {
+			s.data.rollback(savepoint)
+		}
+	}()
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	done = true
+	return nil
+}
+
+// memoryUndoLog holds the steps that undo the writes of a unit of work,
+// oldest first.
+type memoryUndoLog struct {
+	steps []func()
+}
+
+// memorySavepoint is the state of the data that rolling back a unit of work
+// to it restores: its counters, the number of tombstones, which are only
+// ever appended, and the length of the undo log.
+type memorySavepoint struct {
+	seq        int64
+	lastIds    map[string]int
+	tombstones int
+	steps      int
+}
+
+// savepoint returns the current state of the data in a unit of work.
+func (d *memoryData) savepoint() memorySavepoint {
+	lastIds := make(map[string]int, len(d.LastIds))
+	for table, id := range d.LastIds {
+		lastIds[table] = id
+	}
+	return memorySavepoint{d.Seq, lastIds, len(d.Tombstones), len(d.undo.steps)}
+}
+
+// rollback undoes the writes made in a unit of work since savepoint, newest
+// first.
+func (d *memoryData) rollback(savepoint memorySavepoint) {
+	for i := len(d.undo.steps) - 1; i >= savepoint.steps; i-- {
+		d.undo.steps[i]()
+	}
+	d.undo.steps = d.undo.steps[:savepoint.steps]
+	d.Seq, d.LastIds = savepoint.seq, savepoint.lastIds
+	d.Tombstones = d.Tombstones[:savepoint.tombstones]
+}
+
+// onRollback adds a step that undoes a write to the undo log of the unit of
+// work running on the data, if there is one.
+func (d *memoryData) onRollback(undo func()) {
+	if d.undo != nil {
+		d.undo.steps = append(d.undo.steps, undo)
+	}
+}
+
+// rememberNote records the note with id before it is written in a unit of
+// work, so that rolling it back puts the note back, or removes it if it did
+// not exist yet.
+func (d *memoryData) rememberNote(id int) {
+	if d.undo == nil {
+		return
+	}
+	if n, ok := d.Notes[id]; ok {
+		saved := n.clone()
+		d.onRollback(func() { d.Notes[id] = saved })
+	} else {
+		d.onRollback(func() { delete(d.Notes, id) })
+	}
+}
+
+// rememberShareLink records the share link with id before it is written in
+// a unit of work, like rememberNote.
+func (d *memoryData) rememberShareLink(id int) {
+	if d.undo == nil {
+		return
+	}
+	if l, ok := d.ShareLinks[id]; ok {
+		saved := *l
+		saved.ExpiresAt, saved.MaxViews = memoryNullTime(l.ExpiresAt), copyInt(l.MaxViews)
+		d.onRollback(func() { d.ShareLinks[id] = &saved })
+	} else {
+		d.onRollback(func() { delete(d.ShareLinks, id) })
+	}
+}
+
+// memoryData is the content of a MemoryStore, which is also the format of
+// its snapshots. Every record corresponds to a row of a table of the
+// database, with the rows of tables that only extend another one, like the
+// revisions and shares of notes, kept with the record they belong to.
+type memoryData struct {
+	// Seq is the last number of the sequence of writes to notes.
+	Seq int64 `json:"seq"`
+	// LastIds holds the last ID handed out for the records of each table.
+	LastIds map[string]int `json:"last_ids"`
+
+	Notes      map[int]*memoryNote             `json:"notes"`
+	Tombstones []*memoryTombstone              `json:"tombstones"`
+	ShareLinks map[int]*memoryShareLink        `json:"share_links"`
+	Notebooks  map[int]*memoryNotebook         `json:"notebooks"`
+	Users      map[int]*memoryUser             `json:"users"`
+	Sessions   map[string]*memorySession       `json:"sessions"`
+	APIKeys    map[int]*memoryAPIKey           `json:"api_keys"`
+	Workspaces map[int]*memoryWorkspace        `json:"workspaces"`
+	Webhooks   map[int]*memoryWebhook          `json:"webhooks"`
+	Deliveries map[int]*models.WebhookDelivery `json:"deliveries"`
+
+	// undo is the undo log of the unit of work running on the data, or nil
+	// if there is none.
+	undo *memoryUndoLog
+}
+
+func newMemoryData() *memoryData {
+	return &memoryData{
+		LastIds:    map[string]int{},
+		Notes:      map[int]*memoryNote{},
+		Tombstones: []*memoryTombstone{},
+		ShareLinks: map[int]*memoryShareLink{},
+		Notebooks:  map[int]*memoryNotebook{},
+		Users:      map[int]*memoryUser{},
+		Sessions:   map[string]*memorySession{},
+		APIKeys:    map[int]*memoryAPIKey{},
+		Workspaces: map[int]*memoryWorkspace{},
+		Webhooks:   map[int]*memoryWebhook{},
+		Deliveries: map[int]*models.WebhookDelivery{},
+	}
+}
+
+// nextId returns the next ID of the records of table.
+func (d *memoryData) nextId(table string) int {
+	d.LastIds[table]++
+	return d.LastIds[table]
+}
+
+// memoryTime returns t as stored in the database, in UTC with millisecond
+// precision.
+func memoryTime(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Millisecond)
+}
+
+// memoryNullTime is memoryTime for optional times.
+func memoryNullTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	stored := memoryTime(*t)
+	return &stored
+}
+
+// copyInt returns a copy of the optional integer p.
+func copyInt(p *int) *int {
+	if p == nil {
+		return nil
+	}
+	n := *p
+	return &n
+}
+
+// memoryRole is a role granted to a user on a note, notebook or workspace.
+type memoryRole struct {
+	Role      models.Role `json:"role"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// memoryNote is a note along with its revisions, oldest first, and the
+// users it is shared with.
+type memoryNote struct {
+	Id          int        `json:"id"`
+	WorkspaceId int        `json:"workspace_id"`
+	UserId      int        `json:"user_id"`
+	Title       string     `json:"title"`
+	Content     string     `json:"content"`
+	Tags        []string   `json:"tags,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Version     int        `json:"version"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	NotebookId  *int       `json:"notebook_id,omitempty"`
+	Seq         int64      `json:"seq"`
+	ClientId    string     `json:"client_id,omitempty"`
+
+	Revisions []*models.Revision  `json:"revisions"`
+	Shares    map[int]*memoryRole `json:"shares,omitempty"`
+}
+
+// model returns a copy of the note as it is returned by the repositories.
+func (n *memoryNote) model() *models.Note {
+	note := &models.Note{
+		Id:         n.Id,
+		Title:      n.Title,
+		Content:    n.Content,
+		CreatedAt:  n.CreatedAt,
+		UpdatedAt:  n.UpdatedAt,
+		Version:    n.Version,
+		DeletedAt:  memoryNullTime(n.DeletedAt),
+		NotebookId: copyInt(n.NotebookId),
+	}
+	if len(n.Tags) > 0 {
+		note.Tags = append([]string{}, n.Tags...)
+	}
+	return note
+}
+
+// clone returns a deep copy of the note. Revisions are never changed once
+// they are recorded, so they are shared with the copy.
+func (n *memoryNote) clone() *memoryNote {
+	c := *n
+	c.Tags = append([]string(nil), n.Tags...)
+	c.DeletedAt, c.NotebookId = memoryNullTime(n.DeletedAt), copyInt(n.NotebookId)
+	c.Revisions = append([]*models.Revision(nil), n.Revisions...)
+	if n.Shares != nil {
+		c.Shares = make(map[int]*memoryRole, len(n.Shares))
+		for userId, share := range n.Shares {
+			role := *share
+			c.Shares[userId] = &role
+		}
+	}
+	return &c
+}
+
+// live reports whether the note belongs to a workspace and is not in the
+// trash.
+func (n *memoryNote) live(workspaceId int) bool {
+	return n.WorkspaceId == workspaceId && n.DeletedAt == nil
+}
+
+// memoryTombstone records that a note was purged.
+type memoryTombstone struct {
+	NoteId      int       `json:"note_id"`
+	WorkspaceId int       `json:"workspace_id"`
+	Seq         int64     `json:"seq"`
+	DeletedAt   time.Time `json:"deleted_at"`
+}
+
+// memoryShareLink is a share link along with the hashes of its token and
+// password, which is empty if it has none.
+type memoryShareLink struct {
+	Id           int        `json:"id"`
+	NoteId       int        `json:"note_id"`
+	Prefix       string     `json:"prefix"`
+	TokenHash    string     `json:"token_hash"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	MaxViews     *int       `json:"max_views,omitempty"`
+	Views        int        `json:"views"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// model returns a copy of the link as it is returned by the repositories.
+func (l *memoryShareLink) model() *models.ShareLink {
+	return &models.ShareLink{
+		Id:          l.Id,
+		NoteId:      l.NoteId,
+		Prefix:      l.Prefix,
+		HasPassword: l.PasswordHash != "",
+		ExpiresAt:   memoryNullTime(l.ExpiresAt),
+		MaxViews:    copyInt(l.MaxViews),
+		Views:       l.Views,
+		CreatedAt:   l.CreatedAt,
+	}
+}
+
+// active reports whether the link can still be viewed at now.
+func (l *memoryShareLink) active(now time.Time) bool {
+	return (l.ExpiresAt == nil || l.ExpiresAt.After(now)) && (l.MaxViews == nil || l.Views < *l.MaxViews)
+}
+
+// memoryNotebook is a notebook along with the users it is shared with.
+type memoryNotebook struct {
+	Id          int       `json:"id"`
+	WorkspaceId int       `json:"workspace_id"`
+	UserId      int       `json:"user_id"`
+	Name        string    `json:"name"`
+	ParentId    *int      `json:"parent_id,omitempty"`
+	Path        string    `json:"path"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+
+	Shares map[int]*memoryRole `json:"shares,omitempty"`
+}
+
+// model returns a copy of the notebook as it is returned by the
+// repositories.
+func (n *memoryNotebook) model() *models.Notebook {
+	return &models.Notebook{
+		Id:        n.Id,
+		Name:      n.Name,
+		ParentId:  copyInt(n.ParentId),
+		Path:      n.Path,
+		CreatedAt: n.CreatedAt,
+		UpdatedAt: n.UpdatedAt,
+	}
+}
+
+// memoryUser is a user along with the hash of their password.
+type memoryUser struct {
+	Id           int       `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// model returns a copy of the user as it is returned by the repositories.
+func (u *memoryUser) model() *models.User {
+	return &models.User{Id: u.Id, Email: u.Email, PasswordHash: u.PasswordHash, CreatedAt: u.CreatedAt}
+}
+
+// memorySession is a session, stored by the hash of its token.
+type memorySession struct {
+	UserId    int       `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// memoryAPIKey is an API key along with its hash.
+type memoryAPIKey struct {
+	Id         int            `json:"id"`
+	UserId     int            `json:"user_id"`
+	Name       string         `json:"name"`
+	Prefix     string         `json:"prefix"`
+	KeyHash    string         `json:"key_hash"`
+	Scopes     []models.Scope `json:"scopes"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ExpiresAt  *time.Time     `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time     `json:"last_used_at,omitempty"`
+}
+
+// model returns a copy of the key as it is returned by the repositories.
+func (k *memoryAPIKey) model() *models.APIKey {
+	key := &models.APIKey{
+		Id:         k.Id,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		CreatedAt:  k.CreatedAt,
+		ExpiresAt:  memoryNullTime(k.ExpiresAt),
+		LastUsedAt: memoryNullTime(k.LastUsedAt),
+	}
+	if len(k.Scopes) > 0 {
+		key.Scopes = append([]models.Scope{}, k.Scopes...)
+	}
+	return key
+}
+
+// memoryWorkspace is a workspace along with its members.
+type memoryWorkspace struct {
+	Id             int                 `json:"id"`
+	Name           string              `json:"name"`
+	PersonalUserId *int                `json:"personal_user_id,omitempty"`
+	CreatedAt      time.Time           `json:"created_at"`
+	Members        map[int]*memoryRole `json:"members"`
+}
+
+// memoryWebhook is a webhook along with its secret.
+type memoryWebhook struct {
+	Id          int                `json:"id"`
+	WorkspaceId int                `json:"workspace_id"`
+	URL         string             `json:"url"`
+	Secret      string             `json:"secret"`
+	Events      []models.EventType `json:"events"`
+	CreatedAt   time.Time          `json:"created_at"`
+}
+
+// userByEmail returns the user with the given email, compared
+// case-insensitively, or nil if there is none.
+func (d *memoryData) userByEmail(email string) *memoryUser {
+	for _, user := range d.Users {
+		if strings.EqualFold(user.Email, email) {
+			return user
+		}
+	}
+	return nil
+}
+
+// lessEmail orders emails case-insensitively, like the NOCASE collation of
+// the email column.
+func lessEmail(a string, b string) bool {
+	return strings.ToLower(a) < strings.ToLower(b)
+}
+
+// sortedIds returns the keys of m in ascending order.
+func sortedIds[T any](m map[int]T) []int {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// createWorkspace adds a workspace with userId as its owner and returns its
+// ID, like createWorkspace does in the database.
+func (d *memoryData) createWorkspace(userId int, name string, personal bool, now time.Time) int {
+	workspace := &memoryWorkspace{
+		Id:        d.nextId("workspaces"),
+		Name:      name,
+		CreatedAt: now,
+		Members:   map[int]*memoryRole{userId: {models.RoleOwner, now}},
+	}
+	if personal {
+		workspace.PersonalUserId = &userId
+	}
+	d.Workspaces[workspace.Id] = workspace
+	return workspace.Id
+}
+
+// touch gives a note the next number of the sequence of writes, like the
+// triggers of the database do for every write to a note.
+func (d *memoryData) touch(note *memoryNote) {
+	d.Seq++
+	note.Seq = d.Seq
+}
+
+// removeNote permanently removes a note along with its share links and
+// leaves a tombstone, like the triggers of the database do when a note is
+// deleted.
+func (d *memoryData) removeNote(note *memoryNote, now time.Time) {
+	d.rememberNote(note.Id)
+	delete(d.Notes, note.Id)
+	for id, link := range d.ShareLinks {
+		if link.NoteId == note.Id {
+			d.rememberShareLink(id)
+			delete(d.ShareLinks, id)
+		}
+	}
+	d.Seq++
+	d.Tombstones = append(d.Tombstones, &memoryTombstone{note.Id, note.WorkspaceId, d.Seq, memoryTime(now)})
+}
+
+// enqueueEvent adds a pending delivery of an event about note to every
+// webhook of a workspace that subscribed to typ, like enqueueEvent does in
+// the database.
+func (d *memoryData) enqueueEvent(workspaceId int, typ models.EventType, note *memoryNote, now time.Time) error {
+	var payload []byte
+	for _, id := range sortedIds(d.Webhooks) {
+		webhook := d.Webhooks[id]
+		if webhook.WorkspaceId != workspaceId || !hasEvent(webhook.Events, typ) {
+			continue
+		}
+		if payload == nil {
+			e := models.Event{Type: typ, WorkspaceId: workspaceId, NoteId: note.Id, CreatedAt: now}
+			if typ != models.EventNoteDeleted {
+				e.Note = note.model()
+			}
+			var err error
+			if payload, err = json.Marshal(e); err != nil {
+				return err
+			}
+		}
+		at := memoryTime(now)
+		delivery := &models.WebhookDelivery{
+			Id:            d.nextId("webhook_deliveries"),
+			WebhookId:     webhook.Id,
+			Event:         typ,
+			Payload:       json.RawMessage(payload),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: &at,
+			CreatedAt:     at,
+		}
+		d.Deliveries[delivery.Id] = delivery
+		d.onRollback(func() { delete(d.Deliveries, delivery.Id) })
+	}
+	return nil
+}
+
+// hasEvent reports whether events contains typ.
+func hasEvent(events []models.EventType, typ models.EventType) bool {
+	for _, event := range events {
+		if event == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// pathRank returns the highest role userId was granted on a notebook with
+// the given path or one above it, as ranked by notebookShareRank.
+func (d *memoryData) pathRank(userId int, path string) int {
+	rank := 0
+	for _, notebook := range d.Notebooks {
+		if share, ok := notebook.Shares[userId]; ok && strings.HasPrefix(path, notebook.Path) {
+			rank = max(rank, roleRank(share.Role))
+		}
+	}
+	return rank
+}
+
+// noteRank returns the highest role userId was granted on note, directly or
+// through the notebook it is filed in or one above it, as ranked by
+// noteShareRank.
+func (d *memoryData) noteRank(userId int, note *memoryNote) int {
+	rank := 0
+	if share, ok := note.Shares[userId]; ok {
+		rank = roleRank(share.Role)
+	}
+	if note.NotebookId != nil {
+		if notebook, ok := d.Notebooks[*note.NotebookId]; ok {
+			rank = max(rank, d.pathRank(userId, notebook.Path))
+		}
+	}
+	return rank
+}
+
+// roleRank ranks a shared role like noteShareRank.
+func roleRank(role models.Role) int {
+	if role == models.RoleEditor {
+		return 2
+	}
+	return 1
+}
+
+// guest reports whether a user was shared a note not in the trash or a
+// notebook of a workspace, like guestQuery.
+func (d *memoryData) guest(workspaceId int, userId int) bool {
+	for _, note := range d.Notes {
+		if note.WorkspaceId == workspaceId && note.DeletedAt == nil && note.Shares[userId] != nil {
+			return true
+		}
+	}
+	for _, notebook := range d.Notebooks {
+		if notebook.WorkspaceId == workspaceId && notebook.Shares[userId] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// memoryShares returns the owner of a note or notebook followed by the
+// users it is shared with in shares, ordered by email, like getShares.
+func (d *memoryData) memoryShares(ownerId int, createdAt time.Time, shares map[int]*memoryRole) []*models.Share {
+	result := []*models.Share{}
+	if owner, ok := d.Users[ownerId]; ok {
+		result = append(result, &models.Share{UserId: owner.Id, Email: owner.Email, Role: models.RoleOwner, CreatedAt: createdAt})
+	}
+	shared := []*models.Share{}
+	for userId, share := range shares {
+		if user, ok := d.Users[userId]; ok {
+			shared = append(shared, &models.Share{UserId: userId, Email: user.Email, Role: share.Role, CreatedAt: share.CreatedAt})
+		}
+	}
+	sort.Slice(shared, func(i, j int) bool { return lessEmail(shared[i].Email, shared[j].Email) })
+	return append(result, shared...)
+}
+
+// memoryShare grants user role in shares, or changes the role if it is
+// already granted, and returns the share.
+func memoryShare(shares map[int]*memoryRole, user *memoryUser, role models.Role, now time.Time) *models.Share {
+	share, ok := shares[user.Id]
+	if !ok {
+		share = &memoryRole{CreatedAt: memoryTime(now)}
+		shares[user.Id] = share
+	}
+	share.Role = role
+	return &models.Share{UserId: user.Id, Email: user.Email, Role: role, CreatedAt: share.CreatedAt}
+}
@@ -0,0 +1,626 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// memoryNoteRepository implements the NoteRepository interface on a
+// MemoryStore.
+type memoryNoteRepository struct {
+	store *MemoryStore
+	now   func() time.Time
+
+	// MaxRevisions is the number of revisions kept per note. Older revisions
+	// are removed when a new one is recorded. 0 keeps all revisions.
+	MaxRevisions int
+}
+
+// NewMemoryNotesRepository creates a new memoryNoteRepository.
+func NewMemoryNotesRepository(store *MemoryStore) *memoryNoteRepository {
+	return &memoryNoteRepository{store: store, now: time.Now, MaxRevisions: DefaultMaxRevisions}
+}
+
+// Get retrieves a note of a workspace by its ID.
+// It returns ErrNoteNotFound if the note is not found, belongs to another
+// workspace or is in the trash.
+func (r *memoryNoteRepository) Get(ctx context.Context, workspaceId int, id int) (*models.Note, error) {
+	var note *models.Note
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || !n.live(workspaceId) {
+			return &RepoError{"GetNoteByID", id, ErrNoteNotFound}
+		}
+		note = n.model()
+		return nil
+	})
+	return note, err
+}
+
+// GetAll retrieves a page of the notes of a workspace, filtered and ordered
+// according to opts, like noteRepository.GetAll.
+// It returns ErrInvalidCursor if opts.Cursor was not issued for the same
+// sort order.
+func (r *memoryNoteRepository) GetAll(ctx context.Context, workspaceId int, opts models.ListOptions) (*models.NotePage, error) {
+	if opts.Sort == "" {
+		opts.Sort = models.SortById
+	}
+	if _, ok := sortColumns[opts.Sort]; !ok {
+		return nil, &RepoError{Src: "GetAllNotes", Err: fmt.Errorf("unknown sort field %q", opts.Sort)}
+	}
+	var after *cursor
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor, opts.Sort, opts.Desc)
+		if err != nil {
+			return nil, &RepoError{Src: "GetAllNotes", Err: err}
+		}
+		after = c
+	}
+
+	notes := []*models.Note{}
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		for _, n := range d.Notes {
+			if n.WorkspaceId != workspaceId || (n.DeletedAt != nil) != opts.Trashed || !d.listed(n, opts) {
+				continue
+			}
+			note := n.model()
+			if after != nil && !afterCursor(note, opts, after) {
+				continue
+			}
+			notes = append(notes, note)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(notes, func(i, j int) bool {
+		a, b := notes[i], notes[j]
+		if opts.Desc {
+			a, b = b, a
+		}
+		if va, vb := sortValue(a, opts.Sort), sortValue(b, opts.Sort); va != vb {
+			return va < vb
+		}
+		return a.Id < b.Id
+	})
+
+	page := &models.NotePage{Notes: notes}
+	if len(notes) > opts.Limit {
+		page.Notes = notes[:opts.Limit]
+		page.HasMore = true
+		last := page.Notes[len(page.Notes)-1]
+		page.NextCursor = encodeCursor(cursor{Sort: opts.Sort, Desc: opts.Desc, Value: sortValue(last, opts.Sort), Id: last.Id})
+	}
+	return page, nil
+}
+
+// listed reports whether note passes the filters of opts other than the
+// workspace, the trash and the cursor.
+func (d *memoryData) listed(note *memoryNote, opts models.ListOptions) bool {
+	if opts.Title != "" && !strings.Contains(strings.ToLower(note.Title), strings.ToLower(opts.Title)) {
+		return false
+	}
+	if opts.Content != "" && !strings.Contains(strings.ToLower(note.Content), strings.ToLower(opts.Content)) {
+		return false
+	}
+	if opts.NotebookId != 0 {
+		if note.NotebookId == nil {
+			return false
+		}
+		if opts.Recursive {
+			filter, ok := d.Notebooks[opts.NotebookId]
+			notebook, filed := d.Notebooks[*note.NotebookId]
+			if !ok || !filed || !strings.HasPrefix(notebook.Path, filter.Path) {
+				return false
+			}
+		} else if *note.NotebookId != opts.NotebookId {
+			return false
+		}
+	}
+	if len(opts.Tags) > 0 {
+		// Like the query, all tags must match a tag of the note, so a tag
+		// listed twice never matches with TagModeAll.
+		matched := 0
+		for _, tag := range note.Tags {
+			for _, filter := range opts.Tags {
+				if tag == filter {
+					matched++
+					break
+				}
+			}
+		}
+		if matched == 0 || opts.TagMode != models.TagModeAny && matched != len(opts.Tags) {
+			return false
+		}
+	}
+	timeFilters := []struct {
+		t     time.Time
+		after bool
+		value time.Time
+	}{
+		{opts.CreatedAfter, true, note.CreatedAt},
+		{opts.CreatedBefore, false, note.CreatedAt},
+		{opts.UpdatedAfter, true, note.UpdatedAt},
+		{opts.UpdatedBefore, false, note.UpdatedAt},
+	}
+	for _, f := range timeFilters {
+		if f.t.IsZero() {
+			continue
+		}
+		if t := memoryTime(f.t); f.after && f.value.Before(t) || !f.after && f.value.After(t) {
+			return false
+		}
+	}
+	return true
+}
+
+// afterCursor reports whether note comes after the note the cursor c points
+// to in the order of opts. Notes that are not in the trash have no deletion
+// time to compare, so like in the query they never come after a cursor
+// sorting by it.
+func afterCursor(note *models.Note, opts models.ListOptions, c *cursor) bool {
+	less := func(a, b int) bool { return a < b }
+	lessValue := func(a, b string) bool { return a < b }
+	if opts.Desc {
+		less = func(a, b int) bool { return a > b }
+		lessValue = func(a, b string) bool { return a > b }
+	}
+	if opts.Sort == models.SortById {
+		return less(c.Id, note.Id)
+	}
+	if opts.Sort == models.SortByDeletedAt && note.DeletedAt == nil {
+		return false
+	}
+	value := sortValue(note, opts.Sort)
+	return lessValue(c.Value, value) || value == c.Value && less(c.Id, note.Id)
+}
+
+// Create adds a new note created by a user to a workspace along with its
+// tags, first revision and deliveries to the webhooks of the workspace. It
+// sets the timestamps and version of note to the values it was stored with.
+// It returns ErrNotebookNotFound if the note is filed in a notebook that
+// does not exist or belongs to another workspace.
+func (r *memoryNoteRepository) Create(ctx context.Context, workspaceId int, userId int, note *models.Note) (int, error) {
+	now := memoryTime(r.now())
+	var id int
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		if note.NotebookId != nil {
+			if notebook, ok := d.Notebooks[*note.NotebookId]; !ok || notebook.WorkspaceId != workspaceId {
+				return &RepoError{Src: "CreateNote", Err: ErrNotebookNotFound}
+			}
+		}
+		n := &memoryNote{
+			Id:          d.nextId("notes"),
+			WorkspaceId: workspaceId,
+			UserId:      userId,
+			Title:       note.Title,
+			Content:     note.Content,
+			Tags:        memoryTags(note.Tags),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+			Version:     1,
+			NotebookId:  copyInt(note.NotebookId),
+		}
+		d.rememberNote(n.Id)
+		d.Notes[n.Id] = n
+		d.touch(n)
+		r.addRevision(n, now)
+		id = n.Id
+		return d.enqueueEvent(workspaceId, models.EventNoteCreated, n, now)
+	})
+	if err != nil {
+		return 0, err
+	}
+	note.CreatedAt, note.UpdatedAt, note.Version = now, now, 1
+	return id, nil
+}
+
+// memoryTags returns tags sorted and without duplicates, as they are stored.
+func memoryTags(tags []string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	unique := sorted[:1]
+	for _, tag := range sorted[1:] {
+		if tag != unique[len(unique)-1] {
+			unique = append(unique, tag)
+		}
+	}
+	return unique
+}
+
+// addRevision records the title and content of note as its revision at its
+// current version and removes the revisions beyond MaxRevisions.
+func (r *memoryNoteRepository) addRevision(note *memoryNote, at time.Time) {
+	note.Revisions = append(note.Revisions, &models.Revision{
+		NoteId: note.Id, Version: note.Version, Title: note.Title, Content: note.Content, CreatedAt: at,
+	})
+	if r.MaxRevisions > 0 && len(note.Revisions) > r.MaxRevisions {
+		note.Revisions = append([]*models.Revision{}, note.Revisions[len(note.Revisions)-r.MaxRevisions:]...)
+	}
+}
+
+// writable returns the note of a workspace that is about to be written on the
+// condition that it is at version, unless version is 0, and remembers it for
+// rolling back the unit of work the write is part of.
+// It returns ErrNoteNotFound if the note is not found and ErrVersionConflict
+// if the note is at a different version, like checkVersion.
+func writable(d *memoryData, src string, workspaceId int, id int, version int) (*memoryNote, error) {
+	n, ok := d.Notes[id]
+	if !ok || !n.live(workspaceId) {
+		return nil, &RepoError{src, id, ErrNoteNotFound}
+	}
+	if version != 0 && n.Version != version {
+		return nil, &RepoError{src, id, fmt.Errorf("%w: expected version %d, found %d", ErrVersionConflict, version, n.Version)}
+	}
+	d.rememberNote(id)
+	return n, nil
+}
+
+// Update modifies an existing note of a workspace, bumping its update time
+// and version, and records the new revision and webhook deliveries. The tags
+// of the note are replaced unless note.Tags is nil. It sets the metadata of
+// note to the stored values. If version is not 0, the note is only updated
+// if it is still at that version.
+// It returns ErrNoteNotFound if the note is not found and ErrVersionConflict
+// if the note is at a different version.
+func (r *memoryNoteRepository) Update(ctx context.Context, workspaceId int, id int, note *models.Note, version int) error {
+	now := memoryTime(r.now())
+	return r.store.writeContext(ctx, func(d *memoryData) error {
+		n, err := writable(d, "UpdateNoteByID", workspaceId, id, version)
+		if err != nil {
+			return err
+		}
+		n.Title, n.Content, n.UpdatedAt = note.Title, note.Content, now
+		n.Version++
+		if note.Tags != nil {
+			n.Tags = memoryTags(note.Tags)
+		}
+		d.touch(n)
+		r.addRevision(n, now)
+		note.Id, note.Version, note.CreatedAt, note.UpdatedAt = id, n.Version, n.CreatedAt, n.UpdatedAt
+		return d.enqueueEvent(workspaceId, models.EventNoteUpdated, n, now)
+	})
+}
+
+// Delete moves a note of a workspace to the trash and queues its webhook
+// deliveries. If version is not 0, the note is only deleted if it is still
+// at that version.
+// It returns ErrNoteNotFound if the note is not found or already in the trash
+// and ErrVersionConflict if the note is at a different version.
+func (r *memoryNoteRepository) Delete(ctx context.Context, workspaceId int, id int, version int) error {
+	now := r.now()
+	return r.store.writeContext(ctx, func(d *memoryData) error {
+		n, err := writable(d, "DeleteNoteByID", workspaceId, id, version)
+		if err != nil {
+			return err
+		}
+		n.DeletedAt = memoryNullTime(&now)
+		n.Version++
+		d.touch(n)
+		return d.enqueueEvent(workspaceId, models.EventNoteDeleted, n, now)
+	})
+}
+
+// MoveNote files a note of a workspace in another of its notebooks, or in
+// none if notebookId is nil, bumping its update time and version, and
+// queues its webhook deliveries as an update. If version is not 0, the note
+// is only moved if it is still at that version.
+// It returns ErrNoteNotFound if the note is not found, ErrNotebookNotFound if
+// the notebook does not exist and ErrVersionConflict if the note is at a
+// different version.
+func (r *memoryNoteRepository) MoveNote(ctx context.Context, workspaceId int, id int, notebookId *int, version int) error {
+	now := r.now()
+	return r.store.writeContext(ctx, func(d *memoryData) error {
+		if notebookId != nil {
+			if notebook, ok := d.Notebooks[*notebookId]; !ok || notebook.WorkspaceId != workspaceId {
+				return &RepoError{"MoveNoteByID", id, ErrNotebookNotFound}
+			}
+		}
+		n, err := writable(d, "MoveNoteByID", workspaceId, id, version)
+		if err != nil {
+			return err
+		}
+		n.NotebookId, n.UpdatedAt = copyInt(notebookId), memoryTime(now)
+		n.Version++
+		d.touch(n)
+		return d.enqueueEvent(workspaceId, models.EventNoteUpdated, n, now)
+	})
+}
+
+// Restore moves a note of a workspace out of the trash, bumping its version,
+// queues its webhook deliveries and returns the restored note.
+// It returns ErrNoteNotFound if the note is not in the trash.
+func (r *memoryNoteRepository) Restore(ctx context.Context, workspaceId int, id int) (*models.Note, error) {
+	now := r.now()
+	var note *models.Note
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || n.WorkspaceId != workspaceId || n.DeletedAt == nil {
+			return &RepoError{"RestoreNoteByID", id, ErrNoteNotFound}
+		}
+		d.rememberNote(id)
+		n.DeletedAt = nil
+		n.Version++
+		d.touch(n)
+		note = n.model()
+		// The note reappears in the lists of notes like a new one.
+		return d.enqueueEvent(workspaceId, models.EventNoteCreated, n, now)
+	})
+	return note, err
+}
+
+// Purge permanently removes a note of a workspace from the trash.
+// It returns ErrNoteNotFound if the note is not in the trash.
+func (r *memoryNoteRepository) Purge(ctx context.Context, workspaceId int, id int) error {
+	return r.store.writeContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || n.WorkspaceId != workspaceId || n.DeletedAt == nil {
+			return &RepoError{"PurgeNoteByID", id, ErrNoteNotFound}
+		}
+		d.removeNote(n, r.now())
+		return nil
+	})
+}
+
+// PurgeDeletedBefore permanently removes the notes of all users that were
+// moved to the trash before t and returns how many were removed.
+func (r *memoryNoteRepository) PurgeDeletedBefore(ctx context.Context, t time.Time) (int, error) {
+	t = memoryTime(t)
+	purged := 0
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		for _, id := range sortedIds(d.Notes) {
+			if n := d.Notes[id]; n.DeletedAt != nil && n.DeletedAt.Before(t) {
+				d.removeNote(n, r.now())
+				purged++
+			}
+		}
+		return nil
+	})
+	return purged, err
+}
+
+// GetRevisions retrieves the revisions of a note of a workspace, newest first.
+// It returns ErrNoteNotFound if the note is not found or in the trash.
+func (r *memoryNoteRepository) GetRevisions(ctx context.Context, workspaceId int, id int) ([]*models.Revision, error) {
+	revisions := []*models.Revision{}
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || !n.live(workspaceId) || len(n.Revisions) == 0 {
+			return &RepoError{"GetRevisions", id, ErrNoteNotFound}
+		}
+		for i := len(n.Revisions) - 1; i >= 0; i-- {
+			rev := *n.Revisions[i]
+			revisions = append(revisions, &rev)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// GetRevision retrieves the revision of a note of a workspace at the given
+// version.
+// It returns ErrNoteNotFound if the note is not found or in the trash and
+// ErrRevisionNotFound if it has no such revision.
+func (r *memoryNoteRepository) GetRevision(ctx context.Context, workspaceId int, id int, version int) (*models.Revision, error) {
+	var revision *models.Revision
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || !n.live(workspaceId) {
+			return &RepoError{"GetNoteByID", id, ErrNoteNotFound}
+		}
+		for _, rev := range n.Revisions {
+			if rev.Version == version {
+				copied := *rev
+				revision = &copied
+				return nil
+			}
+		}
+		return &RepoError{"GetRevision", id, fmt.Errorf("%w: version %d", ErrRevisionNotFound, version)}
+	})
+	return revision, err
+}
+
+// GetTags retrieves the tags of a workspace carried by notes outside the
+// trash along with their usage counts, ordered by name.
+func (r *memoryNoteRepository) GetTags(ctx context.Context, workspaceId int) ([]*models.Tag, error) {
+	counts := map[string]int{}
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		for _, n := range d.Notes {
+			if n.live(workspaceId) {
+				for _, tag := range n.Tags {
+					counts[tag]++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	tags := []*models.Tag{}
+	for name, count := range counts {
+		tags = append(tags, &models.Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	return tags, nil
+}
+
+// tagged returns the notes of a workspace carrying tag, including those in
+// the trash, ordered by ID. A tag exists as long as a note carries it.
+func (d *memoryData) tagged(workspaceId int, tag string) []*memoryNote {
+	notes := []*memoryNote{}
+	for _, id := range sortedIds(d.Notes) {
+		n := d.Notes[id]
+		if n.WorkspaceId != workspaceId {
+			continue
+		}
+		for _, t := range n.Tags {
+			if t == tag {
+				notes = append(notes, n)
+				break
+			}
+		}
+	}
+	return notes
+}
+
+// retag replaces the tag from with the tag to on notes, bumping their update
+// time and version, and returns how many there are.
+func (r *memoryNoteRepository) retag(d *memoryData, notes []*memoryNote, from string, to string) int {
+	now := memoryTime(r.now())
+	for _, n := range notes {
+		d.rememberNote(n.Id)
+		tags := []string{to}
+		for _, tag := range n.Tags {
+			if tag != from {
+				tags = append(tags, tag)
+			}
+		}
+		n.Tags = memoryTags(tags)
+		n.UpdatedAt = now
+		n.Version++
+		d.touch(n)
+	}
+	return len(notes)
+}
+
+// RenameTag renames a tag of a workspace on all notes carrying it, bumping
+// their update time and version, and returns the number of notes changed.
+// It returns ErrTagNotFound if there is no tag with the name and
+// ErrTagExists if there already is a tag with the new name.
+func (r *memoryNoteRepository) RenameTag(ctx context.Context, workspaceId int, name string, newName string) (int, error) {
+	n := 0
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		notes := d.tagged(workspaceId, name)
+		if len(notes) == 0 {
+			return &RepoError{Src: "RenameTag", Err: fmt.Errorf("%w: %q", ErrTagNotFound, name)}
+		}
+		if len(d.tagged(workspaceId, newName)) > 0 {
+			return &RepoError{Src: "RenameTag", Err: fmt.Errorf("%w: %q", ErrTagExists, newName)}
+		}
+		n = r.retag(d, notes, name, newName)
+		return nil
+	})
+	return n, err
+}
+
+// MergeTags replaces the source tag of a workspace with its target tag on
+// all notes carrying it, bumping their update time and version, and removes
+// the source tag. It returns the number of notes changed.
+// It returns ErrTagNotFound if either tag does not exist.
+func (r *memoryNoteRepository) MergeTags(ctx context.Context, workspaceId int, source string, target string) (int, error) {
+	n := 0
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		notes := d.tagged(workspaceId, source)
+		if len(notes) == 0 {
+			return &RepoError{Src: "MergeTags", Err: fmt.Errorf("%w: %q", ErrTagNotFound, source)}
+		}
+		if len(d.tagged(workspaceId, target)) == 0 {
+			return &RepoError{Src: "MergeTags", Err: fmt.Errorf("%w: %q", ErrTagNotFound, target)}
+		}
+		n = r.retag(d, notes, source, target)
+		return nil
+	})
+	return n, err
+}
+
+// GetChanges retrieves up to limit changes to the notes of a workspace after
+// the sequence number since, in order. Notes in the trash and purged notes
+// are returned as tombstones. Every note appears at most once, with its
+// latest change.
+func (r *memoryNoteRepository) GetChanges(ctx context.Context, workspaceId int, since int64, limit int) (*models.ChangePage, error) {
+	notes := []*models.Change{}
+	tombstones := []*models.Change{}
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		for _, n := range d.Notes {
+			if n.WorkspaceId != workspaceId || n.Seq <= since {
+				continue
+			}
+			change := &models.Change{Seq: n.Seq, NoteId: n.Id}
+			if n.DeletedAt != nil {
+				change.Deleted, change.DeletedAt = true, memoryNullTime(n.DeletedAt)
+			} else {
+				change.Note = n.model()
+			}
+			notes = append(notes, change)
+		}
+		for _, t := range d.Tombstones {
+			if t.WorkspaceId == workspaceId && t.Seq > since {
+				deletedAt := t.DeletedAt
+				tombstones = append(tombstones, &models.Change{Seq: t.Seq, NoteId: t.NoteId, Deleted: true, DeletedAt: &deletedAt})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(notes, func(i, j int) bool { return notes[i].Seq < notes[j].Seq })
+
+	page := &models.ChangePage{Changes: mergeChanges(notes, tombstones), Seq: since}
+	if len(page.Changes) > limit {
+		page.Changes = page.Changes[:limit]
+		page.HasMore = true
+	}
+	if len(page.Changes) > 0 {
+		page.Seq = page.Changes[len(page.Changes)-1].Seq
+	}
+	return page, nil
+}
+
+// GetPushedNote retrieves the note of a workspace that a user created with
+// a pushed change with clientId, also if it is in the trash.
+// It returns ErrNoteNotFound if there is no such note.
+func (r *memoryNoteRepository) GetPushedNote(ctx context.Context, workspaceId int, userId int, clientId string) (*models.Note, error) {
+	var note *models.Note
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		if n := d.pushedNote(workspaceId, userId, clientId); n != nil {
+			note = n.model()
+			return nil
+		}
+		return &RepoError{Src: "GetPushedNote", Err: ErrNoteNotFound}
+	})
+	return note, err
+}
+
+// SetClientId stores the client ID of a note of a workspace that was created
+// with a pushed change.
+// It returns ErrNoteNotFound if the note is not found and ErrClientIdTaken if
+// the user of the note already gave the client ID to another note.
+func (r *memoryNoteRepository) SetClientId(ctx context.Context, workspaceId int, id int, clientId string) error {
+	return r.store.writeContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || n.WorkspaceId != workspaceId {
+			return &RepoError{"SetClientId", id, ErrNoteNotFound}
+		}
+		if other := d.pushedNote(workspaceId, n.UserId, clientId); other != nil && other.Id != id {
+			return &RepoError{"SetClientId", id, ErrClientIdTaken}
+		}
+		d.rememberNote(id)
+		n.ClientId = clientId
+		return nil
+	})
+}
+
+// pushedNote returns the note of a workspace that a user gave clientId, or
+// nil if there is none.
+func (d *memoryData) pushedNote(workspaceId int, userId int, clientId string) *memoryNote {
+	for _, n := range d.Notes {
+		if n.WorkspaceId == workspaceId && n.UserId == userId && n.ClientId == clientId {
+			return n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// memoryNotebookRepository implements the NotebookRepository interface on a
+// MemoryStore.
+type memoryNotebookRepository struct {
+	store *MemoryStore
+	now   func() time.Time
+}
+
+// NewMemoryNotebooksRepository creates a new memoryNotebookRepository.
+func NewMemoryNotebooksRepository(store *MemoryStore) *memoryNotebookRepository {
+	return &memoryNotebookRepository{store: store, now: time.Now}
+}
+
+// notebook returns a notebook of a workspace, or nil if there is none.
+func (d *memoryData) notebook(workspaceId int, id int) *memoryNotebook {
+	if notebook, ok := d.Notebooks[id]; ok && notebook.WorkspaceId == workspaceId {
+		return notebook
+	}
+	return nil
+}
+
+// sortedNotebooks returns the notebooks of a workspace accepted by keep,
+// ordered by path so that every notebook comes after its parent.
+func (d *memoryData) sortedNotebooks(workspaceId int, keep func(*memoryNotebook) bool) []*memoryNotebook {
+	notebooks := []*memoryNotebook{}
+	for _, notebook := range d.Notebooks {
+		if notebook.WorkspaceId == workspaceId && keep(notebook) {
+			notebooks = append(notebooks, notebook)
+		}
+	}
+	sort.Slice(notebooks, func(i, j int) bool { return notebooks[i].Path < notebooks[j].Path })
+	return notebooks
+}
+
+// siblingExists reports whether a notebook of the workspace other than id
+// below parentId already has the given name, like checkSiblingName.
+func (d *memoryData) siblingExists(workspaceId int, id int, parentId *int, name string) bool {
+	for _, notebook := range d.Notebooks {
+		if notebook.WorkspaceId == workspaceId && notebook.Id != id && sameId(notebook.ParentId, parentId) && notebook.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// sameId reports whether two optional IDs are equal.
+func sameId(a *int, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+// movePaths replaces the prefix from with to in the paths of the notebooks
+// below from, including the one at from itself, like movePaths.
+func (d *memoryData) movePaths(from string, to string) {
+	for _, notebook := range d.Notebooks {
+		if strings.HasPrefix(notebook.Path, from) {
+			notebook.Path = to + notebook.Path[len(from):]
+		}
+	}
+}
+
+// Get retrieves a notebook of a workspace by its ID.
+// It returns ErrNotebookNotFound if the notebook is not found.
+func (r *memoryNotebookRepository) Get(workspaceId int, id int) (*models.Notebook, error) {
+	var notebook *models.Notebook
+	err := r.store.read(func(d *memoryData) error {
+		n := d.notebook(workspaceId, id)
+		if n == nil {
+			return &RepoError{"GetNotebookByID", id, ErrNotebookNotFound}
+		}
+		notebook = n.model()
+		return nil
+	})
+	return notebook, err
+}
+
+// GetAll retrieves all notebooks of a workspace, ordered so that every
+// notebook comes after its parent.
+func (r *memoryNotebookRepository) GetAll(workspaceId int) ([]*models.Notebook, error) {
+	notebooks := []*models.Notebook{}
+	r.store.read(func(d *memoryData) error {
+		for _, notebook := range d.sortedNotebooks(workspaceId, func(*memoryNotebook) bool { return true }) {
+			notebooks = append(notebooks, notebook.model())
+		}
+		return nil
+	})
+	return notebooks, nil
+}
+
+// Create adds a new notebook created by a user to a workspace and sets the
+// metadata of notebook to the values it was stored with.
+// It returns ErrNotebookNotFound if the parent does not exist and
+// ErrNotebookExists if the parent already has a notebook with that name.
+func (r *memoryNotebookRepository) Create(workspaceId int, userId int, notebook *models.Notebook) (int, error) {
+	now := memoryTime(r.now())
+	err := r.store.write(func(d *memoryData) error {
+		parentPath := ""
+		if notebook.ParentId != nil {
+			parent := d.notebook(workspaceId, *notebook.ParentId)
+			if parent == nil {
+				return &RepoError{Src: "CreateNotebook", Err: ErrNotebookNotFound}
+			}
+			parentPath = parent.Path
+		}
+		if d.siblingExists(workspaceId, 0, notebook.ParentId, notebook.Name) {
+			return &RepoError{Src: "CreateNotebook", Err: ErrNotebookExists}
+		}
+		stored := &memoryNotebook{
+			Id:          d.nextId("notebooks"),
+			WorkspaceId: workspaceId,
+			UserId:      userId,
+			Name:        notebook.Name,
+			ParentId:    copyInt(notebook.ParentId),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		stored.Path = notebookPath(parentPath, stored.Id)
+		d.Notebooks[stored.Id] = stored
+		notebook.Id, notebook.Path = stored.Id, stored.Path
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	notebook.CreatedAt, notebook.UpdatedAt = now, now
+	return notebook.Id, nil
+}
+
+// Rename changes the name of a notebook of a workspace.
+// It returns ErrNotebookNotFound if the notebook is not found and
+// ErrNotebookExists if its parent already has a notebook with that name.
+func (r *memoryNotebookRepository) Rename(workspaceId int, id int, name string) error {
+	return r.store.write(func(d *memoryData) error {
+		notebook := d.notebook(workspaceId, id)
+		if notebook == nil {
+			return &RepoError{"RenameNotebookByID", id, ErrNotebookNotFound}
+		}
+		if d.siblingExists(workspaceId, id, notebook.ParentId, name) {
+			return &RepoError{"RenameNotebookByID", id, ErrNotebookExists}
+		}
+		notebook.Name, notebook.UpdatedAt = name, memoryTime(r.now())
+		return nil
+	})
+}
+
+// Move moves a notebook of a workspace along with everything below it into
+// another of its notebooks, or to the top level if parentId is nil.
+// It returns ErrNotebookNotFound if either notebook is not found,
+// ErrNotebookCycle if the new parent is the notebook itself or below it and
+// ErrNotebookExists if the new parent already has a notebook with that name.
+func (r *memoryNotebookRepository) Move(workspaceId int, id int, parentId *int) error {
+	return r.store.write(func(d *memoryData) error {
+		notebook := d.notebook(workspaceId, id)
+		if notebook == nil {
+			return &RepoError{"MoveNotebookByID", id, ErrNotebookNotFound}
+		}
+		parentPath := ""
+		if parentId != nil {
+			parent := d.notebook(workspaceId, *parentId)
+			if parent == nil {
+				return &RepoError{"MoveNotebookByID", id, ErrNotebookNotFound}
+			}
+			if strings.HasPrefix(parent.Path, notebook.Path) {
+				return &RepoError{"MoveNotebookByID", id, ErrNotebookCycle}
+			}
+			parentPath = parent.Path
+		}
+		if d.siblingExists(workspaceId, id, parentId, notebook.Name) {
+			return &RepoError{"MoveNotebookByID", id, ErrNotebookExists}
+		}
+		notebook.ParentId, notebook.UpdatedAt = copyInt(parentId), memoryTime(r.now())
+		d.movePaths(notebook.Path, notebookPath(parentPath, id))
+		return nil
+	})
+}
+
+// Delete removes a notebook of a workspace, like notebookRepository.Delete.
+// It returns ErrNotebookNotFound if the notebook is not found,
+// ErrNotebookNotEmpty if mode is NotebookDeleteBlock and the notebook is not
+// empty and ErrNotebookExists if mode is NotebookDeleteMove and the parent
+// already has a notebook with the name of one being moved.
+func (r *memoryNotebookRepository) Delete(workspaceId int, id int, mode models.NotebookDeleteMode) error {
+	now := memoryTime(r.now())
+	return r.store.write(func(d *memoryData) error {
+		notebook := d.notebook(workspaceId, id)
+		if notebook == nil {
+			return &RepoError{"DeleteNotebookByID", id, ErrNotebookNotFound}
+		}
+
+		switch mode {
+		case models.NotebookDeleteTrash:
+			subtree := map[int]bool{}
+			for _, n := range d.Notebooks {
+				if strings.HasPrefix(n.Path, notebook.Path) {
+					subtree[n.Id] = true
+				}
+			}
+			for _, noteId := range sortedIds(d.Notes) {
+				note := d.Notes[noteId]
+				if note.NotebookId == nil || !subtree[*note.NotebookId] {
+					continue
+				}
+				if note.DeletedAt == nil {
+					deletedAt := now
+					note.DeletedAt = &deletedAt
+					note.Version++
+				}
+				note.NotebookId = nil
+				d.touch(note)
+			}
+			for notebookId := range subtree {
+				delete(d.Notebooks, notebookId)
+			}
+		case models.NotebookDeleteMove:
+			for _, child := range d.Notebooks {
+				if sameId(child.ParentId, &id) && d.siblingExists(workspaceId, id, notebook.ParentId, child.Name) {
+					return &RepoError{"DeleteNotebookByID", id, ErrNotebookExists}
+				}
+			}
+			for _, child := range d.Notebooks {
+				if sameId(child.ParentId, &id) {
+					child.ParentId, child.UpdatedAt = copyInt(notebook.ParentId), now
+				}
+			}
+			d.movePaths(notebook.Path, notebook.Path[:len(notebook.Path)-len(strconv.Itoa(id))-1])
+			for _, noteId := range sortedIds(d.Notes) {
+				note := d.Notes[noteId]
+				if !sameId(note.NotebookId, &id) {
+					continue
+				}
+				if note.DeletedAt == nil {
+					note.UpdatedAt = now
+					note.Version++
+				}
+				note.NotebookId = copyInt(notebook.ParentId)
+				d.touch(note)
+			}
+			delete(d.Notebooks, id)
+		default:
+			for _, child := range d.Notebooks {
+				if sameId(child.ParentId, &id) {
+					return &RepoError{"DeleteNotebookByID", id, ErrNotebookNotEmpty}
+				}
+			}
+			for _, note := range d.Notes {
+				if sameId(note.NotebookId, &id) && note.DeletedAt == nil {
+					return &RepoError{"DeleteNotebookByID", id, ErrNotebookNotEmpty}
+				}
+			}
+			for _, noteId := range sortedIds(d.Notes) {
+				if note := d.Notes[noteId]; sameId(note.NotebookId, &id) {
+					note.NotebookId = nil
+					d.touch(note)
+				}
+			}
+			delete(d.Notebooks, id)
+		}
+		return nil
+	})
+}
+
+// GetNotebookAccess returns the role a user was granted on a notebook of a
+// workspace, like GetNoteAccess.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func (r *memoryNotebookRepository) GetNotebookAccess(workspaceId int, userId int, id int) (models.Role, error) {
+	return memoryNotebookAccess(context.Background(), r.store, workspaceId, userId, id)
+}
+
+// GetNotebookShares retrieves the owner of a notebook of a workspace
+// followed by the users the notebook is shared with directly.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func (r *memoryNotebookRepository) GetNotebookShares(workspaceId int, id int) ([]*models.Share, error) {
+	var shares []*models.Share
+	err := r.store.read(func(d *memoryData) error {
+		notebook := d.notebook(workspaceId, id)
+		if notebook == nil {
+			return &RepoError{"GetNotebookShares", id, ErrNotebookNotFound}
+		}
+		shares = d.memoryShares(notebook.UserId, notebook.CreatedAt, notebook.Shares)
+		return nil
+	})
+	return shares, err
+}
+
+// ShareNotebook grants the user with the given email role on a notebook of
+// a workspace and everything below it, or changes the role if the notebook
+// is already shared with the user. The user does not need to be a member of
+// the workspace.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace, ErrUserNotFound if there is no user with the email
+// and ErrShareWithOwner if the user owns the notebook.
+func (r *memoryNotebookRepository) ShareNotebook(workspaceId int, id int, email string, role models.Role) (*models.Share, error) {
+	var share *models.Share
+	err := r.store.write(func(d *memoryData) error {
+		notebook := d.notebook(workspaceId, id)
+		if notebook == nil {
+			return &RepoError{"ShareNotebook", id, ErrNotebookNotFound}
+		}
+		user := d.userByEmail(email)
+		if user == nil {
+			return &RepoError{"ShareNotebook", id, ErrUserNotFound}
+		}
+		if user.Id == notebook.UserId {
+			return &RepoError{"ShareNotebook", id, ErrShareWithOwner}
+		}
+		if notebook.Shares == nil {
+			notebook.Shares = map[int]*memoryRole{}
+		}
+		share = memoryShare(notebook.Shares, user, role, r.now())
+		return nil
+	})
+	return share, err
+}
+
+// RevokeNotebookShare stops sharing a notebook of a workspace with a user.
+// It returns ErrShareNotFound if the notebook is not shared with the user.
+func (r *memoryNotebookRepository) RevokeNotebookShare(workspaceId int, id int, userId int) error {
+	return r.store.write(func(d *memoryData) error {
+		notebook := d.notebook(workspaceId, id)
+		if notebook == nil || notebook.Shares[userId] == nil {
+			return &RepoError{"RevokeNotebookShare", id, ErrShareNotFound}
+		}
+		delete(notebook.Shares, userId)
+		return nil
+	})
+}
+
+// GetSharedNotebooks retrieves the notebooks of a workspace created by other
+// users that are shared with a user, directly or through a notebook above
+// them, ordered so that every notebook comes after its parent.
+func (r *memoryNotebookRepository) GetSharedNotebooks(workspaceId int, userId int) ([]*models.SharedNotebook, error) {
+	notebooks := []*models.SharedNotebook{}
+	r.store.read(func(d *memoryData) error {
+		shared := d.sortedNotebooks(workspaceId, func(n *memoryNotebook) bool {
+			return n.UserId != userId && d.pathRank(userId, n.Path) > 0
+		})
+		for _, notebook := range shared {
+			s := &models.SharedNotebook{Notebook: notebook.model(), Role: shareRoles[d.pathRank(userId, notebook.Path)]}
+			if owner, ok := d.Users[notebook.UserId]; ok {
+				s.Owner = owner.Email
+			}
+			notebooks = append(notebooks, s)
+		}
+		return nil
+	})
+	return notebooks, nil
+}
@@ -0,0 +1,813 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// The in-memory search mirrors the FTS5 index of SQLite, so that both rank
+// and mark up results the same way: documents are tokenized like by the
+// unicode61 tokenizer with remove_diacritics 2, ranked with bm25 weighting
+// titles 10 and contents 1, and marked up like by the highlight and snippet
+// functions.
+
+// searchWeights are the bm25 weights of the title and the content.
+var searchWeights = []float64{10, 1}
+
+// snippetTokens is the number of tokens of a snippet.
+const snippetTokens = 16
+
+// searchToken is a token of a document: its folded text and the byte
+// offsets of the text it was read from.
+type searchToken struct {
+	text       string
+	start, end int
+}
+
+// isTokenChar reports whether c is part of tokens rather than a separator,
+// which it is if it is a letter, a number or for private use.
+func isTokenChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsNumber(c) || unicode.Is(unicode.Co, c)
+}
+
+// foldToken folds a token for matching by lowercasing it and removing
+// diacritics.
+func foldToken(s string) string {
+	var b strings.Builder
+	for _, c := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, c) {
+			b.WriteRune(unicode.ToLower(c))
+		}
+	}
+	return b.String()
+}
+
+// tokenize splits text into folded tokens.
+func tokenize(text string) []searchToken {
+	tokens := []searchToken{}
+	start := -1
+	for i, c := range text {
+		if isTokenChar(c) {
+			if start < 0 {
+				start = i
+			}
+		} else if start >= 0 {
+			tokens = append(tokens, searchToken{foldToken(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, searchToken{foldToken(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// searchDoc is a tokenized note: its title and content are columns 0 and 1.
+type searchDoc struct {
+	note    *memoryNote
+	columns [2]string
+	tokens  [2][]searchToken
+}
+
+// size returns the number of tokens of the document.
+func (doc *searchDoc) size() int {
+	return len(doc.tokens[0]) + len(doc.tokens[1])
+}
+
+// searchInst is an instance of a phrase in a document: the index of the
+// phrase in the query, the column and the position of its first token.
+type searchInst struct {
+	phrase, column, offset int
+}
+
+// phrases returns the phrases of the query in the order they appear in.
+func (q *queryNode) phrases() []*queryNode {
+	if q.op == "" {
+		return []*queryNode{q}
+	}
+	return append(q.left.phrases(), q.right.phrases()...)
+}
+
+// instances returns the positions of the instances of a phrase in a column
+// of a document.
+func (q *queryNode) instances(doc *searchDoc, column int, words []string) []int {
+	offsets := []int{}
+	if q.column >= 0 && q.column != column {
+		return offsets
+	}
+	tokens := doc.tokens[column]
+	for i := 0; i+len(words) <= len(tokens); i++ {
+		matched := true
+		for j, word := range words {
+			token := tokens[i+j].text
+			if j == len(words)-1 && q.prefix {
+				matched = strings.HasPrefix(token, word)
+			} else {
+				matched = token == word
+			}
+			if !matched {
+				break
+			}
+		}
+		if matched {
+			offsets = append(offsets, i)
+		}
+	}
+	return offsets
+}
+
+// searchQuery is a full-text query prepared for matching documents.
+type searchQuery struct {
+	root    *queryNode
+	phrases []*queryNode
+	// index maps the phrases to their index and words to their folded
+	// words.
+	index map[*queryNode]int
+	words [][]string
+}
+
+// newSearchQuery parses a full-text query in FTS5 syntax.
+func newSearchQuery(query string) (*searchQuery, error) {
+	root, err := parseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	q := &searchQuery{root: root, phrases: root.phrases(), index: map[*queryNode]int{}}
+	for i, phrase := range q.phrases {
+		q.index[phrase] = i
+		words := make([]string, len(phrase.words))
+		for j, word := range phrase.words {
+			words[j] = foldToken(word)
+		}
+		q.words = append(q.words, words)
+	}
+	return q, nil
+}
+
+// hits returns the instances of each phrase of the query in a document.
+func (q *searchQuery) hits(doc *searchDoc) [][]searchInst {
+	hits := make([][]searchInst, len(q.phrases))
+	for i, phrase := range q.phrases {
+		for column := range doc.tokens {
+			for _, offset := range phrase.instances(doc, column, q.words[i]) {
+				hits[i] = append(hits[i], searchInst{i, column, offset})
+			}
+		}
+	}
+	return hits
+}
+
+// hasTerm reports whether a term of a phrase is in the index entry of a
+// document: for words anywhere in it, for prefixes in the columns the
+// phrase is restricted to.
+func (q *searchQuery) hasTerm(doc *searchDoc, phrase int, term int) bool {
+	node, word := q.phrases[phrase], q.words[phrase][term]
+	prefix := node.prefix && term == len(q.words[phrase])-1
+	for column, tokens := range doc.tokens {
+		if prefix && node.column >= 0 && node.column != column {
+			continue
+		}
+		for _, token := range tokens {
+			if token.text == word || prefix && strings.HasPrefix(token.text, word) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searchRun runs a query over documents ordered by ID like FTS5 does, which
+// decides which instances of phrases are reported for a match: FTS5 reports
+// those of every phrase whose iterator is at the matching row, which
+// depends on how far the iterators of the query were advanced.
+type searchRun struct {
+	q    *searchQuery
+	docs []*searchDoc
+	hits [][][]searchInst
+	root *searchNode
+	// leaves are the nodes of the phrases and poslists the instances of
+	// the phrases at the rows their nodes were last tested at.
+	leaves   []*searchNode
+	poslists [][]searchInst
+}
+
+// searchNode is a node of a query being run: an operator with the operands
+// as children, where AND and OR take any number of them, or a phrase.
+type searchNode struct {
+	op       string
+	children []*searchNode
+	phrase   int
+	// terms iterate over the rows containing the terms of a phrase.
+	terms []*termIter
+
+	row          int
+	eof, nomatch bool
+}
+
+// termIter iterates over the positions in searchRun.docs of the rows of a
+// term.
+type termIter struct {
+	rows []int
+	i    int
+}
+
+func (t *termIter) eof() bool { return t.i >= len(t.rows) }
+func (t *termIter) row() int  { return t.rows[min(t.i, len(t.rows)-1)] }
+
+// next advances to the next row, or to the first row at or after from if
+// fromValid is set.
+func (t *termIter) next(fromValid bool, from int) {
+	for t.i++; fromValid && !t.eof() && t.rows[t.i] < from; t.i++ {
+	}
+}
+
+// newSearchRun prepares a query to be run over docs.
+func newSearchRun(q *searchQuery, docs []*searchDoc) *searchRun {
+	r := &searchRun{q: q, docs: docs, leaves: make([]*searchNode, len(q.phrases)), poslists: make([][]searchInst, len(q.phrases))}
+	for _, doc := range docs {
+		r.hits = append(r.hits, q.hits(doc))
+	}
+	r.root = r.build(q.root)
+	return r
+}
+
+// build returns the node running a node of the query, merging nested ANDs
+// and ORs into one node like FTS5 does.
+func (r *searchRun) build(node *queryNode) *searchNode {
+	if node.op == "" {
+		i := r.q.index[node]
+		n := &searchNode{phrase: i}
+		for j := range r.q.words[i] {
+			t := &termIter{}
+			for row, doc := range r.docs {
+				if r.q.hasTerm(doc, i, j) {
+					t.rows = append(t.rows, row)
+				}
+			}
+			n.terms = append(n.terms, t)
+		}
+		r.leaves[i] = n
+		return n
+	}
+	n := &searchNode{op: node.op}
+	for _, child := range []*searchNode{r.build(node.left), r.build(node.right)} {
+		if node.op != "NOT" && child.op == node.op {
+			n.children = append(n.children, child.children...)
+		} else {
+			n.children = append(n.children, child)
+		}
+	}
+	return n
+}
+
+// matches calls fn with the position of each document matching the query
+// and the instances of the phrases of the query reported for it.
+func (r *searchRun) matches(fn func(row int, insts []searchInst)) {
+	root := r.root
+	r.first(root)
+	for root.nomatch && !root.eof {
+		r.next(root, false, 0)
+	}
+	for !root.eof {
+		var insts []searchInst
+		for i, leaf := range r.leaves {
+			if !leaf.eof && leaf.row == root.row {
+				insts = append(insts, r.poslists[i]...)
+			}
+		}
+		fn(root.row, insts)
+		r.next(root, false, 0)
+		for root.nomatch && !root.eof {
+			r.next(root, false, 0)
+		}
+	}
+}
+
+func (r *searchRun) first(n *searchNode) {
+	n.eof, n.nomatch = false, false
+	if n.op == "" {
+		for _, t := range n.terms {
+			t.i = 0
+			if t.eof() {
+				n.eof = true
+			}
+		}
+	} else {
+		eofs := 0
+		for _, child := range n.children {
+			r.first(child)
+			if child.eof {
+				eofs++
+			}
+		}
+		n.row = n.children[0].row
+		switch n.op {
+		case "AND":
+			if eofs > 0 {
+				setEOF(n)
+			}
+		case "OR":
+			if eofs == len(n.children) {
+				setEOF(n)
+			}
+		default:
+			n.eof = n.children[0].eof
+		}
+	}
+	r.test(n)
+}
+
+// next advances a node to the next row it may match, or to the first one
+// at or after from if fromValid is set.
+func (r *searchRun) next(n *searchNode, fromValid bool, from int) {
+	switch n.op {
+	case "":
+		n.nomatch = false
+		n.terms[0].next(fromValid, from)
+		if n.eof = n.terms[0].eof(); !n.eof {
+			r.test(n)
+		}
+	case "OR":
+		last := n.row
+		for _, child := range n.children {
+			if !child.eof && (child.row == last || fromValid && child.row < from) {
+				r.next(child, fromValid, from)
+			}
+		}
+		r.test(n)
+	default:
+		r.next(n.children[0], fromValid, from)
+		r.test(n)
+	}
+}
+
+// test moves a node to the next row all of its children agree on and
+// records whether the node matches it.
+func (r *searchRun) test(n *searchNode) {
+	if n.eof {
+		return
+	}
+	switch n.op {
+	case "":
+		r.testPhrase(n)
+	case "AND":
+		last := n.row
+		for match := false; !match; {
+			n.nomatch, match = false, true
+			for _, child := range n.children {
+				if last > child.row {
+					r.next(child, true, last)
+				}
+				if child.eof {
+					setEOF(n)
+					match = true
+					break
+				} else if last != child.row {
+					match, last = false, child.row
+				}
+				if child.nomatch {
+					n.nomatch = true
+				}
+			}
+		}
+		if n.nomatch && n != r.root {
+			r.zeroPoslists(n)
+		}
+		n.row = last
+	case "OR":
+		next := n.children[0]
+		for _, child := range n.children[1:] {
+			if cmp := compareNodes(next, child); cmp > 0 || cmp == 0 && !child.nomatch {
+				next = child
+			}
+		}
+		n.row, n.eof, n.nomatch = next.row, next.eof, next.nomatch
+	case "NOT":
+		left, right := n.children[0], n.children[1]
+		for !left.eof {
+			cmp := compareNodes(left, right)
+			if cmp > 0 {
+				r.next(right, true, left.row)
+				cmp = compareNodes(left, right)
+			}
+			if cmp != 0 || right.nomatch {
+				break
+			}
+			r.next(left, false, 0)
+		}
+		n.row, n.eof, n.nomatch = left.row, left.eof, left.nomatch
+		if left.eof {
+			r.zeroPoslists(right)
+		}
+	}
+}
+
+// testPhrase moves the terms of a phrase to the next row containing all of
+// them and records the instances of the phrase in it.
+func (r *searchRun) testPhrase(n *searchNode) {
+	last := n.terms[0].row()
+	for match := false; !match; {
+		match = true
+		for _, t := range n.terms {
+			if t.eof() || t.row() == last {
+				continue
+			}
+			match = false
+			if last > t.row() {
+				t.next(true, last)
+				if t.eof() {
+					n.eof = true
+					return
+				}
+			}
+			last = t.row()
+		}
+	}
+	n.row = last
+	r.poslists[n.phrase] = nil
+	for _, t := range n.terms {
+		if t.eof() || t.row() != last {
+			n.nomatch = true
+			return
+		}
+	}
+	r.poslists[n.phrase] = r.hits[last][n.phrase]
+	n.nomatch = len(r.poslists[n.phrase]) == 0
+}
+
+// setEOF marks a node and its children as done.
+func setEOF(n *searchNode) {
+	n.eof, n.nomatch = true, false
+	for _, child := range n.children {
+		setEOF(child)
+	}
+}
+
+// zeroPoslists drops the instances of the phrases under a node.
+func (r *searchRun) zeroPoslists(n *searchNode) {
+	if n.op == "" {
+		r.poslists[n.phrase] = nil
+	}
+	for _, child := range n.children {
+		r.zeroPoslists(child)
+	}
+}
+
+// compareNodes compares the rows of two nodes, where done nodes come last.
+func compareNodes(a *searchNode, b *searchNode) int {
+	switch {
+	case b.eof:
+		return -1
+	case a.eof:
+		return 1
+	}
+	return a.row - b.row
+}
+
+// searchMatch is a document matching a query and the instances of the
+// phrases of the query in it, ordered by column and position.
+type searchMatch struct {
+	doc   *searchDoc
+	insts []searchInst
+	bm25  float64
+}
+
+// Search finds the notes of a workspace matching a full-text query, best
+// matches first, like noteRepository.Search.
+// It returns ErrInvalidSearchQuery if the query cannot be parsed. Like the
+// PostgreSQL repository and unlike FTS5, it rejects empty phrases such as
+// "" rather than matching nothing.
+func (r *memoryNoteRepository) Search(ctx context.Context, workspaceId int, opts models.SearchOptions) ([]*models.SearchResult, error) {
+	q, err := newSearchQuery(opts.Query)
+	if err != nil {
+		return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("%w: %v", ErrInvalidSearchQuery, err)}
+	}
+
+	results := []*models.SearchResult{}
+	err = r.store.readContext(ctx, func(d *memoryData) error {
+		// The statistics of bm25 cover every note, like the index does.
+		docs, tokens := []*searchDoc{}, 0
+		for _, id := range sortedIds(d.Notes) {
+			n := d.Notes[id]
+			doc := &searchDoc{note: n, columns: [2]string{markStripper.Replace(n.Title), markStripper.Replace(n.Content)}}
+			doc.tokens = [2][]searchToken{tokenize(n.Title), tokenize(n.Content)}
+			tokens += doc.size()
+			docs = append(docs, doc)
+		}
+		run := newSearchRun(q, docs)
+		nHits := make([]int, len(q.phrases))
+		for _, hits := range run.hits {
+			for i := range hits {
+				if len(hits[i]) > 0 {
+					nHits[i]++
+				}
+			}
+		}
+
+		matches := []*searchMatch{}
+		run.matches(func(row int, insts []searchInst) {
+			if !docs[row].note.live(workspaceId) {
+				return
+			}
+			sort.Slice(insts, func(i, j int) bool {
+				a, b := insts[i], insts[j]
+				if a.column != b.column {
+					return a.column < b.column
+				}
+				if a.offset != b.offset {
+					return a.offset < b.offset
+				}
+				return a.phrase < b.phrase
+			})
+			matches = append(matches, &searchMatch{doc: docs[row], insts: insts})
+		})
+		if len(matches) == 0 {
+			return nil
+		}
+
+		rows := len(docs)
+		idf := make([]float64, len(q.phrases))
+		for i, nHit := range nHits {
+			idf[i] = math.Log((float64(rows) - float64(nHit) + 0.5) / (float64(nHit) + 0.5))
+			if idf[i] <= 0 {
+				idf[i] = 1e-6
+			}
+		}
+		avgdl := float64(tokens) / float64(rows)
+		for _, m := range matches {
+			m.bm25 = bm25(m, idf, avgdl)
+		}
+		sort.Slice(matches, func(i, j int) bool {
+			if matches[i].bm25 != matches[j].bm25 {
+				return matches[i].bm25 < matches[j].bm25
+			}
+			return matches[i].doc.note.Id < matches[j].doc.note.Id
+		})
+
+		if opts.Offset > 0 {
+			matches = matches[min(opts.Offset, len(matches)):]
+		}
+		if opts.Limit >= 0 && opts.Limit < len(matches) {
+			matches = matches[:opts.Limit]
+		}
+		for _, m := range matches {
+			results = append(results, &models.SearchResult{
+				Note:      *m.doc.note.model(),
+				Score:     -m.bm25,
+				Highlight: highlight(m.doc, m.insts, q, 0),
+				Snippet:   snippet(m.doc, m.insts, q, 1),
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// bm25 ranks a match like the bm25 function of FTS5, lower is better.
+func bm25(m *searchMatch, idf []float64, avgdl float64) float64 {
+	const k1, b = 1.2, 0.75
+	freq := make([]float64, len(idf))
+	for _, inst := range m.insts {
+		freq[inst.phrase] += searchWeights[inst.column]
+	}
+	D := float64(m.doc.size())
+	score := 0.0
+	for i := range idf {
+		score += idf[i] * ((freq[i] * (k1 + 1.0)) / (freq[i] + k1*(1-b+b*D/avgdl)))
+	}
+	return -1.0 * score
+}
+
+// highlighter marks up the instances of phrases in a column of a document
+// with markStart and markEnd like the highlight and snippet functions of
+// FTS5, which it is a port of.
+type highlighter struct {
+	doc    *searchDoc
+	insts  []searchInst
+	sizes  []int
+	column int
+
+	// inst is the next instance to merge and start and end are the first
+	// and last token of the current run of overlapping instances, or -1.
+	inst       int
+	start, end int
+
+	// rangeStart and rangeEnd are the first and last token of a snippet,
+	// or 0 and -1 to mark up the whole column.
+	rangeStart, rangeEnd int
+
+	pos, off int
+	open     bool
+	out      strings.Builder
+}
+
+func newHighlighter(doc *searchDoc, insts []searchInst, q *searchQuery, column int) *highlighter {
+	h := &highlighter{doc: doc, insts: insts, column: column, rangeEnd: -1}
+	for _, words := range q.words {
+		h.sizes = append(h.sizes, len(words))
+	}
+	h.next()
+	return h
+}
+
+// next advances to the next run of overlapping instances.
+func (h *highlighter) next() {
+	h.start, h.end = -1, -1
+	for ; h.inst < len(h.insts); h.inst++ {
+		inst := h.insts[h.inst]
+		if inst.column != h.column {
+			continue
+		}
+		end := inst.offset - 1 + h.sizes[inst.phrase]
+		if h.start < 0 {
+			h.start, h.end = inst.offset, end
+		} else if inst.offset <= h.end {
+			h.end = max(h.end, end)
+		} else {
+			break
+		}
+	}
+}
+
+// token marks up the next token of the column.
+func (h *highlighter) token(token searchToken) {
+	text := h.doc.columns[h.column]
+	pos := h.pos
+	h.pos++
+	if h.rangeEnd >= 0 {
+		if pos < h.rangeStart || pos > h.rangeEnd {
+			return
+		}
+		if h.rangeStart > 0 && pos == h.rangeStart {
+			h.off = token.start
+		}
+	}
+
+	if h.open && (pos <= h.start || h.start < 0) && token.start > h.off {
+		h.out.WriteString(markEnd)
+		h.open = false
+	}
+	if pos == h.start && !h.open {
+		h.out.WriteString(text[h.off:token.start])
+		h.out.WriteString(markStart)
+		h.off = token.start
+		h.open = true
+	}
+	if pos == h.end {
+		if !h.open {
+			h.out.WriteString(markStart)
+			h.open = true
+		}
+		h.out.WriteString(text[h.off:token.end])
+		h.off = token.end
+		h.next()
+	}
+	if pos == h.rangeEnd {
+		if h.open {
+			if h.start >= 0 && pos >= h.start {
+				h.out.WriteString(text[h.off:token.end])
+				h.off = token.end
+			}
+			h.out.WriteString(markEnd)
+			h.open = false
+		}
+		h.out.WriteString(text[h.off:token.end])
+		h.off = token.end
+	}
+}
+
+// markUp marks up the tokens of the column and closes the last mark.
+func (h *highlighter) markUp() {
+	for _, token := range h.doc.tokens[h.column] {
+		h.token(token)
+	}
+	if h.open {
+		h.out.WriteString(markEnd)
+	}
+}
+
+// highlight returns the HTML-escaped text of a column of a document with the
+// instances of phrases wrapped in <mark> tags.
+func highlight(doc *searchDoc, insts []searchInst, q *searchQuery, column int) string {
+	h := newHighlighter(doc, insts, q, column)
+	h.markUp()
+	h.out.WriteString(doc.columns[column][h.off:])
+	return escapeHighlight(h.out.String())
+}
+
+// snippetScore scores the snippet of a column starting at pos by the number
+// of distinct phrases and instances in it and returns the start of a
+// snippet of the same instances that centers them.
+func snippetScore(doc *searchDoc, insts []searchInst, q *searchQuery, column int, pos int) (int, int) {
+	seen := make([]bool, len(q.phrases))
+	score, first, last := 0, -1, 0
+	for _, inst := range insts {
+		if inst.column != column || inst.offset < pos || inst.offset >= pos+snippetTokens {
+			continue
+		}
+		if seen[inst.phrase] {
+			score++
+		} else {
+			score += 1000
+		}
+		seen[inst.phrase] = true
+		if first < 0 {
+			first = inst.offset
+		}
+		last = inst.offset + len(q.words[inst.phrase])
+	}
+	adjusted := first - (snippetTokens-(last-first))/2
+	if adjusted+snippetTokens > len(doc.tokens[column]) {
+		adjusted = len(doc.tokens[column]) - snippetTokens
+	}
+	return score, max(adjusted, 0)
+}
+
+// sentenceStarts returns the positions of the tokens of a column that start
+// sentences: the first one and those following a period or colon and
+// whitespace.
+func sentenceStarts(doc *searchDoc, column int) []int {
+	text := doc.columns[column]
+	starts := []int{}
+	for pos, token := range doc.tokens[column] {
+		if pos == 0 {
+			starts = append(starts, 0)
+			continue
+		}
+		i, c := token.start-1, byte(0)
+		for ; i >= 0; i-- {
+			c = text[i]
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				break
+			}
+		}
+		if i != token.start-1 && (c == '.' || c == ':') {
+			starts = append(starts, pos)
+		}
+	}
+	return starts
+}
+
+// snippet returns the HTML-escaped excerpt of a column of a document with the
+// most distinct phrases, preferring excerpts that start sentences, with the
+// instances of phrases wrapped in <mark> tags.
+func snippet(doc *searchDoc, insts []searchInst, q *searchQuery, column int) string {
+	size := len(doc.tokens[column])
+	starts := sentenceStarts(doc, column)
+	bestScore, bestStart := 0, 0
+	for _, inst := range insts {
+		if inst.column != column {
+			continue
+		}
+		score, start := snippetScore(doc, insts, q, column, inst.offset)
+		if score > bestScore {
+			bestScore, bestStart = score, start
+		}
+		if len(starts) > 0 && size > snippetTokens {
+			j := 0
+			for ; j < len(starts)-1; j++ {
+				if starts[j+1] > inst.offset {
+					break
+				}
+			}
+			if starts[j] < inst.offset {
+				score, _ := snippetScore(doc, insts, q, column, starts[j])
+				if starts[j] == 0 {
+					score += 120
+				} else {
+					score += 100
+				}
+				if score > bestScore {
+					bestScore, bestStart = score, starts[j]
+				}
+			}
+		}
+	}
+
+	h := newHighlighter(doc, insts, q, column)
+	h.rangeStart, h.rangeEnd = bestStart, bestStart+snippetTokens-1
+	if bestStart > 0 {
+		h.out.WriteString("…")
+	}
+	for h.start >= 0 && h.start < bestStart {
+		h.next()
+	}
+	h.markUp()
+	if h.rangeEnd >= size-1 {
+		h.out.WriteString(doc.columns[column][h.off:])
+	} else {
+		h.out.WriteString("…")
+	}
+	return escapeHighlight(h.out.String())
+}
@@ -0,0 +1,254 @@
+package repository
+
+import (
+	"context"
+	"sort"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// GetNoteAccess returns the role a user was granted on a note of a
+// workspace, like noteRepository.GetNoteAccess.
+// It returns ErrNoteNotFound if the note does not exist, belongs to another
+// workspace or is in the trash.
+func (r *memoryNoteRepository) GetNoteAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error) {
+	var role models.Role
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || !n.live(workspaceId) {
+			return &RepoError{"GetNoteAccess", id, ErrNoteNotFound}
+		}
+		role = grantedRole(userId, n.UserId, d.noteRank(userId, n))
+		return nil
+	})
+	return role, err
+}
+
+// GetNotebookAccess returns the role a user was granted on a notebook of a
+// workspace, like GetNoteAccess.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func (r *memoryNoteRepository) GetNotebookAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error) {
+	return memoryNotebookAccess(ctx, r.store, workspaceId, userId, id)
+}
+
+// memoryNotebookAccess returns the role a user was granted on a notebook of
+// a workspace.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func memoryNotebookAccess(ctx context.Context, store *MemoryStore, workspaceId int, userId int, id int) (models.Role, error) {
+	var role models.Role
+	err := store.readContext(ctx, func(d *memoryData) error {
+		notebook, ok := d.Notebooks[id]
+		if !ok || notebook.WorkspaceId != workspaceId {
+			return &RepoError{"GetNotebookAccess", id, ErrNotebookNotFound}
+		}
+		role = grantedRole(userId, notebook.UserId, d.pathRank(userId, notebook.Path))
+		return nil
+	})
+	return role, err
+}
+
+// GetNoteShares retrieves the owner of a note of a workspace followed by
+// the users the note is shared with directly.
+// It returns ErrNoteNotFound if the note does not exist or belongs to
+// another workspace.
+func (r *memoryNoteRepository) GetNoteShares(ctx context.Context, workspaceId int, id int) ([]*models.Share, error) {
+	var shares []*models.Share
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || n.WorkspaceId != workspaceId {
+			return &RepoError{"GetNoteShares", id, ErrNoteNotFound}
+		}
+		shares = d.memoryShares(n.UserId, n.CreatedAt, n.Shares)
+		return nil
+	})
+	return shares, err
+}
+
+// ShareNote grants the user with the given email role on a note of a
+// workspace, or changes the role if the note is already shared with the
+// user. The user does not need to be a member of the workspace.
+// It returns ErrNoteNotFound if the note does not exist, belongs to another
+// workspace or is in the trash, ErrUserNotFound if there is no user with the
+// email and ErrShareWithOwner if the user owns the note.
+func (r *memoryNoteRepository) ShareNote(ctx context.Context, workspaceId int, id int, email string, role models.Role) (*models.Share, error) {
+	var share *models.Share
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || !n.live(workspaceId) {
+			return &RepoError{"ShareNote", id, ErrNoteNotFound}
+		}
+		user := d.userByEmail(email)
+		if user == nil {
+			return &RepoError{"ShareNote", id, ErrUserNotFound}
+		}
+		if user.Id == n.UserId {
+			return &RepoError{"ShareNote", id, ErrShareWithOwner}
+		}
+		d.rememberNote(id)
+		if n.Shares == nil {
+			n.Shares = map[int]*memoryRole{}
+		}
+		share = memoryShare(n.Shares, user, role, r.now())
+		return nil
+	})
+	return share, err
+}
+
+// RevokeNoteShare stops sharing a note of a workspace with a user.
+// It returns ErrShareNotFound if the note is not shared with the user.
+func (r *memoryNoteRepository) RevokeNoteShare(ctx context.Context, workspaceId int, id int, userId int) error {
+	return r.store.writeContext(ctx, func(d *memoryData) error {
+		n, ok := d.Notes[id]
+		if !ok || n.WorkspaceId != workspaceId || n.Shares[userId] == nil {
+			return &RepoError{"RevokeNoteShare", id, ErrShareNotFound}
+		}
+		d.rememberNote(id)
+		delete(n.Shares, userId)
+		return nil
+	})
+}
+
+// GetSharedNotes retrieves the notes of a workspace created by other users
+// that are shared with a user, directly or through a notebook, ordered by
+// ID.
+func (r *memoryNoteRepository) GetSharedNotes(ctx context.Context, workspaceId int, userId int) ([]*models.SharedNote, error) {
+	notes := []*models.SharedNote{}
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		for _, id := range sortedIds(d.Notes) {
+			n := d.Notes[id]
+			if !n.live(workspaceId) || n.UserId == userId {
+				continue
+			}
+			if rank := d.noteRank(userId, n); rank > 0 {
+				shared := &models.SharedNote{Note: n.model(), Role: shareRoles[rank]}
+				if owner, ok := d.Users[n.UserId]; ok {
+					shared.Owner = owner.Email
+				}
+				notes = append(notes, shared)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+// CreateShareLink stores a share link to a note of a workspace by the hash
+// of its token and of its password, which is empty for links without one,
+// and sets the metadata of link to the values it was stored with.
+// It returns ErrNoteNotFound if the note belongs to another workspace.
+func (r *memoryNoteRepository) CreateShareLink(ctx context.Context, workspaceId int, noteId int, link *models.ShareLink, tokenHash string, passwordHash string) (int, error) {
+	now := memoryTime(r.now())
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		if n, ok := d.Notes[noteId]; !ok || n.WorkspaceId != workspaceId {
+			return &RepoError{"CreateShareLink", noteId, ErrNoteNotFound}
+		}
+		stored := &memoryShareLink{
+			Id:           d.nextId("share_links"),
+			NoteId:       noteId,
+			Prefix:       link.Prefix,
+			TokenHash:    tokenHash,
+			PasswordHash: passwordHash,
+			ExpiresAt:    memoryNullTime(link.ExpiresAt),
+			MaxViews:     copyInt(link.MaxViews),
+			CreatedAt:    now,
+		}
+		d.rememberShareLink(stored.Id)
+		d.ShareLinks[stored.Id] = stored
+		link.Id = stored.Id
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	link.NoteId, link.HasPassword, link.CreatedAt = noteId, passwordHash != "", now
+	return link.Id, nil
+}
+
+// GetShareLinks retrieves the share links of a note of a workspace that can
+// still be viewed, newest first.
+func (r *memoryNoteRepository) GetShareLinks(ctx context.Context, workspaceId int, noteId int) ([]*models.ShareLink, error) {
+	now := memoryTime(r.now())
+	links := []*models.ShareLink{}
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		if n, ok := d.Notes[noteId]; !ok || n.WorkspaceId != workspaceId {
+			return nil
+		}
+		for _, link := range d.ShareLinks {
+			if link.NoteId == noteId && link.active(now) {
+				links = append(links, link.model())
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].Id > links[j].Id })
+	return links, nil
+}
+
+// DeleteShareLink revokes a share link of a note of a workspace.
+// It returns ErrShareLinkNotFound if the note has no link with the ID.
+func (r *memoryNoteRepository) DeleteShareLink(ctx context.Context, workspaceId int, noteId int, id int) error {
+	return r.store.writeContext(ctx, func(d *memoryData) error {
+		link, ok := d.ShareLinks[id]
+		if !ok || link.NoteId != noteId || d.Notes[noteId] == nil || d.Notes[noteId].WorkspaceId != workspaceId {
+			return &RepoError{"DeleteShareLink", id, ErrShareLinkNotFound}
+		}
+		d.rememberShareLink(id)
+		delete(d.ShareLinks, id)
+		return nil
+	})
+}
+
+// GetShareLink retrieves the share link with the given token hash and the
+// hash of its password, which is empty if it has none.
+// It returns ErrShareLinkNotFound if the link is unknown or can no longer be
+// viewed.
+func (r *memoryNoteRepository) GetShareLink(ctx context.Context, tokenHash string) (*models.ShareLink, string, error) {
+	now := memoryTime(r.now())
+	var link *models.ShareLink
+	var passwordHash string
+	err := r.store.readContext(ctx, func(d *memoryData) error {
+		for _, l := range d.ShareLinks {
+			if l.TokenHash != tokenHash {
+				continue
+			}
+			if n, ok := d.Notes[l.NoteId]; ok && n.DeletedAt == nil && l.active(now) {
+				link, passwordHash = l.model(), l.PasswordHash
+				return nil
+			}
+		}
+		return &RepoError{Src: "GetShareLink", Err: ErrShareLinkNotFound}
+	})
+	return link, passwordHash, err
+}
+
+// ViewShareLink counts a view of a share link and retrieves its note. Views
+// are counted under the lock of the store, so a link is never viewed more
+// often than it allows.
+// It returns ErrShareLinkNotFound if the link can no longer be viewed.
+func (r *memoryNoteRepository) ViewShareLink(ctx context.Context, id int) (*models.Note, error) {
+	now := memoryTime(r.now())
+	var note *models.Note
+	err := r.store.writeContext(ctx, func(d *memoryData) error {
+		link, ok := d.ShareLinks[id]
+		if !ok || !link.active(now) {
+			return &RepoError{"ViewShareLink", id, ErrShareLinkNotFound}
+		}
+		n, ok := d.Notes[link.NoteId]
+		if !ok || n.DeletedAt != nil {
+			return &RepoError{"ViewShareLink", id, ErrShareLinkNotFound}
+		}
+		d.rememberShareLink(id)
+		link.Views++
+		note = n.model()
+		return nil
+	})
+	return note, err
+}
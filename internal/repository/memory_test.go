@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryMember registers a user with email in store and returns them as the
+// owner of their personal workspace.
+func memoryMember(t *testing.T, store *MemoryStore, email string) *models.Member {
+	userId, err := NewMemoryUsersRepository(store).Create(&models.User{Email: email, PasswordHash: "hash"})
+	require.NoError(t, err)
+	member, err := NewMemoryWorkspacesRepository(store).GetPersonalMember(userId)
+	require.NoError(t, err)
+	return member
+}
+
+func TestMemoryNoteRepository_IdsAreNeverReused(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	repo := NewMemoryNotesRepository(store)
+	repo.now = func() time.Time { return created }
+	ada := memoryMember(t, store, "ada@example.com")
+	note := &models.Note{Title: "Plan", Content: "Ship it"}
+	firstId, err := repo.Create(context.Background(), ada.WorkspaceId, ada.UserId, note)
+	require.NoError(t, err)
+	require.NoError(t, repo.Delete(context.Background(), ada.WorkspaceId, firstId, 0))
+	require.NoError(t, repo.Purge(context.Background(), ada.WorkspaceId, firstId))
+
+	// Act
+	secondId, err := repo.Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Plan", Content: "Ship it"})
+	_, getErr := repo.Get(context.Background(), ada.WorkspaceId, firstId)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, firstId)
+	assert.Equal(t, 2, secondId)
+	assert.Equal(t, created, note.CreatedAt)
+	assert.ErrorIs(t, getErr, ErrNoteNotFound)
+}
+
+func TestMemoryNoteRepository_ReturnsCopies(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	repo := NewMemoryNotesRepository(store)
+	ada := memoryMember(t, store, "ada@example.com")
+	id, err := repo.Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Plan", Content: "Ship it", Tags: []string{"work"}})
+	require.NoError(t, err)
+
+	// Act
+	note, err := repo.Get(context.Background(), ada.WorkspaceId, id)
+	require.NoError(t, err)
+	note.Title, note.Tags[0] = "Changed", "changed"
+	again, againErr := repo.Get(context.Background(), ada.WorkspaceId, id)
+
+	// Assert
+	assert.NoError(t, againErr)
+	assert.Equal(t, "Plan", again.Title)
+	assert.Equal(t, []string{"work"}, again.Tags)
+}
+
+func TestMemoryNotebookRepository_DeleteModes(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	notebooks := NewMemoryNotebooksRepository(store)
+	notes := NewMemoryNotesRepository(store)
+	ada := memoryMember(t, store, "ada@example.com")
+	create := func(name string, parentId *int) int {
+		id, err := notebooks.Create(ada.WorkspaceId, ada.UserId, &models.Notebook{Name: name, ParentId: parentId})
+		require.NoError(t, err)
+		return id
+	}
+	workId := create("Work", nil)
+	projectsId := create("Projects", &workId)
+	launchId := create("Launch", &projectsId)
+	noteId, err := notes.Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Plan", Content: "Ship it", NotebookId: &projectsId})
+	require.NoError(t, err)
+
+	// Act
+	blockErr := notebooks.Delete(ada.WorkspaceId, projectsId, models.NotebookDeleteBlock)
+	moveErr := notebooks.Delete(ada.WorkspaceId, projectsId, models.NotebookDeleteMove)
+	launch, launchErr := notebooks.Get(ada.WorkspaceId, launchId)
+	moved, movedErr := notes.Get(context.Background(), ada.WorkspaceId, noteId)
+	trashErr := notebooks.Delete(ada.WorkspaceId, workId, models.NotebookDeleteTrash)
+	_, trashedErr := notes.Get(context.Background(), ada.WorkspaceId, noteId)
+	all, allErr := notebooks.GetAll(ada.WorkspaceId)
+
+	// Assert
+	for _, err := range []error{moveErr, launchErr, movedErr, trashErr, allErr} {
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, blockErr, ErrNotebookNotEmpty)
+	assert.Equal(t, workId, *launch.ParentId)
+	assert.Equal(t, "/1/3/", launch.Path)
+	assert.Equal(t, workId, *moved.NotebookId)
+	assert.Equal(t, 2, moved.Version)
+	assert.ErrorIs(t, trashedErr, ErrNoteNotFound)
+	assert.Empty(t, all)
+}
+
+func TestMemoryNotebookRepository_MoveIntoItself(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	repo := NewMemoryNotebooksRepository(store)
+	ada := memoryMember(t, store, "ada@example.com")
+	workId, err := repo.Create(ada.WorkspaceId, ada.UserId, &models.Notebook{Name: "Work"})
+	require.NoError(t, err)
+	projectsId, err := repo.Create(ada.WorkspaceId, ada.UserId, &models.Notebook{Name: "Projects", ParentId: &workId})
+	require.NoError(t, err)
+
+	// Act
+	err = repo.Move(ada.WorkspaceId, workId, &projectsId)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotebookCycle)
+}
+
+func TestMemoryWorkspaceRepository_Members(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	workspaces := NewMemoryWorkspacesRepository(store)
+	ada := memoryMember(t, store, "ada@example.com")
+	memoryMember(t, store, "Grace@example.com")
+	teamId, err := workspaces.Create(ada.UserId, &models.Workspace{Name: "Team"})
+	require.NoError(t, err)
+
+	// Act
+	_, personalErr := workspaces.AddMember(ada.WorkspaceId, "grace@example.com", models.RoleViewer)
+	grace, addErr := workspaces.AddMember(teamId, "GRACE@example.com", models.RoleEditor)
+	_, demoteErr := workspaces.AddMember(teamId, "ada@example.com", models.RoleViewer)
+	members, membersErr := workspaces.GetMembers(teamId)
+	lastOwnerErr := workspaces.RemoveMember(teamId, ada.UserId)
+	removeErr := workspaces.RemoveMember(teamId, grace.UserId)
+	all, allErr := workspaces.GetAll(ada.UserId)
+
+	// Assert
+	for _, err := range []error{addErr, membersErr, removeErr, allErr} {
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, personalErr, ErrPersonalWorkspace)
+	assert.Equal(t, "Grace@example.com", grace.Email)
+	assert.ErrorIs(t, demoteErr, ErrLastOwner)
+	require.Len(t, members, 2)
+	assert.Equal(t, ada.UserId, members[0].UserId)
+	assert.ErrorIs(t, lastOwnerErr, ErrLastOwner)
+	require.Len(t, all, 2)
+	assert.True(t, all[0].Personal)
+	assert.Equal(t, teamId, all[1].Id)
+}
+
+func TestMemoryWebhookRepository_Deliveries(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	notes := NewMemoryNotesRepository(store)
+	webhooks := NewMemoryWebhooksRepository(store)
+	ada := memoryMember(t, store, "ada@example.com")
+	webhookId, err := webhooks.Create(ada.WorkspaceId, &models.Webhook{URL: "https://example.com/hook", Secret: "secret", Events: []models.EventType{models.EventNoteCreated}})
+	require.NoError(t, err)
+	_, err = notes.Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Plan", Content: "Ship it"})
+	require.NoError(t, err)
+
+	// Act
+	due, dueErr := webhooks.GetDueDeliveries(time.Now(), 10)
+	require.NoError(t, dueErr)
+	require.Len(t, due, 1)
+	other := *due[0]
+	claimErr := webhooks.ClaimDelivery(due[0], time.Now(), time.Now().Add(time.Minute))
+	claimedErr := webhooks.ClaimDelivery(&other, time.Now(), time.Now().Add(time.Minute))
+	dueLeased, dueLeasedErr := webhooks.GetDueDeliveries(time.Now(), 10)
+	other.Status, other.Attempts, other.NextAttemptAt = models.DeliveryDead, 1, nil
+	otherUpdateErr := webhooks.UpdateDelivery(&other)
+	due[0].Status, due[0].Attempts, due[0].NextAttemptAt = models.DeliverySucceeded, 1, nil
+	updateErr := webhooks.UpdateDelivery(due[0])
+	dueAfter, dueAfterErr := webhooks.GetDueDeliveries(time.Now(), 10)
+	deliveries, deliveriesErr := webhooks.GetDeliveries(ada.WorkspaceId, webhookId)
+	deleteErr := webhooks.Delete(ada.WorkspaceId, webhookId)
+	_, redeliverErr := webhooks.Redeliver(ada.WorkspaceId, webhookId, due[0].Id)
+
+	// Assert
+	for _, err := range []error{claimErr, dueLeasedErr, updateErr, dueAfterErr, deliveriesErr, deleteErr} {
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, claimedErr, ErrDeliveryClaimed)
+	assert.Empty(t, dueLeased)
+	assert.ErrorIs(t, otherUpdateErr, ErrDeliveryNotFound)
+	assert.Equal(t, "https://example.com/hook", due[0].URL)
+	assert.Equal(t, "secret", due[0].Secret)
+	assert.Empty(t, dueAfter)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.DeliverySucceeded, deliveries[0].Status)
+	assert.Empty(t, deliveries[0].Secret)
+	assert.ErrorIs(t, redeliverErr, ErrDeliveryNotFound)
+}
+
+func TestMemoryStore_SnapshotRoundtrip(t *testing.T) {
+	// Arrange
+	path := filepath.Join(t.TempDir(), "notes.json")
+	store := NewMemoryStore()
+	ada := memoryMember(t, store, "ada@example.com")
+	noteId, err := NewMemoryNotesRepository(store).Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Plan", Content: "Ship it", Tags: []string{"work"}})
+	require.NoError(t, err)
+
+	// Act
+	saveErr := store.Save(path)
+	loaded, loadErr := LoadMemoryStore(path)
+	require.NoError(t, loadErr)
+	note, getErr := NewMemoryNotesRepository(loaded).Get(context.Background(), ada.WorkspaceId, noteId)
+	nextId, createErr := NewMemoryNotesRepository(loaded).Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Next", Content: "Later"})
+	empty, emptyErr := LoadMemoryStore(filepath.Join(t.TempDir(), "missing.json"))
+
+	// Assert
+	for _, err := range []error{saveErr, getErr, createErr, emptyErr} {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, "Plan", note.Title)
+	assert.Equal(t, []string{"work"}, note.Tags)
+	assert.Equal(t, noteId+1, nextId)
+	assert.Empty(t, empty.data.Notes)
+}
+
+func TestMemoryNoteRepository_ConcurrentUpdates(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	repo := NewMemoryNotesRepository(store)
+	ada := memoryMember(t, store, "ada@example.com")
+	id, err := repo.Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Counter", Content: "0"})
+	require.NoError(t, err)
+
+	// Act
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				note, err := repo.Get(context.Background(), ada.WorkspaceId, id)
+				require.NoError(t, err)
+				err = repo.Update(context.Background(), ada.WorkspaceId, id, &models.Note{Title: "Counter", Content: note.Content + "+"}, note.Version)
+				if err == nil {
+					return
+				}
+				require.ErrorIs(t, err, ErrVersionConflict)
+			}
+		}()
+	}
+	wg.Wait()
+	note, err := repo.Get(context.Background(), ada.WorkspaceId, id)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 51, note.Version)
+	assert.Len(t, note.Content, 51)
+}
+
+func TestMemoryTxManager_SavepointUndoesOnlyItsWrites(t *testing.T) {
+	// Arrange
+	store := NewMemoryStore()
+	repo := NewMemoryNotesRepository(store)
+	ada := memoryMember(t, store, "ada@example.com")
+	untouchedId, err := repo.Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Untouched", Content: "Same"})
+	require.NoError(t, err)
+	planId, err := repo.Create(context.Background(), ada.WorkspaceId, ada.UserId, &models.Note{Title: "Plan", Content: "Ship it"})
+	require.NoError(t, err)
+	untouched := store.data.Notes[untouchedId]
+	failure := errors.New("failure")
+	manager := NewMemoryTxManager(store)
+
+	// Act
+	var innerErr error
+	var steps, innerSteps int
+	err = manager.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := repo.Update(ctx, ada.WorkspaceId, planId, &models.Note{Title: "Plan", Content: "Shipped"}, 1); err != nil {
+			return err
+		}
+		steps = len(store.data.undo.steps)
+		innerErr = manager.WithinTx(ctx, func(ctx context.Context) error {
+			if err := repo.Update(ctx, ada.WorkspaceId, planId, &models.Note{Title: "Plan", Content: "Undone"}, 2); err != nil {
+				return err
+			}
+			if err := repo.Delete(ctx, ada.WorkspaceId, planId, 3); err != nil {
+				return err
+			}
+			innerSteps = len(store.data.undo.steps) - steps
+			return failure
+		})
+		return nil
+	})
+	plan, planErr := repo.Get(context.Background(), ada.WorkspaceId, planId)
+
+	// Assert
+	require.NoError(t, err)
+	assert.ErrorIs(t, innerErr, failure)
+	assert.Equal(t, 1, steps)
+	assert.Equal(t, 2, innerSteps)
+	require.NoError(t, planErr)
+	assert.Equal(t, "Shipped", plan.Content)
+	assert.Equal(t, 2, plan.Version)
+	assert.Same(t, untouched, store.data.Notes[untouchedId])
+	assert.Nil(t, store.data.undo)
+}
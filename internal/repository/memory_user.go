@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// memoryUserRepository implements the UserRepository interface on a
+// MemoryStore.
+type memoryUserRepository struct {
+	store *MemoryStore
+	now   func() time.Time
+}
+
+// NewMemoryUsersRepository creates a new memoryUserRepository.
+func NewMemoryUsersRepository(store *MemoryStore) *memoryUserRepository {
+	return &memoryUserRepository{store: store, now: time.Now}
+}
+
+// Create adds a new user to the store along with their personal workspace
+// and sets its creation time. Unlike the database, a store never holds
+// notes created before there were users.
+// It returns ErrUserExists if there already is a user with the email,
+// compared case-insensitively.
+func (r *memoryUserRepository) Create(user *models.User) (int, error) {
+	now := memoryTime(r.now())
+	err := r.store.write(func(d *memoryData) error {
+		if d.userByEmail(user.Email) != nil {
+			return &RepoError{Src: "CreateUser", Err: ErrUserExists}
+		}
+		stored := &memoryUser{Id: d.nextId("users"), Email: user.Email, PasswordHash: user.PasswordHash, CreatedAt: now}
+		d.Users[stored.Id] = stored
+		d.createWorkspace(stored.Id, "Personal", true, now)
+		user.Id = stored.Id
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	user.CreatedAt = now
+	return user.Id, nil
+}
+
+// GetByEmail retrieves the user with the given email, compared
+// case-insensitively.
+// It returns ErrUserNotFound if there is no such user.
+func (r *memoryUserRepository) GetByEmail(email string) (*models.User, error) {
+	var user *models.User
+	err := r.store.read(func(d *memoryData) error {
+		u := d.userByEmail(email)
+		if u == nil {
+			return &RepoError{Src: "GetUserByEmail", Err: ErrUserNotFound}
+		}
+		user = u.model()
+		return nil
+	})
+	return user, err
+}
+
+// CreateSession stores a session of a user by the hash of its token and
+// removes the expired sessions of the user.
+func (r *memoryUserRepository) CreateSession(userId int, tokenHash string, expiresAt time.Time) error {
+	now := memoryTime(r.now())
+	return r.store.write(func(d *memoryData) error {
+		for hash, session := range d.Sessions {
+			if session.UserId == userId && !session.ExpiresAt.After(now) {
+				delete(d.Sessions, hash)
+			}
+		}
+		d.Sessions[tokenHash] = &memorySession{UserId: userId, CreatedAt: now, ExpiresAt: memoryTime(expiresAt)}
+		return nil
+	})
+}
+
+// GetSessionUser retrieves the user of the session stored under tokenHash.
+// It returns ErrSessionNotFound if there is no such session or it has
+// expired.
+func (r *memoryUserRepository) GetSessionUser(tokenHash string) (*models.User, error) {
+	now := memoryTime(r.now())
+	var user *models.User
+	err := r.store.read(func(d *memoryData) error {
+		session, ok := d.Sessions[tokenHash]
+		if !ok || !session.ExpiresAt.After(now) || d.Users[session.UserId] == nil {
+			return &RepoError{Src: "GetSessionUser", Err: ErrSessionNotFound}
+		}
+		user = d.Users[session.UserId].model()
+		return nil
+	})
+	return user, err
+}
+
+// DeleteSession removes the session stored under tokenHash, so its token
+// can no longer be used.
+// It returns ErrSessionNotFound if there is no such session.
+func (r *memoryUserRepository) DeleteSession(tokenHash string) error {
+	return r.store.write(func(d *memoryData) error {
+		if _, ok := d.Sessions[tokenHash]; !ok {
+			return &RepoError{Src: "DeleteSession", Err: ErrSessionNotFound}
+		}
+		delete(d.Sessions, tokenHash)
+		return nil
+	})
+}
+
+// CreateAPIKey stores an API key of a user by the hash of the key and sets
+// its creation time.
+func (r *memoryUserRepository) CreateAPIKey(userId int, key *models.APIKey, keyHash string) (int, error) {
+	now := memoryTime(r.now())
+	r.store.write(func(d *memoryData) error {
+		stored := &memoryAPIKey{
+			Id:        d.nextId("api_keys"),
+			UserId:    userId,
+			Name:      key.Name,
+			Prefix:    key.Prefix,
+			KeyHash:   keyHash,
+			Scopes:    append([]models.Scope{}, key.Scopes...),
+			CreatedAt: now,
+			ExpiresAt: memoryNullTime(key.ExpiresAt),
+		}
+		d.APIKeys[stored.Id] = stored
+		key.Id = stored.Id
+		return nil
+	})
+	key.CreatedAt = now
+	return key.Id, nil
+}
+
+// GetAPIKeys retrieves the API keys of a user, including expired ones,
+// newest first.
+func (r *memoryUserRepository) GetAPIKeys(userId int) ([]*models.APIKey, error) {
+	keys := []*models.APIKey{}
+	r.store.read(func(d *memoryData) error {
+		for _, key := range d.APIKeys {
+			if key.UserId == userId {
+				keys = append(keys, key.model())
+			}
+		}
+		return nil
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].Id > keys[j].Id })
+	return keys, nil
+}
+
+// DeleteAPIKey revokes an API key of a user.
+// It returns ErrAPIKeyNotFound if the user has no key with the id.
+func (r *memoryUserRepository) DeleteAPIKey(userId int, id int) error {
+	return r.store.write(func(d *memoryData) error {
+		if key, ok := d.APIKeys[id]; !ok || key.UserId != userId {
+			return &RepoError{"DeleteAPIKeyByID", id, ErrAPIKeyNotFound}
+		}
+		delete(d.APIKeys, id)
+		return nil
+	})
+}
+
+// GetAPIKeyUser retrieves the API key stored under keyHash and its user and
+// records that the key was used.
+// It returns ErrAPIKeyNotFound if there is no such key or it has expired.
+func (r *memoryUserRepository) GetAPIKeyUser(keyHash string) (*models.User, *models.APIKey, error) {
+	now := memoryTime(r.now())
+	var user *models.User
+	var key *models.APIKey
+	err := r.store.write(func(d *memoryData) error {
+		for _, k := range d.APIKeys {
+			if k.KeyHash != keyHash || k.ExpiresAt != nil && !k.ExpiresAt.After(now) || d.Users[k.UserId] == nil {
+				continue
+			}
+			if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= lastUsedInterval {
+				lastUsedAt := now
+				k.LastUsedAt = &lastUsedAt
+			}
+			user, key = d.Users[k.UserId].model(), k.model()
+			return nil
+		}
+		return &RepoError{Src: "GetAPIKeyUser", Err: ErrAPIKeyNotFound}
+	})
+	return user, key, err
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// memoryWebhookRepository implements the WebhookRepository interface on a
+// MemoryStore.
+type memoryWebhookRepository struct {
+	store *MemoryStore
+	now   func() time.Time
+}
+
+// NewMemoryWebhooksRepository creates a new memoryWebhookRepository.
+func NewMemoryWebhooksRepository(store *MemoryStore) *memoryWebhookRepository {
+	return &memoryWebhookRepository{store: store, now: time.Now}
+}
+
+// model returns a copy of the webhook as it is returned by the
+// repositories, without its secret.
+func (w *memoryWebhook) model() *models.Webhook {
+	return &models.Webhook{Id: w.Id, URL: w.URL, Events: append([]models.EventType{}, w.Events...), CreatedAt: w.CreatedAt}
+}
+
+// copyDelivery returns a copy of a stored delivery that can be handed out
+// without sharing its fields with the store.
+func copyDelivery(d *models.WebhookDelivery) *models.WebhookDelivery {
+	delivery := *d
+	delivery.NextAttemptAt = memoryNullTime(d.NextAttemptAt)
+	delivery.DeliveredAt = memoryNullTime(d.DeliveredAt)
+	delivery.LeasedUntil = memoryNullTime(d.LeasedUntil)
+	return &delivery
+}
+
+// GetAll retrieves the webhooks of a workspace, without their secrets.
+func (r *memoryWebhookRepository) GetAll(workspaceId int) ([]*models.Webhook, error) {
+	webhooks := []*models.Webhook{}
+	r.store.read(func(d *memoryData) error {
+		for _, id := range sortedIds(d.Webhooks) {
+			if webhook := d.Webhooks[id]; webhook.WorkspaceId == workspaceId {
+				webhooks = append(webhooks, webhook.model())
+			}
+		}
+		return nil
+	})
+	return webhooks, nil
+}
+
+// Create adds a webhook to a workspace and sets the metadata of webhook to
+// the values it was stored with.
+func (r *memoryWebhookRepository) Create(workspaceId int, webhook *models.Webhook) (int, error) {
+	now := memoryTime(r.now())
+	events := []models.EventType{}
+	for _, event := range webhook.Events {
+		if !hasEvent(events, event) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+	r.store.write(func(d *memoryData) error {
+		stored := &memoryWebhook{
+			Id:          d.nextId("webhooks"),
+			WorkspaceId: workspaceId,
+			URL:         webhook.URL,
+			Secret:      webhook.Secret,
+			Events:      events,
+			CreatedAt:   now,
+		}
+		d.Webhooks[stored.Id] = stored
+		webhook.Id = stored.Id
+		return nil
+	})
+	webhook.CreatedAt = now
+	return webhook.Id, nil
+}
+
+// Delete removes a webhook of a workspace along with its deliveries.
+// It returns ErrWebhookNotFound if the workspace has no webhook with the ID.
+func (r *memoryWebhookRepository) Delete(workspaceId int, id int) error {
+	return r.store.write(func(d *memoryData) error {
+		if webhook, ok := d.Webhooks[id]; !ok || webhook.WorkspaceId != workspaceId {
+			return &RepoError{"DeleteWebhook", id, ErrWebhookNotFound}
+		}
+		delete(d.Webhooks, id)
+		for deliveryId, delivery := range d.Deliveries {
+			if delivery.WebhookId == id {
+				delete(d.Deliveries, deliveryId)
+			}
+		}
+		return nil
+	})
+}
+
+// GetDeliveries retrieves the last DeliveryHistory deliveries of a webhook
+// of a workspace, newest first.
+// It returns ErrWebhookNotFound if the workspace has no webhook with the ID.
+func (r *memoryWebhookRepository) GetDeliveries(workspaceId int, webhookId int) ([]*models.WebhookDelivery, error) {
+	deliveries := []*models.WebhookDelivery{}
+	err := r.store.read(func(d *memoryData) error {
+		if webhook, ok := d.Webhooks[webhookId]; !ok || webhook.WorkspaceId != workspaceId {
+			return &RepoError{"GetWebhookDeliveries", webhookId, ErrWebhookNotFound}
+		}
+		for _, delivery := range d.Deliveries {
+			if delivery.WebhookId == webhookId {
+				deliveries = append(deliveries, copyDelivery(delivery))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Id > deliveries[j].Id })
+	if len(deliveries) > DeliveryHistory {
+		deliveries = deliveries[:DeliveryHistory]
+	}
+	return deliveries, nil
+}
+
+// Redeliver makes a delivery of a webhook of a workspace pending again with
+// a fresh set of attempts, to be sent right away, and returns it.
+// It returns ErrDeliveryNotFound if the workspace has no such delivery.
+func (r *memoryWebhookRepository) Redeliver(workspaceId int, webhookId int, id int) (*models.WebhookDelivery, error) {
+	now := memoryTime(r.now())
+	var delivery *models.WebhookDelivery
+	err := r.store.write(func(d *memoryData) error {
+		stored, ok := d.Deliveries[id]
+		if !ok || stored.WebhookId != webhookId || d.Webhooks[webhookId] == nil || d.Webhooks[webhookId].WorkspaceId != workspaceId {
+			return &RepoError{"RedeliverWebhook", id, ErrDeliveryNotFound}
+		}
+		stored.Status, stored.Attempts, stored.NextAttemptAt = models.DeliveryPending, 0, &now
+		delivery = copyDelivery(stored)
+		return nil
+	})
+	return delivery, err
+}
+
+// GetDueDeliveries retrieves up to limit pending deliveries of all
+// workspaces that are due at t and not leased, oldest first, together with
+// the URL and secret of their webhook.
+func (r *memoryWebhookRepository) GetDueDeliveries(t time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	t = memoryTime(t)
+	deliveries := []*models.WebhookDelivery{}
+	r.store.read(func(d *memoryData) error {
+		for _, stored := range d.Deliveries {
+			if !deliveryDue(stored, t) {
+				continue
+			}
+			delivery := copyDelivery(stored)
+			if webhook, ok := d.Webhooks[delivery.WebhookId]; ok {
+				delivery.URL, delivery.Secret = webhook.URL, webhook.Secret
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+	sort.Slice(deliveries, func(i, j int) bool {
+		if a, b := *deliveries[i].NextAttemptAt, *deliveries[j].NextAttemptAt; !a.Equal(b) {
+			return a.Before(b)
+		}
+		return deliveries[i].Id < deliveries[j].Id
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// deliveryDue reports whether a stored delivery is pending, due at t and
+// not leased.
+func deliveryDue(delivery *models.WebhookDelivery, t time.Time) bool {
+	return delivery.Status == models.DeliveryPending && delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(t) &&
+		(delivery.LeasedUntil == nil || !delivery.LeasedUntil.After(t))
+}
+
+// ClaimDelivery leases a delivery retrieved with GetDueDeliveries until
+// until, so that no other dispatcher sends it meanwhile, and sets its
+// LeasedUntil. The delivery must still be due at t, unchanged, and not be
+// leased by another dispatcher.
+// It returns ErrDeliveryClaimed if the delivery cannot be claimed.
+func (r *memoryWebhookRepository) ClaimDelivery(delivery *models.WebhookDelivery, t time.Time, until time.Time) error {
+	t, until = memoryTime(t), memoryTime(until)
+	err := r.store.write(func(d *memoryData) error {
+		stored, ok := d.Deliveries[delivery.Id]
+		if !ok || stored.Attempts != delivery.Attempts || !deliveryDue(stored, t) {
+			return &RepoError{"ClaimDelivery", delivery.Id, ErrDeliveryClaimed}
+		}
+		stored.LeasedUntil = memoryNullTime(&until)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	delivery.LeasedUntil = &until
+	return nil
+}
+
+// UpdateDelivery stores the outcome of an attempt to send a delivery and
+// ends its lease. Only the outcome of the last dispatcher to lease it is
+// stored.
+// It returns ErrDeliveryNotFound if the delivery no longer exists because
+// its webhook was deleted, or was leased by another dispatcher since.
+func (r *memoryWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	return r.store.write(func(d *memoryData) error {
+		stored, ok := d.Deliveries[delivery.Id]
+		if !ok || stored.LeasedUntil != nil && (delivery.LeasedUntil == nil || !stored.LeasedUntil.Equal(*delivery.LeasedUntil)) {
+			return &RepoError{"UpdateDelivery", delivery.Id, ErrDeliveryNotFound}
+		}
+		stored.Status, stored.Attempts = delivery.Status, delivery.Attempts
+		stored.NextAttemptAt = memoryNullTime(delivery.NextAttemptAt)
+		stored.LastStatusCode, stored.LastError = delivery.LastStatusCode, delivery.LastError
+		stored.DeliveredAt = memoryNullTime(delivery.DeliveredAt)
+		stored.LeasedUntil = nil
+		return nil
+	})
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"sort"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// memoryWorkspaceRepository implements the WorkspaceRepository interface on
+// a MemoryStore.
+type memoryWorkspaceRepository struct {
+	store *MemoryStore
+	now   func() time.Time
+}
+
+// NewMemoryWorkspacesRepository creates a new memoryWorkspaceRepository.
+func NewMemoryWorkspacesRepository(store *MemoryStore) *memoryWorkspaceRepository {
+	return &memoryWorkspaceRepository{store: store, now: time.Now}
+}
+
+// model returns a copy of the workspace as it is returned to a member with
+// role.
+func (w *memoryWorkspace) model(role models.Role) *models.Workspace {
+	return &models.Workspace{Id: w.Id, Name: w.Name, Personal: w.PersonalUserId != nil, Role: role, CreatedAt: w.CreatedAt}
+}
+
+// ownerLeft reports whether a workspace has an owner other than userId.
+func (w *memoryWorkspace) ownerLeft(userId int) bool {
+	for id, member := range w.Members {
+		if id != userId && member.Role == models.RoleOwner {
+			return true
+		}
+	}
+	return false
+}
+
+// GetMember retrieves the membership of a user in a workspace. Users who
+// are not members but were shared a note or notebook of the workspace are
+// guests with an empty role.
+// It returns ErrWorkspaceNotFound if the workspace does not exist or the
+// user is neither a member nor a guest of it.
+func (r *memoryWorkspaceRepository) GetMember(workspaceId int, userId int) (*models.Member, error) {
+	var m *models.Member
+	err := r.store.read(func(d *memoryData) error {
+		workspace, ok := d.Workspaces[workspaceId]
+		if !ok {
+			return &RepoError{"GetWorkspaceMember", workspaceId, ErrWorkspaceNotFound}
+		}
+		if member := workspace.Members[userId]; member != nil {
+			m = &models.Member{WorkspaceId: workspaceId, UserId: userId, Role: member.Role}
+			return nil
+		}
+		if !d.guest(workspaceId, userId) {
+			return &RepoError{"GetWorkspaceMember", workspaceId, ErrWorkspaceNotFound}
+		}
+		m = &models.Member{WorkspaceId: workspaceId, UserId: userId}
+		return nil
+	})
+	return m, err
+}
+
+// GetPersonalMember retrieves the membership of a user in their personal
+// workspace.
+// It returns ErrWorkspaceNotFound if the user has none.
+func (r *memoryWorkspaceRepository) GetPersonalMember(userId int) (*models.Member, error) {
+	var m *models.Member
+	err := r.store.read(func(d *memoryData) error {
+		for _, workspace := range d.Workspaces {
+			if workspace.PersonalUserId != nil && *workspace.PersonalUserId == userId && workspace.Members[userId] != nil {
+				m = &models.Member{WorkspaceId: workspace.Id, UserId: userId, Role: workspace.Members[userId].Role}
+				return nil
+			}
+		}
+		return &RepoError{"GetPersonalMember", userId, ErrWorkspaceNotFound}
+	})
+	return m, err
+}
+
+// GetAll retrieves the workspaces a user is a member of along with their
+// role, the personal workspace first.
+func (r *memoryWorkspaceRepository) GetAll(userId int) ([]*models.Workspace, error) {
+	workspaces := []*models.Workspace{}
+	r.store.read(func(d *memoryData) error {
+		for _, id := range sortedIds(d.Workspaces) {
+			if member := d.Workspaces[id].Members[userId]; member != nil {
+				workspaces = append(workspaces, d.Workspaces[id].model(member.Role))
+			}
+		}
+		return nil
+	})
+	sort.SliceStable(workspaces, func(i, j int) bool { return workspaces[i].Personal && !workspaces[j].Personal })
+	return workspaces, nil
+}
+
+// Create adds a new workspace with a user as its owner and sets the
+// metadata of workspace to the values it was stored with.
+func (r *memoryWorkspaceRepository) Create(userId int, workspace *models.Workspace) (int, error) {
+	now := memoryTime(r.now())
+	r.store.write(func(d *memoryData) error {
+		workspace.Id = d.createWorkspace(userId, workspace.Name, false, now)
+		return nil
+	})
+	workspace.Personal, workspace.Role, workspace.CreatedAt = false, models.RoleOwner, now
+	return workspace.Id, nil
+}
+
+// GetMembers retrieves the members of a workspace, owners first and then by
+// email.
+func (r *memoryWorkspaceRepository) GetMembers(workspaceId int) ([]*models.WorkspaceMember, error) {
+	members := []*models.WorkspaceMember{}
+	r.store.read(func(d *memoryData) error {
+		workspace, ok := d.Workspaces[workspaceId]
+		if !ok {
+			return nil
+		}
+		for userId, member := range workspace.Members {
+			if user, ok := d.Users[userId]; ok {
+				members = append(members, &models.WorkspaceMember{UserId: userId, Email: user.Email, Role: member.Role, CreatedAt: member.CreatedAt})
+			}
+		}
+		return nil
+	})
+	sort.Slice(members, func(i, j int) bool {
+		if a, b := members[i].Role == models.RoleOwner, members[j].Role == models.RoleOwner; a != b {
+			return a
+		}
+		return lessEmail(members[i].Email, members[j].Email)
+	})
+	return members, nil
+}
+
+// AddMember makes the user with the given email a member of a workspace
+// with role, or changes the role if the user already is a member.
+// It returns ErrWorkspaceNotFound if the workspace does not exist,
+// ErrPersonalWorkspace if it is a personal workspace, ErrUserNotFound if
+// there is no user with the email and ErrLastOwner if the role of the last
+// owner would change.
+func (r *memoryWorkspaceRepository) AddMember(workspaceId int, email string, role models.Role) (*models.WorkspaceMember, error) {
+	var member *models.WorkspaceMember
+	err := r.store.write(func(d *memoryData) error {
+		workspace, ok := d.Workspaces[workspaceId]
+		if !ok {
+			return &RepoError{"AddWorkspaceMember", workspaceId, ErrWorkspaceNotFound}
+		}
+		if workspace.PersonalUserId != nil {
+			return &RepoError{"AddWorkspaceMember", workspaceId, ErrPersonalWorkspace}
+		}
+		user := d.userByEmail(email)
+		if user == nil {
+			return &RepoError{"AddWorkspaceMember", workspaceId, ErrUserNotFound}
+		}
+		if role != models.RoleOwner && !workspace.ownerLeft(user.Id) {
+			return &RepoError{"AddWorkspaceMember", workspaceId, ErrLastOwner}
+		}
+		stored, ok := workspace.Members[user.Id]
+		if !ok {
+			stored = &memoryRole{CreatedAt: memoryTime(r.now())}
+			workspace.Members[user.Id] = stored
+		}
+		stored.Role = role
+		member = &models.WorkspaceMember{UserId: user.Id, Email: user.Email, Role: role, CreatedAt: stored.CreatedAt}
+		return nil
+	})
+	return member, err
+}
+
+// RemoveMember removes a user from a workspace along with the shares they
+// were granted in it. The notes and notebooks they created stay in the
+// workspace.
+// It returns ErrMemberNotFound if the user is not a member and ErrLastOwner
+// if they are its last owner.
+func (r *memoryWorkspaceRepository) RemoveMember(workspaceId int, userId int) error {
+	return r.store.write(func(d *memoryData) error {
+		workspace, ok := d.Workspaces[workspaceId]
+		if !ok || !workspace.ownerLeft(userId) {
+			return &RepoError{"RemoveWorkspaceMember", workspaceId, ErrLastOwner}
+		}
+		if workspace.Members[userId] == nil {
+			return &RepoError{"RemoveWorkspaceMember", workspaceId, ErrMemberNotFound}
+		}
+		delete(workspace.Members, userId)
+		for _, note := range d.Notes {
+			if note.WorkspaceId == workspaceId {
+				delete(note.Shares, userId)
+			}
+		}
+		for _, notebook := range d.Notebooks {
+			if notebook.WorkspaceId == workspaceId {
+				delete(notebook.Shares, userId)
+			}
+		}
+		return nil
+	})
+}
@@ -1,15 +1,27 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"html"
+	"strings"
+	"time"
 
 	"github.com/JannisK89/notes-api/internal/models"
 )
 
-// ErrNoteNotFound is returned when a note with the given ID is not found in the database.
-var ErrNoteNotFound = errors.New("note not found")
+var (
+	// ErrNoteNotFound is returned when a note with the given ID is not found in the database.
+	ErrNoteNotFound = errors.New("note not found")
+	// ErrInvalidSearchQuery is returned when a search query is not valid FTS5
+	// query syntax.
+	ErrInvalidSearchQuery = errors.New("invalid search query")
+	// ErrVersionConflict is returned when a note is written on the condition
+	// that it is at a given version, but it has been changed since.
+	ErrVersionConflict = errors.New("note version conflict")
+)
 
 // RepoError represents an error that occurred within the repository layer.
 // It wraps the underlying error and provides additional context, such as the
@@ -28,22 +40,85 @@ func (e *RepoError) Unwrap() error {
 	return e.Err
 }
 
+// TimeFormat is the layout timestamps are stored in. It has a fixed width so
+// that stored timestamps sort chronologically as text.
+const TimeFormat = "2006-01-02T15:04:05.000Z"
+
+// noteColumns are the columns scanned by scanNote, in order.
+const noteColumns = "id, title, content, created_at, updated_at, version, deleted_at, notebook_id, " + tagsColumn
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanNote reads a note selected with noteColumns from row.
+func scanNote(row scanner, dest ...interface{}) (*models.Note, error) {
+	note := &models.Note{}
+	var createdAt, updatedAt string
+	var deletedAt, tags sql.NullString
+	var notebookId sql.NullInt64
+	err := row.Scan(append([]interface{}{&note.Id, &note.Title, &note.Content, &createdAt, &updatedAt, &note.Version, &deletedAt, &notebookId, &tags}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	note.Tags = splitTags(tags)
+	if notebookId.Valid {
+		id := int(notebookId.Int64)
+		note.NotebookId = &id
+	}
+	if note.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	if note.UpdatedAt, err = time.Parse(TimeFormat, updatedAt); err != nil {
+		return nil, err
+	}
+	if deletedAt.Valid {
+		t, err := time.Parse(TimeFormat, deletedAt.String)
+		if err != nil {
+			return nil, err
+		}
+		note.DeletedAt = &t
+	}
+	return note, nil
+}
+
+// formatTime formats t in TimeFormat.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(TimeFormat)
+}
+
+// DefaultMaxRevisions is the number of revisions kept per note unless
+// configured otherwise.
+const DefaultMaxRevisions = 50
+
 // noteRepository implements the NoteRepository interface.
 type noteRepository struct {
-	db *sql.DB
+	db  *sql.DB
+	now func() time.Time
+
+	// MaxRevisions is the number of revisions kept per note. Older revisions
+	// are removed when a new one is recorded. 0 keeps all revisions.
+	MaxRevisions int
 }
 
 // NewNotesRepository creates a new noteRepository.
 func NewNotesRepository(db *sql.DB) *noteRepository {
-	return &noteRepository{db}
+	return &noteRepository{db: db, now: time.Now, MaxRevisions: DefaultMaxRevisions}
 }
 
-// Get retrieves a note by its ID from the database.
-// It returns ErrNoteNotFound if the note is not found.
-func (r *noteRepository) Get(id int) (*models.Note, error) {
-	row := r.db.QueryRow("SELECT id, title, content FROM notes WHERE id = ?", id)
-	note := &models.Note{}
-	err := row.Scan(&note.Id, &note.Title, &note.Content)
+// conn returns what the queries of a call with ctx run on, the transaction
+// of its unit of work or the database.
+func (r *noteRepository) conn(ctx context.Context) querier {
+	return conn(ctx, r.db)
+}
+
+// Get retrieves a note of a workspace by its ID from the database.
+// It returns ErrNoteNotFound if the note is not found, belongs to another
+// workspace or is in the trash.
+func (r *noteRepository) Get(ctx context.Context, workspaceId int, id int) (*models.Note, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, "SELECT "+noteColumns+" FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL", id, workspaceId)
+	note, err := scanNote(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, &RepoError{"GetNoteByID", id, fmt.Errorf("%w: %v", ErrNoteNotFound, err)}
@@ -53,9 +128,123 @@ func (r *noteRepository) Get(id int) (*models.Note, error) {
 	return note, nil
 }
 
-// GetAll retrieves all notes from the database.
-func (r *noteRepository) GetAll() ([]*models.Note, error) {
-	rows, err := r.db.Query("SELECT id, title, content FROM notes")
+// sortColumns maps the sort fields accepted by GetAll to their columns.
+var sortColumns = map[models.SortField]string{
+	models.SortById:        "id",
+	models.SortByTitle:     "title",
+	models.SortByCreatedAt: "created_at",
+	models.SortByUpdatedAt: "updated_at",
+	models.SortByDeletedAt: "deleted_at",
+}
+
+// sortValue returns the value of the sort column for note, as stored in a
+// pagination cursor.
+func sortValue(note *models.Note, sort models.SortField) string {
+	switch sort {
+	case models.SortByTitle:
+		return note.Title
+	case models.SortByCreatedAt:
+		return formatTime(note.CreatedAt)
+	case models.SortByUpdatedAt:
+		return formatTime(note.UpdatedAt)
+	case models.SortByDeletedAt:
+		if note.DeletedAt == nil {
+			return ""
+		}
+		return formatTime(*note.DeletedAt)
+	default:
+		return ""
+	}
+}
+
+// escapeLike escapes the LIKE wildcards in s so it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// GetAll retrieves a page of the notes of a workspace from the database, filtered
+// and ordered according to opts. Notes in the trash are only listed,
+// exclusively, if opts.Trashed is set. It returns ErrInvalidCursor if
+// opts.Cursor was not issued for the same sort order.
+func (r *noteRepository) GetAll(ctx context.Context, workspaceId int, opts models.ListOptions) (*models.NotePage, error) {
+	if opts.Sort == "" {
+		opts.Sort = models.SortById
+	}
+	column, ok := sortColumns[opts.Sort]
+	if !ok {
+		return nil, &RepoError{Src: "GetAllNotes", Err: fmt.Errorf("unknown sort field %q", opts.Sort)}
+	}
+
+	where := []string{"workspace_id = ?", "deleted_at IS NULL"}
+	if opts.Trashed {
+		where[1] = "deleted_at IS NOT NULL"
+	}
+	args := []interface{}{workspaceId}
+	if opts.Title != "" {
+		where = append(where, `lower(title) LIKE lower(?) ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(opts.Title)+"%")
+	}
+	if opts.Content != "" {
+		where = append(where, `lower(content) LIKE lower(?) ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(opts.Content)+"%")
+	}
+	if opts.NotebookId != 0 && opts.Recursive {
+		where = append(where, "notebook_id IN (SELECT id FROM notebooks WHERE path LIKE (SELECT path FROM notebooks WHERE id = ?) || '%')")
+		args = append(args, opts.NotebookId)
+	} else if opts.NotebookId != 0 {
+		where = append(where, "notebook_id = ?")
+		args = append(args, opts.NotebookId)
+	}
+	if len(opts.Tags) > 0 {
+		cond, tagArgs := tagFilter(opts.Tags, opts.TagMode)
+		where = append(where, cond)
+		args = append(args, tagArgs...)
+	}
+	timeFilters := []struct {
+		cond string
+		t    time.Time
+	}{
+		{"created_at >= ?", opts.CreatedAfter},
+		{"created_at <= ?", opts.CreatedBefore},
+		{"updated_at >= ?", opts.UpdatedAfter},
+		{"updated_at <= ?", opts.UpdatedBefore},
+	}
+	for _, f := range timeFilters {
+		if !f.t.IsZero() {
+			where = append(where, f.cond)
+			args = append(args, formatTime(f.t))
+		}
+	}
+
+	cmp, dir := ">", "ASC"
+	if opts.Desc {
+		cmp, dir = "<", "DESC"
+	}
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor, opts.Sort, opts.Desc)
+		if err != nil {
+			return nil, &RepoError{Src: "GetAllNotes", Err: err}
+		}
+		if column == "id" {
+			where = append(where, "id "+cmp+" ?")
+			args = append(args, c.Id)
+		} else {
+			where = append(where, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp))
+			args = append(args, c.Value, c.Value, c.Id)
+		}
+	}
+
+	query := "SELECT " + noteColumns + " FROM notes WHERE " + strings.Join(where, " AND ")
+	if column == "id" {
+		query += " ORDER BY id " + dir
+	} else {
+		query += fmt.Sprintf(" ORDER BY %s %s, id %s", column, dir, dir)
+	}
+	query += " LIMIT ?"
+	// Fetch one extra row to find out whether there is another page.
+	args = append(args, opts.Limit+1)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, &RepoError{Src: "GetAllNotes", Err: fmt.Errorf("DB Error: %w", err)}
 	}
@@ -63,43 +252,286 @@ func (r *noteRepository) GetAll() ([]*models.Note, error) {
 
 	notes := []*models.Note{}
 	for rows.Next() {
-		note := &models.Note{}
-		err := rows.Scan(&note.Id, &note.Title, &note.Content)
+		note, err := scanNote(rows)
 		if err != nil {
 			return nil, &RepoError{Src: "GetAllNotes", Err: fmt.Errorf("Error Scanning: %w", err)}
 		}
 		notes = append(notes, note)
 	}
-	return notes, nil
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetAllNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+
+	page := &models.NotePage{Notes: notes}
+	if len(notes) > opts.Limit {
+		page.Notes = notes[:opts.Limit]
+		page.HasMore = true
+		last := page.Notes[len(page.Notes)-1]
+		page.NextCursor = encodeCursor(cursor{Sort: opts.Sort, Desc: opts.Desc, Value: sortValue(last, opts.Sort), Id: last.Id})
+	}
+	return page, nil
 }
 
-// Create adds a new note to the database.
-func (r *noteRepository) Create(note *models.Note) (int, error) {
-	res, err := r.db.Exec("INSERT INTO notes (title, content) VALUES (?, ?)", note.Title, note.Content)
+// Create adds a new note created by a user to a workspace along with its
+// tags, first revision and deliveries to the webhooks of the workspace. It sets the timestamps and version of note to the
+// values it was stored with.
+// It returns ErrNotebookNotFound if the note is filed in a notebook that
+// does not exist or belongs to another workspace.
+func (r *noteRepository) Create(ctx context.Context, workspaceId int, userId int, note *models.Note) (int, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	tx, err := beginTx(ctx, r.db)
 	if err != nil {
 		return 0, &RepoError{Src: "CreateNote", Err: fmt.Errorf("DB Error: %w", err)}
 	}
-	id, err := res.LastInsertId()
+	defer tx.Rollback()
+
+	if note.NotebookId != nil {
+		if err := checkNotebook(tx.Tx, workspaceId, *note.NotebookId); err != nil {
+			return 0, &RepoError{Src: "CreateNote", Err: err}
+		}
+	}
+	var id int64
+	err = tx.QueryRowContext(ctx, "INSERT INTO notes (workspace_id, user_id, title, content, created_at, updated_at, version, notebook_id) VALUES (?, ?, ?, ?, ?, ?, 1, ?) RETURNING id",
+		workspaceId, userId, note.Title, note.Content, formatTime(now), formatTime(now), note.NotebookId).Scan(&id)
 	if err != nil {
-		return 0, &RepoError{Src: "CreateNote", Err: fmt.Errorf("Error getting last Id: %w", err)}
+		return 0, &RepoError{Src: "CreateNote", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if len(note.Tags) > 0 {
+		if err := setTags(ctx, tx, workspaceId, int(id), note.Tags); err != nil {
+			return 0, &RepoError{"CreateNote", int(id), fmt.Errorf("DB Error: %w", err)}
+		}
 	}
+	if err := r.addRevision(ctx, tx, int(id), 1, note, now); err != nil {
+		return 0, &RepoError{"CreateNote", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := enqueueEvent(ctx, tx, workspaceId, models.EventNoteCreated, int(id), now); err != nil {
+		return 0, &RepoError{"CreateNote", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, &RepoError{"CreateNote", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	note.CreatedAt, note.UpdatedAt, note.Version = now, now, 1
 	return int(id), nil
 }
 
-// Update modifies an existing note in the database.
-func (r *noteRepository) Update(id int, note *models.Note) error {
-	_, err := r.db.Exec("UPDATE notes SET title = ?, content = ? WHERE id = ?", note.Title, note.Content, id)
+// Update modifies an existing note of a workspace in the database, bumping its
+// update time and version, and records the new revision and webhook
+// deliveries. The tags of the note are replaced unless note.Tags is nil. It
+// sets the metadata of note to the stored values. If version is not 0, the
+// note is only updated if it is still at that version.
+// It returns ErrNoteNotFound if the note is not found and ErrVersionConflict
+// if the note is at a different version.
+func (r *noteRepository) Update(ctx context.Context, workspaceId int, id int, note *models.Note, version int) error {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return &RepoError{"UpdateNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `UPDATE notes SET title = ?, content = ?, updated_at = ?, version = version + 1
+    WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)
+    RETURNING created_at, updated_at, version`,
+		note.Title, note.Content, formatTime(now), id, workspaceId, version, version)
+	var createdAt, updatedAt string
+	var newVersion int
+	err = row.Scan(&createdAt, &updatedAt, &newVersion)
+	if err == sql.ErrNoRows {
+		tx.Rollback()
+		return r.checkVersion(ctx, "UpdateNoteByID", workspaceId, id, version)
+	}
 	if err != nil {
 		return &RepoError{"UpdateNoteByID", id, fmt.Errorf("DB Error: %w", err)}
 	}
+	if note.Tags != nil {
+		if err := setTags(ctx, tx, workspaceId, id, note.Tags); err != nil {
+			return &RepoError{"UpdateNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+		}
+	}
+	if err := r.addRevision(ctx, tx, id, newVersion, note, now); err != nil {
+		return &RepoError{"UpdateNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := enqueueEvent(ctx, tx, workspaceId, models.EventNoteUpdated, id, now); err != nil {
+		return &RepoError{"UpdateNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"UpdateNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	note.Id = id
+	note.Version = newVersion
+	note.CreatedAt, _ = time.Parse(TimeFormat, createdAt)
+	note.UpdatedAt, _ = time.Parse(TimeFormat, updatedAt)
 	return nil
 }
 
-// Delete removes a note from the database.
-func (r *noteRepository) Delete(id int) error {
-	_, err := r.db.Exec("DELETE FROM notes WHERE id = ?", id)
+// Delete moves a note of a workspace to the trash and queues its webhook
+// deliveries. If version is not 0, the note is only deleted if it is still
+// at that version.
+// It returns ErrNoteNotFound if the note is not found or already in the trash
+// and ErrVersionConflict if the note is at a different version.
+func (r *noteRepository) Delete(ctx context.Context, workspaceId int, id int, version int) error {
+	now := r.now()
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return &RepoError{"DeleteNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE notes SET deleted_at = ?, version = version + 1
+    WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`, formatTime(now), id, workspaceId, version, version)
 	if err != nil {
 		return &RepoError{"DeleteNoteByID", id, fmt.Errorf("DB Error: %w", err)}
 	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"DeleteNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		tx.Rollback()
+		return r.checkVersion(ctx, "DeleteNoteByID", workspaceId, id, version)
+	}
+	if err := enqueueEvent(ctx, tx, workspaceId, models.EventNoteDeleted, id, now); err != nil {
+		return &RepoError{"DeleteNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"DeleteNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return nil
+}
+
+// MoveNote files a note of a workspace in another of its notebooks, or in none
+// if notebookId is nil, bumping its update time and version, and queues its
+// webhook deliveries as an update. If version is not 0, the note is only
+// moved if it is still at that version.
+// It returns ErrNoteNotFound if the note is not found, ErrNotebookNotFound if
+// the notebook does not exist and ErrVersionConflict if the note is at a
+// different version.
+func (r *noteRepository) MoveNote(ctx context.Context, workspaceId int, id int, notebookId *int, version int) error {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return &RepoError{"MoveNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	if notebookId != nil {
+		if err := checkNotebook(tx.Tx, workspaceId, *notebookId); err != nil {
+			return &RepoError{"MoveNoteByID", id, err}
+		}
+	}
+	now := r.now()
+	res, err := tx.ExecContext(ctx, `UPDATE notes SET notebook_id = ?, updated_at = ?, version = version + 1
+    WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL AND (? = 0 OR version = ?)`, notebookId, formatTime(now), id, workspaceId, version, version)
+	if err != nil {
+		return &RepoError{"MoveNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"MoveNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		tx.Rollback()
+		return r.checkVersion(ctx, "MoveNoteByID", workspaceId, id, version)
+	}
+	if err := enqueueEvent(ctx, tx, workspaceId, models.EventNoteUpdated, id, now); err != nil {
+		return &RepoError{"MoveNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"MoveNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
 	return nil
 }
+
+// checkVersion is called after a write to a note matched no rows to find
+// out why. It returns ErrNoteNotFound if the note does not exist and
+// ErrVersionConflict if it does, as it must then be at a version other than
+// the expected one.
+func (r *noteRepository) checkVersion(ctx context.Context, src string, workspaceId int, id int, version int) error {
+	if version == 0 {
+		return &RepoError{src, id, ErrNoteNotFound}
+	}
+	var current int
+	err := r.conn(ctx).QueryRowContext(ctx, "SELECT version FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL", id, workspaceId).Scan(&current)
+	if err == sql.ErrNoRows {
+		return &RepoError{src, id, ErrNoteNotFound}
+	}
+	if err != nil {
+		return &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return &RepoError{src, id, fmt.Errorf("%w: expected version %d, found %d", ErrVersionConflict, version, current)}
+}
+
+// Search finds the notes of a workspace matching a full-text query, best matches
+// first. The
+// query uses FTS5 syntax, so it supports "phrase queries", prefix* matching
+// and the AND, OR and NOT operators. Title matches rank higher than content
+// matches.
+// It returns ErrInvalidSearchQuery if the query cannot be parsed.
+func (r *noteRepository) Search(ctx context.Context, workspaceId int, opts models.SearchOptions) ([]*models.SearchResult, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT notes.id, notes.title, notes.content,
+        notes.created_at, notes.updated_at, notes.version, notes.deleted_at, notes.notebook_id,
+        `+tagsColumn+`,
+        -bm25(notes_fts, 10.0, 1.0),
+        highlight(notes_fts, 0, '`+markStart+`', '`+markEnd+`'),
+        snippet(notes_fts, 1, '`+markStart+`', '`+markEnd+`', '…', 16)
+    FROM notes_fts JOIN notes ON notes.id = notes_fts.rowid
+    WHERE notes_fts MATCH ? AND notes.workspace_id = ? AND notes.deleted_at IS NULL
+    ORDER BY bm25(notes_fts, 10.0, 1.0), notes.id
+    LIMIT ? OFFSET ?`, opts.Query, workspaceId, opts.Limit, opts.Offset)
+	if err != nil {
+		if isQuerySyntaxError(err) {
+			return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("%w: %v", ErrInvalidSearchQuery, err)}
+		}
+		return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	results := []*models.SearchResult{}
+	for rows.Next() {
+		res := &models.SearchResult{}
+		note, err := scanNote(rows, &res.Score, &res.Highlight, &res.Snippet)
+		if err != nil {
+			return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("Error Scanning: %w", err)}
+		}
+		res.Note = *note
+		res.Highlight, res.Snippet = escapeHighlight(res.Highlight), escapeHighlight(res.Snippet)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		if isQuerySyntaxError(err) {
+			return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("%w: %v", ErrInvalidSearchQuery, err)}
+		}
+		return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return results, nil
+}
+
+// isQuerySyntaxError reports whether err was caused by a malformed FTS5
+// query rather than a problem with the database.
+func isQuerySyntaxError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "fts5:") || strings.HasPrefix(msg, "no such column") ||
+		strings.Contains(msg, "unterminated string")
+}
+
+// markStart and markEnd enclose the matched terms in the highlights and
+// snippets built by the database, which escapeHighlight turns into <mark>
+// tags.
+const (
+	markStart = "\x02"
+	markEnd   = "\x03"
+)
+
+// escapeHighlight HTML-escapes a highlight or snippet enclosing matched terms
+// in markStart and markEnd and wraps them in <mark> tags instead, so that
+// clients can render it as HTML without running markup stored in notes.
+func escapeHighlight(s string) string {
+	return markReplacer.Replace(html.EscapeString(s))
+}
+
+// markReplacer replaces markStart and markEnd with <mark> tags.
+var markReplacer = strings.NewReplacer(markStart, "<mark>", markEnd, "</mark>")
+
+// markStripper replaces markStart and markEnd in the text of a note with
+// spaces before it is highlighted, so that only the marks of matched terms
+// turn into <mark> tags. Both separate terms like spaces do.
+var markStripper = strings.NewReplacer(markStart, " ", markEnd, " ")
@@ -1,13 +1,57 @@
 package repository
 
 import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/JannisK89/notes-api/internal/models"
 	"github.com/stretchr/testify/assert"
 )
 
+var (
+	created = time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	updated = time.Date(2024, 5, 2, 17, 45, 30, 250e6, time.UTC)
+)
+
+// testUserId is the user who creates the notes in the tests and
+// testWorkspaceId the workspace they belong to.
+const (
+	testUserId      = 7
+	testWorkspaceId = 3
+)
+
+// noteRows returns the rows a query selecting noteColumns yields for notes.
+func noteRows(notes ...*models.Note) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at", "version", "deleted_at", "notebook_id", "tags"})
+	for _, note := range notes {
+		var deletedAt interface{}
+		if note.DeletedAt != nil {
+			deletedAt = formatTime(*note.DeletedAt)
+		}
+		var tags interface{}
+		if len(note.Tags) > 0 {
+			tags = strings.Join(note.Tags, tagSeparator)
+		}
+		var notebookId interface{}
+		if note.NotebookId != nil {
+			notebookId = *note.NotebookId
+		}
+		rows.AddRow(note.Id, note.Title, note.Content, formatTime(note.CreatedAt), formatTime(note.UpdatedAt), note.Version, deletedAt, notebookId, tags)
+	}
+	return rows
+}
+
+// expectNoWebhooks expects the lookup of the webhooks of the workspace with
+// testWorkspaceId that subscribed to typ, which finds none.
+func expectNoWebhooks(mock sqlmock.Sqlmock, typ models.EventType) {
+	mock.ExpectQuery("SELECT webhooks.id FROM webhooks").WithArgs(testWorkspaceId, typ).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+}
+
 func TestNoteRepository_CreateNote(t *testing.T) {
 	// Arrange
 	db, mock, err := sqlmock.New()
@@ -27,20 +71,30 @@ func TestNoteRepository_CreateNote(t *testing.T) {
 	}
 
 	repo := NewNotesRepository(db)
-
-	mock.ExpectExec("INSERT INTO notes").WithArgs(firstNote.Title, firstNote.Content).WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectExec("INSERT INTO notes").WithArgs(secondNote.Title, secondNote.Content).WillReturnResult(sqlmock.NewResult(2, 1))
+	repo.now = func() time.Time { return created }
+
+	for id, note := range []*models.Note{firstNote, secondNote} {
+		mock.ExpectBegin()
+		mock.ExpectQuery("INSERT INTO notes").WithArgs(testWorkspaceId, testUserId, note.Title, note.Content, "2024-05-01T09:30:00.000Z", "2024-05-01T09:30:00.000Z", nil).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(id + 1))
+		mock.ExpectExec("INSERT INTO note_revisions").WithArgs(id+1, 1, note.Title, note.Content, "2024-05-01T09:30:00.000Z").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM note_revisions").WithArgs(id+1, id+1, DefaultMaxRevisions).WillReturnResult(sqlmock.NewResult(0, 0))
+		expectNoWebhooks(mock, models.EventNoteCreated)
+		mock.ExpectCommit()
+	}
 
 	// Act
-	firstId, firstErr := repo.Create(firstNote)
-	secondId, secondErr := repo.Create(secondNote)
+	firstId, firstErr := repo.Create(context.Background(), testWorkspaceId, testUserId, firstNote)
+	secondId, secondErr := repo.Create(context.Background(), testWorkspaceId, testUserId, secondNote)
 
 	// Assert
 	assert.NoError(t, firstErr)
 	assert.NoError(t, secondErr)
 	assert.Equal(t, 1, firstId)
 	assert.Equal(t, 2, secondId)
-
+	assert.Equal(t, created, firstNote.CreatedAt)
+	assert.Equal(t, created, firstNote.UpdatedAt)
+	assert.Equal(t, 1, firstNote.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestNoteRepository_GetNoteById(t *testing.T) {
@@ -52,29 +106,32 @@ func TestNoteRepository_GetNoteById(t *testing.T) {
 	defer db.Close()
 	notes := [2]*models.Note{
 		&models.Note{
-			Id:      1,
-			Title:   "First Note",
-			Content: "This is the first note",
+			Id:        1,
+			Title:     "First Note",
+			Content:   "This is the first note",
+			CreatedAt: created,
+			UpdatedAt: updated,
+			Version:   2,
 		},
 		&models.Note{
-			Id:      2,
-			Title:   "Second Note",
-			Content: "This is the second note",
+			Id:        2,
+			Title:     "Second Note",
+			Content:   "This is the second note",
+			CreatedAt: created,
+			UpdatedAt: created,
+			Version:   1,
 		}}
 
 	repo := NewNotesRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "title", "content"})
-	for _, note := range notes {
-		rows.AddRow(note.Id, note.Title, note.Content)
-	}
+	rows := noteRows(notes[:]...)
 
-	mock.ExpectQuery("SELECT id, title, content FROM notes WHERE id = ?").WithArgs(notes[0].Id).WillReturnRows(rows)
-	mock.ExpectQuery("SELECT id, title, content FROM notes WHERE id = ?").WithArgs(notes[1].Id).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+" FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL")).WithArgs(notes[0].Id, testWorkspaceId).WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+" FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL")).WithArgs(notes[1].Id, testWorkspaceId).WillReturnRows(rows)
 
 	// Act
-	firstResult, firstErr := repo.Get(notes[0].Id)
-	secondResult, secondErr := repo.Get(notes[1].Id)
+	firstResult, firstErr := repo.Get(context.Background(), testWorkspaceId, notes[0].Id)
+	secondResult, secondErr := repo.Get(context.Background(), testWorkspaceId, notes[1].Id)
 
 	// Assert
 	assert.NoError(t, firstErr)
@@ -93,40 +150,104 @@ func TestNoteRepository_GetAllNotes(t *testing.T) {
 	defer db.Close()
 	notes := [3]*models.Note{
 		&models.Note{
-			Id:      1,
-			Title:   "First Note",
-			Content: "This is the first note",
+			Id:        1,
+			Title:     "First Note",
+			Content:   "This is the first note",
+			CreatedAt: created,
+			UpdatedAt: updated,
+			Version:   2,
 		},
 		&models.Note{
-			Id:      2,
-			Title:   "Second Note",
-			Content: "This is the second note",
+			Id:        2,
+			Title:     "Second Note",
+			Content:   "This is the second note",
+			CreatedAt: created,
+			UpdatedAt: created,
+			Version:   1,
 		},
 		&models.Note{
-			Id:      3,
-			Title:   "Third Note",
-			Content: "This is the third note",
+			Id:        3,
+			Title:     "Third Note",
+			Content:   "This is the third note",
+			CreatedAt: updated,
+			UpdatedAt: updated,
+			Version:   1,
 		},
 	}
 
 	repo := NewNotesRepository(db)
 
-	rows := sqlmock.NewRows([]string{"id", "title", "content"})
-	for _, note := range notes {
-		rows.AddRow(note.Id, note.Title, note.Content)
-	}
+	rows := noteRows(notes[:]...)
 
-	mock.ExpectQuery("SELECT id, title, content FROM notes").WillReturnRows(rows)
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+" FROM notes WHERE workspace_id = ? AND deleted_at IS NULL ORDER BY id ASC LIMIT ?")).WithArgs(testWorkspaceId, 4).WillReturnRows(rows)
 
 	// Act
-	res, err := repo.GetAll()
+	res, err := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 3, Sort: models.SortById})
 
 	// Assert
 	assert.NoError(t, err)
-	assert.Equal(t, len(res), 3)
+	assert.Equal(t, len(res.Notes), 3)
+	assert.False(t, res.HasMore)
+	assert.Empty(t, res.NextCursor)
 	for i := range notes {
-		assert.Equal(t, res[i], notes[i])
+		assert.Equal(t, res.Notes[i], notes[i])
+	}
+}
+
+func TestNoteRepository_GetAllNotesPaginated(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
 	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	opts := models.ListOptions{Limit: 2, Sort: models.SortByTitle, Desc: true, Title: "50%"}
+
+	noteC := &models.Note{Id: 3, Title: "C 50%", Content: "Third", CreatedAt: created, UpdatedAt: created, Version: 1}
+	noteB := &models.Note{Id: 2, Title: "B 50%", Content: "Second", CreatedAt: created, UpdatedAt: created, Version: 1}
+	noteA := &models.Note{Id: 1, Title: "A 50%", Content: "First", CreatedAt: created, UpdatedAt: created, Version: 1}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+` FROM notes WHERE workspace_id = ? AND deleted_at IS NULL AND lower(title) LIKE lower(?) ESCAPE '\' ORDER BY title DESC, id DESC LIMIT ?`)).
+		WithArgs(testWorkspaceId, `%50\%%`, 3).WillReturnRows(noteRows(noteC, noteB, noteA))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+` FROM notes WHERE workspace_id = ? AND deleted_at IS NULL AND lower(title) LIKE lower(?) ESCAPE '\' AND (title < ? OR (title = ? AND id < ?)) ORDER BY title DESC, id DESC LIMIT ?`)).
+		WithArgs(testWorkspaceId, `%50\%%`, "B 50%", "B 50%", 2, 3).WillReturnRows(noteRows(noteA))
+
+	// Act
+	first, firstErr := repo.GetAll(context.Background(), testWorkspaceId, opts)
+	opts.Cursor = first.NextCursor
+	second, secondErr := repo.GetAll(context.Background(), testWorkspaceId, opts)
+
+	// Assert
+	assert.NoError(t, firstErr)
+	assert.Len(t, first.Notes, 2)
+	assert.True(t, first.HasMore)
+	assert.NotEmpty(t, first.NextCursor)
+	assert.NoError(t, secondErr)
+	assert.Len(t, second.Notes, 1)
+	assert.False(t, second.HasMore)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetAllNotesInvalidCursor(t *testing.T) {
+	// Arrange
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	titleCursor := encodeCursor(cursor{Sort: models.SortByTitle, Value: "A", Id: 1})
+
+	// Act
+	_, garbageErr := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 2, Sort: models.SortById, Cursor: "not a cursor"})
+	_, mismatchErr := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 2, Sort: models.SortById, Cursor: titleCursor})
+
+	// Assert
+	assert.ErrorIs(t, garbageErr, ErrInvalidCursor)
+	assert.ErrorIs(t, mismatchErr, ErrInvalidCursor)
 }
 
 func TestNoteRepository_UpdateNoteById(t *testing.T) {
@@ -145,13 +266,53 @@ func TestNoteRepository_UpdateNoteById(t *testing.T) {
 
 	repo := NewNotesRepository(db)
 
-	mock.ExpectExec("UPDATE notes").WithArgs(note.Title, note.Content, note.Id).WillReturnResult(sqlmock.NewResult(1, 1))
+	repo.now = func() time.Time { return updated }
+	repo.MaxRevisions = 10
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE notes SET title = \\?, content = \\?, updated_at = \\?, version = version \\+ 1").
+		WithArgs(note.Title, note.Content, "2024-05-02T17:45:30.250Z", note.Id, testWorkspaceId, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow("2024-05-01T09:30:00.000Z", "2024-05-02T17:45:30.250Z", 2))
+	mock.ExpectExec("INSERT INTO note_revisions").WithArgs(note.Id, 2, note.Title, note.Content, "2024-05-02T17:45:30.250Z").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM note_revisions").WithArgs(note.Id, note.Id, 10).WillReturnResult(sqlmock.NewResult(0, 0))
+	expectNoWebhooks(mock, models.EventNoteUpdated)
+	mock.ExpectCommit()
 
 	// Act
-	err = repo.Update(note.Id, note)
+	err = repo.Update(context.Background(), testWorkspaceId, note.Id, note, 0)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.Equal(t, created, note.CreatedAt)
+	assert.Equal(t, updated, note.UpdatedAt)
+	assert.Equal(t, 2, note.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_UpdateNoteByIdVersionConflict(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	note := &models.Note{Title: "First Note", Content: "This is the first note"}
+	repo := NewNotesRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE notes").WithArgs(note.Title, note.Content, sqlmock.AnyArg(), 1, testWorkspaceId, 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}))
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT version FROM notes WHERE id = ?").WithArgs(1, testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+
+	// Act
+	err = repo.Update(context.Background(), testWorkspaceId, 1, note, 2)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestNoteRepository_DeleteNoteById(t *testing.T) {
@@ -169,12 +330,228 @@ func TestNoteRepository_DeleteNoteById(t *testing.T) {
 	}
 
 	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
 
-	mock.ExpectExec("DELETE FROM notes").WithArgs(note.Id).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET deleted_at = ?, version = version + 1")).
+		WithArgs("2024-05-02T17:45:30.250Z", note.Id, testWorkspaceId, 0, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoWebhooks(mock, models.EventNoteDeleted)
+	mock.ExpectCommit()
 
 	// Act
-	err = repo.Delete(note.Id)
+	err = repo.Delete(context.Background(), testWorkspaceId, note.Id, 0)
 
 	// Assert
 	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_DeleteNoteByIdVersionConflict(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE notes SET deleted_at").WithArgs(sqlmock.AnyArg(), 1, testWorkspaceId, 2, 2).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT version FROM notes WHERE id = ?").WithArgs(1, testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(5))
+
+	// Act
+	err = repo.Delete(context.Background(), testWorkspaceId, 1, 2)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_SearchNotes(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	opts := models.SearchOptions{Query: `"first note" OR sec*`, Limit: 10}
+
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at", "version", "deleted_at", "notebook_id", "tags", "score", "highlight", "snippet"}).
+		AddRow(1, "First Note", "This is the first note", "2024-05-01T09:30:00.000Z", "2024-05-01T09:30:00.000Z", 1, nil, nil, nil, 2.5, "\x02First Note\x03", "This is the \x02first note\x03").
+		AddRow(2, "Second Note", "This is the second note", "2024-05-01T09:30:00.000Z", "2024-05-02T17:45:30.250Z", 3, nil, 4, "notes\x1fsearch", 1.5, "\x02Second\x03 Note", "This is the \x02second\x03 note")
+
+	mock.ExpectQuery("FROM notes_fts JOIN notes").WithArgs(opts.Query, testWorkspaceId, 10, 0).WillReturnRows(rows)
+
+	// Act
+	res, err := repo.Search(context.Background(), testWorkspaceId, opts)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, 1, res[0].Id)
+	assert.Equal(t, 2.5, res[0].Score)
+	assert.Equal(t, "This is the <mark>first note</mark>", res[0].Snippet)
+	assert.Equal(t, "<mark>Second</mark> Note", res[1].Highlight)
+	assert.Equal(t, updated, res[1].UpdatedAt)
+	assert.Equal(t, 3, res[1].Version)
+	assert.Equal(t, []string{"notes", "search"}, res[1].Tags)
+	assert.Nil(t, res[0].NotebookId)
+	assert.Equal(t, 4, *res[1].NotebookId)
+}
+
+func TestNoteRepository_SearchNotesInvalidQuery(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectQuery("FROM notes_fts JOIN notes").WillReturnError(errors.New(`fts5: syntax error near "AND"`))
+
+	// Act
+	_, err = repo.Search(context.Background(), testWorkspaceId, models.SearchOptions{Query: "AND", Limit: 10})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidSearchQuery)
+}
+
+func TestNoteRepository_GetAllNotesByTime(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	opts := models.ListOptions{
+		Limit:         1,
+		Sort:          models.SortByUpdatedAt,
+		CreatedAfter:  created,
+		UpdatedBefore: updated.Add(time.Hour),
+	}
+	newer := &models.Note{Id: 5, Title: "Newer", Content: "Newer", CreatedAt: created, UpdatedAt: updated, Version: 4}
+	older := &models.Note{Id: 4, Title: "Older", Content: "Older", CreatedAt: created, UpdatedAt: created, Version: 1}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+` FROM notes WHERE workspace_id = ? AND deleted_at IS NULL AND created_at >= ? AND updated_at <= ? AND (updated_at > ? OR (updated_at = ? AND id > ?)) ORDER BY updated_at ASC, id ASC LIMIT ?`)).
+		WithArgs(testWorkspaceId, "2024-05-01T09:30:00.000Z", "2024-05-02T18:45:30.250Z", "2024-05-01T09:30:00.000Z", "2024-05-01T09:30:00.000Z", 4, 2).
+		WillReturnRows(noteRows(newer))
+
+	// Act
+	opts.Cursor = encodeCursor(cursor{Sort: models.SortByUpdatedAt, Value: sortValue(older, models.SortByUpdatedAt), Id: older.Id})
+	res, err := repo.GetAll(context.Background(), testWorkspaceId, opts)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.Note{newer}, res.Notes)
+	assert.False(t, res.HasMore)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_UpdateNoteByIdNotFound(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	note := &models.Note{Title: "First Note", Content: "This is the first note"}
+	repo := NewNotesRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE notes").WithArgs(note.Title, note.Content, sqlmock.AnyArg(), 1, testWorkspaceId, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE notes").WithArgs(note.Title, note.Content, sqlmock.AnyArg(), 1, testWorkspaceId, 2, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}))
+	mock.ExpectRollback()
+	mock.ExpectQuery("SELECT version FROM notes WHERE id = ?").WithArgs(1, testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}))
+
+	// Act
+	unconditionalErr := repo.Update(context.Background(), testWorkspaceId, 1, note, 0)
+	conditionalErr := repo.Update(context.Background(), testWorkspaceId, 1, note, 2)
+
+	// Assert
+	assert.ErrorIs(t, unconditionalErr, ErrNoteNotFound)
+	assert.ErrorIs(t, conditionalErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_DeleteNoteByIdNotFound(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE notes SET deleted_at").WithArgs(sqlmock.AnyArg(), 1, testWorkspaceId, 0, 0).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// Act
+	err = repo.Delete(context.Background(), testWorkspaceId, 1, 0)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetNoteByIdDeadline(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+" FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL")).WithArgs(1, testWorkspaceId).
+		WillDelayFor(time.Minute).WillReturnRows(noteRows(&models.Note{Id: 1, Title: "First Note", Content: "This is the first note", CreatedAt: created, UpdatedAt: created, Version: 1}))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Act
+	start := time.Now()
+	note, err := repo.Get(ctx, testWorkspaceId, 1)
+
+	// Assert
+	assert.Nil(t, note)
+	assert.ErrorIs(t, err, sqlmock.ErrCancelled)
+	assert.Less(t, time.Since(start), time.Minute/2)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_UpdateNoteByIdCanceled(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	err = repo.Update(ctx, testWorkspaceId, 1, &models.Note{Title: "First Note", Content: "This is the first note"}, 0)
+
+	// Assert
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
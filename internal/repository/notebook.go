@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+var (
+	// ErrNotebookNotFound is returned when a notebook with the given ID is not
+	// found in the database.
+	ErrNotebookNotFound = errors.New("notebook not found")
+	// ErrNotebookExists is returned when a notebook would get the same name
+	// as another notebook with the same parent.
+	ErrNotebookExists = errors.New("notebook already exists")
+	// ErrNotebookNotEmpty is returned when a notebook that still contains
+	// notes or notebooks is deleted without saying what happens to them.
+	ErrNotebookNotEmpty = errors.New("notebook not empty")
+	// ErrNotebookCycle is returned when a notebook is moved into itself or
+	// one of the notebooks below it.
+	ErrNotebookCycle = errors.New("notebook cannot be moved below itself")
+)
+
+// notebookColumns are the columns scanned by scanNotebook, in order.
+const notebookColumns = "id, name, parent_id, path, created_at, updated_at"
+
+// scanNotebook reads a notebook selected with notebookColumns from row,
+// followed by any extra columns into dest.
+func scanNotebook(row scanner, dest ...interface{}) (*models.Notebook, error) {
+	notebook := &models.Notebook{}
+	var parentId sql.NullInt64
+	var createdAt, updatedAt string
+	err := row.Scan(append([]interface{}{&notebook.Id, &notebook.Name, &parentId, &notebook.Path, &createdAt, &updatedAt}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	if parentId.Valid {
+		id := int(parentId.Int64)
+		notebook.ParentId = &id
+	}
+	if notebook.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	if notebook.UpdatedAt, err = time.Parse(TimeFormat, updatedAt); err != nil {
+		return nil, err
+	}
+	return notebook, nil
+}
+
+// notebookPath returns the path of notebook id below the notebook with the
+// path parentPath, which is "" for a top-level notebook.
+func notebookPath(parentPath string, id int) string {
+	if parentPath == "" {
+		parentPath = "/"
+	}
+	return parentPath + strconv.Itoa(id) + "/"
+}
+
+// checkNotebook returns ErrNotebookNotFound if the workspace has no notebook
+// with the given ID.
+func checkNotebook(tx *sql.Tx, workspaceId int, id int) error {
+	_, err := getNotebook(tx, workspaceId, id)
+	return err
+}
+
+// getNotebook retrieves a notebook of a workspace within tx. It returns
+// ErrNotebookNotFound if the notebook is not found.
+func getNotebook(tx *sql.Tx, workspaceId int, id int) (*models.Notebook, error) {
+	notebook, err := scanNotebook(tx.QueryRow("SELECT "+notebookColumns+" FROM notebooks WHERE id = ? AND workspace_id = ?", id, workspaceId))
+	if err == sql.ErrNoRows {
+		return nil, ErrNotebookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("DB Error: %w", err)
+	}
+	return notebook, nil
+}
+
+// checkSiblingName returns ErrNotebookExists if a notebook of the workspace
+// other than id below parentId already has the given name.
+func checkSiblingName(tx *sql.Tx, workspaceId int, id int, parentId *int, name string) error {
+	var n int
+	err := tx.QueryRow("SELECT count(*) FROM notebooks WHERE workspace_id = ? AND parent_id IS NOT DISTINCT FROM ? AND name = ? AND id != ?",
+		workspaceId, parentId, name, id).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("DB Error: %w", err)
+	}
+	if n > 0 {
+		return ErrNotebookExists
+	}
+	return nil
+}
+
+// notebookRepository implements the NotebookRepository interface.
+type notebookRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewNotebooksRepository creates a new notebookRepository.
+func NewNotebooksRepository(db *sql.DB) *notebookRepository {
+	return &notebookRepository{db: db, now: time.Now}
+}
+
+// Get retrieves a notebook of a workspace by its ID from the database.
+// It returns ErrNotebookNotFound if the notebook is not found.
+func (r *notebookRepository) Get(workspaceId int, id int) (*models.Notebook, error) {
+	row := r.db.QueryRow("SELECT "+notebookColumns+" FROM notebooks WHERE id = ? AND workspace_id = ?", id, workspaceId)
+	notebook, err := scanNotebook(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &RepoError{"GetNotebookByID", id, ErrNotebookNotFound}
+		}
+		return nil, &RepoError{"GetNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return notebook, nil
+}
+
+// GetAll retrieves all notebooks of a workspace from the database, ordered so
+// that every notebook comes after its parent.
+func (r *notebookRepository) GetAll(workspaceId int) ([]*models.Notebook, error) {
+	rows, err := r.db.Query("SELECT "+notebookColumns+" FROM notebooks WHERE workspace_id = ? ORDER BY path", workspaceId)
+	if err != nil {
+		return nil, &RepoError{Src: "GetAllNotebooks", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	notebooks := []*models.Notebook{}
+	for rows.Next() {
+		notebook, err := scanNotebook(rows)
+		if err != nil {
+			return nil, &RepoError{Src: "GetAllNotebooks", Err: fmt.Errorf("DB Error: %w", err)}
+		}
+		notebooks = append(notebooks, notebook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetAllNotebooks", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return notebooks, nil
+}
+
+// Create adds a new notebook created by a user to a workspace and sets the
+// metadata of notebook to the values it was stored with.
+// It returns ErrNotebookNotFound if the parent does not exist and
+// ErrNotebookExists if the parent already has a notebook with that name.
+func (r *notebookRepository) Create(workspaceId int, userId int, notebook *models.Notebook) (int, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, &RepoError{Src: "CreateNotebook", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	parentPath := ""
+	if notebook.ParentId != nil {
+		parent, err := getNotebook(tx, workspaceId, *notebook.ParentId)
+		if err != nil {
+			return 0, &RepoError{Src: "CreateNotebook", Err: err}
+		}
+		parentPath = parent.Path
+	}
+	if err := checkSiblingName(tx, workspaceId, 0, notebook.ParentId, notebook.Name); err != nil {
+		return 0, &RepoError{Src: "CreateNotebook", Err: err}
+	}
+	var id int64
+	err = tx.QueryRow("INSERT INTO notebooks (workspace_id, user_id, name, parent_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?) RETURNING id",
+		workspaceId, userId, notebook.Name, notebook.ParentId, formatTime(now), formatTime(now)).Scan(&id)
+	if err != nil {
+		return 0, &RepoError{Src: "CreateNotebook", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	path := notebookPath(parentPath, int(id))
+	if _, err := tx.Exec("UPDATE notebooks SET path = ? WHERE id = ?", path, id); err != nil {
+		return 0, &RepoError{"CreateNotebook", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, &RepoError{"CreateNotebook", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	notebook.Id, notebook.Path = int(id), path
+	notebook.CreatedAt, notebook.UpdatedAt = now, now
+	return int(id), nil
+}
+
+// Rename changes the name of a notebook of a workspace.
+// It returns ErrNotebookNotFound if the notebook is not found and
+// ErrNotebookExists if its parent already has a notebook with that name.
+func (r *notebookRepository) Rename(workspaceId int, id int, name string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return &RepoError{"RenameNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	notebook, err := getNotebook(tx, workspaceId, id)
+	if err != nil {
+		return &RepoError{"RenameNotebookByID", id, err}
+	}
+	if err := checkSiblingName(tx, workspaceId, id, notebook.ParentId, name); err != nil {
+		return &RepoError{"RenameNotebookByID", id, err}
+	}
+	if _, err := tx.Exec("UPDATE notebooks SET name = ?, updated_at = ? WHERE id = ?", name, formatTime(r.now()), id); err != nil {
+		return &RepoError{"RenameNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"RenameNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return nil
+}
+
+// Move moves a notebook of a workspace along with everything below it into
+// another of its notebooks, or to the top level if parentId is nil.
+// It returns ErrNotebookNotFound if either notebook is not found,
+// ErrNotebookCycle if the new parent is the notebook itself or below it and
+// ErrNotebookExists if the new parent already has a notebook with that name.
+func (r *notebookRepository) Move(workspaceId int, id int, parentId *int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return &RepoError{"MoveNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	notebook, err := getNotebook(tx, workspaceId, id)
+	if err != nil {
+		return &RepoError{"MoveNotebookByID", id, err}
+	}
+	parentPath := ""
+	if parentId != nil {
+		parent, err := getNotebook(tx, workspaceId, *parentId)
+		if err != nil {
+			return &RepoError{"MoveNotebookByID", id, err}
+		}
+		if strings.HasPrefix(parent.Path, notebook.Path) {
+			return &RepoError{"MoveNotebookByID", id, ErrNotebookCycle}
+		}
+		parentPath = parent.Path
+	}
+	if err := checkSiblingName(tx, workspaceId, id, parentId, notebook.Name); err != nil {
+		return &RepoError{"MoveNotebookByID", id, err}
+	}
+	if _, err := tx.Exec("UPDATE notebooks SET parent_id = ?, updated_at = ? WHERE id = ?", parentId, formatTime(r.now()), id); err != nil {
+		return &RepoError{"MoveNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := movePaths(tx, notebook.Path, notebookPath(parentPath, id)); err != nil {
+		return &RepoError{"MoveNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"MoveNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return nil
+}
+
+// movePaths replaces the prefix from with to in the paths of the notebooks
+// below from, including the one at from itself.
+func movePaths(tx *sql.Tx, from string, to string) error {
+	_, err := tx.Exec("UPDATE notebooks SET path = ? || substr(path, ?) WHERE path LIKE ? || '%'", to, len(from)+1, from)
+	return err
+}
+
+// Delete removes a notebook of a workspace. mode decides what happens to the notes and
+// notebooks in it:
+//   - NotebookDeleteBlock only deletes the notebook if it is empty.
+//   - NotebookDeleteTrash moves the notes in it and below it to the trash and
+//     deletes the notebooks below it as well.
+//   - NotebookDeleteMove moves its notes and notebooks to its parent.
+//
+// Notes outside the trash that are moved or trashed get their version
+// bumped. Notes already in the trash move to the parent as well with
+// NotebookDeleteMove and are left without a notebook otherwise.
+// It returns ErrNotebookNotFound if the notebook is not found,
+// ErrNotebookNotEmpty if mode is NotebookDeleteBlock and the notebook is not
+// empty and ErrNotebookExists if mode is NotebookDeleteMove and the parent
+// already has a notebook with the name of one being moved.
+func (r *notebookRepository) Delete(workspaceId int, id int, mode models.NotebookDeleteMode) error {
+	now := formatTime(r.now())
+	tx, err := r.db.Begin()
+	if err != nil {
+		return &RepoError{"DeleteNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	notebook, err := getNotebook(tx, workspaceId, id)
+	if err != nil {
+		return &RepoError{"DeleteNotebookByID", id, err}
+	}
+
+	switch mode {
+	case models.NotebookDeleteTrash:
+		subtree := "SELECT id FROM notebooks WHERE path LIKE ? || '%'"
+		_, err = tx.Exec(`UPDATE notes SET deleted_at = ?, version = version + 1
+    WHERE deleted_at IS NULL AND notebook_id IN (`+subtree+`)`, now, notebook.Path)
+		if err == nil {
+			_, err = tx.Exec("UPDATE notes SET notebook_id = NULL WHERE notebook_id IN ("+subtree+")", notebook.Path)
+		}
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM notebooks WHERE path LIKE ? || '%'", notebook.Path)
+		}
+	case models.NotebookDeleteMove:
+		var conflicts int
+		err = tx.QueryRow(`SELECT count(*) FROM notebooks AS child
+    WHERE child.parent_id = ? AND EXISTS (
+      SELECT 1 FROM notebooks AS sibling
+      WHERE sibling.workspace_id = ? AND sibling.parent_id IS NOT DISTINCT FROM ? AND sibling.id != ? AND sibling.name = child.name)`,
+			id, workspaceId, notebook.ParentId, id).Scan(&conflicts)
+		if err == nil && conflicts > 0 {
+			return &RepoError{"DeleteNotebookByID", id, ErrNotebookExists}
+		}
+		if err == nil {
+			_, err = tx.Exec("UPDATE notebooks SET parent_id = ?, updated_at = ? WHERE parent_id = ?", notebook.ParentId, now, id)
+		}
+		if err == nil {
+			err = movePaths(tx, notebook.Path, notebook.Path[:len(notebook.Path)-len(strconv.Itoa(id))-1])
+		}
+		if err == nil {
+			_, err = tx.Exec(`UPDATE notes SET notebook_id = ?, updated_at = ?, version = version + 1
+    WHERE notebook_id = ? AND deleted_at IS NULL`, notebook.ParentId, now, id)
+		}
+		if err == nil {
+			_, err = tx.Exec("UPDATE notes SET notebook_id = ? WHERE notebook_id = ?", notebook.ParentId, id)
+		}
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM notebooks WHERE id = ?", id)
+		}
+	default:
+		var n int
+		err = tx.QueryRow(`SELECT (SELECT count(*) FROM notebooks WHERE parent_id = ?) +
+    (SELECT count(*) FROM notes WHERE notebook_id = ? AND deleted_at IS NULL)`, id, id).Scan(&n)
+		if err == nil && n > 0 {
+			return &RepoError{"DeleteNotebookByID", id, ErrNotebookNotEmpty}
+		}
+		if err == nil {
+			_, err = tx.Exec("UPDATE notes SET notebook_id = NULL WHERE notebook_id = ?", id)
+		}
+		if err == nil {
+			_, err = tx.Exec("DELETE FROM notebooks WHERE id = ?", id)
+		}
+	}
+	if err != nil {
+		return &RepoError{"DeleteNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"DeleteNotebookByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// notebookRows returns the rows a query selecting notebookColumns yields for
+// notebooks.
+func notebookRows(notebooks ...*models.Notebook) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "name", "parent_id", "path", "created_at", "updated_at"})
+	for _, notebook := range notebooks {
+		var parentId interface{}
+		if notebook.ParentId != nil {
+			parentId = *notebook.ParentId
+		}
+		rows.AddRow(notebook.Id, notebook.Name, parentId, notebook.Path, formatTime(notebook.CreatedAt), formatTime(notebook.UpdatedAt))
+	}
+	return rows
+}
+
+func TestNotebookRepository_CreateNotebook(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+	repo.now = func() time.Time { return created }
+	parentId := 2
+	parent := &models.Notebook{Id: 2, Name: "Work", Path: "/1/2/", CreatedAt: created, UpdatedAt: created}
+	notebook := &models.Notebook{Name: "Projects", ParentId: &parentId}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+notebookColumns+" FROM notebooks WHERE id = ? AND workspace_id = ?")).WithArgs(2, testWorkspaceId).
+		WillReturnRows(notebookRows(parent))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM notebooks WHERE workspace_id = ? AND parent_id IS NOT DISTINCT FROM ? AND name = ?")).WithArgs(testWorkspaceId, 2, "Projects", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("INSERT INTO notebooks").WithArgs(testWorkspaceId, testUserId, "Projects", 2, "2024-05-01T09:30:00.000Z", "2024-05-01T09:30:00.000Z").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notebooks SET path = ? WHERE id = ?")).WithArgs("/1/2/5/", 5).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Act
+	id, err := repo.Create(testWorkspaceId, testUserId, notebook)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 5, id)
+	assert.Equal(t, "/1/2/5/", notebook.Path)
+	assert.Equal(t, created, notebook.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_CreateNotebookExists(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM notebooks")).WithArgs(testWorkspaceId, nil, "Work", 0).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	// Act
+	_, err = repo.Create(testWorkspaceId, testUserId, &models.Notebook{Name: "Work"})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotebookExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_GetNotebookNotFound(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+notebookColumns+" FROM notebooks WHERE id = ? AND workspace_id = ?")).WithArgs(9, testWorkspaceId).
+		WillReturnRows(notebookRows())
+
+	// Act
+	_, err = repo.Get(testWorkspaceId, 9)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotebookNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_MoveNotebook(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+	repo.now = func() time.Time { return updated }
+	parentId := 3
+	notebook := &models.Notebook{Id: 2, Name: "Work", Path: "/1/2/", CreatedAt: created, UpdatedAt: created}
+	parent := &models.Notebook{Id: 3, Name: "Archive", Path: "/3/", CreatedAt: created, UpdatedAt: created}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(2, testWorkspaceId).WillReturnRows(notebookRows(notebook))
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(3, testWorkspaceId).WillReturnRows(notebookRows(parent))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM notebooks")).WithArgs(testWorkspaceId, 3, "Work", 2).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notebooks SET parent_id = ?, updated_at = ? WHERE id = ?")).
+		WithArgs(3, "2024-05-02T17:45:30.250Z", 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notebooks SET path = ? || substr(path, ?) WHERE path LIKE ? || '%'")).
+		WithArgs("/3/2/", 6, "/1/2/").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectCommit()
+
+	// Act
+	err = repo.Move(testWorkspaceId, 2, &parentId)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_MoveNotebookBelowItself(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+	childId := 4
+	notebook := &models.Notebook{Id: 2, Name: "Work", Path: "/2/", CreatedAt: created, UpdatedAt: created}
+	child := &models.Notebook{Id: 4, Name: "Projects", ParentId: &notebook.Id, Path: "/2/4/", CreatedAt: created, UpdatedAt: created}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(2, testWorkspaceId).WillReturnRows(notebookRows(notebook))
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(4, testWorkspaceId).WillReturnRows(notebookRows(child))
+	mock.ExpectRollback()
+
+	// Act
+	err = repo.Move(testWorkspaceId, 2, &childId)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotebookCycle)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_DeleteNotebookNotEmpty(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+	notebook := &models.Notebook{Id: 2, Name: "Work", Path: "/2/", CreatedAt: created, UpdatedAt: created}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(2, testWorkspaceId).WillReturnRows(notebookRows(notebook))
+	mock.ExpectQuery("SELECT count").WithArgs(2, 2).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	// Act
+	err = repo.Delete(testWorkspaceId, 2, models.NotebookDeleteBlock)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotebookNotEmpty)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_DeleteNotebookTrash(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+	repo.now = func() time.Time { return updated }
+	notebook := &models.Notebook{Id: 2, Name: "Work", Path: "/2/", CreatedAt: created, UpdatedAt: created}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(2, testWorkspaceId).WillReturnRows(notebookRows(notebook))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET deleted_at = ?, version = version + 1")).
+		WithArgs("2024-05-02T17:45:30.250Z", "/2/").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET notebook_id = NULL")).WithArgs("/2/").WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM notebooks WHERE path LIKE ? || '%'")).WithArgs("/2/").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	// Act
+	err = repo.Delete(testWorkspaceId, 2, models.NotebookDeleteTrash)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_DeleteNotebookMove(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+	repo.now = func() time.Time { return updated }
+	parentId := 1
+	notebook := &models.Notebook{Id: 12, Name: "Work", ParentId: &parentId, Path: "/1/12/", CreatedAt: created, UpdatedAt: created}
+	now := "2024-05-02T17:45:30.250Z"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(12, testWorkspaceId).WillReturnRows(notebookRows(notebook))
+	mock.ExpectQuery("SELECT count").WithArgs(12, testWorkspaceId, 1, 12).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notebooks SET parent_id = ?, updated_at = ? WHERE parent_id = ?")).
+		WithArgs(1, now, 12).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notebooks SET path = ? || substr(path, ?)")).
+		WithArgs("/1/", 7, "/1/12/").WillReturnResult(sqlmock.NewResult(0, 3))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET notebook_id = ?, updated_at = ?, version = version + 1")).
+		WithArgs(1, now, 12).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET notebook_id = ? WHERE notebook_id = ?")).
+		WithArgs(1, 12).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM notebooks WHERE id = ?")).WithArgs(12).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Act
+	err = repo.Delete(testWorkspaceId, 12, models.NotebookDeleteMove)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetAllNotesInNotebook(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	notebookId := 2
+	note := &models.Note{Id: 1, Title: "Title", Content: "Content", NotebookId: &notebookId, CreatedAt: created, UpdatedAt: created, Version: 1}
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE workspace_id = ? AND deleted_at IS NULL AND notebook_id = ? ORDER BY id ASC")).
+		WithArgs(testWorkspaceId, 2, 11).WillReturnRows(noteRows(note))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE workspace_id = ? AND deleted_at IS NULL AND notebook_id IN (SELECT id FROM notebooks WHERE path LIKE (SELECT path FROM notebooks WHERE id = ?) || '%') ORDER BY id ASC")).
+		WithArgs(testWorkspaceId, 2, 11).WillReturnRows(noteRows(note))
+
+	// Act
+	direct, directErr := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 10, NotebookId: 2})
+	recursive, recursiveErr := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 10, NotebookId: 2, Recursive: true})
+
+	// Assert
+	assert.NoError(t, directErr)
+	assert.Equal(t, []*models.Note{note}, direct.Notes)
+	assert.NoError(t, recursiveErr)
+	assert.Equal(t, []*models.Note{note}, recursive.Notes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_MoveNote(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
+	notebookId := 2
+	notebook := &models.Notebook{Id: 2, Name: "Work", Path: "/2/", CreatedAt: created, UpdatedAt: created}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(2, testWorkspaceId).WillReturnRows(notebookRows(notebook))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET notebook_id = ?, updated_at = ?, version = version + 1")).
+		WithArgs(2, "2024-05-02T17:45:30.250Z", 1, testWorkspaceId, 3, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoWebhooks(mock, models.EventNoteUpdated)
+	mock.ExpectCommit()
+
+	// Act
+	err = repo.MoveNote(context.Background(), testWorkspaceId, 1, &notebookId, 3)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_MoveNoteNotebookNotFound(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	notebookId := 9
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM notebooks WHERE id = \\? AND workspace_id = \\?").WithArgs(9, testWorkspaceId).WillReturnRows(notebookRows())
+	mock.ExpectRollback()
+
+	// Act
+	err = repo.MoveNote(context.Background(), testWorkspaceId, 1, &notebookId, 0)
+
+	// Assert
+	assert.ErrorIs(t, err, ErrNotebookNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
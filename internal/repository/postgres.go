@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// postgresNoteRepository implements the NoteRepository interface on
+// PostgreSQL. It shares the queries of noteRepository, which are written to
+// run on both databases, except for search, which uses the tsvector column
+// of notes instead of the FTS5 index of SQLite.
+type postgresNoteRepository struct {
+	*noteRepository
+}
+
+// NewPostgresNotesRepository creates a new postgresNoteRepository on a
+// database opened with db.OpenPostgresDB.
+func NewPostgresNotesRepository(db *sql.DB) *postgresNoteRepository {
+	return &postgresNoteRepository{NewNotesRepository(db)}
+}
+
+// Search finds the notes of a workspace matching a full-text query, best
+// matches first, like noteRepository.Search. The query is translated from
+// FTS5 syntax to a tsquery, so it supports the same "phrase queries",
+// prefix* matching, AND, OR and NOT operators and title: and content:
+// filters. Title matches rank ten times higher than content matches.
+// It returns ErrInvalidSearchQuery if the query cannot be parsed.
+func (r *postgresNoteRepository) Search(ctx context.Context, workspaceId int, opts models.SearchOptions) ([]*models.SearchResult, error) {
+	query, err := toTSQuery(opts.Query)
+	if err != nil {
+		return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("%w: %v", ErrInvalidSearchQuery, err)}
+	}
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT notes.id, notes.title, notes.content,
+        notes.created_at, notes.updated_at, notes.version, notes.deleted_at, notes.notebook_id,
+        `+tagsColumn+`,
+        ts_rank_cd('{0, 0, 0.1, 1}', notes.search, query),
+        ts_headline('notes_search', translate(notes.title, chr(2) || chr(3), '  '), query,
+            'StartSel=`+markStart+`, StopSel=`+markEnd+`, HighlightAll=true'),
+        ts_headline('notes_search', translate(notes.content, chr(2) || chr(3), '  '), query,
+            'StartSel=`+markStart+`, StopSel=`+markEnd+`, MinWords=8, MaxWords=16, ShortWord=0')
+    FROM notes, to_tsquery('notes_search', ?) AS query
+    WHERE notes.search @@ query AND notes.workspace_id = ? AND notes.deleted_at IS NULL
+    ORDER BY 10 DESC, notes.id
+    LIMIT ? OFFSET ?`, query, workspaceId, opts.Limit, opts.Offset)
+	if err != nil {
+		return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	results := []*models.SearchResult{}
+	for rows.Next() {
+		res := &models.SearchResult{}
+		note, err := scanNote(rows, &res.Score, &res.Highlight, &res.Snippet)
+		if err != nil {
+			return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("Error Scanning: %w", err)}
+		}
+		res.Note = *note
+		res.Highlight, res.Snippet = escapeHighlight(res.Highlight), escapeHighlight(res.Snippet)
+		results = append(results, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "SearchNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return results, nil
+}
+
+// columnWeights are the weights of the words of the columns of the full-text
+// index in the search column, by index.
+var columnWeights = []string{"A", "B"}
+
+// toTSQuery translates a full-text query in FTS5 syntax to the text of a
+// tsquery with the same meaning.
+func toTSQuery(query string) (string, error) {
+	node, err := parseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	return node.tsquery(), nil
+}
+
+// tsquery returns the text of the tsquery matching what n matches.
+func (n *queryNode) tsquery() string {
+	switch n.op {
+	case "OR":
+		return "(" + n.left.tsquery() + " | " + n.right.tsquery() + ")"
+	case "AND":
+		return "(" + n.left.tsquery() + " & " + n.right.tsquery() + ")"
+	case "NOT":
+		return "(" + n.left.tsquery() + " & !" + n.right.tsquery() + ")"
+	}
+	weight := ""
+	if n.column >= 0 {
+		weight = columnWeights[n.column]
+	}
+	lexemes := make([]string, len(n.words))
+	for i, word := range n.words {
+		lexemes[i] = "'" + word + "'"
+		if i == len(n.words)-1 && n.prefix {
+			lexemes[i] += ":*" + weight
+		} else if weight != "" {
+			lexemes[i] += ":" + weight
+		}
+	}
+	if len(lexemes) == 1 {
+		return lexemes[0]
+	}
+	return "(" + strings.Join(lexemes, " <-> ") + ")"
+}
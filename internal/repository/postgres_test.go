@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToTSQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		tsquery string
+	}{
+		{"word", "Plan", "'Plan'"},
+		{"implicit and", "weekly plan", "('weekly' & 'plan')"},
+		{"operators", "a OR b AND c NOT d", "('a' | ('b' & ('c' & !'d')))"},
+		{"parentheses", "(a OR b) AND c", "(('a' | 'b') & 'c')"},
+		{"implicit and binds first", "a NOT b c", "('a' & !('b' & 'c'))"},
+		{"phrase", `"first note"`, "('first' <-> 'note')"},
+		{"phrase with punctuation", `"don't panic"`, "('don' <-> 't' <-> 'panic')"},
+		{"escaped quote", `"say ""hi"""`, "('say' <-> 'hi')"},
+		{"prefix", "sec*", "'sec':*"},
+		{"phrase prefix", `"first no"*`, "('first' <-> 'no':*)"},
+		{"column", "title:plan content:week*", "('plan':A & 'week':*B)"},
+		{"column phrase", `title:"first note"`, "('first':A <-> 'note':A)"},
+		{"unicode", "café", "'café'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Act
+			tsquery, err := toTSQuery(tt.query)
+
+			// Assert
+			assert.NoError(t, err)
+			assert.Equal(t, tt.tsquery, tsquery)
+		})
+	}
+}
+
+func TestToTSQuery_Invalid(t *testing.T) {
+	for _, query := range []string{"AND", "a OR", "NOT b", "(a b", "a)", "(a OR b) c", "a (b)", `"open`, `""`, "a-b", "author:ada", "title:(a b)", "*"} {
+		t.Run(query, func(t *testing.T) {
+			// Act
+			_, err := toTSQuery(query)
+
+			// Assert
+			assert.ErrorIs(t, err, errQuerySyntax)
+		})
+	}
+}
+
+func TestPostgresNoteRepository_SearchNotes(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresNotesRepository(db)
+	opts := models.SearchOptions{Query: `"first note" OR sec*`, Limit: 10, Offset: 10}
+
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at", "version", "deleted_at", "notebook_id", "tags", "score", "highlight", "snippet"}).
+		AddRow(1, "First Note", "This is the first note", "2024-05-01T09:30:00.000Z", "2024-05-01T09:30:00.000Z", 1, nil, nil, nil, 1.1, "\x02First\x03 \x02Note\x03", "This is the \x02first\x03 \x02note\x03").
+		AddRow(2, "Second Note", "This is the second note <script>alert(1)</script>", "2024-05-01T09:30:00.000Z", "2024-05-02T17:45:30.250Z", 3, nil, 4, "notes\x1fsearch", 1, "\x02Second\x03 Note", "This is the \x02second\x03 note <script>alert(1)</script>")
+
+	mock.ExpectQuery(`FROM notes, to_tsquery\('notes_search', \?\) AS query`).
+		WithArgs("(('first' <-> 'note') | 'sec':*)", testWorkspaceId, 10, 10).WillReturnRows(rows)
+
+	// Act
+	res, err := repo.Search(context.Background(), testWorkspaceId, opts)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Len(t, res, 2)
+	assert.Equal(t, 1.1, res[0].Score)
+	assert.Equal(t, "<mark>First</mark> <mark>Note</mark>", res[0].Highlight)
+	assert.Equal(t, "This is the <mark>second</mark> note &lt;script&gt;alert(1)&lt;/script&gt;", res[1].Snippet)
+	assert.Equal(t, updated, res[1].UpdatedAt)
+	assert.Equal(t, []string{"notes", "search"}, res[1].Tags)
+	assert.Equal(t, 4, *res[1].NotebookId)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresNoteRepository_SearchNotesInvalidQuery(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewPostgresNotesRepository(db)
+
+	// Act
+	_, err = repo.Search(context.Background(), testWorkspaceId, models.SearchOptions{Query: `"unterminated`, Limit: 10})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrInvalidSearchQuery)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// errQuerySyntax is wrapped by the errors of parseQuery.
+var errQuerySyntax = errors.New("syntax error")
+
+// searchColumns maps the columns a query can be restricted to to their index
+// in the full-text index, title first.
+var searchColumns = map[string]int{"title": 0, "content": 1}
+
+// queryNode is a node of a parsed full-text query: an operator joining two
+// queries or a phrase.
+type queryNode struct {
+	// op is AND, OR or NOT for operators and empty for phrases. NOT matches
+	// what left matches unless right matches it too.
+	op          string
+	left, right *queryNode
+
+	// words are the words of a phrase, which match in sequence. The last
+	// one matches prefixes if prefix is set. column is the index of the
+	// column the phrase is restricted to, or -1 for any column.
+	words  []string
+	prefix bool
+	column int
+}
+
+// parseQuery parses a full-text query in FTS5 syntax. Operators bind like in
+// FTS5: NOT before AND before OR, while AND may only be left out between
+// phrases, which binds them first.
+func parseQuery(query string) (*queryNode, error) {
+	tokens, err := lexQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("%w near %q", errQuerySyntax, p.tokens[p.pos].text)
+	}
+	return node, nil
+}
+
+// queryToken is a token of a full-text query.
+type queryToken struct {
+	// kind is one of ( ) * : for punctuation, " for a phrase and w for a
+	// bareword.
+	kind byte
+	text string
+}
+
+// isBarewordChar reports whether c may appear in a bareword of an FTS5
+// query.
+func isBarewordChar(c rune) bool {
+	return c > unicode.MaxASCII || c == '_' || c == 0x1a ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
+
+// lexQuery splits a full-text query into tokens.
+func lexQuery(query string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	runes := []rune(query)
+	for i := 0; i < len(runes); {
+		c := runes[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '(' || c == ')' || c == '*' || c == ':':
+			tokens = append(tokens, queryToken{byte(c), string(c)})
+			i++
+		case c == '"':
+			// Quotes are escaped by doubling them.
+			var phrase strings.Builder
+			for i++; ; i++ {
+				if i == len(runes) {
+					return nil, fmt.Errorf("%w: unterminated string", errQuerySyntax)
+				}
+				if runes[i] == '"' {
+					if i+1 < len(runes) && runes[i+1] == '"' {
+						i++
+					} else {
+						break
+					}
+				}
+				phrase.WriteRune(runes[i])
+			}
+			tokens = append(tokens, queryToken{'"', phrase.String()})
+			i++
+		case isBarewordChar(c):
+			start := i
+			for i < len(runes) && isBarewordChar(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, queryToken{'w', string(runes[start:i])})
+		default:
+			return nil, fmt.Errorf("%w near %q", errQuerySyntax, string(c))
+		}
+	}
+	return tokens, nil
+}
+
+// queryParser parses the tokens of a full-text query.
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+// peek returns the next token, which is empty at the end of the query.
+func (p *queryParser) peek() queryToken {
+	if p.pos == len(p.tokens) {
+		return queryToken{}
+	}
+	return p.tokens[p.pos]
+}
+
+// isOperator reports whether t is the operator op.
+func isOperator(t queryToken, op string) bool {
+	return t.kind == 'w' && t.text == op
+}
+
+// startsPhrase reports whether t can start a phrase.
+func startsPhrase(t queryToken) bool {
+	return t.kind == '"' ||
+		t.kind == 'w' && !isOperator(t, "AND") && !isOperator(t, "OR") && !isOperator(t, "NOT")
+}
+
+func (p *queryParser) or() (*queryNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for isOperator(p.peek(), "OR") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) and() (*queryNode, error) {
+	left, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for isOperator(p.peek(), "AND") {
+		p.pos++
+		right, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) not() (*queryNode, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	for isOperator(p.peek(), "NOT") {
+		p.pos++
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{op: "NOT", left: left, right: right}
+	}
+	return left, nil
+}
+
+// operand parses a parenthesized query or a sequence of phrases, which
+// match if all of them match.
+func (p *queryParser) operand() (*queryNode, error) {
+	if p.peek().kind == '(' {
+		p.pos++
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != ')' {
+			return nil, fmt.Errorf("%w: unbalanced parentheses", errQuerySyntax)
+		}
+		p.pos++
+		return inner, nil
+	}
+	left, err := p.phrase()
+	if err != nil {
+		return nil, err
+	}
+	for startsPhrase(p.peek()) {
+		right, err := p.phrase()
+		if err != nil {
+			return nil, err
+		}
+		left = &queryNode{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+// phrase parses a phrase, which may be restricted to a column and end with *
+// to match prefixes.
+func (p *queryParser) phrase() (*queryNode, error) {
+	t := p.peek()
+	if !startsPhrase(t) {
+		if t.kind == 0 {
+			return nil, fmt.Errorf("%w: unexpected end of query", errQuerySyntax)
+		}
+		return nil, fmt.Errorf("%w near %q", errQuerySyntax, t.text)
+	}
+	p.pos++
+
+	phrase := &queryNode{column: -1}
+	if t.kind == 'w' && p.peek().kind == ':' {
+		var ok bool
+		if phrase.column, ok = searchColumns[t.text]; !ok {
+			return nil, fmt.Errorf("%w: no such column %q", errQuerySyntax, t.text)
+		}
+		p.pos++
+		t = p.peek()
+		if t.kind != '"' && t.kind != 'w' {
+			return nil, fmt.Errorf("%w: expected a phrase after the column", errQuerySyntax)
+		}
+		p.pos++
+	}
+
+	phrase.words = strings.FieldsFunc(t.text, func(c rune) bool {
+		return !unicode.IsLetter(c) && !unicode.IsNumber(c)
+	})
+	if len(phrase.words) == 0 {
+		return nil, fmt.Errorf("%w: empty phrase", errQuerySyntax)
+	}
+	if p.peek().kind == '*' {
+		p.pos++
+		phrase.prefix = true
+	}
+	return phrase, nil
+}
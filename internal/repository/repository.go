@@ -1,11 +1,94 @@
 package repository
 
-import "github.com/JannisK89/notes-api/internal/models"
+import (
+	"context"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// TxManager runs units of work: groups of repository calls whose changes are
+// stored together or not at all. Only the calls made with the context of
+// the unit of work take part in it.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
 
 type NoteRepository interface {
-	Get(id int) (*models.Note, error)
-	Create(note *models.Note) (int, error)
-	GetAll() ([]*models.Note, error)
-	Update(id int, note *models.Note) error
-	Delete(id int) error
+	Get(ctx context.Context, workspaceId int, id int) (*models.Note, error)
+	Create(ctx context.Context, workspaceId int, userId int, note *models.Note) (int, error)
+	GetAll(ctx context.Context, workspaceId int, opts models.ListOptions) (*models.NotePage, error)
+	Update(ctx context.Context, workspaceId int, id int, note *models.Note, version int) error
+	Delete(ctx context.Context, workspaceId int, id int, version int) error
+	Search(ctx context.Context, workspaceId int, opts models.SearchOptions) ([]*models.SearchResult, error)
+	Restore(ctx context.Context, workspaceId int, id int) (*models.Note, error)
+	Purge(ctx context.Context, workspaceId int, id int) error
+	PurgeDeletedBefore(ctx context.Context, t time.Time) (int, error)
+	GetRevisions(ctx context.Context, workspaceId int, id int) ([]*models.Revision, error)
+	GetRevision(ctx context.Context, workspaceId int, id int, version int) (*models.Revision, error)
+	GetTags(ctx context.Context, workspaceId int) ([]*models.Tag, error)
+	RenameTag(ctx context.Context, workspaceId int, name string, newName string) (int, error)
+	MergeTags(ctx context.Context, workspaceId int, source string, target string) (int, error)
+	MoveNote(ctx context.Context, workspaceId int, id int, notebookId *int, version int) error
+	GetNoteAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error)
+	GetNotebookAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error)
+	GetNoteShares(ctx context.Context, workspaceId int, id int) ([]*models.Share, error)
+	ShareNote(ctx context.Context, workspaceId int, id int, email string, role models.Role) (*models.Share, error)
+	RevokeNoteShare(ctx context.Context, workspaceId int, id int, userId int) error
+	GetSharedNotes(ctx context.Context, workspaceId int, userId int) ([]*models.SharedNote, error)
+	CreateShareLink(ctx context.Context, workspaceId int, noteId int, link *models.ShareLink, tokenHash string, passwordHash string) (int, error)
+	GetShareLinks(ctx context.Context, workspaceId int, noteId int) ([]*models.ShareLink, error)
+	DeleteShareLink(ctx context.Context, workspaceId int, noteId int, id int) error
+	GetShareLink(ctx context.Context, tokenHash string) (*models.ShareLink, string, error)
+	ViewShareLink(ctx context.Context, id int) (*models.Note, error)
+	GetChanges(ctx context.Context, workspaceId int, since int64, limit int) (*models.ChangePage, error)
+	GetPushedNote(ctx context.Context, workspaceId int, userId int, clientId string) (*models.Note, error)
+	SetClientId(ctx context.Context, workspaceId int, id int, clientId string) error
+}
+
+type NotebookRepository interface {
+	Get(workspaceId int, id int) (*models.Notebook, error)
+	GetAll(workspaceId int) ([]*models.Notebook, error)
+	Create(workspaceId int, userId int, notebook *models.Notebook) (int, error)
+	Rename(workspaceId int, id int, name string) error
+	Move(workspaceId int, id int, parentId *int) error
+	Delete(workspaceId int, id int, mode models.NotebookDeleteMode) error
+	GetNotebookAccess(workspaceId int, userId int, id int) (models.Role, error)
+	GetNotebookShares(workspaceId int, id int) ([]*models.Share, error)
+	ShareNotebook(workspaceId int, id int, email string, role models.Role) (*models.Share, error)
+	RevokeNotebookShare(workspaceId int, id int, userId int) error
+	GetSharedNotebooks(workspaceId int, userId int) ([]*models.SharedNotebook, error)
+}
+
+type UserRepository interface {
+	Create(user *models.User) (int, error)
+	GetByEmail(email string) (*models.User, error)
+	CreateSession(userId int, tokenHash string, expiresAt time.Time) error
+	GetSessionUser(tokenHash string) (*models.User, error)
+	DeleteSession(tokenHash string) error
+	CreateAPIKey(userId int, key *models.APIKey, keyHash string) (int, error)
+	GetAPIKeys(userId int) ([]*models.APIKey, error)
+	DeleteAPIKey(userId int, id int) error
+	GetAPIKeyUser(keyHash string) (*models.User, *models.APIKey, error)
+}
+
+type WorkspaceRepository interface {
+	GetMember(workspaceId int, userId int) (*models.Member, error)
+	GetPersonalMember(userId int) (*models.Member, error)
+	GetAll(userId int) ([]*models.Workspace, error)
+	Create(userId int, workspace *models.Workspace) (int, error)
+	GetMembers(workspaceId int) ([]*models.WorkspaceMember, error)
+	AddMember(workspaceId int, email string, role models.Role) (*models.WorkspaceMember, error)
+	RemoveMember(workspaceId int, userId int) error
+}
+
+type WebhookRepository interface {
+	GetAll(workspaceId int) ([]*models.Webhook, error)
+	Create(workspaceId int, webhook *models.Webhook) (int, error)
+	Delete(workspaceId int, id int) error
+	GetDeliveries(workspaceId int, webhookId int) ([]*models.WebhookDelivery, error)
+	Redeliver(workspaceId int, webhookId int, id int) (*models.WebhookDelivery, error)
+	GetDueDeliveries(t time.Time, limit int) ([]*models.WebhookDelivery, error)
+	ClaimDelivery(delivery *models.WebhookDelivery, t time.Time, until time.Time) error
+	UpdateDelivery(delivery *models.WebhookDelivery) error
 }
@@ -0,0 +1,619 @@
+// Package repositorytest provides a conformance suite for implementations of
+// repository.NoteRepository, so that every backend can prove it behaves like
+// the others.
+package repositorytest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Backend is an empty store under test: its note repository, the manager of
+// its units of work and a way to add users, whose notes the repository keeps
+// apart by workspace.
+type Backend struct {
+	Notes repository.NoteRepository
+	Tx    repository.TxManager
+	// NewMember registers a user with email and returns them as the owner
+	// of their personal workspace.
+	NewMember func(t *testing.T, email string) models.Member
+}
+
+// Factory creates an empty Backend for a single test. Anything it opens must
+// be closed with t.Cleanup.
+type Factory func(t *testing.T) Backend
+
+// Run runs the conformance suite against the backends created by factory,
+// each case as a subtest with a fresh backend.
+func Run(t *testing.T, factory Factory) {
+	cases := []struct {
+		name string
+		test func(t *testing.T, b Backend)
+	}{
+		{"CreateAndGet", testCreateAndGet},
+		{"Update", testUpdate},
+		{"DeleteAndRestore", testDeleteAndRestore},
+		{"NotFound", testNotFound},
+		{"CanceledContext", testCanceledContext},
+		{"Ordering", testOrdering},
+		{"Pagination", testPagination},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"LargePayload", testLargePayload},
+		{"SearchEscapesMarkup", testSearchEscapesMarkup},
+		{"SearchStripsMarks", testSearchStripsMarks},
+		{"PushedNotes", testPushedNotes},
+		{"UnitOfWork", testUnitOfWork},
+		{"UnitOfWorkRollback", testUnitOfWorkRollback},
+		{"UnitOfWorkSavepoint", testUnitOfWorkSavepoint},
+		{"UnitOfWorkRollbackEverything", testUnitOfWorkRollbackEverything},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			c.test(t, factory(t))
+		})
+	}
+}
+
+// create adds a note with title and content to the workspace of member and
+// returns it as stored.
+func create(t *testing.T, b Backend, member models.Member, title string, content string) *models.Note {
+	note := &models.Note{Title: title, Content: content}
+	id, err := b.Notes.Create(context.Background(), member.WorkspaceId, member.UserId, note)
+	require.NoError(t, err)
+	note.Id = id
+	return note
+}
+
+// ids returns the IDs of notes, in order.
+func ids(notes []*models.Note) []int {
+	result := []int{}
+	for _, note := range notes {
+		result = append(result, note.Id)
+	}
+	return result
+}
+
+func testCreateAndGet(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	note := &models.Note{Title: "Plan", Content: "Ship it", Tags: []string{"work", "q3"}}
+
+	// Act
+	id, createErr := b.Notes.Create(context.Background(), ada.WorkspaceId, ada.UserId, note)
+	next := create(t, b, ada, "Next", "Later")
+	got, getErr := b.Notes.Get(context.Background(), ada.WorkspaceId, id)
+
+	// Assert
+	require.NoError(t, createErr)
+	require.NoError(t, getErr)
+	assert.Greater(t, next.Id, id)
+	assert.Equal(t, id, got.Id)
+	assert.Equal(t, "Plan", got.Title)
+	assert.Equal(t, "Ship it", got.Content)
+	assert.Equal(t, []string{"q3", "work"}, got.Tags)
+	assert.Equal(t, 1, got.Version)
+	assert.Nil(t, got.DeletedAt)
+	assert.Nil(t, got.NotebookId)
+	assert.Equal(t, time.UTC, got.CreatedAt.Location())
+	assert.Equal(t, got.CreatedAt.Truncate(time.Millisecond), got.CreatedAt)
+	assert.True(t, note.CreatedAt.Equal(got.CreatedAt))
+	assert.True(t, got.UpdatedAt.Equal(got.CreatedAt))
+}
+
+func testUpdate(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	note := create(t, b, ada, "Plan", "Ship it")
+	time.Sleep(2 * time.Millisecond)
+
+	// Act
+	updateErr := b.Notes.Update(context.Background(), ada.WorkspaceId, note.Id, &models.Note{Title: "Plan", Content: "Shipped", Tags: []string{"done"}}, 1)
+	conflictErr := b.Notes.Update(context.Background(), ada.WorkspaceId, note.Id, &models.Note{Title: "Plan", Content: "Late"}, 1)
+	unconditionalErr := b.Notes.Update(context.Background(), ada.WorkspaceId, note.Id, &models.Note{Title: "Plan", Content: "Done"}, 0)
+	got, getErr := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+	revisions, revisionsErr := b.Notes.GetRevisions(context.Background(), ada.WorkspaceId, note.Id)
+
+	// Assert
+	for _, err := range []error{updateErr, unconditionalErr, getErr, revisionsErr} {
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, conflictErr, repository.ErrVersionConflict)
+	assert.Equal(t, "Done", got.Content)
+	assert.Equal(t, 3, got.Version)
+	// Tags are kept unless an update sets them.
+	assert.Equal(t, []string{"done"}, got.Tags)
+	assert.True(t, got.UpdatedAt.After(got.CreatedAt))
+	require.Len(t, revisions, 3)
+	assert.Equal(t, []int{3, 2, 1}, []int{revisions[0].Version, revisions[1].Version, revisions[2].Version})
+}
+
+func testDeleteAndRestore(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	note := create(t, b, ada, "Plan", "Ship it")
+
+	// Act
+	deleteErr := b.Notes.Delete(context.Background(), ada.WorkspaceId, note.Id, 1)
+	_, getErr := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+	trash, trashErr := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 10, Trashed: true})
+	restored, restoreErr := b.Notes.Restore(context.Background(), ada.WorkspaceId, note.Id)
+	deleteAgainErr := b.Notes.Delete(context.Background(), ada.WorkspaceId, note.Id, 0)
+	purgeErr := b.Notes.Purge(context.Background(), ada.WorkspaceId, note.Id)
+	_, purgedErr := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+	_, restorePurgedErr := b.Notes.Restore(context.Background(), ada.WorkspaceId, note.Id)
+
+	// Assert
+	for _, err := range []error{deleteErr, trashErr, restoreErr, deleteAgainErr, purgeErr} {
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, getErr, repository.ErrNoteNotFound)
+	require.Len(t, trash.Notes, 1)
+	assert.NotNil(t, trash.Notes[0].DeletedAt)
+	assert.Nil(t, restored.DeletedAt)
+	assert.Equal(t, 3, restored.Version)
+	assert.ErrorIs(t, purgedErr, repository.ErrNoteNotFound)
+	assert.ErrorIs(t, restorePurgedErr, repository.ErrNoteNotFound)
+}
+
+func testNotFound(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	grace := b.NewMember(t, "grace@example.com")
+	note := create(t, b, ada, "Plan", "Ship it")
+	missing := note.Id + 1000
+	update := &models.Note{Title: "Plan", Content: "Shipped"}
+
+	// Act
+	errs := map[string]error{}
+	_, errs["Get missing"] = b.Notes.Get(context.Background(), ada.WorkspaceId, missing)
+	_, errs["Get other workspace"] = b.Notes.Get(context.Background(), grace.WorkspaceId, note.Id)
+	errs["Update missing"] = b.Notes.Update(context.Background(), ada.WorkspaceId, missing, update, 0)
+	errs["Update other workspace"] = b.Notes.Update(context.Background(), grace.WorkspaceId, note.Id, update, 0)
+	errs["Delete missing"] = b.Notes.Delete(context.Background(), ada.WorkspaceId, missing, 0)
+	errs["Delete other workspace"] = b.Notes.Delete(context.Background(), grace.WorkspaceId, note.Id, 0)
+	_, errs["Restore live note"] = b.Notes.Restore(context.Background(), ada.WorkspaceId, note.Id)
+	errs["Purge live note"] = b.Notes.Purge(context.Background(), ada.WorkspaceId, note.Id)
+	_, errs["GetRevisions missing"] = b.Notes.GetRevisions(context.Background(), ada.WorkspaceId, missing)
+	_, revisionErr := b.Notes.GetRevision(context.Background(), ada.WorkspaceId, note.Id, 2)
+	others, othersErr := b.Notes.GetAll(context.Background(), grace.WorkspaceId, models.ListOptions{Limit: 10})
+
+	// Assert
+	for name, err := range errs {
+		assert.ErrorIs(t, err, repository.ErrNoteNotFound, name)
+	}
+	assert.ErrorIs(t, revisionErr, repository.ErrRevisionNotFound)
+	require.NoError(t, othersErr)
+	assert.Empty(t, others.Notes)
+	got, err := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+	require.NoError(t, err)
+	assert.Equal(t, "Ship it", got.Content)
+}
+
+func testCanceledContext(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	note := create(t, b, ada, "Plan", "Ship it")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Act
+	_, createErr := b.Notes.Create(ctx, ada.WorkspaceId, ada.UserId, &models.Note{Title: "Next", Content: "Later"})
+	updateErr := b.Notes.Update(ctx, ada.WorkspaceId, note.Id, &models.Note{Title: "Plan", Content: "Shipped"}, 1)
+	_, getErr := b.Notes.Get(ctx, ada.WorkspaceId, note.Id)
+	_, allErr := b.Notes.GetAll(ctx, ada.WorkspaceId, models.ListOptions{Limit: 10})
+	got, err := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+	page, pageErr := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 10})
+
+	// Assert
+	for _, err := range []error{createErr, updateErr, getErr, allErr} {
+		assert.ErrorIs(t, err, context.Canceled)
+	}
+	require.NoError(t, err)
+	require.NoError(t, pageErr)
+	assert.Equal(t, "Ship it", got.Content)
+	assert.Equal(t, 1, got.Version)
+	assert.Equal(t, []int{note.Id}, ids(page.Notes))
+}
+
+func testOrdering(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	notes := map[string]*models.Note{}
+	for _, title := range []string{"banana", "Apple", "cherry", "apple", "Banana"} {
+		notes[title] = create(t, b, ada, title, "Fruit")
+	}
+	time.Sleep(2 * time.Millisecond)
+	require.NoError(t, b.Notes.Update(context.Background(), ada.WorkspaceId, notes["Apple"].Id, &models.Note{Title: "Apple", Content: "Ripe"}, 0))
+	list := func(sort models.SortField, desc bool) []int {
+		page, err := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 10, Sort: sort, Desc: desc})
+		require.NoError(t, err)
+		return ids(page.Notes)
+	}
+	byTitle := func(titles ...string) []int {
+		result := []int{}
+		for _, title := range titles {
+			result = append(result, notes[title].Id)
+		}
+		return result
+	}
+
+	// Act
+	byId := list("", false)
+	byIdDesc := list(models.SortById, true)
+	titles := list(models.SortByTitle, false)
+	updated := list(models.SortByUpdatedAt, false)
+	updatedDesc := list(models.SortByUpdatedAt, true)
+
+	// Assert
+	assert.Equal(t, byTitle("banana", "Apple", "cherry", "apple", "Banana"), byId)
+	assert.Equal(t, byTitle("Banana", "apple", "cherry", "Apple", "banana"), byIdDesc)
+	// Titles sort byte by byte, so upper case comes first.
+	assert.Equal(t, byTitle("Apple", "Banana", "apple", "banana", "cherry"), titles)
+	assert.Equal(t, notes["Apple"].Id, updated[len(updated)-1])
+	assert.Equal(t, notes["Apple"].Id, updatedDesc[0])
+}
+
+func testPagination(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	for i := 0; i < 10; i++ {
+		// Pairs of notes share a title, so pages break between equal values.
+		create(t, b, ada, fmt.Sprintf("Note %d", i/2), "Content")
+	}
+
+	for _, desc := range []bool{false, true} {
+		opts := models.ListOptions{Limit: 3, Sort: models.SortByTitle, Desc: desc}
+		all, err := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 100, Sort: models.SortByTitle, Desc: desc})
+		require.NoError(t, err)
+
+		// Act
+		paged := []*models.Note{}
+		pages := 0
+		for {
+			page, err := b.Notes.GetAll(context.Background(), ada.WorkspaceId, opts)
+			require.NoError(t, err)
+			paged = append(paged, page.Notes...)
+			pages++
+			if !page.HasMore {
+				assert.Empty(t, page.NextCursor)
+				break
+			}
+			require.NotEmpty(t, page.NextCursor)
+			opts.Cursor = page.NextCursor
+		}
+		_, otherSortErr := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 3, Sort: models.SortById, Cursor: opts.Cursor})
+		_, invalidErr := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 3, Cursor: "not a cursor"})
+
+		// Assert
+		assert.Equal(t, 4, pages)
+		assert.Equal(t, ids(all.Notes), ids(paged))
+		assert.Len(t, paged, 10)
+		assert.ErrorIs(t, otherSortErr, repository.ErrInvalidCursor)
+		assert.ErrorIs(t, invalidErr, repository.ErrInvalidCursor)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	const writers, perWriter = 8, 10
+	created := make(chan int, writers*perWriter)
+
+	// Act
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				note := &models.Note{Title: fmt.Sprintf("Note %d-%d", w, i), Content: "Content"}
+				id, err := b.Notes.Create(context.Background(), ada.WorkspaceId, ada.UserId, note)
+				if assert.NoError(t, err) {
+					created <- id
+				}
+				_, err = b.Notes.Get(context.Background(), ada.WorkspaceId, id)
+				assert.NoError(t, err)
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(created)
+	page, err := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: writers * perWriter})
+
+	// Assert
+	require.NoError(t, err)
+	createdIds := []int{}
+	for id := range created {
+		createdIds = append(createdIds, id)
+	}
+	sort.Ints(createdIds)
+	assert.Equal(t, createdIds, ids(page.Notes))
+	assert.Len(t, createdIds, writers*perWriter)
+}
+
+func testConcurrentUpdates(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	note := create(t, b, ada, "Counter", "")
+	const writers = 8
+
+	// Act
+	// Every writer appends to the content until its update is accepted, so
+	// no update may be lost.
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				current, err := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+				if !assert.NoError(t, err) {
+					return
+				}
+				err = b.Notes.Update(context.Background(), ada.WorkspaceId, note.Id, &models.Note{Title: "Counter", Content: current.Content + "+"}, current.Version)
+				if err == nil || !assert.ErrorIs(t, err, repository.ErrVersionConflict) {
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	got, err := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("+", writers), got.Content)
+	assert.Equal(t, writers+1, got.Version)
+}
+
+func testLargePayload(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	// About 1 MiB of multi-byte text with a word at the very end.
+	content := strings.Repeat("Grüße aus dem Garten, 日本語のノート. ", 20000) + "needle"
+	title := strings.Repeat("Ü", 1000)
+	tags := []string{}
+	for i := 0; i < 100; i++ {
+		tags = append(tags, fmt.Sprintf("tag-%03d", i))
+	}
+	note := &models.Note{Title: title, Content: content, Tags: tags}
+
+	// Act
+	id, createErr := b.Notes.Create(context.Background(), ada.WorkspaceId, ada.UserId, note)
+	got, getErr := b.Notes.Get(context.Background(), ada.WorkspaceId, id)
+	updateErr := b.Notes.Update(context.Background(), ada.WorkspaceId, id, &models.Note{Title: title, Content: content + " haystack"}, 0)
+	updated, updatedErr := b.Notes.Get(context.Background(), ada.WorkspaceId, id)
+	results, searchErr := b.Notes.Search(context.Background(), ada.WorkspaceId, models.SearchOptions{Query: "needle", Limit: 10})
+
+	// Assert
+	for _, err := range []error{createErr, getErr, updateErr, updatedErr, searchErr} {
+		require.NoError(t, err)
+	}
+	assert.Equal(t, title, got.Title)
+	assert.True(t, got.Content == content, "content of %d bytes changed", len(content))
+	assert.Equal(t, tags, got.Tags)
+	assert.True(t, updated.Content == content+" haystack", "updated content of %d bytes changed", len(content))
+	require.Len(t, results, 1)
+	assert.Equal(t, id, results[0].Id)
+	assert.Contains(t, results[0].Snippet, "<mark>needle</mark>")
+}
+
+func testSearchEscapesMarkup(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	create(t, b, ada, "<b>Needle</b> & co", `<script>alert("needle")</script> <img src=x onerror=alert(1)> needle`)
+
+	// Act
+	results, err := b.Notes.Search(context.Background(), ada.WorkspaceId, models.SearchOptions{Query: "needle", Limit: 10})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "&lt;b&gt;<mark>Needle</mark>&lt;/b&gt; &amp; co", results[0].Highlight)
+	assert.Equal(t, "&lt;script&gt;alert(&#34;<mark>needle</mark>&#34;)&lt;/script&gt; &lt;img src=x onerror=alert(1)&gt; <mark>needle</mark>", results[0].Snippet)
+	assert.Equal(t, `<script>alert("needle")</script> <img src=x onerror=alert(1)> needle`, results[0].Content)
+}
+
+func testSearchStripsMarks(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	create(t, b, ada, "Needle\x02 list", "Before\x03 the needle\x02\x03 after\x03")
+
+	// Act
+	results, err := b.Notes.Search(context.Background(), ada.WorkspaceId, models.SearchOptions{Query: "needle", Limit: 10})
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "<mark>Needle</mark>  list", results[0].Highlight)
+	assert.Equal(t, "Before  the <mark>needle</mark>   after ", results[0].Snippet)
+	assert.Equal(t, "Before\x03 the needle\x02\x03 after\x03", results[0].Content)
+}
+
+func testPushedNotes(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	grace := b.NewMember(t, "grace@example.com")
+	note := create(t, b, ada, "Plan", "Ship it")
+	other := create(t, b, ada, "Draft", "Maybe")
+	graces := create(t, b, grace, "Plan", "Ship it")
+
+	// Act
+	setErr := b.Notes.SetClientId(context.Background(), ada.WorkspaceId, note.Id, "offline-1")
+	takenErr := b.Notes.SetClientId(context.Background(), ada.WorkspaceId, other.Id, "offline-1")
+	otherUserErr := b.Notes.SetClientId(context.Background(), grace.WorkspaceId, graces.Id, "offline-1")
+	otherWorkspaceErr := b.Notes.SetClientId(context.Background(), grace.WorkspaceId, note.Id, "offline-2")
+	pushed, pushedErr := b.Notes.GetPushedNote(context.Background(), ada.WorkspaceId, ada.UserId, "offline-1")
+	_, unknownErr := b.Notes.GetPushedNote(context.Background(), ada.WorkspaceId, ada.UserId, "offline-2")
+	_, otherMemberErr := b.Notes.GetPushedNote(context.Background(), ada.WorkspaceId, grace.UserId, "offline-1")
+	deleteErr := b.Notes.Delete(context.Background(), ada.WorkspaceId, note.Id, 0)
+	trashed, trashedErr := b.Notes.GetPushedNote(context.Background(), ada.WorkspaceId, ada.UserId, "offline-1")
+	purgeErr := b.Notes.Purge(context.Background(), ada.WorkspaceId, note.Id)
+	_, purgedErr := b.Notes.GetPushedNote(context.Background(), ada.WorkspaceId, ada.UserId, "offline-1")
+
+	// Assert
+	for _, err := range []error{setErr, otherUserErr, pushedErr, deleteErr, trashedErr, purgeErr} {
+		require.NoError(t, err)
+	}
+	assert.ErrorIs(t, takenErr, repository.ErrClientIdTaken)
+	assert.ErrorIs(t, otherWorkspaceErr, repository.ErrNoteNotFound)
+	assert.Equal(t, note.Id, pushed.Id)
+	assert.Equal(t, "Ship it", pushed.Content)
+	assert.ErrorIs(t, unknownErr, repository.ErrNoteNotFound)
+	assert.ErrorIs(t, otherMemberErr, repository.ErrNoteNotFound)
+	assert.Equal(t, note.Id, trashed.Id)
+	assert.NotNil(t, trashed.DeletedAt)
+	assert.ErrorIs(t, purgedErr, repository.ErrNoteNotFound)
+}
+
+func testUnitOfWork(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	note := create(t, b, ada, "Plan", "Ship it")
+	var inside *models.Note
+	var conflictErr error
+
+	// Act
+	err := b.Tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		if err := b.Notes.Update(ctx, ada.WorkspaceId, note.Id, &models.Note{Title: "Plan", Content: "Shipped"}, 1); err != nil {
+			return err
+		}
+		// A failed call leaves the unit of work usable.
+		conflictErr = b.Notes.Update(ctx, ada.WorkspaceId, note.Id, &models.Note{Title: "Plan", Content: "Late"}, 1)
+		var err error
+		inside, err = b.Notes.Get(ctx, ada.WorkspaceId, note.Id)
+		return err
+	})
+	got, getErr := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, getErr)
+	assert.ErrorIs(t, conflictErr, repository.ErrVersionConflict)
+	assert.Equal(t, "Shipped", inside.Content)
+	assert.Equal(t, "Shipped", got.Content)
+	assert.Equal(t, 2, got.Version)
+}
+
+func testUnitOfWorkRollback(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	note := create(t, b, ada, "Plan", "Ship it")
+	failure := fmt.Errorf("failure")
+	work := func(ctx context.Context) {
+		_, err := b.Notes.Create(ctx, ada.WorkspaceId, ada.UserId, &models.Note{Title: "Next", Content: "Later"})
+		require.NoError(t, err)
+		require.NoError(t, b.Notes.Delete(ctx, ada.WorkspaceId, note.Id, 1))
+	}
+
+	// Act
+	err := b.Tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		work(ctx)
+		return failure
+	})
+	panicked := func() {
+		b.Tx.WithinTx(context.Background(), func(ctx context.Context) error {
+			work(ctx)
+			panic(failure)
+		})
+	}
+	got, getErr := b.Notes.Get(context.Background(), ada.WorkspaceId, note.Id)
+	page, pageErr := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 10})
+
+	// Assert
+	assert.ErrorIs(t, err, failure)
+	assert.PanicsWithValue(t, failure, panicked)
+	require.NoError(t, getErr)
+	require.NoError(t, pageErr)
+	assert.Equal(t, 1, got.Version)
+	assert.Equal(t, []int{note.Id}, ids(page.Notes))
+}
+
+func testUnitOfWorkSavepoint(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	failure := fmt.Errorf("failure")
+	var kept, undone int
+
+	// Act
+	err := b.Tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		var err error
+		kept, err = b.Notes.Create(ctx, ada.WorkspaceId, ada.UserId, &models.Note{Title: "Kept", Content: "Stored"})
+		require.NoError(t, err)
+		innerErr := b.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			note := &models.Note{Title: "Undone", Content: "Rolled back"}
+			var err error
+			undone, err = b.Notes.Create(ctx, ada.WorkspaceId, ada.UserId, note)
+			require.NoError(t, err)
+			return failure
+		})
+		require.ErrorIs(t, innerErr, failure)
+		_, err = b.Notes.Get(ctx, ada.WorkspaceId, undone)
+		require.ErrorIs(t, err, repository.ErrNoteNotFound)
+		return b.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			return b.Notes.Update(ctx, ada.WorkspaceId, kept, &models.Note{Title: "Kept", Content: "Updated"}, 1)
+		})
+	})
+	page, pageErr := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 10})
+
+	// Assert
+	require.NoError(t, err)
+	require.NoError(t, pageErr)
+	require.Len(t, page.Notes, 1)
+	assert.Equal(t, kept, page.Notes[0].Id)
+	assert.Equal(t, "Updated", page.Notes[0].Content)
+}
+
+func testUnitOfWorkRollbackEverything(t *testing.T, b Backend) {
+	// Arrange
+	ada := b.NewMember(t, "ada@example.com")
+	grace := b.NewMember(t, "grace@example.com")
+	shared := create(t, b, ada, "Shared", "Later")
+	trashed := create(t, b, ada, "Trashed", "Gone")
+	require.NoError(t, b.Notes.Delete(context.Background(), ada.WorkspaceId, trashed.Id, 0))
+	link := &models.ShareLink{Prefix: "abcdefgh"}
+	_, err := b.Notes.CreateShareLink(context.Background(), ada.WorkspaceId, shared.Id, link, "hash", "")
+	require.NoError(t, err)
+	before, err := b.Notes.GetChanges(context.Background(), ada.WorkspaceId, 0, 100)
+	require.NoError(t, err)
+	failure := fmt.Errorf("failure")
+
+	// Act
+	err = b.Tx.WithinTx(context.Background(), func(ctx context.Context) error {
+		_, err := b.Notes.ShareNote(ctx, ada.WorkspaceId, shared.Id, "grace@example.com", models.RoleEditor)
+		require.NoError(t, err)
+		_, err = b.Notes.ViewShareLink(ctx, link.Id)
+		require.NoError(t, err)
+		require.NoError(t, b.Notes.DeleteShareLink(ctx, ada.WorkspaceId, shared.Id, link.Id))
+		_, err = b.Notes.RenameTag(ctx, ada.WorkspaceId, "missing", "other")
+		require.Error(t, err)
+		require.NoError(t, b.Notes.Purge(ctx, ada.WorkspaceId, trashed.Id))
+		return failure
+	})
+	access, accessErr := b.Notes.GetNoteAccess(context.Background(), ada.WorkspaceId, grace.UserId, shared.Id)
+	links, linksErr := b.Notes.GetShareLinks(context.Background(), ada.WorkspaceId, shared.Id)
+	trash, trashErr := b.Notes.GetAll(context.Background(), ada.WorkspaceId, models.ListOptions{Limit: 10, Trashed: true})
+	after, afterErr := b.Notes.GetChanges(context.Background(), ada.WorkspaceId, 0, 100)
+
+	// Assert
+	assert.ErrorIs(t, err, failure)
+	for _, err := range []error{accessErr, linksErr, trashErr, afterErr} {
+		require.NoError(t, err)
+	}
+	assert.Empty(t, access)
+	require.Len(t, links, 1)
+	assert.Equal(t, 0, links[0].Views)
+	assert.Equal(t, []int{trashed.Id}, ids(trash.Notes))
+	assert.Equal(t, before, after)
+}
@@ -0,0 +1,28 @@
+package repositorytest
+
+import (
+	"testing"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun_Memory(t *testing.T) {
+	Run(t, func(t *testing.T) Backend {
+		store := repository.NewMemoryStore()
+		users := repository.NewMemoryUsersRepository(store)
+		workspaces := repository.NewMemoryWorkspacesRepository(store)
+		return Backend{
+			Notes: repository.NewMemoryNotesRepository(store),
+			Tx:    repository.NewMemoryTxManager(store),
+			NewMember: func(t *testing.T, email string) models.Member {
+				userId, err := users.Create(&models.User{Email: email, PasswordHash: "hash"})
+				require.NoError(t, err)
+				member, err := workspaces.GetPersonalMember(userId)
+				require.NoError(t, err)
+				return *member
+			},
+		}
+	})
+}
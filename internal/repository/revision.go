@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// ErrRevisionNotFound is returned when a note has no revision with the given
+// version.
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// revisionColumns are the columns scanned by scanRevision, in order.
+const revisionColumns = "note_revisions.note_id, note_revisions.version, note_revisions.title, note_revisions.content, note_revisions.created_at"
+
+// scanRevision reads a revision selected with revisionColumns from row.
+func scanRevision(row scanner) (*models.Revision, error) {
+	rev := &models.Revision{}
+	var createdAt string
+	if err := row.Scan(&rev.NoteId, &rev.Version, &rev.Title, &rev.Content, &createdAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if rev.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	return rev, nil
+}
+
+// addRevision records the title and content of note as revision version of
+// note id and removes the revisions beyond MaxRevisions.
+func (r *noteRepository) addRevision(ctx context.Context, tx querier, id int, version int, note *models.Note, at time.Time) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO note_revisions (note_id, version, title, content, created_at) VALUES (?, ?, ?, ?, ?)",
+		id, version, note.Title, note.Content, formatTime(at))
+	if err != nil || r.MaxRevisions <= 0 {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `DELETE FROM note_revisions WHERE note_id = ? AND version NOT IN (
+        SELECT version FROM note_revisions WHERE note_id = ? ORDER BY version DESC LIMIT ?)`,
+		id, id, r.MaxRevisions)
+	return err
+}
+
+// GetRevisions retrieves the revisions of a note of a workspace, newest first.
+// It returns ErrNoteNotFound if the note is not found or in the trash.
+func (r *noteRepository) GetRevisions(ctx context.Context, workspaceId int, id int) ([]*models.Revision, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT `+revisionColumns+`
+    FROM note_revisions JOIN notes ON notes.id = note_revisions.note_id
+    WHERE note_revisions.note_id = ? AND notes.workspace_id = ? AND notes.deleted_at IS NULL
+    ORDER BY note_revisions.version DESC`, id, workspaceId)
+	if err != nil {
+		return nil, &RepoError{"GetRevisions", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	revisions := []*models.Revision{}
+	for rows.Next() {
+		rev, err := scanRevision(rows)
+		if err != nil {
+			return nil, &RepoError{"GetRevisions", id, fmt.Errorf("DB Error: %w", err)}
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{"GetRevisions", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	// Every note has at least the revision it was created with.
+	if len(revisions) == 0 {
+		return nil, &RepoError{"GetRevisions", id, ErrNoteNotFound}
+	}
+	return revisions, nil
+}
+
+// GetRevision retrieves the revision of a note of a workspace at the given
+// version.
+// It returns ErrNoteNotFound if the note is not found or in the trash and
+// ErrRevisionNotFound if it has no such revision.
+func (r *noteRepository) GetRevision(ctx context.Context, workspaceId int, id int, version int) (*models.Revision, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, `SELECT `+revisionColumns+`
+    FROM note_revisions JOIN notes ON notes.id = note_revisions.note_id
+    WHERE note_revisions.note_id = ? AND note_revisions.version = ? AND notes.workspace_id = ? AND notes.deleted_at IS NULL`, id, version, workspaceId)
+	rev, err := scanRevision(row)
+	if err == sql.ErrNoRows {
+		if _, err := r.Get(ctx, workspaceId, id); err != nil {
+			return nil, err
+		}
+		return nil, &RepoError{"GetRevision", id, fmt.Errorf("%w: version %d", ErrRevisionNotFound, version)}
+	}
+	if err != nil {
+		return nil, &RepoError{"GetRevision", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return rev, nil
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// revisionRows returns the rows a query selecting revisionColumns yields for
+// revisions.
+func revisionRows(revisions ...*models.Revision) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"note_id", "version", "title", "content", "created_at"})
+	for _, rev := range revisions {
+		rows.AddRow(rev.NoteId, rev.Version, rev.Title, rev.Content, formatTime(rev.CreatedAt))
+	}
+	return rows
+}
+
+func TestNoteRepository_GetRevisions(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	revisions := []*models.Revision{
+		{NoteId: 1, Version: 3, Title: "Title", Content: "Third", CreatedAt: updated},
+		{NoteId: 1, Version: 1, Title: "Title", Content: "First", CreatedAt: created},
+	}
+
+	mock.ExpectQuery("FROM note_revisions JOIN notes").WithArgs(1, testWorkspaceId).WillReturnRows(revisionRows(revisions...))
+	mock.ExpectQuery("FROM note_revisions JOIN notes").WithArgs(2, testWorkspaceId).WillReturnRows(revisionRows())
+
+	// Act
+	res, err := repo.GetRevisions(context.Background(), testWorkspaceId, 1)
+	_, notFoundErr := repo.GetRevisions(context.Background(), testWorkspaceId, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, revisions, res)
+	assert.ErrorIs(t, notFoundErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetRevision(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	rev := &models.Revision{NoteId: 1, Version: 2, Title: "Title", Content: "Second", CreatedAt: updated}
+	note := &models.Note{Id: 1, Title: "Title", Content: "Third", CreatedAt: created, UpdatedAt: updated, Version: 3}
+
+	mock.ExpectQuery("FROM note_revisions JOIN notes").WithArgs(1, 2, testWorkspaceId).WillReturnRows(revisionRows(rev))
+	// A missing revision is told apart from a missing note.
+	mock.ExpectQuery("FROM note_revisions JOIN notes").WithArgs(1, 9, testWorkspaceId).WillReturnRows(revisionRows())
+	mock.ExpectQuery("FROM notes WHERE id").WithArgs(1, testWorkspaceId).WillReturnRows(noteRows(note))
+	mock.ExpectQuery("FROM note_revisions JOIN notes").WithArgs(2, 1, testWorkspaceId).WillReturnRows(revisionRows())
+	mock.ExpectQuery("FROM notes WHERE id").WithArgs(2, testWorkspaceId).WillReturnRows(noteRows())
+
+	// Act
+	res, err := repo.GetRevision(context.Background(), testWorkspaceId, 1, 2)
+	_, revisionErr := repo.GetRevision(context.Background(), testWorkspaceId, 1, 9)
+	_, noteErr := repo.GetRevision(context.Background(), testWorkspaceId, 2, 1)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, rev, res)
+	assert.ErrorIs(t, revisionErr, ErrRevisionNotFound)
+	assert.ErrorIs(t, noteErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_UpdateKeepsAllRevisions(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.MaxRevisions = 0
+	note := &models.Note{Title: "Title", Content: "Content"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE notes").
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow(formatTime(created), formatTime(updated), 60))
+	mock.ExpectExec("INSERT INTO note_revisions").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoWebhooks(mock, models.EventNoteUpdated)
+	mock.ExpectCommit()
+
+	// Act
+	err = repo.Update(context.Background(), testWorkspaceId, 1, note, 0)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 60, note.Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
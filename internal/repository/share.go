@@ -0,0 +1,334 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+var (
+	// ErrShareNotFound is returned when a note or notebook is not shared with
+	// the given user.
+	ErrShareNotFound = errors.New("share not found")
+	// ErrShareWithOwner is returned when a note or notebook is shared with
+	// the user who created it, who already owns it.
+	ErrShareWithOwner = errors.New("cannot share with the owner")
+)
+
+// noteShareRank is an SQL expression for the highest role the user bound to
+// its two parameters was granted on the current row of notes, directly or
+// through the notebook the note is filed in or one above it. It is 0 for
+// none, 1 for viewer and 2 for editor.
+const noteShareRank = `coalesce((SELECT max(rank) FROM (
+    SELECT CASE note_shares.role WHEN 'editor' THEN 2 ELSE 1 END AS rank FROM note_shares
+        WHERE note_shares.note_id = notes.id AND note_shares.user_id = ?
+    UNION ALL
+    SELECT CASE notebook_shares.role WHEN 'editor' THEN 2 ELSE 1 END
+        FROM notebook_shares JOIN notebooks AS shared ON shared.id = notebook_shares.notebook_id
+        WHERE notebook_shares.user_id = ?
+        AND (SELECT path FROM notebooks WHERE notebooks.id = notes.notebook_id) LIKE shared.path || '%') AS ranks), 0)`
+
+// notebookShareRank is like noteShareRank for the current row of notebooks,
+// which is granted by sharing it or a notebook above it. It has one
+// parameter.
+const notebookShareRank = `coalesce((SELECT max(CASE notebook_shares.role WHEN 'editor' THEN 2 ELSE 1 END)
+    FROM notebook_shares JOIN notebooks AS shared ON shared.id = notebook_shares.notebook_id
+    WHERE notebook_shares.user_id = ? AND notebooks.path LIKE shared.path || '%'), 0)`
+
+// shareRoles maps the ranks computed by noteShareRank and notebookShareRank
+// to roles.
+var shareRoles = map[int]models.Role{1: models.RoleViewer, 2: models.RoleEditor}
+
+// grantedRole returns the role userId has on a note or notebook created by
+// creatorId that the user was granted rank on, which is empty if the user
+// was granted nothing.
+func grantedRole(userId int, creatorId int, rank int) models.Role {
+	if creatorId == userId {
+		return models.RoleOwner
+	}
+	return shareRoles[rank]
+}
+
+// shareTarget describes the tables a kind of shared resource is stored in.
+type shareTarget struct {
+	// table holds the resources and shares their shares.
+	table  string
+	shares string
+	// column is the column of shares that references table.
+	column string
+	// live restricts table to the resources that can be shared.
+	live     string
+	notFound error
+}
+
+var (
+	noteShareTarget     = shareTarget{"notes", "note_shares", "note_id", " AND deleted_at IS NULL", ErrNoteNotFound}
+	notebookShareTarget = shareTarget{"notebooks", "notebook_shares", "notebook_id", "", ErrNotebookNotFound}
+)
+
+// getShares retrieves the owner of a resource of a workspace followed by the
+// users it is shared with, ordered by email.
+func getShares(ctx context.Context, db *sql.DB, t shareTarget, src string, workspaceId int, id int) ([]*models.Share, error) {
+	rows, err := conn(ctx, db).QueryContext(ctx, `SELECT 0, users.id, users.email, 'owner', `+t.table+`.created_at
+    FROM `+t.table+` JOIN users ON users.id = `+t.table+`.user_id WHERE `+t.table+`.id = ? AND `+t.table+`.workspace_id = ?
+    UNION ALL
+    SELECT 1, users.id, users.email, `+t.shares+`.role, `+t.shares+`.created_at
+    FROM `+t.shares+` JOIN users ON users.id = `+t.shares+`.user_id
+    WHERE `+t.shares+`.`+t.column+` = ? AND `+t.shares+`.`+t.column+` IN (SELECT id FROM `+t.table+` WHERE workspace_id = ?)
+    ORDER BY 1, 3`, id, workspaceId, id, workspaceId)
+	if err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	shares := []*models.Share{}
+	for rows.Next() {
+		share := &models.Share{}
+		var position int
+		var createdAt string
+		if err := rows.Scan(&position, &share.UserId, &share.Email, &share.Role, &createdAt); err != nil {
+			return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+		}
+		if share.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+			return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+		}
+		shares = append(shares, share)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if len(shares) == 0 {
+		return nil, &RepoError{src, id, t.notFound}
+	}
+	return shares, nil
+}
+
+// share grants the user with the given email role on a resource of the
+// workspace, or changes the role if it is already shared with the user.
+// The user does not need to be a member of the workspace.
+func share(ctx context.Context, db *sql.DB, now time.Time, t shareTarget, src string, workspaceId int, id int, email string, role models.Role) (*models.Share, error) {
+	tx, err := beginTx(ctx, db)
+	if err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	var ownerId int
+	err = tx.QueryRowContext(ctx, "SELECT user_id FROM "+t.table+" WHERE id = ? AND workspace_id = ?"+t.live, id, workspaceId).Scan(&ownerId)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{src, id, t.notFound}
+	}
+	if err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	s := &models.Share{Role: role}
+	err = tx.QueryRowContext(ctx, "SELECT id, email FROM users WHERE email = ?", email).Scan(&s.UserId, &s.Email)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{src, id, ErrUserNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if s.UserId == ownerId {
+		return nil, &RepoError{src, id, ErrShareWithOwner}
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO `+t.shares+` (`+t.column+`, user_id, role, created_at) VALUES (?, ?, ?, ?)
+    ON CONFLICT (`+t.column+`, user_id) DO UPDATE SET role = excluded.role`, id, s.UserId, role, formatTime(now))
+	if err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	var createdAt string
+	err = tx.QueryRowContext(ctx, "SELECT created_at FROM "+t.shares+" WHERE "+t.column+" = ? AND user_id = ?", id, s.UserId).Scan(&createdAt)
+	if err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if s.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return s, nil
+}
+
+// revokeShare stops sharing a resource of a workspace with a user.
+func revokeShare(ctx context.Context, db *sql.DB, t shareTarget, src string, workspaceId int, id int, userId int) error {
+	result, err := conn(ctx, db).ExecContext(ctx, "DELETE FROM "+t.shares+" WHERE "+t.column+" = ? AND user_id = ? AND "+t.column+
+		" IN (SELECT id FROM "+t.table+" WHERE workspace_id = ?)", id, userId, workspaceId)
+	if err != nil {
+		return &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return &RepoError{src, id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if count == 0 {
+		return &RepoError{src, id, ErrShareNotFound}
+	}
+	return nil
+}
+
+// notebookAccess returns the role a user was granted on a notebook of a
+// workspace.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func notebookAccess(ctx context.Context, db *sql.DB, workspaceId int, userId int, id int) (models.Role, error) {
+	var creatorId, rank int
+	err := conn(ctx, db).QueryRowContext(ctx, "SELECT user_id, "+notebookShareRank+" FROM notebooks WHERE id = ? AND workspace_id = ?", userId, id, workspaceId).
+		Scan(&creatorId, &rank)
+	if err == sql.ErrNoRows {
+		return "", &RepoError{"GetNotebookAccess", id, ErrNotebookNotFound}
+	}
+	if err != nil {
+		return "", &RepoError{"GetNotebookAccess", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return grantedRole(userId, creatorId, rank), nil
+}
+
+// GetNoteAccess returns the role a user was granted on a note of a
+// workspace, owner if they created it and the role of their share
+// otherwise. It is empty if they were granted nothing, leaving them with
+// the role they have in the workspace.
+// It returns ErrNoteNotFound if the note does not exist, belongs to another
+// workspace or is in the trash.
+func (r *noteRepository) GetNoteAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error) {
+	var creatorId, rank int
+	err := r.conn(ctx).QueryRowContext(ctx, "SELECT user_id, "+noteShareRank+" FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL",
+		userId, userId, id, workspaceId).Scan(&creatorId, &rank)
+	if err == sql.ErrNoRows {
+		return "", &RepoError{"GetNoteAccess", id, ErrNoteNotFound}
+	}
+	if err != nil {
+		return "", &RepoError{"GetNoteAccess", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return grantedRole(userId, creatorId, rank), nil
+}
+
+// GetNotebookAccess returns the role a user was granted on a notebook of a
+// workspace, like GetNoteAccess.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func (r *noteRepository) GetNotebookAccess(ctx context.Context, workspaceId int, userId int, id int) (models.Role, error) {
+	return notebookAccess(ctx, r.db, workspaceId, userId, id)
+}
+
+// GetNoteShares retrieves the owner of a note of a workspace followed by
+// the users the note is shared with directly.
+// It returns ErrNoteNotFound if the note does not exist or belongs to
+// another workspace.
+func (r *noteRepository) GetNoteShares(ctx context.Context, workspaceId int, id int) ([]*models.Share, error) {
+	return getShares(ctx, r.db, noteShareTarget, "GetNoteShares", workspaceId, id)
+}
+
+// ShareNote grants the user with the given email role on a note of a
+// workspace, or changes the role if the note is already shared with the
+// user. The user does not need to be a member of the workspace.
+// It returns ErrNoteNotFound if the note does not exist, belongs to another
+// workspace or is in the trash, ErrUserNotFound if there is no user with the
+// email and ErrShareWithOwner if the user owns the note.
+func (r *noteRepository) ShareNote(ctx context.Context, workspaceId int, id int, email string, role models.Role) (*models.Share, error) {
+	return share(ctx, r.db, r.now(), noteShareTarget, "ShareNote", workspaceId, id, email, role)
+}
+
+// RevokeNoteShare stops sharing a note of a workspace with a user.
+// It returns ErrShareNotFound if the note is not shared with the user.
+func (r *noteRepository) RevokeNoteShare(ctx context.Context, workspaceId int, id int, userId int) error {
+	return revokeShare(ctx, r.db, noteShareTarget, "RevokeNoteShare", workspaceId, id, userId)
+}
+
+// GetSharedNotes retrieves the notes of a workspace created by other users
+// that are shared with a user, directly or through a notebook, ordered by
+// ID.
+func (r *noteRepository) GetSharedNotes(ctx context.Context, workspaceId int, userId int) ([]*models.SharedNote, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT * FROM (
+    SELECT `+noteColumns+`, (SELECT email FROM users WHERE users.id = notes.user_id), `+noteShareRank+` AS share_rank
+    FROM notes WHERE workspace_id = ? AND deleted_at IS NULL AND user_id != ?)
+    WHERE share_rank > 0 ORDER BY id`, userId, userId, workspaceId, userId)
+	if err != nil {
+		return nil, &RepoError{Src: "GetSharedNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	notes := []*models.SharedNote{}
+	for rows.Next() {
+		shared := &models.SharedNote{}
+		var rank int
+		if shared.Note, err = scanNote(rows, &shared.Owner, &rank); err != nil {
+			return nil, &RepoError{Src: "GetSharedNotes", Err: fmt.Errorf("DB Error: %w", err)}
+		}
+		shared.Role = shareRoles[rank]
+		notes = append(notes, shared)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetSharedNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return notes, nil
+}
+
+// GetNotebookAccess returns the role a user was granted on a notebook of a
+// workspace, owner if they created it and the role of their share
+// otherwise. It is empty if they were granted nothing.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func (r *notebookRepository) GetNotebookAccess(workspaceId int, userId int, id int) (models.Role, error) {
+	return notebookAccess(context.Background(), r.db, workspaceId, userId, id)
+}
+
+// GetNotebookShares retrieves the owner of a notebook of a workspace
+// followed by the users the notebook is shared with directly.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace.
+func (r *notebookRepository) GetNotebookShares(workspaceId int, id int) ([]*models.Share, error) {
+	return getShares(context.Background(), r.db, notebookShareTarget, "GetNotebookShares", workspaceId, id)
+}
+
+// ShareNotebook grants the user with the given email role on a notebook of
+// a workspace and everything below it, or changes the role if the notebook
+// is already shared with the user. The user does not need to be a member of
+// the workspace.
+// It returns ErrNotebookNotFound if the notebook does not exist or belongs
+// to another workspace, ErrUserNotFound if there is no user with the email
+// and ErrShareWithOwner if the user owns the notebook.
+func (r *notebookRepository) ShareNotebook(workspaceId int, id int, email string, role models.Role) (*models.Share, error) {
+	return share(context.Background(), r.db, r.now(), notebookShareTarget, "ShareNotebook", workspaceId, id, email, role)
+}
+
+// RevokeNotebookShare stops sharing a notebook of a workspace with a user.
+// It returns ErrShareNotFound if the notebook is not shared with the user.
+func (r *notebookRepository) RevokeNotebookShare(workspaceId int, id int, userId int) error {
+	return revokeShare(context.Background(), r.db, notebookShareTarget, "RevokeNotebookShare", workspaceId, id, userId)
+}
+
+// GetSharedNotebooks retrieves the notebooks of a workspace created by other
+// users that are shared with a user, directly or through a notebook above
+// them, ordered so that every notebook comes after its parent.
+func (r *notebookRepository) GetSharedNotebooks(workspaceId int, userId int) ([]*models.SharedNotebook, error) {
+	rows, err := r.db.Query(`SELECT * FROM (
+    SELECT `+notebookColumns+`, (SELECT email FROM users WHERE users.id = notebooks.user_id), `+notebookShareRank+` AS share_rank
+    FROM notebooks WHERE workspace_id = ? AND user_id != ?)
+    WHERE share_rank > 0 ORDER BY path`, userId, workspaceId, userId)
+	if err != nil {
+		return nil, &RepoError{Src: "GetSharedNotebooks", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	notebooks := []*models.SharedNotebook{}
+	for rows.Next() {
+		shared := &models.SharedNotebook{}
+		var rank int
+		if shared.Notebook, err = scanNotebook(rows, &shared.Owner, &rank); err != nil {
+			return nil, &RepoError{Src: "GetSharedNotebooks", Err: fmt.Errorf("DB Error: %w", err)}
+		}
+		shared.Role = shareRoles[rank]
+		notebooks = append(notebooks, shared)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetSharedNotebooks", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return notebooks, nil
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteRepository_GetNoteAccess(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	query := regexp.QuoteMeta("SELECT user_id, coalesce(")
+
+	mock.ExpectQuery(query).WithArgs(testUserId, testUserId, 1, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"user_id", "rank"}).AddRow(testUserId, 0))
+	mock.ExpectQuery(query).WithArgs(testUserId, testUserId, 2, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"user_id", "rank"}).AddRow(9, 2))
+	mock.ExpectQuery(query).WithArgs(testUserId, testUserId, 3, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"user_id", "rank"}).AddRow(9, 0))
+	mock.ExpectQuery(query).WithArgs(testUserId, testUserId, 4, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"user_id", "rank"}))
+
+	// Act
+	own, ownErr := repo.GetNoteAccess(context.Background(), testWorkspaceId, testUserId, 1)
+	shared, sharedErr := repo.GetNoteAccess(context.Background(), testWorkspaceId, testUserId, 2)
+	unshared, unsharedErr := repo.GetNoteAccess(context.Background(), testWorkspaceId, testUserId, 3)
+	_, otherErr := repo.GetNoteAccess(context.Background(), testWorkspaceId, testUserId, 4)
+
+	// Assert
+	assert.NoError(t, ownErr)
+	assert.Equal(t, models.RoleOwner, own)
+	assert.NoError(t, sharedErr)
+	assert.Equal(t, models.RoleEditor, shared)
+	assert.NoError(t, unsharedErr)
+	assert.Empty(t, unshared)
+	assert.ErrorIs(t, otherErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_ShareNote(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
+	owner := regexp.QuoteMeta("SELECT user_id FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NULL")
+	user := regexp.QuoteMeta("SELECT id, email FROM users WHERE email = ?")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(owner).WithArgs(1, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(testUserId))
+	mock.ExpectQuery(user).WithArgs("grace@example.com").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(8, "grace@example.com"))
+	mock.ExpectExec("INSERT INTO note_shares").WithArgs(1, 8, models.RoleEditor, "2024-05-02T17:45:30.250Z").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT created_at FROM note_shares WHERE note_id = ? AND user_id = ?")).WithArgs(1, 8).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow("2024-05-01T09:30:00.000Z"))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(owner).WithArgs(1, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(testUserId))
+	mock.ExpectQuery(user).WithArgs("ada@example.com").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(testUserId, "ada@example.com"))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(owner).WithArgs(1, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"user_id"}).AddRow(testUserId))
+	mock.ExpectQuery(user).WithArgs("nobody@example.com").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}))
+	mock.ExpectRollback()
+
+	// Act
+	share, err := repo.ShareNote(context.Background(), testWorkspaceId, 1, "grace@example.com", models.RoleEditor)
+	_, ownerErr := repo.ShareNote(context.Background(), testWorkspaceId, 1, "ada@example.com", models.RoleViewer)
+	_, unknownErr := repo.ShareNote(context.Background(), testWorkspaceId, 1, "nobody@example.com", models.RoleViewer)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Share{UserId: 8, Email: "grace@example.com", Role: models.RoleEditor, CreatedAt: created}, share)
+	assert.ErrorIs(t, ownerErr, ErrShareWithOwner)
+	assert.ErrorIs(t, unknownErr, ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetNoteShares(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	columns := []string{"position", "id", "email", "role", "created_at"}
+
+	mock.ExpectQuery("FROM notes JOIN users .* UNION ALL .* FROM note_shares JOIN users").WithArgs(1, testWorkspaceId, 1, testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(0, testUserId, "ada@example.com", "owner", "2024-05-01T09:30:00.000Z").
+			AddRow(1, 8, "grace@example.com", "viewer", "2024-05-02T17:45:30.250Z"))
+	mock.ExpectQuery("FROM notes JOIN users").WithArgs(2, testWorkspaceId, 2, testWorkspaceId).WillReturnRows(sqlmock.NewRows(columns))
+
+	// Act
+	shares, err := repo.GetNoteShares(context.Background(), testWorkspaceId, 1)
+	_, notFoundErr := repo.GetNoteShares(context.Background(), testWorkspaceId, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.Share{
+		{UserId: testUserId, Email: "ada@example.com", Role: models.RoleOwner, CreatedAt: created},
+		{UserId: 8, Email: "grace@example.com", Role: models.RoleViewer, CreatedAt: updated},
+	}, shares)
+	assert.ErrorIs(t, notFoundErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNotebookRepository_RevokeNotebookShare(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotebooksRepository(db)
+	query := regexp.QuoteMeta("DELETE FROM notebook_shares WHERE notebook_id = ? AND user_id = ? AND notebook_id IN (SELECT id FROM notebooks WHERE workspace_id = ?)")
+
+	mock.ExpectExec(query).WithArgs(4, 8, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs(4, 5, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(query).WithArgs(4, 8, 9).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.RevokeNotebookShare(testWorkspaceId, 4, 8)
+	notFoundErr := repo.RevokeNotebookShare(testWorkspaceId, 4, 5)
+	otherErr := repo.RevokeNotebookShare(9, 4, 8)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, notFoundErr, ErrShareNotFound)
+	assert.ErrorIs(t, otherErr, ErrShareNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
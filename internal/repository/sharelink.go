@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// ErrShareLinkNotFound is returned when a share link is unknown, has
+// expired, has been viewed as often as it allows or its note is in the
+// trash.
+var ErrShareLinkNotFound = errors.New("share link not found")
+
+// shareLinkColumns are the columns scanned by scanShareLink, in order.
+const shareLinkColumns = "share_links.id, share_links.note_id, share_links.prefix, share_links.password_hash IS NOT NULL, " +
+	"share_links.expires_at, share_links.max_views, share_links.views, share_links.created_at"
+
+// shareLinkActive is an SQL condition for share links that can still be
+// viewed at the time bound to its parameter.
+const shareLinkActive = "(share_links.expires_at IS NULL OR share_links.expires_at > ?) " +
+	"AND (share_links.max_views IS NULL OR share_links.views < share_links.max_views)"
+
+// scanShareLink reads a share link selected with shareLinkColumns from row.
+func scanShareLink(row scanner, dest ...interface{}) (*models.ShareLink, error) {
+	link := &models.ShareLink{}
+	var expiresAt sql.NullString
+	var maxViews sql.NullInt64
+	var createdAt string
+	err := row.Scan(append([]interface{}{&link.Id, &link.NoteId, &link.Prefix, &link.HasPassword, &expiresAt, &maxViews, &link.Views, &createdAt}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	if maxViews.Valid {
+		n := int(maxViews.Int64)
+		link.MaxViews = &n
+	}
+	if link.ExpiresAt, err = parseNullTime(expiresAt); err != nil {
+		return nil, err
+	}
+	if link.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	return link, nil
+}
+
+// CreateShareLink stores a share link to a note of a workspace by the hash
+// of its token and of its password, which is empty for links without one,
+// and sets the metadata of link to the values it was stored with.
+// It returns ErrNoteNotFound if the note belongs to another workspace.
+func (r *noteRepository) CreateShareLink(ctx context.Context, workspaceId int, noteId int, link *models.ShareLink, tokenHash string, passwordHash string) (int, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	var password interface{}
+	if passwordHash != "" {
+		password = passwordHash
+	}
+	var id int64
+	err := r.conn(ctx).QueryRowContext(ctx, `INSERT INTO share_links (note_id, prefix, token_hash, password_hash, expires_at, max_views, created_at)
+    SELECT id, ?, ?, ?, ?, ?, ? FROM notes WHERE id = ? AND workspace_id = ?
+    RETURNING id`,
+		link.Prefix, tokenHash, password, formatNullTime(link.ExpiresAt), link.MaxViews, formatTime(now), noteId, workspaceId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, &RepoError{"CreateShareLink", noteId, ErrNoteNotFound}
+	}
+	if err != nil {
+		return 0, &RepoError{"CreateShareLink", noteId, fmt.Errorf("DB Error: %w", err)}
+	}
+	link.Id, link.NoteId, link.HasPassword, link.CreatedAt = int(id), noteId, passwordHash != "", now
+	return link.Id, nil
+}
+
+// GetShareLinks retrieves the share links of a note of a workspace that can
+// still be viewed, newest first.
+func (r *noteRepository) GetShareLinks(ctx context.Context, workspaceId int, noteId int) ([]*models.ShareLink, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT `+shareLinkColumns+` FROM share_links JOIN notes ON notes.id = share_links.note_id
+    WHERE share_links.note_id = ? AND notes.workspace_id = ? AND `+shareLinkActive+` ORDER BY share_links.id DESC`,
+		noteId, workspaceId, formatTime(r.now()))
+	if err != nil {
+		return nil, &RepoError{"GetShareLinks", noteId, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	links := []*models.ShareLink{}
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, &RepoError{"GetShareLinks", noteId, fmt.Errorf("DB Error: %w", err)}
+		}
+		links = append(links, link)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{"GetShareLinks", noteId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return links, nil
+}
+
+// DeleteShareLink revokes a share link of a note of a workspace.
+// It returns ErrShareLinkNotFound if the note has no link with the ID.
+func (r *noteRepository) DeleteShareLink(ctx context.Context, workspaceId int, noteId int, id int) error {
+	result, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM share_links WHERE id = ? AND note_id = ? AND note_id IN (SELECT id FROM notes WHERE workspace_id = ?)",
+		id, noteId, workspaceId)
+	if err != nil {
+		return &RepoError{"DeleteShareLink", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return &RepoError{"DeleteShareLink", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if count == 0 {
+		return &RepoError{"DeleteShareLink", id, ErrShareLinkNotFound}
+	}
+	return nil
+}
+
+// GetShareLink retrieves the share link with the given token hash and the
+// hash of its password, which is empty if it has none.
+// It returns ErrShareLinkNotFound if the link is unknown or can no longer be
+// viewed.
+func (r *noteRepository) GetShareLink(ctx context.Context, tokenHash string) (*models.ShareLink, string, error) {
+	var passwordHash sql.NullString
+	link, err := scanShareLink(r.conn(ctx).QueryRowContext(ctx, `SELECT `+shareLinkColumns+`, share_links.password_hash
+    FROM share_links JOIN notes ON notes.id = share_links.note_id
+    WHERE share_links.token_hash = ? AND notes.deleted_at IS NULL AND `+shareLinkActive, tokenHash, formatTime(r.now())), &passwordHash)
+	if err == sql.ErrNoRows {
+		return nil, "", &RepoError{Src: "GetShareLink", Err: ErrShareLinkNotFound}
+	}
+	if err != nil {
+		return nil, "", &RepoError{Src: "GetShareLink", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return link, passwordHash.String, nil
+}
+
+// ViewShareLink counts a view of a share link and retrieves its note. Views
+// are counted atomically, so a link is never viewed more often than it
+// allows.
+// It returns ErrShareLinkNotFound if the link can no longer be viewed.
+func (r *noteRepository) ViewShareLink(ctx context.Context, id int) (*models.Note, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, &RepoError{"ViewShareLink", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE share_links SET views = views + 1 WHERE id = ? AND "+shareLinkActive, id, formatTime(r.now()))
+	if err != nil {
+		return nil, &RepoError{"ViewShareLink", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return nil, &RepoError{"ViewShareLink", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if count == 0 {
+		return nil, &RepoError{"ViewShareLink", id, ErrShareLinkNotFound}
+	}
+	note, err := scanNote(tx.QueryRowContext(ctx, "SELECT "+noteColumns+" FROM notes WHERE id = (SELECT note_id FROM share_links WHERE id = ?) AND deleted_at IS NULL", id))
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{"ViewShareLink", id, ErrShareLinkNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{"ViewShareLink", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &RepoError{"ViewShareLink", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return note, nil
+}
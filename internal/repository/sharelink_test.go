@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteRepository_CreateShareLink(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return created }
+	maxViews := 5
+	link := &models.ShareLink{Prefix: "abcdefgh", MaxViews: &maxViews}
+
+	mock.ExpectQuery("INSERT INTO share_links").
+		WithArgs("abcdefgh", "hash", "password", nil, &maxViews, "2024-05-01T09:30:00.000Z", 1, testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("INSERT INTO share_links").
+		WithArgs("abcdefgh", "other", nil, nil, &maxViews, "2024-05-01T09:30:00.000Z", 1, testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectQuery("INSERT INTO share_links").
+		WithArgs("abcdefgh", "other", nil, nil, &maxViews, "2024-05-01T09:30:00.000Z", 1, 9).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// Act
+	id, err := repo.CreateShareLink(context.Background(), testWorkspaceId, 1, link, "hash", "password")
+	_, openErr := repo.CreateShareLink(context.Background(), testWorkspaceId, 1, &models.ShareLink{Prefix: "abcdefgh", MaxViews: &maxViews}, "other", "")
+	_, otherErr := repo.CreateShareLink(context.Background(), 9, 1, &models.ShareLink{Prefix: "abcdefgh", MaxViews: &maxViews}, "other", "")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, id)
+	assert.Equal(t, &models.ShareLink{Id: 3, NoteId: 1, Prefix: "abcdefgh", HasPassword: true, MaxViews: &maxViews, CreatedAt: created}, link)
+	assert.NoError(t, openErr)
+	assert.ErrorIs(t, otherErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetShareLink(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
+	columns := []string{"id", "note_id", "prefix", "has_password", "expires_at", "max_views", "views", "created_at", "password_hash"}
+
+	mock.ExpectQuery("FROM share_links JOIN notes").WithArgs("locked", "2024-05-02T17:45:30.250Z").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow(3, 1, "abcdefgh", true, "2024-06-01T00:00:00.000Z", 5, 2, "2024-05-01T09:30:00.000Z", "password"))
+	mock.ExpectQuery("FROM share_links JOIN notes").WithArgs("expired", "2024-05-02T17:45:30.250Z").WillReturnRows(sqlmock.NewRows(columns))
+
+	// Act
+	link, passwordHash, err := repo.GetShareLink(context.Background(), "locked")
+	_, _, expiredErr := repo.GetShareLink(context.Background(), "expired")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, "password", passwordHash)
+	assert.Equal(t, 3, link.Id)
+	assert.True(t, link.HasPassword)
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), *link.ExpiresAt)
+	assert.Equal(t, 5, *link.MaxViews)
+	assert.Equal(t, 2, link.Views)
+	assert.ErrorIs(t, expiredErr, ErrShareLinkNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_ViewShareLink(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
+	note := &models.Note{Id: 1, Title: "Shared", Content: "Shared note", CreatedAt: created, UpdatedAt: updated, Version: 1, Tags: []string{"work"}}
+	count := regexp.QuoteMeta("UPDATE share_links SET views = views + 1 WHERE id = ?")
+
+	mock.ExpectBegin()
+	mock.ExpectExec(count).WithArgs(3, "2024-05-02T17:45:30.250Z").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM notes WHERE id = (SELECT note_id FROM share_links WHERE id = ?) AND deleted_at IS NULL")).
+		WithArgs(3).WillReturnRows(noteRows(note))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec(count).WithArgs(4, "2024-05-02T17:45:30.250Z").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	// Act
+	viewed, err := repo.ViewShareLink(context.Background(), 3)
+	_, usedUpErr := repo.ViewShareLink(context.Background(), 4)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, note, viewed)
+	assert.ErrorIs(t, usedUpErr, ErrShareLinkNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
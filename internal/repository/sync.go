@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// ErrClientIdTaken is returned when a note is given a client ID that the
+// user already gave another note of the workspace.
+var ErrClientIdTaken = errors.New("client id is taken")
+
+// GetChanges retrieves up to limit changes to the notes of a workspace after
+// the sequence number since, in order. Notes in the trash and purged notes
+// are returned as tombstones. Every note appears at most once, with its
+// latest change.
+func (r *noteRepository) GetChanges(ctx context.Context, workspaceId int, since int64, limit int) (*models.ChangePage, error) {
+	// Fetch one extra change to find out whether there is another page.
+	rows, err := r.conn(ctx).QueryContext(ctx, "SELECT "+noteColumns+", seq FROM notes WHERE workspace_id = ? AND seq > ? ORDER BY seq LIMIT ?", workspaceId, since, limit+1)
+	if err != nil {
+		return nil, &RepoError{Src: "GetChanges", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	notes := []*models.Change{}
+	for rows.Next() {
+		change := &models.Change{}
+		note, err := scanNote(rows, &change.Seq)
+		if err != nil {
+			return nil, &RepoError{Src: "GetChanges", Err: fmt.Errorf("Error Scanning: %w", err)}
+		}
+		change.NoteId = note.Id
+		if note.DeletedAt != nil {
+			change.Deleted, change.DeletedAt = true, note.DeletedAt
+		} else {
+			change.Note = note
+		}
+		notes = append(notes, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetChanges", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+
+	tombstones, err := r.getTombstones(ctx, workspaceId, since, limit+1)
+	if err != nil {
+		return nil, &RepoError{Src: "GetChanges", Err: err}
+	}
+
+	page := &models.ChangePage{Changes: mergeChanges(notes, tombstones), Seq: since}
+	if len(page.Changes) > limit {
+		page.Changes = page.Changes[:limit]
+		page.HasMore = true
+	}
+	if len(page.Changes) > 0 {
+		page.Seq = page.Changes[len(page.Changes)-1].Seq
+	}
+	return page, nil
+}
+
+// GetPushedNote retrieves the note of a workspace that a user created with
+// a pushed change with clientId, also if it is in the trash.
+// It returns ErrNoteNotFound if there is no such note, for example because
+// it was purged.
+func (r *noteRepository) GetPushedNote(ctx context.Context, workspaceId int, userId int, clientId string) (*models.Note, error) {
+	row := r.conn(ctx).QueryRowContext(ctx, "SELECT "+noteColumns+" FROM notes WHERE workspace_id = ? AND user_id = ? AND client_id = ?", workspaceId, userId, clientId)
+	note, err := scanNote(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &RepoError{Src: "GetPushedNote", Err: fmt.Errorf("%w: %v", ErrNoteNotFound, err)}
+		}
+		return nil, &RepoError{Src: "GetPushedNote", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return note, nil
+}
+
+// SetClientId stores the client ID of a note of a workspace that was created
+// with a pushed change. Client IDs are unique per user and workspace.
+// It returns ErrNoteNotFound if the note is not found and ErrClientIdTaken if
+// the user of the note already gave the client ID to another note.
+func (r *noteRepository) SetClientId(ctx context.Context, workspaceId int, id int, clientId string) error {
+	res, err := r.conn(ctx).ExecContext(ctx, "UPDATE notes SET client_id = ? WHERE id = ? AND workspace_id = ?", clientId, id, workspaceId)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return &RepoError{"SetClientId", id, ErrClientIdTaken}
+		}
+		return &RepoError{"SetClientId", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"SetClientId", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		return &RepoError{"SetClientId", id, ErrNoteNotFound}
+	}
+	return nil
+}
+
+// getTombstones retrieves up to limit tombstones of purged notes of a
+// workspace after the sequence number since, in order.
+func (r *noteRepository) getTombstones(ctx context.Context, workspaceId int, since int64, limit int) ([]*models.Change, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, "SELECT note_id, seq, deleted_at FROM note_tombstones WHERE workspace_id = ? AND seq > ? ORDER BY seq LIMIT ?", workspaceId, since, limit)
+	if err != nil {
+		return nil, fmt.Errorf("DB Error: %w", err)
+	}
+	defer rows.Close()
+
+	tombstones := []*models.Change{}
+	for rows.Next() {
+		change := &models.Change{Deleted: true}
+		var deletedAt string
+		if err := rows.Scan(&change.NoteId, &change.Seq, &deletedAt); err != nil {
+			return nil, fmt.Errorf("Error Scanning: %w", err)
+		}
+		t, err := time.Parse(TimeFormat, deletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("Error Scanning: %w", err)
+		}
+		change.DeletedAt = &t
+		tombstones = append(tombstones, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("DB Error: %w", err)
+	}
+	return tombstones, nil
+}
+
+// mergeChanges merges two lists of changes ordered by sequence number into
+// one.
+func mergeChanges(a []*models.Change, b []*models.Change) []*models.Change {
+	merged := make([]*models.Change, 0, len(a)+len(b))
+	for len(a) > 0 && len(b) > 0 {
+		if a[0].Seq < b[0].Seq {
+			merged, a = append(merged, a[0]), a[1:]
+		} else {
+			merged, b = append(merged, b[0]), b[1:]
+		}
+	}
+	merged = append(merged, a...)
+	return append(merged, b...)
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// changeRows returns the rows the query of GetChanges yields for notes,
+// numbered with seqs.
+func changeRows(seqs []int64, notes ...*models.Note) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "title", "content", "created_at", "updated_at", "version", "deleted_at", "notebook_id", "tags", "seq"})
+	for i, note := range notes {
+		var deletedAt interface{}
+		if note.DeletedAt != nil {
+			deletedAt = formatTime(*note.DeletedAt)
+		}
+		rows.AddRow(note.Id, note.Title, note.Content, formatTime(note.CreatedAt), formatTime(note.UpdatedAt), note.Version, deletedAt, nil, nil, seqs[i])
+	}
+	return rows
+}
+
+func TestNoteRepository_GetChanges(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	deleted := updated.Add(time.Hour)
+	purged := deleted.Add(time.Hour)
+	changed := &models.Note{Id: 1, Title: "Changed", Content: "Changed", CreatedAt: created, UpdatedAt: updated, Version: 2}
+	trashed := &models.Note{Id: 2, Title: "Trashed", Content: "Trashed", CreatedAt: created, UpdatedAt: updated, Version: 3, DeletedAt: &deleted}
+	added := &models.Note{Id: 4, Title: "Added", Content: "Added", CreatedAt: created, UpdatedAt: updated, Version: 1}
+
+	notesQuery := regexp.QuoteMeta("SELECT " + noteColumns + ", seq FROM notes WHERE workspace_id = ? AND seq > ? ORDER BY seq LIMIT ?")
+	tombstonesQuery := regexp.QuoteMeta("SELECT note_id, seq, deleted_at FROM note_tombstones WHERE workspace_id = ? AND seq > ? ORDER BY seq LIMIT ?")
+	mock.ExpectQuery(notesQuery).WithArgs(testWorkspaceId, 10, 4).
+		WillReturnRows(changeRows([]int64{11, 14, 16}, changed, trashed, added))
+	mock.ExpectQuery(tombstonesQuery).WithArgs(testWorkspaceId, 10, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"note_id", "seq", "deleted_at"}).AddRow(3, 12, formatTime(purged)).AddRow(5, 17, formatTime(purged)))
+	mock.ExpectQuery(notesQuery).WithArgs(testWorkspaceId, 17, 4).WillReturnRows(changeRows(nil))
+	mock.ExpectQuery(tombstonesQuery).WithArgs(testWorkspaceId, 17, 4).
+		WillReturnRows(sqlmock.NewRows([]string{"note_id", "seq", "deleted_at"}))
+
+	// Act
+	page, err := repo.GetChanges(context.Background(), testWorkspaceId, 10, 3)
+	empty, emptyErr := repo.GetChanges(context.Background(), testWorkspaceId, 17, 3)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.Change{
+		{Seq: 11, NoteId: 1, Note: changed},
+		{Seq: 12, NoteId: 3, Deleted: true, DeletedAt: &purged},
+		{Seq: 14, NoteId: 2, Deleted: true, DeletedAt: &deleted},
+	}, page.Changes)
+	assert.Equal(t, int64(14), page.Seq)
+	assert.True(t, page.HasMore)
+	assert.NoError(t, emptyErr)
+	assert.Empty(t, empty.Changes)
+	assert.Equal(t, int64(17), empty.Seq)
+	assert.False(t, empty.HasMore)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetPushedNote(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	pushed := &models.Note{Id: 10, Title: "New", Content: "Written offline", CreatedAt: created, UpdatedAt: created, Version: 1}
+	query := regexp.QuoteMeta("SELECT " + noteColumns + " FROM notes WHERE workspace_id = ? AND user_id = ? AND client_id = ?")
+	mock.ExpectQuery(query).WithArgs(testWorkspaceId, 7, "offline-1").WillReturnRows(noteRows(pushed))
+	mock.ExpectQuery(query).WithArgs(testWorkspaceId, 7, "offline-2").WillReturnRows(noteRows())
+
+	// Act
+	note, err := repo.GetPushedNote(context.Background(), testWorkspaceId, 7, "offline-1")
+	_, unknownErr := repo.GetPushedNote(context.Background(), testWorkspaceId, 7, "offline-2")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, pushed, note)
+	assert.ErrorIs(t, unknownErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_SetClientId(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	query := regexp.QuoteMeta("UPDATE notes SET client_id = ? WHERE id = ? AND workspace_id = ?")
+	mock.ExpectExec(query).WithArgs("offline-1", 10, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("offline-1", 11, testWorkspaceId).
+		WillReturnError(errors.New("UNIQUE constraint failed: notes.workspace_id, notes.user_id, notes.client_id"))
+	mock.ExpectExec(query).WithArgs("offline-2", 12, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.SetClientId(context.Background(), testWorkspaceId, 10, "offline-1")
+	takenErr := repo.SetClientId(context.Background(), testWorkspaceId, 11, "offline-1")
+	missingErr := repo.SetClientId(context.Background(), testWorkspaceId, 12, "offline-2")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, takenErr, ErrClientIdTaken)
+	assert.ErrorIs(t, missingErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+var (
+	// ErrTagNotFound is returned when no note carries a tag with the given
+	// name.
+	ErrTagNotFound = errors.New("tag not found")
+	// ErrTagExists is returned when a tag is renamed to the name of another
+	// tag.
+	ErrTagExists = errors.New("tag already exists")
+)
+
+// tagsColumn selects the names of the tags of a note, separated by
+// tagSeparator, or NULL if it has none.
+const tagsColumn = "(SELECT group_concat(tags.name, '" + tagSeparator + "') FROM note_tags JOIN tags ON tags.id = note_tags.tag_id WHERE note_tags.note_id = notes.id)"
+
+// tagSeparator separates the tag names selected by tagsColumn. Tag names
+// cannot contain it.
+const tagSeparator = "\x1f"
+
+// splitTags returns the sorted tag names selected by tagsColumn.
+func splitTags(tags sql.NullString) []string {
+	if !tags.Valid || tags.String == "" {
+		return nil
+	}
+	names := strings.Split(tags.String, tagSeparator)
+	sort.Strings(names)
+	return names
+}
+
+// tagFilter returns the condition and arguments selecting the notes that
+// carry all or, if mode is TagModeAny, any of tags.
+func tagFilter(tags []string, mode models.TagMode) (string, []interface{}) {
+	args := make([]interface{}, 0, len(tags)+1)
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	cond := "id IN (SELECT note_tags.note_id FROM note_tags JOIN tags ON tags.id = note_tags.tag_id WHERE tags.name IN (?" +
+		strings.Repeat(", ?", len(tags)-1) + ")"
+	if mode != models.TagModeAny {
+		cond += " GROUP BY note_tags.note_id HAVING count(*) = ?"
+		args = append(args, len(tags))
+	}
+	return cond + ")", args
+}
+
+// setTags replaces the tags of note id of a workspace with tags and removes the
+// tags that no note carries any more.
+func setTags(ctx context.Context, tx querier, workspaceId int, id int, tags []string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM note_tags WHERE note_id = ?", id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO tags (workspace_id, name) VALUES (?, ?) ON CONFLICT (workspace_id, name) DO NOTHING", workspaceId, tag); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO note_tags (note_id, tag_id) SELECT ?, id FROM tags WHERE workspace_id = ? AND name = ?", id, workspaceId, tag); err != nil {
+			return err
+		}
+	}
+	_, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE NOT EXISTS (SELECT 1 FROM note_tags WHERE note_tags.tag_id = tags.id)")
+	return err
+}
+
+// GetTags retrieves the tags of a workspace carried by notes outside the trash
+// along with their usage counts, ordered by name.
+func (r *noteRepository) GetTags(ctx context.Context, workspaceId int) ([]*models.Tag, error) {
+	rows, err := r.conn(ctx).QueryContext(ctx, `SELECT tags.name, count(*) FROM tags
+    JOIN note_tags ON note_tags.tag_id = tags.id
+    JOIN notes ON notes.id = note_tags.note_id
+    WHERE tags.workspace_id = ? AND notes.deleted_at IS NULL
+    GROUP BY tags.id
+    ORDER BY tags.name`, workspaceId)
+	if err != nil {
+		return nil, &RepoError{Src: "GetTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	tags := []*models.Tag{}
+	for rows.Next() {
+		tag := &models.Tag{}
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, &RepoError{Src: "GetTags", Err: fmt.Errorf("Error Scanning: %w", err)}
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return tags, nil
+}
+
+// RenameTag renames a tag of a workspace on all notes carrying it, bumping their
+// update time and version, and returns the number of notes changed.
+// It returns ErrTagNotFound if there is no tag with the name and
+// ErrTagExists if there already is a tag with the new name.
+func (r *noteRepository) RenameTag(ctx context.Context, workspaceId int, name string, newName string) (int, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return 0, &RepoError{Src: "RenameTag", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	id, err := tagId(ctx, tx, workspaceId, name)
+	if err != nil {
+		return 0, &RepoError{Src: "RenameTag", Err: err}
+	}
+	if _, err := tagId(ctx, tx, workspaceId, newName); err == nil {
+		return 0, &RepoError{Src: "RenameTag", Err: fmt.Errorf("%w: %q", ErrTagExists, newName)}
+	} else if !errors.Is(err, ErrTagNotFound) {
+		return 0, &RepoError{Src: "RenameTag", Err: err}
+	}
+
+	n, err := r.touchTagged(ctx, tx, id)
+	if err != nil {
+		return 0, &RepoError{Src: "RenameTag", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE tags SET name = ? WHERE id = ?", newName, id); err != nil {
+		return 0, &RepoError{Src: "RenameTag", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, &RepoError{Src: "RenameTag", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return n, nil
+}
+
+// MergeTags replaces the source tag of a workspace with its target tag on all
+// notes carrying it, bumping their update time and version, and removes the
+// source tag. It returns the number of notes changed.
+// It returns ErrTagNotFound if either tag does not exist.
+func (r *noteRepository) MergeTags(ctx context.Context, workspaceId int, source string, target string) (int, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	sourceId, err := tagId(ctx, tx, workspaceId, source)
+	if err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: err}
+	}
+	targetId, err := tagId(ctx, tx, workspaceId, target)
+	if err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: err}
+	}
+
+	n, err := r.touchTagged(ctx, tx, sourceId)
+	if err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO note_tags (note_id, tag_id) SELECT note_id, ? FROM note_tags WHERE tag_id = ?
+    ON CONFLICT (note_id, tag_id) DO NOTHING`, targetId, sourceId)
+	if err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM note_tags WHERE tag_id = ?", sourceId); err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE id = ?", sourceId); err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, &RepoError{Src: "MergeTags", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return n, nil
+}
+
+// tagId looks up the ID of the tag of a workspace with the given name.
+// It returns ErrTagNotFound if there is none.
+func tagId(ctx context.Context, tx querier, workspaceId int, name string) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM tags WHERE workspace_id = ? AND name = ?", workspaceId, name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("%w: %q", ErrTagNotFound, name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("DB Error: %w", err)
+	}
+	return id, nil
+}
+
+// touchTagged bumps the update time and version of the notes carrying tag
+// id, as their tags are about to change, and returns how many there are.
+func (r *noteRepository) touchTagged(ctx context.Context, tx querier, id int) (int, error) {
+	res, err := tx.ExecContext(ctx, `UPDATE notes SET updated_at = ?, version = version + 1
+    WHERE id IN (SELECT note_id FROM note_tags WHERE tag_id = ?)`, formatTime(r.now()), id)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteRepository_CreateNoteWithTags(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.MaxRevisions = 0
+	note := &models.Note{Title: "Title", Content: "Content", Tags: []string{"home", "work"}}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO notes").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(7))
+	mock.ExpectExec("DELETE FROM note_tags WHERE note_id = ?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, tag := range note.Tags {
+		mock.ExpectExec("INSERT INTO tags").WithArgs(testWorkspaceId, tag).WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO note_tags").WithArgs(7, testWorkspaceId, tag).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectExec("DELETE FROM tags WHERE NOT EXISTS").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO note_revisions").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoWebhooks(mock, models.EventNoteCreated)
+	mock.ExpectCommit()
+
+	// Act
+	id, err := repo.Create(context.Background(), testWorkspaceId, testUserId, note)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 7, id)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetAllNotesByTag(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	note := &models.Note{Id: 1, Title: "Title", Content: "Content", Tags: []string{"home", "work"}, CreatedAt: created, UpdatedAt: created, Version: 1}
+
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE workspace_id = ? AND deleted_at IS NULL AND id IN (SELECT note_tags.note_id FROM note_tags JOIN tags ON tags.id = note_tags.tag_id WHERE tags.name IN (?, ?) GROUP BY note_tags.note_id HAVING count(*) = ?) ORDER BY id ASC")).
+		WithArgs(testWorkspaceId, "home", "work", 2, 11).WillReturnRows(noteRows(note))
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE workspace_id = ? AND deleted_at IS NULL AND id IN (SELECT note_tags.note_id FROM note_tags JOIN tags ON tags.id = note_tags.tag_id WHERE tags.name IN (?, ?)) ORDER BY id ASC")).
+		WithArgs(testWorkspaceId, "home", "work", 11).WillReturnRows(noteRows(note))
+
+	// Act
+	allTags, allErr := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 10, Tags: []string{"home", "work"}, TagMode: models.TagModeAll})
+	anyTag, anyErr := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 10, Tags: []string{"home", "work"}, TagMode: models.TagModeAny})
+
+	// Assert
+	assert.NoError(t, allErr)
+	assert.Equal(t, []*models.Note{note}, allTags.Notes)
+	assert.NoError(t, anyErr)
+	assert.Equal(t, []*models.Note{note}, anyTag.Notes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_GetTags(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectQuery("SELECT tags.name, count\\(\\*\\) FROM tags").WithArgs(testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "count"}).AddRow("home", 2).AddRow("work", 5))
+
+	// Act
+	tags, err := repo.GetTags(context.Background(), testWorkspaceId)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.Tag{{Name: "home", Count: 2}, {Name: "work", Count: 5}}, tags)
+}
+
+func TestNoteRepository_RenameTag(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM tags").WithArgs(testWorkspaceId, "wrk").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT id FROM tags").WithArgs(testWorkspaceId, "work").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET updated_at = ?, version = version + 1")).
+		WithArgs("2024-05-02T17:45:30.250Z", 3).WillReturnResult(sqlmock.NewResult(0, 4))
+	mock.ExpectExec("UPDATE tags SET name").WithArgs("work", 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Act
+	n, err := repo.RenameTag(context.Background(), testWorkspaceId, "wrk", "work")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 4, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_RenameTagErrors(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM tags").WithArgs(testWorkspaceId, "missing").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM tags").WithArgs(testWorkspaceId, "home").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("SELECT id FROM tags").WithArgs(testWorkspaceId, "work").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectRollback()
+
+	// Act
+	_, notFoundErr := repo.RenameTag(context.Background(), testWorkspaceId, "missing", "other")
+	_, existsErr := repo.RenameTag(context.Background(), testWorkspaceId, "home", "work")
+
+	// Assert
+	assert.ErrorIs(t, notFoundErr, ErrTagNotFound)
+	assert.ErrorIs(t, existsErr, ErrTagExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_MergeTags(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM tags").WithArgs(testWorkspaceId, "wrk").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(3))
+	mock.ExpectQuery("SELECT id FROM tags").WithArgs(testWorkspaceId, "work").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectExec("UPDATE notes SET updated_at").WithArgs(sqlmock.AnyArg(), 3).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("INSERT INTO note_tags").WithArgs(2, 3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM note_tags WHERE tag_id = ?").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("DELETE FROM tags WHERE id = ?").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Act
+	n, err := repo.MergeTags(context.Background(), testWorkspaceId, "wrk", "work")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// Restore moves a note of a workspace out of the trash, bumping its version,
+// queues its webhook deliveries and returns the restored note.
+// It returns ErrNoteNotFound if the note is not in the trash.
+func (r *noteRepository) Restore(ctx context.Context, workspaceId int, id int) (*models.Note, error) {
+	tx, err := beginTx(ctx, r.db)
+	if err != nil {
+		return nil, &RepoError{"RestoreNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	row := tx.QueryRowContext(ctx, `UPDATE notes SET deleted_at = NULL, version = version + 1
+    WHERE id = ? AND workspace_id = ? AND deleted_at IS NOT NULL
+    RETURNING `+noteColumns, id, workspaceId)
+	note, err := scanNote(row)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{"RestoreNoteByID", id, ErrNoteNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{"RestoreNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	// The note reappears in the lists of notes like a new one.
+	if err := enqueueEvent(ctx, tx, workspaceId, models.EventNoteCreated, id, r.now()); err != nil {
+		return nil, &RepoError{"RestoreNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &RepoError{"RestoreNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return note, nil
+}
+
+// Purge permanently removes a note of a workspace from the trash.
+// It returns ErrNoteNotFound if the note is not in the trash.
+func (r *noteRepository) Purge(ctx context.Context, workspaceId int, id int) error {
+	res, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NOT NULL", id, workspaceId)
+	if err != nil {
+		return &RepoError{"PurgeNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"PurgeNoteByID", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		return &RepoError{"PurgeNoteByID", id, ErrNoteNotFound}
+	}
+	return nil
+}
+
+// PurgeDeletedBefore permanently removes the notes of all users that were
+// moved to the trash before t and returns how many were removed.
+func (r *noteRepository) PurgeDeletedBefore(ctx context.Context, t time.Time) (int, error) {
+	res, err := r.conn(ctx).ExecContext(ctx, "DELETE FROM notes WHERE deleted_at IS NOT NULL AND deleted_at < ?", formatTime(t))
+	if err != nil {
+		return 0, &RepoError{Src: "PurgeDeletedNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, &RepoError{Src: "PurgeDeletedNotes", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return int(n), nil
+}
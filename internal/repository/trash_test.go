@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoteRepository_GetTrash(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	deleted := updated.Add(time.Hour)
+	note := &models.Note{Id: 1, Title: "Deleted", Content: "Deleted", CreatedAt: created, UpdatedAt: updated, Version: 3, DeletedAt: &deleted}
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT "+noteColumns+" FROM notes WHERE workspace_id = ? AND deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC LIMIT ?")).
+		WithArgs(testWorkspaceId, 11).WillReturnRows(noteRows(note))
+
+	// Act
+	res, err := repo.GetAll(context.Background(), testWorkspaceId, models.ListOptions{Limit: 10, Sort: models.SortByDeletedAt, Desc: true, Trashed: true})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.Note{note}, res.Notes)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_RestoreNoteById(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	note := &models.Note{Id: 1, Title: "Restored", Content: "Restored", CreatedAt: created, UpdatedAt: updated, Version: 4}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("UPDATE notes SET deleted_at = NULL, version = version + 1")).
+		WithArgs(1, testWorkspaceId).WillReturnRows(noteRows(note))
+	expectNoWebhooks(mock, models.EventNoteCreated)
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE notes SET deleted_at = NULL").
+		WithArgs(2, testWorkspaceId).WillReturnRows(noteRows())
+	mock.ExpectRollback()
+
+	// Act
+	restored, err := repo.Restore(context.Background(), testWorkspaceId, 1)
+	_, notFoundErr := repo.Restore(context.Background(), testWorkspaceId, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, note, restored)
+	assert.ErrorIs(t, notFoundErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_PurgeNoteById(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM notes WHERE id = ? AND workspace_id = ? AND deleted_at IS NOT NULL")).
+		WithArgs(1, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM notes").WithArgs(2, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.Purge(context.Background(), testWorkspaceId, 1)
+	notFoundErr := repo.Purge(context.Background(), testWorkspaceId, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, notFoundErr, ErrNoteNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_PurgeDeletedBefore(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM notes WHERE deleted_at IS NOT NULL AND deleted_at < ?")).
+		WithArgs("2024-05-02T17:45:30.250Z").WillReturnResult(sqlmock.NewResult(0, 3))
+
+	// Act
+	n, err := repo.PurgeDeletedBefore(context.Background(), updated)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// querier runs queries on a database, within a transaction or outside of
+// one.
+type querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// unitOfWorkKey is the context key of the *unitOfWork of a context.
+type unitOfWorkKey struct{}
+
+// unitOfWork is the transaction a sqlTxManager runs a unit of work in. It
+// is carried by the context of the unit of work so that the repositories
+// called with it run their queries in the transaction.
+type unitOfWork struct {
+	db *sql.DB
+	tx *sql.Tx
+	// savepoints is the number of savepoints created in tx so far, which
+	// names the next one.
+	savepoints int
+}
+
+// getUnitOfWork returns the unit of work ctx carries on db, or nil if it
+// carries none.
+func getUnitOfWork(ctx context.Context, db *sql.DB) *unitOfWork {
+	if uow, ok := ctx.Value(unitOfWorkKey{}).(*unitOfWork); ok && uow.db == db {
+		return uow
+	}
+	return nil
+}
+
+// conn returns the transaction of the unit of work ctx carries on db, or db
+// if it carries none.
+func conn(ctx context.Context, db *sql.DB) querier {
+	if uow := getUnitOfWork(ctx, db); uow != nil {
+		return uow.tx
+	}
+	return db
+}
+
+// dbTx is a transaction begun with beginTx. Within a unit of work it is a
+// savepoint of the transaction of the unit of work, so that rolling it back
+// only undoes its own changes and committing it leaves them to be committed
+// with the unit of work. Like a *sql.Tx, it can be rolled back after it was
+// committed, which does nothing.
+type dbTx struct {
+	*sql.Tx
+	savepoint string
+	done      bool
+}
+
+// beginTx begins a transaction on db, or a savepoint if ctx carries a unit
+// of work on db.
+func beginTx(ctx context.Context, db *sql.DB) (*dbTx, error) {
+	uow := getUnitOfWork(ctx, db)
+	if uow == nil {
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &dbTx{Tx: tx}, nil
+	}
+	uow.savepoints++
+	name := fmt.Sprintf("sp%d", uow.savepoints)
+	if _, err := uow.tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return nil, err
+	}
+	return &dbTx{Tx: uow.tx, savepoint: name}, nil
+}
+
+// Commit commits the transaction or releases the savepoint.
+func (t *dbTx) Commit() error {
+	if t.savepoint == "" {
+		return t.Tx.Commit()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	_, err := t.Tx.Exec("RELEASE SAVEPOINT " + t.savepoint)
+	return err
+}
+
+// Rollback rolls the transaction back, or the transaction of the unit of
+// work back to the savepoint.
+func (t *dbTx) Rollback() error {
+	if t.savepoint == "" {
+		return t.Tx.Rollback()
+	}
+	if t.done {
+		return sql.ErrTxDone
+	}
+	t.done = true
+	// Rolling back to a savepoint keeps it, so it is released as well.
+	if _, err := t.Tx.Exec("ROLLBACK TO SAVEPOINT " + t.savepoint); err != nil {
+		return err
+	}
+	_, err := t.Tx.Exec("RELEASE SAVEPOINT " + t.savepoint)
+	return err
+}
+
+// sqlTxManager implements the TxManager interface on a database.
+type sqlTxManager struct {
+	db *sql.DB
+}
+
+// NewTxManager creates a new sqlTxManager for the repositories on db.
+func NewTxManager(db *sql.DB) *sqlTxManager {
+	return &sqlTxManager{db: db}
+}
+
+// WithinTx runs fn in a transaction that is committed if fn returns nil and
+// rolled back if it returns an error or panics. Called within the unit of
+// work of another WithinTx, it runs fn in a savepoint of its transaction
+// instead, so that only the changes of fn are rolled back.
+// The repository calls fn makes with the context it is given run in the
+// transaction. A unit of work must not be used by several goroutines at
+// once.
+func (m *sqlTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	tx, err := beginTx(ctx, m.db)
+	if err != nil {
+		return &RepoError{Src: "WithinTx", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if tx.savepoint == "" {
+		ctx = context.WithValue(ctx, unitOfWorkKey{}, &unitOfWork{db: m.db, tx: tx.Tx})
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	committed = true
+	if err := tx.Commit(); err != nil {
+		return &RepoError{Src: "WithinTx", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxManager_WithinTxCommits(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("UPDATE notes SET deleted_at").WithArgs("2024-05-02T17:45:30.250Z", 1, testWorkspaceId, 0, 0).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectNoWebhooks(mock, models.EventNoteDeleted)
+	mock.ExpectExec("RELEASE SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// Act
+	err = NewTxManager(db).WithinTx(context.Background(), func(ctx context.Context) error {
+		return repo.Delete(ctx, testWorkspaceId, 1, 0)
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_WithinTxRollsBack(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+	failure := errors.New("failure")
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	// Act
+	err = NewTxManager(db).WithinTx(context.Background(), func(ctx context.Context) error {
+		return failure
+	})
+	panicked := func() {
+		NewTxManager(db).WithinTx(context.Background(), func(ctx context.Context) error {
+			panic(failure)
+		})
+	}
+
+	// Assert
+	assert.ErrorIs(t, err, failure)
+	assert.PanicsWithValue(t, failure, panicked)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTxManager_WithinTxNested(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+	failure := errors.New("failure")
+	manager := NewTxManager(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT sp2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT sp2").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	// Act
+	var innerErr, keptErr error
+	err = manager.WithinTx(context.Background(), func(ctx context.Context) error {
+		innerErr = manager.WithinTx(ctx, func(ctx context.Context) error {
+			return failure
+		})
+		keptErr = manager.WithinTx(ctx, func(ctx context.Context) error {
+			return nil
+		})
+		return nil
+	})
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, innerErr, failure)
+	assert.NoError(t, keptErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+var (
+	// ErrUserNotFound is returned when a user with the given email is not
+	// found in the database.
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when a user registers with the email of
+	// another user.
+	ErrUserExists = errors.New("user already exists")
+	// ErrSessionNotFound is returned when a session token is unknown or
+	// expired.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// userColumns are the columns scanned by scanUser, in order.
+const userColumns = "users.id, users.email, users.password_hash, users.created_at"
+
+// scanUser reads a user selected with userColumns from row.
+func scanUser(row scanner) (*models.User, error) {
+	user := &models.User{}
+	var createdAt string
+	if err := row.Scan(&user.Id, &user.Email, &user.PasswordHash, &createdAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if user.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// userRepository implements the UserRepository interface.
+type userRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewUsersRepository creates a new userRepository.
+func NewUsersRepository(db *sql.DB) *userRepository {
+	return &userRepository{db: db, now: time.Now}
+}
+
+// Create adds a new user to the database along with their personal
+// workspace and sets its creation time. The notes, notebooks and tags
+// created before there were users are left alone; they are only adopted
+// with AdoptLegacyData.
+// It returns ErrUserExists if there already is a user with the email,
+// compared case-insensitively.
+func (r *userRepository) Create(user *models.User) (int, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, &RepoError{Src: "CreateUser", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("INSERT INTO users (email, password_hash, created_at) VALUES (?, ?, ?) RETURNING id",
+		user.Email, user.PasswordHash, formatTime(now)).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return 0, &RepoError{Src: "CreateUser", Err: ErrUserExists}
+		}
+		return 0, &RepoError{Src: "CreateUser", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+
+	if _, err := createWorkspace(tx, int(id), "Personal", true, now); err != nil {
+		return 0, &RepoError{"CreateUser", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, &RepoError{"CreateUser", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	user.Id, user.CreatedAt = int(id), now
+	return int(id), nil
+}
+
+// LegacyData counts the notes, notebooks and tags created before there were
+// users.
+type LegacyData struct {
+	Notes     int
+	Notebooks int
+	Tags      int
+}
+
+// AdoptLegacyData moves the notes, notebooks and tags created before there
+// were users into the personal workspace of the user with the given email,
+// compared case-insensitively, and returns how many there were. With dryRun
+// set they are only counted. Registering never adopts them, since whoever
+// registers first would get them; the operator runs this instead.
+// It returns ErrUserNotFound if there is no such user.
+func (r *userRepository) AdoptLegacyData(email string, dryRun bool) (*LegacyData, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, &RepoError{Src: "AdoptLegacyData", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	var userId, workspaceId int
+	err = tx.QueryRow(`SELECT users.id, workspaces.id FROM users
+    JOIN workspaces ON workspaces.personal_user_id = users.id
+    WHERE users.email = ?`, email).Scan(&userId, &workspaceId)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{Src: "AdoptLegacyData", Err: ErrUserNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{Src: "AdoptLegacyData", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+
+	adopted := &LegacyData{}
+	for _, table := range []struct {
+		update string
+		args   []interface{}
+		n      *int
+	}{
+		{"UPDATE notes SET user_id = ?, workspace_id = ? WHERE user_id IS NULL", []interface{}{userId, workspaceId}, &adopted.Notes},
+		{"UPDATE notebooks SET user_id = ?, workspace_id = ? WHERE user_id IS NULL", []interface{}{userId, workspaceId}, &adopted.Notebooks},
+		{"UPDATE tags SET workspace_id = ? WHERE workspace_id IS NULL", []interface{}{workspaceId}, &adopted.Tags},
+	} {
+		res, err := tx.Exec(table.update, table.args...)
+		if err != nil {
+			return nil, &RepoError{"AdoptLegacyData", userId, fmt.Errorf("DB Error: %w", err)}
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, &RepoError{"AdoptLegacyData", userId, fmt.Errorf("DB Error: %w", err)}
+		}
+		*table.n = int(n)
+	}
+	if dryRun {
+		return adopted, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &RepoError{"AdoptLegacyData", userId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return adopted, nil
+}
+
+// GetByEmail retrieves the user with the given email, compared
+// case-insensitively.
+// It returns ErrUserNotFound if there is no such user.
+func (r *userRepository) GetByEmail(email string) (*models.User, error) {
+	user, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email))
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{Src: "GetUserByEmail", Err: ErrUserNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{Src: "GetUserByEmail", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return user, nil
+}
+
+// CreateSession stores a session of a user by the hash of its token and
+// removes the expired sessions of the user.
+func (r *userRepository) CreateSession(userId int, tokenHash string, expiresAt time.Time) error {
+	now := formatTime(r.now())
+	tx, err := r.db.Begin()
+	if err != nil {
+		return &RepoError{"CreateSession", userId, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ? AND expires_at <= ?", userId, now); err != nil {
+		return &RepoError{"CreateSession", userId, fmt.Errorf("DB Error: %w", err)}
+	}
+	_, err = tx.Exec("INSERT INTO sessions (token_hash, user_id, created_at, expires_at) VALUES (?, ?, ?, ?)",
+		tokenHash, userId, now, formatTime(expiresAt))
+	if err != nil {
+		return &RepoError{"CreateSession", userId, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"CreateSession", userId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return nil
+}
+
+// GetSessionUser retrieves the user of the session stored under tokenHash.
+// It returns ErrSessionNotFound if there is no such session or it has
+// expired.
+func (r *userRepository) GetSessionUser(tokenHash string) (*models.User, error) {
+	row := r.db.QueryRow(`SELECT `+userColumns+` FROM sessions JOIN users ON users.id = sessions.user_id
+    WHERE sessions.token_hash = ? AND sessions.expires_at > ?`, tokenHash, formatTime(r.now()))
+	user, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{Src: "GetSessionUser", Err: ErrSessionNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{Src: "GetSessionUser", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return user, nil
+}
+
+// DeleteSession removes the session stored under tokenHash, so its token
+// can no longer be used.
+// It returns ErrSessionNotFound if there is no such session.
+func (r *userRepository) DeleteSession(tokenHash string) error {
+	res, err := r.db.Exec("DELETE FROM sessions WHERE token_hash = ?", tokenHash)
+	if err != nil {
+		return &RepoError{Src: "DeleteSession", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{Src: "DeleteSession", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		return &RepoError{Src: "DeleteSession", Err: ErrSessionNotFound}
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err was caused by a write violating a
+// unique constraint, as reported by SQLite or PostgreSQL.
+func isUniqueViolation(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "UNIQUE constraint failed") ||
+		strings.Contains(msg, "duplicate key value violates unique constraint")
+}
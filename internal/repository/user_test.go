@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// userRows returns the rows a query selecting userColumns yields for users.
+func userRows(users ...*models.User) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "email", "password_hash", "created_at"})
+	for _, user := range users {
+		rows.AddRow(user.Id, user.Email, user.PasswordHash, formatTime(user.CreatedAt))
+	}
+	return rows
+}
+
+func TestUserRepository_Create(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+	repo.now = func() time.Time { return created }
+	user := &models.User{Email: "ada@example.com", PasswordHash: "hash"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO users").WithArgs("ada@example.com", "hash", "2024-05-01T09:30:00.000Z").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(1))
+	mock.ExpectQuery("INSERT INTO workspaces").WithArgs("Personal", 1, "2024-05-01T09:30:00.000Z").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4))
+	mock.ExpectExec("INSERT INTO workspace_members").WithArgs(4, 1, models.RoleOwner, "2024-05-01T09:30:00.000Z").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Act
+	id, err := repo.Create(user)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 1, id)
+	assert.Equal(t, 1, user.Id)
+	assert.Equal(t, created, user.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_CreateUserExists(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO users").WillReturnError(errors.New("UNIQUE constraint failed: users.email"))
+	mock.ExpectRollback()
+
+	// Act
+	_, err = repo.Create(&models.User{Email: "Ada@example.com", PasswordHash: "hash"})
+
+	// Assert
+	assert.ErrorIs(t, err, ErrUserExists)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_AdoptLegacyData(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+	expectAdopt := func(commit bool) {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT users.id, workspaces.id FROM users").WithArgs("ada@example.com").
+			WillReturnRows(sqlmock.NewRows([]string{"id", "id"}).AddRow(1, 4))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE notes SET user_id = ?, workspace_id = ? WHERE user_id IS NULL")).
+			WithArgs(1, 4).WillReturnResult(sqlmock.NewResult(0, 3))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE notebooks SET user_id = ?, workspace_id = ? WHERE user_id IS NULL")).
+			WithArgs(1, 4).WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec(regexp.QuoteMeta("UPDATE tags SET workspace_id = ? WHERE workspace_id IS NULL")).
+			WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+		if commit {
+			mock.ExpectCommit()
+		} else {
+			mock.ExpectRollback()
+		}
+	}
+	expectAdopt(false)
+	expectAdopt(true)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT users.id, workspaces.id FROM users").WithArgs("grace@example.com").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "id"}))
+	mock.ExpectRollback()
+
+	// Act
+	counted, countedErr := repo.AdoptLegacyData("ada@example.com", true)
+	adopted, adoptedErr := repo.AdoptLegacyData("ada@example.com", false)
+	_, unknownErr := repo.AdoptLegacyData("grace@example.com", false)
+
+	// Assert
+	assert.NoError(t, countedErr)
+	assert.NoError(t, adoptedErr)
+	assert.Equal(t, &LegacyData{Notes: 3, Notebooks: 2, Tags: 1}, counted)
+	assert.Equal(t, &LegacyData{Notes: 3, Notebooks: 2, Tags: 1}, adopted)
+	assert.ErrorIs(t, unknownErr, ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_GetSessionUser(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+	repo.now = func() time.Time { return updated }
+	user := &models.User{Id: 1, Email: "ada@example.com", PasswordHash: "hash", CreatedAt: created}
+
+	mock.ExpectQuery("FROM sessions JOIN users").WithArgs("valid", "2024-05-02T17:45:30.250Z").WillReturnRows(userRows(user))
+	mock.ExpectQuery("FROM sessions JOIN users").WithArgs("expired", "2024-05-02T17:45:30.250Z").WillReturnRows(userRows())
+
+	// Act
+	res, err := repo.GetSessionUser("valid")
+	_, expiredErr := repo.GetSessionUser("expired")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, user, res)
+	assert.ErrorIs(t, expiredErr, ErrSessionNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUserRepository_DeleteSession(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewUsersRepository(db)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM sessions WHERE token_hash = ?")).WithArgs("valid").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM sessions WHERE token_hash = ?")).WithArgs("unknown").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.DeleteSession("valid")
+	notFoundErr := repo.DeleteSession("unknown")
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, notFoundErr, ErrSessionNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+var (
+	// ErrWebhookNotFound is returned when a webhook does not exist or
+	// belongs to another workspace.
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrDeliveryNotFound is returned when a webhook has no delivery with
+	// the given ID.
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	// ErrDeliveryClaimed is returned when a delivery is no longer due
+	// because another dispatcher leased or sent it meanwhile.
+	ErrDeliveryClaimed = errors.New("delivery is no longer due")
+)
+
+// DeliveryHistory is the number of deliveries of a webhook that are listed,
+// newest first.
+const DeliveryHistory = 100
+
+// webhookColumns are the columns scanned by scanWebhook, in order.
+const webhookColumns = "webhooks.id, webhooks.url, webhooks.created_at, " +
+	"(SELECT group_concat(event) FROM webhook_events WHERE webhook_events.webhook_id = webhooks.id)"
+
+// scanWebhook reads a webhook selected with webhookColumns from row.
+func scanWebhook(row scanner) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	var createdAt string
+	var events sql.NullString
+	if err := row.Scan(&webhook.Id, &webhook.URL, &createdAt, &events); err != nil {
+		return nil, err
+	}
+	webhook.Events = []models.EventType{}
+	if events.Valid {
+		names := strings.Split(events.String, ",")
+		sort.Strings(names)
+		for _, name := range names {
+			webhook.Events = append(webhook.Events, models.EventType(name))
+		}
+	}
+	var err error
+	if webhook.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// deliveryColumns are the columns scanned by scanDelivery, in order.
+const deliveryColumns = "id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at"
+
+// scanDelivery reads a delivery selected with deliveryColumns from row.
+func scanDelivery(row scanner, dest ...interface{}) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	var payload, createdAt string
+	var nextAttemptAt, lastError, deliveredAt sql.NullString
+	var lastStatusCode sql.NullInt64
+	err := row.Scan(append([]interface{}{&delivery.Id, &delivery.WebhookId, &delivery.Event, &payload, &delivery.Status,
+		&delivery.Attempts, &nextAttemptAt, &lastStatusCode, &lastError, &createdAt, &deliveredAt}, dest...)...)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = json.RawMessage(payload)
+	delivery.LastStatusCode = int(lastStatusCode.Int64)
+	delivery.LastError = lastError.String
+	if delivery.NextAttemptAt, err = parseNullTime(nextAttemptAt); err != nil {
+		return nil, err
+	}
+	if delivery.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	if delivery.DeliveredAt, err = parseNullTime(deliveredAt); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// enqueueEvent adds a pending delivery of an event about note id to every
+// webhook of a workspace that subscribed to typ, within tx, so that the
+// deliveries are only stored if the change they report is. Created and
+// updated events carry the note as it is stored in tx.
+func enqueueEvent(ctx context.Context, tx querier, workspaceId int, typ models.EventType, id int, now time.Time) error {
+	rows, err := tx.QueryContext(ctx, `SELECT webhooks.id FROM webhooks JOIN webhook_events ON webhook_events.webhook_id = webhooks.id
+    WHERE webhooks.workspace_id = ? AND webhook_events.event = ? ORDER BY webhooks.id`, workspaceId, typ)
+	if err != nil {
+		return err
+	}
+	webhookIds := []int{}
+	for rows.Next() {
+		var webhookId int
+		if err := rows.Scan(&webhookId); err != nil {
+			rows.Close()
+			return err
+		}
+		webhookIds = append(webhookIds, webhookId)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(webhookIds) == 0 {
+		return nil
+	}
+
+	e := models.Event{Type: typ, WorkspaceId: workspaceId, NoteId: id, CreatedAt: now}
+	if typ != models.EventNoteDeleted {
+		if e.Note, err = scanNote(tx.QueryRowContext(ctx, "SELECT "+noteColumns+" FROM notes WHERE id = ?", id)); err != nil {
+			return err
+		}
+	}
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	for _, webhookId := range webhookIds {
+		_, err := tx.ExecContext(ctx, `INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt_at, created_at)
+    VALUES (?, ?, ?, 'pending', 0, ?, ?)`, webhookId, typ, string(payload), formatTime(now), formatTime(now))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// webhookRepository implements the WebhookRepository interface.
+type webhookRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewWebhooksRepository creates a new webhookRepository.
+func NewWebhooksRepository(db *sql.DB) *webhookRepository {
+	return &webhookRepository{db: db, now: time.Now}
+}
+
+// GetAll retrieves the webhooks of a workspace, without their secrets.
+func (r *webhookRepository) GetAll(workspaceId int) ([]*models.Webhook, error) {
+	rows, err := r.db.Query("SELECT "+webhookColumns+" FROM webhooks WHERE workspace_id = ? ORDER BY id", workspaceId)
+	if err != nil {
+		return nil, &RepoError{Src: "GetAllWebhooks", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	webhooks := []*models.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, &RepoError{Src: "GetAllWebhooks", Err: fmt.Errorf("DB Error: %w", err)}
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetAllWebhooks", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return webhooks, nil
+}
+
+// Create adds a webhook to a workspace and sets the metadata of webhook to
+// the values it was stored with.
+func (r *webhookRepository) Create(workspaceId int, webhook *models.Webhook) (int, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, &RepoError{Src: "CreateWebhook", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	var id int64
+	err = tx.QueryRow("INSERT INTO webhooks (workspace_id, url, secret, created_at) VALUES (?, ?, ?, ?) RETURNING id",
+		workspaceId, webhook.URL, webhook.Secret, formatTime(now)).Scan(&id)
+	if err != nil {
+		return 0, &RepoError{Src: "CreateWebhook", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	for _, event := range webhook.Events {
+		if _, err := tx.Exec("INSERT INTO webhook_events (webhook_id, event) VALUES (?, ?)", id, event); err != nil {
+			return 0, &RepoError{"CreateWebhook", int(id), fmt.Errorf("DB Error: %w", err)}
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, &RepoError{"CreateWebhook", int(id), fmt.Errorf("DB Error: %w", err)}
+	}
+	webhook.Id, webhook.CreatedAt = int(id), now
+	return webhook.Id, nil
+}
+
+// Delete removes a webhook of a workspace along with its deliveries.
+// It returns ErrWebhookNotFound if the workspace has no webhook with the ID.
+func (r *webhookRepository) Delete(workspaceId int, id int) error {
+	res, err := r.db.Exec("DELETE FROM webhooks WHERE id = ? AND workspace_id = ?", id, workspaceId)
+	if err != nil {
+		return &RepoError{"DeleteWebhook", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"DeleteWebhook", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		return &RepoError{"DeleteWebhook", id, ErrWebhookNotFound}
+	}
+	return nil
+}
+
+// GetDeliveries retrieves the last DeliveryHistory deliveries of a webhook
+// of a workspace, newest first.
+// It returns ErrWebhookNotFound if the workspace has no webhook with the ID.
+func (r *webhookRepository) GetDeliveries(workspaceId int, webhookId int) ([]*models.WebhookDelivery, error) {
+	var id int
+	err := r.db.QueryRow("SELECT id FROM webhooks WHERE id = ? AND workspace_id = ?", webhookId, workspaceId).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{"GetWebhookDeliveries", webhookId, ErrWebhookNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{"GetWebhookDeliveries", webhookId, fmt.Errorf("DB Error: %w", err)}
+	}
+
+	rows, err := r.db.Query("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?", webhookId, DeliveryHistory)
+	if err != nil {
+		return nil, &RepoError{"GetWebhookDeliveries", webhookId, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, &RepoError{"GetWebhookDeliveries", webhookId, fmt.Errorf("DB Error: %w", err)}
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{"GetWebhookDeliveries", webhookId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return deliveries, nil
+}
+
+// Redeliver makes a delivery of a webhook of a workspace pending again with
+// a fresh set of attempts, to be sent right away, and returns it.
+// It returns ErrDeliveryNotFound if the workspace has no such delivery.
+func (r *webhookRepository) Redeliver(workspaceId int, webhookId int, id int) (*models.WebhookDelivery, error) {
+	delivery, err := scanDelivery(r.db.QueryRow(`UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = ?
+    WHERE id = ? AND webhook_id = ? AND webhook_id IN (SELECT id FROM webhooks WHERE workspace_id = ?)
+    RETURNING `+deliveryColumns, formatTime(r.now()), id, webhookId, workspaceId))
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{"RedeliverWebhook", id, ErrDeliveryNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{"RedeliverWebhook", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	return delivery, nil
+}
+
+// GetDueDeliveries retrieves up to limit pending deliveries of all
+// workspaces that are due at t and not leased, oldest first, together with
+// the URL and secret of their webhook.
+func (r *webhookRepository) GetDueDeliveries(t time.Time, limit int) ([]*models.WebhookDelivery, error) {
+	rows, err := r.db.Query(`SELECT `+deliveryColumns+`,
+        (SELECT url FROM webhooks WHERE webhooks.id = webhook_id), (SELECT secret FROM webhooks WHERE webhooks.id = webhook_id)
+    FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= ? AND (leased_until IS NULL OR leased_until <= ?)
+    ORDER BY next_attempt_at, id LIMIT ?`, formatTime(t), formatTime(t), limit)
+	if err != nil {
+		return nil, &RepoError{Src: "GetDueDeliveries", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	deliveries := []*models.WebhookDelivery{}
+	for rows.Next() {
+		var url, secret string
+		delivery, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, &RepoError{Src: "GetDueDeliveries", Err: fmt.Errorf("DB Error: %w", err)}
+		}
+		delivery.URL, delivery.Secret = url, secret
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetDueDeliveries", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return deliveries, nil
+}
+
+// ClaimDelivery leases a delivery retrieved with GetDueDeliveries until
+// until, so that no other dispatcher sends it meanwhile, and sets its
+// LeasedUntil. The delivery must still be due at t, unchanged, and not be
+// leased by another dispatcher. Writes to a row are serialized, so only one
+// of several dispatchers claiming a delivery at the same time succeeds.
+// It returns ErrDeliveryClaimed if the delivery cannot be claimed.
+func (r *webhookRepository) ClaimDelivery(delivery *models.WebhookDelivery, t time.Time, until time.Time) error {
+	now := formatTime(t)
+	res, err := r.db.Exec(`UPDATE webhook_deliveries SET leased_until = ?
+    WHERE id = ? AND status = 'pending' AND attempts = ? AND next_attempt_at <= ? AND (leased_until IS NULL OR leased_until <= ?)`,
+		formatTime(until), delivery.Id, delivery.Attempts, now, now)
+	if err != nil {
+		return &RepoError{"ClaimDelivery", delivery.Id, fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"ClaimDelivery", delivery.Id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		return &RepoError{"ClaimDelivery", delivery.Id, ErrDeliveryClaimed}
+	}
+	until = until.UTC().Truncate(time.Millisecond)
+	delivery.LeasedUntil = &until
+	return nil
+}
+
+// UpdateDelivery stores the outcome of an attempt to send a delivery and
+// ends its lease. Only the outcome of the last dispatcher to lease it is
+// stored.
+// It returns ErrDeliveryNotFound if the delivery no longer exists because
+// its webhook was deleted, or was leased by another dispatcher since.
+func (r *webhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	var lastStatusCode, lastError interface{}
+	if delivery.LastStatusCode != 0 {
+		lastStatusCode = delivery.LastStatusCode
+	}
+	if delivery.LastError != "" {
+		lastError = delivery.LastError
+	}
+	res, err := r.db.Exec(`UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ?,
+    leased_until = NULL WHERE id = ? AND (leased_until IS NULL OR leased_until = ?)`,
+		delivery.Status, delivery.Attempts, formatNullTime(delivery.NextAttemptAt), lastStatusCode, lastError,
+		formatNullTime(delivery.DeliveredAt), delivery.Id, formatNullTime(delivery.LeasedUntil))
+	if err != nil {
+		return &RepoError{"UpdateDelivery", delivery.Id, fmt.Errorf("DB Error: %w", err)}
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return &RepoError{"UpdateDelivery", delivery.Id, fmt.Errorf("DB Error: %w", err)}
+	}
+	if n == 0 {
+		return &RepoError{"UpdateDelivery", delivery.Id, ErrDeliveryNotFound}
+	}
+	return nil
+}
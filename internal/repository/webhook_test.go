@@ -0,0 +1,326 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// deliveryRows returns the rows a query selecting deliveryColumns yields for
+// deliveries.
+func deliveryRows(deliveries ...*models.WebhookDelivery) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "webhook_id", "event", "payload", "status", "attempts", "next_attempt_at",
+		"last_status_code", "last_error", "created_at", "delivered_at"})
+	for _, d := range deliveries {
+		var lastStatusCode, lastError interface{}
+		if d.LastStatusCode != 0 {
+			lastStatusCode = d.LastStatusCode
+		}
+		if d.LastError != "" {
+			lastError = d.LastError
+		}
+		rows.AddRow(d.Id, d.WebhookId, d.Event, string(d.Payload), d.Status, d.Attempts, formatNullTime(d.NextAttemptAt),
+			lastStatusCode, lastError, formatTime(d.CreatedAt), formatNullTime(d.DeliveredAt))
+	}
+	return rows
+}
+
+func TestNoteRepository_EnqueuesWebhookDeliveries(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+	repo.now = func() time.Time { return updated }
+	repo.MaxRevisions = 0
+	note := &models.Note{Title: "Docs", Content: "Build me"}
+	stored := &models.Note{Id: 1, Title: "Docs", Content: "Build me", Tags: []string{"ci"}, CreatedAt: created, UpdatedAt: updated, Version: 2}
+	hooks := "SELECT webhooks.id FROM webhooks JOIN webhook_events"
+	insert := regexp.QuoteMeta("INSERT INTO webhook_deliveries (webhook_id, event, payload, status, attempts, next_attempt_at, created_at)")
+	updatedPayload := `{"type":"note.updated","workspace_id":3,"note_id":1,"note":{"id":1,"title":"Docs","content":"Build me","tags":["ci"],` +
+		`"created_at":"2024-05-01T09:30:00Z","updated_at":"2024-05-02T17:45:30.25Z","version":2},"created_at":"2024-05-02T17:45:30.25Z"}`
+	deletedPayload := `{"type":"note.deleted","workspace_id":3,"note_id":1,"created_at":"2024-05-02T17:45:30.25Z"}`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("UPDATE notes SET title").WithArgs("Docs", "Build me", "2024-05-02T17:45:30.250Z", 1, testWorkspaceId, 0, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at", "updated_at", "version"}).AddRow("2024-05-01T09:30:00.000Z", "2024-05-02T17:45:30.250Z", 2))
+	mock.ExpectExec("INSERT INTO note_revisions").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(hooks).WithArgs(testWorkspaceId, models.EventNoteUpdated).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(4).AddRow(6))
+	mock.ExpectQuery("FROM notes WHERE id = \\?").WithArgs(1).WillReturnRows(noteRows(stored))
+	mock.ExpectExec(insert).WithArgs(4, models.EventNoteUpdated, updatedPayload, "2024-05-02T17:45:30.250Z", "2024-05-02T17:45:30.250Z").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(insert).WithArgs(6, models.EventNoteUpdated, updatedPayload, "2024-05-02T17:45:30.250Z", "2024-05-02T17:45:30.250Z").
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE notes SET deleted_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(hooks).WithArgs(testWorkspaceId, models.EventNoteDeleted).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	mock.ExpectExec(insert).WithArgs(6, models.EventNoteDeleted, deletedPayload, "2024-05-02T17:45:30.250Z", "2024-05-02T17:45:30.250Z").
+		WillReturnResult(sqlmock.NewResult(3, 1))
+	mock.ExpectCommit()
+
+	// Act
+	updateErr := repo.Update(context.Background(), testWorkspaceId, 1, note, 0)
+	deleteErr := repo.Delete(context.Background(), testWorkspaceId, 1, 0)
+
+	// Assert
+	assert.NoError(t, updateErr)
+	assert.NoError(t, deleteErr)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNoteRepository_DeleteFailsWhenDeliveryIsNotQueued(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewNotesRepository(db)
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE notes SET deleted_at").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT webhooks.id FROM webhooks").WithArgs(testWorkspaceId, models.EventNoteDeleted).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(6))
+	mock.ExpectExec("INSERT INTO webhook_deliveries").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	// Act
+	err = repo.Delete(context.Background(), testWorkspaceId, 1, 0)
+
+	// Assert
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_Create(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	repo.now = func() time.Time { return created }
+	webhook := &models.Webhook{URL: "https://ci.example.com/hook", Events: []models.EventType{models.EventNoteCreated, models.EventNoteUpdated}, Secret: "whsec_secret"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO webhooks").WithArgs(testWorkspaceId, "https://ci.example.com/hook", "whsec_secret", "2024-05-01T09:30:00.000Z").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectExec("INSERT INTO webhook_events").WithArgs(5, models.EventNoteCreated).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO webhook_events").WithArgs(5, models.EventNoteUpdated).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Act
+	id, err := repo.Create(testWorkspaceId, webhook)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, 5, id)
+	assert.Equal(t, 5, webhook.Id)
+	assert.Equal(t, created, webhook.CreatedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_GetAll(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	mock.ExpectQuery("FROM webhooks WHERE workspace_id = \\? ORDER BY id").WithArgs(testWorkspaceId).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "created_at", "events"}).
+			AddRow(5, "https://ci.example.com/hook", "2024-05-01T09:30:00.000Z", "note.updated,note.created").
+			AddRow(6, "https://example.com/none", "2024-05-01T09:30:00.000Z", nil))
+
+	// Act
+	webhooks, err := repo.GetAll(testWorkspaceId)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.Webhook{
+		{Id: 5, URL: "https://ci.example.com/hook", Events: []models.EventType{models.EventNoteCreated, models.EventNoteUpdated}, CreatedAt: created},
+		{Id: 6, URL: "https://example.com/none", Events: []models.EventType{}, CreatedAt: created},
+	}, webhooks)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_Delete(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	query := regexp.QuoteMeta("DELETE FROM webhooks WHERE id = ? AND workspace_id = ?")
+	mock.ExpectExec(query).WithArgs(5, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs(5, 9).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.Delete(testWorkspaceId, 5)
+	otherErr := repo.Delete(9, 5)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, otherErr, ErrWebhookNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_GetDeliveries(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	delivery := &models.WebhookDelivery{Id: 2, WebhookId: 5, Event: models.EventNoteDeleted, Payload: json.RawMessage(`{"type":"note.deleted"}`),
+		Status: models.DeliverySucceeded, Attempts: 2, LastStatusCode: 200, CreatedAt: created, DeliveredAt: &updated}
+	webhook := regexp.QuoteMeta("SELECT id FROM webhooks WHERE id = ? AND workspace_id = ?")
+
+	mock.ExpectQuery(webhook).WithArgs(5, testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(5))
+	mock.ExpectQuery("FROM webhook_deliveries WHERE webhook_id = \\? ORDER BY id DESC LIMIT \\?").WithArgs(5, DeliveryHistory).
+		WillReturnRows(deliveryRows(delivery))
+	mock.ExpectQuery(webhook).WithArgs(5, 9).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+
+	// Act
+	deliveries, err := repo.GetDeliveries(testWorkspaceId, 5)
+	_, otherErr := repo.GetDeliveries(9, 5)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, []*models.WebhookDelivery{delivery}, deliveries)
+	assert.ErrorIs(t, otherErr, ErrWebhookNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_Redeliver(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	repo.now = func() time.Time { return updated }
+	delivery := &models.WebhookDelivery{Id: 2, WebhookId: 5, Event: models.EventNoteDeleted, Payload: json.RawMessage(`{}`),
+		Status: models.DeliveryPending, NextAttemptAt: &updated, LastStatusCode: 500, LastError: "webhook responded with 500", CreatedAt: created}
+	query := regexp.QuoteMeta("UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = ?")
+
+	mock.ExpectQuery(query).WithArgs("2024-05-02T17:45:30.250Z", 2, 5, testWorkspaceId).WillReturnRows(deliveryRows(delivery))
+	mock.ExpectQuery(query).WithArgs("2024-05-02T17:45:30.250Z", 2, 5, 9).WillReturnRows(deliveryRows())
+
+	// Act
+	redelivered, err := repo.Redeliver(testWorkspaceId, 5, 2)
+	_, otherErr := repo.Redeliver(9, 5, 2)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, delivery, redelivered)
+	assert.ErrorIs(t, otherErr, ErrDeliveryNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_GetDueDeliveries(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	delivery := &models.WebhookDelivery{Id: 2, WebhookId: 5, Event: models.EventNoteCreated, Payload: json.RawMessage(`{}`),
+		Status: models.DeliveryPending, NextAttemptAt: &created, CreatedAt: created}
+	rows := sqlmock.NewRows([]string{"id", "webhook_id", "event", "payload", "status", "attempts", "next_attempt_at",
+		"last_status_code", "last_error", "created_at", "delivered_at", "url", "secret"}).
+		AddRow(2, 5, "note.created", "{}", "pending", 0, "2024-05-01T09:30:00.000Z", nil, nil, "2024-05-01T09:30:00.000Z", nil, "https://ci.example.com/hook", "whsec_secret")
+	mock.ExpectQuery(regexp.QuoteMeta("WHERE status = 'pending' AND next_attempt_at <= ? AND (leased_until IS NULL OR leased_until <= ?)")).
+		WithArgs("2024-05-02T17:45:30.250Z", "2024-05-02T17:45:30.250Z", 10).WillReturnRows(rows)
+
+	// Act
+	deliveries, err := repo.GetDueDeliveries(updated, 10)
+
+	// Assert
+	assert.NoError(t, err)
+	delivery.URL, delivery.Secret = "https://ci.example.com/hook", "whsec_secret"
+	assert.Equal(t, []*models.WebhookDelivery{delivery}, deliveries)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_ClaimDelivery(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	delivery := &models.WebhookDelivery{Id: 2, Status: models.DeliveryPending, Attempts: 1, NextAttemptAt: &created}
+	claimed := &models.WebhookDelivery{Id: 3, Status: models.DeliveryPending, Attempts: 0, NextAttemptAt: &created}
+	until := updated.Add(20 * time.Second)
+	query := regexp.QuoteMeta("UPDATE webhook_deliveries SET leased_until = ?")
+
+	mock.ExpectExec(query).WithArgs("2024-05-02T17:45:50.250Z", 2, 1, "2024-05-02T17:45:30.250Z", "2024-05-02T17:45:30.250Z").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs("2024-05-02T17:45:50.250Z", 3, 0, "2024-05-02T17:45:30.250Z", "2024-05-02T17:45:30.250Z").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.ClaimDelivery(delivery, updated, until)
+	claimedErr := repo.ClaimDelivery(claimed, updated, until)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &until, delivery.LeasedUntil)
+	assert.ErrorIs(t, claimedErr, ErrDeliveryClaimed)
+	assert.Nil(t, claimed.LeasedUntil)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWebhookRepository_UpdateDelivery(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWebhooksRepository(db)
+	failed := &models.WebhookDelivery{Id: 2, Status: models.DeliveryPending, Attempts: 1, NextAttemptAt: &updated, LastError: "connection refused",
+		LeasedUntil: &created}
+	dead := &models.WebhookDelivery{Id: 3, Status: models.DeliveryDead, Attempts: 8, LastStatusCode: 500}
+	query := regexp.QuoteMeta("UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, delivered_at = ?")
+
+	mock.ExpectExec(query).WithArgs(models.DeliveryPending, 1, "2024-05-02T17:45:30.250Z", nil, "connection refused", nil, 2, "2024-05-01T09:30:00.000Z").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(query).WithArgs(models.DeliveryDead, 8, nil, 500, nil, nil, 3, nil).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Act
+	err = repo.UpdateDelivery(failed)
+	deletedErr := repo.UpdateDelivery(dead)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, deletedErr, ErrDeliveryNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+var (
+	// ErrWorkspaceNotFound is returned when a workspace does not exist or
+	// the user is not a member of it.
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	// ErrMemberNotFound is returned when a user is not a member of a
+	// workspace.
+	ErrMemberNotFound = errors.New("member not found")
+	// ErrLastOwner is returned when the last owner of a workspace would
+	// leave it or stop being an owner.
+	ErrLastOwner = errors.New("workspace must keep an owner")
+	// ErrPersonalWorkspace is returned when a member is added to a personal
+	// workspace.
+	ErrPersonalWorkspace = errors.New("personal workspaces cannot have other members")
+)
+
+// workspaceColumns are the columns scanned by scanWorkspace, in order. They
+// select from workspaces joined with the membership of the current user.
+const workspaceColumns = "workspaces.id, workspaces.name, workspaces.personal_user_id IS NOT NULL, workspace_members.role, workspaces.created_at"
+
+// scanWorkspace reads a workspace selected with workspaceColumns from row.
+func scanWorkspace(row scanner) (*models.Workspace, error) {
+	workspace := &models.Workspace{}
+	var createdAt string
+	if err := row.Scan(&workspace.Id, &workspace.Name, &workspace.Personal, &workspace.Role, &createdAt); err != nil {
+		return nil, err
+	}
+	var err error
+	if workspace.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, err
+	}
+	return workspace, nil
+}
+
+// workspaceRepository implements the WorkspaceRepository interface.
+type workspaceRepository struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewWorkspacesRepository creates a new workspaceRepository.
+func NewWorkspacesRepository(db *sql.DB) *workspaceRepository {
+	return &workspaceRepository{db: db, now: time.Now}
+}
+
+// createWorkspace adds a workspace with userId as its owner within tx and
+// returns its ID. A personal workspace belongs to userId alone.
+func createWorkspace(tx *sql.Tx, userId int, name string, personal bool, now time.Time) (int, error) {
+	var personalUserId interface{}
+	if personal {
+		personalUserId = userId
+	}
+	var id int64
+	err := tx.QueryRow("INSERT INTO workspaces (name, personal_user_id, created_at) VALUES (?, ?, ?) RETURNING id", name, personalUserId, formatTime(now)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)",
+		id, userId, models.RoleOwner, formatTime(now))
+	return int(id), err
+}
+
+// guestQuery is an SQL query for whether the user bound to its last two
+// parameters was shared a note or notebook of the workspace bound to its
+// first two.
+const guestQuery = `SELECT EXISTS (SELECT 1 FROM note_shares JOIN notes ON notes.id = note_shares.note_id
+        WHERE notes.workspace_id = ? AND notes.deleted_at IS NULL AND note_shares.user_id = ?)
+    OR EXISTS (SELECT 1 FROM notebook_shares JOIN notebooks ON notebooks.id = notebook_shares.notebook_id
+        WHERE notebooks.workspace_id = ? AND notebook_shares.user_id = ?)`
+
+// GetMember retrieves the membership of a user in a workspace. Users who
+// are not members but were shared a note or notebook of the workspace are
+// guests with an empty role.
+// It returns ErrWorkspaceNotFound if the workspace does not exist or the
+// user is neither a member nor a guest of it.
+func (r *workspaceRepository) GetMember(workspaceId int, userId int) (*models.Member, error) {
+	m := &models.Member{WorkspaceId: workspaceId, UserId: userId}
+	err := r.db.QueryRow("SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceId, userId).Scan(&m.Role)
+	if err == sql.ErrNoRows {
+		var guest bool
+		err = r.db.QueryRow(guestQuery, workspaceId, userId, workspaceId, userId).Scan(&guest)
+		if err == nil && !guest {
+			return nil, &RepoError{"GetWorkspaceMember", workspaceId, ErrWorkspaceNotFound}
+		}
+	}
+	if err != nil {
+		return nil, &RepoError{"GetWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return m, nil
+}
+
+// GetPersonalMember retrieves the membership of a user in their personal
+// workspace.
+// It returns ErrWorkspaceNotFound if the user has none.
+func (r *workspaceRepository) GetPersonalMember(userId int) (*models.Member, error) {
+	m := &models.Member{UserId: userId}
+	err := r.db.QueryRow(`SELECT workspaces.id, workspace_members.role FROM workspaces
+    JOIN workspace_members ON workspace_members.workspace_id = workspaces.id AND workspace_members.user_id = workspaces.personal_user_id
+    WHERE workspaces.personal_user_id = ?`, userId).Scan(&m.WorkspaceId, &m.Role)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{"GetPersonalMember", userId, ErrWorkspaceNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{"GetPersonalMember", userId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return m, nil
+}
+
+// GetAll retrieves the workspaces a user is a member of along with their
+// role, the personal workspace first.
+func (r *workspaceRepository) GetAll(userId int) ([]*models.Workspace, error) {
+	rows, err := r.db.Query(`SELECT `+workspaceColumns+` FROM workspaces
+    JOIN workspace_members ON workspace_members.workspace_id = workspaces.id
+    WHERE workspace_members.user_id = ?
+    ORDER BY workspaces.personal_user_id IS NULL, workspaces.id`, userId)
+	if err != nil {
+		return nil, &RepoError{Src: "GetAllWorkspaces", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	workspaces := []*models.Workspace{}
+	for rows.Next() {
+		workspace, err := scanWorkspace(rows)
+		if err != nil {
+			return nil, &RepoError{Src: "GetAllWorkspaces", Err: fmt.Errorf("DB Error: %w", err)}
+		}
+		workspaces = append(workspaces, workspace)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{Src: "GetAllWorkspaces", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	return workspaces, nil
+}
+
+// Create adds a new workspace with a user as its owner and sets the
+// metadata of workspace to the values it was stored with.
+func (r *workspaceRepository) Create(userId int, workspace *models.Workspace) (int, error) {
+	now := r.now().UTC().Truncate(time.Millisecond)
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, &RepoError{Src: "CreateWorkspace", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	id, err := createWorkspace(tx, userId, workspace.Name, false, now)
+	if err != nil {
+		return 0, &RepoError{Src: "CreateWorkspace", Err: fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, &RepoError{"CreateWorkspace", id, fmt.Errorf("DB Error: %w", err)}
+	}
+	workspace.Id, workspace.Personal, workspace.Role, workspace.CreatedAt = id, false, models.RoleOwner, now
+	return id, nil
+}
+
+// GetMembers retrieves the members of a workspace, owners first and then by
+// email.
+func (r *workspaceRepository) GetMembers(workspaceId int) ([]*models.WorkspaceMember, error) {
+	rows, err := r.db.Query(`SELECT users.id, users.email, workspace_members.role, workspace_members.created_at
+    FROM workspace_members JOIN users ON users.id = workspace_members.user_id
+    WHERE workspace_members.workspace_id = ?
+    ORDER BY workspace_members.role != 'owner', users.email`, workspaceId)
+	if err != nil {
+		return nil, &RepoError{"GetWorkspaceMembers", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer rows.Close()
+
+	members := []*models.WorkspaceMember{}
+	for rows.Next() {
+		member := &models.WorkspaceMember{}
+		var createdAt string
+		if err := rows.Scan(&member.UserId, &member.Email, &member.Role, &createdAt); err != nil {
+			return nil, &RepoError{"GetWorkspaceMembers", workspaceId, fmt.Errorf("DB Error: %w", err)}
+		}
+		if member.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+			return nil, &RepoError{"GetWorkspaceMembers", workspaceId, fmt.Errorf("DB Error: %w", err)}
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, &RepoError{"GetWorkspaceMembers", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return members, nil
+}
+
+// checkOwnerLeft returns ErrLastOwner if no owner of a workspace other than
+// userId would be left.
+func checkOwnerLeft(tx *sql.Tx, workspaceId int, userId int) error {
+	var owners int
+	err := tx.QueryRow("SELECT count(*) FROM workspace_members WHERE workspace_id = ? AND role = 'owner' AND user_id != ?",
+		workspaceId, userId).Scan(&owners)
+	if err != nil {
+		return fmt.Errorf("DB Error: %w", err)
+	}
+	if owners == 0 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// AddMember makes the user with the given email a member of a workspace
+// with role, or changes the role if the user already is a member.
+// It returns ErrWorkspaceNotFound if the workspace does not exist,
+// ErrPersonalWorkspace if it is a personal workspace, ErrUserNotFound if
+// there is no user with the email and ErrLastOwner if the role of the last
+// owner would change.
+func (r *workspaceRepository) AddMember(workspaceId int, email string, role models.Role) (*models.WorkspaceMember, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	var personal bool
+	err = tx.QueryRow("SELECT personal_user_id IS NOT NULL FROM workspaces WHERE id = ?", workspaceId).Scan(&personal)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, ErrWorkspaceNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	if personal {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, ErrPersonalWorkspace}
+	}
+	member := &models.WorkspaceMember{Role: role}
+	err = tx.QueryRow("SELECT id, email FROM users WHERE email = ?", email).Scan(&member.UserId, &member.Email)
+	if err == sql.ErrNoRows {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, ErrUserNotFound}
+	}
+	if err != nil {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	if role != models.RoleOwner {
+		if err := checkOwnerLeft(tx, workspaceId, member.UserId); err != nil {
+			return nil, &RepoError{"AddWorkspaceMember", workspaceId, err}
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO workspace_members (workspace_id, user_id, role, created_at) VALUES (?, ?, ?, ?)
+    ON CONFLICT (workspace_id, user_id) DO UPDATE SET role = excluded.role`, workspaceId, member.UserId, role, formatTime(r.now()))
+	if err != nil {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	var createdAt string
+	err = tx.QueryRow("SELECT created_at FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceId, member.UserId).Scan(&createdAt)
+	if err != nil {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	if member.CreatedAt, err = time.Parse(TimeFormat, createdAt); err != nil {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, &RepoError{"AddWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return member, nil
+}
+
+// RemoveMember removes a user from a workspace along with the shares they
+// were granted in it. The notes and notebooks they created stay in the
+// workspace.
+// It returns ErrMemberNotFound if the user is not a member and ErrLastOwner
+// if they are its last owner.
+func (r *workspaceRepository) RemoveMember(workspaceId int, userId int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return &RepoError{"RemoveWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	defer tx.Rollback()
+
+	if err := checkOwnerLeft(tx, workspaceId, userId); err != nil {
+		return &RepoError{"RemoveWorkspaceMember", workspaceId, err}
+	}
+	result, err := tx.Exec("DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?", workspaceId, userId)
+	if err != nil {
+		return &RepoError{"RemoveWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return &RepoError{"RemoveWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	if count == 0 {
+		return &RepoError{"RemoveWorkspaceMember", workspaceId, ErrMemberNotFound}
+	}
+	_, err = tx.Exec("DELETE FROM note_shares WHERE user_id = ? AND note_id IN (SELECT id FROM notes WHERE workspace_id = ?)", userId, workspaceId)
+	if err == nil {
+		_, err = tx.Exec("DELETE FROM notebook_shares WHERE user_id = ? AND notebook_id IN (SELECT id FROM notebooks WHERE workspace_id = ?)",
+			userId, workspaceId)
+	}
+	if err != nil {
+		return &RepoError{"RemoveWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	if err := tx.Commit(); err != nil {
+		return &RepoError{"RemoveWorkspaceMember", workspaceId, fmt.Errorf("DB Error: %w", err)}
+	}
+	return nil
+}
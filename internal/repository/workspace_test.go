@@ -0,0 +1,168 @@
+package repository
+
+import (
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkspaceRepository_GetMember(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWorkspacesRepository(db)
+	query := regexp.QuoteMeta("SELECT role FROM workspace_members WHERE workspace_id = ? AND user_id = ?")
+
+	mock.ExpectQuery(query).WithArgs(testWorkspaceId, testUserId).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("editor"))
+	mock.ExpectQuery(query).WithArgs(8, testUserId).WillReturnRows(sqlmock.NewRows([]string{"role"}))
+	mock.ExpectQuery("SELECT EXISTS .* FROM note_shares .* FROM notebook_shares").WithArgs(8, testUserId, 8, testUserId).
+		WillReturnRows(sqlmock.NewRows([]string{"guest"}).AddRow(true))
+	mock.ExpectQuery(query).WithArgs(9, testUserId).WillReturnRows(sqlmock.NewRows([]string{"role"}))
+	mock.ExpectQuery("SELECT EXISTS .* FROM note_shares .* FROM notebook_shares").WithArgs(9, testUserId, 9, testUserId).
+		WillReturnRows(sqlmock.NewRows([]string{"guest"}).AddRow(false))
+
+	// Act
+	member, err := repo.GetMember(testWorkspaceId, testUserId)
+	guest, guestErr := repo.GetMember(8, testUserId)
+	_, otherErr := repo.GetMember(9, testUserId)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &models.Member{WorkspaceId: testWorkspaceId, UserId: testUserId, Role: models.RoleEditor}, member)
+	assert.NoError(t, guestErr)
+	assert.Equal(t, &models.Member{WorkspaceId: 8, UserId: testUserId}, guest)
+	assert.ErrorIs(t, otherErr, ErrWorkspaceNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorkspaceRepository_Create(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWorkspacesRepository(db)
+	repo.now = func() time.Time { return created }
+	workspace := &models.Workspace{Name: "Team"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO workspaces").WithArgs("Team", nil, "2024-05-01T09:30:00.000Z").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(testWorkspaceId))
+	mock.ExpectExec("INSERT INTO workspace_members").WithArgs(testWorkspaceId, testUserId, models.RoleOwner, "2024-05-01T09:30:00.000Z").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Act
+	id, err := repo.Create(testUserId, workspace)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, testWorkspaceId, id)
+	assert.Equal(t, &models.Workspace{Id: testWorkspaceId, Name: "Team", Role: models.RoleOwner, CreatedAt: created}, workspace)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorkspaceRepository_AddMember(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWorkspacesRepository(db)
+	repo.now = func() time.Time { return updated }
+	personal := regexp.QuoteMeta("SELECT personal_user_id IS NOT NULL FROM workspaces WHERE id = ?")
+	user := regexp.QuoteMeta("SELECT id, email FROM users WHERE email = ?")
+	owners := regexp.QuoteMeta("SELECT count(*) FROM workspace_members WHERE workspace_id = ? AND role = 'owner' AND user_id != ?")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(personal).WithArgs(testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"personal"}).AddRow(false))
+	mock.ExpectQuery(user).WithArgs("grace@example.com").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(8, "grace@example.com"))
+	mock.ExpectQuery(owners).WithArgs(testWorkspaceId, 8).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("INSERT INTO workspace_members").WithArgs(testWorkspaceId, 8, models.RoleEditor, "2024-05-02T17:45:30.250Z").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT created_at FROM workspace_members WHERE workspace_id = ? AND user_id = ?")).WithArgs(testWorkspaceId, 8).
+		WillReturnRows(sqlmock.NewRows([]string{"created_at"}).AddRow("2024-05-01T09:30:00.000Z"))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(personal).WithArgs(testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"personal"}).AddRow(false))
+	mock.ExpectQuery(user).WithArgs("ada@example.com").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}).AddRow(testUserId, "ada@example.com"))
+	mock.ExpectQuery(owners).WithArgs(testWorkspaceId, testUserId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(personal).WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"personal"}).AddRow(true))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(personal).WithArgs(testWorkspaceId).WillReturnRows(sqlmock.NewRows([]string{"personal"}).AddRow(false))
+	mock.ExpectQuery(user).WithArgs("nobody@example.com").WillReturnRows(sqlmock.NewRows([]string{"id", "email"}))
+	mock.ExpectRollback()
+
+	// Act
+	member, err := repo.AddMember(testWorkspaceId, "grace@example.com", models.RoleEditor)
+	_, lastOwnerErr := repo.AddMember(testWorkspaceId, "ada@example.com", models.RoleViewer)
+	_, personalErr := repo.AddMember(1, "grace@example.com", models.RoleViewer)
+	_, unknownErr := repo.AddMember(testWorkspaceId, "nobody@example.com", models.RoleViewer)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.Equal(t, &models.WorkspaceMember{UserId: 8, Email: "grace@example.com", Role: models.RoleEditor, CreatedAt: created}, member)
+	assert.ErrorIs(t, lastOwnerErr, ErrLastOwner)
+	assert.ErrorIs(t, personalErr, ErrPersonalWorkspace)
+	assert.ErrorIs(t, unknownErr, ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWorkspaceRepository_RemoveMember(t *testing.T) {
+	// Arrange
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("Error when opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	repo := NewWorkspacesRepository(db)
+	owners := regexp.QuoteMeta("SELECT count(*) FROM workspace_members WHERE workspace_id = ? AND role = 'owner' AND user_id != ?")
+	remove := regexp.QuoteMeta("DELETE FROM workspace_members WHERE workspace_id = ? AND user_id = ?")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(owners).WithArgs(testWorkspaceId, 8).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(remove).WithArgs(testWorkspaceId, 8).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM note_shares WHERE user_id = ? AND note_id IN (SELECT id FROM notes WHERE workspace_id = ?)")).
+		WithArgs(8, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM notebook_shares WHERE user_id = ? AND notebook_id IN (SELECT id FROM notebooks WHERE workspace_id = ?)")).
+		WithArgs(8, testWorkspaceId).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(owners).WithArgs(testWorkspaceId, 5).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(remove).WithArgs(testWorkspaceId, 5).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(owners).WithArgs(testWorkspaceId, testUserId).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	// Act
+	err = repo.RemoveMember(testWorkspaceId, 8)
+	notFoundErr := repo.RemoveMember(testWorkspaceId, 5)
+	lastOwnerErr := repo.RemoveMember(testWorkspaceId, testUserId)
+
+	// Assert
+	assert.NoError(t, err)
+	assert.ErrorIs(t, notFoundErr, ErrMemberNotFound)
+	assert.ErrorIs(t, lastOwnerErr, ErrLastOwner)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
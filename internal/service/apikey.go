@@ -0,0 +1,127 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+var (
+	// ErrInvalidAPIKey is returned when an API key is created without a
+	// valid name or with an expiry that has passed.
+	ErrInvalidAPIKey = errors.New("invalid api key")
+	// ErrInvalidScope is returned when an API key is created without scopes
+	// or with an unknown scope.
+	ErrInvalidScope = errors.New("scopes must be notes:read, notes:write or notes:delete")
+)
+
+const (
+	// MaxAPIKeyNameLength is the maximum length of the name of an API key in
+	// characters.
+	MaxAPIKeyNameLength = 100
+	// apiKeyPrefixLength is how many characters of a key after
+	// auth.APIKeyPrefix are kept to identify it.
+	apiKeyPrefixLength = 8
+)
+
+// normalizeScopes sorts scopes and removes duplicates.
+// It returns ErrInvalidScope if there are none or one is unknown.
+func normalizeScopes(scopes []models.Scope) ([]models.Scope, error) {
+	seen := map[models.Scope]bool{}
+	for _, scope := range scopes {
+		known := false
+		for _, s := range models.Scopes {
+			known = known || s == scope
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		seen[scope] = true
+	}
+	if len(seen) == 0 {
+		return nil, ErrInvalidScope
+	}
+	normalized := make([]models.Scope, 0, len(seen))
+	for scope := range seen {
+		normalized = append(normalized, scope)
+	}
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i] < normalized[j] })
+	return normalized, nil
+}
+
+// CreateAPIKey mints an API key for a user with the name, scopes and
+// optional expiry of key. The returned key is the only place the key itself
+// can be read, since only its hash is stored.
+// It returns ErrInvalidAPIKey if the name is not valid or the expiry has
+// passed and ErrInvalidScope if the scopes are not valid.
+func (s *userService) CreateAPIKey(userId int, key *models.APIKey) (*models.APIKey, error) {
+	if key == nil {
+		return nil, &Error{Src: "CreateAPIKey", Err: ErrInvalidAPIKey}
+	}
+	name := strings.TrimSpace(key.Name)
+	if name == "" || utf8.RuneCountInString(name) > MaxAPIKeyNameLength {
+		return nil, &Error{Src: "CreateAPIKey", Err: fmt.Errorf("%w: name must have 1 to %d characters", ErrInvalidAPIKey, MaxAPIKeyNameLength)}
+	}
+	scopes, err := normalizeScopes(key.Scopes)
+	if err != nil {
+		return nil, &Error{Src: "CreateAPIKey", Err: err}
+	}
+	var expiresAt *time.Time
+	if key.ExpiresAt != nil {
+		if !key.ExpiresAt.After(s.now()) {
+			return nil, &Error{Src: "CreateAPIKey", Err: fmt.Errorf("%w: expires_at must be in the future", ErrInvalidAPIKey)}
+		}
+		t := key.ExpiresAt.UTC().Truncate(time.Millisecond)
+		expiresAt = &t
+	}
+
+	secret, err := auth.NewAPIKey()
+	if err != nil {
+		return nil, &Error{Src: "CreateAPIKey", Err: err}
+	}
+	created := &models.APIKey{
+		Name:      name,
+		Key:       secret,
+		Prefix:    secret[:len(auth.APIKeyPrefix)+apiKeyPrefixLength],
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if _, err := s.repo.CreateAPIKey(userId, created, auth.HashToken(secret)); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetAPIKeys retrieves the API keys of a user, without the keys themselves.
+func (s *userService) GetAPIKeys(userId int) ([]*models.APIKey, error) {
+	return s.repo.GetAPIKeys(userId)
+}
+
+// DeleteAPIKey revokes an API key of a user.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *userService) DeleteAPIKey(userId int, id int) error {
+	if id < 1 {
+		return &Error{"DeleteAPIKey", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	return s.repo.DeleteAPIKey(userId, id)
+}
+
+// AuthenticateAPIKey returns the API key key and the user it belongs to.
+// It returns ErrUnauthenticated if the key is unknown or expired.
+func (s *userService) AuthenticateAPIKey(key string) (*models.User, *models.APIKey, error) {
+	user, apiKey, err := s.repo.GetAPIKeyUser(auth.HashToken(key))
+	if errors.Is(err, repository.ErrAPIKeyNotFound) {
+		return nil, nil, &Error{Src: "AuthenticateAPIKey", Err: ErrUnauthenticated}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, apiKey, nil
+}
@@ -0,0 +1,582 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/crdt"
+	"github.com/JannisK89/notes-api/internal/diff"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+var (
+	// ErrInvalidCollabMessage is returned when an editor sends a message of
+	// an unknown type or an operation that does not fit the text.
+	ErrInvalidCollabMessage = errors.New("invalid collaboration message")
+	// ErrCollabTooSlow is the reason an editor is removed from a session
+	// when they do not keep up with its messages.
+	ErrCollabTooSlow = errors.New("editor fell behind")
+	// ErrCollabClosed is returned when joining a session after the hub was
+	// closed, and is the reason editors are removed when it closes.
+	ErrCollabClosed = errors.New("collaborative editing is shutting down")
+	// ErrCollabAccessChanged is the reason an editor is removed from a
+	// session when they can no longer view the note, or no longer edit it
+	// while they were editing. They may join again with the access they
+	// have left.
+	ErrCollabAccessChanged = errors.New("access to the note changed")
+)
+
+const (
+	// DefaultCollabSaveInterval is how often the text of a session is
+	// stored unless configured otherwise.
+	DefaultCollabSaveInterval = 5 * time.Second
+	// DefaultCollabAccessInterval is how often the access of the editors
+	// of a session is checked again unless configured otherwise.
+	DefaultCollabAccessInterval = 30 * time.Second
+	// collabBuffer is how many messages an editor can fall behind before
+	// they are removed.
+	collabBuffer = 256
+	// maxSaveAttempts is how often a save merges the changes made to the
+	// note outside of the session before it gives up until the next one.
+	maxSaveAttempts = 3
+)
+
+// collabKey identifies the session of a note.
+type collabKey struct {
+	workspaceId int
+	noteId      int
+}
+
+// MemberLookup looks up the current membership of a user in a workspace,
+// like WorkspaceService.Member.
+type MemberLookup interface {
+	Member(userId int, workspaceId int) (*models.Member, error)
+}
+
+// CollabHub runs the collaborative editing sessions of notes. The editors
+// of a note share a session, which merges their changes to the text as a
+// CRDT, relays them to the others and periodically stores the text as the
+// content of the note. Changes made to the note outside of the session are
+// merged into it when it is stored.
+type CollabHub struct {
+	repo repository.NoteRepository
+
+	mu       sync.Mutex
+	sessions map[collabKey]*collabSession
+	closed   bool
+	wg       sync.WaitGroup
+
+	// SaveInterval is how often the text of a session is stored.
+	SaveInterval time.Duration
+	// AccessInterval is how often the access of the editors to the note of
+	// their session is checked again.
+	AccessInterval time.Duration
+	// Members looks up the current role of editors in their workspace, if
+	// it is set. Otherwise only the shares of the note are checked again.
+	Members MemberLookup
+	// Events receives the updates sessions make to notes, if it is set.
+	Events EventPublisher
+}
+
+// NewCollabHub creates a new CollabHub with the default save and access
+// intervals.
+func NewCollabHub(repo repository.NoteRepository) *CollabHub {
+	return &CollabHub{
+		repo: repo, sessions: map[collabKey]*collabSession{},
+		SaveInterval: DefaultCollabSaveInterval, AccessInterval: DefaultCollabAccessInterval,
+	}
+}
+
+// collabSession is the session of a note. Its fields are guarded by mu,
+// which is held while the text is stored so that no changes are made
+// meanwhile.
+type collabSession struct {
+	hub  *CollabHub
+	key  collabKey
+	done chan struct{}
+
+	mu            sync.Mutex
+	doc           *crdt.Doc
+	title         string
+	version       int
+	dirty         bool
+	savedText     string
+	savedIds      []crdt.Id
+	collaborators map[int]*Collaborator
+	nextSite      int
+	ended         bool
+}
+
+// Collaborator is an editor in a session. Messages for them are received
+// from Messages, and their own are passed to Apply. member and readOnly do
+// not change after they joined.
+type Collaborator struct {
+	session  *collabSession
+	member   models.Member
+	site     int
+	readOnly bool
+	cursor   *crdt.Id
+	anchor   *crdt.Id
+	messages chan *models.CollabMessage
+	left     bool
+	err      error
+}
+
+// Join adds a member to the session of a note, starting it if they are the
+// first. Viewers of the note, and editors if readOnly is set, can follow
+// the changes but not make any. The first message is a snapshot of the
+// text. Leave must be called once the member is done.
+// It returns ErrInvalidId if the ID is less than 1, ErrNoteNotFound if the
+// note is not in the workspace, ErrForbidden if the member cannot view it
+// and ErrCollabClosed if the hub was closed.
+func (h *CollabHub) Join(ctx context.Context, m models.Member, noteId int, readOnly bool) (*Collaborator, error) {
+	if noteId < 1 {
+		return nil, &Error{"JoinCollab", noteId, fmt.Errorf("%w: %v", ErrInvalidId, noteId)}
+	}
+	if err := authorizeNote(ctx, h.repo, "JoinCollab", m, noteId, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	if !readOnly {
+		err := authorizeNote(ctx, h.repo, "JoinCollab", m, noteId, models.RoleEditor)
+		if err != nil && !errors.Is(err, ErrForbidden) {
+			return nil, err
+		}
+		readOnly = err != nil
+	}
+
+	key := collabKey{m.WorkspaceId, noteId}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, &Error{"JoinCollab", noteId, ErrCollabClosed}
+	}
+	s, ok := h.sessions[key]
+	if !ok {
+		note, err := h.repo.Get(ctx, m.WorkspaceId, noteId)
+		if err != nil {
+			return nil, err
+		}
+		s = h.start(key, note)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextSite++
+	c := &Collaborator{session: s, member: m, site: s.nextSite, readOnly: readOnly, messages: make(chan *models.CollabMessage, collabBuffer)}
+	editors := make([]*models.CollabEditor, 0, len(s.collaborators))
+	for _, other := range s.collaborators {
+		editors = append(editors, other.editor())
+	}
+	c.messages <- &models.CollabMessage{
+		Type: models.CollabSnapshot, Site: c.site, UserId: m.UserId, ReadOnly: readOnly,
+		Elements: s.doc.Elements(), Clock: s.doc.Clock(), Title: s.title, Version: s.version, Editors: editors,
+	}
+	s.collaborators[c.site] = c
+	s.broadcast(c, &models.CollabMessage{Type: models.CollabPresence, Site: c.site, UserId: m.UserId, ReadOnly: readOnly})
+	return c, nil
+}
+
+// start starts the session of a note, which is stored every SaveInterval
+// until it ends. h.mu must be held.
+func (h *CollabHub) start(key collabKey, note *models.Note) *collabSession {
+	doc := crdt.NewDoc(note.Content)
+	s := &collabSession{
+		hub: h, key: key, done: make(chan struct{}),
+		doc: doc, title: note.Title, version: note.Version,
+		savedText: note.Content, savedIds: doc.VisibleIds(),
+		collaborators: map[int]*Collaborator{},
+	}
+	h.sessions[key] = s
+	h.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// Close removes all editors from their sessions and waits until the
+// sessions have stored their text. Joining fails afterwards.
+func (h *CollabHub) Close() {
+	h.mu.Lock()
+	h.closed = true
+	for _, s := range h.sessions {
+		s.mu.Lock()
+		for _, c := range s.collaborators {
+			s.remove(c, ErrCollabClosed)
+		}
+		s.end()
+		s.mu.Unlock()
+	}
+	h.mu.Unlock()
+	h.wg.Wait()
+}
+
+// Messages returns the channel the messages for the collaborator are sent
+// on. It is closed when they leave or are removed from the session, see
+// Err.
+func (c *Collaborator) Messages() <-chan *models.CollabMessage {
+	return c.messages
+}
+
+// Err returns why the collaborator was removed from the session, or nil.
+func (c *Collaborator) Err() error {
+	c.session.mu.Lock()
+	defer c.session.mu.Unlock()
+	return c.err
+}
+
+// Apply applies a message of the collaborator to the text and relays it to
+// the other editors. Editors can insert, delete and move their cursor;
+// read-only collaborators can only move their cursor. As notes must have
+// content, the text cannot be deleted entirely; to replace it, the new text
+// is inserted before the old one is deleted.
+// It returns ErrInvalidCollabMessage if the message is not valid or would
+// empty the text,
+// ErrForbidden if a read-only collaborator changes the text and
+// ErrCollabClosed if they were removed from the session.
+func (c *Collaborator) Apply(msg *models.CollabMessage) error {
+	s := c.session
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.left {
+		return &Error{"ApplyCollab", s.key.noteId, ErrCollabClosed}
+	}
+	if msg == nil {
+		return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: empty message", ErrInvalidCollabMessage)}
+	}
+	if c.readOnly && msg.Type != models.CollabPresence {
+		return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: %s role required", ErrForbidden, models.RoleEditor)}
+	}
+
+	relayed := &models.CollabMessage{Type: msg.Type, Site: c.site, UserId: c.member.UserId}
+	switch msg.Type {
+	case models.CollabInsert:
+		if msg.Id == nil || msg.Id.Site != c.site {
+			return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: insert must have an id of site %d", ErrInvalidCollabMessage, c.site)}
+		}
+		op := crdt.Insert{Id: *msg.Id, Text: msg.Text}
+		if msg.After != nil {
+			op.After = *msg.After
+		}
+		if err := s.doc.Insert(op); err != nil {
+			return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: %w", ErrInvalidCollabMessage, err)}
+		}
+		relayed.Id, relayed.After, relayed.Text = &op.Id, &op.After, op.Text
+		s.dirty = true
+	case models.CollabDelete:
+		if s.empties(msg.Ids) {
+			return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: delete would leave the note without content", ErrInvalidCollabMessage)}
+		}
+		n, err := s.doc.Delete(crdt.Delete{Ids: msg.Ids})
+		if err != nil {
+			return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: %w", ErrInvalidCollabMessage, err)}
+		}
+		if n == 0 {
+			return nil
+		}
+		relayed.Ids = msg.Ids
+		s.dirty = true
+	case models.CollabPresence:
+		for _, id := range []*crdt.Id{msg.Cursor, msg.Anchor} {
+			if id != nil && !s.doc.Has(*id) {
+				return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: %w: %v", ErrInvalidCollabMessage, crdt.ErrUnknownId, *id)}
+			}
+		}
+		c.cursor, c.anchor = msg.Cursor, msg.Anchor
+		relayed.Cursor, relayed.Anchor, relayed.ReadOnly = msg.Cursor, msg.Anchor, c.readOnly
+	default:
+		return &Error{"ApplyCollab", s.key.noteId, fmt.Errorf("%w: unknown type %q", ErrInvalidCollabMessage, msg.Type)}
+	}
+	s.broadcast(c, relayed)
+	return nil
+}
+
+// Leave removes the collaborator from the session. The session ends and
+// stores its text once the last one leaves.
+func (c *Collaborator) Leave() {
+	s := c.session
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !c.left {
+		s.remove(c, nil)
+	}
+	if len(s.collaborators) == 0 && !s.ended {
+		delete(s.hub.sessions, s.key)
+		s.end()
+	}
+}
+
+// empties reports whether deleting the characters with ids would leave no
+// characters in the text. s.mu must be held.
+func (s *collabSession) empties(ids []crdt.Id) bool {
+	deleted := make(map[crdt.Id]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	for _, id := range s.doc.VisibleIds() {
+		if !deleted[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// editor describes the collaborator to the other editors. s.mu must be
+// held.
+func (c *Collaborator) editor() *models.CollabEditor {
+	return &models.CollabEditor{Site: c.site, UserId: c.member.UserId, ReadOnly: c.readOnly, Cursor: c.cursor, Anchor: c.anchor}
+}
+
+// broadcast sends msg to the editors other than from, which may be nil.
+// Editors who fell behind are removed. s.mu must be held.
+func (s *collabSession) broadcast(from *Collaborator, msg *models.CollabMessage) {
+	var slow []*Collaborator
+	for _, c := range s.collaborators {
+		if c == from {
+			continue
+		}
+		select {
+		case c.messages <- msg:
+		default:
+			slow = append(slow, c)
+		}
+	}
+	for _, c := range slow {
+		if !c.left {
+			s.remove(c, ErrCollabTooSlow)
+		}
+	}
+}
+
+// remove removes c from the session because of err, or because they left
+// if err is nil, and tells the others. s.mu must be held.
+func (s *collabSession) remove(c *Collaborator, err error) {
+	delete(s.collaborators, c.site)
+	c.left, c.err = true, err
+	close(c.messages)
+	s.broadcast(nil, &models.CollabMessage{Type: models.CollabLeave, Site: c.site, UserId: c.member.UserId})
+}
+
+// end stops the session after a final save. s.mu must be held.
+func (s *collabSession) end() {
+	s.ended = true
+	close(s.done)
+}
+
+// run stores the text every SaveInterval and once more when the session
+// ends, and checks the access of the editors every AccessInterval.
+func (s *collabSession) run() {
+	defer s.hub.wg.Done()
+	ticker := time.NewTicker(s.hub.SaveInterval)
+	defer ticker.Stop()
+	access := time.NewTicker(s.hub.AccessInterval)
+	defer access.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.save()
+		case <-access.C:
+			s.checkAccess()
+		case <-s.done:
+			s.save()
+			return
+		}
+	}
+}
+
+// save stores the text as the content of the note if it changed. Changes
+// made to the note outside of the session since it was last stored are
+// merged into the text first, and the new version is announced to the
+// editors. If the note is gone, everyone is removed from the session.
+func (s *collabSession) save() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo, key := s.hub.repo, s.key
+	// The session outlives the requests of its editors, so saves are not
+	// tied to any of them and finish even if everyone has left.
+	ctx := context.Background()
+
+	if !s.dirty {
+		// Pick up changes made outside of the session even if nobody typed.
+		current, err := repo.Get(ctx, key.workspaceId, key.noteId)
+		if err != nil {
+			s.fail(err)
+			return
+		}
+		if current.Version != s.version {
+			s.merge(current)
+			s.broadcast(nil, &models.CollabMessage{Type: models.CollabSaved, Title: s.title, Version: s.version})
+		}
+		return
+	}
+
+	for attempt := 0; attempt < maxSaveAttempts; attempt++ {
+		text := s.doc.Text()
+		note := &models.Note{Title: s.title, Content: text}
+		err := repo.Update(ctx, key.workspaceId, key.noteId, note, s.version)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			current, err := repo.Get(ctx, key.workspaceId, key.noteId)
+			if err != nil {
+				s.fail(err)
+				return
+			}
+			s.merge(current)
+			continue
+		}
+		if err != nil {
+			s.fail(err)
+			return
+		}
+
+		s.version, s.dirty = note.Version, false
+		s.savedText, s.savedIds = text, s.doc.VisibleIds()
+		s.broadcast(nil, &models.CollabMessage{Type: models.CollabSaved, Title: s.title, Version: s.version})
+		if s.hub.Events != nil {
+			s.hub.Events.Publish(models.Event{Type: models.EventNoteUpdated, WorkspaceId: key.workspaceId, NoteId: key.noteId, Note: note})
+		}
+		return
+	}
+	log.Printf("collab: note %d changed during %d attempts to save it", key.noteId, maxSaveAttempts)
+}
+
+// checkAccess removes the editors who can no longer view the note, or no
+// longer edit it while they were editing. Their access is looked up without
+// holding s.mu, so that editing goes on meanwhile.
+func (s *collabSession) checkAccess() {
+	s.mu.Lock()
+	collaborators := make([]*Collaborator, 0, len(s.collaborators))
+	for _, c := range s.collaborators {
+		collaborators = append(collaborators, c)
+	}
+	s.mu.Unlock()
+
+	removed := map[*Collaborator]error{}
+	for _, c := range collaborators {
+		err := s.hub.authorize(c)
+		if errors.Is(err, ErrCollabAccessChanged) {
+			removed[c] = ErrCollabAccessChanged
+		} else if errors.Is(err, repository.ErrNoteNotFound) {
+			removed[c] = err
+		} else if err != nil {
+			log.Printf("collab: checking access to note %d: %v", s.key.noteId, err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c, err := range removed {
+		if !c.left {
+			s.remove(c, err)
+		}
+	}
+}
+
+// authorize checks again that c has the access to the note they joined its
+// session with.
+// It returns ErrCollabAccessChanged if they do not and ErrNoteNotFound if
+// the note is gone.
+func (h *CollabHub) authorize(c *Collaborator) error {
+	ctx := context.Background()
+	noteId := c.session.key.noteId
+	m := c.member
+	if h.Members != nil {
+		current, err := h.Members.Member(m.UserId, m.WorkspaceId)
+		if errors.Is(err, repository.ErrWorkspaceNotFound) {
+			return &Error{"CheckCollabAccess", noteId, ErrCollabAccessChanged}
+		} else if err != nil {
+			return err
+		}
+		m = *current
+	}
+	role := models.RoleEditor
+	if c.readOnly {
+		role = models.RoleViewer
+	}
+	err := authorizeNote(ctx, h.repo, "CheckCollabAccess", m, noteId, role)
+	if errors.Is(err, ErrForbidden) {
+		return &Error{"CheckCollabAccess", noteId, ErrCollabAccessChanged}
+	}
+	return err
+}
+
+// merge applies the changes made to the note outside of the session, from
+// the text that was last stored to the content of current, as changes of
+// site 0 and adopts its title and version. s.mu must be held.
+func (s *collabSession) merge(current *models.Note) {
+	var inserts []crdt.Insert
+	var deleted []crdt.Id
+	pos, after := 0, crdt.Id{}
+	for _, edit := range diff.Words(s.savedText, current.Content) {
+		n := utf8.RuneCountInString(edit.Text)
+		switch edit.Op {
+		case diff.Equal, diff.Delete:
+			if edit.Op == diff.Delete {
+				deleted = append(deleted, s.savedIds[pos:pos+n]...)
+			}
+			pos += n
+			after = s.savedIds[pos-1]
+		case diff.Insert:
+			op := crdt.Insert{Id: crdt.Id{Clock: s.doc.Clock() + 1}, After: after, Text: edit.Text}
+			if err := s.doc.Insert(op); err != nil {
+				// The ids of the saved text are all in the document.
+				panic(err)
+			}
+			inserts = append(inserts, op)
+			after = crdt.Id{Clock: op.Id.Clock + n - 1}
+		}
+	}
+	if len(deleted) > 0 {
+		if _, err := s.doc.Delete(crdt.Delete{Ids: deleted}); err != nil {
+			panic(err)
+		}
+	}
+
+	for _, op := range inserts {
+		s.broadcast(nil, &models.CollabMessage{Type: models.CollabInsert, Id: &op.Id, After: &op.After, Text: op.Text})
+	}
+	if len(deleted) > 0 {
+		s.broadcast(nil, &models.CollabMessage{Type: models.CollabDelete, Ids: deleted})
+	}
+	s.title, s.version = current.Title, current.Version
+
+	// The characters of current are those of the saved text that it kept
+	// and the inserted ones. They keep their order in the document, with
+	// the changes of the editors in between.
+	kept := make(map[crdt.Id]bool, len(s.savedIds))
+	for _, id := range s.savedIds {
+		kept[id] = true
+	}
+	for _, id := range deleted {
+		delete(kept, id)
+	}
+	for _, op := range inserts {
+		for i := 0; i < utf8.RuneCountInString(op.Text); i++ {
+			kept[crdt.Id{Site: op.Id.Site, Clock: op.Id.Clock + i}] = true
+		}
+	}
+	s.savedText, s.savedIds = current.Content, make([]crdt.Id, 0, len(kept))
+	for _, e := range s.doc.Elements() {
+		if kept[e.Id] {
+			s.savedIds = append(s.savedIds, e.Id)
+		}
+	}
+}
+
+// fail handles an error storing the text. If the note is gone, everyone is
+// removed from the session; other errors are retried with the next save.
+// s.mu must be held.
+func (s *collabSession) fail(err error) {
+	if !errors.Is(err, repository.ErrNoteNotFound) {
+		log.Printf("collab: saving note %d: %v", s.key.noteId, err)
+		return
+	}
+	for _, c := range s.collaborators {
+		s.remove(c, err)
+	}
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// EventPublisher receives the changes noteService makes to notes, such as
+// an events.Broker.
+type EventPublisher interface {
+	Publish(e models.Event)
+}
+
+// publish reports a successful change to note id by m to s.Events, if it is
+// set. The event carries a copy of note, which may be nil.
+func (s *noteService) publish(m models.Member, typ models.EventType, id int, note *models.Note) {
+	if s.Events == nil {
+		return
+	}
+	e := models.Event{Type: typ, WorkspaceId: m.WorkspaceId, UserId: m.UserId, NoteId: id}
+	if note != nil {
+		copied := *note
+		copied.Id = id
+		copied.Tags = append([]string(nil), note.Tags...)
+		e.Note = &copied
+	}
+	s.Events.Publish(e)
+}
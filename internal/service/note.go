@@ -1,10 +1,17 @@
 package service
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/JannisK89/notes-api/internal/auth"
 	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/patch"
 	"github.com/JannisK89/notes-api/internal/repository"
 )
 
@@ -13,6 +20,27 @@ var (
 	ErrInvalidId = errors.New("id must be greater than 0")
 	// ErrInvalidNote is returned when the note data is invalid.
 	ErrInvalidNote = errors.New("note must have title and content")
+	// ErrInvalidListOptions is returned when the paging or sorting options
+	// for listing notes are invalid.
+	ErrInvalidListOptions = errors.New("invalid list options")
+	// ErrEmptySearchQuery is returned when a search is made without a query.
+	ErrEmptySearchQuery = errors.New("search query must not be empty")
+	// ErrReadOnlyField is returned when a patch changes a field of a note
+	// that is managed by the server.
+	ErrReadOnlyField = errors.New("patch must not change id, created_at, updated_at, version, deleted_at or notebook_id")
+)
+
+// maxPatchAttempts is how often Patch retries when the note is changed
+// concurrently while the patch is applied.
+const maxPatchAttempts = 3
+
+const (
+	// DefaultPageSize is the number of notes returned per page when no limit
+	// is given.
+	DefaultPageSize = 20
+	// MaxPageSize is the largest number of notes that can be requested per
+	// page.
+	MaxPageSize = 100
 )
 
 // Error represents an error that occurred within the service layer. It
@@ -35,45 +63,157 @@ func (e *Error) Unwrap() error {
 	return e.Err
 }
 
+// ConflictError is returned when a note is written on the condition that it
+// is at a given version, but it has been changed since. Current is the
+// version the note is at now, or 0 if it could not be determined.
+type ConflictError struct {
+	Id      int
+	Version int
+	Current int
+	Err     error
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("NoteService conflict with id %d: expected version %d, current version %d", e.Id, e.Version, e.Current)
+}
+
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
 // noteService implements the NoteService interface.
 type noteService struct {
 	repo repository.NoteRepository
+	now  func() time.Time
+	// Params are the argon2id parameters share link passwords are hashed
+	// with.
+	Params auth.Params
+	// Events receives the notes that are created, updated and deleted, if
+	// it is set.
+	Events EventPublisher
+	// Tx runs the repository calls that make up a change as one unit of
+	// work, if it is set.
+	Tx repository.TxManager
 }
 
-// NewNoteService creates a new noteService.
+// NewNoteService creates a new noteService with the default hashing
+// parameters.
 func NewNoteService(repo repository.NoteRepository) *noteService {
-	return &noteService{repo}
+	return &noteService{repo: repo, now: time.Now, Params: auth.DefaultParams}
+}
+
+// withinTx runs fn in a unit of work of s.Tx, or on its own if s.Tx is not
+// set.
+func (s *noteService) withinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if s.Tx == nil {
+		return fn(ctx)
+	}
+	return s.Tx.WithinTx(ctx, fn)
 }
 
-// Get retrieves a note by its ID from the repository.
+// Get retrieves a note of the workspace by its ID from the repository.
+// Viewers can get it.
 // It returns ErrInvalidId if the ID is less than 1.
-func (s *noteService) Get(id int) (*models.Note, error) {
+func (s *noteService) Get(ctx context.Context, m models.Member, id int) (*models.Note, error) {
 	if id < 1 {
 		return nil, &Error{"GetNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
 	}
-	return s.repo.Get(id)
+	if err := s.authorize(ctx, "GetNote", m, id, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(ctx, m.WorkspaceId, id)
 }
 
-// Create adds a new note to the repository.
+// Create adds a new note to the workspace, owned by the member. Editors of
+// the workspace can create notes.
 // It returns ErrInvalidNote if the note is nil or if the title or content is
-// empty.
-func (s *noteService) Create(note *models.Note) (int, error) {
+// empty and ErrInvalidTag if one of its tags is not valid.
+func (s *noteService) Create(ctx context.Context, m models.Member, note *models.Note) (int, error) {
 	if note == nil || note.Title == "" || note.Content == "" {
 		return 0, &Error{Src: "CreateNote", Err: fmt.Errorf("%w: %v", ErrInvalidNote, note)}
 	}
-	return s.repo.Create(note)
+	tags, err := normalizeTags(note.Tags)
+	if err != nil {
+		return 0, &Error{Src: "CreateNote", Err: err}
+	}
+	if err := requireRole("CreateNote", m, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	note.Tags = tags
+	id, err := s.repo.Create(ctx, m.WorkspaceId, m.UserId, note)
+	if err != nil {
+		return 0, err
+	}
+	s.publish(m, models.EventNoteCreated, id, note)
+	return id, nil
 }
 
-// GetAll retrieves all notes from the repository.
-func (s *noteService) GetAll() ([]*models.Note, error) {
-	return s.repo.GetAll()
+// GetAll retrieves a page of the notes of the workspace from the
+// repository. Members of the workspace can list its notes, guests cannot.
+// It returns ErrInvalidListOptions if the limit is out of range or the sort
+// field is unknown.
+func (s *noteService) GetAll(ctx context.Context, m models.Member, opts models.ListOptions) (*models.NotePage, error) {
+	if err := requireRole("GetAllNotes", m, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	opts.Trashed = false
+	if err := checkListOptions(&opts); err != nil {
+		return nil, &Error{Src: "GetAllNotes", Err: err}
+	}
+	if opts.NotebookId != 0 {
+		if _, err := s.repo.GetNotebookAccess(ctx, m.WorkspaceId, m.UserId, opts.NotebookId); err != nil {
+			return nil, err
+		}
+	}
+	return s.repo.GetAll(ctx, m.WorkspaceId, opts)
+}
+
+// checkListOptions fills in the defaults of opts and validates them. Notes
+// can only be sorted by deletion time when listing the trash.
+func checkListOptions(opts *models.ListOptions) error {
+	tags, err := normalizeTags(opts.Tags)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidListOptions, err)
+	}
+	opts.Tags = tags
+	switch opts.TagMode {
+	case "":
+		opts.TagMode = models.TagModeAll
+	case models.TagModeAll, models.TagModeAny:
+	default:
+		return fmt.Errorf("%w: tag mode must be all or any", ErrInvalidListOptions)
+	}
+
+	if opts.Limit == 0 {
+		opts.Limit = DefaultPageSize
+	}
+	if opts.Limit < 0 || opts.Limit > MaxPageSize {
+		return fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListOptions, MaxPageSize)
+	}
+
+	switch opts.Sort {
+	case "":
+		opts.Sort = models.SortById
+	case models.SortById, models.SortByTitle, models.SortByCreatedAt, models.SortByUpdatedAt:
+	case models.SortByDeletedAt:
+		if opts.Trashed {
+			break
+		}
+		fallthrough
+	default:
+		return fmt.Errorf("%w: unknown sort field %q", ErrInvalidListOptions, opts.Sort)
+	}
+	return nil
 }
 
-// Update modifies an existing note in the repository.
+// Update modifies an existing note in the repository. Its tags are left
+// unchanged if note.Tags is nil. Editors can update it. If
+// version is not 0, the note is only updated if it is still at that version.
 // It returns ErrInvalidId if the ID is less than 1.
 // It returns ErrInvalidNote if the note is nil or if the title or content is
-// empty.
-func (s *noteService) Update(id int, note *models.Note) error {
+// empty and ErrInvalidTag if one of its tags is not valid.
+// It returns a *ConflictError if the note is at a different version.
+func (s *noteService) Update(ctx context.Context, m models.Member, id int, note *models.Note, version int) error {
 	if id < 1 {
 		return &Error{"GetNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
 	}
@@ -81,14 +221,160 @@ func (s *noteService) Update(id int, note *models.Note) error {
 	if note == nil || note.Title == "" || note.Content == "" {
 		return &Error{"CreateNote", 0, ErrInvalidNote}
 	}
-	return s.repo.Update(id, note)
+	tags, err := normalizeTags(note.Tags)
+	if err != nil {
+		return &Error{"UpdateNote", id, err}
+	}
+	note.Tags = tags
+	if err := s.authorize(ctx, "UpdateNote", m, id, models.RoleEditor); err != nil {
+		return err
+	}
+	err = s.repo.Update(ctx, m.WorkspaceId, id, note, version)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return s.conflict(ctx, m.WorkspaceId, id, version, err)
+	}
+	if err != nil {
+		return err
+	}
+	s.publish(m, models.EventNoteUpdated, id, note)
+	return nil
 }
 
-// Delete removes a note from the repository.
+// Delete moves a note to the trash. Only owners can delete a note. If
+// version is not 0, the note is only deleted if it is still at that version.
 // It returns ErrInvalidId if the ID is less than 1.
-func (s *noteService) Delete(id int) error {
+// It returns a *ConflictError if the note is at a different version.
+func (s *noteService) Delete(ctx context.Context, m models.Member, id int, version int) error {
 	if id < 1 {
 		return &Error{"GetNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
 	}
-	return s.repo.Delete(id)
+	if err := s.authorize(ctx, "DeleteNote", m, id, models.RoleOwner); err != nil {
+		return err
+	}
+	err := s.repo.Delete(ctx, m.WorkspaceId, id, version)
+	if errors.Is(err, repository.ErrVersionConflict) {
+		return s.conflict(ctx, m.WorkspaceId, id, version, err)
+	}
+	if err != nil {
+		return err
+	}
+	s.publish(m, models.EventNoteDeleted, id, nil)
+	return nil
+}
+
+// conflict builds the ConflictError for a write to note id at version that
+// failed with err, looking up the version the note is at now.
+func (s *noteService) conflict(ctx context.Context, workspaceId int, id int, version int, err error) *ConflictError {
+	conflict := &ConflictError{Id: id, Version: version, Err: err}
+	if current, getErr := s.repo.Get(ctx, workspaceId, id); getErr == nil {
+		conflict.Current = current.Version
+	}
+	return conflict
+}
+
+// Search finds notes matching a full-text query in the repository. Members
+// of the workspace can search it, guests cannot.
+// It returns ErrEmptySearchQuery if the query is blank and
+// ErrInvalidListOptions if the limit or offset are out of range.
+func (s *noteService) Search(ctx context.Context, m models.Member, opts models.SearchOptions) ([]*models.SearchResult, error) {
+	if err := requireRole("SearchNotes", m, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	opts.Query = strings.TrimSpace(opts.Query)
+	if opts.Query == "" {
+		return nil, &Error{Src: "SearchNotes", Err: ErrEmptySearchQuery}
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultPageSize
+	}
+	if opts.Limit < 0 || opts.Limit > MaxPageSize {
+		return nil, &Error{Src: "SearchNotes", Err: fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListOptions, MaxPageSize)}
+	}
+	if opts.Offset < 0 {
+		return nil, &Error{Src: "SearchNotes", Err: fmt.Errorf("%w: offset must not be negative", ErrInvalidListOptions)}
+	}
+	return s.repo.Search(ctx, m.WorkspaceId, opts)
+}
+
+// Patch applies a JSON Merge Patch or JSON Patch to a note and stores the
+// result if it is a valid note. The patch is applied to the JSON form of the
+// note. It either applies completely or not at all, and is never applied on
+// top of a concurrent change. Editors can patch it. If
+// version is not 0, the note is only patched if it is still at that version.
+// It returns ErrInvalidId if the ID is less than 1.
+// It returns ErrInvalidNote if the patched note has no title or content,
+// ErrInvalidTag if one of its tags is not valid and ErrReadOnlyField if the
+// patch changes a field managed by the server.
+// It returns a *ConflictError if the note is at a different version.
+func (s *noteService) Patch(ctx context.Context, m models.Member, id int, p patch.Patch, version int) (*models.Note, error) {
+	if id < 1 {
+		return nil, &Error{"PatchNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+
+	if err := s.authorize(ctx, "PatchNote", m, id, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	for attempt := 1; ; attempt++ {
+		note, err := s.repo.Get(ctx, m.WorkspaceId, id)
+		if err != nil {
+			return nil, err
+		}
+		if version != 0 && note.Version != version {
+			return nil, &ConflictError{Id: id, Version: version, Current: note.Version, Err: repository.ErrVersionConflict}
+		}
+
+		patched, err := applyPatch(note, p)
+		if err != nil {
+			return nil, &Error{"PatchNote", id, err}
+		}
+
+		// Only write the note if it has not changed since it was read.
+		err = s.repo.Update(ctx, m.WorkspaceId, id, patched, note.Version)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			if version == 0 && attempt < maxPatchAttempts {
+				continue
+			}
+			return nil, s.conflict(ctx, m.WorkspaceId, id, note.Version, err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.publish(m, models.EventNoteUpdated, id, patched)
+		return patched, nil
+	}
+}
+
+// applyPatch returns the note resulting from applying p to note.
+func applyPatch(note *models.Note, p patch.Patch) (*models.Note, error) {
+	doc, err := json.Marshal(note)
+	if err != nil {
+		return nil, err
+	}
+	doc, err = p.Apply(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	patched := &models.Note{}
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(patched); err != nil {
+		return nil, fmt.Errorf("%w: %v", patch.ErrInvalidPatch, err)
+	}
+	if patched.Id != note.Id || !patched.CreatedAt.Equal(note.CreatedAt) ||
+		!patched.UpdatedAt.Equal(note.UpdatedAt) || patched.Version != note.Version || patched.DeletedAt != nil ||
+		!sameNotebook(patched.NotebookId, note.NotebookId) {
+		return nil, ErrReadOnlyField
+	}
+	if patched.Title == "" || patched.Content == "" {
+		return nil, ErrInvalidNote
+	}
+	// Patching the tags away leaves the note without tags.
+	if patched.Tags == nil {
+		patched.Tags = []string{}
+	}
+	if patched.Tags, err = normalizeTags(patched.Tags); err != nil {
+		return nil, err
+	}
+	return patched, nil
 }
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+var (
+	// ErrInvalidNotebook is returned when the notebook data is invalid.
+	ErrInvalidNotebook = errors.New("invalid notebook")
+	// ErrInvalidDeleteMode is returned when a notebook is deleted with an
+	// unknown delete mode.
+	ErrInvalidDeleteMode = errors.New("delete mode must be block, trash or move")
+)
+
+// MaxNotebookNameLength is the maximum length of a notebook name in
+// characters.
+const MaxNotebookNameLength = 100
+
+// normalizeNotebookName trims a notebook name.
+// It returns ErrInvalidNotebook if the name is empty or too long.
+func normalizeNotebookName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxNotebookNameLength {
+		return "", fmt.Errorf("%w: name must have 1 to %d characters", ErrInvalidNotebook, MaxNotebookNameLength)
+	}
+	return name, nil
+}
+
+// sameNotebook reports whether a and b refer to the same notebook or both to
+// none.
+func sameNotebook(a *int, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// notebookService implements the NotebookService interface.
+type notebookService struct {
+	repo repository.NotebookRepository
+}
+
+// NewNotebookService creates a new notebookService.
+func NewNotebookService(repo repository.NotebookRepository) *notebookService {
+	return &notebookService{repo}
+}
+
+// Get retrieves a notebook of the workspace by its ID from the repository.
+// Viewers can get it.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *notebookService) Get(m models.Member, id int) (*models.Notebook, error) {
+	if id < 1 {
+		return nil, &Error{"GetNotebook", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := s.authorize("GetNotebook", m, id, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(m.WorkspaceId, id)
+}
+
+// GetAll retrieves all notebooks of the workspace from the repository,
+// parents before their children. Guests of the workspace cannot list them.
+func (s *notebookService) GetAll(m models.Member) ([]*models.Notebook, error) {
+	if err := requireRole("GetAllNotebooks", m, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(m.WorkspaceId)
+}
+
+// Create adds a new notebook to the workspace, owned by the member. Editors
+// of the workspace can create notebooks.
+// It returns ErrInvalidNotebook if the notebook is nil or its name is not
+// valid and ErrInvalidId if its parent ID is less than 1.
+func (s *notebookService) Create(m models.Member, notebook *models.Notebook) (int, error) {
+	if notebook == nil {
+		return 0, &Error{Src: "CreateNotebook", Err: ErrInvalidNotebook}
+	}
+	name, err := normalizeNotebookName(notebook.Name)
+	if err != nil {
+		return 0, &Error{Src: "CreateNotebook", Err: err}
+	}
+	if notebook.ParentId != nil && *notebook.ParentId < 1 {
+		return 0, &Error{Src: "CreateNotebook", Err: fmt.Errorf("%w: %v", ErrInvalidId, *notebook.ParentId)}
+	}
+	if err := requireRole("CreateNotebook", m, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	notebook.Name = name
+	return s.repo.Create(m.WorkspaceId, m.UserId, notebook)
+}
+
+// Rename changes the name of a notebook and returns the renamed notebook.
+// It returns ErrInvalidId if the ID is less than 1 and ErrInvalidNotebook if
+// the name is not valid.
+func (s *notebookService) Rename(m models.Member, id int, name string) (*models.Notebook, error) {
+	if id < 1 {
+		return nil, &Error{"RenameNotebook", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	name, err := normalizeNotebookName(name)
+	if err != nil {
+		return nil, &Error{"RenameNotebook", id, err}
+	}
+	if err := s.authorize("RenameNotebook", m, id, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Rename(m.WorkspaceId, id, name); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(m.WorkspaceId, id)
+}
+
+// Move moves a notebook into another notebook, or to the top level if
+// parentId is nil, and returns the moved notebook.
+// It returns ErrInvalidId if either ID is less than 1.
+func (s *notebookService) Move(m models.Member, id int, parentId *int) (*models.Notebook, error) {
+	if id < 1 {
+		return nil, &Error{"MoveNotebook", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if parentId != nil && *parentId < 1 {
+		return nil, &Error{"MoveNotebook", id, fmt.Errorf("%w: %v", ErrInvalidId, *parentId)}
+	}
+	if err := s.authorize("MoveNotebook", m, id, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Move(m.WorkspaceId, id, parentId); err != nil {
+		return nil, err
+	}
+	return s.repo.Get(m.WorkspaceId, id)
+}
+
+// Delete removes a notebook, treating its contents according to mode, which
+// defaults to NotebookDeleteBlock.
+// It returns ErrInvalidId if the ID is less than 1 and ErrInvalidDeleteMode
+// if the mode is unknown.
+func (s *notebookService) Delete(m models.Member, id int, mode models.NotebookDeleteMode) error {
+	if id < 1 {
+		return &Error{"DeleteNotebook", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	switch mode {
+	case "":
+		mode = models.NotebookDeleteBlock
+	case models.NotebookDeleteBlock, models.NotebookDeleteTrash, models.NotebookDeleteMove:
+	default:
+		return &Error{"DeleteNotebook", id, fmt.Errorf("%w: %q", ErrInvalidDeleteMode, mode)}
+	}
+	if err := s.authorize("DeleteNotebook", m, id, models.RoleOwner); err != nil {
+		return err
+	}
+	return s.repo.Delete(m.WorkspaceId, id, mode)
+}
+
+// Move files a note in another notebook, or in none if notebookId is nil,
+// and returns the moved note. If version is not 0, the note is only moved if
+// it is still at that version.
+// It returns ErrInvalidId if either ID is less than 1.
+// It returns a *ConflictError if the note is at a different version.
+func (s *noteService) Move(ctx context.Context, m models.Member, id int, notebookId *int, version int) (*models.Note, error) {
+	if id < 1 {
+		return nil, &Error{"MoveNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if notebookId != nil && *notebookId < 1 {
+		return nil, &Error{"MoveNote", id, fmt.Errorf("%w: %v", ErrInvalidId, *notebookId)}
+	}
+	if err := s.authorize(ctx, "MoveNote", m, id, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	// The note is read back in the same unit of work so that it is returned
+	// as moved, not as changed by a later write.
+	var note *models.Note
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		err := s.repo.MoveNote(ctx, m.WorkspaceId, id, notebookId, version)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return s.conflict(ctx, m.WorkspaceId, id, version, err)
+		}
+		if err != nil {
+			return err
+		}
+		note, err = s.repo.Get(ctx, m.WorkspaceId, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publish(m, models.EventNoteUpdated, id, note)
+	return note, nil
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/JannisK89/notes-api/internal/diff"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+// ErrInvalidDiffFormat is returned when a diff is requested in an unknown
+// format.
+var ErrInvalidDiffFormat = errors.New("diff format must be unified or words")
+
+// diffContext is the number of unchanged lines shown around each change in
+// a unified diff.
+const diffContext = 3
+
+// GetRevisions retrieves the revisions of a note, newest first.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *noteService) GetRevisions(ctx context.Context, m models.Member, id int) ([]*models.Revision, error) {
+	if id < 1 {
+		return nil, &Error{"GetRevisions", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := s.authorize(ctx, "GetRevisions", m, id, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevisions(ctx, m.WorkspaceId, id)
+}
+
+// GetRevision retrieves the revision of a note at the given version.
+// It returns ErrInvalidId if the ID or version is less than 1.
+func (s *noteService) GetRevision(ctx context.Context, m models.Member, id int, version int) (*models.Revision, error) {
+	if id < 1 || version < 1 {
+		return nil, &Error{"GetRevision", id, fmt.Errorf("%w: %v/%v", ErrInvalidId, id, version)}
+	}
+	if err := s.authorize(ctx, "GetRevision", m, id, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRevision(ctx, m.WorkspaceId, id, version)
+}
+
+// DiffRevisions compares two revisions of a note.
+// It returns ErrInvalidId if the ID or a version is less than 1 and
+// ErrInvalidDiffFormat if the format is unknown. An empty format defaults to
+// a unified diff.
+func (s *noteService) DiffRevisions(ctx context.Context, m models.Member, id int, from int, to int, format models.DiffFormat) (*models.RevisionDiff, error) {
+	if format == "" {
+		format = models.DiffUnified
+	}
+	if format != models.DiffUnified && format != models.DiffWords {
+		return nil, &Error{"DiffRevisions", id, fmt.Errorf("%w: %q", ErrInvalidDiffFormat, format)}
+	}
+
+	fromRev, err := s.GetRevision(ctx, m, id, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := s.GetRevision(ctx, m, id, to)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &models.RevisionDiff{From: from, To: to, Title: diff.Words(fromRev.Title, toRev.Title)}
+	if format == models.DiffWords {
+		d.Content = diff.Words(fromRev.Content, toRev.Content)
+	} else {
+		d.Unified = diff.Unified(fmt.Sprintf("version %d", from), fmt.Sprintf("version %d", to), fromRev.Content, toRev.Content, diffContext)
+	}
+	return d, nil
+}
+
+// RestoreRevision sets the title and content of a note back to those of one
+// of its revisions. This records a new revision rather than discarding the
+// ones after it. Editors of a shared note can restore it. If version is not
+// 0, the note is only restored if it is still at that version.
+// It returns ErrInvalidId if the ID or revision is less than 1.
+// It returns a *ConflictError if the note is at a different version.
+func (s *noteService) RestoreRevision(ctx context.Context, m models.Member, id int, revision int, version int) (*models.Note, error) {
+	if id < 1 || revision < 1 {
+		return nil, &Error{"RestoreRevision", id, fmt.Errorf("%w: %v/%v", ErrInvalidId, id, revision)}
+	}
+	if err := s.authorize(ctx, "RestoreRevision", m, id, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	var note *models.Note
+	err := s.withinTx(ctx, func(ctx context.Context) error {
+		rev, err := s.repo.GetRevision(ctx, m.WorkspaceId, id, revision)
+		if err != nil {
+			return err
+		}
+
+		note = &models.Note{Title: rev.Title, Content: rev.Content}
+		err = s.repo.Update(ctx, m.WorkspaceId, id, note, version)
+		if errors.Is(err, repository.ErrVersionConflict) {
+			return s.conflict(ctx, m.WorkspaceId, id, version, err)
+		}
+		if err != nil {
+			return err
+		}
+		// The tags are not part of revisions and were left as they are.
+		current, err := s.repo.Get(ctx, m.WorkspaceId, id)
+		if err != nil {
+			return err
+		}
+		note.Tags = current.Tags
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.publish(m, models.EventNoteUpdated, id, note)
+	return note, nil
+}
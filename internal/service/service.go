@@ -1,11 +1,86 @@
 package service
 
-import "github.com/JannisK89/notes-api/internal/models"
+import (
+	"context"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/patch"
+)
 
 type NoteService interface {
-	Get(id int) (*models.Note, error)
-	Create(note *models.Note) (int, error)
-	GetAll() ([]*models.Note, error)
-	Update(id int, note *models.Note) error
-	Delete(id int) error
+	Get(ctx context.Context, m models.Member, id int) (*models.Note, error)
+	Create(ctx context.Context, m models.Member, note *models.Note) (int, error)
+	GetAll(ctx context.Context, m models.Member, opts models.ListOptions) (*models.NotePage, error)
+	Update(ctx context.Context, m models.Member, id int, note *models.Note, version int) error
+	Delete(ctx context.Context, m models.Member, id int, version int) error
+	Patch(ctx context.Context, m models.Member, id int, p patch.Patch, version int) (*models.Note, error)
+	Search(ctx context.Context, m models.Member, opts models.SearchOptions) ([]*models.SearchResult, error)
+	GetTrash(ctx context.Context, m models.Member, opts models.ListOptions) (*models.NotePage, error)
+	Restore(ctx context.Context, m models.Member, id int) (*models.Note, error)
+	Purge(ctx context.Context, m models.Member, id int) error
+	GetRevisions(ctx context.Context, m models.Member, id int) ([]*models.Revision, error)
+	GetRevision(ctx context.Context, m models.Member, id int, version int) (*models.Revision, error)
+	DiffRevisions(ctx context.Context, m models.Member, id int, from int, to int, format models.DiffFormat) (*models.RevisionDiff, error)
+	RestoreRevision(ctx context.Context, m models.Member, id int, revision int, version int) (*models.Note, error)
+	GetTags(ctx context.Context, m models.Member) ([]*models.Tag, error)
+	RenameTag(ctx context.Context, m models.Member, name string, newName string) (int, error)
+	MergeTags(ctx context.Context, m models.Member, source string, target string) (int, error)
+	Move(ctx context.Context, m models.Member, id int, notebookId *int, version int) (*models.Note, error)
+	GetShares(ctx context.Context, m models.Member, id int) ([]*models.Share, error)
+	Share(ctx context.Context, m models.Member, id int, email string, role models.Role) (*models.Share, error)
+	Unshare(ctx context.Context, m models.Member, id int, collaboratorId int) error
+	GetShared(ctx context.Context, m models.Member) ([]*models.SharedNote, error)
+	CreateShareLink(ctx context.Context, m models.Member, noteId int, link *models.ShareLink, password string) (*models.ShareLink, error)
+	GetShareLinks(ctx context.Context, m models.Member, noteId int) ([]*models.ShareLink, error)
+	DeleteShareLink(ctx context.Context, m models.Member, noteId int, id int) error
+	ViewShareLink(ctx context.Context, token string, password string) (*models.PublicNote, error)
+	GetChanges(ctx context.Context, m models.Member, since int64, limit int) (*models.ChangePage, error)
+	Push(ctx context.Context, m models.Member, changes []*models.PushChange) ([]*models.PushResult, error)
+}
+
+type NotebookService interface {
+	Get(m models.Member, id int) (*models.Notebook, error)
+	GetAll(m models.Member) ([]*models.Notebook, error)
+	Create(m models.Member, notebook *models.Notebook) (int, error)
+	Rename(m models.Member, id int, name string) (*models.Notebook, error)
+	Move(m models.Member, id int, parentId *int) (*models.Notebook, error)
+	Delete(m models.Member, id int, mode models.NotebookDeleteMode) error
+	GetShares(m models.Member, id int) ([]*models.Share, error)
+	Share(m models.Member, id int, email string, role models.Role) (*models.Share, error)
+	Unshare(m models.Member, id int, collaboratorId int) error
+	GetShared(m models.Member) ([]*models.SharedNotebook, error)
+}
+
+type UserService interface {
+	Register(email string, password string) (*models.User, error)
+	Login(email string, password string) (*models.Session, error)
+	Logout(token string) error
+	Authenticate(token string) (*models.User, error)
+	CreateAPIKey(userId int, key *models.APIKey) (*models.APIKey, error)
+	GetAPIKeys(userId int) ([]*models.APIKey, error)
+	DeleteAPIKey(userId int, id int) error
+	AuthenticateAPIKey(key string) (*models.User, *models.APIKey, error)
+	CreateTicket(userId int) (*models.Ticket, error)
+	AuthenticateTicket(ticket string) (*models.User, error)
+}
+
+type WorkspaceService interface {
+	Member(userId int, workspaceId int) (*models.Member, error)
+	GetAll(userId int) ([]*models.Workspace, error)
+	Create(userId int, workspace *models.Workspace) (int, error)
+	GetMembers(m models.Member) ([]*models.WorkspaceMember, error)
+	AddMember(m models.Member, email string, role models.Role) (*models.WorkspaceMember, error)
+	RemoveMember(m models.Member, userId int) error
+}
+
+type WebhookService interface {
+	GetAll(m models.Member) ([]*models.Webhook, error)
+	Create(m models.Member, webhook *models.Webhook) (*models.Webhook, error)
+	Delete(m models.Member, id int) error
+	GetDeliveries(m models.Member, id int) ([]*models.WebhookDelivery, error)
+	Redeliver(m models.Member, id int, deliveryId int) (*models.WebhookDelivery, error)
+}
+
+type CollabService interface {
+	Join(ctx context.Context, m models.Member, noteId int, readOnly bool) (*Collaborator, error)
 }
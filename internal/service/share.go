@@ -0,0 +1,177 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+var (
+	// ErrForbidden is returned when a user can see a note or notebook but
+	// their role does not allow the operation.
+	ErrForbidden = errors.New("insufficient permission")
+	// ErrInvalidShare is returned when a note or notebook is shared without
+	// an email or with a role other than viewer or editor.
+	ErrInvalidShare = errors.New("share must have an email and a role of viewer or editor")
+)
+
+// checkShare trims the email of a share and validates it and the role.
+// It returns ErrInvalidShare if either is not valid.
+func checkShare(email string, role models.Role) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || (role != models.RoleViewer && role != models.RoleEditor) {
+		return "", ErrInvalidShare
+	}
+	return email, nil
+}
+
+// authorize checks that a member has at least role on a note of their
+// workspace, either through their role in the workspace or because they
+// created the note or it was shared with them.
+// It returns ErrNoteNotFound if the note is not in the workspace and
+// ErrForbidden if the role of the member is too low.
+func (s *noteService) authorize(ctx context.Context, src string, m models.Member, id int, role models.Role) error {
+	return authorizeNote(ctx, s.repo, src, m, id, role)
+}
+
+// authorizeNote implements authorize for services that share the
+// repository of notes.
+func authorizeNote(ctx context.Context, repo repository.NoteRepository, src string, m models.Member, id int, role models.Role) error {
+	granted, err := repo.GetNoteAccess(ctx, m.WorkspaceId, m.UserId, id)
+	if err != nil {
+		return err
+	}
+	if !m.Role.Includes(role) && !granted.Includes(role) {
+		return &Error{src, id, fmt.Errorf("%w: %s role required", ErrForbidden, role)}
+	}
+	return nil
+}
+
+// GetShares retrieves the owner of a note followed by the users it is
+// shared with. Anyone with access to the note can see them.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *noteService) GetShares(ctx context.Context, m models.Member, id int) ([]*models.Share, error) {
+	if id < 1 {
+		return nil, &Error{"GetNoteShares", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := s.authorize(ctx, "GetNoteShares", m, id, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetNoteShares(ctx, m.WorkspaceId, id)
+}
+
+// Share grants the user with the given email role on a note, or changes
+// their role. The user does not need to be a member of the workspace. Only
+// owners can share a note.
+// It returns ErrInvalidId if the ID is less than 1 and ErrInvalidShare if
+// the email or role is not valid.
+func (s *noteService) Share(ctx context.Context, m models.Member, id int, email string, role models.Role) (*models.Share, error) {
+	if id < 1 {
+		return nil, &Error{"ShareNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	email, err := checkShare(email, role)
+	if err != nil {
+		return nil, &Error{"ShareNote", id, err}
+	}
+	if err := s.authorize(ctx, "ShareNote", m, id, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.ShareNote(ctx, m.WorkspaceId, id, email, role)
+}
+
+// Unshare stops sharing a note with a user. Owners can revoke anyone's
+// access and collaborators can give up their own.
+// It returns ErrInvalidId if either ID is less than 1.
+func (s *noteService) Unshare(ctx context.Context, m models.Member, id int, collaboratorId int) error {
+	if id < 1 || collaboratorId < 1 {
+		return &Error{"UnshareNote", id, fmt.Errorf("%w: %v/%v", ErrInvalidId, id, collaboratorId)}
+	}
+	role := models.RoleOwner
+	if collaboratorId == m.UserId {
+		role = models.RoleViewer
+	}
+	if err := s.authorize(ctx, "UnshareNote", m, id, role); err != nil {
+		return err
+	}
+	return s.repo.RevokeNoteShare(ctx, m.WorkspaceId, id, collaboratorId)
+}
+
+// GetShared retrieves the notes of the workspace other users shared with a
+// member.
+func (s *noteService) GetShared(ctx context.Context, m models.Member) ([]*models.SharedNote, error) {
+	return s.repo.GetSharedNotes(ctx, m.WorkspaceId, m.UserId)
+}
+
+// authorize checks that a member has at least role on a notebook of their
+// workspace, like noteService.authorize.
+// It returns ErrNotebookNotFound if the notebook is not in the workspace
+// and ErrForbidden if the role of the member is too low.
+func (s *notebookService) authorize(src string, m models.Member, id int, role models.Role) error {
+	granted, err := s.repo.GetNotebookAccess(m.WorkspaceId, m.UserId, id)
+	if err != nil {
+		return err
+	}
+	if !m.Role.Includes(role) && !granted.Includes(role) {
+		return &Error{src, id, fmt.Errorf("%w: %s role required", ErrForbidden, role)}
+	}
+	return nil
+}
+
+// GetShares retrieves the owner of a notebook followed by the users it is
+// shared with. Anyone with access to the notebook can see them.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *notebookService) GetShares(m models.Member, id int) ([]*models.Share, error) {
+	if id < 1 {
+		return nil, &Error{"GetNotebookShares", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := s.authorize("GetNotebookShares", m, id, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetNotebookShares(m.WorkspaceId, id)
+}
+
+// Share grants the user with the given email role on a notebook and
+// everything below it, or changes their role. The user does not need to be
+// a member of the workspace. Only owners can share a notebook.
+// It returns ErrInvalidId if the ID is less than 1 and ErrInvalidShare if
+// the email or role is not valid.
+func (s *notebookService) Share(m models.Member, id int, email string, role models.Role) (*models.Share, error) {
+	if id < 1 {
+		return nil, &Error{"ShareNotebook", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	email, err := checkShare(email, role)
+	if err != nil {
+		return nil, &Error{"ShareNotebook", id, err}
+	}
+	if err := s.authorize("ShareNotebook", m, id, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.ShareNotebook(m.WorkspaceId, id, email, role)
+}
+
+// Unshare stops sharing a notebook with a user. Owners can revoke anyone's
+// access and collaborators can give up their own.
+// It returns ErrInvalidId if either ID is less than 1.
+func (s *notebookService) Unshare(m models.Member, id int, collaboratorId int) error {
+	if id < 1 || collaboratorId < 1 {
+		return &Error{"UnshareNotebook", id, fmt.Errorf("%w: %v/%v", ErrInvalidId, id, collaboratorId)}
+	}
+	role := models.RoleOwner
+	if collaboratorId == m.UserId {
+		role = models.RoleViewer
+	}
+	if err := s.authorize("UnshareNotebook", m, id, role); err != nil {
+		return err
+	}
+	return s.repo.RevokeNotebookShare(m.WorkspaceId, id, collaboratorId)
+}
+
+// GetShared retrieves the notebooks of the workspace other users shared
+// with a member.
+func (s *notebookService) GetShared(m models.Member) ([]*models.SharedNotebook, error) {
+	return s.repo.GetSharedNotebooks(m.WorkspaceId, m.UserId)
+}
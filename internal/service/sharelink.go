@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+var (
+	// ErrInvalidShareLink is returned when a share link is created with an
+	// expiry that has passed, a view limit below 1 or a password that is too
+	// short or too long.
+	ErrInvalidShareLink = errors.New("invalid share link")
+	// ErrInvalidSharePassword is returned when a share link with a password
+	// is viewed without it or with a wrong one.
+	ErrInvalidSharePassword = errors.New("share link password missing or wrong")
+)
+
+// shareLinkPrefixLength is how many characters of a share link token are
+// kept to identify it.
+const shareLinkPrefixLength = 8
+
+// CreateShareLink creates a share link to a note with the expiry and view
+// limit of link, both optional, and an optional password. Only the owner
+// can create share links. The returned link is the only place its token can
+// be read, since only its hash is stored.
+// It returns ErrInvalidId if the ID is less than 1 and ErrInvalidShareLink
+// if the expiry, view limit or password is not valid.
+func (s *noteService) CreateShareLink(ctx context.Context, m models.Member, noteId int, link *models.ShareLink, password string) (*models.ShareLink, error) {
+	if noteId < 1 {
+		return nil, &Error{"CreateShareLink", noteId, fmt.Errorf("%w: %v", ErrInvalidId, noteId)}
+	}
+	if link == nil {
+		link = &models.ShareLink{}
+	}
+	var expiresAt *time.Time
+	if link.ExpiresAt != nil {
+		if !link.ExpiresAt.After(s.now()) {
+			return nil, &Error{"CreateShareLink", noteId, fmt.Errorf("%w: expires_at must be in the future", ErrInvalidShareLink)}
+		}
+		t := link.ExpiresAt.UTC().Truncate(time.Millisecond)
+		expiresAt = &t
+	}
+	if link.MaxViews != nil && *link.MaxViews < 1 {
+		return nil, &Error{"CreateShareLink", noteId, fmt.Errorf("%w: max_views must be at least 1", ErrInvalidShareLink)}
+	}
+	if n := utf8.RuneCountInString(password); password != "" && (n < MinPasswordLength || n > MaxPasswordLength) {
+		return nil, &Error{"CreateShareLink", noteId, fmt.Errorf("%w: %v", ErrInvalidShareLink, ErrInvalidPassword)}
+	}
+	if err := s.authorize(ctx, "CreateShareLink", m, noteId, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return nil, &Error{"CreateShareLink", noteId, err}
+	}
+	var passwordHash string
+	if password != "" {
+		if passwordHash, err = auth.HashPassword(password, s.Params); err != nil {
+			return nil, &Error{"CreateShareLink", noteId, err}
+		}
+	}
+	created := &models.ShareLink{
+		Token:     token,
+		Prefix:    token[:shareLinkPrefixLength],
+		ExpiresAt: expiresAt,
+		MaxViews:  link.MaxViews,
+	}
+	if _, err := s.repo.CreateShareLink(ctx, m.WorkspaceId, noteId, created, auth.HashToken(token), passwordHash); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// GetShareLinks retrieves the share links of a note that can still be
+// viewed, without their tokens. Only owners can list them.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *noteService) GetShareLinks(ctx context.Context, m models.Member, noteId int) ([]*models.ShareLink, error) {
+	if noteId < 1 {
+		return nil, &Error{"GetShareLinks", noteId, fmt.Errorf("%w: %v", ErrInvalidId, noteId)}
+	}
+	if err := s.authorize(ctx, "GetShareLinks", m, noteId, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.GetShareLinks(ctx, m.WorkspaceId, noteId)
+}
+
+// DeleteShareLink revokes a share link of a note. Only owners can revoke
+// them.
+// It returns ErrInvalidId if either ID is less than 1.
+func (s *noteService) DeleteShareLink(ctx context.Context, m models.Member, noteId int, id int) error {
+	if noteId < 1 || id < 1 {
+		return &Error{"DeleteShareLink", noteId, fmt.Errorf("%w: %v/%v", ErrInvalidId, noteId, id)}
+	}
+	if err := s.authorize(ctx, "DeleteShareLink", m, noteId, models.RoleOwner); err != nil {
+		return err
+	}
+	return s.repo.DeleteShareLink(ctx, m.WorkspaceId, noteId, id)
+}
+
+// ViewShareLink returns the note a share link points to and counts the
+// view. Views that fail the password check are not counted.
+// It returns ErrShareLinkNotFound if the token is unknown or the link can no
+// longer be viewed and ErrInvalidSharePassword if the link has a password
+// and password does not match it.
+func (s *noteService) ViewShareLink(ctx context.Context, token string, password string) (*models.PublicNote, error) {
+	if token == "" {
+		return nil, &Error{Src: "ViewShareLink", Err: repository.ErrShareLinkNotFound}
+	}
+	link, passwordHash, err := s.repo.GetShareLink(ctx, auth.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if passwordHash != "" {
+		// Links have no empty passwords, so a missing one is wrong without
+		// hashing it.
+		if password == "" {
+			return nil, &Error{"ViewShareLink", link.Id, ErrInvalidSharePassword}
+		}
+		ok, err := auth.CheckPassword(passwordHash, password)
+		if err != nil {
+			return nil, &Error{"ViewShareLink", link.Id, err}
+		}
+		if !ok {
+			return nil, &Error{"ViewShareLink", link.Id, ErrInvalidSharePassword}
+		}
+	}
+
+	note, err := s.repo.ViewShareLink(ctx, link.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PublicNote{Title: note.Title, Content: note.Content, Tags: note.Tags, UpdatedAt: note.UpdatedAt}, nil
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+// ErrInvalidSync is returned when changes are requested after a negative
+// sequence number or pushed changes are malformed.
+var ErrInvalidSync = errors.New("invalid sync request")
+
+const (
+	// DefaultChangesPageSize is the number of changes returned per page when
+	// no limit is given.
+	DefaultChangesPageSize = 100
+	// MaxChangesPageSize is the largest number of changes that can be
+	// requested per page.
+	MaxChangesPageSize = 1000
+	// MaxPushChanges is the largest number of changes that can be pushed at
+	// once.
+	MaxPushChanges = 100
+)
+
+// GetChanges retrieves a page of the changes to the notes of the workspace
+// after the sequence number since. Starting at 0 returns every note.
+// Members of the workspace can sync it, guests cannot.
+// It returns ErrInvalidSync if since is negative and ErrInvalidListOptions if
+// the limit is out of range.
+func (s *noteService) GetChanges(ctx context.Context, m models.Member, since int64, limit int) (*models.ChangePage, error) {
+	if err := requireRole("GetChanges", m, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	if since < 0 {
+		return nil, &Error{Src: "GetChanges", Err: fmt.Errorf("%w: since must not be negative", ErrInvalidSync)}
+	}
+	if limit == 0 {
+		limit = DefaultChangesPageSize
+	}
+	if limit < 0 || limit > MaxChangesPageSize {
+		return nil, &Error{Src: "GetChanges", Err: fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListOptions, MaxChangesPageSize)}
+	}
+	return s.repo.GetChanges(ctx, m.WorkspaceId, since, limit)
+}
+
+// Push applies changes a client made while it was offline, in order, and
+// returns their results. Each change is applied on its own and only if the
+// note is still at the version the client changed, like a conditional
+// write. Otherwise it is a conflict and its result carries the current note
+// for the client to resolve it with. Changes the member may not make, and
+// notes created in notebooks that do not exist, are rejected. Deleting a
+// note that is already deleted succeeds. A note created with a client ID is
+// only created once, so a client can push its changes again if it did not
+// get the results.
+// It returns ErrInvalidSync if there are no or too many changes, or one of
+// them is malformed, before any change is applied, and ErrInvalidNote or
+// ErrInvalidTag if one of the notes is not valid.
+func (s *noteService) Push(ctx context.Context, m models.Member, changes []*models.PushChange) ([]*models.PushResult, error) {
+	if len(changes) == 0 || len(changes) > MaxPushChanges {
+		return nil, &Error{Src: "PushChanges", Err: fmt.Errorf("%w: between 1 and %d changes must be pushed", ErrInvalidSync, MaxPushChanges)}
+	}
+	for i, change := range changes {
+		if err := checkPushChange(change); err != nil {
+			return nil, &Error{Src: "PushChanges", Err: fmt.Errorf("change %d: %w", i, err)}
+		}
+	}
+
+	results := make([]*models.PushResult, 0, len(changes))
+	for _, change := range changes {
+		result, err := s.push(ctx, m, change)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// checkPushChange validates a pushed change. Notes are created and updated
+// with their note, and updated and deleted at the version they were changed
+// at.
+func checkPushChange(change *models.PushChange) error {
+	switch {
+	case change == nil:
+		return fmt.Errorf("%w: change must not be null", ErrInvalidSync)
+	case change.Id < 0:
+		return fmt.Errorf("%w: %v", ErrInvalidId, change.Id)
+	case change.Id == 0 && change.Deleted:
+		return fmt.Errorf("%w: only existing notes can be deleted", ErrInvalidSync)
+	case change.Id > 0 && change.Version < 1:
+		return fmt.Errorf("%w: version is required to change note %d", ErrInvalidSync, change.Id)
+	case change.Deleted:
+		return nil
+	case change.Note == nil || change.Note.Title == "" || change.Note.Content == "":
+		return ErrInvalidNote
+	}
+	_, err := normalizeTags(change.Note.Tags)
+	return err
+}
+
+// createPushed creates the note of a pushed change and returns it. The
+// client ID of the change is stored with the note in the same unit of work,
+// and if the member already created a note with the client ID, that note is
+// returned as it is now instead.
+func (s *noteService) createPushed(ctx context.Context, m models.Member, change *models.PushChange) (*models.Note, error) {
+	note := change.Note
+	if change.ClientId == "" {
+		id, err := s.Create(ctx, m, note)
+		note.Id = id
+		return note, err
+	}
+	if err := requireRole("CreateNote", m, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	tags, err := normalizeTags(note.Tags)
+	if err != nil {
+		return nil, &Error{Src: "CreateNote", Err: err}
+	}
+	note.Tags = tags
+
+	// A push of the same change that runs at the same time may store the
+	// client ID first, in which case its note is returned on the second try.
+	for attempt := 0; ; attempt++ {
+		pushed, err := s.repo.GetPushedNote(ctx, m.WorkspaceId, m.UserId, change.ClientId)
+		if err == nil {
+			return pushed, nil
+		}
+		if !errors.Is(err, repository.ErrNoteNotFound) {
+			return nil, err
+		}
+
+		err = s.withinTx(ctx, func(ctx context.Context) error {
+			id, err := s.repo.Create(ctx, m.WorkspaceId, m.UserId, note)
+			if err != nil {
+				return err
+			}
+			note.Id = id
+			return s.repo.SetClientId(ctx, m.WorkspaceId, id, change.ClientId)
+		})
+		if errors.Is(err, repository.ErrClientIdTaken) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.publish(m, models.EventNoteCreated, note.Id, note)
+		return note, nil
+	}
+}
+
+// push applies a single change and returns its result. Errors other than
+// conflicts, missing permissions and missing notebooks are returned.
+func (s *noteService) push(ctx context.Context, m models.Member, change *models.PushChange) (*models.PushResult, error) {
+	result := &models.PushResult{ClientId: change.ClientId, Id: change.Id, Status: models.PushApplied}
+	var err error
+	switch {
+	case change.Id == 0:
+		result.Note, err = s.createPushed(ctx, m, change)
+		if err == nil {
+			result.Id = result.Note.Id
+		}
+	case change.Deleted:
+		err = s.Delete(ctx, m, change.Id, change.Version)
+		if errors.Is(err, repository.ErrNoteNotFound) {
+			err = nil
+		}
+	default:
+		err = s.Update(ctx, m, change.Id, change.Note, change.Version)
+		result.Note = change.Note
+	}
+
+	var conflict *ConflictError
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.As(err, &conflict), errors.Is(err, repository.ErrNoteNotFound):
+		result.Status, result.Note = models.PushConflict, nil
+		current, getErr := s.repo.Get(ctx, m.WorkspaceId, change.Id)
+		if getErr == nil {
+			result.Note = current
+		} else if !errors.Is(getErr, repository.ErrNoteNotFound) {
+			return nil, getErr
+		}
+		return result, nil
+	case errors.Is(err, ErrForbidden):
+		result.Status, result.Note, result.Error = models.PushRejected, nil, errors.Unwrap(err).Error()
+		return result, nil
+	case errors.Is(err, repository.ErrNotebookNotFound):
+		result.Status, result.Note, result.Error = models.PushRejected, nil, repository.ErrNotebookNotFound.Error()
+		return result, nil
+	}
+	return nil, err
+}
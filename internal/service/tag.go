@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/models"
+)
+
+// ErrInvalidTag is returned when a tag name is not valid or a note has too
+// many tags.
+var ErrInvalidTag = errors.New("invalid tag")
+
+const (
+	// MaxTagLength is the maximum length of a tag name in characters.
+	MaxTagLength = 64
+	// MaxTagsPerNote is the maximum number of tags a note can carry.
+	MaxTagsPerNote = 32
+)
+
+// tagPattern matches valid tag names: letters and digits, optionally joined
+// by "-", "_", "." or "/".
+var tagPattern = regexp.MustCompile(`^[\p{L}\p{N}]+([-_./][\p{L}\p{N}]+)*$`)
+
+// normalizeTag trims and lowercases a tag name, as tags are matched case
+// insensitively.
+// It returns ErrInvalidTag if the name is not valid.
+func normalizeTag(tag string) (string, error) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if utf8.RuneCountInString(tag) > MaxTagLength || !tagPattern.MatchString(tag) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidTag, tag)
+	}
+	return tag, nil
+}
+
+// normalizeTags normalizes, sorts and deduplicates tags. A nil slice stays
+// nil.
+// It returns ErrInvalidTag if a name is not valid or there are more than
+// MaxTagsPerNote tags.
+func normalizeTags(tags []string) ([]string, error) {
+	if tags == nil {
+		return nil, nil
+	}
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, tag := range tags {
+		tag, err := normalizeTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+	if len(normalized) > MaxTagsPerNote {
+		return nil, fmt.Errorf("%w: a note can have at most %d tags", ErrInvalidTag, MaxTagsPerNote)
+	}
+	sort.Strings(normalized)
+	return normalized, nil
+}
+
+// GetTags retrieves all tags in use in the workspace along with the number
+// of notes carrying them. Guests of the workspace cannot list them.
+func (s *noteService) GetTags(ctx context.Context, m models.Member) ([]*models.Tag, error) {
+	if err := requireRole("GetTags", m, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetTags(ctx, m.WorkspaceId)
+}
+
+// RenameTag renames a tag on all notes carrying it and returns the number of
+// notes changed. Editors of the workspace can rename tags.
+// It returns ErrInvalidTag if either name is not valid or they are the same.
+func (s *noteService) RenameTag(ctx context.Context, m models.Member, name string, newName string) (int, error) {
+	name, newName, err := normalizeTagPair(name, newName)
+	if err != nil {
+		return 0, &Error{Src: "RenameTag", Err: err}
+	}
+	if err := requireRole("RenameTag", m, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	return s.repo.RenameTag(ctx, m.WorkspaceId, name, newName)
+}
+
+// MergeTags replaces the source tag with the target tag on all notes
+// carrying it and returns the number of notes changed. Editors of the
+// workspace can merge tags.
+// It returns ErrInvalidTag if either name is not valid or they are the same.
+func (s *noteService) MergeTags(ctx context.Context, m models.Member, source string, target string) (int, error) {
+	source, target, err := normalizeTagPair(source, target)
+	if err != nil {
+		return 0, &Error{Src: "MergeTags", Err: err}
+	}
+	if err := requireRole("MergeTags", m, models.RoleEditor); err != nil {
+		return 0, err
+	}
+	return s.repo.MergeTags(ctx, m.WorkspaceId, source, target)
+}
+
+// normalizeTagPair normalizes two tag names that must differ.
+func normalizeTagPair(a string, b string) (string, string, error) {
+	a, err := normalizeTag(a)
+	if err != nil {
+		return "", "", err
+	}
+	b, err = normalizeTag(b)
+	if err != nil {
+		return "", "", err
+	}
+	if a == b {
+		return "", "", fmt.Errorf("%w: %q and %q are the same tag", ErrInvalidTag, a, b)
+	}
+	return a, b, nil
+}
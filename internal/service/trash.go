@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+const (
+	// DefaultTrashRetention is how long notes stay in the trash before they
+	// are purged when no retention is configured.
+	DefaultTrashRetention = 30 * 24 * time.Hour
+	// DefaultPurgeInterval is how often the trash is purged when no interval
+	// is configured.
+	DefaultPurgeInterval = time.Hour
+)
+
+// GetTrash retrieves a page of the notes of the workspace in the trash from
+// the repository.
+// They can additionally be sorted by deletion time. Guests of the
+// workspace cannot list the trash.
+// It returns ErrInvalidListOptions if the limit is out of range or the sort
+// field is unknown.
+func (s *noteService) GetTrash(ctx context.Context, m models.Member, opts models.ListOptions) (*models.NotePage, error) {
+	if err := requireRole("GetTrash", m, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	opts.Trashed = true
+	if err := checkListOptions(&opts); err != nil {
+		return nil, &Error{Src: "GetTrash", Err: err}
+	}
+	return s.repo.GetAll(ctx, m.WorkspaceId, opts)
+}
+
+// Restore moves a note out of the trash and returns it. Editors of the
+// workspace can restore notes.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *noteService) Restore(ctx context.Context, m models.Member, id int) (*models.Note, error) {
+	if id < 1 {
+		return nil, &Error{"RestoreNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := requireRole("RestoreNote", m, models.RoleEditor); err != nil {
+		return nil, err
+	}
+	note, err := s.repo.Restore(ctx, m.WorkspaceId, id)
+	if err != nil {
+		return nil, err
+	}
+	// The note reappears in the lists of notes like a new one.
+	s.publish(m, models.EventNoteCreated, id, note)
+	return note, nil
+}
+
+// Purge permanently deletes a note from the trash. Only owners of the
+// workspace can purge notes.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *noteService) Purge(ctx context.Context, m models.Member, id int) error {
+	if id < 1 {
+		return &Error{"PurgeNote", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := requireRole("PurgeNote", m, models.RoleOwner); err != nil {
+		return err
+	}
+	return s.repo.Purge(ctx, m.WorkspaceId, id)
+}
+
+// TrashPurger periodically deletes notes that have been in the trash for
+// longer than the retention period.
+type TrashPurger struct {
+	repo      repository.NoteRepository
+	Retention time.Duration
+	Interval  time.Duration
+	now       func() time.Time
+}
+
+// NewTrashPurger creates a TrashPurger with the default retention and
+// interval.
+func NewTrashPurger(repo repository.NoteRepository) *TrashPurger {
+	return &TrashPurger{
+		repo:      repo,
+		Retention: DefaultTrashRetention,
+		Interval:  DefaultPurgeInterval,
+		now:       time.Now,
+	}
+}
+
+// PurgeOnce deletes the notes that have been in the trash for longer than
+// the retention period and returns how many were deleted.
+func (p *TrashPurger) PurgeOnce(ctx context.Context) (int, error) {
+	return p.repo.PurgeDeletedBefore(ctx, p.now().Add(-p.Retention))
+}
+
+// Run purges the trash once and then every interval until ctx is done.
+// Failures are logged and retried at the next interval.
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+	for {
+		n, err := p.PurgeOnce(ctx)
+		if err != nil {
+			log.Printf("purging trash: %v", err)
+		} else if n > 0 {
+			log.Printf("purged %d notes from the trash", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+var (
+	// ErrInvalidEmail is returned when a user registers with an email that
+	// is not a plain address.
+	ErrInvalidEmail = errors.New("email must be a valid address")
+	// ErrInvalidPassword is returned when a user registers with a password
+	// that is too short or too long.
+	ErrInvalidPassword = fmt.Errorf("password must have %d to %d characters", MinPasswordLength, MaxPasswordLength)
+	// ErrInvalidCredentials is returned when a login fails because the
+	// email is unknown or the password is wrong. The two cases are not told
+	// apart so logins cannot be used to find out who has an account.
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrUnauthenticated is returned when a session token is missing,
+	// unknown or expired.
+	ErrUnauthenticated = errors.New("authentication required")
+)
+
+const (
+	// MinPasswordLength and MaxPasswordLength bound the length of a
+	// password in characters.
+	MinPasswordLength = 8
+	MaxPasswordLength = 1024
+	// maxEmailLength is the longest email address that can be delivered to,
+	// as per RFC 5321.
+	maxEmailLength = 254
+	// DefaultSessionTTL is how long a session token is valid after login.
+	DefaultSessionTTL = 7 * 24 * time.Hour
+	// DefaultTicketTTL is how long a ticket is valid after it was created.
+	DefaultTicketTTL = 30 * time.Second
+)
+
+// userService implements the UserService interface.
+type userService struct {
+	repo repository.UserRepository
+	now  func() time.Time
+	// SessionTTL is how long a session token is valid after login.
+	SessionTTL time.Duration
+	// TicketTTL is how long a ticket is valid after it was created.
+	TicketTTL time.Duration
+	// Params are the argon2id parameters new passwords are hashed with.
+	Params auth.Params
+
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+// NewUserService creates a new userService with the default session and
+// ticket TTLs and hashing parameters.
+func NewUserService(repo repository.UserRepository) *userService {
+	return &userService{repo: repo, now: time.Now, SessionTTL: DefaultSessionTTL, TicketTTL: DefaultTicketTTL, Params: auth.DefaultParams}
+}
+
+// normalizeEmail trims an email and checks that it is a plain address
+// without a display name.
+// It returns ErrInvalidEmail if it is not.
+func normalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// Register creates a user with the given email and password. The password
+// is stored as an argon2id hash.
+// It returns ErrInvalidEmail or ErrInvalidPassword if either is not valid.
+func (s *userService) Register(email string, password string) (*models.User, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, &Error{Src: "Register", Err: err}
+	}
+	if n := utf8.RuneCountInString(password); n < MinPasswordLength || n > MaxPasswordLength {
+		return nil, &Error{Src: "Register", Err: ErrInvalidPassword}
+	}
+	hash, err := auth.HashPassword(password, s.Params)
+	if err != nil {
+		return nil, &Error{Src: "Register", Err: err}
+	}
+	user := &models.User{Email: email, PasswordHash: hash}
+	if _, err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks the email and password of a user and starts a session that
+// lasts SessionTTL.
+// It returns ErrInvalidCredentials if there is no user with the email or
+// the password is wrong.
+func (s *userService) Login(email string, password string) (*models.Session, error) {
+	user, err := s.repo.GetByEmail(strings.TrimSpace(email))
+	if errors.Is(err, repository.ErrUserNotFound) {
+		// Hash the password anyway, so unknown emails take as long as wrong
+		// passwords.
+		auth.CheckPassword(s.dummy(), password)
+		return nil, &Error{Src: "Login", Err: ErrInvalidCredentials}
+	}
+	if err != nil {
+		return nil, err
+	}
+	ok, err := auth.CheckPassword(user.PasswordHash, password)
+	if err != nil {
+		return nil, &Error{"Login", user.Id, err}
+	}
+	if !ok {
+		return nil, &Error{"Login", user.Id, ErrInvalidCredentials}
+	}
+
+	token, err := auth.NewToken()
+	if err != nil {
+		return nil, &Error{"Login", user.Id, err}
+	}
+	expiresAt := s.now().Add(s.SessionTTL).UTC().Truncate(time.Millisecond)
+	if err := s.repo.CreateSession(user.Id, auth.HashToken(token), expiresAt); err != nil {
+		return nil, err
+	}
+	return &models.Session{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// Logout ends the session of token.
+// It returns ErrUnauthenticated if the session does not exist.
+func (s *userService) Logout(token string) error {
+	err := s.repo.DeleteSession(auth.HashToken(token))
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return &Error{Src: "Logout", Err: ErrUnauthenticated}
+	}
+	return err
+}
+
+// Authenticate returns the user whose session token is token.
+// It returns ErrUnauthenticated if the token is empty, unknown or expired,
+// or if it is a ticket.
+func (s *userService) Authenticate(token string) (*models.User, error) {
+	if token == "" || auth.IsTicket(token) {
+		return nil, &Error{Src: "Authenticate", Err: ErrUnauthenticated}
+	}
+	user, err := s.repo.GetSessionUser(auth.HashToken(token))
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return nil, &Error{Src: "Authenticate", Err: ErrUnauthenticated}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateTicket creates a ticket for a user that lasts TicketTTL. Tickets
+// are stored like sessions, which are told apart by the prefix of their
+// token.
+func (s *userService) CreateTicket(userId int) (*models.Ticket, error) {
+	ticket, err := auth.NewTicket()
+	if err != nil {
+		return nil, &Error{"CreateTicket", userId, err}
+	}
+	expiresAt := s.now().Add(s.TicketTTL).UTC().Truncate(time.Millisecond)
+	if err := s.repo.CreateSession(userId, auth.HashToken(ticket), expiresAt); err != nil {
+		return nil, err
+	}
+	return &models.Ticket{Ticket: ticket, ExpiresAt: expiresAt}, nil
+}
+
+// AuthenticateTicket returns the user of ticket and removes it, so that it
+// cannot be used again.
+// It returns ErrUnauthenticated if the ticket is not a ticket, unknown,
+// expired or was used already.
+func (s *userService) AuthenticateTicket(ticket string) (*models.User, error) {
+	if !auth.IsTicket(ticket) {
+		return nil, &Error{Src: "AuthenticateTicket", Err: ErrUnauthenticated}
+	}
+	hash := auth.HashToken(ticket)
+	user, err := s.repo.GetSessionUser(hash)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return nil, &Error{Src: "AuthenticateTicket", Err: ErrUnauthenticated}
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Whoever removes the ticket first may use it.
+	err = s.repo.DeleteSession(hash)
+	if errors.Is(err, repository.ErrSessionNotFound) {
+		return nil, &Error{Src: "AuthenticateTicket", Err: ErrUnauthenticated}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// dummy returns a hash with the current parameters to check passwords
+// against when there is no user to check them against.
+func (s *userService) dummy() string {
+	s.dummyOnce.Do(func() {
+		s.dummyHash, _ = auth.HashPassword("", s.Params)
+	})
+	return s.dummyHash
+}
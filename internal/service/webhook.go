@@ -0,0 +1,465 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/auth"
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+// ErrInvalidWebhook is returned when a webhook is created with a URL that is
+// not an absolute http or https URL or that points to a private address,
+// without events, with an unknown event or with a secret that is too short or
+// too long.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+// ErrPrivateTarget is recorded for deliveries that are not sent because the
+// host of their webhook resolves to a private address.
+var ErrPrivateTarget = errors.New("webhook target is not a public address")
+
+// publicAddr reports whether addr can be reached from the internet, which
+// loopback, private, link-local, multicast and unspecified addresses cannot.
+// Without it, any user could have the server probe its own network with
+// webhooks and read the responses back from their deliveries.
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsLinkLocalUnicast() &&
+		!addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() && !addr.IsMulticast() && !addr.IsUnspecified()
+}
+
+// privateHost reports whether host is a private address or a name of the
+// local host. Other names are checked once they are resolved, when a
+// delivery is sent.
+func privateHost(host string) bool {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return !publicAddr(addr)
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	return host == "localhost" || strings.HasSuffix(host, ".localhost")
+}
+
+const (
+	// MaxWebhookURLLength is the maximum length of a webhook URL.
+	MaxWebhookURLLength = 2048
+	// MinWebhookSecretLength and MaxWebhookSecretLength bound the length of
+	// the secret of a webhook in characters.
+	MinWebhookSecretLength = 16
+	MaxWebhookSecretLength = 256
+	// WebhookSecretPrefix starts the secrets generated for webhooks created
+	// without one.
+	WebhookSecretPrefix = "whsec_"
+)
+
+// The headers every delivery is sent with. The signature is
+// "sha256=" followed by the hex-encoded HMAC-SHA256, keyed with the secret of
+// the webhook, of the timestamp, a dot and the body, see SignPayload.
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// SignPayload returns the signature of a delivery of payload sent at the
+// Unix time timestamp to a webhook with secret. Receivers recompute it to
+// check that a delivery is genuine, and can reject old timestamps to guard
+// against replays.
+func SignPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookEvents are the event types webhooks can subscribe to.
+var webhookEvents = map[models.EventType]bool{
+	models.EventNoteCreated: true,
+	models.EventNoteUpdated: true,
+	models.EventNoteDeleted: true,
+}
+
+// checkWebhook validates the URL, events and secret of webhook and returns
+// its sorted events without duplicates. The URL may only point to a private
+// address if allowPrivate is set.
+func checkWebhook(webhook *models.Webhook, allowPrivate bool) ([]models.EventType, error) {
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || len(webhook.URL) > MaxWebhookURLLength {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL of up to %d characters", ErrInvalidWebhook, MaxWebhookURLLength)
+	}
+	if !allowPrivate && privateHost(u.Hostname()) {
+		return nil, fmt.Errorf("%w: url must not point to a private address", ErrInvalidWebhook)
+	}
+	if len(webhook.Events) == 0 {
+		return nil, fmt.Errorf("%w: events must not be empty", ErrInvalidWebhook)
+	}
+	seen := map[models.EventType]bool{}
+	events := []models.EventType{}
+	for _, event := range webhook.Events {
+		if !webhookEvents[event] {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i] < events[j] })
+	if n := utf8.RuneCountInString(webhook.Secret); webhook.Secret != "" && (n < MinWebhookSecretLength || n > MaxWebhookSecretLength) {
+		return nil, fmt.Errorf("%w: secret must have %d to %d characters", ErrInvalidWebhook, MinWebhookSecretLength, MaxWebhookSecretLength)
+	}
+	return events, nil
+}
+
+// webhookService implements the WebhookService interface. Webhooks can only
+// be created with URLs that point to private addresses if
+// AllowPrivateTargets is set.
+type webhookService struct {
+	repo                repository.WebhookRepository
+	AllowPrivateTargets bool
+}
+
+// NewWebhookService creates a new webhookService.
+func NewWebhookService(repo repository.WebhookRepository) *webhookService {
+	return &webhookService{repo: repo}
+}
+
+// GetAll retrieves the webhooks of the workspace, without their secrets.
+// Only owners can list them.
+func (s *webhookService) GetAll(m models.Member) ([]*models.Webhook, error) {
+	if err := requireRole("GetAllWebhooks", m, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(m.WorkspaceId)
+}
+
+// Create adds a webhook to the workspace that is called with the events it
+// subscribes to. A secret is generated if it has none. The returned webhook
+// is the only place its secret can be read. Only owners can create webhooks.
+// It returns ErrInvalidWebhook if the URL, events or secret are not valid.
+func (s *webhookService) Create(m models.Member, webhook *models.Webhook) (*models.Webhook, error) {
+	if webhook == nil {
+		return nil, &Error{Src: "CreateWebhook", Err: ErrInvalidWebhook}
+	}
+	events, err := checkWebhook(webhook, s.AllowPrivateTargets)
+	if err != nil {
+		return nil, &Error{Src: "CreateWebhook", Err: err}
+	}
+	if err := requireRole("CreateWebhook", m, models.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	secret := webhook.Secret
+	if secret == "" {
+		token, err := auth.NewToken()
+		if err != nil {
+			return nil, &Error{Src: "CreateWebhook", Err: err}
+		}
+		secret = WebhookSecretPrefix + token
+	}
+	created := &models.Webhook{URL: webhook.URL, Events: events, Secret: secret}
+	if _, err := s.repo.Create(m.WorkspaceId, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// Delete removes a webhook of the workspace along with its deliveries. Only
+// owners can delete webhooks.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *webhookService) Delete(m models.Member, id int) error {
+	if id < 1 {
+		return &Error{"DeleteWebhook", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := requireRole("DeleteWebhook", m, models.RoleOwner); err != nil {
+		return err
+	}
+	return s.repo.Delete(m.WorkspaceId, id)
+}
+
+// GetDeliveries retrieves the latest deliveries of a webhook of the
+// workspace, newest first. Only owners can list them.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *webhookService) GetDeliveries(m models.Member, id int) ([]*models.WebhookDelivery, error) {
+	if id < 1 {
+		return nil, &Error{"GetWebhookDeliveries", id, fmt.Errorf("%w: %v", ErrInvalidId, id)}
+	}
+	if err := requireRole("GetWebhookDeliveries", m, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDeliveries(m.WorkspaceId, id)
+}
+
+// Redeliver sends a delivery of a webhook of the workspace again, whatever
+// its state, with a fresh set of attempts. This brings back dead
+// deliveries. Only owners can redeliver.
+// It returns ErrInvalidId if either ID is less than 1.
+func (s *webhookService) Redeliver(m models.Member, id int, deliveryId int) (*models.WebhookDelivery, error) {
+	if id < 1 || deliveryId < 1 {
+		return nil, &Error{"RedeliverWebhook", id, fmt.Errorf("%w: %v/%v", ErrInvalidId, id, deliveryId)}
+	}
+	if err := requireRole("RedeliverWebhook", m, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.Redeliver(m.WorkspaceId, id, deliveryId)
+}
+
+const (
+	// DefaultDeliveryInterval is how often due deliveries are sent when no
+	// interval is configured.
+	DefaultDeliveryInterval = 5 * time.Second
+	// DefaultMaxDeliveryAttempts is how often a delivery is attempted before
+	// it is dead when no limit is configured.
+	DefaultMaxDeliveryAttempts = 8
+	// DefaultRetryDelay is the delay before the first retry of a failed
+	// delivery when none is configured. It doubles with every attempt.
+	DefaultRetryDelay = 30 * time.Second
+	// MaxRetryDelay is the longest delay between two attempts.
+	MaxRetryDelay = 6 * time.Hour
+)
+
+// deliveryBatchSize is the number of due deliveries sent at a time.
+const deliveryBatchSize = 50
+
+// deliveryTimeout is how long a webhook has to respond to a delivery.
+const deliveryTimeout = 10 * time.Second
+
+// deliveryLease is how long a delivery is leased to the dispatcher sending
+// it. It outlasts the timeout of the delivery, so that the delivery is only
+// sent again if the dispatcher stopped before recording the outcome.
+const deliveryLease = 2 * deliveryTimeout
+
+// deliveryWorkers is the number of webhooks sent deliveries at the same
+// time.
+const deliveryWorkers = 8
+
+// WebhookDispatcher periodically sends the pending webhook deliveries that
+// are due. Failed deliveries are retried with exponential backoff until
+// they run out of attempts and are dead. Deliveries to private addresses
+// fail unless AllowPrivateTargets is set.
+type WebhookDispatcher struct {
+	repo                repository.WebhookRepository
+	Client              *http.Client
+	Interval            time.Duration
+	MaxAttempts         int
+	RetryDelay          time.Duration
+	AllowPrivateTargets bool
+	now                 func() time.Time
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher with the default
+// interval, attempts and retry delay. Its client does not follow redirects
+// or use a proxy, and checks the address it connects to rather than the
+// host name of a webhook, so that a name that resolves to a private address
+// only when the delivery is sent is refused as well.
+func NewWebhookDispatcher(repo repository.WebhookRepository) *WebhookDispatcher {
+	d := &WebhookDispatcher{
+		repo:        repo,
+		Interval:    DefaultDeliveryInterval,
+		MaxAttempts: DefaultMaxDeliveryAttempts,
+		RetryDelay:  DefaultRetryDelay,
+		now:         time.Now,
+	}
+	dialer := &net.Dialer{Timeout: deliveryTimeout, Control: d.checkDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	d.Client = &http.Client{
+		Transport: transport,
+		Timeout:   deliveryTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return d
+}
+
+// checkDial is the net.Dialer.Control of the client of the dispatcher. It
+// refuses to connect to address, the resolved address of a webhook, if it
+// is private and AllowPrivateTargets is not set.
+func (d *WebhookDispatcher) checkDial(network string, address string, _ syscall.RawConn) error {
+	if d.AllowPrivateTargets {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !publicAddr(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateTarget, addrPort.Addr())
+	}
+	return nil
+}
+
+// DeliverOnce sends the deliveries that are due and returns how many were
+// attempted. The deliveries of different webhooks are sent concurrently and
+// those of each webhook in order, each claimed right before it is sent so
+// that other dispatchers do not send it as well. A webhook that fails or
+// takes longer than deliveryTimeout in total gets the rest of its deliveries
+// at the next interval, so that a slow webhook does not hold up the others.
+// Deliveries interrupted because ctx is done stay leased until their lease
+// ends and are due again then.
+func (d *WebhookDispatcher) DeliverOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.repo.GetDueDeliveries(d.now(), deliveryBatchSize)
+	if err != nil {
+		return 0, err
+	}
+	var targets [][]*models.WebhookDelivery
+	target := map[int]int{}
+	for _, delivery := range deliveries {
+		i, ok := target[delivery.WebhookId]
+		if !ok {
+			i = len(targets)
+			target[delivery.WebhookId] = i
+			targets = append(targets, nil)
+		}
+		targets[i] = append(targets[i], delivery)
+	}
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		attempted int
+	)
+	workers := make(chan struct{}, deliveryWorkers)
+	for _, deliveries := range targets {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() { <-workers; wg.Done() }()
+			n := d.deliverTo(ctx, deliveries)
+			mu.Lock()
+			attempted += n
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return attempted, ctx.Err()
+}
+
+// deliverTo sends the due deliveries of a webhook in order and returns how
+// many were attempted. It stops at the first failure or once the webhook
+// took deliveryTimeout, and skips deliveries claimed by another dispatcher.
+func (d *WebhookDispatcher) deliverTo(ctx context.Context, deliveries []*models.WebhookDelivery) int {
+	start := d.now()
+	attempted := 0
+	for _, delivery := range deliveries {
+		if ctx.Err() != nil || d.now().Sub(start) >= deliveryTimeout {
+			break
+		}
+		now := d.now()
+		if err := d.repo.ClaimDelivery(delivery, now, now.Add(deliveryLease)); err != nil {
+			if !errors.Is(err, repository.ErrDeliveryClaimed) {
+				log.Printf("claiming webhook delivery %d: %v", delivery.Id, err)
+			}
+			continue
+		}
+		d.send(ctx, delivery)
+		if ctx.Err() != nil {
+			break
+		}
+		attempted++
+		if err := d.repo.UpdateDelivery(delivery); err != nil {
+			log.Printf("recording webhook delivery %d: %v", delivery.Id, err)
+		}
+		if delivery.Status != models.DeliverySucceeded {
+			break
+		}
+	}
+	return attempted
+}
+
+// send attempts a delivery and records the outcome in it. A 2xx response is
+// a success, anything else a failure.
+func (d *WebhookDispatcher) send(ctx context.Context, delivery *models.WebhookDelivery) {
+	now := d.now().UTC().Truncate(time.Millisecond)
+	delivery.Attempts++
+	code, err := d.post(ctx, delivery, now)
+	delivery.LastStatusCode = code
+	if err == nil {
+		delivery.Status, delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt = models.DeliverySucceeded, nil, "", &now
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.MaxAttempts {
+		delivery.Status, delivery.NextAttemptAt = models.DeliveryDead, nil
+		return
+	}
+	next := now.Add(d.backoff(delivery.Attempts))
+	delivery.NextAttemptAt = &next
+}
+
+// post sends the payload of a delivery to its webhook, signed with its
+// secret, and returns the status code of the response, if there was one.
+func (d *WebhookDispatcher) post(ctx context.Context, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now.Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(delivery.Event))
+	req.Header.Set(DeliveryHeader, strconv.Itoa(delivery.Id))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, SignPayload(delivery.Secret, timestamp, delivery.Payload))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with %s", res.Status)
+	}
+	return res.StatusCode, nil
+}
+
+// backoff returns the delay before the next attempt of a delivery that
+// failed attempts times, which doubles with every attempt up to
+// MaxRetryDelay.
+func (d *WebhookDispatcher) backoff(attempts int) time.Duration {
+	delay := d.RetryDelay
+	for i := 1; i < attempts && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, MaxRetryDelay)
+}
+
+// Run sends due deliveries once and then every interval until ctx is done.
+// Failures are logged and retried at the next interval.
+func (d *WebhookDispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := d.DeliverOnce(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("delivering webhooks: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/JannisK89/notes-api/internal/models"
+	"github.com/JannisK89/notes-api/internal/repository"
+)
+
+var (
+	// ErrInvalidWorkspace is returned when a workspace is created with an
+	// empty or too long name.
+	ErrInvalidWorkspace = errors.New("invalid workspace")
+	// ErrInvalidMember is returned when a member is added without an email
+	// or with an unknown role.
+	ErrInvalidMember = errors.New("member must have an email and a role of viewer, editor or owner")
+)
+
+// MaxWorkspaceNameLength is the maximum length of a workspace name in
+// characters.
+const MaxWorkspaceNameLength = 100
+
+// requireRole checks that a member has at least role in their workspace,
+// for operations that are not about a single note or notebook.
+// It returns ErrForbidden if the role of the member is too low.
+func requireRole(src string, m models.Member, role models.Role) error {
+	if !m.Role.Includes(role) {
+		return &Error{Src: src, Err: fmt.Errorf("%w: %s role required", ErrForbidden, role)}
+	}
+	return nil
+}
+
+// workspaceService implements the WorkspaceService interface.
+type workspaceService struct {
+	repo repository.WorkspaceRepository
+}
+
+// NewWorkspaceService creates a new workspaceService.
+func NewWorkspaceService(repo repository.WorkspaceRepository) *workspaceService {
+	return &workspaceService{repo}
+}
+
+// Member retrieves the membership of a user in a workspace, or in their
+// personal workspace if workspaceId is 0. Everything a request does happens
+// within the workspace of this membership.
+// It returns ErrInvalidId if the ID is negative.
+func (s *workspaceService) Member(userId int, workspaceId int) (*models.Member, error) {
+	if workspaceId < 0 {
+		return nil, &Error{"GetWorkspaceMember", workspaceId, fmt.Errorf("%w: %v", ErrInvalidId, workspaceId)}
+	}
+	if workspaceId == 0 {
+		return s.repo.GetPersonalMember(userId)
+	}
+	return s.repo.GetMember(workspaceId, userId)
+}
+
+// GetAll retrieves the workspaces of a user, the personal workspace first.
+func (s *workspaceService) GetAll(userId int) ([]*models.Workspace, error) {
+	return s.repo.GetAll(userId)
+}
+
+// Create adds a new workspace with the user as its owner.
+// It returns ErrInvalidWorkspace if the workspace is nil or its name is not
+// valid.
+func (s *workspaceService) Create(userId int, workspace *models.Workspace) (int, error) {
+	if workspace == nil {
+		return 0, &Error{Src: "CreateWorkspace", Err: ErrInvalidWorkspace}
+	}
+	name := strings.TrimSpace(workspace.Name)
+	if name == "" || utf8.RuneCountInString(name) > MaxWorkspaceNameLength {
+		return 0, &Error{Src: "CreateWorkspace", Err: fmt.Errorf("%w: name must have 1 to %d characters", ErrInvalidWorkspace, MaxWorkspaceNameLength)}
+	}
+	workspace.Name = name
+	return s.repo.Create(userId, workspace)
+}
+
+// GetMembers retrieves the members of the workspace of a member. Guests of
+// the workspace cannot list them.
+func (s *workspaceService) GetMembers(m models.Member) ([]*models.WorkspaceMember, error) {
+	if err := requireRole("GetWorkspaceMembers", m, models.RoleViewer); err != nil {
+		return nil, err
+	}
+	return s.repo.GetMembers(m.WorkspaceId)
+}
+
+// AddMember makes the user with the given email a member of the workspace
+// with role, or changes their role. Only owners can add members.
+// It returns ErrInvalidMember if the email or role is not valid.
+func (s *workspaceService) AddMember(m models.Member, email string, role models.Role) (*models.WorkspaceMember, error) {
+	email = strings.TrimSpace(email)
+	if email == "" || (role != models.RoleViewer && role != models.RoleEditor && role != models.RoleOwner) {
+		return nil, &Error{"AddWorkspaceMember", m.WorkspaceId, ErrInvalidMember}
+	}
+	if err := requireRole("AddWorkspaceMember", m, models.RoleOwner); err != nil {
+		return nil, err
+	}
+	return s.repo.AddMember(m.WorkspaceId, email, role)
+}
+
+// RemoveMember removes a user from the workspace. Owners can remove anyone
+// and members can leave.
+// It returns ErrInvalidId if the ID is less than 1.
+func (s *workspaceService) RemoveMember(m models.Member, userId int) error {
+	if userId < 1 {
+		return &Error{"RemoveWorkspaceMember", m.WorkspaceId, fmt.Errorf("%w: %v", ErrInvalidId, userId)}
+	}
+	if userId != m.UserId {
+		if err := requireRole("RemoveWorkspaceMember", m, models.RoleOwner); err != nil {
+			return err
+		}
+	}
+	return s.repo.RemoveMember(m.WorkspaceId, userId)
+}
@@ -0,0 +1,357 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455) as far as the API needs it: text and binary messages, pings and
+// the closing handshake. Extensions and subprotocols are not supported.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Message types, the opcodes of their frames.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+	PongMessage   = 10
+)
+
+// Close codes sent with a CloseMessage.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseUnsupportedData = 1003
+	CloseNoStatus        = 1005
+	CloseInvalidData     = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseInternalError   = 1011
+)
+
+// DefaultMaxMessageSize is the largest message a Conn reads unless
+// configured otherwise.
+const DefaultMaxMessageSize = 1 << 20
+
+// acceptGUID is appended to the key of a handshake to compute its accept
+// header.
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	// ErrBadHandshake is returned when a request is not a valid WebSocket
+	// handshake.
+	ErrBadHandshake = errors.New("bad websocket handshake")
+	// ErrUnsupportedVersion is returned when a handshake asks for a version
+	// of the protocol other than 13.
+	ErrUnsupportedVersion = errors.New("unsupported websocket version")
+	// ErrProtocol is returned when the peer breaks the protocol.
+	ErrProtocol = errors.New("websocket protocol error")
+	// ErrMessageTooBig is returned when a message is larger than the
+	// maximum message size.
+	ErrMessageTooBig = errors.New("websocket message too big")
+	// ErrClosed is returned when writing after the closing handshake began.
+	ErrClosed = errors.New("websocket closed")
+)
+
+// CloseError is returned by ReadMessage when the peer closes the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed with %d %s", e.Code, e.Reason)
+}
+
+// IsUpgrade reports whether r asks to switch to the WebSocket protocol.
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// headerContains reports whether a comma-separated header has token, in any
+// case.
+func headerContains(h http.Header, name string, token string) bool {
+	for _, value := range h.Values(name) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade completes the handshake of a WebSocket request and takes over its
+// connection. Nothing is written to w if the handshake is invalid, so the
+// caller can respond with an error.
+// It returns ErrBadHandshake if r is not a valid handshake and
+// ErrUnsupportedVersion if it asks for another version of the protocol.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet || !IsUpgrade(r) {
+		return nil, fmt.Errorf("%w: not a GET request to upgrade to websocket", ErrBadHandshake)
+	}
+	if version := r.Header.Get("Sec-WebSocket-Version"); version != "13" {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedVersion, version)
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("%w: invalid key %q", ErrBadHandshake, key)
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+	// Clear the deadlines the server may have set for the request.
+	if err := netConn.SetDeadline(time.Time{}); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	accept := sha1.Sum([]byte(key + acceptGUID))
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n",
+		base64.StdEncoding.EncodeToString(accept[:]))
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return &Conn{conn: netConn, r: rw.Reader, MaxMessageSize: DefaultMaxMessageSize}, nil
+}
+
+// Conn is a WebSocket connection. One goroutine may read from it while
+// others write to it.
+type Conn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	writeMu   sync.Mutex
+	closeSent bool
+
+	// MaxMessageSize is the largest message that is read. Larger messages
+	// close the connection.
+	MaxMessageSize int64
+	// ReadTimeout is how long to wait for the next frame, if it is set.
+	// Pongs count, so pinging the peer regularly keeps the connection open.
+	ReadTimeout time.Duration
+	// WriteTimeout is how long writing a message may take, if it is set.
+	WriteTimeout time.Duration
+}
+
+// ReadMessage reads the next text or binary message and returns its type
+// and data. It answers pings and the closing handshake on the way.
+// It returns a *CloseError once the peer closes the connection,
+// ErrMessageTooBig if the message is larger than MaxMessageSize and
+// ErrProtocol if the peer breaks the protocol. The connection is closed by
+// then, except for io errors.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+	for {
+		if c.ReadTimeout > 0 {
+			if err := c.conn.SetReadDeadline(time.Now().Add(c.ReadTimeout)); err != nil {
+				return 0, nil, err
+			}
+		}
+		fin, opcode, payload, err := c.readFrame(int64(len(message)))
+		if err != nil {
+			return 0, nil, c.fail(err)
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.WriteMessage(PongMessage, payload); err != nil && !errors.Is(err, ErrClosed) {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			continue
+		case CloseMessage:
+			return 0, nil, c.readClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(fmt.Errorf("%w: new message before the last one ended", ErrProtocol))
+			}
+			messageType = opcode
+		case 0:
+			if messageType == 0 {
+				return 0, nil, c.fail(fmt.Errorf("%w: continuation without a message", ErrProtocol))
+			}
+		default:
+			return 0, nil, c.fail(fmt.Errorf("%w: unknown opcode %d", ErrProtocol, opcode))
+		}
+
+		message = append(message, payload...)
+		if !fin {
+			continue
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			c.WriteClose(CloseInvalidData, "invalid UTF-8")
+			c.conn.Close()
+			return 0, nil, fmt.Errorf("%w: invalid UTF-8 in text message", ErrProtocol)
+		}
+		return messageType, message, nil
+	}
+}
+
+// readFrame reads a frame and returns its payload, unmasked. read is the
+// size of the message read so far.
+func (c *Conn) readFrame(read int64) (bool, int, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := header[0]&0x80 != 0, int(header[0]&0x0f)
+	if header[0]&0x70 != 0 {
+		return false, 0, nil, fmt.Errorf("%w: reserved bits set", ErrProtocol)
+	}
+	if header[1]&0x80 == 0 {
+		return false, 0, nil, fmt.Errorf("%w: unmasked frame from client", ErrProtocol)
+	}
+
+	size := int64(header[1] & 0x7f)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		size = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if opcode >= CloseMessage && (!fin || size > 125) {
+		return false, 0, nil, fmt.Errorf("%w: fragmented or large control frame", ErrProtocol)
+	}
+	if size < 0 || (opcode < CloseMessage && read+size > c.MaxMessageSize) {
+		return false, 0, nil, ErrMessageTooBig
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return fin, opcode, payload, nil
+}
+
+// fail closes the connection because of err, telling the peer why if it
+// broke the protocol, and returns err.
+func (c *Conn) fail(err error) error {
+	switch {
+	case errors.Is(err, ErrMessageTooBig):
+		c.WriteClose(CloseMessageTooBig, "message too big")
+	case errors.Is(err, ErrProtocol):
+		c.WriteClose(CloseProtocolError, "protocol error")
+	default:
+		return err
+	}
+	c.conn.Close()
+	return err
+}
+
+// readClose answers the close frame with payload, unless a close frame was
+// sent already, closes the connection and returns the CloseError of the
+// peer.
+func (c *Conn) readClose(payload []byte) error {
+	closeErr := &CloseError{Code: CloseNoStatus}
+	if len(payload) >= 2 {
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+	}
+	if closeErr.Code == CloseNoStatus {
+		c.WriteClose(CloseNormal, "")
+	} else {
+		c.WriteClose(closeErr.Code, "")
+	}
+	c.conn.Close()
+	return closeErr
+}
+
+// WriteMessage writes a message of the given type in a single frame.
+// It returns ErrClosed once a close frame was sent.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrame(messageType, data)
+}
+
+// WriteJSON writes v as JSON in a text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(TextMessage, data)
+}
+
+// WriteClose starts or completes the closing handshake with code and
+// reason. Later writes return ErrClosed.
+func (c *Conn) WriteClose(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(reason) > 123 {
+		reason = reason[:123]
+	}
+	payload = append(payload, reason...)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	err := c.writeFrame(CloseMessage, payload)
+	c.closeSent = true
+	return err
+}
+
+// writeFrame writes a final, unmasked frame. c.writeMu must be held.
+func (c *Conn) writeFrame(opcode int, payload []byte) error {
+	if c.closeSent {
+		return ErrClosed
+	}
+	frame := []byte{0x80 | byte(opcode)}
+	switch size := len(payload); {
+	case size <= 125:
+		frame = append(frame, byte(size))
+	case size <= 0xffff:
+		frame = binary.BigEndian.AppendUint16(append(frame, 126), uint16(size))
+	default:
+		frame = binary.BigEndian.AppendUint64(append(frame, 127), uint64(size))
+	}
+	frame = append(frame, payload...)
+
+	if c.WriteTimeout > 0 {
+		if err := c.conn.SetWriteDeadline(time.Now().Add(c.WriteTimeout)); err != nil {
+			return err
+		}
+	}
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+// Close closes the connection without a closing handshake.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
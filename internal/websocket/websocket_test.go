@@ -0,0 +1,267 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey is the key of the example handshake in RFC 6455.
+const testKey = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// testClient is the client side of a connection, which writes masked
+// frames.
+type testClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dial opens a connection to server and sends a handshake with the given
+// headers and the test key.
+func dial(t *testing.T, server *httptest.Server, header http.Header) (*testClient, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	require.NoError(t, err)
+	req.Header = header
+	require.NoError(t, req.Write(conn))
+	r := bufio.NewReader(conn)
+	res, err := http.ReadResponse(r, req)
+	require.NoError(t, err)
+	return &testClient{conn, r}, res
+}
+
+// handshake returns the headers of a valid handshake.
+func handshake() http.Header {
+	return http.Header{
+		"Connection":            {"keep-alive, Upgrade"},
+		"Upgrade":               {"websocket"},
+		"Sec-Websocket-Version": {"13"},
+		"Sec-Websocket-Key":     {testKey},
+	}
+}
+
+// writeFrame writes a frame with the given first byte and payload.
+func (c *testClient) writeFrame(t *testing.T, first byte, payload []byte) {
+	t.Helper()
+	frame := []byte{first}
+	switch {
+	case len(payload) <= 125:
+		frame = append(frame, 0x80|byte(len(payload)))
+	default:
+		frame = binary.BigEndian.AppendUint16(append(frame, 0x80|126), uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	_, err := c.conn.Write(frame)
+	require.NoError(t, err)
+}
+
+// readFrame reads an unmasked frame and returns its opcode and payload.
+func (c *testClient) readFrame(t *testing.T) (int, []byte) {
+	t.Helper()
+	var header [2]byte
+	_, err := io.ReadFull(c.r, header[:])
+	require.NoError(t, err)
+	require.Zero(t, header[1]&0x80, "server frames must not be masked")
+	size := int(header[1] & 0x7f)
+	if size == 126 {
+		var ext [2]byte
+		_, err := io.ReadFull(c.r, ext[:])
+		require.NoError(t, err)
+		size = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, size)
+	_, err = io.ReadFull(c.r, payload)
+	require.NoError(t, err)
+	return int(header[0] & 0x0f), payload
+}
+
+// closePayload returns the payload of a close frame with code and reason.
+func closePayload(code int, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, uint16(code)), reason...)
+}
+
+// echoServer starts a server that upgrades requests and echoes messages
+// until reading fails. The error that ended it is sent to errs.
+func echoServer(t *testing.T, maxSize int64) (*httptest.Server, chan error) {
+	errs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+		conn.MaxMessageSize = maxSize
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				errs <- err
+				return
+			}
+			if err := conn.WriteMessage(typ, data); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, errs
+}
+
+func TestUpgrade(t *testing.T) {
+	server, _ := echoServer(t, DefaultMaxMessageSize)
+
+	tests := []struct {
+		name   string
+		modify func(h http.Header)
+		status int
+	}{
+		{"valid", func(h http.Header) {}, http.StatusSwitchingProtocols},
+		{"no upgrade", func(h http.Header) { h.Del("Upgrade") }, http.StatusBadRequest},
+		{"no connection upgrade", func(h http.Header) { h.Set("Connection", "keep-alive") }, http.StatusBadRequest},
+		{"old version", func(h http.Header) { h.Set("Sec-Websocket-Version", "8") }, http.StatusBadRequest},
+		{"short key", func(h http.Header) { h.Set("Sec-Websocket-Key", "c2hvcnQ=") }, http.StatusBadRequest},
+		{"invalid key", func(h http.Header) { h.Set("Sec-Websocket-Key", "not base64!") }, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			header := handshake()
+			tt.modify(header)
+
+			// Act
+			_, res := dial(t, server, header)
+
+			// Assert
+			assert.Equal(t, tt.status, res.StatusCode)
+			if tt.status == http.StatusSwitchingProtocols {
+				assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", res.Header.Get("Sec-Websocket-Accept"))
+				assert.Equal(t, "websocket", res.Header.Get("Upgrade"))
+			}
+		})
+	}
+}
+
+func TestConn_Messages(t *testing.T) {
+	// Arrange
+	server, errs := echoServer(t, DefaultMaxMessageSize)
+	client, _ := dial(t, server, handshake())
+	long := strings.Repeat("x", 300)
+
+	// Act
+	client.writeFrame(t, 0x81, []byte("hello"))
+	helloType, hello := client.readFrame(t)
+	client.writeFrame(t, 0x01, []byte("hel"))
+	client.writeFrame(t, 0x89, []byte("ping"))
+	pongType, pong := client.readFrame(t)
+	client.writeFrame(t, 0x80, []byte("lo again"))
+	fragmentedType, fragmented := client.readFrame(t)
+	client.writeFrame(t, 0x82, []byte(long))
+	longType, longData := client.readFrame(t)
+	client.writeFrame(t, 0x88, closePayload(CloseGoingAway, "bye"))
+	closeType, closeData := client.readFrame(t)
+
+	// Assert
+	assert.Equal(t, TextMessage, helloType)
+	assert.Equal(t, "hello", string(hello))
+	assert.Equal(t, PongMessage, pongType)
+	assert.Equal(t, "ping", string(pong))
+	assert.Equal(t, TextMessage, fragmentedType)
+	assert.Equal(t, "hello again", string(fragmented))
+	assert.Equal(t, BinaryMessage, longType)
+	assert.Equal(t, long, string(longData))
+	assert.Equal(t, CloseMessage, closeType)
+	assert.Equal(t, closePayload(CloseGoingAway, ""), closeData)
+	var closeErr *CloseError
+	require.ErrorAs(t, <-errs, &closeErr)
+	assert.Equal(t, &CloseError{Code: CloseGoingAway, Reason: "bye"}, closeErr)
+}
+
+func TestConn_ProtocolErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		frames [][]byte
+		code   int
+		err    error
+	}{
+		{"unmasked frame", [][]byte{{0x81, 0x02, 'h', 'i'}}, CloseProtocolError, ErrProtocol},
+		{"reserved bits", [][]byte{{0xc1, 0x80, 0, 0, 0, 0}}, CloseProtocolError, ErrProtocol},
+		{"unknown opcode", [][]byte{{0x83, 0x80, 0, 0, 0, 0}}, CloseProtocolError, ErrProtocol},
+		{"continuation without message", [][]byte{{0x80, 0x80, 0, 0, 0, 0}}, CloseProtocolError, ErrProtocol},
+		{"message inside message", [][]byte{{0x01, 0x80, 0, 0, 0, 0}, {0x81, 0x80, 0, 0, 0, 0}}, CloseProtocolError, ErrProtocol},
+		{"fragmented ping", [][]byte{{0x09, 0x80, 0, 0, 0, 0}}, CloseProtocolError, ErrProtocol},
+		{"too big", [][]byte{{0x81, 0x80 | 17, 0, 0, 0, 0}}, CloseMessageTooBig, ErrMessageTooBig},
+		{"too big in fragments", [][]byte{append([]byte{0x01, 0x80 | 10, 0, 0, 0, 0}, "0123456789"...), {0x80, 0x80 | 7, 0, 0, 0, 0}}, CloseMessageTooBig, ErrMessageTooBig},
+		{"invalid utf-8", [][]byte{{0x81, 0x82, 0, 0, 0, 0, 0xff, 0xfe}}, CloseInvalidData, ErrProtocol},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			server, errs := echoServer(t, 16)
+			client, _ := dial(t, server, handshake())
+
+			// Act
+			for _, frame := range tt.frames {
+				_, err := client.conn.Write(frame)
+				require.NoError(t, err)
+			}
+			opcode, payload := client.readFrame(t)
+
+			// Assert
+			assert.Equal(t, CloseMessage, opcode)
+			assert.Equal(t, tt.code, int(binary.BigEndian.Uint16(payload)))
+			err := <-errs
+			assert.True(t, errors.Is(err, tt.err), err)
+		})
+	}
+}
+
+func TestConn_WriteClose(t *testing.T) {
+	// Arrange
+	conns := make(chan *Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		conns <- conn
+	}))
+	defer server.Close()
+	client, _ := dial(t, server, handshake())
+	conn := <-conns
+	defer conn.Close()
+
+	// Act
+	closeErr := conn.WriteClose(ClosePolicyViolation, "not allowed")
+	afterErr := conn.WriteJSON(map[string]string{"too": "late"})
+	client.writeFrame(t, 0x88, closePayload(ClosePolicyViolation, ""))
+	_, _, readErr := conn.ReadMessage()
+
+	// Assert
+	assert.NoError(t, closeErr)
+	assert.ErrorIs(t, afterErr, ErrClosed)
+	opcode, payload := client.readFrame(t)
+	assert.Equal(t, CloseMessage, opcode)
+	assert.Equal(t, closePayload(ClosePolicyViolation, "not allowed"), payload)
+	assert.Equal(t, &CloseError{Code: ClosePolicyViolation}, readErr)
+	_, err := client.r.ReadByte()
+	assert.ErrorIs(t, err, io.EOF)
+}